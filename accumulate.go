@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How a purchase spread over a window is priced: the plain average of its daily
+// prices, or the average weighted by each day's volume (VWAP)
+var windowFills = []string{"average", "vwap"}
+
+// A purchase accumulated over a window of trading days, filled at one price
+type windowFill struct {
+	Start       string  `json:"start"`
+	End         string  `json:"end"`
+	Fill        string  `json:"fill"`
+	Price       float64 `json:"price"`
+	TradingDays int     `json:"tradingDays"`
+	Low         float64 `json:"low"`
+	High        float64 `json:"high"`
+	Volume      float64 `json:"volume,omitempty"`
+}
+
+// Helper function to check a window fill
+func isWindowFill(fill string) bool {
+	for _, f := range windowFills {
+		if fill == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Work out the price a purchase spread over a window's daily prices fills at.
+// VWAP needs every day's volume, which not every provider reports.
+func calculateWindowFill(points []pricePoint, start, end, fill string) (windowFill, error) {
+	result := windowFill{Start: start, End: end, Fill: fill}
+	total, weighted := 0.0, 0.0
+	for _, point := range points {
+		if fill == "vwap" && point.Volume <= 0 {
+			return result, fmt.Errorf("No volume for %s: use fill=average", point.Date)
+		}
+		if result.TradingDays == 0 || point.Price < result.Low {
+			result.Low = point.Price
+		}
+		if point.Price > result.High {
+			result.High = point.Price
+		}
+		result.TradingDays++
+		total += point.Price
+		weighted += point.Price * point.Volume
+		result.Volume += point.Volume
+	}
+	if result.TradingDays == 0 {
+		return result, fmt.Errorf("No trading days between %s and %s", start, end)
+	}
+	result.Price = total / float64(result.TradingDays)
+	if fill == "vwap" {
+		result.Price = weighted / result.Volume
+	}
+	return result, nil
+}
+
+// Backtest buying over a window (/bought-between/:buyDate/:buyEnd) instead of on
+// one day, as someone accumulating a position over a few weeks would: the whole
+// amount fills at the window's average price (?fill=average, at ?priceAt=) or
+// its VWAP (?fill=vwap). Values convert at the FX rate on the window's last day.
+func handleAmountBoughtBetween(c *gin.Context) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	buyEnd := c.Param("buyEnd")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fill := strings.ToLower(c.DefaultQuery("fill", "average"))
+	if !isWindowFill(fill) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fill parameter: must be one of " + strings.Join(windowFills, ", ")})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Buying over a window is not supported for bonds or options"})
+		return
+	}
+	if hasTimeOfDay(buyDate) || hasTimeOfDay(buyEnd) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Windows are priced on daily prices: use dates without a time of day"})
+		return
+	}
+
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+	}
+
+	points, err := fetchPriceHistory(ticker, typeParam, buyDate, buyEnd, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch prices over the window", err)
+		return
+	}
+	window, err := calculateWindowFill(points, buyDate, buyEnd, fill)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to price the window", "details": err.Error()})
+		return
+	}
+	buyPrice := window.Price
+
+	shares, fxRateBuy := parsedAmount, 1.0
+	if isValue {
+		fxRateBuy, err = priceOpts.Notes.fxRate(currency, "USD", buyEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+			return
+		}
+		shares = parsedAmount * fxRateBuy / buyPrice
+	}
+
+	response := gin.H{
+		"ticker":     ticker,
+		"buyDate":    buyDate,
+		"buyEnd":     buyEnd,
+		"buyPrice":   buyPrice,
+		"shares":     shares,
+		"window":     window,
+		"type":       typeParam,
+		"priceAt":    priceOpts.At,
+		"priceBasis": priceOpts.basis(typeParam),
+	}
+	if isValue {
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["stockCurrency"] = "USD"
+		response["fxRateBuy"] = fxRateBuy
+	} else {
+		response["quantity"] = parsedAmount
+	}
+
+	if sellDate == "" {
+		if isValue {
+			response["message"] = localize(c, "Backtest result (value buy over a window)")
+		} else {
+			response["message"] = localize(c, "Backtest result (quantity buy over a window)")
+		}
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	sellPrice, err := fetchSellPrice(ticker, buyEnd, sellDate, typeParam, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch sell price", err)
+		return
+	}
+
+	// Positions and cash received from spin-offs and mergers once the window closed
+	actions, err := applyCorporateActions(ticker, typeParam, buyEnd, sellDate, constantShares(shares), priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+		return
+	}
+	finalValueUSD := shares*sellPrice + actions.Value
+	investedUSD := shares * buyPrice
+
+	response["sellDate"] = sellDate
+	response["sellPrice"] = sellPrice
+	response["corporateActions"] = actions
+	if isValue {
+		fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			return
+		}
+		response["message"] = localize(c, "Backtest result (value buy over a window/sell)")
+		response["finalValueUSD"] = finalValueUSD
+		response["finalValueInOriginalCurrency"] = finalValueUSD * fxRateSell
+		response["fxRateSell"] = fxRateSell
+		response["returnPercent"] = (finalValueUSD*fxRateSell/parsedAmount - 1) * 100
+		addHedgedReturn(response, c.Query("hedged") == "true", currency, parsedAmount, finalValueUSD*fxRateSell, fxRateBuy, fxRateSell)
+	} else {
+		response["message"] = localize(c, "Backtest result (quantity buy over a window/sell)")
+		response["finalValue"] = finalValueUSD
+		response["returnPercent"] = (finalValueUSD/investedUSD - 1) * 100
+	}
+	addMood(response, c.Query("mood") == "true", investedUSD, finalValueUSD, buyDate, sellDate)
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a window fills at the average of its daily prices, or weighted by volume
+func TestCalculateWindowFill(t *testing.T) {
+	points := []pricePoint{
+		{Date: "2025-06-02", Price: 100, Volume: 1000},
+		{Date: "2025-06-03", Price: 110, Volume: 3000},
+		{Date: "2025-06-04", Price: 90, Volume: 1000},
+	}
+	result, err := calculateWindowFill(points, "2025-06-01", "2025-06-04", "average")
+	assert.NoError(t, err)
+	assert.InDelta(t, 100, result.Price, 1e-9)
+	assert.Equal(t, 3, result.TradingDays)
+	assert.Equal(t, 90.0, result.Low)
+	assert.Equal(t, 110.0, result.High)
+
+	result, err = calculateWindowFill(points, "2025-06-01", "2025-06-04", "vwap")
+	assert.NoError(t, err)
+	assert.InDelta(t, (100*1000+110*3000+90*1000)/5000.0, result.Price, 1e-9)
+	assert.Equal(t, 5000.0, result.Volume)
+
+	// VWAP needs every day's volume
+	points[1].Volume = 0
+	_, err = calculateWindowFill(points, "2025-06-01", "2025-06-04", "vwap")
+	assert.ErrorContains(t, err, "No volume for 2025-06-03")
+	_, err = calculateWindowFill(nil, "2025-06-01", "2025-06-04", "average")
+	assert.Error(t, err)
+}
+
+// Test the bought-between routes against the mocked daily prices
+func TestBoughtBetweenWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	mockStockData["2025-06-20"]["5. volume"] = "1000"
+	mockStockData["2025-06-30"]["5. volume"] = "3000"
+	t.Cleanup(func() {
+		delete(mockStockData["2025-06-20"], "5. volume")
+		delete(mockStockData["2025-06-30"], "5. volume")
+	})
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		BuyPrice      float64    `json:"buyPrice"`
+		Shares        float64    `json:"shares"`
+		Window        windowFill `json:"window"`
+		SellPrice     float64    `json:"sellPrice"`
+		FinalValue    float64    `json:"finalValue"`
+		ReturnPercent float64    `json:"returnPercent"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/bought-between/2025-06-20/2025-06-30/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	average := (205.75 + 205.17) / 2
+	assert.InDelta(t, average, response.BuyPrice, 1e-9)
+	assert.Equal(t, 2, response.Window.TradingDays)
+	assert.Equal(t, "average", response.Window.Fill)
+	assert.InDelta(t, 10*211.18, response.FinalValue, 1e-9)
+	assert.InDelta(t, (211.18/average-1)*100, response.ReturnPercent, 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/bought-between/2025-06-20/2025-06-30?fill=vwap")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, (205.75*1000+205.17*3000)/4000, response.BuyPrice, 1e-9)
+	assert.NotContains(t, w.Body.String(), "sellPrice")
+
+	// A one-day window buys the same as on that day
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/bought-between/2025-03-31/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 1080/200.50, response.Shares, 1e-9)
+
+	for _, path := range []string{
+		"/10/AAPL/bought-between/2025-03-31/2025-06-30?fill=vwap", // no volume on 2025-03-31
+		"/10/AAPL/bought-between/2025-06-20/2025-06-30?fill=twap",
+		"/10/AAPL/bought-between/2025-06-30/2025-06-20",
+		"/10/AAPL/bought-between/2025-06-20/2025-07-18/and-sold-on/2025-07-17",
+		"/10/AAPL/bought-between/2025-06-20/2025-06-30?limitPrice=200",
+		"/10/AAPL/bought-between/2025-06-20/2025-06-30/and-sold-on/2025-07-18?type=bond",
+	} {
+		w = makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
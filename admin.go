@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware requiring "Authorization: Bearer <token>". Without a token only
+// local requests (loopback or a Unix socket) get through.
+func withAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			if !isLocalRemoteAddr(c.Request.RemoteAddr) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Set ADMIN_TOKEN to reach the admin endpoints from another host"})
+				return
+			}
+			c.Next()
+			return
+		}
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or wrong admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Helper function to tell whether a request came over loopback or a Unix socket,
+// whose peers have no host:port address
+func isLocalRemoteAddr(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr == "" || remoteAddr == "@"
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Helper function to count the codes in a provider's currency list, or -1 if it
+// hasn't been fetched yet
+func (l *currencyList) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.codes == nil {
+		return -1
+	}
+	return len(l.codes)
+}
+
+// Report memory, GC and goroutine stats
+func handleRuntimeStats(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	var gc debug.GCStats
+	debug.ReadGCStats(&gc)
+
+	lastGC := ""
+	if !gc.LastGC.IsZero() {
+		lastGC = gc.LastGC.UTC().Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"goVersion":  runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"memory": gin.H{
+			"heapAllocBytes":  mem.HeapAlloc,
+			"heapInuseBytes":  mem.HeapInuse,
+			"heapObjects":     mem.HeapObjects,
+			"totalAllocBytes": mem.TotalAlloc,
+			"systemBytes":     mem.Sys,
+			"nextGCBytes":     mem.NextGC,
+			"stackInuseBytes": mem.StackInuse,
+			"mallocs":         mem.Mallocs,
+			"frees":           mem.Frees,
+		},
+		"gc": gin.H{
+			"count":        gc.NumGC,
+			"lastGC":       lastGC,
+			"pauseTotalMs": float64(gc.PauseTotal) / float64(time.Millisecond),
+			"cpuFraction":  mem.GCCPUFraction,
+		},
+	})
+}
+
+// Report what the in-memory caches and datasets hold. Currency lists not yet
+// fetched are -1; datasets are loaded if they weren't already.
+func handleCacheStats(c *gin.Context) {
+	searchedCoinIDsMu.Lock()
+	coinIDs := make(map[string]string, len(searchedCoinIDs))
+	for symbol, coinID := range searchedCoinIDs {
+		coinIDs[symbol] = coinID
+	}
+	searchedCoinIDsMu.Unlock()
+
+	datasets := gin.H{}
+	if dataset, err := loadFXDataset(); err == nil {
+		rows := 0
+		for _, rates := range dataset {
+			rows += len(rates)
+		}
+		datasets["fxRates"] = gin.H{"currencies": len(dataset), "rows": rows}
+	} else {
+		datasets["fxRates"] = gin.H{"error": err.Error()}
+	}
+	if actions, err := loadCorporateActions(); err == nil {
+		datasets["corporateActions"] = gin.H{"rows": len(actions)}
+	} else {
+		datasets["corporateActions"] = gin.H{"error": err.Error()}
+	}
+	if prices, err := loadItemPrices(); err == nil {
+		datasets["itemPrices"] = gin.H{"rows": len(prices)}
+	} else {
+		datasets["itemPrices"] = gin.H{"error": err.Error()}
+	}
+
+	size, hits, misses := prices.stats()
+	latestSize, latestHits, latestMisses := latest.stats()
+	c.JSON(http.StatusOK, gin.H{
+		"prices": gin.H{"size": size, "hits": hits, "misses": misses},
+		"latest": gin.H{"size": latestSize, "hits": latestHits, "misses": latestMisses},
+		"currencyLists": gin.H{
+			"frankfurter":      frankfurterCurrencies.size(),
+			"exchangeRateHost": exchangeRateHostCurrencies.size(),
+		},
+		"searchedCoinIDs": coinIDs,
+		"datasets":        datasets,
+	})
+}
+
+// Most cached prices listed by GET /admin/prices
+const maxListedPrices = 1000
+
+// Helper function to read the price cache filter from ?ticker=, ?type=, ?from= and ?to=
+func priceFilterParams(c *gin.Context) (priceFilter, error) {
+	filter := priceFilter{Ticker: c.Query("ticker"), AssetType: c.Query("type"), From: c.Query("from"), To: c.Query("to")}
+	if filter.AssetType != "" && !isValidAssetType(filter.AssetType) {
+		return filter, fmt.Errorf("Invalid type parameter: must be one of %s", strings.Join(assetTypes, ", "))
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			return filter, fmt.Errorf("Invalid from or to parameter: must be YYYY-MM-DD")
+		}
+	}
+	return filter, nil
+}
+
+// Show the cached prices matching ?ticker=, ?type=, ?from= and ?to=, with the
+// cache's size and hit rate
+func handleListPrices(c *gin.Context) {
+	filter, err := priceFilterParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	matching := prices.list(filter)
+	size, hits, misses := prices.stats()
+	maxSize := settings().PriceCacheSize
+
+	c.JSON(http.StatusOK, gin.H{
+		"size":      size,
+		"maxSize":   maxSize,
+		"hits":      hits,
+		"misses":    misses,
+		"matching":  len(matching),
+		"truncated": len(matching) > maxListedPrices,
+		"prices":    matching[:min(len(matching), maxListedPrices)],
+	})
+}
+
+// Drop the cached prices matching ?ticker=, ?type=, ?from= and ?to=, so they're
+// fetched again. Clearing the whole cache takes ?all=true.
+func handleInvalidatePrices(c *gin.Context) {
+	filter, err := priceFilterParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if filter == (priceFilter{}) && c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Give a ticker, type, from or to, or all=true to clear the whole cache"})
+		return
+	}
+	removed := prices.invalidate(filter)
+	log.Printf("Admin: invalidated %d cached prices (ticker=%q type=%q from=%q to=%q)", removed, filter.Ticker, filter.AssetType, filter.From, filter.To)
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+// Report outbound requests per provider today and since startup, and what's left
+// of each PROVIDER_DAILY_LIMITS allowance
+func handleProviderQuota(c *gin.Context) {
+	report := providerUsageReport()
+	c.JSON(http.StatusOK, gin.H{"providers": report})
+}
+
+// Router for the admin listener: pprof profiles under /debug/pprof/, runtime and GC
+// stats at /debug/runtime, cache contents at /debug/caches, the price cache at
+// /admin/prices, provider quota use at /admin/quota and price check discrepancies
+// at /admin/price-checks
+func newAdminRouter(token string) *gin.Engine {
+	r := gin.New()
+	r.Use(gin.Recovery(), withAdminToken(token))
+
+	r.GET("/debug/runtime", handleRuntimeStats)
+	r.GET("/debug/caches", handleCacheStats)
+	r.GET("/admin/prices", handleListPrices)
+	r.DELETE("/admin/prices", handleInvalidatePrices)
+	r.GET("/admin/quota", handleProviderQuota)
+	r.GET("/admin/price-checks", handlePriceChecks)
+
+	r.GET("/debug/pprof/", gin.WrapF(pprof.Index))
+	r.GET("/debug/pprof/cmdline", gin.WrapF(pprof.Cmdline))
+	r.GET("/debug/pprof/profile", gin.WrapF(pprof.Profile))
+	r.GET("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	r.POST("/debug/pprof/symbol", gin.WrapF(pprof.Symbol))
+	r.GET("/debug/pprof/trace", gin.WrapF(pprof.Trace))
+	r.GET("/debug/pprof/:profile", func(c *gin.Context) {
+		pprof.Handler(c.Param("profile")).ServeHTTP(c.Writer, c.Request)
+	})
+	return r
+}
+
+// Serve the admin endpoints on ADMIN_ADDRESS, if set. They aren't behind the API's
+// limits or CORS, so ADMIN_TOKEN is required unless it's bound to localhost.
+func serveAdmin(cfg Config) {
+	if cfg.AdminAddress == "" {
+		return
+	}
+	listener, err := listen(cfg.AdminAddress)
+	if err != nil {
+		log.Fatalf("Admin listener: %v", err)
+	}
+	server := &http.Server{
+		Handler:           newAdminRouter(cfg.AdminToken),
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	log.Printf("Admin endpoints on %s", listener.Addr())
+	go func() {
+		log.Fatal(server.Serve(listener))
+	}()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to make a local admin request with an optional bearer token
+func makeAdminRequest(token, path, bearer string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = "127.0.0.1:52000"
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	newAdminRouter(token).ServeHTTP(w, req)
+	return w
+}
+
+// Test the admin token is required when set
+func TestAdminToken(t *testing.T) {
+	assert.Equal(t, http.StatusOK, makeAdminRequest("", "/debug/runtime", "").Code)
+
+	w := makeAdminRequest("s3cret", "/debug/runtime", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="admin"`, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, makeAdminRequest("s3cret", "/debug/pprof/", "wrong").Code)
+	assert.Equal(t, http.StatusOK, makeAdminRequest("s3cret", "/debug/pprof/", "s3cret").Code)
+}
+
+// Test requests from other hosts are turned away when no token is set
+func TestAdminWithoutToken(t *testing.T) {
+	for remoteAddr, code := range map[string]int{
+		"192.0.2.1:52000": http.StatusForbidden,
+		"[::1]:52000":     http.StatusOK,
+		"@":               http.StatusOK, // Unix socket
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		newAdminRouter("").ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, remoteAddr)
+	}
+
+	// A token lets other hosts in
+	req := httptest.NewRequest(http.MethodGet, "/debug/runtime", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	newAdminRouter("s3cret").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The config won't put a tokenless admin listener anywhere but localhost
+	cfg := defaultConfig()
+	cfg.AlphaVantageAPIKey = "demo"
+	for address, ok := range map[string]bool{
+		"127.0.0.1:6060":             true,
+		"localhost:6060":             true,
+		"unix:/run/ifyoubought.sock": true,
+		":6060":                      false,
+		"0.0.0.0:6060":               false,
+	} {
+		cfg.AdminAddress, cfg.AdminToken = address, ""
+		if ok {
+			assert.NoError(t, cfg.validate(), address)
+		} else {
+			assert.ErrorContains(t, cfg.validate(), "admin_token must be set", address)
+		}
+		cfg.AdminToken = "s3cret"
+		assert.NoError(t, cfg.validate(), address)
+	}
+}
+
+// Test pprof profiles are served
+func TestAdminPprof(t *testing.T) {
+	w := makeAdminRequest("", "/debug/pprof/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+
+	w = makeAdminRequest("", "/debug/pprof/goroutine?debug=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine profile")
+
+	w = makeAdminRequest("", "/debug/pprof/cmdline", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// Test runtime and cache stats
+func TestAdminStats(t *testing.T) {
+	w := makeAdminRequest("", "/debug/runtime", "")
+	var runtimeStats map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &runtimeStats))
+	assert.Greater(t, runtimeStats["goroutines"], 0.0)
+	assert.Contains(t, runtimeStats["memory"], "heapAllocBytes")
+	assert.Contains(t, runtimeStats["gc"], "count")
+
+	searchedCoinIDsMu.Lock()
+	searchedCoinIDs["TESTCOIN"] = "test-coin"
+	searchedCoinIDsMu.Unlock()
+	defer func() {
+		searchedCoinIDsMu.Lock()
+		delete(searchedCoinIDs, "TESTCOIN")
+		searchedCoinIDsMu.Unlock()
+	}()
+
+	w = makeAdminRequest("", "/debug/caches", "")
+	var cacheStats struct {
+		CurrencyLists   map[string]int                    `json:"currencyLists"`
+		SearchedCoinIDs map[string]string                 `json:"searchedCoinIDs"`
+		Datasets        map[string]map[string]interface{} `json:"datasets"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cacheStats))
+	assert.Contains(t, cacheStats.CurrencyLists, "frankfurter")
+	assert.Equal(t, "test-coin", cacheStats.SearchedCoinIDs["TESTCOIN"])
+	assert.Greater(t, cacheStats.Datasets["corporateActions"]["rows"], 0.0)
+	assert.Greater(t, cacheStats.Datasets["itemPrices"]["rows"], 0.0)
+}
+
+// Test the price cache is inspected and invalidated
+func TestAdminPrices(t *testing.T) {
+	prices.invalidate(priceFilter{})
+	defer prices.invalidate(priceFilter{})
+	prices.put(newPriceKey("AAPL", "2025-07-17", "stock", priceOptions{At: "close"}), 210.02)
+	prices.put(newPriceKey("AAPL", "2025-07-18", "stock", priceOptions{At: "close"}), 211.18)
+	prices.put(newPriceKey("MSFT", "2025-07-18", "stock", priceOptions{At: "close"}), 510.05)
+
+	w := makeAdminRequest("", "/admin/prices?ticker=aapl", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Size     int           `json:"size"`
+		Matching int           `json:"matching"`
+		Prices   []cachedPrice `json:"prices"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Equal(t, 3, listed.Size)
+	assert.Equal(t, 2, listed.Matching)
+	assert.Equal(t, 210.02, listed.Prices[0].Price)
+	assert.Equal(t, http.StatusBadRequest, makeAdminRequest("", "/admin/prices?from=yesterday", "").Code)
+
+	invalidate := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/admin/prices"+query, nil)
+		req.RemoteAddr = "127.0.0.1:52000"
+		w := httptest.NewRecorder()
+		newAdminRouter("").ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusBadRequest, invalidate("").Code)
+	w = invalidate("?ticker=AAPL&from=2025-07-18&to=2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"removed": 1}`, w.Body.String())
+	w = invalidate("?all=true")
+	assert.JSONEq(t, `{"removed": 2}`, w.Body.String())
+}
+
+// Test provider quota use is reported
+func TestAdminQuota(t *testing.T) {
+	recordProviderRequest("quota.test.invalid", http.StatusOK, false)
+	w := makeAdminRequest("", "/admin/quota", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"provider":"quota.test.invalid"`)
+	assert.Contains(t, w.Body.String(), `"provider":"alphaVantage"`)
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Alert states
+const (
+	alertActive    = "active"
+	alertTriggered = "triggered"
+)
+
+// Most active alerts one API key can have
+const maxActiveAlertsPerKey = 50
+
+// A watch on a hypothetical position: notify by email or webhook once its value
+// crosses a threshold, in the amount's currency (USD for quantities). Owner is
+// the hash of the API key that registered it; it's saved but never shown.
+type alert struct {
+	ID            string     `json:"id"`
+	Owner         string     `json:"owner,omitempty"`
+	Amount        string     `json:"amount"`
+	Ticker        string     `json:"ticker"`
+	Type          string     `json:"type"`
+	BuyDate       string     `json:"buyDate"`
+	Currency      string     `json:"currency"`
+	Shares        float64    `json:"shares"`
+	Direction     string     `json:"direction"` // above or below
+	Threshold     float64    `json:"threshold"`
+	Email         string     `json:"email,omitempty"`
+	WebhookURL    string     `json:"webhookUrl,omitempty"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	LastCheckedAt *time.Time `json:"lastCheckedAt,omitempty"`
+	LastValue     *float64   `json:"lastValue,omitempty"`
+	LastError     string     `json:"lastError,omitempty"`
+	TriggeredAt   *time.Time `json:"triggeredAt,omitempty"`
+}
+
+// Helper function to check whether a value has crossed the alert's threshold
+func (a alert) crossed(value float64) bool {
+	if a.Direction == "below" {
+		return value <= a.Threshold
+	}
+	return value >= a.Threshold
+}
+
+// How alerts are delivered: signed webhooks, and email through an SMTP server
+type alertNotifier struct {
+	WebhookSecret       string
+	WebhookAllowPrivate bool
+	SMTPAddr            string
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+}
+
+// Send an alert's notification for the value that triggered it
+func (n alertNotifier) notify(a alert, value float64) error {
+	summary := fmt.Sprintf("Your hypothetical %s of %s bought on %s is now worth %s, %s your alert at %s",
+		a.Amount, a.Ticker, a.BuyDate, formatMoney(value, a.Currency, "en"), a.Direction, formatMoney(a.Threshold, a.Currency, "en"))
+
+	if a.WebhookURL != "" {
+		body, err := json.Marshal(gin.H{"message": summary, "value": value, "alert": a})
+		if err != nil {
+			return err
+		}
+		return postWebhook(a.WebhookURL, n.WebhookSecret, a.ID, body, n.WebhookAllowPrivate)
+	}
+
+	var auth smtp.Auth
+	if n.SMTPUsername != "" {
+		host := strings.Split(n.SMTPAddr, ":")[0]
+		auth = smtp.PlainAuth("", n.SMTPUsername, n.SMTPPassword, host)
+	}
+	message := "From: " + n.SMTPFrom + "\r\n" +
+		"To: " + a.Email + "\r\n" +
+		"Subject: " + a.Ticker + " alert: " + a.Amount + " from " + a.BuyDate + " is " + a.Direction + " " + formatMoney(a.Threshold, a.Currency, "en") + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" +
+		summary + ".\r\n"
+	return smtp.SendMail(n.SMTPAddr, auth, n.SMTPFrom, []string{a.Email}, []byte(message))
+}
+
+// Value an alert's position at the latest price, in its currency
+func positionValue(a alert) (float64, error) {
+	return latestValue(a.Ticker, a.Type, a.Currency, a.Shares)
+}
+
+// Alerts, saved as JSON at path after every change, and checked on a schedule.
+// Each belongs to the API key (X-API-Key) that registered it.
+type alertStore struct {
+	path     string
+	notifier alertNotifier
+	value    func(a alert) (float64, error)
+
+	mu     sync.Mutex
+	alerts map[string]*alert
+}
+
+// Load the alerts saved at path, if any
+func newAlertStore(path string, notifier alertNotifier) (*alertStore, error) {
+	s := &alertStore{path: path, notifier: notifier, value: positionValue, alerts: map[string]*alert{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved []*alert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("Alerts file %s: %v", path, err)
+	}
+	for _, a := range saved {
+		s.alerts[a.ID] = a
+	}
+	return s, nil
+}
+
+// Write the alerts to the file, oldest first, replacing it in one step. Callers hold s.mu.
+func (s *alertStore) save() error {
+	saved := make([]*alert, 0, len(s.alerts))
+	for _, a := range s.alerts {
+		saved = append(saved, a)
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].CreatedAt.Before(saved[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+// Value every active alert at the latest prices and notify those that crossed.
+// Alerts whose notification fails stay active and are tried again next time.
+func (s *alertStore) checkAll() {
+	s.mu.Lock()
+	var active []alert
+	for _, a := range s.alerts {
+		if a.Status == alertActive {
+			active = append(active, *a)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, a := range active {
+		value, err := s.value(a)
+
+		triggered := false
+		if err == nil && a.crossed(value) {
+			if notifyErr := s.notifier.notify(a, value); notifyErr != nil {
+				err = fmt.Errorf("Notification failed: %v", notifyErr)
+			} else {
+				triggered = true
+			}
+		}
+
+		s.mu.Lock()
+		if stored, ok := s.alerts[a.ID]; ok {
+			now := time.Now().UTC()
+			stored.LastCheckedAt, stored.LastError = &now, ""
+			if err != nil {
+				stored.LastError = err.Error()
+			} else {
+				stored.LastValue = &value
+			}
+			if triggered {
+				stored.Status, stored.TriggeredAt = alertTriggered, &now
+			}
+			if err := s.save(); err != nil {
+				log.Printf("Saving alerts failed: %v", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Check the alerts every interval
+func (s *alertStore) run(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			s.checkAll()
+		}
+	}()
+}
+
+// Request body of POST /v1/alerts
+type alertRequest struct {
+	Amount     string  `json:"amount" binding:"required"`
+	Ticker     string  `json:"ticker" binding:"required"`
+	BuyDate    string  `json:"buyDate" binding:"required"`
+	Type       string  `json:"type"`
+	Threshold  float64 `json:"threshold" binding:"required"`
+	Direction  string  `json:"direction"`
+	Email      string  `json:"email"`
+	WebhookURL string  `json:"webhookUrl"`
+}
+
+// Register an alert: POST /v1/alerts {"amount": "1000USD", "ticker": "AAPL", "buyDate":
+// "2020-03-20", "threshold": 5000, "email": "you@example.com"}. Without a direction,
+// the alert fires when the value crosses the threshold from where it is now.
+func (s *alertStore) handleCreate(c *gin.Context) {
+	var request alertRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert request", "details": err.Error()})
+		return
+	}
+
+	if err := checkDateParam("buyDate", request.BuyDate); err != nil {
+		abortWithAssetDateError(c, err, request.Ticker, request.Type)
+		return
+	}
+
+	assetType := request.Type
+	if assetType == "" {
+		assetType = "stock"
+		if isIndexTicker(request.Ticker) {
+			assetType = "index"
+		}
+	}
+	if !isValidAssetType(assetType) || isModelledType(assetType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: alerts support stock, crypto, index and commodity"})
+		return
+	}
+	if request.Threshold <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid threshold: must be positive"})
+		return
+	}
+	if request.Direction != "" && request.Direction != "above" && request.Direction != "below" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid direction: must be above or below"})
+		return
+	}
+
+	switch {
+	case (request.Email == "") == (request.WebhookURL == ""):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert delivery", "details": "give one of email or webhookUrl"})
+		return
+	case request.Email != "":
+		if s.notifier.SMTPAddr == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert delivery", "details": "email alerts are off: the server has no SMTP_ADDR"})
+			return
+		}
+		if _, err := mail.ParseAddress(request.Email); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email", "details": err.Error()})
+			return
+		}
+	default:
+		if s.notifier.WebhookSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert delivery", "details": "webhook alerts are off: the server has no WEBHOOK_SECRET to sign them with"})
+			return
+		}
+		if err := checkCallbackURL(request.WebhookURL, s.notifier.WebhookAllowPrivate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL", "details": err.Error()})
+			return
+		}
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(request.Amount, c.Query("locale"))
+	if err != nil || parsedAmount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": fmt.Sprint(err)})
+		return
+	}
+
+	a := alert{
+		ID:         newRandomID(),
+		Amount:     request.Amount,
+		Ticker:     strings.ToUpper(request.Ticker),
+		Type:       assetType,
+		BuyDate:    request.BuyDate,
+		Currency:   "USD",
+		Shares:     parsedAmount,
+		Direction:  request.Direction,
+		Threshold:  request.Threshold,
+		Email:      request.Email,
+		WebhookURL: request.WebhookURL,
+		Status:     alertActive,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if isValue {
+		a.Currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+		fxRate, err := getHistoricalFXRate(a.Currency, "USD", request.BuyDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate", "details": err.Error()})
+			return
+		}
+		buyPrice, err := fetchPrice(a.Ticker, request.BuyDate, assetType, priceOptions{At: "close"})
+		if err != nil {
+			respondPriceError(c, "Failed to fetch stock price", err)
+			return
+		}
+		a.Shares = parsedAmount * fxRate / buyPrice
+	}
+
+	value, err := s.value(a)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch latest price", "details": err.Error()})
+		return
+	}
+	if a.Direction == "" {
+		a.Direction = "above"
+		if request.Threshold < value {
+			a.Direction = "below"
+		}
+	}
+	now := time.Now().UTC()
+	a.LastValue, a.LastCheckedAt = &value, &now
+	a.Owner = c.GetString("apiKeyOwner")
+
+	s.mu.Lock()
+	if s.activeAlerts(a.Owner) >= maxActiveAlertsPerKey {
+		s.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("An API key can have at most %d active alerts", maxActiveAlertsPerKey)})
+		return
+	}
+	stored := a
+	s.alerts[a.ID] = &stored
+	err = s.save()
+	s.mu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save alert", "details": err.Error()})
+		return
+	}
+
+	a.Owner = ""
+	c.Header("Location", "/v1/alerts/"+a.ID)
+	c.JSON(http.StatusCreated, a)
+}
+
+// Helper function to count an owner's active alerts. Callers hold s.mu.
+func (s *alertStore) activeAlerts(owner string) int {
+	count := 0
+	for _, a := range s.alerts {
+		if a.Owner == owner && a.Status == alertActive {
+			count++
+		}
+	}
+	return count
+}
+
+// Helper function to look up one of the caller's alerts. Callers hold s.mu.
+func (s *alertStore) find(c *gin.Context) (*alert, bool) {
+	a, ok := s.alerts[c.Param("id")]
+	if !ok || a.Owner != c.GetString("apiKeyOwner") {
+		return nil, false
+	}
+	return a, true
+}
+
+// Show one of the caller's alerts and its last check
+func (s *alertStore) handleGet(c *gin.Context) {
+	s.mu.Lock()
+	a, ok := s.find(c)
+	var snapshot alert
+	if ok {
+		snapshot = *a
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	snapshot.Owner = ""
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// Delete one of the caller's alerts
+func (s *alertStore) handleDelete(c *gin.Context) {
+	s.mu.Lock()
+	_, ok := s.find(c)
+	var err error
+	if ok {
+		delete(s.alerts, c.Param("id"))
+		err = s.save()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save alerts", "details": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
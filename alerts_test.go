@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to set up a router with the alerts API over a store in a
+// temporary file, for the keys "alice" and "bob", valuing positions at price per
+// share
+func setupAlertsRouter(t *testing.T, notifier alertNotifier, price float64) (*gin.Engine, *alertStore) {
+	setupMockAlphaVantage(t)
+	gin.SetMode(gin.TestMode)
+
+	alerts, err := newAlertStore(filepath.Join(t.TempDir(), "alerts.json"), notifier)
+	assert.NoError(t, err)
+	alerts.value = func(a alert) (float64, error) { return a.Shares * price, nil }
+
+	router := gin.New()
+	routes := router.Group("/v1/alerts", withAPIKey([]string{"alice", "bob"}))
+	routes.POST("", alerts.handleCreate)
+	routes.GET("/:id", alerts.handleGet)
+	routes.DELETE("/:id", alerts.handleDelete)
+	return router, alerts
+}
+
+// Helper function to call the alerts API as the holder of key
+func callAlerts(router *gin.Engine, key, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to register an alert as alice
+func createAlert(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	return callAlerts(router, "alice", "POST", "/v1/alerts", body)
+}
+
+// Test an alert is sized from the buy date, saved, shown and deleted
+func TestAlertLifecycle(t *testing.T) {
+	router, alerts := setupAlertsRouter(t, alertNotifier{SMTPAddr: "smtp.example.com:25", SMTPFrom: "alerts@example.com"}, 250)
+
+	w := createAlert(router, `{"amount": "1000USD", "ticker": "aapl", "buyDate": "2025-07-18", "threshold": 5000, "email": "you@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "/v1/alerts/"+created.ID, w.Header().Get("Location"))
+	assert.Equal(t, "AAPL", created.Ticker)
+	assert.Equal(t, "stock", created.Type)
+	assert.Equal(t, "USD", created.Currency)
+	assert.InDelta(t, 1000/211.18, created.Shares, 1e-9)
+	assert.InDelta(t, 1000/211.18*250, *created.LastValue, 1e-9)
+	assert.Equal(t, "above", created.Direction)
+	assert.Equal(t, alertActive, created.Status)
+
+	// Saved alerts survive a restart
+	reloaded, err := newAlertStore(alerts.path, alerts.notifier)
+	assert.NoError(t, err)
+	assert.Contains(t, reloaded.alerts, created.ID)
+
+	w = callAlerts(router, "alice", "GET", "/v1/alerts/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = callAlerts(router, "alice", "DELETE", "/v1/alerts/"+created.ID, "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, callAlerts(router, "alice", "GET", "/v1/alerts/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, callAlerts(router, "alice", "DELETE", "/v1/alerts/"+created.ID, "").Code)
+}
+
+// Test alerts need an API key, belong to it, and are capped per key
+func TestAlertOwnership(t *testing.T) {
+	router, alerts := setupAlertsRouter(t, alertNotifier{SMTPAddr: "smtp.example.com:25", SMTPFrom: "alerts@example.com"}, 250)
+	body := `{"amount": "10", "ticker": "AAPL", "buyDate": "2025-07-18", "threshold": 5000, "email": "you@example.com"}`
+
+	assert.Equal(t, http.StatusUnauthorized, callAlerts(router, "", "POST", "/v1/alerts", body).Code)
+	assert.Equal(t, http.StatusUnauthorized, callAlerts(router, "mallory", "POST", "/v1/alerts", body).Code)
+
+	w := createAlert(router, body)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "owner")
+	var created alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Bob can't see or delete alice's alert
+	assert.Equal(t, http.StatusNotFound, callAlerts(router, "bob", "GET", "/v1/alerts/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, callAlerts(router, "bob", "DELETE", "/v1/alerts/"+created.ID, "").Code)
+	w = callAlerts(router, "alice", "GET", "/v1/alerts/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "owner")
+
+	// Alice's active alerts are capped; triggered ones don't count, nor do bob's
+	for i := 1; i < maxActiveAlertsPerKey; i++ {
+		assert.Equal(t, http.StatusCreated, createAlert(router, body).Code)
+	}
+	assert.Equal(t, http.StatusConflict, createAlert(router, body).Code)
+	assert.Equal(t, http.StatusCreated, callAlerts(router, "bob", "POST", "/v1/alerts", body).Code)
+	alerts.alerts[created.ID].Status = alertTriggered
+	assert.Equal(t, http.StatusCreated, createAlert(router, body).Code)
+}
+
+// Test a threshold below the position's value makes a "below" alert
+func TestAlertDirectionInferred(t *testing.T) {
+	router, _ := setupAlertsRouter(t, alertNotifier{SMTPAddr: "smtp.example.com:25", SMTPFrom: "alerts@example.com"}, 250)
+
+	w := createAlert(router, `{"amount": "10", "ticker": "AAPL", "buyDate": "2025-07-18", "threshold": 2000, "email": "you@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, 10.0, created.Shares)
+	assert.Equal(t, "below", created.Direction)
+}
+
+// Test alert requests are checked
+func TestAlertCreateValidation(t *testing.T) {
+	router, _ := setupAlertsRouter(t, alertNotifier{}, 250)
+	base := `"amount": "1000USD", "ticker": "AAPL", "buyDate": "2025-07-18"`
+
+	cases := map[string]string{
+		`{` + base + `, "threshold": 5000}`:                                                          "give one of email or webhookUrl",
+		`{` + base + `, "threshold": 5000, "email": "you@example.com"}`:                              "SMTP_ADDR",
+		`{` + base + `, "threshold": 5000, "webhookUrl": "https://hooks.example.com/"}`:              "WEBHOOK_SECRET",
+		`{` + base + `, "threshold": -1, "webhookUrl": "https://hooks.example.com/"}`:                "threshold",
+		`{` + base + `, "threshold": 5000, "direction": "sideways", "email": "you@example.com"}`:     "direction",
+		`{` + base + `, "threshold": 5000, "type": "bond", "email": "you@example.com"}`:              "type",
+		`{"ticker": "AAPL", "buyDate": "2025-07-18", "threshold": 5000, "email": "you@example.com"}`: "Invalid alert request",
+		`{"amount": "1000USD", "ticker": "AAPL", "buyDate": "2999-01-01", "threshold": 5000}`:        "FUTURE_DATE",
+		`{"amount": "1000USD", "ticker": "AAPL", "buyDate": "18/07/2025", "threshold": 5000}`:        "Invalid date",
+	}
+	for body, message := range cases {
+		w := createAlert(router, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+	}
+
+	router, _ = setupAlertsRouter(t, alertNotifier{WebhookSecret: "whsec"}, 250)
+	w := createAlert(router, `{`+base+`, "threshold": 5000, "webhookUrl": "http://127.0.0.1:9000/"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "private address")
+}
+
+// Test checks trigger alerts once they cross, with a signed webhook
+func TestAlertCheckAll(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer hook.Close()
+
+	router, alerts := setupAlertsRouter(t, alertNotifier{WebhookSecret: "whsec", WebhookAllowPrivate: true}, 250)
+	w := createAlert(router, `{"amount": "10", "ticker": "AAPL", "buyDate": "2025-07-18", "threshold": 3000, "webhookUrl": "`+hook.URL+`"}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Still below the threshold: nothing is sent
+	alerts.checkAll()
+	assert.Len(t, received, 0)
+	assert.Equal(t, alertActive, alerts.alerts[created.ID].Status)
+
+	alerts.value = func(a alert) (float64, error) { return a.Shares * 310, nil }
+	alerts.checkAll()
+	req := <-received
+	body := <-bodies
+	assert.Equal(t, created.ID, req.Header.Get("X-Webhook-Id"))
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, signWebhook("whsec", timestamp, body), req.Header.Get("X-Webhook-Signature"))
+	assert.Contains(t, string(body), `"value":3100`)
+	assert.Contains(t, string(body), "is now worth $3,100.00, above your alert at $3,000.00")
+
+	w = callAlerts(router, "alice", "GET", "/v1/alerts/"+created.ID, "")
+	var triggered alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &triggered))
+	assert.Equal(t, alertTriggered, triggered.Status)
+	assert.NotNil(t, triggered.TriggeredAt)
+	assert.Equal(t, 3100.0, *triggered.LastValue)
+
+	// Triggered alerts aren't checked again
+	alerts.checkAll()
+	assert.Len(t, received, 0)
+}
+
+// Test failed checks and notifications leave the alert active with the error
+func TestAlertCheckAllErrors(t *testing.T) {
+	router, alerts := setupAlertsRouter(t, alertNotifier{WebhookSecret: "whsec", WebhookAllowPrivate: true}, 250)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	w := createAlert(router, `{"amount": "10", "ticker": "AAPL", "buyDate": "2025-07-18", "threshold": 3000, "webhookUrl": "`+hook.URL+`"}`)
+	var created alert
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	alerts.value = func(a alert) (float64, error) { return 0, errors.New("No latest quote for AAPL") }
+	alerts.checkAll()
+	assert.Equal(t, alertActive, alerts.alerts[created.ID].Status)
+	assert.Equal(t, "No latest quote for AAPL", alerts.alerts[created.ID].LastError)
+
+	alerts.value = func(a alert) (float64, error) { return 3100, nil }
+	alerts.checkAll()
+	assert.Equal(t, alertActive, alerts.alerts[created.ID].Status)
+	assert.Contains(t, alerts.alerts[created.ID].LastError, "Notification failed")
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alpaca stock bars response struct, a page at a time
+// Example: https://data.alpaca.markets/v2/stocks/AAPL/bars?timeframe=1Day&start=2025-07-18&end=2025-07-18T23:59:59Z&feed=iex&adjustment=raw
+type alpacaBarsResponse struct {
+	Message string `json:"message"`
+	Bars    []struct {
+		Time  time.Time `json:"t"`
+		Open  float64   `json:"o"`
+		High  float64   `json:"h"`
+		Low   float64   `json:"l"`
+		Close float64   `json:"c"`
+	} `json:"bars"`
+	NextPageToken string `json:"next_page_token"`
+}
+
+// Alpaca's daily bars from the free IEX feed, for US stocks and ETFs. Only trades
+// on IEX make them up, so they can differ slightly from consolidated prices.
+type alpacaProvider struct{}
+
+func (alpacaProvider) Name() string { return "alpaca" }
+
+// Fetch daily bars between two dates (or the whole history when start is empty)
+// as a series, following Alpaca's page tokens. Each bar is stamped at midnight
+// New York time on its trading day, so the end is taken as the end of its day.
+func fetchAlpacaSeries(ticker, start, end string, adjusted bool) (dailySeries, error) {
+	cfg := settings()
+	ticker = strings.ToUpper(ticker)
+	if i := strings.LastIndex(ticker, "."); i > 0 {
+		if _, ok := exchangeSuffixes[ticker[i:]]; ok {
+			return nil, fmt.Errorf("Alpaca has no stocks on %s", exchangeFor(ticker, "stock").Code)
+		}
+	}
+	if start == "" {
+		start = "2016-01-01"
+	}
+	query := url.Values{
+		"timeframe":  {"1Day"},
+		"start":      {start},
+		"feed":       {"iex"},
+		"adjustment": {"raw"},
+		"limit":      {"10000"},
+	}
+	if end != "" {
+		query.Set("end", end+"T23:59:59Z")
+	}
+	if adjusted {
+		query.Set("adjustment", "all")
+	}
+
+	series := dailySeries{}
+	for {
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/v2/stocks/%s/bars?%s", cfg.AlpacaBaseURL, url.PathEscape(ticker), query.Encode()), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("APCA-API-KEY-ID", cfg.AlpacaKeyID)
+		req.Header.Set("APCA-API-SECRET-KEY", cfg.AlpacaSecretKey)
+		resp, err := outboundClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var result alpacaBarsResponse
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Alpaca returned status %d %s", resp.StatusCode, result.Message)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+		}
+
+		for _, bar := range result.Bars {
+			date := bar.Time.In(newYork).Format("2006-01-02")
+			series[date] = map[string]float64{"open": bar.Open, "high": bar.High, "low": bar.Low, "close": bar.Close}
+		}
+		if result.NextPageToken == "" {
+			return series, nil
+		}
+		query.Set("page_token", result.NextPageToken)
+	}
+}
+
+func (alpacaProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	series, err := fetchAlpacaSeries(ticker, date, date, opts.Adjusted)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := series[date][opts.At]
+	if !ok {
+		return 0, fmt.Errorf("No data for date %s", date)
+	}
+	return price, nil
+}
+
+func (alpacaProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	series, err := fetchAlpacaSeries(ticker, start, end, opts.Adjusted)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(series))
+	for date, day := range series {
+		points = append(points, pricePoint{Date: date, Price: day[opts.At]})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Alpaca bars API for AAPL with mockStockData's closes, each bar
+// opening 1 below its close and adjusted to 99%, two bars to a page. Other
+// symbols have no bars.
+func setupMockAlpaca(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("APCA-API-KEY-ID") != "key-id" || r.Header.Get("APCA-API-SECRET-KEY") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message": "request is not authorized"}`)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("feed") != "iex" || query.Get("timeframe") != "1Day" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message": "subscription does not permit querying recent SIP data"}`)
+			return
+		}
+		scale := 1.0
+		if query.Get("adjustment") == "all" {
+			scale = 0.99
+		}
+		start, _ := time.ParseInLocation("2006-01-02", query.Get("start"), time.UTC)
+		end, _ := time.Parse(time.RFC3339, query.Get("end"))
+
+		var dates []string
+		if r.URL.Path == "/v2/stocks/AAPL/bars" {
+			for date := range mockStockData {
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+		type bar struct {
+			Time  time.Time `json:"t"`
+			Open  float64   `json:"o"`
+			High  float64   `json:"h"`
+			Low   float64   `json:"l"`
+			Close float64   `json:"c"`
+		}
+		var bars []bar
+		for _, date := range dates {
+			day, _ := time.ParseInLocation("2006-01-02", date, newYork)
+			if day.Before(start) || day.After(end) || date < query.Get("page_token") {
+				continue
+			}
+			var price float64
+			fmt.Sscan(mockStockData[date]["4. close"], &price)
+			bars = append(bars, bar{day.UTC(), (price - 1) * scale, (price + 1) * scale, (price - 2) * scale, price * scale})
+		}
+		result := map[string]interface{}{"symbol": strings.Split(r.URL.Path, "/")[3], "next_page_token": nil}
+		if len(bars) > 2 {
+			result["next_page_token"] = bars[2].Time.In(newYork).Format("2006-01-02")
+			bars = bars[:2]
+		}
+		result["bars"] = bars
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) {
+		cfg.AlpacaBaseURL, cfg.AlpacaKeyID, cfg.AlpacaSecretKey = server.URL, "key-id", "secret"
+	})
+}
+
+// Test Alpaca's daily bars, as traded and adjusted, dated in New York
+func TestAlpacaDailyPrice(t *testing.T) {
+	setupMockAlpaca(t)
+
+	price, err := alpacaProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+	price, err = alpacaProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.InDelta(t, 210.18*0.99, price, 1e-9)
+
+	_, err = alpacaProvider{}.DailyPrice("MSFT", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-18")
+	_, err = alpacaProvider{}.DailyPrice("VOD.L", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Alpaca has no stocks on LSE")
+
+	setSettings(t, func(cfg *Config) { cfg.AlpacaSecretKey = "wrong" })
+	_, err = alpacaProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Alpaca returned status 401 request is not authorized")
+}
+
+// Test Alpaca's history is read across its pages
+func TestAlpacaHistory(t *testing.T) {
+	setupMockAlpaca(t)
+
+	points, err := alpacaProvider{}.History("AAPL", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Len(t, points, 6)
+	assert.Equal(t, pricePoint{Date: "2025-03-31", Price: 200.50}, points[0])
+	assert.Equal(t, pricePoint{Date: "2025-07-18", Price: 211.18}, points[5])
+
+	points, err = alpacaProvider{}.History("AAPL", "2025-07-01", "2025-07-17", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-07-17", Price: 210.02}}, points)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Languages that write decimals with a comma (1.000,50); all others use a dot (1,000.50)
+var commaDecimalLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true,
+	"fi": true, "fr": true, "hr": true, "hu": true, "id": true, "it": true,
+	"lt": true, "lv": true, "nb": true, "nl": true, "no": true, "pl": true,
+	"pt": true, "ro": true, "ru": true, "sk": true, "sl": true, "sr": true,
+	"sv": true, "tr": true, "uk": true, "vi": true,
+}
+
+// Shorthand magnitude suffixes as exponents (10k, 2.5m, 1bn)
+var amountMagnitudes = map[string]string{
+	"":   "",
+	"k":  "e3",
+	"m":  "e6",
+	"b":  "e9",
+	"bn": "e9",
+}
+
+// Characters accepted as digit grouping regardless of locale (spaces and the Swiss apostrophe)
+const groupingSpaces = " '\u00a0\u202f\u2009"
+
+// Determine the decimal separator for a locale hint like "de", "de-DE" or "en_GB".
+// Returns 0 when no hint was given.
+func decimalSeparatorForLocale(locale string) (byte, error) {
+	if locale == "" {
+		return 0, nil
+	}
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 || len(parts[0]) < 2 || len(parts[0]) > 3 {
+		return 0, fmt.Errorf("Invalid locale %q", locale)
+	}
+	if commaDecimalLanguages[strings.ToLower(parts[0])] {
+		return ',', nil
+	}
+	return '.', nil
+}
+
+// Normalize a number written with locale-specific grouping and decimal separators,
+// e.g. "1,000.50", "1.000,50" or "1 000,50", to the plain form "1000.50". Without a
+// locale hint the separator roles are inferred, and inputs like "1,000" that read
+// differently per locale are rejected.
+func normalizeLocalizedNumber(number, locale string) (string, error) {
+	decimalSep, err := decimalSeparatorForLocale(locale)
+	if err != nil {
+		return "", err
+	}
+
+	sign := ""
+	if strings.HasPrefix(number, "-") || strings.HasPrefix(number, "+") {
+		sign, number = number[:1], number[1:]
+	}
+
+	if decimalSep == 0 {
+		decimalSep, err = inferDecimalSeparator(number)
+		if err != nil {
+			return "", err
+		}
+	}
+	groupSep := byte('.')
+	if decimalSep == '.' {
+		groupSep = ','
+	}
+
+	intPart, fracPart := number, ""
+	if i := strings.IndexByte(number, decimalSep); i >= 0 {
+		intPart, fracPart = number[:i], number[i+1:]
+		if strings.ContainsAny(fracPart, ".,"+groupingSpaces) {
+			return "", fmt.Errorf("Invalid amount %q: misplaced separator after the decimal point", number)
+		}
+	}
+
+	// Every group after the first must have exactly three digits
+	groups := strings.FieldsFunc(intPart, func(r rune) bool {
+		return r == rune(groupSep) || strings.ContainsRune(groupingSpaces, r)
+	})
+	if len(groups) > 1 {
+		if len(groups[0]) > 3 {
+			return "", fmt.Errorf("Invalid amount %q: unexpected digit grouping", number)
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return "", fmt.Errorf("Invalid amount %q: unexpected digit grouping", number)
+			}
+		}
+	}
+
+	normalized := sign + strings.Join(groups, "")
+	if fracPart != "" {
+		normalized += "." + fracPart
+	}
+	return normalized, nil
+}
+
+// Work out which of '.' and ',' is the decimal separator when no locale was given
+func inferDecimalSeparator(number string) (byte, error) {
+	lastDot := strings.LastIndexByte(number, '.')
+	lastComma := strings.LastIndexByte(number, ',')
+
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		// Both present: the last one is the decimal separator
+		if lastDot > lastComma {
+			return '.', nil
+		}
+		return ',', nil
+	case lastDot < 0 && lastComma < 0:
+		return '.', nil
+	}
+
+	sep, last := byte('.'), lastDot
+	if lastComma >= 0 {
+		sep, last = ',', lastComma
+	}
+
+	// Repeated separator can only be grouping (1,000,000)
+	if strings.Count(number, string(sep)) > 1 {
+		if sep == '.' {
+			return ',', nil
+		}
+		return '.', nil
+	}
+
+	// A single separator followed by exactly three digits reads as either
+	// a thousands or a decimal separator depending on locale
+	intPart := strings.Trim(number[:last], groupingSpaces)
+	if len(number)-last-1 == 3 && intPart != "" && intPart != "0" && !strings.ContainsAny(intPart, groupingSpaces) {
+		return 0, fmt.Errorf("Ambiguous amount %q: add ?locale= (e.g. en or de) to say whether %q separates thousands or decimals", number, string(sep))
+	}
+	return sep, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Query parameters of /v1/backtest standing in for the slash grammar's path
+// parameters
+var backtestQueryParams = map[string]string{
+	"amount":   "amount",
+	"ticker":   "ticker",
+	"buyDate":  "buy",
+	"sellDate": "sell",
+}
+
+// Handler for /v1/backtest, the slash grammar's backtests as query parameters:
+// ?ticker=AAPL&amount=1000&currency=EUR&buy=2020-03-20&sell=2024-03-20&drip=true.
+// The other query parameters (type, priceAt, reportIn, ...) work as on the paths.
+func handleBacktestQuery(c *gin.Context) {
+	for _, name := range []string{"ticker", "amount", "buy"} {
+		if c.Query(name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing parameter", "details": name + " is required"})
+			return
+		}
+	}
+
+	amount := c.Query("amount")
+	if currency := c.Query("currency"); currency != "" {
+		if _, _, isValue, err := parseAmount(amount, c.Query("locale")); err == nil && isValue {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": fmt.Sprintf("amount %q already has a currency; drop it or currency", amount)})
+			return
+		}
+		amount += currency
+	}
+
+	sell, held := c.Query("sell"), c.Query("held") == "true"
+	drip, dividends := c.Query("drip") == "true", c.Query("dividends") == "true"
+	switch {
+	case sell != "" && held:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sell and held=true cannot be combined"})
+		return
+	case drip && dividends:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "drip=true and dividends=true cannot be combined"})
+		return
+	case (drip || dividends) && sell == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "drip and dividends need a sell date"})
+		return
+	}
+
+	// Check the parameters as the path's would be, then answer as the path would
+	c.Params = append(c.Params,
+		gin.Param{Key: "amount", Value: amount},
+		gin.Param{Key: "ticker", Value: c.Query("ticker")},
+		gin.Param{Key: "buyDate", Value: c.Query("buy")},
+	)
+	if sell != "" {
+		c.Params = append(c.Params, gin.Param{Key: "sellDate", Value: sell})
+	}
+	if !checkInputParams(c) || !applyLimitOrder(c) {
+		return
+	}
+
+	switch {
+	case held:
+		handleAmountBuyHeld(c)
+	case drip:
+		handleAmountBuySellDrip(c)
+	case dividends:
+		handleAmountBuySellDividends(c)
+	case sell != "":
+		handleAmountBuySell(c)
+	default:
+		handleAmountBuy(c)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test /v1/backtest answers the same as the equivalent path
+func TestBacktestQueryWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	equivalents := map[string]string{
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31":                                        "/10/AAPL/on/2025-03-31",
+		"/v1/backtest?ticker=AAPL&amount=1000&currency=EUR&buy=2025-03-31&sell=2025-07-18":         "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18",
+		"/v1/backtest?ticker=AAPL&amount=1000USD&buy=2025-03-31&sell=2025-07-18&drip=true":         "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&sell=2025-07-18&dividends=true":         "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&sell=2025-07-18&priceAt=open&mood=true": "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?priceAt=open&mood=true",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&sell=2025-07-18&limitPrice=199":         "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?limitPrice=199",
+	}
+	for query, path := range equivalents {
+		w := makeTestRequest(router, "GET", query)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got, want map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.NoError(t, json.Unmarshal(makeTestRequest(router, "GET", path).Body.Bytes(), &want))
+		assert.Equal(t, want, got, query)
+	}
+}
+
+// Test /v1/backtest rejects missing, conflicting and impossible parameters
+func TestBacktestQueryErrors(t *testing.T) {
+	router := setupTestRouterWithMocks()
+
+	tests := map[string]string{
+		"/v1/backtest?amount=10&buy=2025-03-31":                                                      "ticker is required",
+		"/v1/backtest?ticker=AAPL&buy=2025-03-31":                                                    "amount is required",
+		"/v1/backtest?ticker=AAPL&amount=10":                                                         "buy is required",
+		"/v1/backtest?ticker=AAPL&amount=10USD&currency=EUR&buy=2025-03-31":                          "already has a currency",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&drip=true&dividends=true&sell=2025-07-18": "cannot be combined",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&sell=2025-07-18&held=true":                "cannot be combined",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-03-31&drip=true":                                "need a sell date",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-07-18&sell=2025-03-31":                          "must be after buyDate",
+		"/v1/backtest?ticker=AAPL&amount=-5&buy=2025-03-31":                                          "Invalid amount format",
+		"/v1/backtest?ticker=AA%20PL&amount=10&buy=2025-03-31":                                       "Invalid ticker",
+		"/v1/backtest?ticker=AAPL&amount=10&buy=2025-13-01":                                          "not a date",
+	}
+	for query, message := range tests {
+		w := makeTestRequest(router, "GET", query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), message, query)
+	}
+}
+
+// Test a buy date before the first trade suggests the same query from then
+func TestBacktestQueryBeforeFirstTrade(t *testing.T) {
+	setupMockAlphaVantage(t)
+	resetFirstTradedDates(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/v1/backtest?ticker=AAPL&amount=10&buy=2024-01-02&sell=2025-07-18")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "/v1/backtest?amount=10&buy=2025-03-31&sell=2025-07-18&ticker=AAPL", body["suggestedPath"])
+}
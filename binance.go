@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Binance quotes coins against USDT, read as USD like the other stablecoins
+const binanceQuoteAsset = "USDT"
+
+// Binance's largest page of klines
+const binanceKlineLimit = 1000
+
+// Kline intervals by length, finest first, and the longest span each is used for
+var binanceIntervals = []struct {
+	Name    string
+	Length  time.Duration
+	MaxSpan time.Duration
+}{
+	{"1m", time.Minute, 24 * time.Hour},
+	{"1h", time.Hour, binanceKlineLimit * time.Hour},
+	{"1d", 24 * time.Hour, 0},
+}
+
+// Binance's klines (OHLC bars) for a coin's USDT pair: minute bars for spans of
+// up to a day, hourly bars up to a page of them, and daily bars beyond, paged
+// through as needed
+type binanceProvider struct{}
+
+func (binanceProvider) Name() string { return "binance" }
+
+// Helper function to pick the kline interval for a span
+func binanceInterval(span time.Duration) (string, time.Duration) {
+	for _, interval := range binanceIntervals {
+		if span <= interval.MaxSpan || interval.MaxSpan == 0 {
+			return interval.Name, interval.Length
+		}
+	}
+	return "", 0
+}
+
+// Fetch one page of klines opening from start to end
+// Example: https://api.binance.com/api/v3/klines?symbol=BTCUSDT&interval=1m&startTime=1752796800000&endTime=1752883200000&limit=1000
+func fetchBinanceKlines(pair, interval string, start, end time.Time) ([][]json.RawMessage, error) {
+	query := url.Values{
+		"symbol":    {pair},
+		"interval":  {interval},
+		"startTime": {strconv.FormatInt(start.UnixMilli(), 10)},
+		"endTime":   {strconv.FormatInt(end.UnixMilli(), 10)},
+		"limit":     {strconv.Itoa(binanceKlineLimit)},
+	}
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/api/v3/klines?%s", settings().BinanceBaseURL, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Msg string `json:"msg"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return nil, fmt.Errorf("Binance returned status %d %s", resp.StatusCode, failure.Msg)
+	}
+
+	var klines [][]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&klines); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	return klines, nil
+}
+
+// Helper function to read a kline's open time and its open, high, low and close
+func parseBinanceKline(kline []json.RawMessage) (int64, [4]float64, error) {
+	var openTime int64
+	var prices [4]float64
+	if len(kline) < 5 {
+		return 0, prices, fmt.Errorf("Short Binance kline")
+	}
+	if err := json.Unmarshal(kline[0], &openTime); err != nil {
+		return 0, prices, err
+	}
+	for i := range prices {
+		var value string
+		if err := json.Unmarshal(kline[i+1], &value); err != nil {
+			return 0, prices, err
+		}
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, prices, err
+		}
+		prices[i] = price
+	}
+	return openTime, prices, nil
+}
+
+func (binanceProvider) Quotes(symbol string, from, to time.Time) ([][2]float64, error) {
+	pair := symbol + binanceQuoteAsset
+	interval, length := binanceInterval(to.Sub(from))
+
+	var points [][2]float64
+	for start := from.Truncate(length); !start.After(to); {
+		klines, err := fetchBinanceKlines(pair, interval, start, to)
+		if err != nil {
+			return nil, err
+		}
+		for _, kline := range klines {
+			openTime, ohlc, err := parseBinanceKline(kline)
+			if err != nil {
+				return nil, fmt.Errorf("Bad Binance kline: %v", err)
+			}
+			opened := time.UnixMilli(openTime).UTC()
+			points = appendBarQuotes(points, from, to, opened, length, ohlc)
+			start = opened.Add(length)
+		}
+		if len(klines) < binanceKlineLimit {
+			break
+		}
+	}
+	return dedupeQuotes(points), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Binance klines API for BTCUSDT: each bar opens at 100000 plus the
+// minutes since midnight UTC on 2025-07-18, ranges 5 either way and closes 1 up.
+// Returns the number of requests made.
+func setupMockBinance(t *testing.T) *int {
+	requests := 0
+	base := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	lengths := map[string]time.Duration{"1m": time.Minute, "1h": time.Hour, "1d": 24 * time.Hour}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		if r.URL.Path != "/api/v3/klines" || query.Get("symbol") != "BTCUSDT" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code": -1121, "msg": "Invalid symbol."}`)
+			return
+		}
+		length := lengths[query.Get("interval")]
+		start, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+		end, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+
+		klines := [][]interface{}{}
+		for opened := time.UnixMilli(start).UTC(); opened.UnixMilli() <= end && len(klines) < limit; opened = opened.Add(length) {
+			open := 100000 + opened.Sub(base).Minutes()
+			klines = append(klines, []interface{}{
+				opened.UnixMilli(), fmt.Sprint(open), fmt.Sprint(open + 5), fmt.Sprint(open - 5), fmt.Sprint(open + 1),
+				"12.5", opened.Add(length).UnixMilli() - 1,
+			})
+		}
+		json.NewEncoder(w).Encode(klines)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.BinanceBaseURL = server.URL })
+	return &requests
+}
+
+// Test spans get the finest interval that fits in a page
+func TestBinanceInterval(t *testing.T) {
+	name, _ := binanceInterval(24 * time.Hour)
+	assert.Equal(t, "1m", name)
+	name, _ = binanceInterval(30 * 24 * time.Hour)
+	assert.Equal(t, "1h", name)
+	name, length := binanceInterval(10 * 365 * 24 * time.Hour)
+	assert.Equal(t, "1d", name)
+	assert.Equal(t, 24*time.Hour, length)
+}
+
+// Test klines become quotes: opens, highs and lows inside intraday bars, closes
+// at the next bar's open
+func TestBinanceQuotes(t *testing.T) {
+	setupMockBinance(t)
+
+	from := time.Date(2025, 7, 18, 14, 0, 0, 0, time.UTC)
+	points, err := binanceProvider{}.Quotes("BTC", from, from.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]float64{
+		{float64(from.UnixMilli()), 100840},
+		{float64(from.Add(20 * time.Second).UnixMilli()), 100845},
+		{float64(from.Add(40 * time.Second).UnixMilli()), 100835},
+		{float64(from.Add(time.Minute).UnixMilli()), 100841},
+		{float64(from.Add(80 * time.Second).UnixMilli()), 100846},
+		{float64(from.Add(100 * time.Second).UnixMilli()), 100836},
+		{float64(from.Add(2 * time.Minute).UnixMilli()), 100842},
+	}, points)
+	assert.Equal(t, 100846.0, ohlcPoint(points, "high"))
+
+	_, err = binanceProvider{}.Quotes("NOPE", from, from.Add(time.Minute))
+	assert.ErrorContains(t, err, "Invalid symbol.")
+}
+
+// Test long spans page through daily bars, one quote a day at midnight
+func TestBinanceDailyPaging(t *testing.T) {
+	requests := setupMockBinance(t)
+
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1500)
+	points, err := binanceProvider{}.Quotes("BTC", from, to)
+	assert.NoError(t, err)
+	assert.Len(t, points, 1501)
+	assert.Equal(t, 2, *requests)
+	assert.Equal(t, float64(to.UnixMilli()), points[1500][0])
+}
+
+// Test prices at a time of day come from Binance's minute bars first
+func TestBinanceIntradayPrice(t *testing.T) {
+	setupMockCoinGecko(t)
+	setupMockBinance(t)
+
+	price, err := fetchPrice("BTC", "2025-07-18T14:30", "crypto", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	// The 14:29 bar's close
+	assert.Equal(t, 100870.0, price)
+
+	// Daily prices still come from CoinGecko
+	price, err = fetchPrice("BTC", "2025-07-18", "crypto", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 118000.0, price)
+}
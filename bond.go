@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A constant-maturity Treasury yield series on FRED
+type treasurySeries struct {
+	SeriesID string
+	Years    float64
+}
+
+// Supported Treasury maturities by ticker
+var treasuryBonds = map[string]treasurySeries{
+	"UST3M":  {"DGS3MO", 0.25},
+	"UST6M":  {"DGS6MO", 0.5},
+	"UST1Y":  {"DGS1", 1},
+	"UST2Y":  {"DGS2", 2},
+	"UST5Y":  {"DGS5", 5},
+	"UST10Y": {"DGS10", 10},
+	"UST20Y": {"DGS20", 20},
+	"UST30Y": {"DGS30", 30},
+}
+
+// Face value of one bond, for quantity-based scenarios
+const bondFaceValue = 1000.0
+
+// A dated FRED observation
+type fredObservation struct {
+	Date  string
+	Value float64
+}
+
+// Fetch FRED observations between two dates, skipping days without a value
+// Example: https://api.stlouisfed.org/fred/series/observations?series_id=DGS10&observation_start=2020-01-02&observation_end=2020-01-02&file_type=json&api_key=KEY
+func fetchFREDObservations(seriesID, start, end string) ([]fredObservation, error) {
+	cfg := settings()
+	if cfg.FREDAPIKey == "" {
+		return nil, fmt.Errorf("FRED_API_KEY is not configured")
+	}
+
+	url := fmt.Sprintf("%s/fred/series/observations?series_id=%s&observation_start=%s&observation_end=%s&file_type=json&api_key=%s",
+		cfg.FREDBaseURL, seriesID, start, end, cfg.FREDAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Observations []struct {
+			Date  string `json:"date"`
+			Value string `json:"value"`
+		} `json:"observations"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	// Market holidays are reported as "."
+	var observations []fredObservation
+	for _, observation := range result.Observations {
+		if value, err := strconv.ParseFloat(observation.Value, 64); err == nil {
+			observations = append(observations, fredObservation{Date: observation.Date, Value: value})
+		}
+	}
+	return observations, nil
+}
+
+// Fetch a FRED yield (percent) for a date
+func fetchTreasuryYield(seriesID, date string) (float64, error) {
+	observations, err := fetchFREDObservations(seriesID, date, date)
+	if err != nil {
+		return 0, err
+	}
+	for _, observation := range observations {
+		if observation.Date == date {
+			return observation.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("No %s yield for date %s", seriesID, date)
+}
+
+// Price per unit of face value of a bond paying couponRate (annual, semi-annual
+// coupons) with yearsLeft to maturity, discounted at yield
+func priceBond(couponRate, yield, yearsLeft float64) float64 {
+	if yearsLeft <= 0 {
+		return 1
+	}
+	periods := yearsLeft * 2
+	if yield == 0 {
+		return 1 + couponRate/2*periods
+	}
+	discount := math.Pow(1+yield/2, -periods)
+	return couponRate/yield*(1-discount) + discount
+}
+
+// Outcome of buying a Treasury at par on the buy date and holding it to the sell date
+type bondResult struct {
+	YieldAtBuy    float64
+	YieldAtSell   float64
+	YearsHeld     float64
+	Matured       bool
+	SellPrice     float64 // per 100 face
+	CouponIncome  float64 // per unit of face, accrued and not reinvested
+	ValuePerFace  float64 // sale price plus coupons, per unit of face
+	MaturityYears float64
+}
+
+// Model a Treasury bought at par (coupon = the prevailing yield) and held to the sell
+// date: coupons accrue simply and the price moves with the same-maturity yield on
+// the sell date. A bond held past maturity is redeemed at par.
+func calculateBond(series treasurySeries, buyDate, sellDate string) (*bondResult, error) {
+	buy, err := time.Parse("2006-01-02", dateOnly(buyDate))
+	if err != nil {
+		return nil, err
+	}
+	sell, err := time.Parse("2006-01-02", dateOnly(sellDate))
+	if err != nil {
+		return nil, err
+	}
+	if !sell.After(buy) {
+		return nil, fmt.Errorf("Sell date must be after buy date")
+	}
+
+	yieldAtBuy, err := fetchTreasuryYield(series.SeriesID, dateOnly(buyDate))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &bondResult{
+		YieldAtBuy:    yieldAtBuy,
+		YearsHeld:     sell.Sub(buy).Hours() / 24 / 365.25,
+		MaturityYears: series.Years,
+	}
+	couponRate := yieldAtBuy / 100
+
+	if result.YearsHeld >= series.Years {
+		result.Matured = true
+		result.SellPrice = 100
+		result.CouponIncome = couponRate * series.Years
+	} else {
+		yieldAtSell, err := fetchTreasuryYield(series.SeriesID, dateOnly(sellDate))
+		if err != nil {
+			return nil, err
+		}
+		result.YieldAtSell = yieldAtSell
+		result.SellPrice = priceBond(couponRate, yieldAtSell/100, series.Years-result.YearsHeld) * 100
+		result.CouponIncome = couponRate * result.YearsHeld
+	}
+
+	result.ValuePerFace = result.SellPrice/100 + result.CouponIncome
+	return result, nil
+}
+
+// Handle a buy/sell scenario for type=bond. Value amounts buy that much face value
+// at par; quantities are numbers of $1,000-face bonds.
+func handleBondBuySell(c *gin.Context, parsedAmount float64, currency string, isValue bool, reportIn string) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+
+	series, ok := treasuryBonds[strings.ToUpper(ticker)]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported bond: use UST3M, UST6M, UST1Y, UST2Y, UST5Y, UST10Y, UST20Y or UST30Y"})
+		return
+	}
+
+	fxRateBuy, fxRateSell := 1.0, 1.0
+	faceValue := parsedAmount * bondFaceValue
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		var err error
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+
+		fxRateBuy, err = requestNotes(c).fxRate(currency, "USD", buyDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+			return
+		}
+
+		fxRateSell, err = requestNotes(c).fxRate("USD", currency, sellDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			return
+		}
+
+		faceValue = parsedAmount * fxRateBuy
+	}
+
+	bond, err := calculateBond(series, buyDate, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to model bond", "details": err.Error()})
+		return
+	}
+
+	finalValueUSD := faceValue * bond.ValuePerFace
+
+	response := gin.H{
+		"ticker":        ticker,
+		"seriesId":      series.SeriesID,
+		"maturityYears": series.Years,
+		"buyDate":       buyDate,
+		"sellDate":      sellDate,
+		"buyPrice":      100.0,
+		"sellPrice":     bond.SellPrice,
+		"faceValue":     faceValue,
+		"couponRate":    bond.YieldAtBuy,
+		"yieldAtBuy":    bond.YieldAtBuy,
+		"yearsHeld":     bond.YearsHeld,
+		"matured":       bond.Matured,
+		"couponIncome":  faceValue * bond.CouponIncome,
+		"capitalGain":   faceValue * (bond.SellPrice/100 - 1),
+		"type":          "bond",
+	}
+	if !bond.Matured {
+		response["yieldAtSell"] = bond.YieldAtSell
+	}
+	if isValue {
+		response["message"] = localize(c, "Backtest result (value buy/sell of a Treasury bond)")
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["finalValueUSD"] = finalValueUSD
+		response["finalValueInOriginalCurrency"] = finalValueUSD * fxRateSell
+		response["fxRateBuy"] = fxRateBuy
+		response["fxRateSell"] = fxRateSell
+	} else {
+		response["message"] = localize(c, "Backtest result (quantity buy/sell of Treasury bonds)")
+		response["quantity"] = parsedAmount
+		response["finalValue"] = finalValueUSD
+	}
+	if err := addReportCurrency(response, reportIn, finalValueUSD, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+		return
+	}
+	if err := addCashComparison(response, c.Query("compareCash") == "true", faceValue, finalValueUSD, fxRateSell, buyDate, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+		return
+	}
+	if err := addEquivalents(response, c.Query("funUnits") == "true", faceValue, finalValueUSD, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+		return
+	}
+	addMood(response, c.Query("mood") == "true", faceValue, finalValueUSD, buyDate, sellDate)
+	if err := addBTCComparison(response, c.Query("vsBTC") == "true", faceValue, finalValueUSD, fxRateSell, buyDate, sellDate, priceOptions{At: "close"}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+		return
+	}
+	addCryptoUnits(response, ticker, "bond", currency)
+	c.JSON(http.StatusOK, response)
+}
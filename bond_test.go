@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock Treasury yields and interest rates (percent) by FRED series and date
+var mockTreasuryYields = map[string]map[string]string{
+	"DGS10": {
+		"2025-03-31": "4.23",
+		"2025-07-18": "4.43",
+	},
+	"DGS3MO": {
+		"2025-03-31": "4.32",
+		"2025-07-18": ".",
+	},
+	"FEDFUNDS": {
+		"2025-03-01": "4.33",
+		"2025-04-01": "4.33",
+		"2025-05-01": "4.33",
+		"2025-06-01": "4.33",
+		"2025-07-01": "4.33",
+	},
+}
+
+// Start a fake FRED observations API serving mockTreasuryYields
+func setupMockFRED(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		start, end := query.Get("observation_start"), query.Get("observation_end")
+
+		series := mockTreasuryYields[query.Get("series_id")]
+		var dates []string
+		for date := range series {
+			if date >= start && date <= end {
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+
+		observations := []map[string]string{}
+		for _, date := range dates {
+			observations = append(observations, map[string]string{"date": date, "value": series[date]})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"observations": observations})
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.FREDBaseURL, cfg.FREDAPIKey = server.URL, "test" })
+}
+
+// Test bond pricing from yields
+func TestPriceBond(t *testing.T) {
+	// A bond yielding its coupon trades at par
+	assert.InDelta(t, 1.0, priceBond(0.05, 0.05, 10), 1e-12)
+
+	// Rising yields push the price below par, falling yields above
+	assert.Less(t, priceBond(0.04, 0.05, 10), 1.0)
+	assert.Greater(t, priceBond(0.04, 0.03, 10), 1.0)
+
+	assert.Equal(t, 1.0, priceBond(0.04, 0.05, 0))
+	assert.InDelta(t, 1.2, priceBond(0.04, 0, 5), 1e-12)
+}
+
+// Test the type=bond buy/sell scenario
+func TestBondRoute(t *testing.T) {
+	setupMockFRED(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/of/UST10Y/on/2025-03-31/and-sold-on/2025-07-18?type=bond")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	years := 109 / 365.25
+	sellPrice := priceBond(0.0423, 0.0443, 10-years) * 100
+	assert.Equal(t, 10000.0, response["faceValue"])
+	assert.Equal(t, 4.43, response["yieldAtSell"])
+	assert.InDelta(t, sellPrice, response["sellPrice"], 1e-9)
+	assert.InDelta(t, 10000*0.0423*years, response["couponIncome"], 1e-9)
+	assert.InDelta(t, 10000*(sellPrice/100+0.0423*years), response["finalValue"], 1e-9)
+
+	// A 3-month bill held past maturity is redeemed at par
+	w = makeTestRequest(router, "GET", "/10/of/UST3M/on/2025-03-31/and-sold-on/2025-07-18?type=bond")
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["matured"])
+	assert.InDelta(t, 10000*(1+0.0432*0.25), response["finalValue"], 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/of/UST10Y/on/2025-03-31?type=bond")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = makeTestRequest(router, "GET", "/10/of/UST7Y/on/2025-03-31/and-sold-on/2025-07-18?type=bond")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A broker's CSV export the portfolio import reads (?broker=). Its rows are
+// mapped onto the import's own columns (date, ticker, quantity, side); rows that
+// aren't trades, like deposits and dividends, map to nil and are skipped.
+type brokerFormat struct {
+	Name    string
+	Columns []string // header names the export must have, lowercased
+	row     func(field func(name string) string, symbols map[string]string) (map[string]string, error)
+}
+
+// The broker exports the portfolio import reads, by ?broker= name
+var brokerFormats = map[string]brokerFormat{
+	"trading212": {
+		Name:    "Trading 212",
+		Columns: []string{"action", "time", "isin", "ticker", "no. of shares"},
+		row:     trading212Row,
+	},
+	"degiro": {
+		Name:    "Degiro",
+		Columns: []string{"date", "product", "isin", "quantity"},
+		row:     degiroRow,
+	},
+	"robinhood": {
+		Name:    "Robinhood",
+		Columns: []string{"activity date", "instrument", "trans code", "quantity"},
+		row:     robinhoodRow,
+	},
+	"ibkr": {
+		Name:    "Interactive Brokers Flex",
+		Columns: []string{"symbol", "tradedate", "quantity", "buy/sell"},
+		row:     ibkrRow,
+	},
+}
+
+// Helper function to list the ?broker= names, sorted
+func brokerNames() []string {
+	names := make([]string, 0, len(brokerFormats))
+	for name := range brokerFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Helper function to turn a broker's date into YYYY-MM-DD
+func brokerDate(date, layout string) (string, error) {
+	parsed, err := time.Parse(layout, date)
+	if err != nil {
+		return "", fmt.Errorf("date %q is not a %s date", date, layout)
+	}
+	return parsed.Format("2006-01-02"), nil
+}
+
+// Helper function to read a broker's share count, which may be negative for
+// sells, as a quantity without a sign
+func brokerQuantity(quantity string) (string, bool, error) {
+	parsed, err := strconv.ParseFloat(strings.ReplaceAll(quantity, ",", ""), 64)
+	if err != nil || parsed == 0 {
+		return "", false, fmt.Errorf("quantity %q is not a number of shares", quantity)
+	}
+	return strconv.FormatFloat(math.Abs(parsed), 'f', -1, 64), parsed < 0, nil
+}
+
+// Trading 212's history export: one row per order, deposit, dividend or
+// interest payment, with its Action ("Market buy", "Limit sell") and a Time like
+// 2024-01-15 14:30:05
+func trading212Row(field func(name string) string, symbols map[string]string) (map[string]string, error) {
+	action := strings.ToLower(field("action"))
+	side := ""
+	switch {
+	case strings.HasSuffix(action, " buy"):
+		side = "buy"
+	case strings.HasSuffix(action, " sell"):
+		side = "sell"
+	default:
+		return nil, nil
+	}
+	day, _, _ := strings.Cut(field("time"), " ")
+	date, err := brokerDate(day, "2006-01-02")
+	if err != nil {
+		return nil, err
+	}
+	quantity, _, err := brokerQuantity(field("no. of shares"))
+	if err != nil {
+		return nil, err
+	}
+	ticker := strings.ToUpper(field("ticker"))
+	if mapped, ok := symbols[strings.ToUpper(field("isin"))]; ok {
+		ticker = mapped
+	}
+	return map[string]string{"date": date, "ticker": ticker, "quantity": quantity, "side": side}, nil
+}
+
+// Degiro's transactions export: trades only, dated DD-MM-YYYY, with negative
+// quantities for sells. Products are named by ISIN rather than ticker, so each
+// needs mapping with ?symbols=.
+func degiroRow(field func(name string) string, symbols map[string]string) (map[string]string, error) {
+	if field("date") == "" && field("isin") == "" {
+		return nil, nil
+	}
+	date, err := brokerDate(field("date"), "02-01-2006")
+	if err != nil {
+		return nil, err
+	}
+	isin := strings.ToUpper(field("isin"))
+	ticker, ok := symbols[isin]
+	if !ok {
+		return nil, fmt.Errorf("no ticker for ISIN %s (%s): map it with ?symbols=ISIN:TICKER", isin, field("product"))
+	}
+	quantity, negative, err := brokerQuantity(field("quantity"))
+	if err != nil {
+		return nil, err
+	}
+	side := "buy"
+	if negative {
+		side = "sell"
+	}
+	return map[string]string{"date": date, "ticker": ticker, "quantity": quantity, "side": side}, nil
+}
+
+// Robinhood's account activity report: trades, dividends and transfers by Trans
+// Code, dated M/D/YYYY, ending with a disclaimer row
+func robinhoodRow(field func(name string) string, symbols map[string]string) (map[string]string, error) {
+	side := ""
+	switch strings.ToLower(field("trans code")) {
+	case "buy":
+		side = "buy"
+	case "sell":
+		side = "sell"
+	default:
+		return nil, nil
+	}
+	date, err := brokerDate(field("activity date"), "1/2/2006")
+	if err != nil {
+		return nil, err
+	}
+	quantity, _, err := brokerQuantity(field("quantity"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"date": date, "ticker": strings.ToUpper(field("instrument")), "quantity": quantity, "side": side}, nil
+}
+
+// Interactive Brokers' Flex Query trades report: TradeDate as yyyyMMdd (or
+// yyyy-MM-dd), negative quantities for sells and cancelled trades marked Ca in
+// Notes/Codes. Only stocks are imported when the report has an AssetClass column,
+// and the header rows repeated between sections are skipped.
+func ibkrRow(field func(name string) string, symbols map[string]string) (map[string]string, error) {
+	side := ""
+	switch strings.ToUpper(field("buy/sell")) {
+	case "BUY":
+		side = "buy"
+	case "SELL":
+		side = "sell"
+	default:
+		return nil, nil
+	}
+	if class := field("assetclass"); class != "" && !strings.EqualFold(class, "STK") {
+		return nil, nil
+	}
+	for _, code := range strings.Split(field("notes/codes"), ";") {
+		if strings.EqualFold(strings.TrimSpace(code), "Ca") {
+			return nil, nil
+		}
+	}
+	layout := "20060102"
+	if strings.Contains(field("tradedate"), "-") {
+		layout = "2006-01-02"
+	}
+	date, err := brokerDate(field("tradedate"), layout)
+	if err != nil {
+		return nil, err
+	}
+	quantity, _, err := brokerQuantity(field("quantity"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"date": date, "ticker": strings.ToUpper(field("symbol")), "quantity": quantity, "side": side}, nil
+}
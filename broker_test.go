@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test each broker's export maps onto the import's trades, skipping what isn't one
+func TestParseBrokerExports(t *testing.T) {
+	tests := []struct {
+		broker  string
+		csv     string
+		symbols map[string]string
+		trades  []importedTrade
+		skipped int
+	}{
+		{
+			broker: "trading212",
+			csv: "Action,Time,ISIN,Ticker,Name,No. of shares,Price / share,Currency (Price / share),Total,Currency (Total)\n" +
+				"Deposit,2025-03-28 09:00:00,,,,,,,1000,EUR\n" +
+				"Market buy,2025-03-31 14:30:05,US0378331005,AAPL,Apple,4.5,200.50,USD,835.41,EUR\n" +
+				"Limit sell,2025-06-30 15:01:00,IE00B3XXRP09,VUSA,Vanguard S&P 500,2,90.10,GBP,210.50,EUR\n" +
+				"Dividend (Ordinary),2025-05-15 08:00:00,US0378331005,AAPL,Apple,4.5,0.25,USD,0.96,EUR\n",
+			symbols: map[string]string{"IE00B3XXRP09": "VUSA.L"},
+			trades: []importedTrade{
+				{Line: 3, Date: "2025-03-31", Ticker: "AAPL", Side: "buy", Quantity: 4.5},
+				{Line: 4, Date: "2025-06-30", Ticker: "VUSA.L", Side: "sell", Quantity: 2},
+			},
+			skipped: 2,
+		},
+		{
+			broker: "degiro",
+			csv: "Date,Time,Product,ISIN,Reference exchange,Venue,Quantity,Price,,Local value,,Value,,Exchange rate,Transaction and/or third,,Total,,Order ID\n" +
+				"30-06-2025,15:30,APPLE INC,US0378331005,NDQ,XNAS,-3,205.17,USD,615.51,USD,570.00,EUR,1.0798,-1.00,EUR,569.00,EUR,abc\n" +
+				"31-03-2025,09:15,APPLE INC,US0378331005,NDQ,XNAS,10,200.50,USD,-2005.00,USD,-1856.48,EUR,1.08,-2.00,EUR,-1858.48,EUR,def\n",
+			symbols: map[string]string{"US0378331005": "AAPL"},
+			trades: []importedTrade{
+				{Line: 2, Date: "2025-06-30", Ticker: "AAPL", Side: "sell", Quantity: 3},
+				{Line: 3, Date: "2025-03-31", Ticker: "AAPL", Side: "buy", Quantity: 10},
+			},
+		},
+		{
+			broker: "robinhood",
+			csv: "\"Activity Date\",\"Process Date\",\"Settle Date\",\"Instrument\",\"Description\",\"Trans Code\",\"Quantity\",\"Price\",\"Amount\"\n" +
+				"\"6/30/2025\",\"6/30/2025\",\"7/1/2025\",\"AAPL\",\"Apple\",\"Sell\",\"2\",\"$205.17\",\"$410.34\"\n" +
+				"\"5/15/2025\",\"5/15/2025\",\"5/15/2025\",\"AAPL\",\"Cash Div: R/D 2025-05-12\",\"CDIV\",\"\",\"\",\"$1.25\"\n" +
+				"\"3/31/2025\",\"3/31/2025\",\"4/1/2025\",\"AAPL\",\"Apple\",\"Buy\",\"5\",\"$200.50\",\"($1,002.50)\"\n" +
+				"\"\",\"\",\"\",\"\",\"\",\"\",\"\",\"\",\"\"\n" +
+				"\"The data provided is for informational purposes only.\"\n",
+			trades: []importedTrade{
+				{Line: 2, Date: "2025-06-30", Ticker: "AAPL", Side: "sell", Quantity: 2},
+				{Line: 4, Date: "2025-03-31", Ticker: "AAPL", Side: "buy", Quantity: 5},
+			},
+			skipped: 3,
+		},
+		{
+			broker: "ibkr",
+			csv: "\"AssetClass\",\"Symbol\",\"TradeDate\",\"Quantity\",\"TradePrice\",\"Buy/Sell\",\"Notes/Codes\"\n" +
+				"\"STK\",\"AAPL\",\"20250331\",\"10\",\"200.50\",\"BUY\",\"O\"\n" +
+				"\"OPT\",\"AAPL  250620C00200000\",\"20250401\",\"1\",\"5.10\",\"BUY\",\"O\"\n" +
+				"\"AssetClass\",\"Symbol\",\"TradeDate\",\"Quantity\",\"TradePrice\",\"Buy/Sell\",\"Notes/Codes\"\n" +
+				"\"STK\",\"AAPL\",\"2025-06-30\",\"-4\",\"205.17\",\"SELL\",\"C;P\"\n" +
+				"\"STK\",\"AAPL\",\"20250515\",\"5\",\"211.45\",\"BUY\",\"Ca\"\n",
+			trades: []importedTrade{
+				{Line: 2, Date: "2025-03-31", Ticker: "AAPL", Side: "buy", Quantity: 10},
+				{Line: 5, Date: "2025-06-30", Ticker: "AAPL", Side: "sell", Quantity: 4},
+			},
+			skipped: 3,
+		},
+	}
+	for _, tt := range tests {
+		format := brokerFormats[tt.broker]
+		trades, rowErrors, skipped, err := parsePortfolioCSV(strings.NewReader(tt.csv), &format, tt.symbols, "stock", "", "")
+		assert.NoError(t, err, tt.broker)
+		assert.Empty(t, rowErrors, tt.broker)
+		assert.Equal(t, tt.skipped, skipped, tt.broker)
+		for i := range tt.trades {
+			tt.trades[i].Type = "stock"
+		}
+		assert.Equal(t, tt.trades, trades, tt.broker)
+	}
+
+	// Degiro products need a ticker for their ISIN
+	format := brokerFormats["degiro"]
+	_, rowErrors, _, err := parsePortfolioCSV(strings.NewReader("Date,Product,ISIN,Quantity\n31-03-2025,APPLE INC,US0378331005,10\n"), &format, nil, "stock", "", "")
+	assert.NoError(t, err)
+	assert.Contains(t, rowErrors[0].Error, "US0378331005")
+
+	// Another broker's export
+	_, _, _, err = parsePortfolioCSV(strings.NewReader("date,ticker,quantity\n2025-03-31,AAPL,10\n"), &format, nil, "stock", "", "")
+	assert.Error(t, err)
+}
+
+// Test a broker's export is backtested through the import route
+func TestPortfolioImportBrokerWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	csv := "Date,Product,ISIN,Quantity\n30-06-2025,APPLE INC,US0378331005,-3\n31-03-2025,APPLE INC,US0378331005,10\n"
+	w := postPortfolioCSV(router, "?broker=degiro&symbols=US0378331005:AAPL&sellDate=2025-07-18", csv)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Broker        string  `json:"broker"`
+		SkippedRows   int     `json:"skippedRows"`
+		Proceeds      float64 `json:"proceeds"`
+		HoldingsValue float64 `json:"holdingsValue"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "Degiro", response.Broker)
+	assert.InDelta(t, 3*205.17, response.Proceeds, 1e-9)
+	assert.InDelta(t, 7*211.18, response.HoldingsValue, 1e-9)
+
+	w = postPortfolioCSV(router, "?broker=etrade", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postPortfolioCSV(router, "?broker=degiro&symbols=US0378331005", csv)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
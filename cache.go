@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cache lifetime of a backtest sold on a past date, which never changes. Anything
+// priced at today's (or a future) date is cached for CACHE_MAX_AGE seconds.
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// Helper function to make a strong ETag from a response body
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// Helper function to check an If-None-Match header against an ETag, comparing
+// weakly as RFC 9110 asks for GET and HEAD
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to check whether a response is for a holding sold before today
+// (UTC), so it can be cached for good. Holdings still held sell on the latest
+// close, which is usually before today but changes, and results built on stale,
+// fallback or missing data may be better on a later request.
+func isHistorical(c *gin.Context) bool {
+	sellDate := c.Param("sellDate")
+	if sellDate == "" || c.GetBool("stillHeld") || dateOnly(sellDate) >= time.Now().UTC().Format("2006-01-02") {
+		return false
+	}
+	notes, ok := c.Get("dataNotes")
+	return !ok || !notes.(*dataNotes).provisional()
+}
+
+// Middleware adding ETags and Cache-Control to successful GET responses and
+// answering If-None-Match with 304 Not Modified. Results for past sell dates are
+// cached for a year, unless their data notes say something was stale, from a
+// fallback provider or missing; others for CACHE_MAX_AGE seconds.
+func withCaching() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		if c.Writer.Status() != http.StatusOK {
+			c.Writer.Write(body)
+			return
+		}
+
+		etag := bodyETag(body)
+		header := c.Writer.Header()
+		header.Set("ETag", etag)
+		header.Add("Vary", "Accept-Language")
+		if isHistorical(c) {
+			header.Set("Cache-Control", immutableCacheControl)
+		} else {
+			header.Set("Cache-Control", "public, max-age="+strconv.Itoa(settings().CacheMaxAge))
+		}
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		c.Writer.Write(body)
+	}
+}
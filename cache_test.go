@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test If-None-Match comparison
+func TestETagMatches(t *testing.T) {
+	assert.True(t, etagMatches(`"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`"xyz", W/"abc"`, `"abc"`))
+	assert.True(t, etagMatches(`*`, `"abc"`))
+	assert.False(t, etagMatches(`"abd"`, `"abc"`))
+	assert.False(t, etagMatches(``, `"abc"`))
+}
+
+// Test ETags, Cache-Control and 304s on a past buy/sell backtest
+func TestCachingWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withCaching())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	request := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	path := "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18"
+
+	w := request(path, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+
+	// Same scenario, same ETag
+	assert.Equal(t, etag, request(path, nil).Header().Get("ETag"))
+
+	w = request(path, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+
+	// A different language is a different representation
+	w = request(path, map[string]string{"If-None-Match": etag, "Accept-Language": "de"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// Errors aren't cached
+	w = request("/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=nope", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+// Test a past backtest built on fallback or missing data isn't cached for good
+func TestCachingProvisionalData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withCaching())
+	router.GET("/on/:sellDate", func(c *gin.Context) {
+		switch c.Query("data") {
+		case "fallback":
+			requestNotes(c).add(dataSource{Kind: "fx", Symbol: "EUR/USD", Date: "2025-07-18", Provider: "fallback", Fallback: true})
+		case "missing":
+			requestNotes(c).unavailable("dividends", "AAPL", "2025-07-18", assert.AnError)
+		case "cached":
+			requestNotes(c).add(dataSource{Kind: "price", Symbol: "AAPL", Date: "2025-07-18", Provider: "alphavantage", Cached: true})
+		}
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+
+	cacheControl := func(data string) string {
+		req, _ := http.NewRequest("GET", "/on/2025-07-18?data="+data, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get("Cache-Control")
+	}
+	shortLived := "public, max-age=" + strconv.Itoa(settings().CacheMaxAge)
+	assert.Equal(t, immutableCacheControl, cacheControl(""))
+	assert.Equal(t, immutableCacheControl, cacheControl("cached"))
+	assert.Equal(t, shortLived, cacheControl("fallback"))
+	assert.Equal(t, shortLived, cacheControl("missing"))
+}
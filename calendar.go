@@ -0,0 +1,240 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a buy/sell holding lasted, so results can be annualized without a
+// market calendar of your own
+type holdingPeriod struct {
+	Days           int     `json:"days"`
+	Years          float64 `json:"years"`
+	TradingDays    int     `json:"tradingDays"`
+	DividendEvents int     `json:"dividendEvents"`
+	Exchange       string  `json:"exchange,omitempty"`
+}
+
+// Helper function to get the date of the nth weekday of a month (n < 0 counts
+// from the end of the month)
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	if n < 0 {
+		last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC)
+		return last.AddDate(0, 0, -((int(last.Weekday())-int(weekday)+7)%7 + 7*(-n-1)))
+	}
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	return first.AddDate(0, 0, (int(weekday)-int(first.Weekday())+7)%7+7*(n-1))
+}
+
+// Helper function to get Easter Sunday (anonymous Gregorian algorithm)
+func easterSunday(year int) time.Time {
+	a, b, c := year%19, year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Helper function to move a fixed-date holiday on a weekend to the weekday it's
+// observed on: Saturdays to the Friday before, Sundays to the Monday after
+func observed(date time.Time) time.Time {
+	switch date.Weekday() {
+	case time.Saturday:
+		return date.AddDate(0, 0, -1)
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// NYSE full-day holidays in a year, as observed. New Year's Day on a Saturday
+// isn't made up on the Friday before, which is the last trading day of the year.
+func nyseHolidays(year int) map[string]bool {
+	days := []time.Time{
+		nthWeekday(year, time.January, time.Monday, 3),  // Martin Luther King Jr. Day
+		nthWeekday(year, time.February, time.Monday, 3), // Presidents' Day
+		easterSunday(year).AddDate(0, 0, -2),            // Good Friday
+		nthWeekday(year, time.May, time.Monday, -1),     // Memorial Day
+		observed(time.Date(year, time.July, 4, 0, 0, 0, 0, time.UTC)),
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(time.Date(year, time.December, 25, 0, 0, 0, 0, time.UTC)),
+	}
+	if newYear := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC); newYear.Weekday() != time.Saturday {
+		days = append(days, observed(newYear))
+	}
+	if year >= 2022 {
+		days = append(days, observed(time.Date(year, time.June, 19, 0, 0, 0, 0, time.UTC)))
+	}
+
+	return holidaySet(days)
+}
+
+// Helper function to make a set of holiday dates
+func holidaySet(days []time.Time) map[string]bool {
+	holidays := make(map[string]bool, len(days))
+	for _, day := range days {
+		holidays[day.Format("2006-01-02")] = true
+	}
+	return holidays
+}
+
+// Helper function to add fixed-date holidays that are made up on the next free
+// weekday when they fall at a weekend or on another holiday, as in the UK,
+// Canada and Australia (Christmas on a Saturday, Boxing Day on the Sunday: closed
+// Monday and Tuesday)
+func substituteHolidays(days []time.Time, fixed ...time.Time) []time.Time {
+	taken := holidaySet(days)
+	for _, day := range fixed {
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday || taken[day.Format("2006-01-02")] {
+			day = day.AddDate(0, 0, 1)
+		}
+		taken[day.Format("2006-01-02")] = true
+		days = append(days, day)
+	}
+	return days
+}
+
+// Helper function to date a day of a year
+func yearDay(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// London Stock Exchange holidays: the English bank holidays, with the May ones
+// moved for VE Day anniversaries and jubilees. Jubilees, royal weddings and
+// funerals are in the closures dataset.
+func lseHolidays(year int) map[string]bool {
+	easter := easterSunday(year)
+	earlyMay := nthWeekday(year, time.May, time.Monday, 1)
+	if year == 1995 || year == 2020 {
+		earlyMay = yearDay(year, time.May, 8)
+	}
+	spring := nthWeekday(year, time.May, time.Monday, -1)
+	switch year {
+	case 2002, 2012:
+		spring = yearDay(year, time.June, 4)
+	case 2022:
+		spring = yearDay(year, time.June, 2)
+	}
+	days := []time.Time{
+		easter.AddDate(0, 0, -2), easter.AddDate(0, 0, 1), earlyMay, spring,
+		nthWeekday(year, time.August, time.Monday, -1), // Summer bank holiday
+	}
+	return holidaySet(substituteHolidays(days, yearDay(year, time.January, 1), yearDay(year, time.December, 25), yearDay(year, time.December, 26)))
+}
+
+// Xetra holidays: New Year, Easter, Labour Day and Christmas Eve to New Year's Eve
+func xetraHolidays(year int) map[string]bool {
+	easter := easterSunday(year)
+	return holidaySet([]time.Time{
+		yearDay(year, time.January, 1), easter.AddDate(0, 0, -2), easter.AddDate(0, 0, 1), yearDay(year, time.May, 1),
+		yearDay(year, time.December, 24), yearDay(year, time.December, 25), yearDay(year, time.December, 26), yearDay(year, time.December, 31),
+	})
+}
+
+// Euronext holidays (Paris, Amsterdam): New Year, Easter, Labour Day and Christmas
+func euronextHolidays(year int) map[string]bool {
+	easter := easterSunday(year)
+	return holidaySet([]time.Time{
+		yearDay(year, time.January, 1), easter.AddDate(0, 0, -2), easter.AddDate(0, 0, 1), yearDay(year, time.May, 1),
+		yearDay(year, time.December, 25), yearDay(year, time.December, 26),
+	})
+}
+
+// SIX Swiss Exchange holidays, including Ascension, Whit Monday and National Day
+func sixHolidays(year int) map[string]bool {
+	easter := easterSunday(year)
+	return holidaySet([]time.Time{
+		yearDay(year, time.January, 1), yearDay(year, time.January, 2), easter.AddDate(0, 0, -2), easter.AddDate(0, 0, 1),
+		easter.AddDate(0, 0, 39), easter.AddDate(0, 0, 50), yearDay(year, time.May, 1), yearDay(year, time.August, 1),
+		yearDay(year, time.December, 24), yearDay(year, time.December, 25), yearDay(year, time.December, 26), yearDay(year, time.December, 31),
+	})
+}
+
+// Toronto Stock Exchange holidays, with Family Day from 2008
+func tsxHolidays(year int) map[string]bool {
+	victoriaDay := yearDay(year, time.May, 24)
+	for victoriaDay.Weekday() != time.Monday {
+		victoriaDay = victoriaDay.AddDate(0, 0, -1)
+	}
+	days := []time.Time{
+		easterSunday(year).AddDate(0, 0, -2), victoriaDay,
+		nthWeekday(year, time.August, time.Monday, 1),    // Civic Holiday
+		nthWeekday(year, time.September, time.Monday, 1), // Labour Day
+		nthWeekday(year, time.October, time.Monday, 2),   // Thanksgiving
+	}
+	if year >= 2008 {
+		days = append(days, nthWeekday(year, time.February, time.Monday, 3))
+	}
+	return holidaySet(substituteHolidays(days, yearDay(year, time.January, 1), yearDay(year, time.July, 1), yearDay(year, time.December, 25), yearDay(year, time.December, 26)))
+}
+
+// Australian Securities Exchange holidays. Anzac Day isn't made up when it falls
+// at a weekend.
+func asxHolidays(year int) map[string]bool {
+	easter := easterSunday(year)
+	days := []time.Time{
+		easter.AddDate(0, 0, -2), easter.AddDate(0, 0, 1), yearDay(year, time.April, 25),
+		nthWeekday(year, time.June, time.Monday, 2), // King's (Queen's) Birthday
+	}
+	return holidaySet(substituteHolidays(days, yearDay(year, time.January, 1), yearDay(year, time.January, 26), yearDay(year, time.December, 25), yearDay(year, time.December, 26)))
+}
+
+// Get the date of an asset's most recent daily close at a moment. Crypto days close
+// at the snapshot time (by default midnight UTC, so that's yesterday's); everything
+// else closes on its exchange's trading days at its local closing time (4pm in New
+// York, 3:30pm in Tokyo), so a Tokyo stock at 7am UTC already has today's close
+// and a New York one doesn't.
+func latestCloseDate(ticker, assetType string, now time.Time) string {
+	market := exchangeFor(ticker, assetType)
+	if market == nil {
+		return cryptoLatestClose(now)
+	}
+	return market.latestClose(now)
+}
+
+// Count the trading days after the buy date up to and including the sell date.
+// Crypto trades every day; everything else follows its exchange's calendar.
+func countTradingDays(ticker, buyDate, sellDate, assetType string) int {
+	start, err := time.Parse("2006-01-02", dateOnly(buyDate))
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse("2006-01-02", dateOnly(sellDate))
+	if err != nil {
+		return 0
+	}
+
+	market := exchangeFor(ticker, assetType)
+	count := 0
+	for date := start.AddDate(0, 0, 1); !date.After(end); date = date.AddDate(0, 0, 1) {
+		if market == nil || market.isTradingDay(date) {
+			count++
+		}
+	}
+	return count
+}
+
+// Helper function to add a "holdingPeriod" block with the days held, the trading
+// days in between on the asset's exchange and how many dividends were paid along
+// the way
+func addHoldingPeriod(response gin.H, ticker, buyDate, sellDate, assetType string, dividends []dividendData) {
+	days := daysBetween(dateOnly(buyDate), dateOnly(sellDate))
+	period := holdingPeriod{
+		Days:           days,
+		Years:          float64(days) / 365.25,
+		TradingDays:    countTradingDays(ticker, buyDate, sellDate, assetType),
+		DividendEvents: len(dividends),
+	}
+	if market := exchangeFor(ticker, assetType); market != nil {
+		period.Exchange = market.Code
+	}
+	response["holdingPeriod"] = period
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test NYSE holidays land on the days they're observed
+func TestNYSEHolidays(t *testing.T) {
+	holidays := nyseHolidays(2025)
+	for _, date := range []string{
+		"2025-01-01", "2025-01-20", "2025-02-17", "2025-04-18", "2025-05-26",
+		"2025-06-19", "2025-07-04", "2025-09-01", "2025-11-27", "2025-12-25",
+	} {
+		assert.True(t, holidays[date], date)
+	}
+	assert.Len(t, holidays, 10)
+
+	// Weekend holidays move to the Friday before or Monday after
+	assert.True(t, nyseHolidays(2021)["2021-07-05"])
+	assert.True(t, nyseHolidays(2021)["2021-12-24"])
+	assert.True(t, nyseHolidays(2023)["2023-01-02"])
+
+	// ...except New Year's Day on a Saturday, and Juneteenth before 2022
+	assert.False(t, nyseHolidays(2021)["2021-12-31"])
+	assert.Len(t, nyseHolidays(2022), 9)
+	assert.False(t, nyseHolidays(2021)["2021-06-18"])
+}
+
+// Test trading days are counted after the buy date through the sell date
+func TestCountTradingDays(t *testing.T) {
+	// Good Friday, Memorial Day, Juneteenth and Independence Day fall in between
+	assert.Equal(t, 75, countTradingDays("AAPL", "2025-03-31", "2025-07-18", "stock"))
+	// A weekend and a holiday aren't trading days
+	assert.Equal(t, 0, countTradingDays("AAPL", "2025-07-03", "2025-07-06", "stock"))
+	assert.Equal(t, 1, countTradingDays("^GSPC", "2025-07-03T10:00", "2025-07-07T15:30", "index"))
+	// Crypto trades every day
+	assert.Equal(t, 109, countTradingDays("BTC", "2025-03-31", "2025-07-18", "crypto"))
+	// London trades on Independence Day
+	assert.Equal(t, 2, countTradingDays("VOD.L", "2025-07-03", "2025-07-07", "stock"))
+}
+
+// Test exchanges outside New York close on their own holidays
+func TestExchangeHolidays(t *testing.T) {
+	closed := func(code, date string) bool {
+		day, _ := time.Parse("2006-01-02", date)
+		return !exchanges[code].isTradingDay(day)
+	}
+
+	// The early May bank holiday moved for VE Day's 75th anniversary
+	assert.True(t, closed("LSE", "2020-05-08"))
+	assert.False(t, closed("LSE", "2020-05-04"))
+	// Christmas on a Saturday and Boxing Day on the Sunday close Monday and Tuesday
+	assert.True(t, closed("LSE", "2021-12-27"))
+	assert.True(t, closed("LSE", "2021-12-28"))
+	// The Platinum Jubilee: a moved spring bank holiday and a one-off closure
+	assert.True(t, closed("LSE", "2022-06-02"))
+	assert.True(t, closed("LSE", "2022-06-03"))
+
+	assert.True(t, closed("XETRA", "2025-12-24"))
+	assert.True(t, closed("EPA", "2025-05-01"))
+	assert.True(t, closed("SIX", "2025-05-29")) // Ascension
+	assert.True(t, closed("TSX", "2025-02-17")) // Family Day
+	assert.False(t, closed("TSX", "2007-02-19"))
+	assert.True(t, closed("TSX", "2025-05-19")) // Victoria Day
+	// Australia Day on a Sunday is made up on the Monday; Anzac Day isn't
+	assert.True(t, closed("ASX", "2025-01-27"))
+	assert.False(t, closed("ASX", "2021-04-26"))
+	assert.True(t, closed("ASX", "2022-09-22"))
+
+	// The NYSE closed for President Carter's funeral
+	assert.True(t, closed("NYSE", "2025-01-09"))
+	// Exchanges without rules close only at weekends
+	assert.False(t, closed("HKEX", "2025-12-25"))
+}
+
+// Test buy/sell responses carry the holding period, counting the May and July
+// ex-dividend dates
+func TestHoldingPeriodWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	for _, path := range []string{
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?type=stock",
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?type=stock",
+	} {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			HoldingPeriod holdingPeriod `json:"holdingPeriod"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 109, response.HoldingPeriod.Days, path)
+		assert.InDelta(t, 109/365.25, response.HoldingPeriod.Years, 1e-9, path)
+		assert.Equal(t, 75, response.HoldingPeriod.TradingDays, path)
+		assert.Equal(t, 2, response.HoldingPeriod.DividendEvents, path)
+	}
+}
+
+// Test the most recent close skips weekends, holidays and days still trading
+func TestLatestCloseDate(t *testing.T) {
+	at := func(value string) time.Time {
+		moment, _ := time.ParseInLocation("2006-01-02 15:04", value, newYork)
+		return moment
+	}
+	assert.Equal(t, "2025-07-17", latestCloseDate("AAPL", "stock", at("2025-07-18 15:59")))
+	assert.Equal(t, "2025-07-18", latestCloseDate("AAPL", "stock", at("2025-07-18 16:00")))
+	// Saturday, and the Monday after Independence Day (a Friday) before the close
+	assert.Equal(t, "2025-07-18", latestCloseDate("^GSPC", "index", at("2025-07-19 12:00")))
+	assert.Equal(t, "2025-07-03", latestCloseDate("AAPL", "stock", at("2025-07-07 09:30")))
+	// Crypto closes at midnight UTC, 8pm in New York in summer
+	assert.Equal(t, "2025-07-18", latestCloseDate("BTC", "crypto", at("2025-07-19 19:59")))
+	assert.Equal(t, "2025-07-19", latestCloseDate("BTC", "crypto", at("2025-07-19 20:00")))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A FRED interest rate series (annual percent) over a window, from a year before
+// it so monthly and weekly series have a rate in force on its first day
+type cashRates struct {
+	seriesID     string
+	observations []fredObservation
+}
+
+// Fetch a FRED interest rate series for the window from start to end
+func fetchCashRates(seriesID, start, end string) (cashRates, error) {
+	first, err := time.Parse("2006-01-02", dateOnly(start))
+	if err != nil {
+		return cashRates{}, err
+	}
+	observations, err := fetchFREDObservations(seriesID, first.AddDate(-1, 0, 0).Format("2006-01-02"), dateOnly(end))
+	if err != nil {
+		return cashRates{}, err
+	}
+	if len(observations) == 0 || observations[0].Date > dateOnly(start) {
+		return cashRates{}, fmt.Errorf("No %s rate in force on %s", seriesID, dateOnly(start))
+	}
+	return cashRates{seriesID: seriesID, observations: observations}, nil
+}
+
+// Grow an amount from one date to another, compounding daily at the latest
+// published rate for each day
+func (r cashRates) grow(amount float64, from, to string) (float64, error) {
+	start, err := time.Parse("2006-01-02", dateOnly(from))
+	if err != nil {
+		return 0, err
+	}
+	end, err := time.Parse("2006-01-02", dateOnly(to))
+	if err != nil {
+		return 0, err
+	}
+
+	value := amount
+	next := 0
+	rate := 0.0
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		for next < len(r.observations) && r.observations[next].Date <= day.Format("2006-01-02") {
+			rate = r.observations[next].Value
+			next++
+		}
+		value *= 1 + rate/100/365
+	}
+	return value, nil
+}
+
+// Grow an amount at a FRED interest rate series (annual percent) from buyDate to
+// sellDate, compounding daily at the latest published rate for each day
+func calculateCashValue(amount float64, seriesID, buyDate, sellDate string) (float64, error) {
+	rates, err := fetchCashRates(seriesID, buyDate, sellDate)
+	if err != nil {
+		return 0, err
+	}
+	return rates.grow(amount, buyDate, sellDate)
+}
+
+// Helper function to accrue interest at the CASH_RATE_SERIES rate on dividends
+// kept as cash, from each payment date to the sell date, for ?cashInterest=true.
+// Sets each payment's interest and returns the total.
+func accrueDividendInterest(payments []cashDividend, buyDate, sellDate string) (float64, error) {
+	rates, err := fetchCashRates(settings().CashRateSeries, buyDate, sellDate)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for i, payment := range payments {
+		value, err := rates.grow(payment.Amount, payment.PaymentDate, sellDate)
+		if err != nil {
+			return 0, err
+		}
+		payments[i].InterestEarned = value - payment.Amount
+		total += payments[i].InterestEarned
+	}
+	return total, nil
+}
+
+// Helper function to accrue interest at the CASH_RATE_SERIES rate on the cash a
+// DRIP leaves idle, the dividends or parts of them it didn't reinvest, from each
+// payment date to the sell date. Sets each event's interest and returns the total.
+func accrueDRIPInterest(events []dripEvent, buyDate, sellDate string) (float64, error) {
+	rates, err := fetchCashRates(settings().CashRateSeries, buyDate, sellDate)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for i, event := range events {
+		idle := event.Amount - event.reinvested()
+		if idle <= 0 {
+			continue
+		}
+		value, err := rates.grow(idle, event.PaymentDate, sellDate)
+		if err != nil {
+			return 0, err
+		}
+		events[i].InterestEarned = value - idle
+		total += events[i].InterestEarned
+	}
+	return total, nil
+}
+
+// Helper function to add a "versus leaving it in cash" block, growing the amount
+// invested (USD) at the CASH_RATE_SERIES rate over the same window
+func addCashComparison(response gin.H, compareCash bool, investedUSD, finalValueUSD, fxRateSell float64, buyDate, sellDate string) error {
+	cfg := settings()
+	if !compareCash {
+		return nil
+	}
+
+	cashValueUSD, err := calculateCashValue(investedUSD, cfg.CashRateSeries, buyDate, sellDate)
+	if err != nil {
+		return err
+	}
+
+	response["cashComparison"] = gin.H{
+		"rateSeries":                   cfg.CashRateSeries,
+		"finalValueUSD":                cashValueUSD,
+		"finalValueInOriginalCurrency": cashValueUSD * fxRateSell,
+		"differenceUSD":                finalValueUSD - cashValueUSD,
+	}
+	return nil
+}
+
+// Helper function to add "interestEarned" on idle cash and the "cashRateSeries"
+// it was earned at, with ?cashInterest=true
+func addCashInterest(response gin.H, enabled bool, interestUSD float64) {
+	if !enabled {
+		return
+	}
+	response["interestEarned"] = interestUSD
+	response["cashRateSeries"] = settings().CashRateSeries
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the compareCash block against a constant rate
+func TestCashComparison(t *testing.T) {
+	setupMockFRED(t)
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock&compareCash=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// 109 days at 4.33%, compounded daily
+	cashValue := 2005.0 * math.Pow(1+0.0433/365, 109)
+	cash := response["cashComparison"].(map[string]interface{})
+	assert.Equal(t, "FEDFUNDS", cash["rateSeries"])
+	assert.InDelta(t, cashValue, cash["finalValueUSD"], 1e-9)
+	assert.InDelta(t, 2111.8-cashValue, cash["differenceUSD"], 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock")
+	var plain map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &plain)
+	assert.NoError(t, err)
+	assert.NotContains(t, plain, "cashComparison")
+}
+
+// Test ?cashInterest=true grows dividends held as cash, and the cash a DRIP
+// doesn't reinvest, at the cash rate series from their payment date
+func TestCashInterestWithMocks(t *testing.T) {
+	setupMockFRED(t)
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	// The May dividend is paid 64 days before the sale; July's after it
+	mayInterest := func(amount float64) float64 { return amount * (math.Pow(1+0.0433/365, 64) - 1) }
+
+	var response struct {
+		Dividends      []cashDividend `json:"dividends"`
+		InterestEarned float64        `json:"interestEarned"`
+		CashRateSeries string         `json:"cashRateSeries"`
+		FinalValue     float64        `json:"finalValue"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?cashInterest=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "FEDFUNDS", response.CashRateSeries)
+	assert.InDelta(t, mayInterest(2.6), response.Dividends[0].InterestEarned, 1e-9)
+	assert.Equal(t, 0.0, response.Dividends[1].InterestEarned)
+	assert.InDelta(t, mayInterest(2.6), response.InterestEarned, 1e-9)
+	assert.InDelta(t, 10*211.18+2.6+response.Dividends[1].Amount+mayInterest(2.6), response.FinalValue, 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?cashInterest=true&depositRate=3")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var drip struct {
+		Dividends      []dripEvent `json:"dividends"`
+		DividendCash   float64     `json:"dividendCash"`
+		InterestEarned float64     `json:"interestEarned"`
+		TotalShares    float64     `json:"totalShares"`
+		FinalValue     float64     `json:"finalValue"`
+	}
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?reinvest=50&cashInterest=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &drip))
+	assert.InDelta(t, mayInterest(1.3), drip.Dividends[0].InterestEarned, 1e-9)
+	assert.InDelta(t, mayInterest(1.3), drip.InterestEarned, 1e-9)
+	assert.InDelta(t, drip.TotalShares*211.18+drip.DividendCash+mayInterest(1.3), drip.FinalValue, 1e-9)
+
+	// Fully reinvested, nothing sits idle before the sale
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?cashInterest=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	drip.InterestEarned = -1
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &drip))
+	assert.Equal(t, 0.0, drip.InterestEarned)
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip")
+	assert.NotContains(t, w.Body.String(), "interestEarned")
+
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "cash", "cashInterest": true}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	response.InterestEarned = 0
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, mayInterest(2.6), response.InterestEarned, 1e-9)
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "dividends": {"mode": "cash", "cashInterest": true, "depositRate": 2}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "dividends.depositRate can't be combined with dividends.cashInterest")
+}
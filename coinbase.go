@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Coinbase's largest page of candles
+const coinbaseCandleLimit = 300
+
+// Candle granularities by length, finest first, and the longest span each is used
+// for
+var coinbaseGranularities = []struct {
+	Length  time.Duration
+	MaxSpan time.Duration
+}{
+	{time.Minute, 24 * time.Hour},
+	{time.Hour, coinbaseCandleLimit * time.Hour},
+	{24 * time.Hour, 0},
+}
+
+// Coinbase's candles for a coin's USD pair, priced in dollars rather than a
+// stablecoin: minute candles for spans of up to a day, hourly ones up to a page of
+// them, and daily ones beyond, paged through as needed
+type coinbaseProvider struct{}
+
+func (coinbaseProvider) Name() string { return "coinbase" }
+
+// Helper function to pick the candle length for a span
+func coinbaseGranularity(span time.Duration) time.Duration {
+	for _, granularity := range coinbaseGranularities {
+		if span <= granularity.MaxSpan || granularity.MaxSpan == 0 {
+			return granularity.Length
+		}
+	}
+	return 0
+}
+
+// Fetch one page of candles opening from start to end, oldest first. Coinbase
+// sends them newest first as [time, low, high, open, close, volume], leaving out
+// minutes without trades.
+// Example: https://api.exchange.coinbase.com/products/BTC-USD/candles?granularity=60&start=2025-07-18T14:00:00Z&end=2025-07-18T18:59:00Z
+func fetchCoinbaseCandles(product string, length time.Duration, start, end time.Time) ([][6]float64, error) {
+	query := url.Values{
+		"granularity": {fmt.Sprint(int(length.Seconds()))},
+		"start":       {start.Format(time.RFC3339)},
+		"end":         {end.Format(time.RFC3339)},
+	}
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/products/%s/candles?%s", settings().CoinbaseBaseURL, url.PathEscape(product), query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return nil, fmt.Errorf("Coinbase returned status %d %s", resp.StatusCode, failure.Message)
+	}
+
+	var candles [][6]float64
+	if err := json.NewDecoder(resp.Body).Decode(&candles); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	sort.Slice(candles, func(i, j int) bool { return candles[i][0] < candles[j][0] })
+	return candles, nil
+}
+
+func (coinbaseProvider) Quotes(symbol string, from, to time.Time) ([][2]float64, error) {
+	product := symbol + "-USD"
+	length := coinbaseGranularity(to.Sub(from))
+
+	var points [][2]float64
+	for start := from.Truncate(length); !start.After(to); start = start.Add(coinbaseCandleLimit * length) {
+		end := start.Add((coinbaseCandleLimit - 1) * length)
+		if end.After(to) {
+			end = to
+		}
+		candles, err := fetchCoinbaseCandles(product, length, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, candle := range candles {
+			opened := time.Unix(int64(candle[0]), 0).UTC()
+			points = appendBarQuotes(points, from, to, opened, length, [4]float64{candle[3], candle[2], candle[1], candle[4]})
+		}
+	}
+	return dedupeQuotes(points), nil
+}
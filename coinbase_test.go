@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Coinbase candles API for BTC-USD, newest candle first: each opens
+// at mockCryptoPrices' price for its UTC day plus the minutes since midnight,
+// ranges 5 either way and closes 20 up. Returns the number of requests made.
+func setupMockCoinbase(t *testing.T) *int {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/products/BTC-USD/candles" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "NotFound"}`)
+			return
+		}
+		query := r.URL.Query()
+		seconds, _ := strconv.Atoi(query.Get("granularity"))
+		length := time.Duration(seconds) * time.Second
+		start, _ := time.Parse(time.RFC3339, query.Get("start"))
+		end, _ := time.Parse(time.RFC3339, query.Get("end"))
+
+		candles := [][6]float64{}
+		for opened := end.Truncate(length); !opened.Before(start); opened = opened.Add(-length) {
+			day := opened.Truncate(24 * time.Hour)
+			open := mockCryptoPrices["bitcoin"][day.Format("2006-01-02")] + opened.Sub(day).Minutes()
+			candles = append(candles, [6]float64{float64(opened.Unix()), open - 5, open + 5, open, open + 20, 3.5})
+		}
+		if len(candles) > coinbaseCandleLimit {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message": "granularity too small for the requested time range"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(candles)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.CoinbaseBaseURL = server.URL })
+	return &requests
+}
+
+// Test spans get minute candles up to a day, then hourly ones up to a page
+func TestCoinbaseGranularity(t *testing.T) {
+	assert.Equal(t, time.Minute, coinbaseGranularity(24*time.Hour))
+	assert.Equal(t, time.Hour, coinbaseGranularity(10*24*time.Hour))
+	assert.Equal(t, 24*time.Hour, coinbaseGranularity(30*24*time.Hour))
+}
+
+// Test candles become quotes oldest first, paging through a day of minutes
+func TestCoinbaseQuotes(t *testing.T) {
+	requests := setupMockCoinbase(t)
+
+	from := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	points, err := coinbaseProvider{}.Quotes("BTC", from, from.Add(2*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]float64{
+		{float64(from.UnixMilli()), 118000},
+		{float64(from.Add(20 * time.Second).UnixMilli()), 118005},
+		{float64(from.Add(40 * time.Second).UnixMilli()), 117995},
+		{float64(from.Add(time.Minute).UnixMilli()), 118020},
+		{float64(from.Add(80 * time.Second).UnixMilli()), 118006},
+		{float64(from.Add(100 * time.Second).UnixMilli()), 117996},
+		{float64(from.Add(2 * time.Minute).UnixMilli()), 118021},
+	}, points)
+
+	*requests = 0
+	points, err = coinbaseProvider{}.Quotes("BTC", from, from.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, *requests)
+	assert.Equal(t, float64(from.Add(24*time.Hour).UnixMilli()), points[len(points)-1][0])
+	assert.Equal(t, 119459.0, ohlcPoint(points, "close"))
+
+	_, err = coinbaseProvider{}.Quotes("NOPE", from, from.Add(time.Minute))
+	assert.ErrorContains(t, err, "Coinbase returned status 404 NotFound")
+}
+
+// Test Coinbase's USD pairs are tried before Binance's USDT ones, after
+// CoinGecko for whole days and before it for times of day
+func TestCryptoProviderOrder(t *testing.T) {
+	names := func(providers []cryptoProvider) []string {
+		var names []string
+		for _, provider := range providers {
+			names = append(names, provider.Name())
+		}
+		return names
+	}
+	setSettings(t, func(cfg *Config) {
+		cfg.CoinbaseBaseURL, cfg.BinanceBaseURL, cfg.CryptoCompareBaseURL, cfg.CoinCapAPIKey = "http://coinbase", "http://binance", "http://cryptocompare", ""
+	})
+
+	assert.Equal(t, []string{"coinGecko", "coinbase", "binance", "cryptoCompare"}, names(cryptoProviders(false)))
+	assert.Equal(t, []string{"binance", "coinbase", "coinGecko", "cryptoCompare"}, names(cryptoProviders(true)))
+
+	setSettings(t, func(cfg *Config) { cfg.CoinbaseBaseURL = "" })
+	assert.Equal(t, []string{"coinGecko", "binance", "cryptoCompare"}, names(cryptoProviders(false)))
+}
+
+// Test crypto prices fall back to Coinbase while CoinGecko is throttling
+func TestCoinbaseFallback(t *testing.T) {
+	setupMockCoinGecko(t)
+	throttled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(throttled.Close)
+	setSettings(t, func(cfg *Config) { cfg.CoinGeckoBaseURL = throttled.URL })
+	setupMockCoinbase(t)
+	setupMockCryptoCompare(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/0.5/BTC/on/2025-03-31/and-sold-on/2025-07-18?type=crypto")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		BuyPrice  float64      `json:"buyPrice"`
+		SellPrice float64      `json:"sellPrice"`
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// The close is the day's last minute candle's, at midnight
+	assert.Equal(t, 82500.0+1439+20, response.BuyPrice)
+	assert.Equal(t, 118000.0+1439+20, response.SellPrice)
+	assert.Len(t, response.DataNotes, 2)
+	for _, source := range response.DataNotes {
+		assert.Equal(t, "coinbase", source.Provider)
+		assert.True(t, source.Fallback)
+	}
+}
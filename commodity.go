@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A commodity priced in USD per Unit, from either a Stooq spot symbol or an
+// Alpha Vantage commodity function (daily spot series)
+type commodity struct {
+	Name          string
+	Unit          string
+	UnitPlural    string
+	StooqSymbol   string
+	AlphaFunction string
+}
+
+// Supported commodities by ticker
+var commodities = map[string]commodity{
+	"GOLD":      {Name: "Gold", Unit: "troy ounce", UnitPlural: "troy ounces", StooqSymbol: "xauusd"},
+	"SILVER":    {Name: "Silver", Unit: "troy ounce", UnitPlural: "troy ounces", StooqSymbol: "xagusd"},
+	"PLATINUM":  {Name: "Platinum", Unit: "troy ounce", UnitPlural: "troy ounces", StooqSymbol: "xptusd"},
+	"PALLADIUM": {Name: "Palladium", Unit: "troy ounce", UnitPlural: "troy ounces", StooqSymbol: "xpdusd"},
+	"OIL":       {Name: "WTI crude oil", Unit: "barrel", UnitPlural: "barrels", AlphaFunction: "WTI"},
+	"WTI":       {Name: "WTI crude oil", Unit: "barrel", UnitPlural: "barrels", AlphaFunction: "WTI"},
+	"BRENT":     {Name: "Brent crude oil", Unit: "barrel", UnitPlural: "barrels", AlphaFunction: "BRENT"},
+	"NATGAS":    {Name: "Henry Hub natural gas", Unit: "MMBtu", UnitPlural: "MMBtu", AlphaFunction: "NATURAL_GAS"},
+}
+
+// Fetch the USD spot price of one unit of a commodity on a date. Alpha Vantage
+// series carry a single daily value, used for every priceAt.
+func fetchCommodityPriceUSD(ticker, date, priceAt string) (float64, error) {
+	item, ok := commodities[strings.ToUpper(ticker)]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported commodity %s", ticker)
+	}
+
+	if item.StooqSymbol != "" {
+		return fetchStooqPrice(item.StooqSymbol, date, priceAt)
+	}
+	return fetchCommodityAlphaVantage(item.AlphaFunction, date)
+}
+
+// Fetch a daily commodity spot price from Alpha Vantage
+// Example: https://www.alphavantage.co/query?function=WTI&interval=daily&apikey=demo
+func fetchCommodityAlphaVantage(function, date string) (float64, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=%s&interval=daily&apikey=%s", cfg.AlphaVantageBaseURL, function, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var result struct {
+		Data []struct {
+			Date  string `json:"date"`
+			Value string `json:"value"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	for _, point := range result.Data {
+		if point.Date != date {
+			continue
+		}
+		// Days without a quote are reported as "."
+		price, err := strconv.ParseFloat(point.Value, 64)
+		if err != nil {
+			break
+		}
+		return price, nil
+	}
+	return 0, fmt.Errorf("No %s price for date %s", function, date)
+}
+
+// Helper function to add the commodity's unit to a response, so quantities read
+// as e.g. "10 troy ounces" and prices as USD per troy ounce
+func addCommodityUnit(response gin.H, ticker, assetType string) {
+	if assetType != "commodity" {
+		return
+	}
+	if item, ok := commodities[strings.ToUpper(ticker)]; ok {
+		response["commodity"] = item.Name
+		response["unit"] = item.Unit
+		response["units"] = item.UnitPlural
+	}
+}
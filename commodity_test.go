@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test commodity spot prices from both providers
+func TestCommodityPrice(t *testing.T) {
+	setupMockStooq(t)
+	setupMockAlphaVantage(t)
+
+	price, err := fetchCommodityPriceUSD("gold", "2025-07-18", "close")
+	assert.NoError(t, err)
+	assert.Equal(t, 3350.20, price)
+
+	price, err = fetchCommodityPriceUSD("OIL", "2025-03-31", "open")
+	assert.NoError(t, err)
+	assert.Equal(t, 71.48, price)
+
+	// No quote that day
+	_, err = fetchCommodityPriceUSD("WTI", "2025-07-17", "close")
+	assert.Error(t, err)
+
+	_, err = fetchCommodityPriceUSD("UNOBTANIUM", "2025-07-18", "close")
+	assert.Error(t, err)
+}
+
+// Test commodity backtests with unit handling
+func TestCommodityRoute(t *testing.T) {
+	setupMockStooq(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/of/GOLD/on/2025-03-31/and-sold-on/2025-07-18?type=commodity")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "commodity", response["type"])
+	assert.Equal(t, "troy ounce", response["unit"])
+	assert.Equal(t, "troy ounces", response["units"])
+	assert.InDelta(t, 33502.0, response["finalValue"], 1e-9)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// Bodies smaller than this aren't worth compressing
+const compressMinBytes = 1024
+
+// Supported encodings, preferred first when a client accepts several equally
+var compressionEncodings = []string{"br", "gzip"}
+
+// Helper function to pick the encoding to compress a response with from an
+// Accept-Encoding header, or "" to send it as is
+func acceptedEncoding(header string) string {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[coding] = weight
+	}
+
+	best, bestWeight := "", 0.0
+	for _, encoding := range compressionEncodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = weights["*"]
+		}
+		if weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	return best
+}
+
+// Helper function to compress a body with gzip or brotli
+func compressBody(body []byte, encoding string) ([]byte, error) {
+	var out bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == "br" {
+		writer = brotli.NewWriterLevel(&out, brotli.DefaultCompression)
+	} else {
+		writer = gzip.NewWriter(&out)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Middleware compressing response bodies with brotli or gzip, as negotiated
+// through Accept-Encoding
+func withCompression() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		header := c.Writer.Header()
+		header.Add("Vary", "Accept-Encoding")
+		if encoding == "" || len(body) < compressMinBytes || header.Get("Content-Encoding") != "" {
+			c.Writer.Write(body)
+			return
+		}
+
+		compressed, err := compressBody(body, encoding)
+		if err != nil {
+			c.Writer.Write(body)
+			return
+		}
+		header.Set("Content-Encoding", encoding)
+		header.Del("Content-Length")
+		c.Writer.Write(compressed)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Accept-Encoding negotiation
+func TestAcceptedEncoding(t *testing.T) {
+	assert.Equal(t, "br", acceptedEncoding("gzip, deflate, br"))
+	assert.Equal(t, "gzip", acceptedEncoding("gzip, br;q=0.5"))
+	assert.Equal(t, "gzip", acceptedEncoding("GZIP"))
+	assert.Equal(t, "br", acceptedEncoding("*"))
+	assert.Equal(t, "gzip", acceptedEncoding("*, br;q=0"))
+	assert.Equal(t, "", acceptedEncoding("deflate"))
+	assert.Equal(t, "", acceptedEncoding(""))
+}
+
+// Test responses are compressed as negotiated, and small ones are left alone
+func TestCompression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withCompression())
+	large := strings.Repeat(`{"date":"2025-01-02","price":123.45},`, 100)
+	router.GET("/large", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	router.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	request := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/large", "gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Less(t, w.Body.Len(), len(large))
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(reader)
+	assert.Equal(t, large, string(body))
+
+	w = request("/large", "gzip, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	body, _ = io.ReadAll(brotli.NewReader(bytes.NewReader(w.Body.Bytes())))
+	assert.Equal(t, large, string(body))
+
+	w = request("/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	w = request("/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "ok", w.Body.String())
+}
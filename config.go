@@ -0,0 +1,401 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Server settings. Each is read from the config file by its key and can be
+// overridden by its environment variable.
+type Config struct {
+	AlphaVantageAPIKey   string  `key:"alpha_vantage_api_key" env:"ALPHA_VANTAGE_API_KEY"`
+	AlphaVantageBaseURL  string  `key:"alpha_vantage_base_url" env:"ALPHA_VANTAGE_BASE_URL"`
+	FrankfurterBaseURL   string  `key:"frankfurter_base_url" env:"FRANKFURTER_BASE_URL"`
+	FXFallbackBaseURL    string  `key:"fx_fallback_base_url" env:"FX_FALLBACK_BASE_URL"`
+	FXFallbackAPIKey     string  `key:"fx_fallback_api_key" env:"FX_FALLBACK_API_KEY"`
+	FXDatasetPath        string  `key:"fx_dataset_path" env:"FX_DATASET_PATH"`
+	CorporateActionsPath string  `key:"corporate_actions_path" env:"CORPORATE_ACTIONS_PATH"`
+	EquivalentsPath      string  `key:"equivalents_path" env:"EQUIVALENTS_PATH"`
+	ExchangeHolidaysPath string  `key:"exchange_holidays_path" env:"EXCHANGE_HOLIDAYS_PATH"`
+	CoinGeckoBaseURL     string  `key:"coingecko_base_url" env:"COINGECKO_BASE_URL"`
+	CoinGeckoAPIKey      string  `key:"coingecko_api_key" env:"COINGECKO_API_KEY"`
+	CoinbaseBaseURL      string  `key:"coinbase_base_url" env:"COINBASE_BASE_URL"`
+	BinanceBaseURL       string  `key:"binance_base_url" env:"BINANCE_BASE_URL"`
+	CryptoCompareBaseURL string  `key:"cryptocompare_base_url" env:"CRYPTOCOMPARE_BASE_URL"`
+	CryptoCompareAPIKey  string  `key:"cryptocompare_api_key" env:"CRYPTOCOMPARE_API_KEY"`
+	CoinCapBaseURL       string  `key:"coincap_base_url" env:"COINCAP_BASE_URL"`
+	CoinCapAPIKey        string  `key:"coincap_api_key" env:"COINCAP_API_KEY"`
+	CryptoSnapshotTime   string  `key:"crypto_snapshot_time" env:"CRYPTO_SNAPSHOT_TIME"`
+	StockProviders       string  `key:"stock_providers" env:"STOCK_PROVIDERS"`
+	PolygonBaseURL       string  `key:"polygon_base_url" env:"POLYGON_BASE_URL"`
+	PolygonAPIKey        string  `key:"polygon_api_key" env:"POLYGON_API_KEY"`
+	TiingoBaseURL        string  `key:"tiingo_base_url" env:"TIINGO_BASE_URL"`
+	TiingoAPIKey         string  `key:"tiingo_api_key" env:"TIINGO_API_KEY"`
+	TwelveDataBaseURL    string  `key:"twelve_data_base_url" env:"TWELVE_DATA_BASE_URL"`
+	TwelveDataAPIKey     string  `key:"twelve_data_api_key" env:"TWELVE_DATA_API_KEY"`
+	FMPBaseURL           string  `key:"fmp_base_url" env:"FMP_BASE_URL"`
+	FMPAPIKey            string  `key:"fmp_api_key" env:"FMP_API_KEY"`
+	EODHDBaseURL         string  `key:"eodhd_base_url" env:"EODHD_BASE_URL"`
+	EODHDAPIKey          string  `key:"eodhd_api_key" env:"EODHD_API_KEY"`
+	AlpacaBaseURL        string  `key:"alpaca_base_url" env:"ALPACA_BASE_URL"`
+	AlpacaKeyID          string  `key:"alpaca_api_key_id" env:"ALPACA_API_KEY_ID"`
+	AlpacaSecretKey      string  `key:"alpaca_api_secret_key" env:"ALPACA_API_SECRET_KEY"`
+	StooqBaseURL         string  `key:"stooq_base_url" env:"STOOQ_BASE_URL"`
+	SourceAllowlist      string  `key:"source_allowlist" env:"SOURCE_ALLOWLIST"`
+	VerifyPrices         bool    `key:"verify_prices" env:"VERIFY_PRICES"`
+	PriceCheckTolerance  float64 `key:"price_check_tolerance" env:"PRICE_CHECK_TOLERANCE"`
+	FREDBaseURL          string  `key:"fred_base_url" env:"FRED_BASE_URL"`
+	FREDAPIKey           string  `key:"fred_api_key" env:"FRED_API_KEY"`
+	CashRateSeries       string  `key:"cash_rate_series" env:"CASH_RATE_SERIES"`
+	RiskFreeRateSeries   string  `key:"risk_free_rate_series" env:"RISK_FREE_RATE_SERIES"`
+	StablecoinDepeg      bool    `key:"stablecoin_depeg" env:"STABLECOIN_DEPEG"`
+	CORSAllowedOrigins   string  `key:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   string  `key:"cors_allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   string  `key:"cors_allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	CORSMaxAge           int     `key:"cors_max_age" env:"CORS_MAX_AGE"`
+	CacheMaxAge          int     `key:"cache_max_age" env:"CACHE_MAX_AGE"`
+	PriceCacheSize       int     `key:"price_cache_size" env:"PRICE_CACHE_SIZE"`
+	ProviderDailyLimits  string  `key:"provider_daily_limits" env:"PROVIDER_DAILY_LIMITS"`
+	AlphaVantageRate     int     `key:"alpha_vantage_rate_limit" env:"ALPHA_VANTAGE_RATE_LIMIT"`
+	BreakerThreshold     int     `key:"breaker_threshold" env:"BREAKER_THRESHOLD"`
+	BreakerCooldown      int     `key:"breaker_cooldown" env:"BREAKER_COOLDOWN"`
+	WarmTickers          string  `key:"warm_tickers" env:"WARM_TICKERS"`
+	WarmInterval         int     `key:"warm_interval" env:"WARM_INTERVAL"`
+	RefreshInterval      int     `key:"refresh_interval" env:"REFRESH_INTERVAL"`
+	JobWorkers           int     `key:"job_workers" env:"JOB_WORKERS"`
+	JobQueueSize         int     `key:"job_queue_size" env:"JOB_QUEUE_SIZE"`
+	JobTTL               int     `key:"job_ttl" env:"JOB_TTL"`
+	WebhookSecret        string  `key:"webhook_secret" env:"WEBHOOK_SECRET"`
+	WebhookAllowPrivate  bool    `key:"webhook_allow_private" env:"WEBHOOK_ALLOW_PRIVATE"`
+	AlertsPath           string  `key:"alerts_path" env:"ALERTS_PATH"`
+	AlertCheckInterval   int     `key:"alert_check_interval" env:"ALERT_CHECK_INTERVAL"`
+	APIKeys              string  `key:"api_keys" env:"API_KEYS"`
+	WatchlistsPath       string  `key:"watchlists_path" env:"WATCHLISTS_PATH"`
+	SMTPAddr             string  `key:"smtp_addr" env:"SMTP_ADDR"`
+	SMTPUsername         string  `key:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword         string  `key:"smtp_password" env:"SMTP_PASSWORD"`
+	SMTPFrom             string  `key:"smtp_from" env:"SMTP_FROM"`
+	MaxURLBytes          int64   `key:"max_url_bytes" env:"MAX_URL_BYTES"`
+	MaxBodyBytes         int64   `key:"max_body_bytes" env:"MAX_BODY_BYTES"`
+	Port                 int     `key:"port" env:"PORT"`
+	ListenAddresses      string  `key:"listen_addresses" env:"LISTEN_ADDRESSES"`
+	AdminAddress         string  `key:"admin_address" env:"ADMIN_ADDRESS"`
+	AdminToken           string  `key:"admin_token" env:"ADMIN_TOKEN"`
+	TLSCertFile          string  `key:"tls_cert_file" env:"TLS_CERT_FILE"`
+	TLSKeyFile           string  `key:"tls_key_file" env:"TLS_KEY_FILE"`
+	TLSAutocertDomains   string  `key:"tls_autocert_domains" env:"TLS_AUTOCERT_DOMAINS"`
+	TLSAutocertCacheDir  string  `key:"tls_autocert_cache_dir" env:"TLS_AUTOCERT_CACHE_DIR"`
+	TLSAutocertEmail     string  `key:"tls_autocert_email" env:"TLS_AUTOCERT_EMAIL"`
+	HTTPRedirectPort     int     `key:"http_redirect_port" env:"HTTP_REDIRECT_PORT"`
+	GinMode              string  `key:"gin_mode" env:"GIN_MODE"`
+	SecretsSource        string  `key:"secrets_source" env:"SECRETS_SOURCE"`
+	SecretsRef           string  `key:"secrets_ref" env:"SECRETS_REF"`
+	SecretsRefresh       int     `key:"secrets_refresh" env:"SECRETS_REFRESH"`
+	VaultAddr            string  `key:"vault_addr" env:"VAULT_ADDR"`
+	VaultToken           string  `key:"vault_token" env:"VAULT_TOKEN"`
+	AWSRegion            string  `key:"aws_region" env:"AWS_REGION"`
+	OutboundProxy        string  `key:"outbound_proxy" env:"OUTBOUND_PROXY"`
+	CABundlePath         string  `key:"ca_bundle_path" env:"CA_BUNDLE_PATH"`
+}
+
+// The settings in use, as last applied. Each applied Config is a snapshot that's
+// never changed afterwards: a reload publishes a new one, so readers don't lock
+// and never see half-applied settings.
+var currentConfig atomic.Pointer[Config]
+
+// The settings in use. A function reading several of them loads them once, so
+// they all come from the same snapshot.
+func settings() *Config {
+	return currentConfig.Load()
+}
+
+// The default settings. There is no default Alpha Vantage key: set your own, or "demo".
+func defaultConfig() Config {
+	return Config{
+		AlphaVantageBaseURL:  "https://www.alphavantage.co",
+		FrankfurterBaseURL:   "https://api.frankfurter.app",
+		FXFallbackBaseURL:    "https://api.exchangerate.host",
+		CoinGeckoBaseURL:     "https://api.coingecko.com/api/v3",
+		CoinbaseBaseURL:      "https://api.exchange.coinbase.com",
+		BinanceBaseURL:       "https://api.binance.com",
+		CryptoCompareBaseURL: "https://min-api.cryptocompare.com",
+		CoinCapBaseURL:       "https://rest.coincap.io/v3",
+		CryptoSnapshotTime:   "00:00",
+		StockProviders:       "alphaVantage,polygon,tiingo,twelveData,fmp,eodhd,alpaca,stooq",
+		PolygonBaseURL:       "https://api.polygon.io",
+		TiingoBaseURL:        "https://api.tiingo.com",
+		TwelveDataBaseURL:    "https://api.twelvedata.com",
+		FMPBaseURL:           "https://financialmodelingprep.com",
+		EODHDBaseURL:         "https://eodhd.com/api",
+		AlpacaBaseURL:        "https://data.alpaca.markets",
+		StooqBaseURL:         "https://stooq.com",
+		PriceCheckTolerance:  1,
+		FREDBaseURL:          "https://api.stlouisfed.org",
+		CashRateSeries:       "FEDFUNDS",
+		RiskFreeRateSeries:   "DTB3",
+		CORSAllowedMethods:   "GET, HEAD, POST, PUT, DELETE, OPTIONS",
+		CORSAllowedHeaders:   "Accept-Language, If-None-Match, Content-Type, X-API-Key",
+		CORSMaxAge:           600,
+		CacheMaxAge:          300,
+		PriceCacheSize:       10000,
+		ProviderDailyLimits:  "alphaVantage=25",
+		AlphaVantageRate:     5,
+		BreakerThreshold:     5,
+		BreakerCooldown:      60,
+		WarmInterval:         86400,
+		RefreshInterval:      300,
+		JobWorkers:           4,
+		JobQueueSize:         100,
+		JobTTL:               3600,
+		AlertsPath:           "alerts.json",
+		AlertCheckInterval:   900,
+		WatchlistsPath:       "watchlists.json",
+		MaxURLBytes:          2048,
+		MaxBodyBytes:         1 << 20,
+		Port:                 8080,
+		TLSAutocertCacheDir:  "autocert-cache",
+		GinMode:              "debug",
+		SecretsRefresh:       3600,
+	}
+}
+
+// Helper function to parse a setting into a Config field
+func setConfigField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", value)
+		}
+		field.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(parsed)
+	}
+	return nil
+}
+
+// Load settings: the defaults, then the YAML (.yaml, .yml) or TOML (.toml) file at
+// path if given, then environment variables. Unknown keys in the file are errors.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	value, fields := reflect.ValueOf(&cfg).Elem(), reflect.TypeOf(cfg)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		settings := map[string]interface{}{}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.Unmarshal(data, &settings)
+		case ".toml":
+			err = toml.Unmarshal(data, &settings)
+		default:
+			return cfg, fmt.Errorf("Config file %s must be .yaml, .yml or .toml", path)
+		}
+		if err != nil {
+			return cfg, fmt.Errorf("Config file %s: %v", path, err)
+		}
+
+		fieldByKey := map[string]int{}
+		for i := 0; i < fields.NumField(); i++ {
+			fieldByKey[fields.Field(i).Tag.Get("key")] = i
+		}
+		for key, setting := range settings {
+			i, ok := fieldByKey[key]
+			if !ok {
+				return cfg, fmt.Errorf("Config file %s: unknown setting %q", path, key)
+			}
+			if _, nested := setting.(map[string]interface{}); nested {
+				return cfg, fmt.Errorf("Config file %s: %s must be a single value", path, key)
+			}
+			if err := setConfigField(value.Field(i), fmt.Sprint(setting)); err != nil {
+				return cfg, fmt.Errorf("Config file %s: %s: %v", path, key, err)
+			}
+		}
+	}
+
+	for i := 0; i < fields.NumField(); i++ {
+		env := fields.Field(i).Tag.Get("env")
+		if setting := os.Getenv(env); setting != "" {
+			if err := setConfigField(value.Field(i), setting); err != nil {
+				return cfg, fmt.Errorf("%s: %v", env, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Check settings the server can't run without or can't use, reporting every problem
+func (cfg Config) validate() error {
+	var problems []error
+
+	if cfg.AlphaVantageAPIKey == "" {
+		problems = append(problems, fmt.Errorf("alpha_vantage_api_key (ALPHA_VANTAGE_API_KEY) is required: get a free key at https://www.alphavantage.co/support/#api-key, or use \"demo\""))
+	}
+	for name, baseURL := range map[string]string{
+		"alpha_vantage_base_url": cfg.AlphaVantageBaseURL,
+		"frankfurter_base_url":   cfg.FrankfurterBaseURL,
+		"fx_fallback_base_url":   cfg.FXFallbackBaseURL,
+		"coingecko_base_url":     cfg.CoinGeckoBaseURL,
+		"coincap_base_url":       cfg.CoinCapBaseURL,
+		"polygon_base_url":       cfg.PolygonBaseURL,
+		"tiingo_base_url":        cfg.TiingoBaseURL,
+		"twelve_data_base_url":   cfg.TwelveDataBaseURL,
+		"fmp_base_url":           cfg.FMPBaseURL,
+		"eodhd_base_url":         cfg.EODHDBaseURL,
+		"alpaca_base_url":        cfg.AlpacaBaseURL,
+		"stooq_base_url":         cfg.StooqBaseURL,
+		"fred_base_url":          cfg.FREDBaseURL,
+	} {
+		if parsed, err := url.Parse(baseURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems = append(problems, fmt.Errorf("%s %q is not an http(s) URL", name, baseURL))
+		}
+	}
+	// A blank Coinbase, Binance or CryptoCompare URL turns the provider off
+	for name, baseURL := range map[string]string{
+		"coinbase_base_url":      cfg.CoinbaseBaseURL,
+		"binance_base_url":       cfg.BinanceBaseURL,
+		"cryptocompare_base_url": cfg.CryptoCompareBaseURL,
+	} {
+		if parsed, err := url.Parse(baseURL); baseURL != "" && (err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "") {
+			problems = append(problems, fmt.Errorf("%s %q is not an http(s) URL", name, baseURL))
+		}
+	}
+	if err := checkStockProviders(cfg.StockProviders); err != nil {
+		problems = append(problems, err)
+	}
+	if err := checkSourceAllowlist(cfg.SourceAllowlist); err != nil {
+		problems = append(problems, err)
+	}
+	if cfg.PriceCheckTolerance < 0 {
+		problems = append(problems, fmt.Errorf("price_check_tolerance %g must not be negative", cfg.PriceCheckTolerance))
+	}
+	if (cfg.AlpacaKeyID == "") != (cfg.AlpacaSecretKey == "") {
+		problems = append(problems, fmt.Errorf("alpaca_api_key_id and alpaca_api_secret_key must be set together"))
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		problems = append(problems, fmt.Errorf("port %d is not between 1 and 65535", cfg.Port))
+	}
+	for _, address := range splitList(cfg.ListenAddresses) {
+		if err := checkListenAddress(address); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if cfg.AdminAddress != "" {
+		if err := checkListenAddress(cfg.AdminAddress); err != nil {
+			problems = append(problems, fmt.Errorf("admin_address: %v", err))
+		} else if cfg.AdminToken == "" && !isLocalListenAddress(cfg.AdminAddress) {
+			problems = append(problems, fmt.Errorf("admin_token must be set unless admin_address is a loopback address or unix:/path"))
+		}
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		problems = append(problems, fmt.Errorf("tls_cert_file and tls_key_file must be set together"))
+	}
+	if cfg.TLSCertFile != "" && cfg.TLSAutocertDomains != "" {
+		problems = append(problems, fmt.Errorf("tls_cert_file and tls_autocert_domains can't both be set"))
+	}
+	if cfg.HTTPRedirectPort != 0 && (cfg.HTTPRedirectPort < 1 || cfg.HTTPRedirectPort > 65535 || cfg.HTTPRedirectPort == cfg.Port) {
+		problems = append(problems, fmt.Errorf("http_redirect_port %d is not between 1 and 65535, or is the same as port", cfg.HTTPRedirectPort))
+	}
+	if cfg.HTTPRedirectPort != 0 && !cfg.servesTLS() {
+		problems = append(problems, fmt.Errorf("http_redirect_port needs tls_cert_file or tls_autocert_domains"))
+	}
+	if cfg.GinMode != "debug" && cfg.GinMode != "release" && cfg.GinMode != "test" {
+		problems = append(problems, fmt.Errorf("gin_mode %q must be debug, release or test", cfg.GinMode))
+	}
+	if cfg.MaxURLBytes < 1 || cfg.MaxBodyBytes < 1 {
+		problems = append(problems, fmt.Errorf("max_url_bytes and max_body_bytes must be positive"))
+	}
+	if cfg.JobWorkers < 1 || cfg.JobQueueSize < 1 || cfg.JobTTL < 1 {
+		problems = append(problems, fmt.Errorf("job_workers, job_queue_size and job_ttl must be positive"))
+	}
+	if cfg.AlertCheckInterval < 1 {
+		problems = append(problems, fmt.Errorf("alert_check_interval must be positive"))
+	}
+	if cfg.SMTPAddr != "" && cfg.SMTPFrom == "" {
+		problems = append(problems, fmt.Errorf("smtp_from is required with smtp_addr"))
+	}
+	if cfg.CORSMaxAge < 0 || cfg.CacheMaxAge < 0 || cfg.PriceCacheSize < 0 || cfg.WarmInterval < 0 || cfg.RefreshInterval < 0 || cfg.BreakerThreshold < 0 || cfg.AlphaVantageRate < 0 || cfg.SecretsRefresh < 0 {
+		problems = append(problems, fmt.Errorf("cors_max_age, cache_max_age, price_cache_size, warm_interval, refresh_interval, breaker_threshold, alpha_vantage_rate_limit and secrets_refresh must not be negative"))
+	}
+	if cfg.BreakerCooldown < 1 {
+		problems = append(problems, fmt.Errorf("breaker_cooldown must be positive"))
+	}
+	if _, err := parseProviderLimits(cfg.ProviderDailyLimits); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := parseWarmTickers(cfg.WarmTickers); err != nil {
+		problems = append(problems, err)
+	}
+	if _, err := parseSnapshotTime(cfg.CryptoSnapshotTime); err != nil {
+		problems = append(problems, err)
+	}
+	if cfg.SecretsSource != "" {
+		if cfg.SecretsSource != "vault" && cfg.SecretsSource != "gcp" && cfg.SecretsSource != "aws" {
+			problems = append(problems, fmt.Errorf("secrets_source %q must be vault, gcp or aws", cfg.SecretsSource))
+		}
+		if cfg.SecretsRef == "" {
+			problems = append(problems, fmt.Errorf("secrets_ref is required with secrets_source"))
+		}
+		if cfg.SecretsSource == "vault" && (cfg.VaultAddr == "" || cfg.VaultToken == "") {
+			problems = append(problems, fmt.Errorf("vault_addr and vault_token are required with secrets_source vault"))
+		}
+		if cfg.SecretsSource == "aws" && cfg.AWSRegion == "" {
+			problems = append(problems, fmt.Errorf("aws_region is required with secrets_source aws"))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// Make settings the ones the server uses. Those read only at startup are kept in
+// their own variables, set once.
+func applyConfig(cfg Config) {
+	publishConfig(cfg)
+	fxDatasetPath = cfg.FXDatasetPath
+	corporateActionsPath = cfg.CorporateActionsPath
+	equivalentsPath = cfg.EquivalentsPath
+	exchangeHolidaysPath = cfg.ExchangeHolidaysPath
+	corsAllowedOrigins = cfg.CORSAllowedOrigins
+	corsAllowedMethods = cfg.CORSAllowedMethods
+	corsAllowedHeaders = cfg.CORSAllowedHeaders
+	corsMaxAge = strconv.Itoa(cfg.CORSMaxAge)
+	maxURLBytes = cfg.MaxURLBytes
+	maxBodyBytes = cfg.MaxBodyBytes
+	serverPort = strconv.Itoa(cfg.Port)
+	ginMode = cfg.GinMode
+}
+
+// Swap in new settings for the requests that start from now on; those in flight
+// finish with the snapshot they have
+func publishConfig(cfg Config) {
+	old := currentConfig.Swap(&cfg)
+	// Crypto prices cached under another snapshot are for other windows
+	if old != nil && old.CryptoSnapshotTime != cfg.CryptoSnapshotTime {
+		prices.invalidate(priceFilter{AssetType: "crypto"})
+	}
+	setAlphaVantageRateLimit(cfg.AlphaVantageRate)
+}
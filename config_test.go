@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to write a config file into a test's temp dir
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// Helper function to change settings for a test, restoring them when it ends
+func setSettings(t *testing.T, change func(cfg *Config)) {
+	old := settings()
+	updated := *old
+	change(&updated)
+	currentConfig.Store(&updated)
+	t.Cleanup(func() { currentConfig.Store(old) })
+}
+
+// Test defaults, config files and environment overrides
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("ALPHA_VANTAGE_API_KEY", "")
+	t.Setenv("PORT", "")
+
+	cfg, err := loadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultConfig(), cfg)
+	assert.Error(t, cfg.validate(), "no Alpha Vantage key")
+
+	path := writeConfigFile(t, "config.yaml", "alpha_vantage_api_key: yamlkey\nport: 9000\nstablecoin_depeg: true\n")
+	cfg, err = loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "yamlkey", cfg.AlphaVantageAPIKey)
+	assert.Equal(t, 9000, cfg.Port)
+	assert.True(t, cfg.StablecoinDepeg)
+	assert.Equal(t, "FEDFUNDS", cfg.CashRateSeries)
+	assert.NoError(t, cfg.validate())
+
+	path = writeConfigFile(t, "config.toml", "alpha_vantage_api_key = \"tomlkey\"\nmax_body_bytes = 4096\n")
+	cfg, err = loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "tomlkey", cfg.AlphaVantageAPIKey)
+	assert.Equal(t, int64(4096), cfg.MaxBodyBytes)
+
+	// The environment wins over the file
+	t.Setenv("ALPHA_VANTAGE_API_KEY", "envkey")
+	t.Setenv("PORT", "7000")
+	cfg, err = loadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "envkey", cfg.AlphaVantageAPIKey)
+	assert.Equal(t, 7000, cfg.Port)
+}
+
+// Test bad config files and settings are rejected
+func TestInvalidConfig(t *testing.T) {
+	t.Setenv("PORT", "")
+
+	_, err := loadConfig(writeConfigFile(t, "config.yaml", "alpha_vantage_key: typo\n"))
+	assert.ErrorContains(t, err, `unknown setting "alpha_vantage_key"`)
+
+	_, err = loadConfig(writeConfigFile(t, "config.yaml", "port: eighty\n"))
+	assert.ErrorContains(t, err, "port")
+
+	_, err = loadConfig(writeConfigFile(t, "config.json", "{}"))
+	assert.ErrorContains(t, err, ".yaml, .yml or .toml")
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+
+	t.Setenv("PORT", "http")
+	_, err = loadConfig("")
+	assert.ErrorContains(t, err, "PORT")
+
+	cfg := defaultConfig()
+	cfg.AlphaVantageAPIKey = "demo"
+	cfg.Port = 70000
+	cfg.GinMode = "loud"
+	cfg.FREDBaseURL = "api.stlouisfed.org"
+	cfg.CryptoSnapshotTime = "25:00"
+	cfg.StockProviders = "alphaVantage,yahoo"
+	cfg.AlpacaKeyID = "key-id"
+	cfg.SourceAllowlist = "tiingo,yahoo"
+	err = cfg.validate()
+	assert.ErrorContains(t, err, "port 70000")
+	assert.ErrorContains(t, err, "crypto_snapshot_time")
+	assert.ErrorContains(t, err, `stock_providers: unknown provider "yahoo"`)
+	assert.ErrorContains(t, err, "alpaca_api_key_id and alpaca_api_secret_key must be set together")
+	assert.ErrorContains(t, err, `source_allowlist: unknown provider "yahoo"`)
+	assert.ErrorContains(t, err, "gin_mode")
+	assert.ErrorContains(t, err, "fred_base_url")
+}
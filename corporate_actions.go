@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A spin-off or merger affecting holders of Ticker before Date
+type corporateAction struct {
+	Date      string
+	Ticker    string
+	Action    string  // "spinoff" or "merger"
+	NewTicker string  // shares received, if any
+	Ratio     float64 // NewTicker shares per share held
+	Cash      float64 // cash (USD) per share held
+}
+
+// Bundled corporate actions dataset
+//
+//go:embed data/corporate_actions.csv
+var bundledCorporateActions []byte
+
+// Corporate actions sorted by date, loaded on first use
+var (
+	corporateActionsOnce sync.Once
+	corporateActions     []corporateAction
+	corporateActionsErr  error
+)
+
+// Load the bundled corporate actions plus any rows from CORPORATE_ACTIONS_PATH
+func loadCorporateActions() ([]corporateAction, error) {
+	corporateActionsOnce.Do(func() {
+		actions, err := readCorporateActions(bytes.NewReader(bundledCorporateActions))
+		if err != nil {
+			corporateActionsErr = fmt.Errorf("Bundled corporate actions: %v", err)
+			return
+		}
+
+		if corporateActionsPath != "" {
+			file, err := os.Open(corporateActionsPath)
+			if err != nil {
+				corporateActionsErr = err
+				return
+			}
+			defer file.Close()
+			extra, err := readCorporateActions(file)
+			if err != nil {
+				corporateActionsErr = fmt.Errorf("Corporate actions %s: %v", corporateActionsPath, err)
+				return
+			}
+			actions = append(actions, extra...)
+		}
+
+		sort.SliceStable(actions, func(i, j int) bool { return actions[i].Date < actions[j].Date })
+		corporateActions = actions
+	})
+	return corporateActions, corporateActionsErr
+}
+
+// Parse date,ticker,action,new_ticker,ratio,cash rows
+func readCorporateActions(r io.Reader) ([]corporateAction, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+
+	var actions []corporateAction
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return actions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == "date" {
+			continue
+		}
+
+		if record[2] != "spinoff" && record[2] != "merger" {
+			return nil, fmt.Errorf("Unknown corporate action %q", record[2])
+		}
+		ratio, err := strconv.ParseFloat(record[4], 64)
+		if err != nil {
+			return nil, err
+		}
+		cash, err := strconv.ParseFloat(record[5], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		actions = append(actions, corporateAction{
+			Date:      record[0],
+			Ticker:    strings.ToUpper(record[1]),
+			Action:    record[2],
+			NewTicker: strings.ToUpper(record[3]),
+			Ratio:     ratio,
+			Cash:      cash,
+		})
+	}
+}
+
+// Helper function to list the corporate actions dated after buyDate and on or before sellDate
+func corporateActionsBetween(buyDate, sellDate string) ([]corporateAction, error) {
+	actions, err := loadCorporateActions()
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := dateOnly(buyDate), dateOnly(sellDate)
+	var between []corporateAction
+	for _, action := range actions {
+		if action.Date > start && action.Date <= end {
+			between = append(between, action)
+		}
+	}
+	return between, nil
+}
+
+// Check whether a ticker was merged away during the holding period, in which case
+// it has no price on the sell date
+func mergedAway(ticker, assetType, buyDate, sellDate string) (bool, error) {
+	if assetType != "stock" {
+		return false, nil
+	}
+
+	actions, err := corporateActionsBetween(buyDate, sellDate)
+	if err != nil {
+		return false, err
+	}
+	for _, action := range actions {
+		if action.Ticker == strings.ToUpper(ticker) && action.Action == "merger" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Helper function to price a ticker on the sell date, or 0 if it was merged away
+// before then (its value is carried by the corporate actions block instead)
+func fetchSellPrice(ticker, buyDate, sellDate, assetType string, opts priceOptions) (float64, error) {
+	merged, err := mergedAway(ticker, assetType, buyDate, sellDate)
+	if err != nil {
+		return 0, err
+	}
+	if merged {
+		return 0, nil
+	}
+	return fetchPrice(ticker, sellDate, assetType, opts)
+}
+
+// One corporate action applied to a holding
+type corporateActionEvent struct {
+	Date           string  `json:"date"`
+	Action         string  `json:"action"`
+	Ticker         string  `json:"ticker"`
+	NewTicker      string  `json:"newTicker,omitempty"`
+	SharesHeld     float64 `json:"sharesHeld"`
+	SharesReceived float64 `json:"sharesReceived,omitempty"`
+	CashReceived   float64 `json:"cashReceived,omitempty"`
+}
+
+// A position received through corporate actions, valued on the sell date
+type receivedPosition struct {
+	Ticker string  `json:"ticker"`
+	Shares float64 `json:"shares"`
+	Price  float64 `json:"price"`
+	Value  float64 `json:"value"`
+}
+
+// Positions and cash received from spin-offs and mergers over a holding period.
+// Value (USD) is added to the final value; MergedAway means the original holding
+// itself was exchanged.
+type corporateActionsResult struct {
+	Events        []corporateActionEvent `json:"events"`
+	Positions     []receivedPosition     `json:"positions"`
+	Cash          float64                `json:"cash"`
+	Value         float64                `json:"value"`
+	MergedAway    bool                   `json:"mergedAway"`
+	ValuePerShare float64                `json:"-"`
+}
+
+// Walk the corporate actions affecting a holding, where sharesOn gives the
+// original-ticker shares held just before a date. Returns the events, the shares
+// received by ticker, the cash received and whether the holding was merged away.
+func walkCorporateActions(ticker string, actions []corporateAction, sharesOn func(date string) float64) ([]corporateActionEvent, map[string]float64, float64, bool) {
+	ticker = strings.ToUpper(ticker)
+	events := []corporateActionEvent{}
+	positions := map[string]float64{}
+	cash := 0.0
+	merged := false
+
+	for _, action := range actions {
+		var held float64
+		switch {
+		case action.Ticker == ticker && !merged:
+			held = sharesOn(action.Date)
+		case positions[action.Ticker] > 0:
+			held = positions[action.Ticker]
+		default:
+			continue
+		}
+
+		event := corporateActionEvent{
+			Date:         action.Date,
+			Action:       action.Action,
+			Ticker:       action.Ticker,
+			NewTicker:    action.NewTicker,
+			SharesHeld:   held,
+			CashReceived: held * action.Cash,
+		}
+		if action.Action == "merger" {
+			if action.Ticker == ticker {
+				merged = true
+			} else {
+				delete(positions, action.Ticker)
+			}
+		}
+		if action.NewTicker != "" {
+			event.SharesReceived = held * action.Ratio
+			positions[action.NewTicker] += event.SharesReceived
+		}
+		cash += event.CashReceived
+		events = append(events, event)
+	}
+
+	return events, positions, cash, merged
+}
+
+// Apply spin-offs and mergers over the holding period, valuing received positions
+// on the sell date. Only stocks have corporate actions.
+func applyCorporateActions(ticker, assetType, buyDate, sellDate string, sharesOn func(date string) float64, opts priceOptions) (*corporateActionsResult, error) {
+	result := &corporateActionsResult{Events: []corporateActionEvent{}, Positions: []receivedPosition{}}
+	if assetType != "stock" {
+		return result, nil
+	}
+
+	actions, err := corporateActionsBetween(buyDate, sellDate)
+	if err != nil {
+		return nil, err
+	}
+
+	events, positions, cash, merged := walkCorporateActions(ticker, actions, sharesOn)
+	_, perSharePositions, perShareCash, _ := walkCorporateActions(ticker, actions, func(string) float64 { return 1 })
+
+	result.Events, result.Cash, result.MergedAway = events, cash, merged
+	result.Value, result.ValuePerShare = cash, perShareCash
+
+	received := make([]string, 0, len(positions))
+	for newTicker := range positions {
+		received = append(received, newTicker)
+	}
+	sort.Strings(received)
+
+	for _, newTicker := range received {
+		price, err := fetchPrice(newTicker, sellDate, "stock", opts)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to price %s received through corporate actions: %v", newTicker, err)
+		}
+		shares := positions[newTicker]
+		result.Positions = append(result.Positions, receivedPosition{
+			Ticker: newTicker,
+			Shares: shares,
+			Price:  price,
+			Value:  shares * price,
+		})
+		result.Value += shares * price
+		result.ValuePerShare += perSharePositions[newTicker] * price
+	}
+
+	return result, nil
+}
+
+// Helper function for holdings whose share count doesn't change
+func constantShares(shares float64) func(date string) float64 {
+	return func(string) float64 { return shares }
+}
+
+// Helper function giving the shares held before a date in a DRIP backtest,
+// counting reinvestments paid before it
+func dripSharesOn(initialShares float64, events []dripEvent) func(date string) float64 {
+	return func(date string) float64 {
+		shares := initialShares
+		for _, event := range events {
+			if event.reinvestedOn() < date {
+				shares += event.SharesBought
+			}
+		}
+		return shares
+	}
+}
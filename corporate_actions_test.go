@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test following spin-offs and mergers through a holding period
+func TestWalkCorporateActions(t *testing.T) {
+	actions := []corporateAction{
+		{Date: "2018-06-15", Ticker: "TWX", Action: "merger", NewTicker: "T", Ratio: 1.437, Cash: 53.75},
+		{Date: "2022-04-11", Ticker: "T", Action: "spinoff", NewTicker: "WBD", Ratio: 0.241917},
+		{Date: "2023-01-04", Ticker: "GE", Action: "spinoff", NewTicker: "GEHC", Ratio: 0.333333},
+	}
+
+	events, positions, cash, merged := walkCorporateActions("twx", actions, constantShares(100))
+	assert.True(t, merged)
+	assert.Len(t, events, 2)
+	assert.InDelta(t, 5375.0, cash, 1e-9)
+	assert.InDelta(t, 143.7, positions["T"], 1e-9)
+	assert.InDelta(t, 143.7*0.241917, positions["WBD"], 1e-9)
+	assert.NotContains(t, positions, "GEHC")
+}
+
+// Test spin-offs and cash mergers loaded from CORPORATE_ACTIONS_PATH
+func TestCorporateActionsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+
+	path := filepath.Join(t.TempDir(), "actions.csv")
+	err := os.WriteFile(path, []byte("date,ticker,action,new_ticker,ratio,cash\n2025-05-01,AAPL,spinoff,NEWCO,0.5,0\n2025-06-02,MSFT,merger,,0,250\n"), 0o644)
+	assert.NoError(t, err)
+
+	originalPath := corporateActionsPath
+	corporateActionsPath = path
+	corporateActionsOnce = sync.Once{}
+	t.Cleanup(func() {
+		corporateActionsPath = originalPath
+		corporateActionsOnce = sync.Once{}
+	})
+
+	router := setupTestRouterWithMocks()
+
+	// Spin-off shares are valued on the sell date (the mock prices every ticker alike)
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.InDelta(t, 15*211.18, response["finalValue"], 1e-9)
+	actions := response["corporateActions"].(map[string]interface{})
+	assert.Len(t, actions["positions"], 1)
+	assert.Equal(t, false, actions["mergedAway"])
+
+	// A cash merger replaces the holding, so there is no sell price
+	w = makeTestRequest(router, "GET", "/10/MSFT/on/2025-03-31/and-sold-on/2025-07-18?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.0, response["sellPrice"])
+	assert.InDelta(t, 2500.0, response["finalValue"], 1e-9)
+	actions = response["corporateActions"].(map[string]interface{})
+	assert.Equal(t, true, actions["mergedAway"])
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test origin patterns
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"http://localhost:3000", "https://*.example.com"}
+	assert.True(t, originAllowed("http://localhost:3000", allowed))
+	assert.True(t, originAllowed("https://app.example.com", allowed))
+	assert.False(t, originAllowed("https://example.com", allowed))
+	assert.False(t, originAllowed("https://evil.com", allowed))
+	assert.False(t, originAllowed("http://localhost:3000", nil))
+	assert.True(t, originAllowed("https://anything.test", []string{"*"}))
+
+	assert.Equal(t, []string{"GET", "HEAD"}, splitList(" GET, ,HEAD "))
+}
+
+// Test CORS headers on requests and preflights
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withCORS([]string{"https://app.example.com"}, []string{"GET", "OPTIONS"}, []string{"Accept-Language"}, "600"))
+	router.GET("/ping", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+
+	request := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/ping", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "https://app.example.com", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+
+	w = request("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "GET"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Accept-Language", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	// Other origins get no CORS headers
+	w = request("GET", "https://evil.com", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = request("GET", "", nil)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
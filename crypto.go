@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CoinGecko coin IDs for the crypto symbols we accept as assets and currencies
+var cryptoCoinIDs = map[string]string{
+	"BTC":   "bitcoin",
+	"ETH":   "ethereum",
+	"SOL":   "solana",
+	"BNB":   "binancecoin",
+	"XRP":   "ripple",
+	"ADA":   "cardano",
+	"DOGE":  "dogecoin",
+	"LTC":   "litecoin",
+	"BCH":   "bitcoin-cash",
+	"DOT":   "polkadot",
+	"AVAX":  "avalanche-2",
+	"LINK":  "chainlink",
+	"MATIC": "matic-network",
+	"TRX":   "tron",
+	"XLM":   "stellar",
+	"USDT":  "tether",
+	"USDC":  "usd-coin",
+	"DAI":   "dai",
+	"EURC":  "euro-coin",
+}
+
+// Helper function to check if a currency code is a crypto currency
+func isCryptoCurrency(code string) bool {
+	_, ok := cryptoCoinIDs[code]
+	return ok
+}
+
+// CoinGecko market chart response struct
+// Example: https://api.coingecko.com/api/v3/coins/bitcoin/market_chart/range?vs_currency=usd&from=1577836800&to=1577923200
+type coinGeckoMarketChartResponse struct {
+	Prices [][2]float64 `json:"prices"`
+}
+
+// Fetch historical crypto prices from CoinGecko
+// Returns [unix millis, USD price] pairs between the two timestamps
+func fetchCryptoHistory(coinID string, fromUnix, toUnix int64) ([][2]float64, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/coins/%s/market_chart/range?vs_currency=usd&from=%d&to=%d", cfg.CoinGeckoBaseURL, coinID, fromUnix, toUnix)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CoinGeckoAPIKey != "" {
+		req.Header.Set("x-cg-demo-api-key", cfg.CoinGeckoAPIKey)
+	}
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CoinGecko returned status %d", resp.StatusCode)
+	}
+
+	var result coinGeckoMarketChartResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	return result.Prices, nil
+}
+
+// CoinGecko search response struct, coins ordered by market cap rank
+type coinGeckoSearchResponse struct {
+	Coins []struct {
+		ID     string `json:"id"`
+		Symbol string `json:"symbol"`
+	} `json:"coins"`
+}
+
+// Coin IDs found via CoinGecko search for symbols outside cryptoCoinIDs
+var (
+	searchedCoinIDsMu sync.Mutex
+	searchedCoinIDs   = map[string]string{}
+)
+
+// Look up the CoinGecko coin ID for a symbol, searching CoinGecko for symbols
+// we don't know and picking the largest coin by market cap
+func lookupCoinID(symbol string) (string, error) {
+	if coinID, ok := cryptoCoinIDs[symbol]; ok {
+		return coinID, nil
+	}
+
+	searchedCoinIDsMu.Lock()
+	defer searchedCoinIDsMu.Unlock()
+	if coinID, ok := searchedCoinIDs[symbol]; ok {
+		return coinID, nil
+	}
+
+	cfg := settings()
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/search?query=%s", cfg.CoinGeckoBaseURL, url.QueryEscape(symbol)), nil)
+	if err != nil {
+		return "", err
+	}
+	if cfg.CoinGeckoAPIKey != "" {
+		req.Header.Set("x-cg-demo-api-key", cfg.CoinGeckoAPIKey)
+	}
+
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result coinGeckoSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	for _, coin := range result.Coins {
+		if strings.EqualFold(coin.Symbol, symbol) {
+			searchedCoinIDs[symbol] = coin.ID
+			return coin.ID, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown currency or crypto symbol %s", symbol)
+}
+
+// Helper function to parse a crypto snapshot time (HH:MM, UTC) into minutes after midnight
+func parseSnapshotTime(value string) (int, error) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("crypto_snapshot_time %q must be a UTC time of day as HH:MM", value)
+	}
+	return at.Hour()*60 + at.Minute(), nil
+}
+
+// Helper function to get the 24 hours a crypto asset's daily prices on a date come
+// from. Crypto trades around the clock, so a date's close is the snapshot at
+// CRYPTO_SNAPSHOT_TIME: at midnight that ends the date itself, and at a later time
+// it falls on the date, with the window starting the day before.
+func cryptoDayWindow(date string) (time.Time, time.Time, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	minutes, _ := parseSnapshotTime(settings().CryptoSnapshotTime)
+	end := day.Add(24 * time.Hour)
+	if minutes > 0 {
+		end = day.Add(time.Duration(minutes) * time.Minute)
+	}
+	return end.Add(-24 * time.Hour), end, nil
+}
+
+// Helper function to get the date of the most recent crypto close at a moment:
+// the last date whose window has ended
+func cryptoLatestClose(now time.Time) string {
+	now = now.UTC()
+	minutes, _ := parseSnapshotTime(settings().CryptoSnapshotTime)
+	if minutes > 0 && now.Hour()*60+now.Minute() >= minutes {
+		return now.Format("2006-01-02")
+	}
+	return now.AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+// How a response's crypto prices were taken
+type cryptoPricing struct {
+	Market            string `json:"market"`
+	SnapshotTime      string `json:"snapshotTime"`
+	Window            string `json:"window"`
+	WeekendAdjustment bool   `json:"weekendAdjustment"`
+}
+
+// Add a "cryptoPricing" block to a crypto response documenting the snapshot
+// convention: every calendar date has its own price, weekends and holidays included
+func addCryptoPricing(response gin.H, assetType string) {
+	if assetType != "crypto" {
+		return
+	}
+	cfg := settings()
+	window := "00:00 to 24:00 UTC on the date"
+	if minutes, _ := parseSnapshotTime(cfg.CryptoSnapshotTime); minutes > 0 {
+		window = fmt.Sprintf("%s UTC the day before to %s UTC on the date", cfg.CryptoSnapshotTime, cfg.CryptoSnapshotTime)
+	}
+	response["cryptoPricing"] = cryptoPricing{
+		Market:            "24/7",
+		SnapshotTime:      cfg.CryptoSnapshotTime + " UTC",
+		Window:            window,
+		WeekendAdjustment: false,
+	}
+}
+
+// Fetch the USD price of a crypto symbol on a date (YYYY-MM-DD), using the first
+// price the crypto providers report for that UTC day
+func fetchCryptoDailyPriceUSD(symbol, date string) (float64, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+
+	quotes, err := fetchCryptoQuotes(symbol, day, day.Add(24*time.Hour), "")
+	if err != nil {
+		return 0, err
+	}
+	return quotes.Points[0][1], nil
+}
+
+// Fetch a crypto asset's open, high, low or close in USD for a date, taken as the
+// first, highest, lowest or last quote of the date's window, from the provider a
+// request chose if any
+func fetchCryptoDailyPriceAtUSD(symbol, date, priceAt, source string) (float64, priceAnswer, error) {
+	start, end, err := cryptoDayWindow(date)
+	if err != nil {
+		return 0, priceAnswer{}, err
+	}
+
+	quotes, err := fetchCryptoQuotes(symbol, start, end, source)
+	if err != nil {
+		return 0, priceAnswer{}, err
+	}
+	return ohlcPoint(quotes.Points, priceAt), priceAnswer{Date: date, Provider: quotes.Provider, Fallback: quotes.Fallback}, nil
+}
+
+// Helper function to pick the open, high, low or close from [timestamp, price] points
+func ohlcPoint(prices [][2]float64, priceAt string) float64 {
+	switch priceAt {
+	case "open":
+		return prices[0][1]
+	case "high", "low":
+		extreme := prices[0][1]
+		for _, point := range prices[1:] {
+			if (priceAt == "high" && point[1] > extreme) || (priceAt == "low" && point[1] < extreme) {
+				extreme = point[1]
+			}
+		}
+		return extreme
+	default:
+		return prices[len(prices)-1][1]
+	}
+}
+
+// Convert between currencies where at least one side is crypto, going through USD
+func getCryptoCrossRate(fromCurrency, toCurrency, date string) (float64, error) {
+	fromUSD, err := usdPerUnit(fromCurrency, date)
+	if err != nil {
+		return 0, err
+	}
+	toUSD, err := usdPerUnit(toCurrency, date)
+	if err != nil {
+		return 0, err
+	}
+	return fromUSD / toUSD, nil
+}
+
+// USD value of one unit of a fiat or crypto currency on a date
+func usdPerUnit(currency, date string) (float64, error) {
+	currency = pegStablecoin(currency)
+	if currency == "USD" {
+		return 1, nil
+	}
+	if isCryptoCurrency(currency) {
+		return fetchCryptoDailyPriceUSD(currency, date)
+	}
+
+	// Codes no FX provider publishes are tried as coin symbols
+	rate, _, err := fetchFiatRate(currency, "USD", date)
+	if errors.Is(err, errUnknownCurrency) {
+		return fetchCryptoDailyPriceUSD(currency, date)
+	}
+	return rate, err
+}
+
+// Smallest commonly quoted unit of a coin and its number of decimals
+type cryptoUnit struct {
+	Name     string
+	Decimals int
+}
+
+var cryptoSmallUnits = map[string]cryptoUnit{
+	"BTC":  {"sats", 8},
+	"BCH":  {"sats", 8},
+	"LTC":  {"litoshis", 8},
+	"DOGE": {"koinu", 8},
+	"ETH":  {"gwei", 9},
+	"SOL":  {"lamports", 9},
+	"ADA":  {"lovelace", 6},
+	"XRP":  {"drops", 6},
+	"TRX":  {"sun", 6},
+	"XLM":  {"stroops", 7},
+	"DOT":  {"planck", 10},
+}
+
+// Default precision for coins without a small unit in the table
+const defaultCryptoDecimals = 8
+
+// A crypto amount as an exact decimal string plus its count of small units
+type cryptoAmount struct {
+	Symbol     string `json:"symbol"`
+	Amount     string `json:"amount"`
+	Unit       string `json:"unit,omitempty"`
+	SmallUnits int64  `json:"smallUnits,omitempty"`
+}
+
+// Express an amount of a coin at its native precision
+func newCryptoAmount(symbol string, amount float64) cryptoAmount {
+	unit, ok := cryptoSmallUnits[symbol]
+	if !ok {
+		return cryptoAmount{
+			Symbol: symbol,
+			Amount: strconv.FormatFloat(amount, 'f', defaultCryptoDecimals, 64),
+		}
+	}
+
+	smallUnits := int64(math.Round(amount * math.Pow10(unit.Decimals)))
+	return cryptoAmount{
+		Symbol:     symbol,
+		Amount:     strconv.FormatFloat(amount, 'f', unit.Decimals, 64),
+		Unit:       unit.Name,
+		SmallUnits: smallUnits,
+	}
+}
+
+// Response fields holding a quantity of the asset or an amount of the purchase currency
+var (
+	assetQuantityFields  = []string{"quantity", "shares", "initialShares", "reinvestedShares", "totalShares"}
+	currencyAmountFields = []string{"value", "finalValueInOriginalCurrency"}
+)
+
+// Add a cryptoUnits block restating crypto-denominated fields of a response
+// at native precision, e.g. shares of BTC in sats or an ETH purchase value in gwei
+func addCryptoUnits(response gin.H, ticker, assetType, currency string) {
+	units := map[string]cryptoAmount{}
+
+	if symbol := strings.ToUpper(ticker); assetType == "crypto" && isCryptoCurrency(symbol) {
+		for _, field := range assetQuantityFields {
+			if value, ok := response[field].(float64); ok {
+				units[field] = newCryptoAmount(symbol, value)
+			}
+		}
+	}
+
+	if isCryptoCurrency(currency) {
+		for _, field := range currencyAmountFields {
+			if value, ok := response[field].(float64); ok {
+				units[field] = newCryptoAmount(currency, value)
+			}
+		}
+	}
+
+	if reportIn, ok := response["reportCurrency"].(string); ok && isCryptoCurrency(reportIn) {
+		if value, ok := response["finalValueInReportCurrency"].(float64); ok {
+			units["finalValueInReportCurrency"] = newCryptoAmount(reportIn, value)
+		}
+	}
+
+	if len(units) > 0 {
+		response["cryptoUnits"] = units
+	}
+}
+
+// Helper function to add a "versus Bitcoin" block, running the same amount (USD)
+// through BTC over the same window
+func addBTCComparison(response gin.H, vsBTC bool, investedUSD, finalValueUSD, fxRateSell float64, buyDate, sellDate string, opts priceOptions) error {
+	if !vsBTC {
+		return nil
+	}
+
+	buyPrice, err := fetchPrice("BTC", buyDate, "crypto", opts)
+	if err != nil {
+		return err
+	}
+	sellPrice, err := fetchPrice("BTC", sellDate, "crypto", opts)
+	if err != nil {
+		return err
+	}
+
+	btcBought := investedUSD / buyPrice
+	btcValueUSD := btcBought * sellPrice
+
+	response["vsBTC"] = gin.H{
+		"btcBought":                    newCryptoAmount("BTC", btcBought),
+		"buyPrice":                     buyPrice,
+		"sellPrice":                    sellPrice,
+		"finalValueUSD":                btcValueUSD,
+		"finalValueInOriginalCurrency": btcValueUSD * fxRateSell,
+		"differenceUSD":                finalValueUSD - btcValueUSD,
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A source of historical crypto prices in USD
+type cryptoProvider interface {
+	Name() string
+	// Quotes returns [unix millis, USD price] points from one time to another,
+	// oldest first, as finely grained as the provider has for the span
+	Quotes(symbol string, from, to time.Time) ([][2]float64, error)
+}
+
+// Names of every crypto provider, configured or not
+var cryptoProviderNames = []string{"coinGecko", "coinbase", "binance", "cryptoCompare", "coinCap"}
+
+// Helper function to check whether a name is a crypto provider's
+func isCryptoProviderName(name string) bool {
+	for _, known := range cryptoProviderNames {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Crypto providers in the order they are tried: CoinGecko first, then the
+// fallbacks when CoinGecko is throttling, failing or has nothing for the span,
+// Coinbase's USD pairs ahead of Binance's USDT ones. Exchanges' minute bars are
+// exact where CoinGecko's are coarse, so spans shorter than a day (prices at a
+// time of day) try Binance and Coinbase first.
+func cryptoProviders(intraday bool) []cryptoProvider {
+	cfg := settings()
+	var venues []cryptoProvider
+	if intraday && cfg.BinanceBaseURL != "" {
+		venues = append(venues, binanceProvider{})
+	}
+	if cfg.CoinbaseBaseURL != "" {
+		venues = append(venues, coinbaseProvider{})
+	}
+	if !intraday && cfg.BinanceBaseURL != "" {
+		venues = append(venues, binanceProvider{})
+	}
+	providers := []cryptoProvider{coinGeckoProvider{}}
+	if intraday {
+		providers = append(venues, providers...)
+	} else {
+		providers = append(providers, venues...)
+	}
+	if cfg.CryptoCompareBaseURL != "" {
+		providers = append(providers, cryptoCompareProvider{})
+	}
+	if cfg.CoinCapAPIKey != "" {
+		providers = append(providers, coinCapProvider{})
+	}
+	return providers
+}
+
+// Quotes from the crypto provider that had them, and whether it came after a
+// provider that was tried first
+type cryptoQuotes struct {
+	Points   [][2]float64
+	Provider string
+	Fallback bool
+}
+
+// Fetch a coin's quotes between two times from the first provider that has any, or
+// only from the one a request chose with ?source=
+func fetchCryptoQuotes(symbol string, from, to time.Time, source string) (cryptoQuotes, error) {
+	providers := cryptoProviders(to.Sub(from) < 24*time.Hour)
+	if source != "" {
+		var chosen []cryptoProvider
+		for _, provider := range providers {
+			if provider.Name() == source {
+				chosen = append(chosen, provider)
+			}
+		}
+		if len(chosen) == 0 {
+			return cryptoQuotes{}, fmt.Errorf("%s has no crypto prices", source)
+		}
+		providers = chosen
+	}
+
+	var failures []string
+	for i, provider := range providers {
+		points, err := provider.Quotes(symbol, from, to)
+		if err == nil && len(points) == 0 {
+			err = errors.New("no prices")
+		}
+		if err == nil {
+			return cryptoQuotes{Points: points, Provider: provider.Name(), Fallback: i > 0}, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+	}
+	return cryptoQuotes{}, fmt.Errorf("No crypto price for %s from %s to %s (%s)",
+		symbol, from.Format("2006-01-02T15:04"), to.Format("2006-01-02T15:04"), strings.Join(failures, "; "))
+}
+
+// CoinGecko's market chart range, for coins it knows by symbol or search
+type coinGeckoProvider struct{}
+
+func (coinGeckoProvider) Name() string { return "coinGecko" }
+
+func (coinGeckoProvider) Quotes(symbol string, from, to time.Time) ([][2]float64, error) {
+	coinID, err := lookupCoinID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return fetchCryptoHistory(coinID, from.Unix(), to.Unix())
+}
+
+// CryptoCompare histohour/histoday response struct
+// Example: https://min-api.cryptocompare.com/data/v2/histohour?fsym=BTC&tsym=USD&limit=24&toTs=1752883200
+type cryptoCompareHistoryResponse struct {
+	Response string `json:"Response"`
+	Message  string `json:"Message"`
+	Data     struct {
+		Data []struct {
+			Time  int64   `json:"time"`
+			Open  float64 `json:"open"`
+			Close float64 `json:"close"`
+		} `json:"Data"`
+	} `json:"Data"`
+}
+
+// CryptoCompare's hourly and daily bars, by symbol. Spans of up to 2000 hours
+// come as hourly bars; longer ones as daily bars, all history at once.
+type cryptoCompareProvider struct{}
+
+func (cryptoCompareProvider) Name() string { return "cryptoCompare" }
+
+func (cryptoCompareProvider) Quotes(symbol string, from, to time.Time) ([][2]float64, error) {
+	cfg := settings()
+	endpoint, interval := "histohour", int64(3600)
+	limit := int64(to.Sub(from).Hours()) + 1
+	query := url.Values{"fsym": {symbol}, "tsym": {"USD"}, "toTs": {strconv.FormatInt(to.Unix(), 10)}}
+	if limit > 2000 {
+		endpoint, interval = "histoday", 86400
+		query.Set("allData", "true")
+	} else {
+		query.Set("limit", strconv.FormatInt(limit, 10))
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/data/v2/%s?%s", cfg.CryptoCompareBaseURL, endpoint, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CryptoCompareAPIKey != "" {
+		req.Header.Set("Authorization", "Apikey "+cfg.CryptoCompareAPIKey)
+	}
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CryptoCompare returned status %d", resp.StatusCode)
+	}
+
+	var result cryptoCompareHistoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	if result.Response == "Error" {
+		return nil, fmt.Errorf("CryptoCompare: %s", result.Message)
+	}
+
+	// Each bar opens at its time and closes when the next opens; bars of all
+	// zeroes are from before the coin traded
+	var points [][2]float64
+	for _, bar := range result.Data.Data {
+		if bar.Open == 0 && bar.Close == 0 {
+			continue
+		}
+		if bar.Time >= from.Unix() && bar.Time <= to.Unix() {
+			points = append(points, [2]float64{float64(bar.Time * 1000), bar.Open})
+		}
+		if end := bar.Time + interval; end > from.Unix() && end <= to.Unix() {
+			points = append(points, [2]float64{float64(end * 1000), bar.Close})
+		}
+	}
+	return dedupeQuotes(points), nil
+}
+
+// Helper function to add a bar's open, high, low and close to quotes between two
+// times. A bar opens at its time and closes when the next opens. Intraday bars
+// are stamped with their high and low a third and two thirds of the way through,
+// between the whole minutes times are asked for at; daily bars leave them out, so
+// each day has one quote at midnight like CoinGecko's history.
+func appendBarQuotes(points [][2]float64, from, to, opened time.Time, length time.Duration, ohlc [4]float64) [][2]float64 {
+	add := func(stamp time.Time, price float64) {
+		if !stamp.Before(from) && !stamp.After(to) {
+			points = append(points, [2]float64{float64(stamp.UnixMilli()), price})
+		}
+	}
+	add(opened, ohlc[0])
+	if length < 24*time.Hour {
+		add(opened.Add(length/3), ohlc[1])
+		add(opened.Add(2*length/3), ohlc[2])
+	}
+	add(opened.Add(length), ohlc[3])
+	return points
+}
+
+// Helper function to drop a quote stamped the same as the one before it: for
+// consecutive bars, the later bar's open at the earlier one's close
+func dedupeQuotes(points [][2]float64) [][2]float64 {
+	kept := points[:0]
+	for _, point := range points {
+		if len(kept) == 0 || kept[len(kept)-1][0] != point[0] {
+			kept = append(kept, point)
+		}
+	}
+	return kept
+}
+
+// CoinCap asset search and history response structs
+// Example: https://rest.coincap.io/v3/assets/bitcoin/history?interval=h1&start=1752796800000&end=1752883200000
+type coinCapAssetsResponse struct {
+	Data []struct {
+		ID     string `json:"id"`
+		Symbol string `json:"symbol"`
+	} `json:"data"`
+}
+
+type coinCapHistoryResponse struct {
+	Data []struct {
+		PriceUSD string `json:"priceUsd"`
+		Time     int64  `json:"time"`
+	} `json:"data"`
+}
+
+// CoinCap asset IDs by symbol, found via its search
+var (
+	coinCapIDsMu sync.Mutex
+	coinCapIDs   = map[string]string{}
+)
+
+// CoinCap's price history, by its asset ID: 5-minute points for spans of a day or
+// less, hourly ones up to a month and daily ones beyond
+type coinCapProvider struct{}
+
+func (coinCapProvider) Name() string { return "coinCap" }
+
+// Helper function to make an authenticated CoinCap request and decode its answer
+func coinCapGet(path string, query url.Values, result interface{}) error {
+	cfg := settings()
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s%s?%s", cfg.CoinCapBaseURL, path, query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.CoinCapAPIKey)
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CoinCap returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	return nil
+}
+
+// Look up CoinCap's ID for a symbol, taking the highest ranked asset with it
+func lookupCoinCapID(symbol string) (string, error) {
+	coinCapIDsMu.Lock()
+	defer coinCapIDsMu.Unlock()
+	if id, ok := coinCapIDs[symbol]; ok {
+		return id, nil
+	}
+
+	var result coinCapAssetsResponse
+	if err := coinCapGet("/assets", url.Values{"search": {symbol}}, &result); err != nil {
+		return "", err
+	}
+	for _, asset := range result.Data {
+		if strings.EqualFold(asset.Symbol, symbol) {
+			coinCapIDs[symbol] = asset.ID
+			return asset.ID, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown crypto symbol %s", symbol)
+}
+
+func (coinCapProvider) Quotes(symbol string, from, to time.Time) ([][2]float64, error) {
+	id, err := lookupCoinCapID(symbol)
+	if err != nil {
+		return nil, err
+	}
+	interval := "d1"
+	switch span := to.Sub(from); {
+	case span <= 24*time.Hour:
+		interval = "m5"
+	case span <= 31*24*time.Hour:
+		interval = "h1"
+	}
+
+	var result coinCapHistoryResponse
+	query := url.Values{
+		"interval": {interval},
+		"start":    {strconv.FormatInt(from.UnixMilli(), 10)},
+		"end":      {strconv.FormatInt(to.UnixMilli(), 10)},
+	}
+	if err := coinCapGet("/assets/"+url.PathEscape(id)+"/history", query, &result); err != nil {
+		return nil, err
+	}
+
+	points := make([][2]float64, 0, len(result.Data))
+	for _, point := range result.Data {
+		price, err := strconv.ParseFloat(point.PriceUSD, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad CoinCap price %q", point.PriceUSD)
+		}
+		points = append(points, [2]float64{float64(point.Time), price})
+	}
+	return points, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake CryptoCompare API with hourly bars for BTC, each opening at
+// mockCryptoPrices' price for its UTC day and closing 10 higher
+func setupMockCryptoCompare(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data/v2/histohour" || r.URL.Query().Get("fsym") != "BTC" {
+			fmt.Fprint(w, `{"Response": "Error", "Message": "no data for the symbol"}`)
+			return
+		}
+		toTs, _ := strconv.ParseInt(r.URL.Query().Get("toTs"), 10, 64)
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+		var result cryptoCompareHistoryResponse
+		result.Response = "Success"
+		for stamp := toTs - limit*3600; stamp <= toTs; stamp += 3600 {
+			price := mockCryptoPrices["bitcoin"][time.Unix(stamp, 0).UTC().Format("2006-01-02")]
+			result.Data.Data = append(result.Data.Data, struct {
+				Time  int64   `json:"time"`
+				Open  float64 `json:"open"`
+				Close float64 `json:"close"`
+			}{stamp, price, price + 10})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.CryptoCompareBaseURL = server.URL })
+}
+
+// Test CryptoCompare's hourly bars become quotes within the span, the last bar's
+// close at its end
+func TestCryptoCompareQuotes(t *testing.T) {
+	setupMockCryptoCompare(t)
+
+	from := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	points, err := cryptoCompareProvider{}.Quotes("BTC", from, from.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, points, 25)
+	assert.Equal(t, float64(from.UnixMilli()), points[0][0])
+	assert.Equal(t, 118000.0, points[0][1])
+	// The day's last bar closes at midnight, where the next day's first opens
+	assert.Equal(t, [2]float64{float64(from.Add(24 * time.Hour).UnixMilli()), 118010}, points[24])
+
+	_, err = cryptoCompareProvider{}.Quotes("NOPE", from, from.Add(time.Hour))
+	assert.ErrorContains(t, err, "no data for the symbol")
+}
+
+// Test CoinCap quotes come from its history for the asset its search finds
+func TestCoinCapQuotes(t *testing.T) {
+	var interval, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/assets":
+			fmt.Fprint(w, `{"data": [{"id": "wrapped-bitcoin", "symbol": "WBTC"}, {"id": "bitcoin", "symbol": "BTC"}]}`)
+		case "/assets/bitcoin/history":
+			interval = r.URL.Query().Get("interval")
+			fmt.Fprint(w, `{"data": [{"priceUsd": "117950.5", "time": 1752796800000}, {"priceUsd": "118000.25", "time": 1752797100000}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	setSettings(t, func(cfg *Config) { cfg.CoinCapBaseURL, cfg.CoinCapAPIKey = server.URL, "test-key" })
+	coinCapIDs = map[string]string{}
+
+	from := time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)
+	points, err := coinCapProvider{}.Quotes("BTC", from, from.Add(24*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]float64{{1752796800000, 117950.5}, {1752797100000, 118000.25}}, points)
+	assert.Equal(t, "m5", interval)
+	assert.Equal(t, "Bearer test-key", auth)
+	assert.Equal(t, "bitcoin", coinCapIDs["BTC"])
+}
+
+// Test crypto prices fall back to CryptoCompare while CoinGecko is throttling,
+// and the response notes where they came from
+func TestCryptoProviderFallback(t *testing.T) {
+	setupMockCoinGecko(t)
+	throttled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(throttled.Close)
+	setSettings(t, func(cfg *Config) { cfg.CoinGeckoBaseURL = throttled.URL })
+	setupMockCryptoCompare(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/0.5/BTC/on/2025-03-31/and-sold-on/2025-07-18?type=crypto")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		BuyPrice  float64      `json:"buyPrice"`
+		SellPrice float64      `json:"sellPrice"`
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// The close is the last bar's, at midnight
+	assert.Equal(t, 82510.0, response.BuyPrice)
+	assert.Equal(t, 118010.0, response.SellPrice)
+	assert.Len(t, response.DataNotes, 2)
+	for _, source := range response.DataNotes {
+		assert.Equal(t, "cryptoCompare", source.Provider)
+		assert.True(t, source.Fallback)
+	}
+
+	// With every provider failing, the error says how each did
+	_, err := fetchCryptoQuotes("ETH", time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC), time.Date(2025, 7, 19, 0, 0, 0, 0, time.UTC), "")
+	assert.ErrorContains(t, err, "coinGecko: CoinGecko returned status 429")
+	assert.ErrorContains(t, err, "cryptoCompare: CryptoCompare: no data for the symbol")
+}
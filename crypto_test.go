@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock USD prices by CoinGecko coin ID and date
+var mockCryptoPrices = map[string]map[string]float64{
+	"bitcoin": {
+		"2025-03-31": 82500.0,
+		"2025-06-30": 107000.0,
+		"2025-07-18": 118000.0,
+	},
+	"ethereum": {
+		"2025-03-31": 1820.0,
+		"2025-07-18": 3550.0,
+	},
+	"solana": {
+		"2025-03-31": 125.0,
+		"2025-07-18": 177.5,
+	},
+	"usd-coin": {
+		"2025-03-31": 0.9992,
+	},
+	"pepe": {
+		"2025-07-18": 0.0000125,
+	},
+}
+
+// Start a fake CoinGecko market chart API serving mockCryptoPrices
+func setupMockCoinGecko(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/search" {
+			query := strings.ToLower(r.URL.Query().Get("query"))
+			if _, ok := mockCryptoPrices[query]; !ok {
+				fmt.Fprint(w, `{"coins": []}`)
+				return
+			}
+			fmt.Fprintf(w, `{"coins": [{"id": %q, "symbol": %q}]}`, query, strings.ToUpper(query))
+			return
+		}
+		if r.URL.Path == "/simple/price" {
+			// The latest mock price stands in for the live one
+			id, latest := r.URL.Query().Get("ids"), ""
+			for date := range mockCryptoPrices[id] {
+				latest = max(latest, date)
+			}
+			fmt.Fprintf(w, `{%q: {"usd": %v}}`, id, mockCryptoPrices[id][latest])
+			return
+		}
+
+		// Path: /coins/{id}/market_chart/range
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 4 || parts[0] != "coins" {
+			http.NotFound(w, r)
+			return
+		}
+
+		var from, to int64
+		fmt.Sscan(r.URL.Query().Get("from"), &from)
+		fmt.Sscan(r.URL.Query().Get("to"), &to)
+
+		// Each day's price holds for the whole UTC day; quote it for every day
+		// overlapping [from, to), stamped no earlier than from
+		var dates []string
+		for date := range mockCryptoPrices[parts[1]] {
+			day, _ := time.Parse("2006-01-02", date)
+			if day.Unix() < to && day.Unix()+86400 > from {
+				dates = append(dates, date)
+			}
+		}
+		sort.Strings(dates)
+
+		prices := [][2]float64{}
+		for _, date := range dates {
+			day, _ := time.Parse("2006-01-02", date)
+			stamp := max(day.Unix(), from)
+			prices = append(prices, [2]float64{float64(stamp * 1000), mockCryptoPrices[parts[1]][date]})
+		}
+		json.NewEncoder(w).Encode(coinGeckoMarketChartResponse{Prices: prices})
+	}))
+	t.Cleanup(server.Close)
+
+	// Mocked prices missing a date mustn't fall back to the live providers
+	setSettings(t, func(cfg *Config) {
+		cfg.CoinGeckoBaseURL, cfg.CoinbaseBaseURL, cfg.BinanceBaseURL, cfg.CryptoCompareBaseURL, cfg.CoinCapAPIKey = server.URL, "", "", "", ""
+	})
+	prices.invalidate(priceFilter{}) // prices cached from other mocks would shadow this one's
+	latest.clear()
+	searchedCoinIDs = map[string]string{}
+}
+
+// Test crypto price lookups through the FX layer
+func TestCryptoFXRate(t *testing.T) {
+	setupMockCoinGecko(t)
+
+	rate, err := getHistoricalFXRate("BTC", "USD", "2025-03-31")
+	assert.NoError(t, err)
+	assert.Equal(t, 82500.0, rate)
+
+	rate, err = getHistoricalFXRate("USD", "BTC", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1/118000.0, rate, 1e-12)
+
+	rate, err = getHistoricalFXRate("ETH", "BTC", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 3550.0/118000.0, rate, 1e-12)
+
+	_, err = getHistoricalFXRate("BTC", "USD", "2020-01-01")
+	assert.Error(t, err)
+}
+
+// Test picking the open, high, low and close from a day of quotes
+func TestOHLCPoint(t *testing.T) {
+	prices := [][2]float64{{0, 100}, {1, 120}, {2, 90}, {3, 110}}
+	assert.Equal(t, 100.0, ohlcPoint(prices, "open"))
+	assert.Equal(t, 120.0, ohlcPoint(prices, "high"))
+	assert.Equal(t, 90.0, ohlcPoint(prices, "low"))
+	assert.Equal(t, 110.0, ohlcPoint(prices, "close"))
+}
+
+// Test crypto-denominated amounts
+func TestParseAmountCrypto(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+		currency string
+	}{
+		{"0.5BTC", 0.5, "BTC"},
+		{"10ETH", 10, "ETH"},
+		{"100DOGE", 100, "DOGE"},
+		{"10MATIC", 10, "MATIC"},
+		{"10 MATIC", 10, "MATIC"},
+		{"1kDOGE", 1000, "DOGE"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			amount, currency, isValue, err := parseAmount(tc.input, "")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, amount)
+			assert.Equal(t, tc.currency, currency)
+			assert.True(t, isValue)
+		})
+	}
+}
+
+// Test native precision and small units for crypto amounts
+func TestNewCryptoAmount(t *testing.T) {
+	btc := newCryptoAmount("BTC", 0.01234567891)
+	assert.Equal(t, "0.01234568", btc.Amount)
+	assert.Equal(t, "sats", btc.Unit)
+	assert.Equal(t, int64(1234568), btc.SmallUnits)
+
+	eth := newCryptoAmount("ETH", 1.5)
+	assert.Equal(t, "1.500000000", eth.Amount)
+	assert.Equal(t, "gwei", eth.Unit)
+	assert.Equal(t, int64(1500000000), eth.SmallUnits)
+
+	link := newCryptoAmount("LINK", 2)
+	assert.Equal(t, "2.00000000", link.Amount)
+	assert.Empty(t, link.Unit)
+}
+
+// Test crypto asset pricing and the cryptoUnits response block
+func TestCryptoAssetBuySell(t *testing.T) {
+	setupMockCoinGecko(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/0.5/BTC/on/2025-03-31/and-sold-on/2025-07-18?type=crypto")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 82500.0, response["buyPrice"])
+	assert.Equal(t, 118000.0, response["sellPrice"])
+	assert.Equal(t, 59000.0, response["finalValue"])
+
+	units := response["cryptoUnits"].(map[string]interface{})
+	quantity := units["quantity"].(map[string]interface{})
+	assert.Equal(t, "sats", quantity["unit"])
+	assert.Equal(t, float64(50000000), quantity["smallUnits"])
+}
+
+// Test a crypto date's window ends at the snapshot time, midnight ending the date itself
+func TestCryptoDayWindow(t *testing.T) {
+	start, end, err := cryptoDayWindow("2025-07-19")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 19, 0, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2025, 7, 20, 0, 0, 0, 0, time.UTC), end)
+	assert.Equal(t, "2025-07-18", cryptoLatestClose(time.Date(2025, 7, 19, 23, 59, 0, 0, time.UTC)))
+
+	setSettings(t, func(cfg *Config) { cfg.CryptoSnapshotTime = "16:00" })
+
+	start, end, err = cryptoDayWindow("2025-07-19")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 7, 18, 16, 0, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2025, 7, 19, 16, 0, 0, 0, time.UTC), end)
+	assert.Equal(t, "2025-07-18", cryptoLatestClose(time.Date(2025, 7, 19, 15, 59, 0, 0, time.UTC)))
+	assert.Equal(t, "2025-07-19", cryptoLatestClose(time.Date(2025, 7, 19, 16, 0, 0, 0, time.UTC)))
+}
+
+// Test crypto is priced on weekend dates themselves, never moved to a weekday, and
+// responses say how
+func TestCryptoWeekendPricing(t *testing.T) {
+	setupMockCoinGecko(t)
+	mockCryptoPrices["solana"]["2025-06-29"] = 152.0 // a Sunday
+	t.Cleanup(func() { delete(mockCryptoPrices["solana"], "2025-06-29") })
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/SOL/on/2025-06-29/and-sold-on/2025-07-18?type=crypto")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		BuyPrice      float64       `json:"buyPrice"`
+		CryptoPricing cryptoPricing `json:"cryptoPricing"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 152.0, response.BuyPrice)
+	assert.Equal(t, cryptoPricing{
+		Market:       "24/7",
+		SnapshotTime: "00:00 UTC",
+		Window:       "00:00 to 24:00 UTC on the date",
+	}, response.CryptoPricing)
+
+	// A date without a price fails rather than moving on to the next one
+	_, err := fetchPriceOnOrAfter("SOL", "2025-06-28", "crypto", priceOptions{At: "close"})
+	assert.Error(t, err)
+	price, err := fetchPriceOnOrAfter("SOL", "2025-06-29", "crypto", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 152.0, price)
+}
+
+// Test crypto-to-crypto swaps reported in a third currency
+func TestCryptoSwap(t *testing.T) {
+	setupMockCoinGecko(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1ETH/into/SOL/on/2025-03-31/and-sold-on/2025-07-18?reportIn=BTC")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// 1 ETH at $1820 buys 14.56 SOL, worth $2584.40 at the sell date
+	assert.Equal(t, "crypto", response["type"])
+	assert.Equal(t, "ETH", response["currency"])
+	assert.InDelta(t, 14.56, response["shares"], 1e-9)
+	assert.InDelta(t, 2584.4, response["finalValueUSD"], 1e-9)
+	assert.InDelta(t, 2584.4/3550.0, response["finalValueInOriginalCurrency"], 1e-9)
+	assert.Equal(t, "BTC", response["reportCurrency"])
+	assert.InDelta(t, 2584.4/118000.0, response["finalValueInReportCurrency"], 1e-9)
+
+	units := response["cryptoUnits"].(map[string]interface{})
+	assert.Contains(t, units, "shares")
+	assert.Contains(t, units, "finalValueInOriginalCurrency")
+	assert.Contains(t, units, "finalValueInReportCurrency")
+
+	w = makeTestRequest(router, "GET", "/1ETH/into/SOL/on/2025-03-31/and-sold-on/2025-07-18?reportIn=XX")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test the vsBTC comparison block
+func TestBTCComparison(t *testing.T) {
+	setupMockCoinGecko(t)
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock&vsBTC=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// $2005 of BTC at $82,500, sold at $118,000
+	btcValue := 2005.0 / 82500.0 * 118000.0
+	vsBTC := response["vsBTC"].(map[string]interface{})
+	assert.InDelta(t, btcValue, vsBTC["finalValueUSD"], 1e-9)
+	assert.InDelta(t, 2111.8-btcValue, vsBTC["differenceUSD"], 1e-9)
+	assert.Equal(t, "sats", vsBTC["btcBought"].(map[string]interface{})["unit"])
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Currency symbols and the ISO codes they can stand for.
+// The first entry is the default reading when no home currency is given.
+var currencySymbols = map[string][]string{
+	"$":  {"USD", "CAD", "AUD", "NZD", "HKD", "SGD", "MXN"},
+	"€":  {"EUR"},
+	"£":  {"GBP"},
+	"¥":  {"JPY", "CNY"},
+	"₹":  {"INR"},
+	"₩":  {"KRW"},
+	"₺":  {"TRY"},
+	"₽":  {"RUB"},
+	"₪":  {"ILS"},
+	"₱":  {"PHP"},
+	"฿":  {"THB"},
+	"kr": {"SEK", "NOK", "DKK", "ISK"},
+}
+
+// ISO 4217 codes and crypto symbols like BTC or DOGE
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z]{3,5}$`)
+
+// Stablecoins and the fiat currency they track
+var stablecoinPegs = map[string]string{
+	"USDT": "USD",
+	"USDC": "USD",
+	"DAI":  "USD",
+	"EURC": "EUR",
+}
+
+// Read a stablecoin as its peg, unless STABLECOIN_DEPEG asks for market prices
+func pegStablecoin(currency string) string {
+	if peg, ok := stablecoinPegs[currency]; ok && !settings().StablecoinDepeg {
+		return peg
+	}
+	return currency
+}
+
+// Resolve a currency symbol or code to an ISO 4217 code.
+// Ambiguous symbols like $, ¥ and kr use homeCurrency when it is one of their readings.
+func resolveCurrency(currency, homeCurrency string) (string, error) {
+	homeCurrency = strings.ToUpper(homeCurrency)
+
+	candidates, ok := currencySymbols[currency]
+	if !ok {
+		// Already an ISO code or crypto symbol
+		if currencyCodeRegex.MatchString(currency) {
+			return currency, nil
+		}
+		return "", fmt.Errorf("Unknown currency symbol %q", currency)
+	}
+
+	if homeCurrency == "" {
+		return candidates[0], nil
+	}
+
+	for _, code := range candidates {
+		if code == homeCurrency {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("homeCurrency %s is not a valid reading of %q (expected one of %s)", homeCurrency, currency, strings.Join(candidates, ", "))
+}
+
+// Restate a USD final value in the ?reportIn= currency at the sell date's rate
+func addReportCurrency(response gin.H, reportIn string, finalValueUSD float64, sellDate string) error {
+	if reportIn == "" {
+		return nil
+	}
+
+	fxRateReport, err := getHistoricalFXRate("USD", reportIn, sellDate)
+	if err != nil {
+		return err
+	}
+
+	response["reportCurrency"] = reportIn
+	response["fxRateReport"] = fxRateReport
+	response["finalValueInReportCurrency"] = finalValueUSD * fxRateReport
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test currency symbol disambiguation
+func TestResolveCurrency(t *testing.T) {
+	testCases := []struct {
+		currency     string
+		homeCurrency string
+		expected     string
+		wantErr      bool
+	}{
+		{"EUR", "", "EUR", false},
+		{"$", "", "USD", false},
+		{"$", "CAD", "CAD", false},
+		{"$", "aud", "AUD", false},
+		{"¥", "", "JPY", false},
+		{"¥", "CNY", "CNY", false},
+		{"kr", "", "SEK", false},
+		{"kr", "NOK", "NOK", false},
+		{"€", "", "EUR", false},
+		{"$", "EUR", "", true},
+		{"USDT", "", "USDT", false},
+		{"₿x", "", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.currency+"_"+tc.homeCurrency, func(t *testing.T) {
+			code, err := resolveCurrency(tc.currency, tc.homeCurrency)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, code)
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Supported DCA purchase and rebalancing frequencies, in months (weekly is
+// handled separately)
+var periodFrequencies = map[string]int{
+	"week":    0,
+	"month":   1,
+	"quarter": 3,
+	"year":    12,
+}
+
+// One periodic purchase in a dollar-cost averaging schedule
+type dcaPurchase struct {
+	Date      string  `json:"date"`
+	Amount    float64 `json:"amount"`
+	AmountUSD float64 `json:"amountUSD"`
+	Price     float64 `json:"price"`
+	Shares    float64 `json:"shares"`
+}
+
+// Helper function to add months to a date, clamping to the end of shorter months
+// so a schedule starting on the 31st buys on the 30th of April rather than 1 May
+func addMonthsClamped(start time.Time, months int) time.Time {
+	firstOfMonth := time.Date(start.Year(), start.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := firstOfMonth.AddDate(0, 1, -1).Day()
+	day := start.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// Build the dates of a periodic schedule (DCA purchases, rebalances): the buy
+// date, then every period until (but not including) the sell date
+func periodicDates(buyDate, sellDate, every string) ([]string, error) {
+	months, ok := periodFrequencies[every]
+	if !ok {
+		return nil, fmt.Errorf("Invalid frequency %q: must be week, month, quarter or year", every)
+	}
+
+	buy, err := time.Parse("2006-01-02", buyDate)
+	if err != nil {
+		return nil, err
+	}
+	sell, err := time.Parse("2006-01-02", sellDate)
+	if err != nil {
+		return nil, err
+	}
+	if !sell.After(buy) {
+		return nil, fmt.Errorf("Sell date must be after buy date")
+	}
+
+	var dates []string
+	for i := 0; ; i++ {
+		day := addMonthsClamped(buy, i*months)
+		if months == 0 {
+			day = buy.AddDate(0, 0, 7*i)
+		}
+		if !day.Before(sell) {
+			break
+		}
+		dates = append(dates, day.Format("2006-01-02"))
+	}
+	return dates, nil
+}
+
+// Helper function to price a schedule's dates from one daily history (oldest
+// first), instead of a request per date: each date at the first price on or after
+// it, moving over weekends and holidays by up to a week like fetchPriceOnOrAfter
+func priceOnOrAfterIn(points []pricePoint) func(date string) (float64, error) {
+	return func(date string) (float64, error) {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return 0, err
+		}
+		i := sort.Search(len(points), func(i int) bool { return points[i].Date >= date })
+		if i == len(points) || points[i].Date >= day.AddDate(0, 0, 7).Format("2006-01-02") {
+			return 0, fmt.Errorf("No trading day within a week of %s", date)
+		}
+		return points[i].Price, nil
+	}
+}
+
+// Spread an amount (in currency) evenly over the scheduled dates, converting each
+// instalment to USD at that day's rate and buying at that day's price
+func calculateDCA(amount float64, dates []string, usdRate, priceOn func(date string) (float64, error)) ([]dcaPurchase, float64, float64, error) {
+	purchases := []dcaPurchase{}
+	shares, investedUSD := 0.0, 0.0
+	instalment := amount / float64(len(dates))
+
+	for _, date := range dates {
+		rate, err := usdRate(date)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("FX rate for %s: %v", date, err)
+		}
+		price, err := priceOn(date)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("Price for %s: %v", date, err)
+		}
+
+		bought := instalment * rate / price
+		shares += bought
+		investedUSD += instalment * rate
+		purchases = append(purchases, dcaPurchase{Date: date, Amount: instalment, AmountUSD: instalment * rate, Price: price, Shares: bought})
+	}
+
+	return purchases, shares, investedUSD, nil
+}
+
+// Compare investing a value amount in one go on the buy date against spreading it
+// over periodic purchases (?every=week|month|quarter|year) until the sell date.
+// Both outcomes are price-only; use adjusted=true for total return.
+func handleLumpSumVsDCA(c *gin.Context) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	every := c.DefaultQuery("every", "month")
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+	if !isValue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lump sum vs DCA needs a value amount, e.g. 1000USD"})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Lump sum vs DCA is not supported for bonds or options"})
+		return
+	}
+
+	dates, err := periodicDates(dateOnly(buyDate), dateOnly(sellDate), every)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid DCA schedule", "details": err.Error()})
+		return
+	}
+
+	// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+	currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+		return
+	}
+
+	fxRateBuy, err := priceOpts.Notes.fxRate(currency, "USD", buyDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+		return
+	}
+
+	fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+		return
+	}
+
+	sellPrice, err := fetchPrice(ticker, sellDate, typeParam, priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+		return
+	}
+
+	// The lump sum and scheduled purchases falling on weekends or holidays buy on
+	// the next trading day, all priced from one fetch of the daily history
+	points, err := fetchPriceHistory(ticker, typeParam, dates[0], dateOnly(sellDate), priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch price history", err)
+		return
+	}
+	priceOn := priceOnOrAfterIn(points)
+	buyPrice, err := priceOn(dates[0])
+	if err != nil {
+		respondPriceError(c, "Failed to fetch buy price", err)
+		return
+	}
+	purchases, dcaShares, dcaInvestedUSD, err := calculateDCA(parsedAmount, dates,
+		func(date string) (float64, error) {
+			return priceOpts.Notes.fxRate(currency, "USD", date)
+		},
+		priceOn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price DCA purchases", "details": err.Error()})
+		return
+	}
+
+	lumpSumShares := parsedAmount * fxRateBuy / buyPrice
+	lumpSumValueUSD := lumpSumShares * sellPrice
+	dcaValueUSD := dcaShares * sellPrice
+
+	winner := "lumpSum"
+	if dcaValueUSD > lumpSumValueUSD {
+		winner = "dca"
+	}
+
+	lumpSum := gin.H{
+		"buyPrice":                     buyPrice,
+		"shares":                       lumpSumShares,
+		"investedUSD":                  parsedAmount * fxRateBuy,
+		"finalValueUSD":                lumpSumValueUSD,
+		"finalValueInOriginalCurrency": lumpSumValueUSD * fxRateSell,
+	}
+	dca := gin.H{
+		"purchases":                    purchases,
+		"shares":                       dcaShares,
+		"averagePrice":                 dcaInvestedUSD / dcaShares,
+		"investedUSD":                  dcaInvestedUSD,
+		"finalValueUSD":                dcaValueUSD,
+		"finalValueInOriginalCurrency": dcaValueUSD * fxRateSell,
+	}
+
+	// Money-weighted returns in the amount's currency, over the dates it went in
+	addXIRR(lumpSum, []cashFlow{{buyDate, -parsedAmount}, {sellDate, lumpSumValueUSD * fxRateSell}})
+	flows := make([]cashFlow, 0, len(purchases)+1)
+	for _, purchase := range purchases {
+		flows = append(flows, cashFlow{purchase.Date, -purchase.Amount})
+	}
+	addXIRR(dca, append(flows, cashFlow{sellDate, dcaValueUSD * fxRateSell}))
+
+	response := gin.H{
+		"message":       localize(c, "Lump sum vs DCA"),
+		"value":         parsedAmount,
+		"currency":      currency,
+		"ticker":        ticker,
+		"buyDate":       buyDate,
+		"sellDate":      sellDate,
+		"every":         every,
+		"lumpSum":       lumpSum,
+		"dca":           dca,
+		"sellPrice":     sellPrice,
+		"differenceUSD": lumpSumValueUSD - dcaValueUSD,
+		"difference":    (lumpSumValueUSD - dcaValueUSD) * fxRateSell,
+		"winner":        winner,
+		"stockCurrency": "USD",
+		"fxRateBuy":     fxRateBuy,
+		"fxRateSell":    fxRateSell,
+		"type":          typeParam,
+		"priceAt":       priceOpts.At,
+		"priceBasis":    priceOpts.basis(typeParam),
+	}
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCryptoPricing(response, typeParam)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
+}
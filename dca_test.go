@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test periodic DCA and rebalancing schedules
+func TestPeriodicDates(t *testing.T) {
+	dates, err := periodicDates("2025-01-31", "2025-05-01", "month")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-01-31", "2025-02-28", "2025-03-31", "2025-04-30"}, dates)
+
+	dates, err = periodicDates("2025-03-31", "2025-07-18", "quarter")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-03-31", "2025-06-30"}, dates)
+
+	dates, err = periodicDates("2025-07-01", "2025-07-15", "week")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-07-01", "2025-07-08"}, dates)
+
+	dates, err = periodicDates("2024-02-29", "2026-01-01", "year")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2024-02-29", "2025-02-28"}, dates)
+
+	_, err = periodicDates("2025-07-01", "2025-07-15", "fortnight")
+	assert.Error(t, err)
+
+	_, err = periodicDates("2025-07-15", "2025-07-01", "month")
+	assert.Error(t, err)
+}
+
+// Test the lump sum vs DCA route
+func TestLumpSumVsDCAWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca?every=quarter")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	lumpSum := 1000 / 200.50 * 211.18
+	dca := (500/200.50 + 500/205.17) * 211.18
+	assert.InDelta(t, lumpSum, response["lumpSum"].(map[string]interface{})["finalValueUSD"], 1e-9)
+	assert.InDelta(t, dca, response["dca"].(map[string]interface{})["finalValueUSD"], 1e-9)
+	assert.InDelta(t, lumpSum-dca, response["differenceUSD"], 1e-9)
+	assert.Equal(t, "lumpSum", response["winner"])
+	assert.Len(t, response["dca"].(map[string]interface{})["purchases"], 2)
+
+	// The lump sum's XIRR is its annualized return; the DCA's discounts both purchases
+	days := float64(daysBetween("2025-03-31", "2025-07-18"))
+	assert.InDelta(t, (math.Pow(lumpSum/1000, 365/days)-1)*100, response["lumpSum"].(map[string]interface{})["xirrPercent"], 1e-6)
+	rate := response["dca"].(map[string]interface{})["xirrPercent"].(float64) / 100
+	flows := []cashFlow{{"2025-03-31", -500}, {"2025-06-30", -500}, {"2025-07-18", dca}}
+	assert.InDelta(t, 0, netPresentValue(flows, rate), 1e-6)
+
+	// Quantities and unknown frequencies are rejected
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca?every=day")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test a lump sum on a weekend buys on the next trading day, like the first DCA purchase
+func TestLumpSumVsDCAWeekendWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-29/and-sold-on/2025-07-18/lump-sum-vs-dca?every=quarter")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		LumpSum struct {
+			BuyPrice float64 `json:"buyPrice"`
+		} `json:"lumpSum"`
+		DCA struct {
+			Purchases []dcaPurchase `json:"purchases"`
+		} `json:"dca"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 200.50, response.LumpSum.BuyPrice)
+	assert.Equal(t, response.LumpSum.BuyPrice, response.DCA.Purchases[0].Price)
+}
+
+// Test a schedule is priced from one history, on or after each date
+func TestPriceOnOrAfterIn(t *testing.T) {
+	points := []pricePoint{{Date: "2025-03-31", Price: 200.50}, {Date: "2025-04-07", Price: 190}, {Date: "2025-06-30", Price: 205.17}}
+	priceOn := priceOnOrAfterIn(points)
+	for date, want := range map[string]float64{"2025-03-31": 200.50, "2025-04-05": 190, "2025-04-07": 190, "2025-06-28": 205.17} {
+		price, err := priceOn(date)
+		assert.NoError(t, err, date)
+		assert.Equal(t, want, price, date)
+	}
+	for _, date := range []string{"2025-04-30", "2025-07-01", "yesterday"} {
+		_, err := priceOn(date)
+		assert.Error(t, err, date)
+	}
+}
+
+// Test a weekly DCA costs a handful of upstream requests, not one per purchase
+func TestLumpSumVsDCAUpstreamRequests(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+	var added []string
+	for day := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC); day.Before(time.Date(2025, 7, 18, 0, 0, 0, 0, time.UTC)); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		if _, ok := mockStockData[date]; !ok && day.Weekday() != time.Saturday && day.Weekday() != time.Sunday {
+			mockStockData[date] = map[string]string{"1. open": "200.00", "2. high": "200.00", "3. low": "200.00", "4. close": "200.00", "5. volume": "1000"}
+			added = append(added, date)
+		}
+	}
+	t.Cleanup(func() {
+		for _, date := range added {
+			delete(mockStockData, date)
+		}
+		prices.invalidate(priceFilter{})
+	})
+	prices.invalidate(priceFilter{})
+
+	upstream := func() int {
+		providerUsageMu.Lock()
+		defer providerUsageMu.Unlock()
+		if usage, ok := providerUsages[hostOf(settings().AlphaVantageBaseURL)]; ok {
+			return usage.TotalRequests
+		}
+		return 0
+	}
+	before := upstream()
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca?every=week")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		DCA struct {
+			Purchases []dcaPurchase `json:"purchases"`
+		} `json:"dca"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.DCA.Purchases, 16)
+	assert.LessOrEqual(t, upstream()-before, 3)
+}
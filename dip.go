@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Longest lookback for a dip's recent high, in days
+const maxDipLookbackDays = 365
+
+// Most tranches a cash pile can be split into
+const maxDipTranches = 100
+
+// How a buy-the-dip strategy deploys its cash: in ?tranches= equal parts, each
+// when the price is ?dip= percent below its highest over the ?lookback= days
+type dipOptions struct {
+	DipPercent   float64 `json:"dipPercent"`
+	Tranches     int     `json:"tranches"`
+	LookbackDays int     `json:"lookbackDays"`
+}
+
+// One tranche bought on a dip
+type dipPurchase struct {
+	Date        string  `json:"date"`
+	Amount      float64 `json:"amount"`
+	AmountUSD   float64 `json:"amountUSD"`
+	High        float64 `json:"high"`
+	Price       float64 `json:"price"`
+	DropPercent float64 `json:"dropPercent"`
+	Shares      float64 `json:"shares"`
+}
+
+// Helper function to read and validate the buy-the-dip options
+func dipOptionsParam(c *gin.Context) (dipOptions, error) {
+	opts := dipOptions{DipPercent: 10, Tranches: 4, LookbackDays: 90}
+	if dip := c.Query("dip"); dip != "" {
+		var err error
+		opts.DipPercent, err = strconv.ParseFloat(dip, 64)
+		if err != nil || opts.DipPercent <= 0 || opts.DipPercent >= 100 {
+			return opts, fmt.Errorf("Invalid dip parameter: must be a percentage above 0 and under 100")
+		}
+	}
+	if tranches := c.Query("tranches"); tranches != "" {
+		var err error
+		opts.Tranches, err = strconv.Atoi(tranches)
+		if err != nil || opts.Tranches < 1 || opts.Tranches > maxDipTranches {
+			return opts, fmt.Errorf("Invalid tranches parameter: must be a whole number from 1 to %d", maxDipTranches)
+		}
+	}
+	if lookback := c.Query("lookback"); lookback != "" {
+		var err error
+		opts.LookbackDays, err = strconv.Atoi(lookback)
+		if err != nil || opts.LookbackDays < 1 || opts.LookbackDays > maxDipLookbackDays {
+			return opts, fmt.Errorf("Invalid lookback parameter: must be a whole number of days from 1 to %d", maxDipLookbackDays)
+		}
+	}
+	return opts, nil
+}
+
+// Deploy an amount (in currency) in equal tranches on the dips in daily prices
+// from buyDate on. A tranche buys when the price is the dip below its highest
+// over the lookback, counting only days since the last tranche, so the next one
+// waits for the price to fall that much again. Returns the purchases, the shares
+// and USD invested, and the cash never deployed.
+func calculateDipBuys(amount float64, points []pricePoint, buyDate string, opts dipOptions, usdRate func(date string) (float64, error)) ([]dipPurchase, float64, float64, float64, error) {
+	purchases := []dipPurchase{}
+	shares, investedUSD := 0.0, 0.0
+	tranche := amount / float64(opts.Tranches)
+	since := ""
+
+	for i, point := range points {
+		if point.Date < buyDate || point.Price <= 0 {
+			continue
+		}
+		if len(purchases) == opts.Tranches {
+			break
+		}
+		day, err := time.Parse("2006-01-02", point.Date)
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		from := day.AddDate(0, 0, -opts.LookbackDays).Format("2006-01-02")
+		if since > from {
+			from = since
+		}
+		high := 0.0
+		for j := i; j >= 0 && points[j].Date >= from; j-- {
+			high = max(high, points[j].Price)
+		}
+		if point.Price > high*(1-opts.DipPercent/100) {
+			continue
+		}
+
+		rate, err := usdRate(point.Date)
+		if err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("FX rate for %s: %v", point.Date, err)
+		}
+		bought := tranche * rate / point.Price
+		shares += bought
+		investedUSD += tranche * rate
+		purchases = append(purchases, dipPurchase{
+			Date: point.Date, Amount: tranche, AmountUSD: tranche * rate, High: high, Price: point.Price,
+			DropPercent: (1 - point.Price/high) * 100, Shares: bought,
+		})
+		since = point.Date
+	}
+
+	return purchases, shares, investedUSD, amount - tranche*float64(len(purchases)), nil
+}
+
+// Simulate keeping a value amount as a cash pile from the buy date and buying the
+// dips with it until the sell date, against investing it all on the buy date and
+// spreading it over periodic purchases (?every=, month by default). Tranches the
+// price never dipped for stay as cash, earning nothing. All three are price-only.
+func handleBuyTheDip(c *gin.Context) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	every := c.DefaultQuery("every", "month")
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dipOpts, err := dipOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+	if !isValue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Buy the dip needs a value amount, e.g. 1000USD"})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Buy the dip is not supported for bonds or options"})
+		return
+	}
+
+	dates, err := periodicDates(dateOnly(buyDate), dateOnly(sellDate), every)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid DCA schedule", "details": err.Error()})
+		return
+	}
+
+	// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+	currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+		return
+	}
+
+	fxRateBuy, err := priceOpts.Notes.fxRate(currency, "USD", buyDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+		return
+	}
+
+	fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+		return
+	}
+
+	buyPrice, err := fetchPrice(ticker, buyDate, typeParam, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch buy price", err)
+		return
+	}
+
+	sellPrice, err := fetchPrice(ticker, sellDate, typeParam, priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+		return
+	}
+
+	// Daily prices from a lookback before the buy date, for its recent high, up to
+	// the day before the sale
+	start, err := time.Parse("2006-01-02", dateOnly(buyDate))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid buy date", "details": err.Error()})
+		return
+	}
+	points, err := fetchPriceHistory(ticker, typeParam, start.AddDate(0, 0, -dipOpts.LookbackDays).Format("2006-01-02"), dateOnly(sellDate), priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch price history", err)
+		return
+	}
+	history := points
+	for len(points) > 0 && points[len(points)-1].Date >= dateOnly(sellDate) {
+		points = points[:len(points)-1]
+	}
+
+	usdRate := func(date string) (float64, error) {
+		return priceOpts.Notes.fxRate(currency, "USD", date)
+	}
+	dipPurchases, dipShares, dipInvestedUSD, cashLeft, err := calculateDipBuys(parsedAmount, points, dateOnly(buyDate), dipOpts, usdRate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price dip purchases", "details": err.Error()})
+		return
+	}
+
+	// Scheduled purchases falling on weekends or holidays buy on the next trading
+	// day (the sale's at the latest), priced from the same history as the dips
+	dcaPurchases, dcaShares, dcaInvestedUSD, err := calculateDCA(parsedAmount, dates, usdRate, priceOnOrAfterIn(history))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price DCA purchases", "details": err.Error()})
+		return
+	}
+
+	lumpSumShares := parsedAmount * fxRateBuy / buyPrice
+	lumpSumValue := lumpSumShares * sellPrice * fxRateSell
+	dcaValue := dcaShares * sellPrice * fxRateSell
+	dipValue := dipShares*sellPrice*fxRateSell + cashLeft
+
+	lumpSum := gin.H{
+		"buyPrice":                     buyPrice,
+		"shares":                       lumpSumShares,
+		"investedUSD":                  parsedAmount * fxRateBuy,
+		"finalValueUSD":                lumpSumValue / fxRateSell,
+		"finalValueInOriginalCurrency": lumpSumValue,
+	}
+	dca := gin.H{
+		"purchases":                    dcaPurchases,
+		"shares":                       dcaShares,
+		"averagePrice":                 dcaInvestedUSD / dcaShares,
+		"investedUSD":                  dcaInvestedUSD,
+		"finalValueUSD":                dcaValue / fxRateSell,
+		"finalValueInOriginalCurrency": dcaValue,
+	}
+	dip := gin.H{
+		"purchases":                    dipPurchases,
+		"shares":                       dipShares,
+		"investedUSD":                  dipInvestedUSD,
+		"cashLeft":                     cashLeft,
+		"finalValueUSD":                dipValue / fxRateSell,
+		"finalValueInOriginalCurrency": dipValue,
+	}
+	if dipShares > 0 {
+		dip["averagePrice"] = dipInvestedUSD / dipShares
+	}
+
+	// Money-weighted returns in the amount's currency, over the dates it went in.
+	// The dip's cash pile is all there from the buy date, deployed or not.
+	addXIRR(lumpSum, []cashFlow{{buyDate, -parsedAmount}, {sellDate, lumpSumValue}})
+	flows := make([]cashFlow, 0, len(dcaPurchases)+1)
+	for _, purchase := range dcaPurchases {
+		flows = append(flows, cashFlow{purchase.Date, -purchase.Amount})
+	}
+	addXIRR(dca, append(flows, cashFlow{sellDate, dcaValue}))
+	addXIRR(dip, []cashFlow{{buyDate, -parsedAmount}, {sellDate, dipValue}})
+
+	winner := "lumpSum"
+	if dcaValue > lumpSumValue {
+		winner = "dca"
+	}
+	if dipValue > max(lumpSumValue, dcaValue) {
+		winner = "buyTheDip"
+	}
+
+	response := gin.H{
+		"message":       localize(c, "Buy the dip vs lump sum and DCA"),
+		"value":         parsedAmount,
+		"currency":      currency,
+		"ticker":        ticker,
+		"buyDate":       buyDate,
+		"sellDate":      sellDate,
+		"strategy":      dipOpts,
+		"every":         every,
+		"buyTheDip":     dip,
+		"lumpSum":       lumpSum,
+		"dca":           dca,
+		"sellPrice":     sellPrice,
+		"versusLumpSum": dipValue - lumpSumValue,
+		"versusDCA":     dipValue - dcaValue,
+		"winner":        winner,
+		"stockCurrency": "USD",
+		"fxRateBuy":     fxRateBuy,
+		"fxRateSell":    fxRateSell,
+		"type":          typeParam,
+		"priceAt":       priceOpts.At,
+		"priceBasis":    priceOpts.basis(typeParam),
+	}
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCryptoPricing(response, typeParam)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
+}
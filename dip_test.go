@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test tranches buy only on dips below the high since the last tranche
+func TestCalculateDipBuys(t *testing.T) {
+	points := []pricePoint{
+		{Date: "2025-05-30", Price: 120},
+		{Date: "2025-06-02", Price: 100},
+		{Date: "2025-06-03", Price: 108},
+		{Date: "2025-06-04", Price: 99},
+		{Date: "2025-06-05", Price: 110},
+		{Date: "2025-06-06", Price: 98},
+	}
+	usd := func(string) (float64, error) { return 1, nil }
+	opts := dipOptions{DipPercent: 10, Tranches: 3, LookbackDays: 30}
+
+	purchases, shares, investedUSD, cashLeft, err := calculateDipBuys(900, points, "2025-06-01", opts, usd)
+	assert.NoError(t, err)
+	// 100 is 16.7% below the high before the buy date; 99 is only 8.3% below 108;
+	// 98 is 10.9% below 110
+	assert.Len(t, purchases, 2)
+	assert.Equal(t, "2025-06-02", purchases[0].Date)
+	assert.Equal(t, 120.0, purchases[0].High)
+	assert.Equal(t, "2025-06-06", purchases[1].Date)
+	assert.InDelta(t, (1-98/110.0)*100, purchases[1].DropPercent, 1e-9)
+	assert.InDelta(t, 300/100.0+300/98.0, shares, 1e-9)
+	assert.InDelta(t, 600, investedUSD, 1e-9)
+	assert.InDelta(t, 300, cashLeft, 1e-9)
+
+	// Without a dip, the whole pile stays as cash
+	purchases, shares, _, cashLeft, err = calculateDipBuys(900, points[4:5], "2025-06-01", opts, usd)
+	assert.NoError(t, err)
+	assert.Empty(t, purchases)
+	assert.Zero(t, shares)
+	assert.InDelta(t, 900, cashLeft, 1e-9)
+}
+
+// Test the buy-the-dip route against lump sum and DCA on the mocked prices
+func TestBuyTheDipWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip?dip=2&tranches=2&every=quarter")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		BuyTheDip struct {
+			Purchases     []dipPurchase `json:"purchases"`
+			CashLeft      float64       `json:"cashLeft"`
+			FinalValueUSD float64       `json:"finalValueUSD"`
+		} `json:"buyTheDip"`
+		Strategy dipOptions `json:"strategy"`
+		Winner   string     `json:"winner"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// 205.75 on 2025-06-20 is 2.7% below 211.45; nothing dips 2% below it after
+	assert.Len(t, response.BuyTheDip.Purchases, 1)
+	assert.Equal(t, "2025-06-20", response.BuyTheDip.Purchases[0].Date)
+	assert.InDelta(t, 211.45, response.BuyTheDip.Purchases[0].High, 1e-9)
+	assert.InDelta(t, 500, response.BuyTheDip.CashLeft, 1e-9)
+	assert.InDelta(t, 500/205.75*211.18+500, response.BuyTheDip.FinalValueUSD, 1e-9)
+	assert.Equal(t, 90, response.Strategy.LookbackDays)
+	assert.Equal(t, "lumpSum", response.Winner)
+
+	for _, path := range []string{
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip?dip=100",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip?tranches=0",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip?lookback=400",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/buy-the-dip?limitPrice=200",
+	} {
+		w = makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How often a DRIP reinvests dividends: each as it's paid, or swept together at
+// the end of the month or quarter they're paid in
+var dripFrequencies = []string{"dividend", "monthly", "quarterly"}
+
+// Longest settlement lag a DRIP takes, in days
+const maxDRIPLagDays = 60
+
+// Prices a DRIP can reinvest at: the reinvest date's close or open, or the next
+// day's open
+var dripPrices = []string{"close", "open", "nextOpen"}
+
+// How a DRIP backtest reinvests dividends, from ?dripFrequency=, ?dripLag= (days
+// from the payment, or the end of the sweep's period, to the reinvestment),
+// ?dripPrice= (the request's priceAt when empty), the fee on each reinvestment,
+// ?dripFeePercent= of the dividend plus ?dripFeeFixed= USD, and the percent of
+// each dividend reinvested, ?reinvest= (all of it when 0), the rest kept as cash
+type dripOptions struct {
+	Frequency       string  `json:"frequency"`
+	LagDays         int     `json:"lagDays"`
+	Price           string  `json:"price,omitempty"`
+	FeePercent      float64 `json:"feePercent"`
+	FeeFixed        float64 `json:"feeFixed"`
+	ReinvestPercent float64 `json:"reinvestPercent"`
+}
+
+// Helper function to read and validate the DRIP options
+func dripOptionsParam(c *gin.Context) (dripOptions, error) {
+	opts := dripOptions{Frequency: strings.ToLower(c.DefaultQuery("dripFrequency", "dividend")), ReinvestPercent: 100}
+	if !isDRIPFrequency(opts.Frequency) {
+		return opts, fmt.Errorf("Invalid dripFrequency parameter: must be one of %s", strings.Join(dripFrequencies, ", "))
+	}
+	if lag := c.Query("dripLag"); lag != "" {
+		var err error
+		opts.LagDays, err = strconv.Atoi(lag)
+		if err != nil || opts.LagDays < 0 || opts.LagDays > maxDRIPLagDays {
+			return opts, fmt.Errorf("Invalid dripLag parameter: must be a whole number of days from 0 to %d", maxDRIPLagDays)
+		}
+	}
+	if opts.Price = c.Query("dripPrice"); opts.Price != "" && !isDRIPPrice(opts.Price) {
+		return opts, fmt.Errorf("Invalid dripPrice parameter: must be one of %s", strings.Join(dripPrices, ", "))
+	}
+	if fee := c.Query("dripFeePercent"); fee != "" {
+		var err error
+		opts.FeePercent, err = strconv.ParseFloat(fee, 64)
+		if err != nil || opts.FeePercent < 0 || opts.FeePercent >= 100 {
+			return opts, fmt.Errorf("Invalid dripFeePercent parameter: must be a percentage from 0 to under 100")
+		}
+	}
+	if fee := c.Query("dripFeeFixed"); fee != "" {
+		var err error
+		opts.FeeFixed, err = strconv.ParseFloat(fee, 64)
+		if err != nil || opts.FeeFixed < 0 {
+			return opts, fmt.Errorf("Invalid dripFeeFixed parameter: must be a non-negative amount in USD")
+		}
+	}
+	if percent := c.Query("reinvest"); percent != "" {
+		var err error
+		opts.ReinvestPercent, err = strconv.ParseFloat(percent, 64)
+		if err != nil || opts.ReinvestPercent <= 0 || opts.ReinvestPercent > 100 {
+			return opts, fmt.Errorf("Invalid reinvest parameter: must be a percentage above 0 and up to 100 (use with-dividends to keep every dividend as cash)")
+		}
+	}
+	return opts, nil
+}
+
+// Helper function to check a DRIP reinvestment price
+func isDRIPPrice(price string) bool {
+	for _, p := range dripPrices {
+		if price == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to check a DRIP frequency
+func isDRIPFrequency(frequency string) bool {
+	for _, f := range dripFrequencies {
+		if frequency == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to get the date a dividend paid on a date is reinvested: the end
+// of its month or quarter when swept, then the settlement lag later, and the day
+// after that when buying at the next day's open
+func (opts dripOptions) reinvestDate(paymentDate string) (string, error) {
+	day, err := time.Parse("2006-01-02", paymentDate)
+	if err != nil {
+		return "", err
+	}
+	switch opts.Frequency {
+	case "monthly":
+		day = time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	case "quarterly":
+		quarterEnd := (day.Month()-1)/3*3 + 3
+		day = time.Date(day.Year(), quarterEnd+1, 0, 0, 0, 0, 0, time.UTC)
+	}
+	day = day.AddDate(0, 0, opts.LagDays)
+	if opts.Price == "nextOpen" {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.Format("2006-01-02"), nil
+}
+
+// Helper function to get the fee on reinvesting a dividend, in USD
+func (opts dripOptions) fee(amount float64) float64 {
+	return amount*opts.FeePercent/100 + opts.FeeFixed
+}
+
+// Helper function to split a dividend into the part reinvested and the part kept
+// as cash
+func (opts dripOptions) split(amount float64) (float64, float64) {
+	if opts.ReinvestPercent <= 0 || opts.ReinvestPercent >= 100 {
+		return amount, 0
+	}
+	reinvested := amount * opts.ReinvestPercent / 100
+	return reinvested, amount - reinvested
+}
+
+// Helper function to price a DRIP's reinvestments on or after their reinvest date,
+// at the close or open it reinvests at
+func (opts dripOptions) priceOn(ticker, assetType string, priceOpts priceOptions) func(date string) (float64, error) {
+	switch opts.Price {
+	case "close":
+		priceOpts.At = "close"
+	case "open", "nextOpen":
+		priceOpts.At = "open"
+	}
+	return func(date string) (float64, error) {
+		return fetchPriceOnOrAfter(ticker, date, assetType, priceOpts)
+	}
+}
+
+// Helper function to total the fees paid on a DRIP's reinvestments, in USD
+func dripFees(events []dripEvent) float64 {
+	total := 0.0
+	for _, event := range events {
+		total += event.Fee
+	}
+	return total
+}
+
+// Helper function to add "reinvestmentFees", the USD paid in fees on a DRIP's
+// reinvestments, when there were any
+func addDRIPFees(response gin.H, events []dripEvent) {
+	if fees := dripFees(events); fees > 0 {
+		response["reinvestmentFees"] = fees
+	}
+}
+
+// Helper function to add "cashKept", the USD of dividends a partial DRIP kept as
+// cash rather than reinvesting, when it reinvests less than all of each
+func addDRIPCashKept(response gin.H, opts dripOptions, events []dripEvent) {
+	if opts.ReinvestPercent <= 0 || opts.ReinvestPercent >= 100 {
+		return
+	}
+	total := 0.0
+	for _, event := range events {
+		total += event.CashKept
+	}
+	response["cashKept"] = total
+}
+
+// Helper function to get the part of a dividend that bought shares, fee included
+func (e dripEvent) reinvested() float64 {
+	if e.SharesBought <= 0 {
+		return 0
+	}
+	return e.Amount - e.CashKept
+}
+
+// Helper function to get the date a reinvested dividend's shares were bought
+func (e dripEvent) reinvestedOn() string {
+	if e.ReinvestDate != "" {
+		return e.ReinvestDate
+	}
+	return e.PaymentDate
+}
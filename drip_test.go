@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test dividends are reinvested on their payment date, or swept at the end of
+// their month or quarter, plus the settlement lag
+func TestDRIPReinvestDate(t *testing.T) {
+	testCases := []struct {
+		opts     dripOptions
+		paid     string
+		expected string
+	}{
+		{dripOptions{}, "2025-05-15", "2025-05-15"},
+		{dripOptions{Frequency: "dividend"}, "2025-05-15", "2025-05-15"},
+		{dripOptions{Frequency: "dividend", LagDays: 3}, "2025-05-15", "2025-05-18"},
+		{dripOptions{Frequency: "monthly"}, "2025-05-15", "2025-05-31"},
+		{dripOptions{Frequency: "monthly"}, "2024-02-01", "2024-02-29"},
+		{dripOptions{Frequency: "monthly", LagDays: 2}, "2025-12-10", "2026-01-02"},
+		{dripOptions{Frequency: "quarterly"}, "2025-05-15", "2025-06-30"},
+		{dripOptions{Frequency: "quarterly"}, "2025-01-01", "2025-03-31"},
+		{dripOptions{Frequency: "quarterly"}, "2025-11-30", "2025-12-31"},
+	}
+	for _, tc := range testCases {
+		date, err := tc.opts.reinvestDate(tc.paid)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expected, date, "%+v paid %s", tc.opts, tc.paid)
+	}
+}
+
+// Test ?dripFrequency= and ?dripLag= move the reinvestment and its price
+func TestDRIPScheduleWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		Reinvestment dripOptions `json:"reinvestment"`
+		Dividends    []dripEvent `json:"dividends"`
+		DividendCash float64     `json:"dividendCash"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, dripOptions{Frequency: "dividend", ReinvestPercent: 100}, response.Reinvestment)
+	assert.Empty(t, response.Dividends[0].ReinvestDate)
+
+	// Swept at the end of the quarter, at its close
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripFrequency=quarterly")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "quarterly", response.Reinvestment.Frequency)
+	may := response.Dividends[0]
+	assert.Equal(t, "2025-05-15", may.PaymentDate)
+	assert.Equal(t, "2025-06-30", may.ReinvestDate)
+	assert.Equal(t, 205.17, may.Price)
+	assert.InDelta(t, 2.6/205.17, may.SharesBought, 1e-9)
+
+	// Settling 36 days after payment
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripLag=36")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-06-20", response.Dividends[0].ReinvestDate)
+	assert.Equal(t, 205.75, response.Dividends[0].Price)
+
+	// Not reinvested by the sale, so kept as cash
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-06-20/with-drip?dripFrequency=quarterly")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0.0, response.Dividends[0].SharesBought)
+	assert.InDelta(t, 2.6, response.DividendCash, 1e-9)
+
+	for _, query := range []string{"dripFrequency=weekly", "dripLag=61", "dripLag=-1", "dripLag=two"} {
+		w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?"+query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest", "frequency": "quarterly"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"reinvestDate":"2025-06-30"`)
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "dividends": {"mode": "reinvest", "frequency": "daily"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "dividends.frequency must be one of dividend, monthly, quarterly")
+}
+
+// Test ?dripFeePercent=, ?dripFeeFixed= and ?dripPrice= change what each
+// reinvestment buys
+func TestDRIPFeesAndPriceWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	paid := mockStockData["2025-05-15"]
+	mockStockData["2025-05-15"] = map[string]string{"1. open": "210.00", "4. close": "211.45"}
+	mockStockData["2025-05-16"] = map[string]string{"1. open": "212.00", "4. close": "212.50"}
+	t.Cleanup(func() {
+		mockStockData["2025-05-15"] = paid
+		delete(mockStockData, "2025-05-16")
+	})
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		Reinvestment     dripOptions `json:"reinvestment"`
+		Dividends        []dripEvent `json:"dividends"`
+		DividendCash     float64     `json:"dividendCash"`
+		ReinvestmentFees float64     `json:"reinvestmentFees"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripFeePercent=1&dripFeeFixed=0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	may := response.Dividends[0]
+	assert.InDelta(t, 0.126, may.Fee, 1e-9)
+	assert.InDelta(t, (2.6-0.126)/211.45, may.SharesBought, 1e-9)
+	assert.InDelta(t, 0.126, response.ReinvestmentFees, 1e-9)
+	assert.Equal(t, 0.0, response.Dividends[1].Fee)
+
+	// A fee as big as the dividend leaves it as cash
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripFeeFixed=5")
+	assert.Equal(t, http.StatusOK, w.Code)
+	response.ReinvestmentFees = 0
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0.0, response.Dividends[0].SharesBought)
+	assert.InDelta(t, 2.6+10*0.26, response.DividendCash, 1e-9)
+	assert.NotContains(t, w.Body.String(), "reinvestmentFees")
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripPrice=open")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "open", response.Reinvestment.Price)
+	assert.Equal(t, 210.00, response.Dividends[0].Price)
+	assert.Empty(t, response.Dividends[0].ReinvestDate)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?dripPrice=nextOpen")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-05-16", response.Dividends[0].ReinvestDate)
+	assert.Equal(t, 212.00, response.Dividends[0].Price)
+
+	for _, query := range []string{"dripPrice=vwap", "dripFeePercent=100", "dripFeeFixed=-1"} {
+		w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?"+query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	// Scenarios count reinvestment fees with the others
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest", "feeFixed": 0.5}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var scenario struct {
+		Fees map[string]float64 `json:"fees"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &scenario))
+	assert.InDelta(t, 0.5, scenario.Fees["reinvestment"], 1e-9)
+	assert.InDelta(t, 0.5, scenario.Fees["total"], 1e-9)
+}
+
+// Test dividends swept on the same date are reinvested together, paying one fee
+func TestDRIPSweepBatchesDividends(t *testing.T) {
+	dividends := []dividendData{
+		{ExDate: "2025-04-10", PaymentDate: "2025-04-15", Amount: 0.25},
+		{ExDate: "2025-05-12", PaymentDate: "2025-05-15", Amount: 0.26},
+	}
+	var priced []string
+	priceOn := func(date string) (float64, error) {
+		priced = append(priced, date)
+		return 200, nil
+	}
+
+	shares, events, cash, err := calculateDRIP(100, dividends, "2025-07-18", dripOptions{Frequency: "quarterly", FeeFixed: 1}, priceOn)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-06-30"}, priced)
+	assert.InDelta(t, (25+26-1)/200.0, shares, 1e-9)
+	assert.InDelta(t, 1, dripFees(events), 1e-9)
+	assert.InDelta(t, 25.0/51, events[0].Fee, 1e-9)
+	assert.InDelta(t, (25-25.0/51)/200, events[0].SharesBought, 1e-9)
+	assert.InDelta(t, 100.0, events[1].SharesHeld, 1e-9) // the sweep settles after both ex-dates
+	assert.Zero(t, cash)
+
+	// Reinvested as paid, each pays its own fee
+	priced = nil
+	shares, events, _, err = calculateDRIP(100, dividends, "2025-07-18", dripOptions{Frequency: "dividend", FeeFixed: 1}, priceOn)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-04-15", "2025-05-15"}, priced)
+	assert.InDelta(t, 2, dripFees(events), 1e-9)
+	assert.InDelta(t, 100+24/200.0, events[1].SharesHeld, 1e-9)
+	assert.InDelta(t, 24/200.0+(100+24/200.0)*0.26/200-1/200.0, shares, 1e-9)
+}
+
+// Test ?reinvest= reinvests part of each dividend and keeps the rest as cash
+func TestDRIPPartialReinvestWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?reinvest=50&explain=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Reinvestment dripOptions   `json:"reinvestment"`
+		Dividends    []dripEvent   `json:"dividends"`
+		TotalShares  float64       `json:"totalShares"`
+		DividendCash float64       `json:"dividendCash"`
+		CashKept     float64       `json:"cashKept"`
+		FinalValue   float64       `json:"finalValue"`
+		Explain      []explainStep `json:"explain"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 50.0, response.Reinvestment.ReinvestPercent)
+	may := response.Dividends[0]
+	assert.InDelta(t, 1.3/211.45, may.SharesBought, 1e-9)
+	assert.InDelta(t, 1.3, may.CashKept, 1e-9)
+	assert.InDelta(t, 1.3, response.CashKept, 1e-9)
+
+	// The July dividend is paid after the sale, all of it as cash
+	july := (10 + 1.3/211.45) * 0.26
+	assert.InDelta(t, 1.3+july, response.DividendCash, 1e-9)
+	assert.InDelta(t, response.TotalShares*211.18+1.3+july, response.FinalValue, 1e-9)
+	assert.InDelta(t, response.FinalValue, response.Explain[len(response.Explain)-1].Value, 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip")
+	assert.NotContains(t, w.Body.String(), "cashKept")
+	for _, query := range []string{"reinvest=0", "reinvest=101", "reinvest=half"} {
+		w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?"+query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	w = postScenario(router, `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest", "reinvestPercent": 50}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 1.3, response.CashKept, 1e-9)
+	assert.InDelta(t, 1.3+july, response.DividendCash, 1e-9)
+}
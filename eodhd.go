@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EODHD end-of-day price response, oldest first. Only the close has an adjusted
+// value.
+// Example: https://eodhd.com/api/eod/VOD.LSE?from=2025-07-18&to=2025-07-18&period=d&fmt=json&api_token=demo
+type eodhdPrice struct {
+	Date          string  `json:"date"`
+	Open          float64 `json:"open"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Close         float64 `json:"close"`
+	AdjustedClose float64 `json:"adjusted_close"`
+}
+
+// EODHD dividend response, dated on ex-dates; recent ones may have no payment date
+// yet
+// Example: https://eodhd.com/api/div/AAPL.US?from=2025-01-01&fmt=json&api_token=demo
+type eodhdDividend struct {
+	Date        string  `json:"date"`
+	PaymentDate string  `json:"paymentDate"`
+	Value       float64 `json:"value"`
+}
+
+// EODHD's exchange code for each ticker suffix. EODHD has no Tokyo listings.
+var eodhdExchanges = map[string]string{
+	".L": "LSE", ".LON": "LSE", ".DE": "XETRA", ".DEX": "XETRA", ".PA": "PA", ".AS": "AS",
+	".SW": "SW", ".TO": "TO", ".TRT": "TO", ".V": "V", ".AX": "AU", ".HK": "HK",
+	".SS": "SHG", ".SHH": "SHG", ".SZ": "SHE", ".SHZ": "SHE", ".NS": "NSE", ".BO": "BSE",
+	".BSE": "BSE", ".KS": "KO", ".KQ": "KQ",
+}
+
+// Helper function to get EODHD's symbol for a stock: AAPL is AAPL.US, VOD.L is
+// VOD.LSE and BRK.B is BRK-B.US
+func eodhdSymbol(ticker string) (string, error) {
+	ticker = strings.ToUpper(ticker)
+	if i := strings.LastIndex(ticker, "."); i > 0 {
+		if code, ok := eodhdExchanges[ticker[i:]]; ok {
+			return ticker[:i] + "." + code, nil
+		}
+		if _, ok := exchangeSuffixes[ticker[i:]]; ok {
+			return "", fmt.Errorf("EODHD has no stocks on %s", exchangeFor(ticker, "stock").Code)
+		}
+	}
+	return strings.ReplaceAll(ticker, ".", "-") + ".US", nil
+}
+
+// EOD Historical Data's daily prices and dividends, with decades of history on
+// exchanges around the world
+type eodhdProvider struct{}
+
+func (eodhdProvider) Name() string { return "eodhd" }
+
+// Helper function to make an EODHD request for a stock and decode its answer.
+// Errors come back as plain text.
+func eodhdGet(endpoint, ticker string, query url.Values, result interface{}) error {
+	cfg := settings()
+	symbol, err := eodhdSymbol(ticker)
+	if err != nil {
+		return err
+	}
+	query.Set("fmt", "json")
+	query.Set("api_token", cfg.EODHDAPIKey)
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/%s/%s?%s", cfg.EODHDBaseURL, endpoint, url.PathEscape(symbol), query.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		return fmt.Errorf("EODHD returned status %d %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	return nil
+}
+
+// Fetch daily prices between two dates (or the whole history when they are
+// empty) as a series. Adjusted opens, highs and lows are scaled by the day's
+// adjusted-to-raw close ratio, as Alpha Vantage's are.
+func fetchEODHDSeries(ticker, start, end string, adjusted bool) (dailySeries, error) {
+	query := url.Values{"period": {"d"}}
+	if start != "" {
+		query.Set("from", start)
+	}
+	if end != "" {
+		query.Set("to", end)
+	}
+	var prices []eodhdPrice
+	if err := eodhdGet("eod", ticker, query, &prices); err != nil {
+		return nil, err
+	}
+
+	series := dailySeries{}
+	for _, day := range prices {
+		scale := 1.0
+		if adjusted {
+			if day.Close == 0 {
+				continue
+			}
+			scale = day.AdjustedClose / day.Close
+		}
+		series[day.Date] = map[string]float64{
+			"open": day.Open * scale, "high": day.High * scale, "low": day.Low * scale, "close": day.Close * scale,
+		}
+	}
+	return series, nil
+}
+
+func (eodhdProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	series, err := fetchEODHDSeries(ticker, date, date, opts.Adjusted)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := series[date][opts.At]
+	if !ok {
+		return 0, fmt.Errorf("No data for date %s", date)
+	}
+	return price, nil
+}
+
+func (eodhdProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	series, err := fetchEODHDSeries(ticker, start, end, opts.Adjusted)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(series))
+	for date, day := range series {
+		points = append(points, pricePoint{Date: date, Price: day[opts.At]})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
+
+func (eodhdProvider) Dividends(ticker, startDate, endDate string) ([]dividendData, error) {
+	start, end := dateOnly(startDate), dateOnly(endDate)
+	var events []eodhdDividend
+	if err := eodhdGet("div", ticker, url.Values{"from": {start}, "to": {end}}, &events); err != nil {
+		return nil, err
+	}
+
+	var dividends []dividendData
+	for _, event := range events {
+		if event.Date <= start || event.Date > end || event.Value == 0 {
+			continue
+		}
+		paymentDate := event.PaymentDate
+		if _, err := time.Parse("2006-01-02", paymentDate); err != nil {
+			paymentDate = event.Date
+		}
+		dividends = append(dividends, dividendData{ExDate: event.Date, PaymentDate: paymentDate, Amount: event.Value})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate < dividends[j].ExDate })
+	return dividends, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake EOD Historical Data API for AAPL.US with mockStockData's closes,
+// each day opening 1 below its close and adjusted to 99%, and mockDividends, the
+// latest without a payment date yet. Other symbols aren't found.
+func setupMockEODHD(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("api_token") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, "Unauthenticated")
+			return
+		}
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		inRange := func(date string) bool { return (from == "" || date >= from) && (to == "" || date <= to) }
+		switch r.URL.Path {
+		case "/eod/AAPL.US":
+			prices := []eodhdPrice{}
+			for date, day := range mockStockData {
+				if !inRange(date) {
+					continue
+				}
+				var price float64
+				fmt.Sscan(day["4. close"], &price)
+				prices = append(prices, eodhdPrice{Date: date, Open: price - 1, High: price + 1, Low: price - 2, Close: price, AdjustedClose: price * 0.99})
+			}
+			json.NewEncoder(w).Encode(prices)
+		case "/div/AAPL.US":
+			events := []map[string]interface{}{}
+			for i, event := range mockDividends {
+				if !inRange(event["ex_dividend_date"]) {
+					continue
+				}
+				var amount float64
+				fmt.Sscan(event["amount"], &amount)
+				paymentDate := interface{}(event["payment_date"])
+				if i == 0 {
+					paymentDate = nil
+				}
+				events = append(events, map[string]interface{}{"date": event["ex_dividend_date"], "paymentDate": paymentDate, "value": amount})
+			}
+			json.NewEncoder(w).Encode(events)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "Ticker Not Found.")
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.EODHDBaseURL, cfg.EODHDAPIKey = server.URL, "test-key" })
+}
+
+// Test tickers become EODHD symbols on its exchanges
+func TestEODHDSymbol(t *testing.T) {
+	for ticker, want := range map[string]string{"AAPL": "AAPL.US", "brk.b": "BRK-B.US", "VOD.L": "VOD.LSE", "SAP.DEX": "SAP.XETRA", "CBA.AX": "CBA.AU"} {
+		symbol, err := eodhdSymbol(ticker)
+		assert.NoError(t, err)
+		assert.Equal(t, want, symbol, ticker)
+	}
+	_, err := eodhdSymbol("7203.T")
+	assert.EqualError(t, err, "EODHD has no stocks on TSE")
+}
+
+// Test EODHD's prices, as traded and adjusted, and its failures
+func TestEODHDDailyPrice(t *testing.T) {
+	setupMockEODHD(t)
+
+	price, err := eodhdProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+	price, err = eodhdProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.InDelta(t, 210.18*0.99, price, 1e-9)
+
+	_, err = eodhdProvider{}.DailyPrice("AAPL", "2025-07-19", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-19")
+	_, err = eodhdProvider{}.DailyPrice("NOPE", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "EODHD returned status 404 Ticker Not Found.")
+
+	setSettings(t, func(cfg *Config) { cfg.EODHDAPIKey = "wrong" })
+	_, err = eodhdProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "EODHD returned status 401 Unauthenticated")
+}
+
+// Test EODHD's dividends, taken as paid on the ex-date until a payment date is
+// announced
+func TestEODHDDividends(t *testing.T) {
+	setupMockEODHD(t)
+
+	dividends, err := eodhdProvider{}.Dividends("AAPL", "2025-05-12", "2025-08-31")
+	assert.NoError(t, err)
+	assert.Equal(t, []dividendData{
+		{ExDate: "2025-07-14", PaymentDate: "2025-08-14", Amount: 0.26},
+		{ExDate: "2025-08-11", PaymentDate: "2025-08-11", Amount: 0.26},
+	}, dividends)
+}
+
+// Test EODHD answers in the failover chain when the providers before it can't
+func TestEODHDFallback(t *testing.T) {
+	setupMockEODHD(t)
+	setStockProviderOrder(t, "stooq,eodhd")
+
+	price, answer, err := fetchStockDailyPrice("AAPL", "2025-07-18", priceOptions{At: "close", Adjusted: true})
+	assert.NoError(t, err)
+	assert.InDelta(t, 211.18*0.99, price, 1e-9)
+	assert.Equal(t, priceAnswer{Date: "2025-07-18", Provider: "eodhd", Fallback: true}, answer)
+
+	points, err := fetchStockPriceHistory("AAPL", "2025-03-31", "2025-07-18", priceOptions{At: "close", Source: "eodhd"})
+	assert.NoError(t, err)
+	assert.Len(t, points, 6)
+	assert.Equal(t, pricePoint{Date: "2025-03-31", Price: 200.50}, points[0])
+
+	_, _, err = fetchStockDailyPrice("NOPE", "2025-07-18", priceOptions{At: "close", Adjusted: true})
+	assert.ErrorContains(t, err, "eodhd: EODHD returned status 404 Ticker Not Found.")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// An everyday item's price in a given year
+type itemPrice struct {
+	Item     string
+	Plural   string
+	Year     int
+	PriceUSD float64
+}
+
+// A gain or loss counted in an everyday item, at its price when the holding was sold.
+// Count is negative for a loss.
+type equivalent struct {
+	Item      string  `json:"item"`
+	Count     float64 `json:"count"`
+	PriceUSD  float64 `json:"priceUSD"`
+	PriceYear int     `json:"priceYear"`
+}
+
+// Bundled price table
+//
+//go:embed data/equivalents.csv
+var bundledItemPrices []byte
+
+// Item prices in table order, loaded on first use
+var (
+	itemPricesOnce sync.Once
+	itemPrices     []itemPrice
+	itemPricesErr  error
+)
+
+// Load the bundled item prices plus any rows from EQUIVALENTS_PATH
+func loadItemPrices() ([]itemPrice, error) {
+	itemPricesOnce.Do(func() {
+		prices, err := readItemPrices(bytes.NewReader(bundledItemPrices))
+		if err != nil {
+			itemPricesErr = fmt.Errorf("Bundled item prices: %v", err)
+			return
+		}
+
+		if equivalentsPath != "" {
+			file, err := os.Open(equivalentsPath)
+			if err != nil {
+				itemPricesErr = err
+				return
+			}
+			defer file.Close()
+			extra, err := readItemPrices(file)
+			if err != nil {
+				itemPricesErr = fmt.Errorf("Item prices %s: %v", equivalentsPath, err)
+				return
+			}
+			prices = append(prices, extra...)
+		}
+
+		itemPrices = prices
+	})
+	return itemPrices, itemPricesErr
+}
+
+// Parse item,plural,year,price_usd rows
+func readItemPrices(r io.Reader) ([]itemPrice, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+
+	var prices []itemPrice
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return prices, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == "item" {
+			continue
+		}
+
+		year, err := strconv.Atoi(record[2])
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(record[3], 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("Invalid price %q for %s", record[3], record[0])
+		}
+
+		prices = append(prices, itemPrice{Item: record[0], Plural: record[1], Year: year, PriceUSD: price})
+	}
+}
+
+// Count a gain or loss (USD) in each item, at the item's latest price on or before
+// the sell year. Later rows for the same item and year override earlier ones.
+func calculateEquivalents(gainUSD float64, sellYear int, prices []itemPrice) []equivalent {
+	var order []string
+	latest := map[string]itemPrice{}
+	for _, price := range prices {
+		if price.Year > sellYear {
+			continue
+		}
+		current, seen := latest[price.Item]
+		if !seen {
+			order = append(order, price.Item)
+		}
+		if !seen || price.Year >= current.Year {
+			latest[price.Item] = price
+		}
+	}
+
+	equivalents := []equivalent{}
+	for _, item := range order {
+		price := latest[item]
+		count := gainUSD / price.PriceUSD
+		name := price.Plural
+		if count >= -1 && count <= 1 {
+			name = price.Item
+		}
+		equivalents = append(equivalents, equivalent{
+			Item:      name,
+			Count:     count,
+			PriceUSD:  price.PriceUSD,
+			PriceYear: price.Year,
+		})
+	}
+	return equivalents
+}
+
+// Helper function to add an "equivalents" list counting the gain or loss in
+// everyday items priced in the year of the sale
+func addEquivalents(response gin.H, enabled bool, investedUSD, finalValueUSD float64, sellDate string) error {
+	if !enabled {
+		return nil
+	}
+
+	prices, err := loadItemPrices()
+	if err != nil {
+		return err
+	}
+	sellYear, err := strconv.Atoi(dateOnly(sellDate)[:4])
+	if err != nil {
+		return err
+	}
+
+	response["equivalents"] = calculateEquivalents(finalValueUSD-investedUSD, sellYear, prices)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the bundled price table loads
+func TestLoadItemPrices(t *testing.T) {
+	prices, err := loadItemPrices()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, prices)
+
+	_, err = readItemPrices(strings.NewReader("item,plural,year,price_usd\nlatte,lattes,2020,free\n"))
+	assert.Error(t, err)
+}
+
+// Test counting gains and losses in items priced by era
+func TestCalculateEquivalents(t *testing.T) {
+	prices := []itemPrice{
+		{Item: "Big Mac", Plural: "Big Macs", Year: 2000, PriceUSD: 2.50},
+		{Item: "Big Mac", Plural: "Big Macs", Year: 2020, PriceUSD: 5.00},
+		{Item: "year of Netflix", Plural: "years of Netflix", Year: 2011, PriceUSD: 100},
+		{Item: "Big Mac", Plural: "Big Macs", Year: 2020, PriceUSD: 4.00},
+	}
+
+	// Sold in 2005: the 2000 Big Mac price, and no Netflix yet
+	result := calculateEquivalents(25, 2005, prices)
+	assert.Equal(t, []equivalent{{Item: "Big Macs", Count: 10, PriceUSD: 2.50, PriceYear: 2000}}, result)
+
+	// Sold in 2024: the overriding 2020 Big Mac price; a loss counts negative
+	result = calculateEquivalents(-50, 2024, prices)
+	assert.Equal(t, []equivalent{
+		{Item: "Big Macs", Count: -12.5, PriceUSD: 4.00, PriceYear: 2020},
+		{Item: "year of Netflix", Count: -0.5, PriceUSD: 100, PriceYear: 2011},
+	}, result)
+
+	assert.Empty(t, calculateEquivalents(10, 1990, prices))
+}
+
+// Test ?funUnits=true on the buy/sell route
+func TestEquivalentsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?funUnits=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Equivalents []equivalent `json:"equivalents"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Equivalents, 4)
+	for _, item := range response.Equivalents {
+		if item.Item == "Big Macs" {
+			assert.InDelta(t, 10*(211.18-200.50)/5.79, item.Count, 1e-9)
+			assert.Equal(t, 2025, item.PriceYear)
+		}
+	}
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.NotContains(t, w.Body.String(), "equivalents")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // exchange time zones, on hosts without a zoneinfo database
+
+	"github.com/gin-gonic/gin"
+)
+
+// A stock exchange's time zone, the local time of its daily close and the rules
+// for its regular holidays (nil for an exchange that only closes at weekends and
+// on the closures in the dataset)
+type exchange struct {
+	Code        string
+	Location    *time.Location
+	CloseHour   int
+	CloseMinute int
+	Holidays    func(year int) map[string]bool
+}
+
+// Helper function to describe an exchange closing at a local time in a time zone
+func newExchange(code, timezone string, closeHour, closeMinute int, holidays func(year int) map[string]bool) *exchange {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	return &exchange{Code: code, Location: location, CloseHour: closeHour, CloseMinute: closeMinute, Holidays: holidays}
+}
+
+// Exchanges the daily series come from, by code. Tickers without a known suffix
+// trade in New York.
+var exchanges = map[string]*exchange{
+	"NYSE":  {Code: "NYSE", Location: newYork, CloseHour: 16, Holidays: nyseHolidays},
+	"TSE":   newExchange("TSE", "Asia/Tokyo", 15, 30, nil),
+	"LSE":   newExchange("LSE", "Europe/London", 16, 30, lseHolidays),
+	"XETRA": newExchange("XETRA", "Europe/Berlin", 17, 30, xetraHolidays),
+	"EPA":   newExchange("EPA", "Europe/Paris", 17, 30, euronextHolidays),
+	"AMS":   newExchange("AMS", "Europe/Amsterdam", 17, 30, euronextHolidays),
+	"SIX":   newExchange("SIX", "Europe/Zurich", 17, 30, sixHolidays),
+	"TSX":   newExchange("TSX", "America/Toronto", 16, 0, tsxHolidays),
+	"ASX":   newExchange("ASX", "Australia/Sydney", 16, 0, asxHolidays),
+	"HKEX":  newExchange("HKEX", "Asia/Hong_Kong", 16, 0, nil),
+	"SSE":   newExchange("SSE", "Asia/Shanghai", 15, 0, nil),
+	"NSE":   newExchange("NSE", "Asia/Kolkata", 15, 30, nil),
+	"KRX":   newExchange("KRX", "Asia/Seoul", 15, 30, nil),
+}
+
+// Exchanges by ticker suffix, in both the Yahoo (.T) and Alpha Vantage (.LON) styles
+var exchangeSuffixes = map[string]string{
+	".T": "TSE", ".L": "LSE", ".LON": "LSE", ".DE": "XETRA", ".DEX": "XETRA",
+	".PA": "EPA", ".AS": "AMS", ".SW": "SIX", ".TO": "TSX", ".TRT": "TSX", ".V": "TSX",
+	".AX": "ASX", ".HK": "HKEX", ".SS": "SSE", ".SHH": "SSE", ".SZ": "SSE", ".SHZ": "SSE",
+	".NS": "NSE", ".BO": "NSE", ".BSE": "NSE", ".KS": "KRX", ".KQ": "KRX",
+}
+
+// Exchanges of index symbols outside New York, where their constituents trade
+var indexExchanges = map[string]string{
+	"^N225": "TSE", "^FTSE": "LSE", "^GDAXI": "XETRA", "^FCHI": "EPA", "^AEX": "AMS",
+	"^SSMI": "SIX", "^GSPTSE": "TSX", "^AXJO": "ASX", "^HSI": "HKEX", "000001.SS": "SSE",
+	"^NSEI": "NSE", "^BSESN": "NSE", "^KS11": "KRX",
+}
+
+// Helper function to find the exchange an asset's daily closes come from. Crypto
+// trades around the clock on no exchange, so has none.
+func exchangeFor(ticker, assetType string) *exchange {
+	if assetType == "crypto" {
+		return nil
+	}
+	ticker = strings.ToUpper(ticker)
+	if code, ok := indexExchanges[ticker]; ok {
+		return exchanges[code]
+	}
+	if assetType == "stock" {
+		if i := strings.LastIndex(ticker, "."); i > 0 {
+			if code, ok := exchangeSuffixes[ticker[i:]]; ok {
+				return exchanges[code]
+			}
+		}
+	}
+	return exchanges["NYSE"]
+}
+
+// Bundled dataset of one-off exchange closures
+//
+//go:embed data/exchange_holidays.csv
+var bundledExchangeClosures []byte
+
+// One-off closures by exchange code and date, loaded on first use
+var (
+	exchangeClosuresOnce sync.Once
+	exchangeClosures     map[string]map[string]bool
+)
+
+// Load the bundled closures plus any rows from EXCHANGE_HOLIDAYS_PATH. A calendar
+// is needed on every backtest, so a bad file is logged and left out rather than
+// failing them all.
+func loadExchangeClosures() map[string]map[string]bool {
+	exchangeClosuresOnce.Do(func() {
+		closures := map[string]map[string]bool{}
+		if err := readExchangeClosures(bytes.NewReader(bundledExchangeClosures), closures); err != nil {
+			log.Printf("Bundled exchange holidays: %v", err)
+		}
+		if exchangeHolidaysPath != "" {
+			file, err := os.Open(exchangeHolidaysPath)
+			if err == nil {
+				defer file.Close()
+				err = readExchangeClosures(file, closures)
+			}
+			if err != nil {
+				log.Printf("Exchange holidays %s: %v", exchangeHolidaysPath, err)
+			}
+		}
+		exchangeClosures = closures
+	})
+	return exchangeClosures
+}
+
+// Parse date,exchange,name rows into the closures
+func readExchangeClosures(r io.Reader, closures map[string]map[string]bool) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record[0] == "date" {
+			continue
+		}
+
+		if _, err := time.Parse("2006-01-02", record[0]); err != nil {
+			return err
+		}
+		code := strings.ToUpper(record[1])
+		if exchanges[code] == nil {
+			return fmt.Errorf("Unknown exchange %q", record[1])
+		}
+		if closures[code] == nil {
+			closures[code] = map[string]bool{}
+		}
+		closures[code][record[0]] = true
+	}
+}
+
+// Holidays by exchange code and year, built once each
+var exchangeHolidaySets sync.Map
+
+// Helper function to get the days an exchange is closed in a year, besides weekends
+func (e *exchange) holidays(year int) map[string]bool {
+	key := e.Code + " " + strconv.Itoa(year)
+	if holidays, ok := exchangeHolidaySets.Load(key); ok {
+		return holidays.(map[string]bool)
+	}
+	holidays := map[string]bool{}
+	if e.Holidays != nil {
+		holidays = e.Holidays(year)
+	}
+	prefix := strconv.Itoa(year) + "-"
+	for date := range loadExchangeClosures()[e.Code] {
+		if strings.HasPrefix(date, prefix) {
+			holidays[date] = true
+		}
+	}
+	exchangeHolidaySets.Store(key, holidays)
+	return holidays
+}
+
+// Helper function to check whether an exchange trades on a date
+func (e *exchange) isTradingDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !e.holidays(date.Year())[date.Format("2006-01-02")]
+}
+
+// Helper function to get the date of an exchange's most recent close at a moment,
+// in its own time zone: today's once it has closed, otherwise the trading day before
+func (e *exchange) latestClose(now time.Time) string {
+	local := now.In(e.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	if local.Hour()*60+local.Minute() < e.CloseHour*60+e.CloseMinute {
+		day = day.AddDate(0, 0, -1)
+	}
+	for !e.isTradingDay(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day.Format("2006-01-02")
+}
+
+// Dates paths can give instead of YYYY-MM-DD, meaning the asset's most recent close
+var relativeDates = map[string]bool{"today": true, "latest": true}
+
+// Helper function to resolve "today" or "latest" in a request's buyDate and sellDate
+// to the asset's most recent close on its own exchange. A sell on the latest close
+// is a holding still held, whose result moves on with the next close.
+func resolveRelativeDates(c *gin.Context) {
+	for i, param := range c.Params {
+		if (param.Key != "buyDate" && param.Key != "sellDate") || !relativeDates[strings.ToLower(param.Value)] {
+			continue
+		}
+		c.Params[i].Value = latestCloseDate(c.Param("ticker"), assetTypeParam(c), time.Now())
+		if param.Key == "sellDate" {
+			c.Set("stillHeld", true)
+		}
+	}
+}
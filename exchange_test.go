@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test tickers are placed on their exchange by suffix or index symbol
+func TestExchangeFor(t *testing.T) {
+	assert.Equal(t, "NYSE", exchangeFor("AAPL", "stock").Code)
+	assert.Equal(t, "NYSE", exchangeFor("BRK.B", "stock").Code)
+	assert.Equal(t, "TSE", exchangeFor("7203.T", "stock").Code)
+	assert.Equal(t, "LSE", exchangeFor("tsco.lon", "stock").Code)
+	assert.Equal(t, "ASX", exchangeFor("CBA.AX", "stock").Code)
+	assert.Equal(t, "TSE", exchangeFor("^N225", "index").Code)
+	assert.Equal(t, "NYSE", exchangeFor("^GSPC", "index").Code)
+	assert.Equal(t, "NYSE", exchangeFor("WTI", "commodity").Code)
+	assert.Nil(t, exchangeFor("BTC", "crypto"))
+}
+
+// Test the latest close follows each exchange's own clock
+func TestLatestCloseByExchange(t *testing.T) {
+	// 7am UTC on a Friday: Tokyo closed at 6:30am UTC, New York hasn't opened
+	at := time.Date(2025, 7, 18, 7, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2025-07-18", latestCloseDate("7203.T", "stock", at))
+	assert.Equal(t, "2025-07-17", latestCloseDate("AAPL", "stock", at))
+	assert.Equal(t, "2025-07-17", latestCloseDate("VOD.L", "stock", at))
+
+	// London closes at 4:30pm local, 3:30pm UTC in summer
+	assert.Equal(t, "2025-07-18", latestCloseDate("VOD.L", "stock", time.Date(2025, 7, 18, 15, 30, 0, 0, time.UTC)))
+
+	// Monday morning in Sydney is still Sunday in New York
+	at = time.Date(2025, 7, 20, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2025-07-18", latestCloseDate("CBA.AX", "stock", at))
+	assert.Equal(t, "2025-07-18", latestCloseDate("AAPL", "stock", at))
+}
+
+// Test "today" and "latest" resolve to the asset's latest close, as a holding still held
+func TestRelativeDates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation())
+	var sellDate string
+	var stillHeld bool
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", func(c *gin.Context) {
+		sellDate, stillHeld = c.Param("sellDate"), c.GetBool("stillHeld")
+		c.Status(http.StatusOK)
+	})
+
+	for _, ticker := range []string{"AAPL", "7203.T"} {
+		w := makeTestRequest(router, "GET", "/10/"+ticker+"/on/2025-03-31/and-sold-on/latest")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, latestCloseDate(ticker, "stock", time.Now()), sellDate)
+		assert.True(t, stillHeld)
+	}
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/today/and-sold-on/latest")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "must be after buyDate")
+}
+
+// Test loading extra closures from EXCHANGE_HOLIDAYS_PATH
+func TestExchangeHolidaysPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "holidays.csv")
+	err := os.WriteFile(path, []byte("date,exchange,name\n2025-07-16,hkex,Typhoon signal 8\n"), 0o644)
+	assert.NoError(t, err)
+
+	reset := func() {
+		exchangeClosuresOnce = sync.Once{}
+		exchangeHolidaySets.Clear()
+	}
+	originalPath := exchangeHolidaysPath
+	exchangeHolidaysPath = path
+	reset()
+	t.Cleanup(func() {
+		exchangeHolidaysPath = originalPath
+		reset()
+	})
+
+	assert.False(t, exchanges["HKEX"].isTradingDay(time.Date(2025, 7, 16, 0, 0, 0, 0, time.UTC)))
+	// Bundled rows are still present
+	assert.False(t, exchanges["NYSE"].isTradingDay(time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC)))
+
+	// Rows naming an unknown exchange are rejected
+	err = readExchangeClosures(strings.NewReader("2025-07-16,XYZ,Closed\n"), map[string]map[string]bool{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// One step of how a result was worked out, with ?explain=true. Value is the
+// step's result: a price, a rate, an amount of money or a number of shares.
+type explainStep struct {
+	Step        string  `json:"step"` // "price", "fxRate", "fee", "invested", "sharesBought", "reinvestment", "dividend", "corporateActions", "proceeds", "tax" or "finalValue"
+	Date        string  `json:"date,omitempty"`
+	ActualDate  string  `json:"actualDate,omitempty"` // the date the data is for, when it isn't Date
+	Provider    string  `json:"provider,omitempty"`
+	Value       float64 `json:"value"`
+	SharesDelta float64 `json:"sharesDelta,omitempty"`
+	SharesHeld  float64 `json:"sharesHeld,omitempty"`
+	Detail      string  `json:"detail"`
+}
+
+// A holding to explain. Currency is the currency of a value buy, empty for a
+// quantity; fees and tax are in it (USD for quantities). SellDate is empty for a
+// buy.
+type explainedHolding struct {
+	Ticker        string
+	At            string
+	Currency      string
+	Amount        float64
+	BuyDate       string
+	SellDate      string
+	BuyPrice      float64
+	SellPrice     float64
+	FXRateBuy     float64
+	FXRateSell    float64
+	BuyFee        float64
+	SellFee       float64
+	Tax           float64
+	Shares        float64
+	Reinvestments []dripEvent
+	Payments      []cashDividend
+	Actions       *corporateActionsResult
+}
+
+// The steps of one result, looking up where prices and FX rates came from in the
+// request's data notes
+type explanation struct {
+	notes *dataNotes
+	steps []explainStep
+}
+
+// Helper function to find the data source recorded for a price or FX rate
+func (n *dataNotes) find(kind, symbol, date string) (dataSource, bool) {
+	if n == nil {
+		return dataSource{}, false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, s := range n.sources {
+		if s.Kind == kind && strings.EqualFold(s.Symbol, symbol) && s.Date == date {
+			return s, true
+		}
+	}
+	return dataSource{}, false
+}
+
+func (e *explanation) add(step explainStep) {
+	e.steps = append(e.steps, step)
+}
+
+// Add a step for a price, with the date it's for and its provider
+func (e *explanation) price(ticker, at, date string, price float64) {
+	step := explainStep{Step: "price", Date: date, Value: price, Detail: fmt.Sprintf("%s %s on %s: %s USD", strings.ToUpper(ticker), at, date, explainNumber(price))}
+	if source, ok := e.notes.find("price", ticker, date); ok {
+		step.ActualDate, step.Provider = source.ActualDate, source.Provider
+	}
+	e.add(step)
+}
+
+// Add a step for an FX rate, with the date it's for and its provider
+func (e *explanation) fxRate(from, to, date string, rate float64) {
+	date = dateOnly(date)
+	step := explainStep{Step: "fxRate", Date: date, Value: rate, Detail: fmt.Sprintf("1 %s = %s %s on %s", from, explainNumber(rate), to, date)}
+	if source, ok := e.notes.find("fxRate", from+"/"+to, date); ok {
+		step.ActualDate, step.Provider = source.ActualDate, source.Provider
+	}
+	e.add(step)
+}
+
+// Add the steps buying a holding: the price, the FX rate, the buy fee and the
+// shares bought
+func (e *explanation) buy(h explainedHolding) {
+	e.price(h.Ticker, h.At, h.BuyDate, h.BuyPrice)
+	if h.Currency == "" {
+		cost := h.Shares * h.BuyPrice
+		e.add(explainStep{Step: "sharesBought", Date: h.BuyDate, Value: cost, SharesDelta: h.Shares, SharesHeld: h.Shares,
+			Detail: fmt.Sprintf("%s shares × %s USD = %.2f USD", explainNumber(h.Shares), explainNumber(h.BuyPrice), cost)})
+		if h.BuyFee > 0 {
+			e.add(explainStep{Step: "fee", Date: h.BuyDate, Value: h.BuyFee, Detail: fmt.Sprintf("buy fee of %.2f USD paid on top", h.BuyFee)})
+		}
+		return
+	}
+
+	amount := h.Amount
+	if h.BuyFee > 0 {
+		amount -= h.BuyFee
+		e.add(explainStep{Step: "fee", Date: h.BuyDate, Value: h.BuyFee,
+			Detail: fmt.Sprintf("%.2f %s - %.2f %s buy fee = %.2f %s", h.Amount, h.Currency, h.BuyFee, h.Currency, amount, h.Currency)})
+	}
+	if h.Currency != "USD" {
+		e.fxRate(h.Currency, "USD", h.BuyDate, h.FXRateBuy)
+	}
+	investedUSD := amount * h.FXRateBuy
+	e.add(explainStep{Step: "invested", Date: h.BuyDate, Value: investedUSD,
+		Detail: fmt.Sprintf("%.2f %s × %s = %.2f USD", amount, h.Currency, explainNumber(h.FXRateBuy), investedUSD)})
+	e.add(explainStep{Step: "sharesBought", Date: h.BuyDate, Value: h.Shares, SharesDelta: h.Shares, SharesHeld: h.Shares,
+		Detail: fmt.Sprintf("%.2f USD ÷ %s USD = %s shares", investedUSD, explainNumber(h.BuyPrice), explainNumber(h.Shares))})
+}
+
+// Add a step for each dividend, reinvested or paid as cash. Returns the shares
+// held after reinvesting and the cash received, in USD.
+func (e *explanation) dividends(h explainedHolding) (float64, float64) {
+	held, cash := h.Shares, 0.0
+	for _, event := range h.Reinvestments {
+		perShare := 0.0
+		if event.SharesHeld > 0 {
+			perShare = event.Amount / event.SharesHeld
+		}
+		paid := fmt.Sprintf("%s shares held on %s × %s USD = %.2f USD", explainNumber(event.SharesHeld), event.ExDate, explainNumber(perShare), event.Amount)
+		if event.SharesBought <= 0 {
+			cash += event.Amount + event.InterestEarned
+			e.add(explainStep{Step: "dividend", Date: event.PaymentDate, Value: event.Amount + event.InterestEarned, SharesHeld: held,
+				Detail: paid + ", not reinvested by the sale and kept as cash" + explainInterest(event.InterestEarned)})
+			continue
+		}
+		held += event.SharesBought
+		if event.CashKept > 0 {
+			cash += event.CashKept + event.InterestEarned
+			paid += fmt.Sprintf(", keeping %.2f USD as cash", event.CashKept) + explainInterest(event.InterestEarned)
+		}
+		e.add(explainStep{Step: "reinvestment", Date: event.reinvestedOn(), Value: event.Amount, SharesDelta: event.SharesBought, SharesHeld: held,
+			Detail: fmt.Sprintf("%s%s, reinvested at %s USD = %s shares", paid, explainFee(event.Fee), explainNumber(event.Price), explainNumber(event.SharesBought))})
+	}
+	for _, payment := range h.Payments {
+		cash += payment.Amount + payment.InterestEarned
+		e.add(explainStep{Step: "dividend", Date: payment.PaymentDate, Value: payment.Amount + payment.InterestEarned, SharesHeld: held,
+			Detail: fmt.Sprintf("%.2f USD paid as cash", payment.Amount) + explainInterest(payment.InterestEarned)})
+	}
+	return held, cash
+}
+
+// Add the steps selling a holding: its dividends, corporate actions, the price
+// and the proceeds. Returns what the holding came to in USD, cash included.
+func (e *explanation) sell(h explainedHolding) float64 {
+	held, cash := e.dividends(h)
+	actions := h.Actions
+	if actions == nil {
+		actions = &corporateActionsResult{}
+	}
+	if len(actions.Events) > 0 {
+		e.add(explainStep{Step: "corporateActions", Date: h.SellDate, Value: actions.Value,
+			Detail: fmt.Sprintf("%d spin-off or merger events, worth %.2f USD on the sell date (%.2f USD of it cash)", len(actions.Events), actions.Value, actions.Cash)})
+	}
+	e.price(h.Ticker, h.At, h.SellDate, h.SellPrice)
+	proceeds := held * h.SellPrice
+	detail := fmt.Sprintf("%s shares × %s USD", explainNumber(held), explainNumber(h.SellPrice))
+	if actions.Value != 0 {
+		detail += fmt.Sprintf(" + %.2f USD corporate actions", actions.Value)
+	}
+	if cash != 0 {
+		detail += fmt.Sprintf(" + %.2f USD dividend cash", cash)
+	}
+	total := proceeds + actions.Value + cash
+	e.add(explainStep{Step: "proceeds", Date: h.SellDate, Value: total, SharesHeld: held, Detail: fmt.Sprintf("%s = %.2f USD", detail, total)})
+	return total
+}
+
+// Add the steps converting what a holding came to back to its currency, less the
+// sell fee and capital gains tax, ending on its final value
+func (e *explanation) finalValue(h explainedHolding, valueUSD float64) {
+	currency, value := "USD", valueUSD
+	if h.Currency != "" && h.Currency != "USD" {
+		currency, value = h.Currency, valueUSD*h.FXRateSell
+		e.fxRate("USD", currency, h.SellDate, h.FXRateSell)
+		e.add(explainStep{Step: "proceeds", Date: h.SellDate, Value: value,
+			Detail: fmt.Sprintf("%.2f USD × %s = %.2f %s", valueUSD, explainNumber(h.FXRateSell), value, currency)})
+	}
+	if h.SellFee > 0 {
+		e.add(explainStep{Step: "fee", Date: h.SellDate, Value: h.SellFee,
+			Detail: fmt.Sprintf("%.2f %s - %.2f %s sell fee = %.2f %s", value, currency, h.SellFee, currency, value-h.SellFee, currency)})
+		value -= h.SellFee
+	}
+	if h.Tax > 0 {
+		e.add(explainStep{Step: "tax", Date: h.SellDate, Value: h.Tax,
+			Detail: fmt.Sprintf("%.2f %s - %.2f %s capital gains tax = %.2f %s", value, currency, h.Tax, currency, value-h.Tax, currency)})
+		value -= h.Tax
+	}
+	e.add(explainStep{Step: "finalValue", Date: h.SellDate, Value: value, Detail: fmt.Sprintf("%.2f %s", value, currency)})
+}
+
+// Helper function to explain a buy, or a buy and sell, step by step
+func explainHolding(notes *dataNotes, h explainedHolding) []explainStep {
+	e := &explanation{notes: notes}
+	e.buy(h)
+	if h.SellDate != "" {
+		e.finalValue(h, e.sell(h))
+	}
+	return e.steps
+}
+
+// Helper function to explain a scenario step by step: each lot's buy, dividends
+// and proceeds, then the sale of them all
+func explainScenario(notes *dataNotes, lots []scenarioLot, h explainedHolding) []explainStep {
+	e := &explanation{notes: notes}
+	valueUSD := 0.0
+	for _, lot := range lots {
+		l := h
+		l.BuyDate, l.BuyPrice, l.FXRateBuy, l.BuyFee, l.Shares = lot.Date, lot.BuyPrice, lot.FXRateBuy, lot.BuyFee, lot.Shares
+		l.Amount, l.Reinvestments = lot.paid, lot.reinvestments
+		l.Actions = &corporateActionsResult{Value: lot.proceedsUSD - (lot.Shares+lot.ReinvestedShares)*lot.sellPrice}
+		l.Payments, _ = lot.Dividends.([]cashDividend)
+		if _, _, isValue, _ := parseAmount(lot.Amount, ""); !isValue {
+			l.Currency = ""
+		}
+		e.buy(l)
+		valueUSD += e.sell(l)
+	}
+	e.finalValue(h, valueUSD)
+	return e.steps
+}
+
+// Helper function to describe the fee taken off a reinvestment, if any
+func explainFee(fee float64) string {
+	if fee <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" less a %.2f USD fee", fee)
+}
+
+// Helper function to describe the interest earned on cash by the sell date, if any
+func explainInterest(interest float64) string {
+	if interest == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", earning %.2f USD interest by the sell date", interest)
+}
+
+// Helper function to format a price, rate or number of shares without trailing zeros
+func explainNumber(value float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.8f", value), "0"), ".")
+}
+
+// Helper function to add an "explain" block walking through how the result was
+// worked out: the prices and FX rates used, with their dates, every dividend
+// reinvestment with the shares it bought, and the fees
+func addExplanation(response gin.H, enabled bool, notes *dataNotes, h explainedHolding) {
+	if !enabled {
+		return
+	}
+	response["explain"] = explainHolding(notes, h)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test ?explain=true walks a value DRIP backtest from the prices and FX rates to
+// the final value, one reinvestment at a time
+func TestExplainDRIPWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?explain=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Dividends                    []dripEvent   `json:"dividends"`
+		TotalShares                  float64       `json:"totalShares"`
+		FinalValueInOriginalCurrency float64       `json:"finalValueInOriginalCurrency"`
+		Explain                      []explainStep `json:"explain"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	steps := response.Explain
+	if !assert.GreaterOrEqual(t, len(steps), 7) {
+		return
+	}
+
+	assert.Equal(t, "price", steps[0].Step)
+	assert.Equal(t, "2025-03-31", steps[0].Date)
+	assert.Equal(t, 200.50, steps[0].Value)
+	assert.Equal(t, "alphaVantage", steps[0].Provider)
+	assert.Equal(t, "fxRate", steps[1].Step)
+	assert.InDelta(t, 1.08, steps[1].Value, 1e-9)
+	assert.Equal(t, "1 EUR = 1.08 USD on 2025-03-31", steps[1].Detail)
+	assert.Equal(t, "invested", steps[2].Step)
+	assert.InDelta(t, 1080, steps[2].Value, 1e-9)
+	assert.Equal(t, "sharesBought", steps[3].Step)
+	assert.InDelta(t, 1080/200.50, steps[3].SharesDelta, 1e-9)
+
+	// Each reinvestment adds the shares it bought
+	held := steps[3].SharesHeld
+	var reinvestments []explainStep
+	for _, step := range steps {
+		if step.Step == "reinvestment" {
+			held += step.SharesDelta
+			assert.InDelta(t, held, step.SharesHeld, 1e-9)
+			reinvestments = append(reinvestments, step)
+		}
+	}
+	bought := 0
+	for _, event := range response.Dividends {
+		if event.SharesBought > 0 {
+			assert.InDelta(t, event.SharesBought, reinvestments[bought].SharesDelta, 1e-9)
+			bought++
+		}
+	}
+	assert.Equal(t, bought, len(reinvestments))
+	assert.InDelta(t, response.TotalShares, held, 1e-9)
+
+	last := steps[len(steps)-1]
+	assert.Equal(t, "finalValue", last.Step)
+	assert.InDelta(t, response.FinalValueInOriginalCurrency, last.Value, 1e-6)
+	assert.Equal(t, "fxRate", steps[len(steps)-3].Step)
+	assert.Equal(t, "2025-07-18", steps[len(steps)-3].Date)
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip")
+	assert.NotContains(t, w.Body.String(), `"explain"`)
+}
+
+// Test the explanation of a quantity buy/sell and of dividends kept as cash
+func TestExplainQuantityWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		FinalValue float64       `json:"finalValue"`
+		Explain    []explainStep `json:"explain"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?explain=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var names []string
+	for _, step := range response.Explain {
+		names = append(names, step.Step)
+	}
+	assert.Equal(t, []string{"price", "sharesBought", "price", "proceeds", "finalValue"}, names)
+	assert.Equal(t, "10 shares × 200.5 USD = 2005.00 USD", response.Explain[1].Detail)
+	assert.InDelta(t, 10*211.18, response.Explain[4].Value, 1e-9)
+
+	response.Explain = nil
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?explain=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	cash := 0.0
+	for _, step := range response.Explain {
+		if step.Step == "dividend" {
+			cash += step.Value
+		}
+	}
+	assert.Greater(t, cash, 0.0)
+	last := response.Explain[len(response.Explain)-1]
+	assert.InDelta(t, response.FinalValue, last.Value, 1e-9)
+	assert.InDelta(t, 10*211.18+cash, last.Value, 1e-9)
+}
+
+// Test a scenario's explanation deducts the buy and sell fees and the capital
+// gains tax on the way to its final value
+func TestExplainScenarioWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	body := `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "1000EUR"}, {"side": "sell", "date": "2025-07-18"}],
+		"fees": {"percent": 1, "fixed": 2}, "taxes": {"capitalGainsPercent": 25}}`
+	req, _ := http.NewRequest("POST", "/v1/scenario?explain=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Fees struct {
+			Buy  float64 `json:"buy"`
+			Sell float64 `json:"sell"`
+		} `json:"fees"`
+		Taxes struct {
+			CapitalGains float64 `json:"capitalGains"`
+		} `json:"taxes"`
+		FinalValueInOriginalCurrency float64       `json:"finalValueInOriginalCurrency"`
+		Explain                      []explainStep `json:"explain"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var fees []float64
+	tax := 0.0
+	for _, step := range response.Explain {
+		switch step.Step {
+		case "fee":
+			fees = append(fees, step.Value)
+		case "tax":
+			tax = step.Value
+		}
+	}
+	assert.Equal(t, []float64{response.Fees.Buy, response.Fees.Sell}, fees)
+	assert.Equal(t, "1000.00 EUR - 12.00 EUR buy fee = 988.00 EUR", response.Explain[1].Detail)
+	assert.InDelta(t, response.Taxes.CapitalGains, tax, 1e-9)
+	last := response.Explain[len(response.Explain)-1]
+	assert.Equal(t, "finalValue", last.Step)
+	assert.InDelta(t, response.FinalValueInOriginalCurrency, last.Value, 1e-6)
+}
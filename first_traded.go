@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// First dates symbols have prices for, by type and ticker. They don't change, so
+// each is looked up once.
+var (
+	firstTradedMu    sync.Mutex
+	firstTradedDates = map[string]string{}
+)
+
+// Returned for prices asked for before a symbol first traded (its IPO, launch or
+// first quoted day)
+type beforeFirstTradeError struct {
+	Ticker      string
+	Date        string
+	FirstTraded string
+}
+
+func (e *beforeFirstTradeError) Error() string {
+	return fmt.Sprintf("%s has no prices before %s, when it first traded; %s is too early", e.Ticker, e.FirstTraded, e.Date)
+}
+
+// Fetch the first date a symbol has a daily price for, from its full history
+func fetchFirstTradedDate(ticker, assetType string) (string, error) {
+	var series dailySeries
+	var err error
+	if assetType == "index" {
+		// Indices quoted in other currencies than USD aren't warmed, but their history is the same
+		index, ok := marketIndices[ticker]
+		if !ok {
+			return "", fmt.Errorf("Unsupported index %s", ticker)
+		}
+		series, err = fetchStooqSeries(index.StooqSymbol)
+	} else {
+		series, err = fetchWarmSeries(warmTicker{Ticker: ticker, AssetType: assetType})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	first := ""
+	for date := range series {
+		if first == "" || date < first {
+			first = date
+		}
+	}
+	if first == "" {
+		return "", fmt.Errorf("No price history for %s", ticker)
+	}
+	return first, nil
+}
+
+// Helper function to get the first date a symbol traded, if it can be found
+func firstTradedDate(ticker, assetType string) (string, bool) {
+	key := assetType + " " + strings.ToUpper(ticker)
+	firstTradedMu.Lock()
+	first, ok := firstTradedDates[key]
+	firstTradedMu.Unlock()
+	if ok {
+		return first, true
+	}
+
+	first, err := fetchFirstTradedDate(strings.ToUpper(ticker), assetType)
+	if err != nil {
+		return "", false
+	}
+	firstTradedMu.Lock()
+	firstTradedDates[key] = first
+	firstTradedMu.Unlock()
+	return first, true
+}
+
+// Helper function to explain a failed daily price lookup: when the date is before
+// the symbol first traded, say so instead of passing on the provider's error
+func explainMissingPrice(ticker, date, assetType string, err error) error {
+	if hasTimeOfDay(date) || isModelledType(assetType) {
+		return err
+	}
+	if first, ok := firstTradedDate(ticker, assetType); ok && date < first {
+		return &beforeFirstTradeError{Ticker: strings.ToUpper(ticker), Date: date, FirstTraded: first}
+	}
+	return err
+}
+
+// Helper function to rebuild the request's path with one path parameter replaced,
+// keeping its query. On /v1/backtest the parameter's query parameter is replaced.
+func pathWithParam(c *gin.Context, name, value string) string {
+	segments := strings.Split(c.FullPath(), "/")
+	inPath := false
+	for i, segment := range segments {
+		if param, ok := strings.CutPrefix(segment, ":"); ok {
+			if param == name {
+				segments[i] = url.PathEscape(value)
+				inPath = true
+			} else {
+				segments[i] = url.PathEscape(c.Param(param))
+			}
+		}
+	}
+	path := strings.Join(segments, "/")
+	query := c.Request.URL.RawQuery
+	if key, ok := backtestQueryParams[name]; ok && !inPath && c.Request.URL.Query().Has(key) {
+		values := c.Request.URL.Query()
+		values.Set(key, value)
+		query = values.Encode()
+	}
+	if query != "" {
+		path += "?" + query
+	}
+	return path
+}
+
+// Helper function to answer a failed buy price lookup: 400 with the first traded
+// date, and the same request run from then, when the buy date is too early; 500
+// otherwise
+func respondPriceError(c *gin.Context, message string, err error) {
+	var early *beforeFirstTradeError
+	if !errors.As(err, &early) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+		return
+	}
+	response := gin.H{
+		"error":           "Date before first trade",
+		"code":            "BEFORE_FIRST_TRADE",
+		"details":         early.Error(),
+		"ticker":          early.Ticker,
+		"firstTradedDate": early.FirstTraded,
+	}
+	sellDate, hasSell := c.Params.Get("sellDate")
+	if _, ok := c.Params.Get("buyDate"); ok && (!hasSell || dateOnly(sellDate) > early.FirstTraded) {
+		response["suggestedPath"] = pathWithParam(c, "buyDate", early.FirstTraded)
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to forget the first traded dates found by other tests' mocks
+func resetFirstTradedDates(t *testing.T) {
+	reset := func() {
+		firstTradedMu.Lock()
+		firstTradedDates = map[string]string{}
+		firstTradedMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// Test buying before a stock first traded gets its first date and a path from then
+func TestBeforeFirstTrade(t *testing.T) {
+	setupMockAlphaVantage(t)
+	resetFirstTradedDates(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2024-01-02/and-sold-on/2025-07-18?type=stock")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "BEFORE_FIRST_TRADE", body["code"])
+	assert.Equal(t, "AAPL", body["ticker"])
+	assert.Equal(t, "2025-03-31", body["firstTradedDate"])
+	assert.Equal(t, "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock", body["suggestedPath"])
+
+	// No path is suggested when the sell date is before the first trade too
+	w = makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2024-01-02/and-sold-on/2024-06-03?type=stock")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "BEFORE_FIRST_TRADE", body["code"])
+	assert.NotContains(t, w.Body.String(), "suggestedPath")
+
+	// Missing days after the first trade keep the provider's error
+	_, err := fetchPrice("AAPL", "2025-04-05", "stock", priceOptions{At: "close"})
+	assert.EqualError(t, err, "No data for date 2025-04-05")
+}
+
+// Test crypto is checked against its first day on CoinGecko
+func TestBeforeFirstTradeCrypto(t *testing.T) {
+	setupMockCoinGecko(t)
+	resetFirstTradedDates(t)
+
+	_, err := fetchPrice("SOL", "2019-01-02", "crypto", priceOptions{At: "close"})
+	var early *beforeFirstTradeError
+	assert.ErrorAs(t, err, &early)
+	assert.Equal(t, "SOL", early.Ticker)
+	assert.Less(t, "2019-01-02", early.FirstTraded)
+}
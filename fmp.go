@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// FMP daily price history response struct. Only the close has an adjusted value.
+// Example: https://financialmodelingprep.com/api/v3/historical-price-full/AAPL?from=2025-07-18&to=2025-07-18&apikey=demo
+type fmpHistoricalPriceResponse struct {
+	Historical []struct {
+		Date     string  `json:"date"`
+		Open     float64 `json:"open"`
+		High     float64 `json:"high"`
+		Low      float64 `json:"low"`
+		Close    float64 `json:"close"`
+		AdjClose float64 `json:"adjClose"`
+	} `json:"historical"`
+}
+
+// FMP dividend history response struct, each dated on its ex-date
+// Example: https://financialmodelingprep.com/api/v3/historical-price-full/stock_dividend/AAPL?apikey=demo
+type fmpDividendResponse struct {
+	Historical []struct {
+		Date        string  `json:"date"`
+		Dividend    float64 `json:"dividend"`
+		PaymentDate string  `json:"paymentDate"`
+	} `json:"historical"`
+}
+
+// What a company is called, the currency its shares trade in and where
+// Example: https://financialmodelingprep.com/api/v3/profile/AAPL?apikey=demo
+type stockProfile struct {
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	Exchange string `json:"exchange"`
+}
+
+// Financial Modeling Prep's daily prices and dividends, and company profiles
+type fmpProvider struct{}
+
+func (fmpProvider) Name() string { return "fmp" }
+
+// Helper function to make an FMP request and decode its answer
+func fmpGet(path string, query url.Values, result interface{}) error {
+	cfg := settings()
+	query.Set("apikey", cfg.FMPAPIKey)
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/api/v3/%s?%s", cfg.FMPBaseURL, path, query.Encode()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"Error Message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return fmt.Errorf("FMP returned status %d %s", resp.StatusCode, failure.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	return nil
+}
+
+// Fetch daily prices between two dates (or the whole history when they are
+// empty) as a series. Adjusted opens, highs and lows are scaled by the day's
+// adjusted-to-raw close ratio, as Alpha Vantage's are.
+func fetchFMPSeries(ticker, start, end string, adjusted bool) (dailySeries, error) {
+	query := url.Values{}
+	if start != "" {
+		query.Set("from", start)
+	}
+	if end != "" {
+		query.Set("to", end)
+	}
+	var result fmpHistoricalPriceResponse
+	if err := fmpGet("historical-price-full/"+url.PathEscape(ticker), query, &result); err != nil {
+		return nil, err
+	}
+
+	series := dailySeries{}
+	for _, day := range result.Historical {
+		scale := 1.0
+		if adjusted {
+			if day.Close == 0 {
+				continue
+			}
+			scale = day.AdjClose / day.Close
+		}
+		series[day.Date] = map[string]float64{
+			"open": day.Open * scale, "high": day.High * scale, "low": day.Low * scale, "close": day.Close * scale,
+		}
+	}
+	return series, nil
+}
+
+func (fmpProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	series, err := fetchFMPSeries(ticker, date, date, opts.Adjusted)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := series[date][opts.At]
+	if !ok {
+		return 0, fmt.Errorf("No data for date %s", date)
+	}
+	return price, nil
+}
+
+func (fmpProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	series, err := fetchFMPSeries(ticker, start, end, opts.Adjusted)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(series))
+	for date, day := range series {
+		points = append(points, pricePoint{Date: date, Price: day[opts.At]})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
+
+func (fmpProvider) Dividends(ticker, startDate, endDate string) ([]dividendData, error) {
+	var result fmpDividendResponse
+	if err := fmpGet("historical-price-full/stock_dividend/"+url.PathEscape(ticker), url.Values{}, &result); err != nil {
+		return nil, err
+	}
+
+	start, end := dateOnly(startDate), dateOnly(endDate)
+	var dividends []dividendData
+	for _, event := range result.Historical {
+		if event.Date <= start || event.Date > end || event.Dividend == 0 {
+			continue
+		}
+		// Payment dates not yet announced are blank
+		paymentDate := event.PaymentDate
+		if _, err := time.Parse("2006-01-02", paymentDate); err != nil {
+			paymentDate = event.Date
+		}
+		dividends = append(dividends, dividendData{ExDate: event.Date, PaymentDate: paymentDate, Amount: event.Dividend})
+	}
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate < dividends[j].ExDate })
+	return dividends, nil
+}
+
+func (fmpProvider) Profile(ticker string) (stockProfile, error) {
+	var result []struct {
+		CompanyName       string `json:"companyName"`
+		Currency          string `json:"currency"`
+		ExchangeShortName string `json:"exchangeShortName"`
+	}
+	if err := fmpGet("profile/"+url.PathEscape(ticker), url.Values{}, &result); err != nil {
+		return stockProfile{}, err
+	}
+	if len(result) == 0 {
+		return stockProfile{}, fmt.Errorf("No profile for %s", ticker)
+	}
+	return stockProfile{Name: result[0].CompanyName, Currency: result[0].Currency, Exchange: result[0].ExchangeShortName}, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Financial Modeling Prep API for AAPL with mockStockData's closes,
+// each day opening 1 below its close and adjusted to 99%, mockDividends, and a
+// profile. Other tickers have no data.
+func setupMockFMP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apikey") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"Error Message": "Invalid API KEY."}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v3/historical-price-full/AAPL":
+			from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+			historical := []map[string]interface{}{}
+			for date, day := range mockStockData {
+				if (from != "" && date < from) || (to != "" && date > to) {
+					continue
+				}
+				var price float64
+				fmt.Sscan(day["4. close"], &price)
+				historical = append(historical, map[string]interface{}{
+					"date": date, "open": price - 1, "high": price + 1, "low": price - 2, "close": price, "adjClose": price * 0.99,
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"symbol": "AAPL", "historical": historical})
+		case "/api/v3/historical-price-full/stock_dividend/AAPL":
+			historical := []map[string]interface{}{}
+			for _, event := range mockDividends {
+				var amount float64
+				fmt.Sscan(event["amount"], &amount)
+				historical = append(historical, map[string]interface{}{
+					"date": event["ex_dividend_date"], "dividend": amount, "adjDividend": amount, "paymentDate": event["payment_date"],
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"symbol": "AAPL", "historical": historical})
+		case "/api/v3/profile/AAPL":
+			fmt.Fprint(w, `[{"symbol": "AAPL", "companyName": "Apple Inc.", "currency": "USD", "exchange": "NASDAQ Global Select", "exchangeShortName": "NASDAQ"}]`)
+		default:
+			if strings.HasPrefix(r.URL.Path, "/api/v3/profile/") {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.FMPBaseURL, cfg.FMPAPIKey = server.URL, "test-key" })
+}
+
+// Test FMP's prices, as traded and adjusted
+func TestFMPDailyPrice(t *testing.T) {
+	setupMockFMP(t)
+
+	price, err := fmpProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+	price, err = fmpProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.InDelta(t, 210.18*0.99, price, 1e-9)
+
+	_, err = fmpProvider{}.DailyPrice("NOPE", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-18")
+
+	points, err := fmpProvider{}.History("AAPL", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Len(t, points, 6)
+	assert.Equal(t, pricePoint{Date: "2025-03-31", Price: 200.50}, points[0])
+
+	setSettings(t, func(cfg *Config) { cfg.FMPAPIKey = "wrong" })
+	_, err = fmpProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "FMP returned status 401 Invalid API KEY.")
+}
+
+// Test FMP's dividends keep their payment dates
+func TestFMPDividends(t *testing.T) {
+	setupMockFMP(t)
+
+	dividends, err := fmpProvider{}.Dividends("AAPL", "2025-02-10", "2025-07-18")
+	assert.NoError(t, err)
+	assert.Equal(t, []dividendData{
+		{ExDate: "2025-05-12", PaymentDate: "2025-05-15", Amount: 0.26},
+		{ExDate: "2025-07-14", PaymentDate: "2025-08-14", Amount: 0.26},
+	}, dividends)
+}
+
+// Test company profiles come from the first provider that has them
+func TestFetchStockProfile(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setStockProviderOrder(t, "alphaVantage,fmp")
+
+	_, err := fetchStockProfile("AAPL")
+	assert.ErrorContains(t, err, "No stock provider with company profiles is configured")
+
+	setupMockFMP(t)
+	profile, err := fetchStockProfile("AAPL")
+	assert.NoError(t, err)
+	assert.Equal(t, stockProfile{Name: "Apple Inc.", Currency: "USD", Exchange: "NASDAQ"}, profile)
+
+	_, err = fetchStockProfile("NOPE")
+	assert.ErrorContains(t, err, "No profile for NOPE")
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Response formats besides JSON, and their content types
+var outputFormats = map[string]string{
+	"text":     "text/plain; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"html":     "text/html; charset=utf-8",
+}
+
+// How a locale writes numbers and money. Spaces are non-breaking.
+type numberFormat struct {
+	Decimal     string
+	Group       string
+	SymbolAfter bool // "1.234,56 €" rather than "€1,234.56"
+	SymbolSpace bool // "€ 1.234,56" when the symbol comes first
+	PercentSign string
+}
+
+// Number formats by language, or language and region where they differ
+var numberFormats = map[string]numberFormat{
+	"en":    {Decimal: ".", Group: ",", PercentSign: "%"},
+	"ja":    {Decimal: ".", Group: ",", PercentSign: "%"},
+	"zh":    {Decimal: ".", Group: ",", PercentSign: "%"},
+	"de":    {Decimal: ",", Group: ".", SymbolAfter: true, PercentSign: "\u00a0%"},
+	"de-CH": {Decimal: ".", Group: "’", SymbolSpace: true, PercentSign: "%"},
+	"fr":    {Decimal: ",", Group: "\u202f", SymbolAfter: true, PercentSign: "\u202f%"},
+	"es":    {Decimal: ",", Group: ".", SymbolAfter: true, PercentSign: "\u00a0%"},
+	"it":    {Decimal: ",", Group: ".", SymbolAfter: true, PercentSign: "%"},
+	"pt":    {Decimal: ",", Group: "\u00a0", SymbolAfter: true, PercentSign: "%"},
+	"pt-BR": {Decimal: ",", Group: ".", SymbolSpace: true, PercentSign: "%"},
+	"nl":    {Decimal: ",", Group: ".", SymbolSpace: true, PercentSign: "%"},
+}
+
+// Symbols shown for currencies; others are shown by their code
+var displaySymbols = map[string]string{
+	"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "CN¥", "INR": "₹",
+	"KRW": "₩", "TRY": "₺", "RUB": "₽", "ILS": "₪", "PHP": "₱", "THB": "฿",
+	"CAD": "CA$", "AUD": "A$", "NZD": "NZ$", "HKD": "HK$", "MXN": "MX$", "BRL": "R$",
+	"BTC": "₿",
+}
+
+// Helper function to find the number format for a locale like "de", "de-CH" or
+// "pt_BR", falling back to the language and then to English
+func lookupNumberFormat(locale string) numberFormat {
+	parts := strings.FieldsFunc(locale, func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 {
+		return numberFormats["en"]
+	}
+	language := strings.ToLower(parts[0])
+	if len(parts) > 1 {
+		if format, ok := numberFormats[language+"-"+strings.ToUpper(parts[1])]; ok {
+			return format
+		}
+	}
+	if format, ok := numberFormats[language]; ok {
+		return format
+	}
+	return numberFormats["en"]
+}
+
+// Format a number with the locale's separators, to the given decimals (-1 for
+// as many as needed)
+func formatNumber(value float64, decimals int, format numberFormat) string {
+	digits := strconv.FormatFloat(math.Abs(value), 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.Group)
+		}
+		grouped.WriteRune(digit)
+	}
+
+	result := grouped.String()
+	if fraction != "" {
+		result += format.Decimal + fraction
+	}
+	if value < 0 && strings.Trim(digits, "0.") != "" {
+		result = "-" + result
+	}
+	return result
+}
+
+// Format an amount of money the way a locale writes it: "$1,234.56" in English,
+// "1.234,56 €" in German, to the currency's minor unit (no decimals for JPY)
+func formatMoney(amount float64, currency, locale string) string {
+	format := lookupNumberFormat(locale)
+	number := formatNumber(amount, currencyDecimals(currency), format)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+
+	symbol, ok := displaySymbols[currency]
+	if !ok {
+		symbol = currency
+	}
+	switch {
+	case format.SymbolAfter:
+		return sign + number + "\u00a0" + symbol
+	case format.SymbolSpace || !ok:
+		return sign + symbol + "\u00a0" + number
+	}
+	return sign + symbol + number
+}
+
+// Format a percentage the way a locale writes it: "12.34%" or "12,34 %"
+func formatPercent(value float64, locale string) string {
+	format := lookupNumberFormat(locale)
+	return formatNumber(value, 2, format) + format.PercentSign
+}
+
+// Helper function to format a quantity with up to quantityDecimals decimals
+func formatQuantity(value float64, locale string) string {
+	format := lookupNumberFormat(locale)
+	number := formatNumber(value, quantityDecimals, format)
+	if strings.Contains(number, format.Decimal) {
+		number = strings.TrimRight(strings.TrimRight(number, "0"), format.Decimal)
+	}
+	return number
+}
+
+// Helper function to turn a field name like "finalValueUSD" into "Final value USD"
+func humanizeField(field string) string {
+	runes := []rune(field)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		upper, prevUpper := unicode.IsUpper(runes[i]), unicode.IsUpper(runes[i-1])
+		nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if upper && (!prevUpper || nextLower) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	for i, word := range words {
+		if strings.ToUpper(word) != word {
+			words[i] = strings.ToLower(word)
+		}
+	}
+	if len(words) > 0 {
+		first := []rune(words[0])
+		first[0] = unicode.ToUpper(first[0])
+		words[0] = string(first)
+	}
+	return strings.Join(words, " ")
+}
+
+// One labelled value of a human-readable response
+type formattedField struct {
+	Label string
+	Value string
+}
+
+// A titled group of values: the response's top-level fields, or a nested object
+type formattedSection struct {
+	Title  string
+	Fields []formattedField
+}
+
+// Helper function to format a response value for people, using the response's
+// currencies for money fields. Lists are left to the JSON format.
+func formatValue(value interface{}, parent, field string, currencies map[fieldKind]string, locale string) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "–", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		switch kind := classifyField(parent, field); kind {
+		case originalMoneyField, reportMoneyField, usdMoneyField:
+			return formatMoney(v, currencies[kind], locale), true
+		case percentField:
+			return formatPercent(v, locale), true
+		case quantityField:
+			return formatQuantity(v, locale), true
+		}
+		return formatNumber(v, -1, lookupNumberFormat(locale)), true
+	}
+	return "", false
+}
+
+// Lay a decoded JSON response out as sections of formatted values labelled in the
+// given language: the top-level fields first, then one section per nested object,
+// both in field name order
+func formatResponse(response map[string]interface{}, language, locale string) (string, []formattedSection) {
+	currency, _ := response["currency"].(string)
+	reportCurrency, _ := response["reportCurrency"].(string)
+	if currency == "" {
+		currency = "USD"
+	}
+	currencies := map[fieldKind]string{originalMoneyField: currency, reportMoneyField: reportCurrency, usdMoneyField: "USD"}
+
+	title, _ := response["message"].(string)
+	if errorMessage, ok := response["error"].(string); ok {
+		title = textWord(language, "error") + ": " + errorMessage
+	}
+
+	sections := []formattedSection{{}}
+	for _, key := range sortedKeys(response) {
+		if key == "message" || key == "error" {
+			continue
+		}
+		if nested, ok := response[key].(map[string]interface{}); ok {
+			section := formattedSection{Title: fieldLabel(language, key)}
+			for _, nestedKey := range sortedKeys(nested) {
+				if value, ok := formatValue(nested[nestedKey], key, nestedKey, currencies, locale); ok {
+					section.Fields = append(section.Fields, formattedField{Label: fieldLabel(language, nestedKey), Value: value})
+				}
+			}
+			if len(section.Fields) > 0 {
+				sections = append(sections, section)
+			}
+			continue
+		}
+		if value, ok := formatValue(response[key], "", key, currencies, locale); ok {
+			sections[0].Fields = append(sections[0].Fields, formattedField{Label: fieldLabel(language, key), Value: value})
+		}
+	}
+	return title, sections
+}
+
+// Helper function to list a map's keys in order
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Render a decoded JSON response as plain text, markdown or HTML
+func renderResponse(response map[string]interface{}, format, language, locale string) string {
+	title, sections := formatResponse(response, language, locale)
+	var out strings.Builder
+
+	switch format {
+	case "text":
+		out.WriteString(title + "\n")
+		for _, section := range sections {
+			indent := ""
+			if section.Title != "" {
+				out.WriteString("\n" + section.Title + "\n")
+				indent = "  "
+			} else if len(section.Fields) > 0 {
+				out.WriteString("\n")
+			}
+			for _, field := range section.Fields {
+				out.WriteString(indent + field.Label + ": " + field.Value + "\n")
+			}
+		}
+	case "markdown":
+		out.WriteString("# " + title + "\n")
+		for _, section := range sections {
+			if section.Title != "" {
+				out.WriteString("\n## " + section.Title + "\n")
+			}
+			if len(section.Fields) == 0 {
+				continue
+			}
+			out.WriteString("\n| " + textWord(language, "field") + " | " + textWord(language, "value") + " |\n| --- | --- |\n")
+			for _, field := range section.Fields {
+				out.WriteString("| " + field.Label + " | " + strings.ReplaceAll(field.Value, "|", "\\|") + " |\n")
+			}
+		}
+	case "html":
+		fmt.Fprintf(&out, "<!DOCTYPE html>\n<html lang=\"%s\">\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<h1>%s</h1>\n", language, html.EscapeString(title), html.EscapeString(title))
+		for _, section := range sections {
+			if section.Title != "" {
+				fmt.Fprintf(&out, "<h2>%s</h2>\n", html.EscapeString(section.Title))
+			}
+			if len(section.Fields) == 0 {
+				continue
+			}
+			out.WriteString("<table>\n")
+			for _, field := range section.Fields {
+				fmt.Fprintf(&out, "<tr><th>%s</th><td>%s</td></tr>\n", html.EscapeString(field.Label), html.EscapeString(field.Value))
+			}
+			out.WriteString("</table>\n")
+		}
+		out.WriteString("</body>\n</html>\n")
+	}
+	return out.String()
+}
+
+// Middleware rendering JSON responses as text, markdown or HTML for ?format=,
+// labelled in the request's language and with money and numbers written the way
+// its locale does (English by default)
+func withFormat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := c.DefaultQuery("format", "json")
+		contentType, ok := outputFormats[format]
+		if format == "json" {
+			c.Next()
+			return
+		}
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid format parameter: must be json, text, markdown or html"})
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		var response map[string]interface{}
+		if !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") || json.NewDecoder(bytes.NewReader(body)).Decode(&response) != nil {
+			c.Writer.Write(body)
+			return
+		}
+
+		c.Writer.Header().Set("Content-Type", contentType)
+		c.Writer.WriteString(renderResponse(response, format, requestLanguage(c), requestLocale(c)))
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test money formatting per currency and locale
+func TestFormatMoney(t *testing.T) {
+	testCases := []struct {
+		amount   float64
+		currency string
+		locale   string
+		expected string
+	}{
+		{1234.56, "USD", "en", "$1,234.56"},
+		{1234.56, "EUR", "de", "1.234,56\u00a0€"},
+		{1234.56, "EUR", "de-DE", "1.234,56\u00a0€"},
+		{1234.56, "EUR", "fr", "1\u202f234,56\u00a0€"},
+		{1234.56, "EUR", "nl", "€\u00a01.234,56"},
+		{1234.56, "CHF", "de-CH", "CHF\u00a01’234.56"},
+		{1234.56, "BRL", "pt_BR", "R$\u00a01.234,56"},
+		{1234.56, "JPY", "en", "¥1,235"},
+		{1234.56, "KWD", "en", "KWD\u00a01,234.560"},
+		{-1234.5, "USD", "en", "-$1,234.50"},
+		{-0.001, "USD", "en", "$0.00"},
+		{1234567.891, "GBP", "xx", "£1,234,567.89"},
+		{0.5, "BTC", "en", "₿0.50000000"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.currency+"_"+tc.locale, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatMoney(tc.amount, tc.currency, tc.locale))
+		})
+	}
+}
+
+// Test percentages, quantities and field labels
+func TestFormatHelpers(t *testing.T) {
+	assert.Equal(t, "12.50%", formatPercent(12.5, "en"))
+	assert.Equal(t, "12,50\u00a0%", formatPercent(12.5, "de"))
+	assert.Equal(t, "-3,25\u202f%", formatPercent(-3.25, "fr"))
+	assert.Equal(t, "3.34164589", formatQuantity(3.341645885, "en"))
+	assert.Equal(t, "1.000", formatQuantity(1000, "de"))
+	assert.Equal(t, "0,5", formatQuantity(0.5, "de"))
+
+	assert.Equal(t, "Final value USD", humanizeField("finalValueUSD"))
+	assert.Equal(t, "Final value in original currency", humanizeField("finalValueInOriginalCurrency"))
+	assert.Equal(t, "Fx rate buy", humanizeField("fxRateBuy"))
+	assert.Equal(t, "Ticker", humanizeField("ticker"))
+}
+
+// Test ?format= rendering of buy/sell responses
+func TestFormatWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withFormat(), withRounding())
+	router.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	// Euros in German
+	w := makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?format=text&locale=de")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "Backtest result (value buy/sell)\n")
+	assert.Contains(t, body, "Value: 1.000,00\u00a0€\n")
+	assert.Contains(t, body, "Ticker: AAPL\n")
+	assert.Contains(t, body, "\nReturns\n")
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?format=markdown")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "# Backtest result (value buy/sell)\n")
+	assert.Contains(t, w.Body.String(), "| Value | €1,000.00 |\n")
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?format=html")
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "<tr><th>Value</th><td>€1,000.00</td></tr>")
+
+	// Errors keep their status
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?format=text&type=nope")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Error: Invalid type parameter")
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?format=pdf")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid format parameter")
+}
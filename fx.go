@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Returned when no FX provider publishes rates for a currency code
+var errUnknownCurrency = errors.New("currency not published by any FX provider")
+
+// A source of historical fiat exchange rates
+type fxProvider interface {
+	Name() string
+	// Supports reports whether the provider publishes rates between two codes
+	Supports(fromCurrency, toCurrency string) (bool, error)
+	// Rate returns how many units of toCurrency one fromCurrency bought on date, and
+	// the date the rate is for (the last one published on or before date)
+	Rate(fromCurrency, toCurrency, date string) (float64, string, error)
+}
+
+// FX providers in the order they are tried: Frankfurter (ECB reference rates
+// from 1999) first, then the fallback provider when an API key is configured,
+// then fixed legacy conversions and the bundled historical dataset
+func fxProviders() []fxProvider {
+	providers := []fxProvider{frankfurterProvider{}}
+	if settings().FXFallbackAPIKey != "" {
+		providers = append(providers, exchangeRateHostProvider{})
+	}
+	return append(providers, legacyCurrencyProvider{}, datasetProvider{})
+}
+
+// Fetch historical FX rates for fiat, stablecoin and crypto codes.
+// Stablecoins are read as their peg, crypto goes to CoinGecko, fiat to the FX
+// providers, and codes no FX provider publishes fall through to the crypto provider.
+// Today's rates are kept fresh by the background refresher.
+func getHistoricalFXRate(fromCurrency, toCurrency, date string) (float64, error) {
+	rate, _, err := getHistoricalFXRateSource(fromCurrency, toCurrency, date)
+	return rate, err
+}
+
+// Where today's FX rates in the latest data cache came from, by cache key
+var (
+	latestFXSourcesMu sync.Mutex
+	latestFXSources   = map[string]dataSource{}
+)
+
+// Fetch a historical FX rate like getHistoricalFXRate, with where it came from.
+// Rates that need no provider (same currency, pegged stablecoins) have no provider.
+func getHistoricalFXRateSource(fromCurrency, toCurrency, date string) (float64, dataSource, error) {
+	// FX rates are daily; ignore any time of day
+	date = dateOnly(date)
+	fromCurrency, toCurrency = pegStablecoin(fromCurrency), pegStablecoin(toCurrency)
+	if fromCurrency == toCurrency {
+		return 1, dataSource{}, nil
+	}
+	if date != time.Now().UTC().Format("2006-01-02") {
+		return fetchFXRate(fromCurrency, toCurrency, date)
+	}
+
+	key := "fx " + fromCurrency + " " + toCurrency
+	start := time.Now().UTC()
+	rate, _, err := latest.lookup(key, date, func() (float64, string, error) {
+		rate, source, err := fetchFXRate(fromCurrency, toCurrency, date)
+		if err == nil {
+			latestFXSourcesMu.Lock()
+			latestFXSources[key] = source
+			latestFXSourcesMu.Unlock()
+		}
+		return rate, date, err
+	})
+	if err != nil {
+		return 0, dataSource{}, err
+	}
+	latestFXSourcesMu.Lock()
+	source := latestFXSources[key]
+	latestFXSourcesMu.Unlock()
+	source.RetrievedAt, source.Stale = latest.fetchedAt(key, start)
+	source.Cached = source.RetrievedAt.Before(start)
+	return rate, source, nil
+}
+
+// Fetch the rate between two different currencies from the providers that know them
+func fetchFXRate(fromCurrency, toCurrency, date string) (float64, dataSource, error) {
+	source := dataSource{Kind: "fxRate", Symbol: fromCurrency + "/" + toCurrency, Date: date, Provider: "coinGecko", RetrievedAt: time.Now().UTC()}
+
+	// FX providers only know fiat currencies; price crypto via CoinGecko
+	if isCryptoCurrency(fromCurrency) || isCryptoCurrency(toCurrency) {
+		rate, err := getCryptoCrossRate(fromCurrency, toCurrency, date)
+		return rate, source, err
+	}
+
+	rate, provider, err := fetchFiatRate(fromCurrency, toCurrency, date)
+	if errors.Is(err, errUnknownCurrency) {
+		rate, err := getCryptoCrossRate(fromCurrency, toCurrency, date)
+		return rate, source, err
+	}
+	source.Provider, source.ActualDate, source.Fallback = provider.Name, provider.Date, provider.Fallback
+	if !provider.CachedAt.IsZero() {
+		source.Cached, source.RetrievedAt = true, provider.CachedAt
+	}
+	return rate, source, err
+}
+
+// The FX provider a rate came from, the date its rate is for when that isn't the
+// one asked for, whether it came after a provider that was tried first, and when
+// it was fetched if it came from the cache
+type fxProviderUsed struct {
+	Name     string
+	Date     string
+	Fallback bool
+	CachedAt time.Time
+}
+
+// A past fiat rate already fetched, with where it came from
+type cachedFXRate struct {
+	Rate float64
+	Used fxProviderUsed
+}
+
+// Past fiat rates fetched from providers, by currency pair and date, so schedules
+// converting on many dates (DCA purchases, portfolio contributions) fetch each
+// one once. Providers don't revise past rates; like the price cache it holds up
+// to PRICE_CACHE_SIZE of them.
+var (
+	pastFXRatesMu sync.Mutex
+	pastFXRates   = map[string]cachedFXRate{}
+)
+
+// Fetch a fiat rate, from the cache when it's for a day before today (UTC) and
+// was fetched before
+func fetchFiatRate(fromCurrency, toCurrency, date string) (float64, fxProviderUsed, error) {
+	key := fromCurrency + " " + toCurrency + " " + date
+	if date >= time.Now().UTC().Format("2006-01-02") {
+		return fetchFiatRateFromProviders(fromCurrency, toCurrency, date)
+	}
+	pastFXRatesMu.Lock()
+	cached, ok := pastFXRates[key]
+	pastFXRatesMu.Unlock()
+	if ok {
+		return cached.Rate, cached.Used, nil
+	}
+
+	rate, used, err := fetchFiatRateFromProviders(fromCurrency, toCurrency, date)
+	size := settings().PriceCacheSize
+	if err != nil || size == 0 {
+		return rate, used, err
+	}
+	pastFXRatesMu.Lock()
+	defer pastFXRatesMu.Unlock()
+	for k := range pastFXRates {
+		if len(pastFXRates) < size {
+			break
+		}
+		delete(pastFXRates, k)
+	}
+	cached = cachedFXRate{Rate: rate, Used: used}
+	cached.Used.CachedAt = time.Now().UTC()
+	pastFXRates[key] = cached
+	return rate, used, nil
+}
+
+// Fetch a fiat rate from the first provider that publishes both currencies and
+// has a rate for the date, e.g. falling back for exotic currencies or pre-1999 dates
+func fetchFiatRateFromProviders(fromCurrency, toCurrency, date string) (float64, fxProviderUsed, error) {
+	var failures []string
+	for i, provider := range fxProviders() {
+		known, err := provider.Supports(fromCurrency, toCurrency)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+			continue
+		}
+		if !known {
+			continue
+		}
+
+		rate, rateDate, err := provider.Rate(fromCurrency, toCurrency, date)
+		if err == nil {
+			used := fxProviderUsed{Name: provider.Name(), Fallback: i > 0}
+			if rateDate != date {
+				used.Date = rateDate
+			}
+			return rate, used, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+	}
+
+	if len(failures) == 0 {
+		return 0, fxProviderUsed{}, fmt.Errorf("%s to %s: %w", fromCurrency, toCurrency, errUnknownCurrency)
+	}
+	return 0, fxProviderUsed{}, fmt.Errorf("No FX rate for %s to %s on %s (%s)", fromCurrency, toCurrency, date, strings.Join(failures, "; "))
+}
+
+// Currency codes a provider publishes, fetched on first use
+type currencyList struct {
+	mu    sync.Mutex
+	codes map[string]bool
+}
+
+// Check all codes are in the list, loading it with fetch if needed
+func (l *currencyList) contains(fetch func() (map[string]bool, error), codes ...string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.codes == nil {
+		fetched, err := fetch()
+		if err != nil {
+			return false, err
+		}
+		l.codes = fetched
+	}
+	for _, code := range codes {
+		if !l.codes[code] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Frankfurter exchange rate response struct
+type frankfurterResponse struct {
+	Amount float64            `json:"amount"`
+	Base   string             `json:"base"`
+	Date   string             `json:"date"`
+	Rates  map[string]float64 `json:"rates"`
+}
+
+// Frankfurter provider (free, no API key required)
+type frankfurterProvider struct{}
+
+var frankfurterCurrencies currencyList
+
+func (frankfurterProvider) Name() string { return "frankfurter" }
+
+func (frankfurterProvider) Supports(fromCurrency, toCurrency string) (bool, error) {
+	return frankfurterCurrencies.contains(func() (map[string]bool, error) {
+		resp, err := outboundClient.Get(settings().FrankfurterBaseURL + "/currencies")
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		var currencies map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&currencies); err != nil {
+			return nil, fmt.Errorf("Failed to read Frankfurter currencies: %v", err)
+		}
+
+		codes := map[string]bool{}
+		for code := range currencies {
+			codes[code] = true
+		}
+		return codes, nil
+	}, fromCurrency, toCurrency)
+}
+
+func (frankfurterProvider) Rate(fromCurrency, toCurrency, date string) (float64, string, error) {
+	// Frankfurter format: https://api.frankfurter.app/2020-01-01?from=EUR&to=USD
+	url := fmt.Sprintf("%s/%s?from=%s&to=%s", settings().FrankfurterBaseURL, date, fromCurrency, toCurrency)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var result frankfurterResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", err
+	}
+
+	if result.Rates == nil {
+		return 0, "", fmt.Errorf("No rates returned from Frankfurter")
+	}
+
+	rate, ok := result.Rates[toCurrency]
+	if !ok {
+		return 0, "", fmt.Errorf("No rate found for %s to %s on %s", fromCurrency, toCurrency, date)
+	}
+
+	// Weekends and holidays get the last working day's reference rate
+	if result.Date == "" {
+		result.Date = date
+	}
+	return rate, result.Date, nil
+}
+
+// exchangerate.host historical response struct, quotes keyed like "EURUSD"
+// Example: https://api.exchangerate.host/historical?date=1995-01-02&source=ITL&currencies=USD&access_key=...
+type exchangeRateHostResponse struct {
+	Success bool               `json:"success"`
+	Quotes  map[string]float64 `json:"quotes"`
+	Error   struct {
+		Info string `json:"info"`
+	} `json:"error"`
+}
+
+// Fallback provider for the exchangerate.host API (or any compatible source
+// configured via FX_FALLBACK_BASE_URL), covering ~170 currencies
+type exchangeRateHostProvider struct{}
+
+var exchangeRateHostCurrencies currencyList
+
+func (exchangeRateHostProvider) Name() string { return "exchangerate.host" }
+
+func (exchangeRateHostProvider) Supports(fromCurrency, toCurrency string) (bool, error) {
+	cfg := settings()
+	return exchangeRateHostCurrencies.contains(func() (map[string]bool, error) {
+		resp, err := outboundClient.Get(fmt.Sprintf("%s/list?access_key=%s", cfg.FXFallbackBaseURL, url.QueryEscape(cfg.FXFallbackAPIKey)))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		var result struct {
+			Success    bool              `json:"success"`
+			Currencies map[string]string `json:"currencies"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("Failed to read exchangerate.host currencies: %v", err)
+		}
+		if !result.Success {
+			return nil, fmt.Errorf("exchangerate.host currency list request failed")
+		}
+
+		codes := map[string]bool{}
+		for code := range result.Currencies {
+			codes[code] = true
+		}
+		return codes, nil
+	}, fromCurrency, toCurrency)
+}
+
+func (exchangeRateHostProvider) Rate(fromCurrency, toCurrency, date string) (float64, string, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/historical?date=%s&source=%s&currencies=%s&access_key=%s",
+		cfg.FXFallbackBaseURL, date, fromCurrency, toCurrency, url.QueryEscape(cfg.FXFallbackAPIKey))
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var result exchangeRateHostResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", err
+	}
+	if !result.Success {
+		return 0, "", fmt.Errorf("exchangerate.host error: %s", result.Error.Info)
+	}
+
+	rate, ok := result.Quotes[fromCurrency+toCurrency]
+	if !ok {
+		return 0, "", fmt.Errorf("No rate found for %s to %s on %s", fromCurrency, toCurrency, date)
+	}
+
+	return rate, date, nil
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A currency replaced by a successor at a fixed conversion rate
+type legacyCurrency struct {
+	Successor    string
+	PerSuccessor float64 // legacy units per successor unit
+	Since        string  // first date (YYYY-MM-DD) the fixed conversion applies
+}
+
+// Legacy currencies: euro predecessors at their irrevocable conversion rates,
+// and redenominated currencies at their redenomination factors
+var legacyCurrencies = map[string]legacyCurrency{
+	"DEM": {"EUR", 1.95583, "1999-01-01"},
+	"FRF": {"EUR", 6.55957, "1999-01-01"},
+	"ITL": {"EUR", 1936.27, "1999-01-01"},
+	"ESP": {"EUR", 166.386, "1999-01-01"},
+	"NLG": {"EUR", 2.20371, "1999-01-01"},
+	"BEF": {"EUR", 40.3399, "1999-01-01"},
+	"LUF": {"EUR", 40.3399, "1999-01-01"},
+	"ATS": {"EUR", 13.7603, "1999-01-01"},
+	"PTE": {"EUR", 200.482, "1999-01-01"},
+	"FIM": {"EUR", 5.94573, "1999-01-01"},
+	"IEP": {"EUR", 0.787564, "1999-01-01"},
+	"GRD": {"EUR", 340.750, "2001-01-01"},
+	"SIT": {"EUR", 239.640, "2007-01-01"},
+	"CYP": {"EUR", 0.585274, "2008-01-01"},
+	"MTL": {"EUR", 0.429300, "2008-01-01"},
+	"SKK": {"EUR", 30.1260, "2009-01-01"},
+	"EEK": {"EUR", 15.6466, "2011-01-01"},
+	"LVL": {"EUR", 0.702804, "2014-01-01"},
+	"LTL": {"EUR", 3.45280, "2015-01-01"},
+	"HRK": {"EUR", 7.53450, "2023-01-01"},
+
+	"ARP": {"ARA", 1000, "1985-06-14"},
+	"ARA": {"ARS", 10000, "1992-01-01"},
+	"MXP": {"MXN", 1000, "1993-01-01"},
+	"PLZ": {"PLN", 10000, "1995-01-01"},
+	"RUR": {"RUB", 1000, "1998-01-01"},
+	"TRL": {"TRY", 1000000, "2005-01-01"},
+	"ROL": {"RON", 10000, "2005-07-01"},
+	"AZM": {"AZN", 5000, "2006-01-01"},
+	"MZM": {"MZN", 1000, "2006-07-01"},
+	"GHC": {"GHS", 10000, "2007-07-01"},
+	"VEB": {"VEF", 1000, "2008-01-01"},
+	"ZMK": {"ZMW", 1000, "2013-01-01"},
+	"BYR": {"BYN", 10000, "2016-07-01"},
+	"VEF": {"VES", 100000, "2018-08-20"},
+}
+
+// Express a currency in the currency that replaced it as of date, following
+// chains like VEB -> VEF -> VES. Returns the code and its units per original unit.
+func legacySuccessor(code, date string) (string, float64) {
+	factor := 1.0
+	for {
+		legacy, ok := legacyCurrencies[code]
+		if !ok || date < legacy.Since {
+			return code, factor
+		}
+		code, factor = legacy.Successor, factor/legacy.PerSuccessor
+	}
+}
+
+// Provider converting legacy currencies through their successor, so 1,000,000 ITL
+// in 2005 is priced as 516.46 EUR
+type legacyCurrencyProvider struct{}
+
+func (legacyCurrencyProvider) Name() string { return "legacy" }
+
+func (legacyCurrencyProvider) Supports(fromCurrency, toCurrency string) (bool, error) {
+	_, fromLegacy := legacyCurrencies[fromCurrency]
+	_, toLegacy := legacyCurrencies[toCurrency]
+	return fromLegacy || toLegacy, nil
+}
+
+func (legacyCurrencyProvider) Rate(fromCurrency, toCurrency, date string) (float64, string, error) {
+	fromSuccessor, fromFactor := legacySuccessor(fromCurrency, date)
+	toSuccessor, toFactor := legacySuccessor(toCurrency, date)
+	if fromSuccessor == fromCurrency && toSuccessor == toCurrency {
+		return 0, "", fmt.Errorf("%s and %s were both still in circulation on %s", fromCurrency, toCurrency, date)
+	}
+
+	rate, source, err := getHistoricalFXRateSource(fromSuccessor, toSuccessor, date)
+	if err != nil {
+		return 0, "", err
+	}
+	rateDate := date
+	if source.ActualDate != "" {
+		rateDate = source.ActualDate
+	}
+	return fromFactor * rate / toFactor, rateDate, nil
+}
+
+// Bundled historical FX dataset (annual averages before ECB reference rates)
+//
+//go:embed data/fx_history.csv
+var bundledFXDataset []byte
+
+// Dataset rows older than this no longer describe the requested date
+const datasetMaxAge = 366 * 24 * time.Hour
+
+// One dataset row
+type datasetRate struct {
+	Date        time.Time
+	UnitsPerUSD float64
+}
+
+// Dataset rows by currency, sorted by date, loaded on first use
+var (
+	fxDatasetOnce sync.Once
+	fxDataset     map[string][]datasetRate
+	fxDatasetErr  error
+)
+
+// Load the bundled dataset plus any rows from FX_DATASET_PATH
+func loadFXDataset() (map[string][]datasetRate, error) {
+	fxDatasetOnce.Do(func() {
+		dataset := map[string][]datasetRate{}
+		if err := readFXDataset(bytes.NewReader(bundledFXDataset), dataset); err != nil {
+			fxDatasetErr = fmt.Errorf("Bundled FX dataset: %v", err)
+			return
+		}
+
+		if fxDatasetPath != "" {
+			file, err := os.Open(fxDatasetPath)
+			if err != nil {
+				fxDatasetErr = err
+				return
+			}
+			defer file.Close()
+			if err := readFXDataset(file, dataset); err != nil {
+				fxDatasetErr = fmt.Errorf("FX dataset %s: %v", fxDatasetPath, err)
+				return
+			}
+		}
+
+		for _, rates := range dataset {
+			sort.Slice(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+		}
+		fxDataset = dataset
+	})
+	return fxDataset, fxDatasetErr
+}
+
+// Parse date,currency,units_per_usd rows into the dataset
+func readFXDataset(r io.Reader, dataset map[string][]datasetRate) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 3
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record[0] == "date" {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", record[0])
+		if err != nil {
+			return err
+		}
+		unitsPerUSD, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return err
+		}
+
+		currency := strings.ToUpper(record[1])
+		dataset[currency] = append(dataset[currency], datasetRate{Date: date, UnitsPerUSD: unitsPerUSD})
+	}
+}
+
+// Provider serving rates from the historical dataset, for dates and currencies
+// no live provider covers (e.g. DEM or ITL in 1995)
+type datasetProvider struct{}
+
+func (datasetProvider) Name() string { return "dataset" }
+
+func (datasetProvider) Supports(fromCurrency, toCurrency string) (bool, error) {
+	dataset, err := loadFXDataset()
+	if err != nil {
+		return false, err
+	}
+	_, fromKnown := dataset[fromCurrency]
+	_, toKnown := dataset[toCurrency]
+	return (fromKnown || fromCurrency == "USD") && (toKnown || toCurrency == "USD"), nil
+}
+
+func (datasetProvider) Rate(fromCurrency, toCurrency, date string) (float64, string, error) {
+	from, err := datasetRateOn(fromCurrency, date)
+	if err != nil {
+		return 0, "", err
+	}
+	to, err := datasetRateOn(toCurrency, date)
+	if err != nil {
+		return 0, "", err
+	}
+
+	// The rate is as old as the older of its two rows
+	rowDate := to.Date
+	if to.Date.IsZero() || (!from.Date.IsZero() && from.Date.Before(to.Date)) {
+		rowDate = from.Date
+	}
+	return to.UnitsPerUSD / from.UnitsPerUSD, rowDate.Format("2006-01-02"), nil
+}
+
+// Look up the latest dataset rate on or before date
+func datasetUnitsPerUSD(currency, date string) (float64, error) {
+	rate, err := datasetRateOn(currency, date)
+	return rate.UnitsPerUSD, err
+}
+
+// Helper function to find the latest dataset row on or before date. USD is always
+// 1 and has no date.
+func datasetRateOn(currency, date string) (datasetRate, error) {
+	if currency == "USD" {
+		return datasetRate{UnitsPerUSD: 1}, nil
+	}
+
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return datasetRate{}, err
+	}
+
+	dataset, err := loadFXDataset()
+	if err != nil {
+		return datasetRate{}, err
+	}
+	rates := dataset[currency]
+
+	// Index of the first row after date
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 || day.Sub(rates[i-1].Date) > datasetMaxAge {
+		return datasetRate{}, fmt.Errorf("No dataset rate for %s on %s", currency, date)
+	}
+	return rates[i-1], nil
+}
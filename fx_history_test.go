@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test following legacy currencies to their successors
+func TestLegacySuccessor(t *testing.T) {
+	code, factor := legacySuccessor("ITL", "2005-06-01")
+	assert.Equal(t, "EUR", code)
+	assert.InDelta(t, 1/1936.27, factor, 1e-12)
+
+	// Still in circulation
+	code, factor = legacySuccessor("ITL", "1995-06-01")
+	assert.Equal(t, "ITL", code)
+	assert.Equal(t, 1.0, factor)
+
+	// Chained redenominations
+	code, factor = legacySuccessor("VEB", "2020-01-01")
+	assert.Equal(t, "VES", code)
+	assert.InDelta(t, 1/1e8, factor, 1e-20)
+
+	code, _ = legacySuccessor("VEB", "2010-01-01")
+	assert.Equal(t, "VEF", code)
+}
+
+// Test pre-1999 and legacy currency conversions
+func TestHistoricalFXRateLegacy(t *testing.T) {
+	setupMockFrankfurter(t)
+	setupMockCoinGecko(t)
+
+	// Pre-euro date served from the bundled dataset
+	rate, err := getHistoricalFXRate("ITL", "USD", "1995-01-02")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1/1629.45, rate, 1e-12)
+
+	rate, err = getHistoricalFXRate("USD", "DEM", "1998-12-31")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.7597, rate, 1e-12)
+
+	// After the euro, legacy currencies convert at the fixed rate
+	rate, err = getHistoricalFXRate("ITL", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.16/1936.27, rate, 1e-12)
+
+	// Outside the dataset's range
+	_, err = getHistoricalFXRate("ITL", "USD", "1980-01-02")
+	assert.Error(t, err)
+}
+
+// Test loading extra rows from FX_DATASET_PATH
+func TestFXDatasetPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fx.csv")
+	err := os.WriteFile(path, []byte("date,currency,units_per_usd\n1985-03-01,DEM,3.3\n"), 0o644)
+	assert.NoError(t, err)
+
+	originalPath := fxDatasetPath
+	fxDatasetPath = path
+	fxDatasetOnce = sync.Once{}
+	t.Cleanup(func() {
+		fxDatasetPath = originalPath
+		fxDatasetOnce = sync.Once{}
+	})
+
+	rate, err := datasetUnitsPerUSD("DEM", "1985-06-14")
+	assert.NoError(t, err)
+	assert.Equal(t, 3.3, rate)
+
+	// Bundled rows are still present
+	rate, err = datasetUnitsPerUSD("DEM", "1995-06-14")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.4321, rate)
+}
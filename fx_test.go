@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock USD value of one unit of each Frankfurter currency, by date
+var mockUSDPerUnit = map[string]map[string]float64{
+	"2025-03-31": {"USD": 1, "EUR": 1.08, "GBP": 1.29, "JPY": 0.0067, "CAD": 0.70},
+	"2025-07-18": {"USD": 1, "EUR": 1.16, "GBP": 1.34, "JPY": 0.0068, "CAD": 0.73},
+}
+
+// Start a fake Frankfurter API serving mockUSDPerUnit
+func setupMockFrankfurter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/currencies" {
+			currencies := map[string]string{}
+			for code := range mockUSDPerUnit["2025-07-18"] {
+				currencies[code] = code
+			}
+			json.NewEncoder(w).Encode(currencies)
+			return
+		}
+
+		date := strings.Trim(r.URL.Path, "/")
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		rates, ok := mockUSDPerUnit[date]
+		if !ok || rates[from] == 0 || rates[to] == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(frankfurterResponse{
+			Amount: 1,
+			Base:   from,
+			Date:   date,
+			Rates:  map[string]float64{to: rates[from] / rates[to]},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.FrankfurterBaseURL = server.URL })
+	frankfurterCurrencies.codes = nil
+	t.Cleanup(func() { frankfurterCurrencies.codes = nil })
+	resetPastFXRates(t)
+}
+
+// Helper function to forget cached FX rates, as rates from other mocks would
+// shadow this one's
+func resetPastFXRates(t *testing.T) {
+	reset := func() {
+		pastFXRatesMu.Lock()
+		pastFXRates = map[string]cachedFXRate{}
+		pastFXRatesMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// Mock USD value of currencies only the fallback provider knows, plus pre-1999 dates
+var mockFallbackUSDPerUnit = map[string]map[string]float64{
+	"1995-01-02": {"USD": 1, "ITL": 0.00062, "DEM": 0.65},
+	"2025-07-18": {"USD": 1, "EUR": 1.16, "NGN": 0.00065},
+}
+
+// Start a fake exchangerate.host API serving mockFallbackUSDPerUnit
+func setupMockFallbackFX(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/list" {
+			currencies := map[string]string{}
+			for _, rates := range mockFallbackUSDPerUnit {
+				for code := range rates {
+					currencies[code] = code
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "currencies": currencies})
+			return
+		}
+
+		query := r.URL.Query()
+		from, to := query.Get("source"), query.Get("currencies")
+		rates := mockFallbackUSDPerUnit[query.Get("date")]
+		if rates[from] == 0 || rates[to] == 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": map[string]string{"info": "no data"}})
+			return
+		}
+		json.NewEncoder(w).Encode(exchangeRateHostResponse{
+			Success: true,
+			Quotes:  map[string]float64{from + to: rates[from] / rates[to]},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.FXFallbackBaseURL, cfg.FXFallbackAPIKey = server.URL, "test-key" })
+	exchangeRateHostCurrencies.codes = nil
+	t.Cleanup(func() { exchangeRateHostCurrencies.codes = nil })
+	resetPastFXRates(t)
+}
+
+// Test that same-currency conversions don't hit Frankfurter
+func TestHistoricalFXRateSameCurrency(t *testing.T) {
+	rate, err := getHistoricalFXRate("USD", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+}
+
+// Test a past rate is fetched once, then served from the cache
+func TestHistoricalFXRateCached(t *testing.T) {
+	setupMockFrankfurter(t)
+	upstream := func() int {
+		providerUsageMu.Lock()
+		defer providerUsageMu.Unlock()
+		if usage, ok := providerUsages[hostOf(settings().FrankfurterBaseURL)]; ok {
+			return usage.TotalRequests
+		}
+		return 0
+	}
+
+	rate, source, err := getHistoricalFXRateSource("EUR", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.False(t, source.Cached)
+	before := upstream()
+
+	cachedRate, cachedSource, err := getHistoricalFXRateSource("EUR", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.Equal(t, rate, cachedRate)
+	assert.True(t, cachedSource.Cached)
+	assert.Equal(t, "frankfurter", cachedSource.Provider)
+	assert.Equal(t, before, upstream())
+}
+
+// Test the composite FX resolver across fiat, stablecoins and crypto
+func TestHistoricalFXRateComposite(t *testing.T) {
+	setupMockFrankfurter(t)
+	setupMockCoinGecko(t)
+
+	// Fiat via Frankfurter
+	rate, err := getHistoricalFXRate("EUR", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.16, rate, 1e-9)
+
+	// Stablecoins read as their peg
+	rate, err = getHistoricalFXRate("USDT", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, rate)
+
+	rate, err = getHistoricalFXRate("USDC", "EUR", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 1/1.16, rate, 1e-9)
+
+	// Crypto against non-USD fiat goes through USD
+	rate, err = getHistoricalFXRate("BTC", "EUR", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 118000.0/1.16, rate, 1e-6)
+
+	// Codes Frankfurter doesn't know fall through to a CoinGecko symbol search
+	rate, err = getHistoricalFXRate("PEPE", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.0000125, rate, 1e-12)
+
+	_, err = getHistoricalFXRate("NOPE", "USD", "2025-07-18")
+	assert.Error(t, err)
+}
+
+// Test stablecoins priced at market when depeg data is enabled
+func TestHistoricalFXRateDepeg(t *testing.T) {
+	setupMockFrankfurter(t)
+	setupMockCoinGecko(t)
+
+	setSettings(t, func(cfg *Config) { cfg.StablecoinDepeg = true })
+
+	rate, err := getHistoricalFXRate("USDC", "USD", "2025-03-31")
+	assert.NoError(t, err)
+	assert.Equal(t, 0.9992, rate)
+}
+
+// Test falling back for exotic currencies and dates Frankfurter doesn't cover
+func TestHistoricalFXRateFallback(t *testing.T) {
+	setupMockFrankfurter(t)
+	setupMockCoinGecko(t)
+
+	// Without a fallback key exotic currencies are unknown
+	_, err := getHistoricalFXRate("NGN", "USD", "2025-07-18")
+	assert.Error(t, err)
+
+	setupMockFallbackFX(t)
+
+	// Currency Frankfurter doesn't publish
+	rate, err := getHistoricalFXRate("NGN", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.00065, rate, 1e-12)
+
+	// Legacy currency on a date before Frankfurter's history starts
+	rate, err = getHistoricalFXRate("ITL", "USD", "1995-01-02")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.00062, rate, 1e-12)
+
+	// Frankfurter knows both, but has no rate for the date
+	_, err = getHistoricalFXRate("EUR", "USD", "1995-01-02")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "frankfurter")
+	assert.Contains(t, err.Error(), "exchangerate.host")
+}
@@ -0,0 +1,44 @@
+package main
+
+import "github.com/gin-gonic/gin"
+
+// A value holding's return with and without its currency effects, with
+// ?hedged=true. The hedged return is the asset's own, in its listing currency,
+// as if the FX move had been hedged away at no cost; the currency return is the
+// move itself, so (1 + unhedged) = (1 + hedged) × (1 + currency).
+type hedgedReturn struct {
+	ListingCurrency       string  `json:"listingCurrency"`
+	Currency              string  `json:"currency"`
+	UnhedgedReturnPercent float64 `json:"unhedgedReturnPercent"`
+	HedgedReturnPercent   float64 `json:"hedgedReturnPercent"`
+	CurrencyReturnPercent float64 `json:"currencyReturnPercent"`
+	CurrencyEffect        float64 `json:"currencyEffect"` // percentage points of the unhedged return that came from FX
+	HedgedFinalValue      float64 `json:"hedgedFinalValue"`
+}
+
+// Work out the hedged return of investing invested (in currency) for finalValue,
+// converted to USD at fxRateBuy and back at fxRateSell
+func calculateHedgedReturn(currency string, invested, finalValue, fxRateBuy, fxRateSell float64) hedgedReturn {
+	unhedged := finalValue/invested - 1
+	currencyReturn := fxRateBuy*fxRateSell - 1
+	hedged := (1+unhedged)/(1+currencyReturn) - 1
+	return hedgedReturn{
+		ListingCurrency:       "USD",
+		Currency:              currency,
+		UnhedgedReturnPercent: unhedged * 100,
+		HedgedReturnPercent:   hedged * 100,
+		CurrencyReturnPercent: currencyReturn * 100,
+		CurrencyEffect:        (unhedged - hedged) * 100,
+		HedgedFinalValue:      invested * (1 + hedged),
+	}
+}
+
+// Helper function to add a "hedged" block, splitting a value holding's return in
+// its currency into the asset's return and the part FX made up. Quantities are
+// bought in USD, so there's nothing to split.
+func addHedgedReturn(response gin.H, enabled bool, currency string, invested, finalValue, fxRateBuy, fxRateSell float64) {
+	if !enabled || currency == "" {
+		return
+	}
+	response["hedged"] = calculateHedgedReturn(currency, invested, finalValue, fxRateBuy, fxRateSell)
+}
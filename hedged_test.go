@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the hedged return strips the currency move out of the unhedged one
+func TestCalculateHedgedReturn(t *testing.T) {
+	// 1000 EUR at 1.08 USD, sold for 1100 USD at 1.16 USD
+	result := calculateHedgedReturn("EUR", 1000, 1100/1.16, 1.08, 1/1.16)
+	assert.Equal(t, "USD", result.ListingCurrency)
+	assert.InDelta(t, (1100/1080.0-1)*100, result.HedgedReturnPercent, 1e-9)
+	assert.InDelta(t, (1.08/1.16-1)*100, result.CurrencyReturnPercent, 1e-9)
+	assert.InDelta(t, (1100/1.16/1000-1)*100, result.UnhedgedReturnPercent, 1e-9)
+	assert.InDelta(t, result.UnhedgedReturnPercent-result.HedgedReturnPercent, result.CurrencyEffect, 1e-9)
+	assert.InDelta(t, 1000*1100/1080.0, result.HedgedFinalValue, 1e-9)
+
+	// Nothing to hedge in USD
+	result = calculateHedgedReturn("USD", 1000, 1200, 1, 1)
+	assert.InDelta(t, 20, result.HedgedReturnPercent, 1e-9)
+	assert.InDelta(t, 20, result.UnhedgedReturnPercent, 1e-9)
+	assert.InDelta(t, 0, result.CurrencyEffect, 1e-9)
+}
+
+// Test ?hedged=true on the value routes and scenarios
+func TestHedgedReturnWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		FinalValueInOriginalCurrency float64      `json:"finalValueInOriginalCurrency"`
+		Hedged                       hedgedReturn `json:"hedged"`
+	}
+	w := makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?hedged=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "EUR", response.Hedged.Currency)
+	assert.InDelta(t, (211.18/200.50-1)*100, response.Hedged.HedgedReturnPercent, 1e-9)
+	assert.InDelta(t, (response.FinalValueInOriginalCurrency/1000-1)*100, response.Hedged.UnhedgedReturnPercent, 1e-9)
+	assert.InDelta(t, (1.08/1.16-1)*100, response.Hedged.CurrencyReturnPercent, 1e-6)
+
+	// Dividends count towards both
+	for _, route := range []string{"with-drip", "with-dividends"} {
+		w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/"+route+"?hedged=true")
+		assert.Equal(t, http.StatusOK, w.Code, route)
+		var result struct {
+			FinalValueUSD float64      `json:"finalValueUSD"`
+			Hedged        hedgedReturn `json:"hedged"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.InDelta(t, (result.FinalValueUSD/1080-1)*100, result.Hedged.HedgedReturnPercent, 1e-6, route)
+	}
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.NotContains(t, w.Body.String(), `"hedged"`)
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?hedged=true")
+	assert.NotContains(t, w.Body.String(), `"hedged"`)
+
+	body := `{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "1000EUR"}, {"side": "sell", "date": "2025-07-18"}], "fees": {"fixed": 5}}`
+	req, _ := http.NewRequest("POST", "/v1/scenario?hedged=true", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var scenario struct {
+		ReturnPercent float64      `json:"returnPercent"`
+		Hedged        hedgedReturn `json:"hedged"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &scenario))
+	assert.InDelta(t, scenario.ReturnPercent, scenario.Hedged.UnhedgedReturnPercent, 1e-9)
+	assert.InDelta(t, (1.08/1.16-1)*100, scenario.Hedged.CurrencyReturnPercent, 1e-6)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A dated daily price, with the day's volume when the provider reports it
+type pricePoint struct {
+	Date   string  `json:"date"`
+	Price  float64 `json:"price"`
+	Volume float64 `json:"volume,omitempty"`
+}
+
+// Fetch an asset's daily prices between two dates (YYYY-MM-DD, inclusive; empty
+// for the whole history), oldest first, at the given point of the day. Index
+// levels stay in the index's own currency.
+func fetchPriceHistory(ticker, assetType, start, end string, opts priceOptions) ([]pricePoint, error) {
+	var points []pricePoint
+	var err error
+
+	switch assetType {
+	case "stock":
+		points, err = fetchStockPriceHistory(ticker, start, end, opts)
+	case "index":
+		index, ok := marketIndices[strings.ToUpper(ticker)]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported index %s", ticker)
+		}
+		points, err = fetchStooqHistory(index.StooqSymbol, start, end, opts.At)
+	case "commodity":
+		item, ok := commodities[strings.ToUpper(ticker)]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported commodity %s", ticker)
+		}
+		if item.StooqSymbol != "" {
+			points, err = fetchStooqHistory(item.StooqSymbol, start, end, opts.At)
+		} else {
+			points, err = fetchCommodityHistoryAlphaVantage(item.AlphaFunction)
+		}
+	case "crypto":
+		points, err = fetchCryptoDailyHistory(strings.ToUpper(ticker), start, end, opts.Source)
+	default:
+		return nil, fmt.Errorf("Price history is not available for type %s", assetType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+
+	var inRange []pricePoint
+	for _, point := range points {
+		if (start == "" || point.Date >= start) && (end == "" || point.Date <= end) {
+			inRange = append(inRange, point)
+		}
+	}
+	if len(inRange) == 0 {
+		return nil, fmt.Errorf("No price history for %s", ticker)
+	}
+	return inRange, nil
+}
+
+// Fetch a stock's full daily history from Alpha Vantage
+// Example: https://www.alphavantage.co/query?function=TIME_SERIES_DAILY&symbol=AAPL&outputsize=full&apikey=demo
+func fetchStockHistoryAlphaVantage(ticker string, opts priceOptions) ([]pricePoint, error) {
+	cfg := settings()
+	function := "TIME_SERIES_DAILY"
+	if opts.Adjusted {
+		function = "TIME_SERIES_DAILY_ADJUSTED"
+	}
+	url := fmt.Sprintf("%s/query?function=%s&symbol=%s&outputsize=full&apikey=%s", cfg.AlphaVantageBaseURL, function, ticker, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result alphaVantageDailyResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	if result.TimeSeries == nil {
+		return nil, fmt.Errorf("No time series data returned from Alpha Vantage")
+	}
+
+	volumeField := "5. volume"
+	if opts.Adjusted {
+		volumeField = "6. volume"
+	}
+	var points []pricePoint
+	for date, dayData := range result.TimeSeries {
+		price, err := strconv.ParseFloat(dayData[priceAtFields[opts.At]], 64)
+		if err != nil {
+			continue
+		}
+		if opts.Adjusted {
+			adjustedClose, err1 := strconv.ParseFloat(dayData["5. adjusted close"], 64)
+			closeVal, err2 := strconv.ParseFloat(dayData["4. close"], 64)
+			if err1 != nil || err2 != nil || closeVal == 0 {
+				continue
+			}
+			price *= adjustedClose / closeVal
+		}
+		volume, _ := strconv.ParseFloat(dayData[volumeField], 64)
+		points = append(points, pricePoint{Date: date, Price: price, Volume: volume})
+	}
+	return points, nil
+}
+
+// Fetch daily opens, highs, lows or closes from Stooq between two dates (or the
+// whole history when they are empty)
+// Example: https://stooq.com/q/d/l/?s=^spx&d1=20200102&d2=20241231&i=d
+func fetchStooqHistory(symbol, start, end, priceAt string) ([]pricePoint, error) {
+	url := fmt.Sprintf("%s/q/d/l/?s=%s&i=d", settings().StooqBaseURL, symbol)
+	if start != "" && end != "" {
+		url += "&d1=" + strings.ReplaceAll(start, "-", "") + "&d2=" + strings.ReplaceAll(end, "-", "")
+	}
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	if err != nil || len(records) < 2 {
+		return nil, fmt.Errorf("No Stooq data for %s", symbol)
+	}
+
+	var points []pricePoint
+	for _, record := range records[1:] {
+		if len(record) < 5 {
+			continue
+		}
+		price, err := strconv.ParseFloat(record[stooqPriceColumns[priceAt]], 64)
+		if err != nil {
+			continue
+		}
+		point := pricePoint{Date: record[0], Price: price}
+		if len(record) > 5 {
+			point.Volume, _ = strconv.ParseFloat(record[5], 64)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+// Fetch a full daily commodity series from Alpha Vantage, skipping days without a quote
+func fetchCommodityHistoryAlphaVantage(function string) ([]pricePoint, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=%s&interval=daily&apikey=%s", cfg.AlphaVantageBaseURL, function, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Data []struct {
+			Date  string `json:"date"`
+			Value string `json:"value"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	var points []pricePoint
+	for _, point := range result.Data {
+		if price, err := strconv.ParseFloat(point.Value, 64); err == nil {
+			points = append(points, pricePoint{Date: point.Date, Price: price})
+		}
+	}
+	return points, nil
+}
+
+// Fetch daily crypto prices in USD from the crypto providers, keeping the last quote
+// of each UTC day, from the provider a request chose if any. Without a start date the
+// history begins in 2013, when CoinGecko's starts.
+func fetchCryptoDailyHistory(symbol, start, end, source string) ([]pricePoint, error) {
+	var err error
+	from, to := time.Date(2013, 4, 28, 0, 0, 0, 0, time.UTC), time.Now().UTC()
+	if start != "" {
+		if from, err = time.Parse("2006-01-02", start); err != nil {
+			return nil, err
+		}
+	}
+	if end != "" {
+		if to, err = time.Parse("2006-01-02", end); err != nil {
+			return nil, err
+		}
+		to = to.Add(24 * time.Hour)
+	}
+
+	quotes, err := fetchCryptoQuotes(symbol, from, to, source)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []pricePoint
+	for _, quote := range quotes.Points {
+		date := time.UnixMilli(int64(quote[0])).UTC().Format("2006-01-02")
+		if len(points) > 0 && points[len(points)-1].Date == date {
+			points[len(points)-1].Price = quote[1]
+			continue
+		}
+		points = append(points, pricePoint{Date: date, Price: quote[1]})
+	}
+	return points, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test daily price histories for each asset type
+func TestPriceHistory(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockStooq(t)
+	setupMockCoinGecko(t)
+
+	points, err := fetchPriceHistory("AAPL", "stock", "2025-05-01", "2025-07-17", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{
+		{Date: "2025-05-15", Price: 211.45},
+		{Date: "2025-06-20", Price: 205.75},
+		{Date: "2025-06-30", Price: 205.17},
+		{Date: "2025-07-17", Price: 210.02},
+	}, points)
+
+	// Adjusted prices skip days without an adjusted close
+	points, err = fetchPriceHistory("AAPL", "stock", "", "", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.Len(t, points, 2)
+	assert.InDelta(t, 198.20*199.50/200.50, points[0].Price, 1e-9)
+
+	points, err = fetchPriceHistory("^GSPC", "index", "2025-07-01", "2025-07-31", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-07-17", Price: 6297.36}, {Date: "2025-07-18", Price: 6296.79}}, points)
+
+	points, err = fetchPriceHistory("GOLD", "commodity", "2025-07-01", "2025-07-31", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-07-18", Price: 3350.20}}, points)
+
+	points, err = fetchPriceHistory("WTI", "commodity", "", "", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-03-31", Price: 71.48}, {Date: "2025-07-18", Price: 67.34}}, points)
+
+	points, err = fetchPriceHistory("BTC", "crypto", "2025-03-01", "2025-07-31", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{
+		{Date: "2025-03-31", Price: 82500},
+		{Date: "2025-06-30", Price: 107000},
+		{Date: "2025-07-18", Price: 118000},
+	}, points)
+
+	_, err = fetchPriceHistory("AAPL", "stock", "2026-01-01", "", priceOptions{At: "close"})
+	assert.Error(t, err)
+
+	_, err = fetchPriceHistory("UST10Y", "bond", "", "", priceOptions{At: "close"})
+	assert.Error(t, err)
+}
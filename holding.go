@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// The FX rates, prices and dividends a buy/sell backtest starts from. None depends
+// on another, so they're fetched at once.
+type holdingData struct {
+	FXRateBuy    float64 // purchase currency to USD, 1 for quantities
+	FXRateSell   float64 // USD to purchase currency, 1 for quantities
+	BuyPrice     float64
+	SellPrice    float64
+	Dividends    []dividendData
+	DividendsErr error // why the dividends couldn't be fetched; only routes built on them fail
+}
+
+// A fetch that failed, with the error message handlers answer it with
+type fetchFailure struct {
+	Message string
+	Err     error
+}
+
+func (f *fetchFailure) Error() string {
+	return f.Message + ": " + f.Err.Error()
+}
+
+func (f *fetchFailure) Unwrap() error {
+	return f.Err
+}
+
+// Fetch a holding's FX rates (when currency isn't empty), buy and sell prices and
+// dividends concurrently. Whichever fetches fail, the error is the first of them in
+// that order, so requests fail the same way they did when fetched one by one.
+// Dividends are best-effort: their failure is kept in DividendsErr.
+func fetchHoldingData(ticker, assetType, currency, buyDate, sellDate string, opts priceOptions) (holdingData, error) {
+	data := holdingData{FXRateBuy: 1, FXRateSell: 1}
+	failures := make([]error, 4)
+	var g errgroup.Group
+	fetch := func(i int, message string, f func() error) {
+		g.Go(func() error {
+			if err := f(); err != nil {
+				failures[i] = &fetchFailure{Message: message, Err: err}
+				return failures[i]
+			}
+			return nil
+		})
+	}
+
+	if currency != "" {
+		fetch(0, "Failed to fetch FX rate for buy date", func() (err error) {
+			data.FXRateBuy, err = opts.Notes.fxRate(currency, "USD", buyDate)
+			return err
+		})
+		fetch(1, "Failed to fetch FX rate for sell date", func() (err error) {
+			data.FXRateSell, err = opts.Notes.fxRate("USD", currency, sellDate)
+			return err
+		})
+	}
+	fetch(2, "Failed to fetch buy price", func() (err error) {
+		data.BuyPrice, err = fetchPrice(ticker, buyDate, assetType, opts)
+		return err
+	})
+	fetch(3, "Failed to fetch sell price", func() (err error) {
+		data.SellPrice, err = fetchSellPrice(ticker, buyDate, sellDate, assetType, opts)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		if data.Dividends, err = fetchHoldingDividends(ticker, assetType, buyDate, sellDate, opts); err != nil {
+			data.DividendsErr = &fetchFailure{Message: "Failed to fetch dividends", Err: err}
+		}
+		return nil
+	})
+
+	if g.Wait() != nil {
+		for _, err := range failures {
+			if err != nil {
+				return data, err
+			}
+		}
+	}
+	return data, nil
+}
+
+// Helper function to answer a failed fetchHoldingData with the failed fetch's message
+func respondFetchFailure(c *gin.Context, err error) {
+	var failure *fetchFailure
+	if errors.As(err, &failure) {
+		respondPriceError(c, failure.Message, failure.Err)
+		return
+	}
+	respondPriceError(c, "Failed to fetch holding data", err)
+}
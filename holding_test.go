@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a holding's prices, FX rates and dividends are fetched at the same time
+func TestFetchHoldingData(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+
+	// Slow Alpha Vantage down, counting how many requests it serves at once
+	upstream, _ := url.Parse(settings().AlphaVantageBaseURL)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		proxy.ServeHTTP(w, r)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = server.URL })
+
+	data, err := fetchHoldingData("AAPL", "stock", "EUR", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.InDelta(t, 1.08, data.FXRateBuy, 0.0001)
+	assert.InDelta(t, 1/1.16, data.FXRateSell, 0.0001)
+	assert.Equal(t, 211.18, data.SellPrice)
+	assert.Positive(t, data.BuyPrice)
+	assert.NotEmpty(t, data.Dividends)
+	assert.Greater(t, maxInFlight, 1)
+
+	// Quantities aren't converted
+	data, err = fetchHoldingData("AAPL", "stock", "", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, data.FXRateBuy)
+	assert.Equal(t, 1.0, data.FXRateSell)
+}
+
+// Test the failure reported is the first in fetch order, however the fetches finish
+func TestFetchHoldingDataFailureOrder(t *testing.T) {
+	resetFirstTradedDates(t)
+	setupAlphaVantagePacing(t, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	}))
+	t.Cleanup(server.Close)
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL, cfg.FrankfurterBaseURL = server.URL, server.URL })
+	prices.invalidate(priceFilter{})
+	latest.clear()
+	resetPastFXRates(t)
+
+	_, err := fetchHoldingData("AAPL", "stock", "EUR", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	var failure *fetchFailure
+	assert.ErrorAs(t, err, &failure)
+	assert.Equal(t, "Failed to fetch FX rate for buy date", failure.Message)
+
+	_, err = fetchHoldingData("AAPL", "stock", "", "2025-03-31", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorAs(t, err, &failure)
+	assert.Equal(t, "Failed to fetch buy price", failure.Message)
+}
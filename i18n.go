@@ -0,0 +1,148 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Translations of response messages, text-format field labels and other words,
+// one JSON file per language code. English is the source language: its bundle
+// lists every message, and missing translations fall back to it.
+//
+//go:embed data/messages/*.json
+var messageFiles embed.FS
+
+// A language's translations
+type messageBundle struct {
+	Messages map[string]string `json:"messages"` // keyed by the English message
+	Fields   map[string]string `json:"fields"`   // keyed by response field name
+	Text     map[string]string `json:"text"`     // other words used by text formats
+}
+
+// Bundles by language code
+var messageBundles = loadMessageBundles()
+
+// Load the bundled translations. A broken bundle is a bug, so it stops the server.
+func loadMessageBundles() map[string]messageBundle {
+	files, err := messageFiles.ReadDir("data/messages")
+	if err != nil {
+		panic(err)
+	}
+
+	bundles := map[string]messageBundle{}
+	for _, file := range files {
+		data, err := messageFiles.ReadFile("data/messages/" + file.Name())
+		if err != nil {
+			panic(err)
+		}
+		var bundle messageBundle
+		if err := json.Unmarshal(data, &bundle); err != nil {
+			panic(fmt.Sprintf("Message bundle %s: %v", file.Name(), err))
+		}
+		bundles[strings.TrimSuffix(file.Name(), path.Ext(file.Name()))] = bundle
+	}
+	return bundles
+}
+
+// Helper function to find the bundled language for a tag like "de-CH" or "pt_BR"
+func supportedLanguage(tag string) (string, bool) {
+	language, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	language = strings.ToLower(strings.TrimSpace(language))
+	_, ok := messageBundles[language]
+	return language, ok
+}
+
+// Helper function to list an Accept-Language header's tags, most preferred first
+func acceptedLanguages(header string) []string {
+	type weightedTag struct {
+		tag    string
+		weight float64
+	}
+	var tags []weightedTag
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				weight = parsed
+			}
+		}
+		if weight > 0 {
+			tags = append(tags, weightedTag{tag, weight})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].weight > tags[j].weight })
+
+	result := make([]string, len(tags))
+	for i, tag := range tags {
+		result[i] = tag.tag
+	}
+	return result
+}
+
+// Helper function to pick the response language from ?lang=, then Accept-Language,
+// then English
+func requestLanguage(c *gin.Context) string {
+	tags := acceptedLanguages(c.GetHeader("Accept-Language"))
+	if lang := c.Query("lang"); lang != "" {
+		tags = append([]string{lang}, tags...)
+	}
+	for _, tag := range tags {
+		if language, ok := supportedLanguage(tag); ok {
+			return language
+		}
+	}
+	return "en"
+}
+
+// Helper function to pick the locale text formats write numbers in: ?locale=,
+// then ?lang=, then the preferred Accept-Language tag
+func requestLocale(c *gin.Context) string {
+	if locale := c.Query("locale"); locale != "" {
+		return locale
+	}
+	if lang := c.Query("lang"); lang != "" {
+		return lang
+	}
+	if tags := acceptedLanguages(c.GetHeader("Accept-Language")); len(tags) > 0 {
+		return tags[0]
+	}
+	return "en"
+}
+
+// Translate an English response message into the request's language
+func localize(c *gin.Context, message string) string {
+	if translated, ok := messageBundles[requestLanguage(c)].Messages[message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Helper function to label a response field in a language, falling back to English
+// and then to the field name itself
+func fieldLabel(language, field string) string {
+	for _, bundle := range []messageBundle{messageBundles[language], messageBundles["en"]} {
+		if label, ok := bundle.Fields[field]; ok {
+			return label
+		}
+	}
+	return humanizeField(field)
+}
+
+// Helper function to look up a word used by text formats, falling back to English
+func textWord(language, key string) string {
+	if word, ok := messageBundles[language].Text[key]; ok {
+		return word
+	}
+	return messageBundles["en"].Text[key]
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test every bundle translates everything the English bundle lists
+func TestMessageBundlesComplete(t *testing.T) {
+	english := messageBundles["en"]
+	assert.NotEmpty(t, english.Messages)
+
+	for _, language := range []string{"de", "fr", "es", "pt"} {
+		bundle, ok := messageBundles[language]
+		assert.True(t, ok, language)
+		for message := range english.Messages {
+			assert.NotEmpty(t, bundle.Messages[message], language+": "+message)
+		}
+		for field := range english.Fields {
+			assert.NotEmpty(t, bundle.Fields[field], language+": "+field)
+		}
+		for word := range english.Text {
+			assert.NotEmpty(t, bundle.Text[word], language+": "+word)
+		}
+	}
+}
+
+// Test Accept-Language parsing
+func TestAcceptedLanguages(t *testing.T) {
+	assert.Equal(t, []string{"de-CH", "de", "en"}, acceptedLanguages("de-CH, en;q=0.5, de;q=0.9"))
+	assert.Equal(t, []string{"fr"}, acceptedLanguages("fr, *;q=0.1, es;q=0"))
+	assert.Empty(t, acceptedLanguages(""))
+}
+
+// Test localized messages and text-format labels
+func TestLocalizedMessagesWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withFormat())
+	router.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	request := func(path, acceptLanguage string) string {
+		req, _ := http.NewRequest("GET", path, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+	path := "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18"
+
+	assert.Contains(t, request(path, ""), `"message":"Backtest result (value buy/sell)"`)
+	assert.Contains(t, request(path, "de-DE,de;q=0.9"), `"message":"Backtest-Ergebnis (Kauf und Verkauf nach Betrag)"`)
+	assert.Contains(t, request(path, "ja, es;q=0.8"), `"message":"Resultado del backtest (compra y venta por importe)"`)
+	assert.Contains(t, request(path+"?lang=fr", "de"), `"message":"Résultat du backtest (achat et vente par montant)"`)
+	assert.Contains(t, request(path+"?lang=xx", ""), `"message":"Backtest result (value buy/sell)"`)
+
+	// Text formats are labelled in the language and written in its locale
+	body := request(path+"?format=text", "pt-BR")
+	assert.Contains(t, body, "Resultado do backtest (compra e venda por valor)\n")
+	assert.Contains(t, body, "Valor: € 1.000,00\n")
+	assert.Contains(t, body, "Data de compra: 2025-03-31\n")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A dividend a position received, in USD
+type incomePayment struct {
+	ExDate      string
+	PaymentDate string
+	Amount      float64
+}
+
+// Dividends received in one calendar year (by payment date)
+type yearIncome struct {
+	Year      int     `json:"year"`
+	AmountUSD float64 `json:"amountUSD"`
+	Payments  int     `json:"payments"`
+}
+
+// What a dividend scenario paid out: in total, by year, and over the twelve months
+// before the sell date as a yield on the original cost and on the position's value
+type incomeSummary struct {
+	TotalUSD                float64      `json:"totalUSD"`
+	TrailingTwelveMonthsUSD float64      `json:"trailingTwelveMonthsUSD"`
+	YieldOnCostPercent      float64      `json:"yieldOnCostPercent"`
+	CurrentYieldPercent     float64      `json:"currentYieldPercent"`
+	ByYear                  []yearIncome `json:"byYear"`
+}
+
+// Helper function to list DRIP dividends as income, reinvested or not
+func dripIncome(events []dripEvent) []incomePayment {
+	payments := make([]incomePayment, len(events))
+	for i, event := range events {
+		payments[i] = incomePayment{ExDate: event.ExDate, PaymentDate: event.PaymentDate, Amount: event.Amount}
+	}
+	return payments
+}
+
+// Helper function to total the DRIP dividends that bought shares
+func dripReinvestedUSD(events []dripEvent) float64 {
+	total := 0.0
+	for _, event := range events {
+		total += event.reinvested()
+	}
+	return total
+}
+
+// Helper function to list dividends kept as cash as income, without their interest
+func cashIncome(dividends []cashDividend) []incomePayment {
+	payments := make([]incomePayment, len(dividends))
+	for i, dividend := range dividends {
+		payments[i] = incomePayment{ExDate: dividend.ExDate, PaymentDate: dividend.PaymentDate, Amount: dividend.Amount}
+	}
+	return payments
+}
+
+// Summarize the income of a position that cost costUSD and was worth valueUSD on
+// the sell date. The trailing twelve months are the dividends that went ex in the
+// year up to the sell date, so a holding shorter than a year counts what it got.
+func summarizeIncome(payments []incomePayment, costUSD, valueUSD float64, sellDate string) incomeSummary {
+	summary := incomeSummary{ByYear: []yearIncome{}}
+	sellDay, _ := time.Parse("2006-01-02", dateOnly(sellDate))
+	yearBefore := sellDay.AddDate(-1, 0, 0).Format("2006-01-02")
+
+	for _, payment := range payments {
+		summary.TotalUSD += payment.Amount
+		if payment.ExDate > yearBefore && payment.ExDate <= dateOnly(sellDate) {
+			summary.TrailingTwelveMonthsUSD += payment.Amount
+		}
+
+		paid := payment.PaymentDate
+		if paid == "" {
+			paid = payment.ExDate
+		}
+		year, _ := strconv.Atoi(paid[:4])
+		i := sort.Search(len(summary.ByYear), func(i int) bool { return summary.ByYear[i].Year >= year })
+		if i == len(summary.ByYear) || summary.ByYear[i].Year != year {
+			summary.ByYear = append(summary.ByYear[:i], append([]yearIncome{{Year: year}}, summary.ByYear[i:]...)...)
+		}
+		summary.ByYear[i].AmountUSD += payment.Amount
+		summary.ByYear[i].Payments++
+	}
+
+	summary.YieldOnCostPercent = summary.TrailingTwelveMonthsUSD / costUSD * 100
+	if valueUSD > 0 {
+		summary.CurrentYieldPercent = summary.TrailingTwelveMonthsUSD / valueUSD * 100
+	}
+	return summary
+}
+
+// Add an "income" block summarizing the dividends a DRIP or dividends-as-cash
+// scenario received
+func addIncomeSummary(response gin.H, payments []incomePayment, costUSD, valueUSD float64, sellDate string) {
+	response["income"] = summarizeIncome(payments, costUSD, valueUSD, sellDate)
+}
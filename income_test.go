@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test income is totalled by payment year and over the year up to the sell date
+func TestSummarizeIncome(t *testing.T) {
+	payments := []incomePayment{
+		{ExDate: "2023-11-10", PaymentDate: "2023-11-16", Amount: 2},
+		{ExDate: "2023-12-28", PaymentDate: "2024-01-05", Amount: 2},
+		{ExDate: "2024-05-10", PaymentDate: "2024-05-16", Amount: 3},
+		{ExDate: "2024-11-08", Amount: 3},
+	}
+	summary := summarizeIncome(payments, 200, 300, "2024-12-01")
+	assert.Equal(t, 10.0, summary.TotalUSD)
+	assert.Equal(t, 8.0, summary.TrailingTwelveMonthsUSD)
+	assert.InDelta(t, 4.0, summary.YieldOnCostPercent, 1e-9)
+	assert.InDelta(t, 8.0/3, summary.CurrentYieldPercent, 1e-9)
+	assert.Equal(t, []yearIncome{{Year: 2023, AmountUSD: 2, Payments: 1}, {Year: 2024, AmountUSD: 8, Payments: 3}}, summary.ByYear)
+
+	// No dividends is an empty list, not null
+	empty := summarizeIncome(nil, 200, 300, "2024-12-01")
+	assert.NotNil(t, empty.ByYear)
+	assert.Zero(t, empty.YieldOnCostPercent)
+}
+
+// Test DRIP and dividends-as-cash responses carry the income summary, with the
+// July dividend paid in August, after the sale
+func TestIncomeSummaryWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	for _, path := range []string{
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends",
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip",
+	} {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Income incomeSummary `json:"income"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Income.ByYear, 1, path)
+		assert.Equal(t, 2, response.Income.ByYear[0].Payments, path)
+		assert.InDelta(t, response.Income.TotalUSD, response.Income.TrailingTwelveMonthsUSD, 1e-9, path)
+		assert.InDelta(t, response.Income.TotalUSD/(10*200.50)*100, response.Income.YieldOnCostPercent, 1e-9, path)
+	}
+
+	// Cash dividends are on the 10 shares bought
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends")
+	var response struct {
+		Income incomeSummary `json:"income"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 5.2, response.Income.TotalUSD, 1e-9)
+	assert.InDelta(t, 5.2/(10*211.18)*100, response.Income.CurrentYieldPercent, 1e-9)
+}
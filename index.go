@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A market index as served by Stooq, quoted in its home currency
+type marketIndex struct {
+	StooqSymbol string
+	Currency    string
+}
+
+// Supported index symbols (Yahoo-style, as users know them)
+var marketIndices = map[string]marketIndex{
+	"^GSPC":  {"^spx", "USD"},
+	"^SPX":   {"^spx", "USD"},
+	"^NDX":   {"^ndx", "USD"},
+	"^IXIC":  {"^ndq", "USD"},
+	"^DJI":   {"^dji", "USD"},
+	"^FTSE":  {"^ukx", "GBP"},
+	"^GDAXI": {"^dax", "EUR"},
+	"^FCHI":  {"^cac", "EUR"},
+	"^N225":  {"^nkx", "JPY"},
+	"^HSI":   {"^hsi", "HKD"},
+}
+
+// Helper function to check if a ticker is an index symbol like ^GSPC
+func isIndexTicker(ticker string) bool {
+	return strings.HasPrefix(ticker, "^")
+}
+
+// Stooq daily CSV columns for each selectable price point
+var stooqPriceColumns = map[string]int{
+	"open":  1,
+	"high":  2,
+	"low":   3,
+	"close": 4,
+}
+
+// Fetch an index level on a date in USD, converting indices quoted in other
+// currencies at that day's FX rate so they compare directly with US stocks
+func fetchIndexPriceUSD(ticker, date, priceAt string) (float64, error) {
+	index, ok := marketIndices[strings.ToUpper(ticker)]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported index %s", ticker)
+	}
+
+	level, err := fetchStooqPrice(index.StooqSymbol, date, priceAt)
+	if err != nil {
+		return 0, fmt.Errorf("No index level for %s on %s: %v", ticker, date, err)
+	}
+
+	if index.Currency == "USD" {
+		return level, nil
+	}
+	fxRate, err := getHistoricalFXRate(index.Currency, "USD", date)
+	if err != nil {
+		return 0, err
+	}
+	return level * fxRate, nil
+}
+
+// Fetch a daily open, high, low or close from Stooq
+// Example: https://stooq.com/q/d/l/?s=^spx&d1=20240102&d2=20240102&i=d
+func fetchStooqPrice(symbol, date, priceAt string) (float64, error) {
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+
+	compact := day.Format("20060102")
+	url := fmt.Sprintf("%s/q/d/l/?s=%s&d1=%s&d2=%s&i=d", settings().StooqBaseURL, symbol, compact, compact)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	// Date,Open,High,Low,Close[,Volume]; Stooq answers "No data" for closed days
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	if err != nil || len(records) < 2 || len(records[1]) < 5 || records[1][0] != date {
+		return 0, fmt.Errorf("No Stooq data for %s on %s", symbol, date)
+	}
+
+	return strconv.ParseFloat(records[1][stooqPriceColumns[priceAt]], 64)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Mock index and spot rows (Date,Open,High,Low,Close) by Stooq symbol and date
+var mockIndexData = map[string]map[string]string{
+	"^spx": {
+		"2025-03-31": "5527.91,5587.08,5488.73,5611.85",
+		"2025-06-30": "6193.36,6215.08,6174.97,6204.95",
+		"2025-07-17": "6268.08,6304.78,6261.97,6297.36",
+		"2025-07-18": "6316.60,6319.80,6285.80,6296.79",
+	},
+	"^ukx": {
+		"2025-07-18": "8972.64,9012.99,8967.27,8992.12",
+	},
+	"xauusd": {
+		"2025-03-31": "3084.40,3128.06,3076.73,3123.57",
+		"2025-07-18": "3339.55,3361.35,3328.43,3350.20",
+	},
+}
+
+// Start a fake Stooq daily CSV API serving mockIndexData between d1 and d2
+// (the whole series without them)
+func setupMockStooq(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end := "", "9999-12-31"
+		if d1, d2 := r.URL.Query().Get("d1"), r.URL.Query().Get("d2"); d1 != "" {
+			from, err1 := time.Parse("20060102", d1)
+			to, err2 := time.Parse("20060102", d2)
+			if err1 != nil || err2 != nil {
+				http.Error(w, "bad date range", http.StatusBadRequest)
+				return
+			}
+			start, end = from.Format("2006-01-02"), to.Format("2006-01-02")
+		}
+
+		series := mockIndexData[r.URL.Query().Get("s")]
+		var dates []string
+		for date := range series {
+			if date >= start && date <= end {
+				dates = append(dates, date)
+			}
+		}
+		if len(dates) == 0 {
+			fmt.Fprint(w, "No data")
+			return
+		}
+		sort.Strings(dates)
+
+		fmt.Fprint(w, "Date,Open,High,Low,Close\n")
+		for _, date := range dates {
+			fmt.Fprintf(w, "%s,%s\n", date, series[date])
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.StooqBaseURL = server.URL })
+	prices.invalidate(priceFilter{}) // prices cached from other mocks would shadow this one's
+	latest.clear()
+}
+
+// Test index levels, including conversion of non-USD indices
+func TestIndexPrice(t *testing.T) {
+	setupMockStooq(t)
+	setupMockFrankfurter(t)
+
+	price, err := fetchIndexPriceUSD("^GSPC", "2025-07-18", "close")
+	assert.NoError(t, err)
+	assert.Equal(t, 6296.79, price)
+
+	price, err = fetchIndexPriceUSD("^GSPC", "2025-07-18", "high")
+	assert.NoError(t, err)
+	assert.Equal(t, 6319.80, price)
+
+	rate, err := getHistoricalFXRate("GBP", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	price, err = fetchIndexPriceUSD("^FTSE", "2025-07-18", "close")
+	assert.NoError(t, err)
+	assert.InDelta(t, 8992.12*rate, price, 1e-9)
+
+	_, err = fetchIndexPriceUSD("^GSPC", "2025-07-19", "close")
+	assert.Error(t, err)
+
+	_, err = fetchIndexPriceUSD("^NOPE", "2025-07-18", "close")
+	assert.Error(t, err)
+}
+
+// Test index symbols in the ticker slot
+func TestIndexRoute(t *testing.T) {
+	setupMockStooq(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/%5EGSPC/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "index", response["type"])
+	assert.Equal(t, "^GSPC", response["ticker"])
+	assert.Equal(t, 5611.85, response["buyPrice"])
+	assert.InDelta(t, 62967.9, response["finalValue"], 1e-9)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Accepted layouts for a date with a time of day, e.g. 2024-05-01T14:30
+var intradayLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+// Helper function to strip an optional time of day from a date parameter
+func dateOnly(date string) string {
+	if i := strings.IndexByte(date, 'T'); i >= 0 {
+		return date[:i]
+	}
+	return date
+}
+
+// Helper function to check if a date parameter carries a time of day
+func hasTimeOfDay(date string) bool {
+	return strings.Contains(date, "T")
+}
+
+// Parse a date with a time of day in the given location
+func parseIntradayTime(datetime string, loc *time.Location) (time.Time, error) {
+	for _, layout := range intradayLayouts {
+		if t, err := time.ParseInLocation(layout, datetime, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid date and time %q: expected YYYY-MM-DDTHH:MM", datetime)
+}
+
+// Fetch the price of an asset at a time of day. Stock times are New York
+// exchange time, crypto times are UTC.
+func fetchIntradayPrice(ticker, datetime, assetType string, opts priceOptions) (float64, priceAnswer, error) {
+	if assetType == "crypto" {
+		at, err := parseIntradayTime(datetime, time.UTC)
+		if err != nil {
+			return 0, priceAnswer{}, err
+		}
+		return fetchCryptoIntradayPriceUSD(strings.ToUpper(ticker), at, opts.Source)
+	}
+
+	if opts.Source != "" && opts.Source != "alphaVantage" {
+		return 0, priceAnswer{}, fmt.Errorf("Stock prices at a time of day only come from alphaVantage, not %s", opts.Source)
+	}
+	at, err := parseIntradayTime(datetime, newYork)
+	if err != nil {
+		return 0, priceAnswer{}, err
+	}
+	price, barTime, err := fetchStockIntradayAlphaVantage(ticker, at, opts)
+	return price, priceAnswer{Date: barTime}, err
+}
+
+// Exchange time zone for Alpha Vantage intraday bars
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return loc
+}()
+
+// Fetch the open, high, low or close of the last 1-minute bar at or before a time
+// on the same day, and the bar's time. Alpha Vantage adjusts intraday bars by default, so the basis is
+// always requested explicitly to match the daily series.
+// Example: https://www.alphavantage.co/query?function=TIME_SERIES_INTRADAY&symbol=IBM&interval=1min&month=2024-05&outputsize=full&adjusted=false&apikey=demo
+func fetchStockIntradayAlphaVantage(ticker string, at time.Time, opts priceOptions) (float64, string, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=TIME_SERIES_INTRADAY&symbol=%s&interval=1min&month=%s&outputsize=full&adjusted=%t&apikey=%s",
+		cfg.AlphaVantageBaseURL, ticker, at.Format("2006-01"), opts.Adjusted, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, "", err
+	}
+
+	var result struct {
+		TimeSeries map[string]map[string]string `json:"Time Series (1min)"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, "", fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+
+	if result.TimeSeries == nil {
+		return 0, "", fmt.Errorf("No time series data returned from Alpha Vantage")
+	}
+
+	// Bars are keyed "2024-05-01 14:30:00"; find the latest one at or before the time
+	day := at.Format("2006-01-02")
+	target := at.Format("2006-01-02 15:04:05")
+	var timestamps []string
+	for timestamp := range result.TimeSeries {
+		if strings.HasPrefix(timestamp, day) && timestamp <= target {
+			timestamps = append(timestamps, timestamp)
+		}
+	}
+	if len(timestamps) == 0 {
+		return 0, "", fmt.Errorf("No intraday data for %s at or before %s", ticker, at.Format("2006-01-02T15:04"))
+	}
+	sort.Strings(timestamps)
+
+	bar := timestamps[len(timestamps)-1]
+	priceStr, ok := result.TimeSeries[bar][priceAtFields[opts.At]]
+	if !ok {
+		return 0, "", fmt.Errorf("No %s price for %s", opts.At, bar)
+	}
+	price, err := strconv.ParseFloat(priceStr, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return price, strings.Replace(bar[:16], " ", "T", 1), nil
+}
+
+// Fetch the last crypto price at or before a time, looking back up to an hour,
+// and its time
+func fetchCryptoIntradayPriceUSD(symbol string, at time.Time, source string) (float64, priceAnswer, error) {
+	quotes, err := fetchCryptoQuotes(symbol, at.Add(-time.Hour), at, source)
+	if err != nil {
+		return 0, priceAnswer{}, err
+	}
+
+	point := quotes.Points[len(quotes.Points)-1]
+	return point[1], priceAnswer{
+		Date:     time.UnixMilli(int64(point[0])).UTC().Format("2006-01-02T15:04"),
+		Provider: quotes.Provider,
+		Fallback: quotes.Fallback,
+	}, nil
+}
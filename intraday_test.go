@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test splitting dates from times of day
+func TestDateOnly(t *testing.T) {
+	assert.Equal(t, "2024-05-01", dateOnly("2024-05-01T14:30"))
+	assert.Equal(t, "2024-05-01", dateOnly("2024-05-01"))
+	assert.True(t, hasTimeOfDay("2024-05-01T14:30"))
+	assert.False(t, hasTimeOfDay("2024-05-01"))
+}
+
+// Test pricing stocks at a time of day
+func TestStockIntradayPrice(t *testing.T) {
+	setupMockAlphaVantage(t)
+
+	// Exact bar
+	price, err := fetchPrice("AAPL", "2025-07-18T10:30", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 210.80, price)
+
+	// Latest bar before the time
+	price, err = fetchPrice("AAPL", "2025-07-18T10:31", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 210.80, price)
+
+	// Before the first bar of the day
+	_, err = fetchPrice("AAPL", "2025-07-18T09:00", "stock", priceOptions{At: "close"})
+	assert.Error(t, err)
+
+	_, err = fetchPrice("AAPL", "2025-07-18T25:00", "stock", priceOptions{At: "close"})
+	assert.Error(t, err)
+
+	// Daily close without a time
+	price, err = fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+}
+
+// Test pricing crypto at a time of day
+func TestCryptoIntradayPrice(t *testing.T) {
+	setupMockCoinGecko(t)
+
+	price, err := fetchPrice("BTC", "2025-07-18T12:00", "crypto", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 118000.0, price)
+}
+
+// Test intraday buy dates through the routes
+func TestIntradayRoute(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/1000EUR/of/AAPL/on/2025-07-18T10:30?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-07-18T10:30", response["buyDate"])
+	assert.Equal(t, 210.80, response["closePrice"])
+	assert.InDelta(t, 1.16, response["fxRate"], 1e-9)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Job states
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+// A backtest run in the background: any GET request of the API, answered later
+type job struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	Path       string          `json:"path"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	StatusCode int             `json:"statusCode,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	ResultText string          `json:"resultText,omitempty"`
+	Callback   *callback       `json:"callback,omitempty"`
+	language   string
+}
+
+// Jobs waiting for, or being run by, a fixed pool of workers. Finished jobs are
+// kept for ttl so clients can collect their results, and POSTed to their callback
+// URL if they have one, signed with webhookSecret.
+type jobQueue struct {
+	handler             http.Handler
+	queue               chan *job
+	ttl                 time.Duration
+	webhookSecret       string
+	webhookAllowPrivate bool
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// Start workers running jobs from a queue of up to size jobs against handler.
+// Callbacks are only taken with a webhook secret to sign them.
+func newJobQueue(handler http.Handler, workers, size int, ttl time.Duration, webhookSecret string, webhookAllowPrivate bool) *jobQueue {
+	q := &jobQueue{
+		handler:             handler,
+		queue:               make(chan *job, size),
+		ttl:                 ttl,
+		webhookSecret:       webhookSecret,
+		webhookAllowPrivate: webhookAllowPrivate,
+		jobs:                map[string]*job{},
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// Helper function to make a random ID for jobs and alerts
+func newRandomID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Helper function to drop finished jobs older than the ttl. Callers hold q.mu.
+func (q *jobQueue) prune(now time.Time) {
+	for id, j := range q.jobs {
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > q.ttl {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+// Queue a job for path, or return false if the queue is full
+func (q *jobQueue) submit(path, callbackURL, language string) (job, bool) {
+	now := time.Now().UTC()
+	j := &job{ID: newRandomID(), Status: jobQueued, Path: path, CreatedAt: now, language: language}
+	if callbackURL != "" {
+		j.Callback = &callback{URL: callbackURL, Status: "pending"}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(now)
+	select {
+	case q.queue <- j:
+		q.jobs[j.ID] = j
+		return *j, true
+	default:
+		return job{}, false
+	}
+}
+
+// Look a job up by ID
+func (q *jobQueue) get(id string) (job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.prune(time.Now().UTC())
+	j, ok := q.jobs[id]
+	if !ok {
+		return job{}, false
+	}
+	snapshot := *j
+	if j.Callback != nil {
+		delivery := *j.Callback
+		snapshot.Callback = &delivery
+	}
+	return snapshot, true
+}
+
+// Run queued jobs one at a time, through the API's own router
+func (q *jobQueue) work() {
+	for j := range q.queue {
+		q.mu.Lock()
+		started := time.Now().UTC()
+		j.Status, j.StartedAt = jobRunning, &started
+		q.mu.Unlock()
+
+		w := q.run(j)
+
+		q.mu.Lock()
+		finished := time.Now().UTC()
+		j.FinishedAt, j.StatusCode = &finished, w.Code
+		j.Status = jobFailed
+		if w.Code == http.StatusOK {
+			j.Status = jobSucceeded
+		}
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") && json.Valid(w.Body.Bytes()) {
+			j.Result = json.RawMessage(w.Body.Bytes())
+		} else {
+			j.ResultText = w.Body.String()
+		}
+		q.mu.Unlock()
+
+		if j.Callback != nil {
+			go q.deliverCallback(j)
+		}
+	}
+}
+
+// Helper function to run one job's request. A job that can't be requested, or
+// whose handler panics, fails with a 500 instead of taking the worker (and the
+// server) down with it.
+func (q *jobQueue) run(j *job) (w *httptest.ResponseRecorder) {
+	w = httptest.NewRecorder()
+	fail := func(details string) {
+		w = httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusInternalServerError)
+		body, _ := json.Marshal(gin.H{"error": "Job failed", "details": details})
+		w.Write(body)
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			fail(fmt.Sprint(err))
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, j.Path, nil)
+	if err != nil {
+		fail(err.Error())
+		return w
+	}
+	req.RequestURI = j.Path
+	if j.language != "" {
+		req.Header.Set("Accept-Language", j.language)
+	}
+	q.handler.ServeHTTP(w, req)
+	return w
+}
+
+// Request body of POST /v1/jobs
+type jobRequest struct {
+	Path        string `json:"path" binding:"required"`
+	CallbackURL string `json:"callbackUrl"`
+}
+
+// Helper function to check a job's path is a GET route of the API, not the jobs
+// API, and could be sent as the target of a request line: no spaces or control
+// characters, which a real client would have had to escape
+func checkJobPath(path string) error {
+	parsed, err := url.ParseRequestURI(path)
+	invalid := strings.ContainsFunc(path, func(r rune) bool { return r <= ' ' || r == 0x7f })
+	if err != nil || invalid || !strings.HasPrefix(path, "/") || parsed.Host != "" || strings.HasPrefix(parsed.Path, "/v1/jobs") {
+		return fmt.Errorf("path must be an API path like /10000USD/in/AAPL:60,MSFT:40/on/2015-01-02/and-sold-on/2025-01-02")
+	}
+	return nil
+}
+
+// Queue a backtest: POST /v1/jobs {"path": "/10000USD/of/AAPL/on/...", "callbackUrl":
+// "https://..."}. Answers 202 with the job and a Location to poll, or 503 when the
+// queue is full.
+func (q *jobQueue) handleSubmit(c *gin.Context) {
+	var request jobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job request", "details": err.Error()})
+		return
+	}
+	if err := checkJobPath(request.Path); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job path", "details": err.Error()})
+		return
+	}
+
+	if request.CallbackURL != "" {
+		if q.webhookSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid callback URL", "details": "callbacks are off: the server has no WEBHOOK_SECRET to sign them with"})
+			return
+		}
+		if err := checkCallbackURL(request.CallbackURL, q.webhookAllowPrivate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid callback URL", "details": err.Error()})
+			return
+		}
+	}
+
+	j, ok := q.submit(request.Path, request.CallbackURL, c.GetHeader("Accept-Language"))
+	if !ok {
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Job queue is full, try again later"})
+		return
+	}
+	c.Header("Location", "/v1/jobs/"+j.ID)
+	c.JSON(http.StatusAccepted, j)
+}
+
+// Report a job's status, and its result once finished
+func (q *jobQueue) handleStatus(c *gin.Context) {
+	j, ok := q.get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found", "details": "unknown job ID, or its result has expired"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, j)
+}
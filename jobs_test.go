@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to set up a router with the jobs API in front of a slow route,
+// which holds each request until release is closed
+func setupJobsRouter(workers, size int, release chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	jobs := newJobQueue(router, workers, size, time.Hour, "whsec", true)
+	router.POST("/v1/jobs", jobs.handleSubmit)
+	router.GET("/v1/jobs/:id", jobs.handleStatus)
+	router.GET("/slow/:amount", func(c *gin.Context) {
+		<-release
+		if c.Param("amount") == "bad" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"amount": c.Param("amount"), "lang": c.GetHeader("Accept-Language")})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("backtest blew up")
+	})
+	return router
+}
+
+// Helper function to submit a job
+func submitJob(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/v1/jobs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "de")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to poll a job until it finishes
+func waitForJob(t *testing.T, router *gin.Engine, id string) job {
+	for i := 0; i < 200; i++ {
+		w := makeTestRequest(router, "GET", "/v1/jobs/"+id)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var j job
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &j))
+		if j.Status == jobSucceeded || j.Status == jobFailed {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job didn't finish")
+	return job{}
+}
+
+// Test a job is queued, run, and its result collected
+func TestJobLifecycle(t *testing.T) {
+	release := make(chan struct{})
+	router := setupJobsRouter(1, 10, release)
+
+	w := submitJob(router, `{"path": "/slow/1000"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var submitted job
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.Equal(t, jobQueued, submitted.Status)
+	assert.Equal(t, "/v1/jobs/"+submitted.ID, w.Header().Get("Location"))
+	assert.Len(t, submitted.ID, 32)
+
+	w = makeTestRequest(router, "GET", "/v1/jobs/"+submitted.ID)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.NotContains(t, w.Body.String(), `"result"`)
+
+	close(release)
+	finished := waitForJob(t, router, submitted.ID)
+	assert.Equal(t, jobSucceeded, finished.Status)
+	assert.Equal(t, http.StatusOK, finished.StatusCode)
+	assert.JSONEq(t, `{"amount": "1000", "lang": "de"}`, string(finished.Result))
+	assert.NotNil(t, finished.StartedAt)
+	assert.NotNil(t, finished.FinishedAt)
+
+	w = submitJob(router, `{"path": "/slow/bad"}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	finished = waitForJob(t, router, submitted.ID)
+	assert.Equal(t, jobFailed, finished.Status)
+	assert.Equal(t, http.StatusBadRequest, finished.StatusCode)
+	assert.Contains(t, string(finished.Result), "Invalid amount")
+
+	w = makeTestRequest(router, "GET", "/v1/jobs/0123456789abcdef")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// Test a full queue turns jobs away
+func TestJobQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	router := setupJobsRouter(1, 1, release)
+
+	// One job running, one waiting: the queue is full
+	assert.Equal(t, http.StatusAccepted, submitJob(router, `{"path": "/slow/1"}`).Code)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, http.StatusAccepted, submitJob(router, `{"path": "/slow/2"}`).Code)
+
+	w := submitJob(router, `{"path": "/slow/3"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+// Test job requests are checked
+func TestJobSubmitValidation(t *testing.T) {
+	router := setupJobsRouter(1, 1, make(chan struct{}))
+
+	for _, body := range []string{`{}`, `not json`, `{"path": "http://evil.example/"}`, `{"path": "10/AAPL/on/2020-01-01"}`, `{"path": "/v1/jobs/abc"}`,
+		`{"path": "/1000USD/of/AAPL/on/2020-03-20 x"}`, `{"path": "/slow/1\tHTTP/1.1"}`, `{"path": "/slow/1\n"}`} {
+		w := submitJob(router, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+// Test a job that can't be requested, or whose handler panics, fails without
+// taking the worker down
+func TestJobFailuresDontKillWorker(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	router := setupJobsRouter(1, 10, release)
+
+	w := submitJob(router, `{"path": "/panic"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var submitted job
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	finished := waitForJob(t, router, submitted.ID)
+	assert.Equal(t, jobFailed, finished.Status)
+	assert.Equal(t, http.StatusInternalServerError, finished.StatusCode)
+
+	// A malformed path that got past the checks fails too
+	q := newJobQueue(router, 1, 10, time.Hour, "", false)
+	j, ok := q.submit("/1000USD/of/AAPL/on/2020-03-20 x\x7f%zz", "", "")
+	assert.True(t, ok)
+	for i := 0; i < 200; i++ {
+		if j, _ = q.get(j.ID); j.Status == jobFailed || j.Status == jobSucceeded {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, jobFailed, j.Status)
+
+	// The worker is still running jobs
+	w = submitJob(router, `{"path": "/slow/1000"}`)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.Equal(t, jobSucceeded, waitForJob(t, router, submitted.ID).Status)
+}
+
+// Test finished jobs expire after their ttl
+func TestJobPrune(t *testing.T) {
+	q := &jobQueue{ttl: time.Minute, jobs: map[string]*job{}}
+	now := time.Now()
+	old, recent := now.Add(-2*time.Minute), now.Add(-30*time.Second)
+	q.jobs["old"] = &job{ID: "old", FinishedAt: &old}
+	q.jobs["recent"] = &job{ID: "recent", FinishedAt: &recent}
+	q.jobs["running"] = &job{ID: "running"}
+
+	q.prune(now)
+	assert.NotContains(t, q.jobs, "old")
+	assert.Contains(t, q.jobs, "recent")
+	assert.Contains(t, q.jobs, "running")
+}
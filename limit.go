@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Order windows: a number of days, weeks, months or years (30d, 6w, 3m, 1y)
+var limitWindowRegex = regexp.MustCompile(`^([1-9][0-9]*)([dwmy])$`)
+
+// A hypothetical buy limit order placed on the buy date and left open for the
+// window, with where it filled. Prices are in USD, like buyPrice.
+type limitOrder struct {
+	LimitPrice float64 `json:"limitPrice"`
+	Window     string  `json:"window"`
+	OrderDate  string  `json:"orderDate"`
+	Until      string  `json:"until"`
+	Filled     bool    `json:"filled"`
+	FillDate   string  `json:"fillDate,omitempty"`
+	FillPrice  float64 `json:"fillPrice,omitempty"`
+	DaysToFill int     `json:"daysToFill"`
+
+	ticker string
+}
+
+// Helper function to price a leg at the order's fill: the fill price for the
+// ticker on the fill date, instead of the day's price
+func (o *limitOrder) priceOn(ticker, date string) (float64, bool) {
+	if o == nil || !o.Filled || date != o.FillDate || !strings.EqualFold(ticker, o.ticker) {
+		return 0, false
+	}
+	return o.FillPrice, true
+}
+
+// Helper function to work out the last day of an order window starting on a date
+func limitWindowEnd(orderDate, window string) (string, error) {
+	match := limitWindowRegex.FindStringSubmatch(window)
+	start, err := time.Parse("2006-01-02", orderDate)
+	if match == nil || err != nil {
+		return "", fmt.Errorf("Invalid window parameter: must be days, weeks, months or years, like 30d, 6w, 3m or 1y")
+	}
+	n, _ := strconv.Atoi(match[1])
+	switch match[2] {
+	case "d":
+		start = start.AddDate(0, 0, n)
+	case "w":
+		start = start.AddDate(0, 0, 7*n)
+	case "m":
+		start = start.AddDate(0, n, 0)
+	case "y":
+		start = start.AddDate(n, 0, 0)
+	}
+	return start.Format("2006-01-02"), nil
+}
+
+// Helper function to tell whether an asset's daily history has lows and opens;
+// crypto and commodities without a Stooq series are priced once a day
+func hasDailyRange(ticker, assetType string) bool {
+	switch assetType {
+	case "stock", "index":
+		return true
+	case "commodity":
+		return commodities[strings.ToUpper(ticker)].StooqSymbol != ""
+	}
+	return false
+}
+
+// Find where a buy limit order would have filled: the first day from the order
+// date through until whose low reached the limit, at the limit or at the day's
+// open when it opened below it. Assets priced once a day fill when that price
+// reaches the limit, at that price.
+func findLimitFill(order *limitOrder, assetType string, opts priceOptions) error {
+	lowOpts := opts
+	lowOpts.At = "close"
+	ranged := hasDailyRange(order.ticker, assetType)
+	if ranged {
+		lowOpts.At = "low"
+	}
+	points, err := fetchPriceHistory(order.ticker, assetType, order.OrderDate, order.Until, lowOpts)
+	if err != nil {
+		return err
+	}
+
+	for _, point := range points {
+		if point.Price > order.LimitPrice {
+			continue
+		}
+		order.Filled, order.FillDate, order.FillPrice = true, point.Date, point.Price
+		if ranged {
+			openOpts := opts
+			openOpts.At = "open"
+			open, err := fetchPrice(order.ticker, point.Date, assetType, openOpts)
+			if err != nil {
+				return err
+			}
+			order.FillPrice = math.Min(order.LimitPrice, open)
+		}
+		orderDay, _ := time.Parse("2006-01-02", order.OrderDate)
+		fillDay, _ := time.Parse("2006-01-02", point.Date)
+		order.DaysToFill = int(fillDay.Sub(orderDay).Hours() / 24)
+		return nil
+	}
+	return nil
+}
+
+// Middleware simulating ?limitPrice= (with ?window=, default 30d) on buy routes:
+// the buy happens where the limit order would have filled rather than on the buy
+// date, and the rest of the backtest runs from there
+func withLimitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if applyLimitOrder(c) {
+			c.Next()
+		}
+	}
+}
+
+// Helper function to move a request's buy date to its limit order's fill. Answers
+// and returns false when the order can't be simulated, or never filled.
+func applyLimitOrder(c *gin.Context) bool {
+	limit := c.Query("limitPrice")
+	buyDate, ok := c.Params.Get("buyDate")
+	if limit == "" || !ok {
+		return true
+	}
+	if c.Param("portfolio") != "" || c.Param("buyEnd") != "" || strings.HasSuffix(c.FullPath(), "/lump-sum-vs-dca") || strings.HasSuffix(c.FullPath(), "/buy-the-dip") {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limitPrice is only supported on single-asset buy, buy/sell, and-held, DRIP and dividend backtests"})
+		return false
+	}
+	assetType := assetTypeParam(c)
+	if isModelledType(assetType) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limitPrice is not supported for bonds or options"})
+		return false
+	}
+	if isStaggered(buyDate) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limitPrice can't be combined with several buy dates"})
+		return false
+	}
+	if hasTimeOfDay(buyDate) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Limit orders are simulated on daily prices: use a buy date without a time of day"})
+		return false
+	}
+
+	order := &limitOrder{Window: c.DefaultQuery("window", "30d"), OrderDate: buyDate, ticker: strings.ToUpper(c.Param("ticker"))}
+	var err error
+	if order.LimitPrice, err = strconv.ParseFloat(limit, 64); err != nil || order.LimitPrice <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid limitPrice parameter: must be a price in USD above 0"})
+		return false
+	}
+	if order.Until, err = limitWindowEnd(buyDate, order.Window); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	// The order stays open until the window ends, the day before the sale or the
+	// most recent close, whichever comes first
+	until := latestCloseDate(order.ticker, assetType, time.Now())
+	if sellDate := c.Param("sellDate"); sellDate != "" {
+		day, _ := time.Parse("2006-01-02", dateOnly(sellDate))
+		until = day.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	if until < order.Until {
+		order.Until = until
+	}
+
+	opts, err := priceOptionsParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := findLimitFill(order, assetType, opts); err != nil {
+		respondPriceError(c, "Failed to fetch price history", err)
+		c.Abort()
+		return false
+	}
+	if !order.Filled {
+		c.AbortWithStatusJSON(http.StatusOK, gin.H{
+			"message":    localize(c, "Limit order never filled"),
+			"ticker":     c.Param("ticker"),
+			"type":       assetType,
+			"limitOrder": order,
+		})
+		return false
+	}
+
+	for i, param := range c.Params {
+		if param.Key == "buyDate" {
+			c.Params[i].Value = order.FillDate
+		}
+	}
+	c.Set("limitOrder", order)
+	return true
+}
+
+// Helper function to add the limit order a backtest bought with, if any
+func addLimitOrder(response gin.H, opts priceOptions) {
+	if opts.Fill != nil {
+		response["limitOrder"] = opts.Fill
+	}
+}
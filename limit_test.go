@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test order windows run days, weeks, months or years on from the order date
+func TestLimitWindowEnd(t *testing.T) {
+	for window, until := range map[string]string{"30d": "2025-04-30", "2w": "2025-04-14", "2m": "2025-05-31", "1y": "2026-03-31"} {
+		got, err := limitWindowEnd("2025-03-31", window)
+		assert.NoError(t, err, window)
+		assert.Equal(t, until, got, window)
+	}
+	for _, window := range []string{"", "0d", "30", "1h", "-5d"} {
+		_, err := limitWindowEnd("2025-03-31", window)
+		assert.Error(t, err, window)
+	}
+}
+
+// Test the buy moves to the first day the limit was reached, at the limit or the
+// open below it
+func TestLimitOrderWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	type result struct {
+		BuyDate    string     `json:"buyDate"`
+		BuyPrice   float64    `json:"buyPrice"`
+		ClosePrice float64    `json:"closePrice"`
+		Finalvalue float64    `json:"finalValue"`
+		LimitOrder limitOrder `json:"limitOrder"`
+	}
+
+	// 2025-03-31 opened at 198.20, under the limit
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?limitPrice=199")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response result
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-03-31", response.BuyDate)
+	assert.Equal(t, 198.20, response.BuyPrice)
+	assert.InDelta(t, 10*211.18, response.Finalvalue, 1e-9)
+	assert.True(t, response.LimitOrder.Filled)
+	assert.Equal(t, "2025-04-30", response.LimitOrder.Until)
+	assert.Equal(t, 0, response.LimitOrder.DaysToFill)
+
+	// Scanning on, 2025-07-18's low of 209.70 reaches the limit
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-04-01?limitPrice=210&window=4m")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response = result{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-07-18", response.BuyDate)
+	assert.Equal(t, 210.0, response.ClosePrice)
+	assert.Equal(t, limitOrder{
+		LimitPrice: 210, Window: "4m", OrderDate: "2025-04-01", Until: "2025-08-01",
+		Filled: true, FillDate: "2025-07-18", FillPrice: 210, DaysToFill: 108,
+	}, response.LimitOrder)
+
+	// Never reached before the sale
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?limitPrice=150&window=1y")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response = result{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.BuyDate)
+	assert.False(t, response.LimitOrder.Filled)
+	assert.Equal(t, "2025-07-17", response.LimitOrder.Until)
+	assert.Contains(t, w.Body.String(), "Limit order never filled")
+}
+
+// Test limit orders that can't be simulated are answered with 400
+func TestLimitOrderErrors(t *testing.T) {
+	router := setupTestRouterWithMocks()
+
+	tests := map[string]string{
+		"/10/AAPL/on/2025-03-31?limitPrice=abc":                                              "Invalid limitPrice",
+		"/10/AAPL/on/2025-03-31?limitPrice=-1":                                               "Invalid limitPrice",
+		"/10/AAPL/on/2025-03-31?limitPrice=199&window=30x":                                   "Invalid window",
+		"/10/AAPL/on/2025-03-31T10:00?limitPrice=199":                                        "daily prices",
+		"/10/UST10Y/on/2025-03-31/and-sold-on/2025-07-18?type=bond&limitPrice=99":            "bonds",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca?limitPrice=1": "only supported",
+	}
+	for path, message := range tests {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), message, path)
+	}
+}
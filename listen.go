@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// First file descriptor systemd passes listening sockets from (SD_LISTEN_FDS_START)
+const systemdFirstFD = 3
+
+// Helper function to check a listen address: "host:port", ":port" or "unix:/path"
+func checkListenAddress(address string) error {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if path == "" {
+			return fmt.Errorf("listen address %q has no socket path", address)
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("listen address %q is not host:port or unix:/path", address)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("listen address %q has no port between 1 and 65535", address)
+	}
+	return nil
+}
+
+// Helper function to tell whether a listen address only takes local connections:
+// a Unix socket, or a loopback host
+func isLocalListenAddress(address string) bool {
+	if strings.HasPrefix(address, "unix:") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Open a listener on a "host:port" or "unix:/path" address, replacing a stale socket file
+func listen(address string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(address, "unix:"); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
+
+// Helper function to take the sockets systemd passed with socket activation
+// (LISTEN_PID and LISTEN_FDS), or none when it didn't
+func systemdListeners() ([]net.Listener, error) {
+	if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+		return nil, nil
+	}
+	count, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+
+	var listeners []net.Listener
+	for fd := systemdFirstFD; fd < systemdFirstFD+count; fd++ {
+		file := os.NewFile(uintptr(fd), "systemd-socket-"+strconv.Itoa(fd))
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %d: %v", fd, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// Open the listeners to serve on: the sockets systemd passed, otherwise every
+// LISTEN_ADDRESSES address, otherwise :PORT
+func openListeners(cfg Config) ([]net.Listener, error) {
+	listeners, err := systemdListeners()
+	if err != nil || len(listeners) > 0 {
+		return listeners, err
+	}
+
+	addresses := splitList(cfg.ListenAddresses)
+	if len(addresses) == 0 {
+		addresses = []string{":" + strconv.Itoa(cfg.Port)}
+	}
+	for _, address := range addresses {
+		listener, err := listen(address)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test listen addresses are host:port or unix:/path
+func TestCheckListenAddress(t *testing.T) {
+	assert.NoError(t, checkListenAddress("127.0.0.1:8080"))
+	assert.NoError(t, checkListenAddress(":9090"))
+	assert.NoError(t, checkListenAddress("[::1]:8080"))
+	assert.NoError(t, checkListenAddress("unix:/run/ifyoubought.sock"))
+
+	assert.ErrorContains(t, checkListenAddress("8080"), "is not host:port or unix:/path")
+	assert.ErrorContains(t, checkListenAddress("localhost:http"), "has no port")
+	assert.ErrorContains(t, checkListenAddress("unix:"), "has no socket path")
+}
+
+// Test serving on a TCP address and a Unix socket at once
+func TestOpenListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	assert.NoError(t, os.WriteFile(socket, nil, 0o600)) // stale socket file from a previous run
+
+	cfg := defaultConfig()
+	cfg.ListenAddresses = "127.0.0.1:0, unix:" + socket
+	listeners, err := openListeners(cfg)
+	assert.NoError(t, err)
+	assert.Len(t, listeners, 2)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})}
+	for _, listener := range listeners {
+		go server.Serve(listener)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + listeners[0].Addr().String() + "/")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	unixClient := &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+	}}}
+	resp, err = unixClient.Get("http://unix/")
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+}
+
+// Test systemd sockets are only taken when they were passed to this process
+func TestSystemdListenersOtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	listeners, err := systemdListeners()
+	assert.NoError(t, err)
+	assert.Empty(t, listeners)
+}
@@ -2,27 +2,47 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/piquette/finance-go/datetime"
 )
 
-// Environment variables
+// Settings read only at startup, loaded by loadConfig (see Config for their keys
+// and defaults). The rest are read through settings().
 var (
-	alphaVantageAPIKey  = getEnv("ALPHA_VANTAGE_API_KEY", "2G2R3SZ8BNV2EGAL")
-	alphaVantageBaseURL = getEnv("ALPHA_VANTAGE_BASE_URL", "https://www.alphavantage.co")
-	frankfurterBaseURL  = getEnv("FRANKFURTER_BASE_URL", "https://api.frankfurter.app")
-	serverPort          = getEnv("PORT", "8080")
-	ginMode             = getEnv("GIN_MODE", "debug")
+	fxDatasetPath        string
+	corporateActionsPath string
+	equivalentsPath      string
+	exchangeHolidaysPath string
+	corsAllowedOrigins   string
+	corsAllowedMethods   string
+	corsAllowedHeaders   string
+	corsMaxAge           string
+	maxURLBytes          int64
+	maxBodyBytes         int64
+	serverPort           string
+	ginMode              string
 )
 
+// Start with the defaults and environment; main loads the config file on top
+func init() {
+	cfg, _ := loadConfig("")
+	applyConfig(cfg)
+}
+
 // Helper function to get environment variables with defaults
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -44,16 +64,56 @@ type alphaVantageDailyAdjustedResponse struct {
 	TimeSeries map[string]map[string]string `json:"Time Series (Daily)"`
 }
 
-// Dividend data structure
+// Dividend data structure. Ownership is checked on the ex-dividend date and the
+// cash arrives on the payment date.
 type dividendData struct {
-	Date   string  `json:"date"`
-	Amount float64 `json:"amount"`
+	ExDate      string  `json:"exDate"`
+	PaymentDate string  `json:"paymentDate"`
+	Amount      float64 `json:"amount"`
+}
+
+// Alpha Vantage series fields for each selectable price point
+var priceAtFields = map[string]string{
+	"open":  "1. open",
+	"high":  "2. high",
+	"low":   "3. low",
+	"close": "4. close",
+}
+
+// Which price to use for each leg: the point of the day and whether closes are
+// split/dividend-adjusted. Source, when set, is the one stock provider to use
+// (?source=). Notes, when set, records where prices came from; Fill, when set, is
+// a limit order's fill, priced in place of its day's price.
+type priceOptions struct {
+	At       string
+	Adjusted bool
+	Source   string
+	Verify   bool // check stock prices against a second provider
+	Lenient  bool // price weekends and holidays on the trading day before, unless ?strict=true
+	Notes    *dataNotes
+	Fill     *limitOrder
+}
+
+// Helper function to label the price basis an asset was priced on. Only stocks
+// have corporate actions; crypto and index levels are always unadjusted.
+func (opts priceOptions) basis(assetType string) string {
+	if opts.Adjusted && assetType == "stock" {
+		return "adjusted"
+	}
+	return "unadjusted"
 }
 
-// Fetch historical daily close price for a given ticker and date (YYYY-MM-DD)
-func fetchStockDailyCloseAlphaVantage(ticker, date string) (float64, error) {
-	url := fmt.Sprintf("%s/query?function=TIME_SERIES_DAILY&symbol=%s&apikey=%s", alphaVantageBaseURL, ticker, alphaVantageAPIKey)
-	resp, err := http.Get(url)
+// Fetch historical daily price (open/high/low/close) for a given ticker and date (YYYY-MM-DD).
+// Adjusted prices come from TIME_SERIES_DAILY_ADJUSTED, scaling the day's raw price by
+// its adjusted-to-raw close ratio.
+func fetchStockDailyPriceAlphaVantage(ticker, date string, opts priceOptions) (float64, error) {
+	cfg := settings()
+	function := "TIME_SERIES_DAILY"
+	if opts.Adjusted {
+		function = "TIME_SERIES_DAILY_ADJUSTED"
+	}
+	url := fmt.Sprintf("%s/query?function=%s&symbol=%s&apikey=%s", cfg.AlphaVantageBaseURL, function, ticker, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
 	if err != nil {
 		return 0, err
 	}
@@ -82,16 +142,185 @@ func fetchStockDailyCloseAlphaVantage(ticker, date string) (float64, error) {
 		return 0, fmt.Errorf("No data for date %s", date)
 	}
 
-	closeStr, ok := dayData["4. close"]
+	priceStr, ok := dayData[priceAtFields[opts.At]]
 	if !ok {
-		return 0, fmt.Errorf("No close price for date %s", date)
+		return 0, fmt.Errorf("No %s price for date %s", opts.At, date)
 	}
 
-	closeVal, err := strconv.ParseFloat(closeStr, 64)
+	price, err := strconv.ParseFloat(priceStr, 64)
 	if err != nil {
 		return 0, err
 	}
-	return closeVal, nil
+	if !opts.Adjusted {
+		return price, nil
+	}
+
+	adjustedClose, err := strconv.ParseFloat(dayData["5. adjusted close"], 64)
+	if err != nil {
+		return 0, fmt.Errorf("No adjusted close for date %s", date)
+	}
+	closeVal, err := strconv.ParseFloat(dayData["4. close"], 64)
+	if err != nil || closeVal == 0 {
+		return 0, fmt.Errorf("No close price for date %s", date)
+	}
+	return price * adjustedClose / closeVal, nil
+}
+
+// Where today's prices in the latest data cache came from, by cache key
+var (
+	latestPriceAnswersMu sync.Mutex
+	latestPriceAnswers   = map[string]priceAnswer{}
+)
+
+// Fetch the price of an asset on a date at the given point of the day (open, high,
+// low or close), from the price cache for past days and otherwise from the provider.
+// Dates before the symbol first traded fail with a beforeFirstTradeError.
+func fetchPrice(ticker, date, assetType string, opts priceOptions) (float64, error) {
+	if price, ok := opts.Fill.priceOn(ticker, date); ok {
+		return price, nil
+	}
+	if opts.Lenient {
+		if day := lastTradingDayOnOrBefore(ticker, date, assetType); day != date {
+			return fetchPriceMovedTo(ticker, date, day, assetType, opts)
+		}
+	}
+
+	// Fetches kept for the background refresher mustn't hold on to the request's notes
+	notes := opts.Notes
+	opts.Notes = nil
+
+	key := newPriceKey(ticker, date, assetType, opts)
+	source := dataSource{Kind: "price", Symbol: key.Ticker, Date: date, Provider: priceProvider(assetType, opts.Source)}
+	if entry, ok := prices.entry(key); ok {
+		source.Cached, source.RetrievedAt = true, entry.FetchedAt
+		source.Check = opts.check(ticker, date, assetType, source.Provider, entry.Price)
+		notes.add(source)
+		return entry.Price, nil
+	}
+
+	var price float64
+	var answer priceAnswer
+	var err error
+	if date == time.Now().UTC().Format("2006-01-02") {
+		start := time.Now().UTC()
+		price, _, err = latest.lookup(latestPriceKey(key), date, func() (float64, string, error) {
+			price, answer, err := fetchProviderPrice(ticker, date, assetType, opts)
+			if err == nil {
+				latestPriceAnswersMu.Lock()
+				latestPriceAnswers[latestPriceKey(key)] = answer
+				latestPriceAnswersMu.Unlock()
+			}
+			return price, answer.Date, err
+		})
+		if err != nil {
+			return 0, err
+		}
+		latestPriceAnswersMu.Lock()
+		answer = latestPriceAnswers[latestPriceKey(key)]
+		latestPriceAnswersMu.Unlock()
+		source.RetrievedAt, source.Stale = latest.fetchedAt(latestPriceKey(key), start)
+		source.Cached = source.RetrievedAt.Before(start)
+	} else {
+		price, answer, err = fetchProviderPrice(ticker, date, assetType, opts)
+		if err != nil {
+			return 0, explainMissingPrice(ticker, date, assetType, err)
+		}
+		source.RetrievedAt = time.Now().UTC()
+		prices.put(key, price)
+	}
+
+	if answer.Date != date {
+		source.ActualDate = answer.Date
+	}
+	if answer.Provider != "" {
+		source.Provider, source.Fallback = answer.Provider, answer.Fallback
+	}
+	source.Check = opts.check(ticker, date, assetType, source.Provider, price)
+	notes.add(source)
+	return price, nil
+}
+
+// What a provider answered a price with: the date (or time) the price is for, and
+// the provider when a fallback answered instead of the asset type's usual one
+type priceAnswer struct {
+	Date     string
+	Provider string
+	Fallback bool
+}
+
+// Fetch the price of an asset on a date from its provider, routing crypto assets
+// to the crypto providers, indices to Stooq and commodities to their spot price
+// provider. Dates with a time of day (2024-05-01T14:30) are priced from intraday
+// data, and the time of the price found is returned with it.
+func fetchProviderPrice(ticker, date, assetType string, opts priceOptions) (float64, priceAnswer, error) {
+	if isModelledType(assetType) {
+		return 0, priceAnswer{}, fmt.Errorf("Prices for type %s depend on the purchase; use the buy/sell route", assetType)
+	}
+	if hasTimeOfDay(date) {
+		if assetType == "index" || assetType == "commodity" {
+			return 0, priceAnswer{}, fmt.Errorf("Prices for type %s are only available daily", assetType)
+		}
+		return fetchIntradayPrice(ticker, date, assetType, opts)
+	}
+
+	var price float64
+	var err error
+	switch assetType {
+	case "commodity":
+		price, err = fetchCommodityPriceUSD(ticker, date, opts.At)
+	case "index":
+		price, err = fetchIndexPriceUSD(ticker, date, opts.At)
+	case "crypto":
+		return fetchCryptoDailyPriceAtUSD(strings.ToUpper(ticker), date, opts.At, opts.Source)
+	default:
+		return fetchStockDailyPrice(ticker, date, opts)
+	}
+	return price, priceAnswer{Date: date}, err
+}
+
+// Helper function to read and validate ?priceAt= (default close) and ?adjusted=
+func priceOptionsParam(c *gin.Context) (priceOptions, error) {
+	opts := priceOptions{At: c.Query("priceAt"), Verify: settings().VerifyPrices, Lenient: true, Notes: requestNotes(c)}
+	if order, ok := c.Get("limitOrder"); ok {
+		opts.Fill = order.(*limitOrder)
+	}
+	if opts.At == "" {
+		opts.At = "close"
+	}
+	if _, ok := priceAtFields[opts.At]; !ok {
+		return opts, fmt.Errorf("Invalid priceAt parameter: must be 'open', 'high', 'low' or 'close'")
+	}
+
+	if adjusted := c.Query("adjusted"); adjusted != "" {
+		var err error
+		opts.Adjusted, err = strconv.ParseBool(adjusted)
+		if err != nil {
+			return opts, fmt.Errorf("Invalid adjusted parameter: must be 'true' or 'false'")
+		}
+	}
+	if verify := c.Query("verify"); verify != "" {
+		var err error
+		opts.Verify, err = strconv.ParseBool(verify)
+		if err != nil {
+			return opts, fmt.Errorf("Invalid verify parameter: must be 'true' or 'false'")
+		}
+	}
+
+	if strict := c.Query("strict"); strict != "" {
+		isStrict, err := strconv.ParseBool(strict)
+		if err != nil {
+			return opts, fmt.Errorf("Invalid strict parameter: must be 'true' or 'false'")
+		}
+		opts.Lenient = !isStrict
+	}
+
+	if source := c.Query("source"); source != "" {
+		var ok bool
+		if opts.Source, ok = selectableSource(source); !ok {
+			return opts, fmt.Errorf("Invalid source parameter: must be a configured provider (%s)", strings.Join(selectableSourceNames(), ", "))
+		}
+	}
+	return opts, nil
 }
 
 func min(a, b int) int {
@@ -101,11 +330,13 @@ func min(a, b int) int {
 	return b
 }
 
-// Fetch historical dividends for a given ticker and date range
+// Fetch historical dividends with an ex-dividend date after the buy date and on or
+// before the sell date (buying on the ex-date no longer earns the dividend)
+// Example: https://www.alphavantage.co/query?function=DIVIDENDS&symbol=IBM&apikey=demo
 func fetchStockDividendsAlphaVantage(ticker, startDate, endDate string) ([]dividendData, error) {
-	// Use Alpha Vantage TIME_SERIES_MONTHLY_ADJUSTED endpoint
-	url := fmt.Sprintf("%s/query?function=TIME_SERIES_MONTHLY_ADJUSTED&symbol=%s&apikey=%s", alphaVantageBaseURL, ticker, alphaVantageAPIKey)
-	resp, err := http.Get(url)
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=DIVIDENDS&symbol=%s&apikey=%s", cfg.AlphaVantageBaseURL, ticker, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -120,92 +351,425 @@ func fetchStockDividendsAlphaVantage(ticker, startDate, endDate string) ([]divid
 	fmt.Printf("Alpha Vantage dividend response (first 500 chars): %s\n", string(body[:min(500, len(body))]))
 
 	var result struct {
-		TimeSeries map[string]map[string]string `json:"Monthly Adjusted Time Series"`
+		Data []struct {
+			ExDividendDate string `json:"ex_dividend_date"`
+			PaymentDate    string `json:"payment_date"`
+			Amount         string `json:"amount"`
+		} `json:"data"`
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
 	}
 
-	if result.TimeSeries == nil {
-		return nil, fmt.Errorf("No time series data returned from Alpha Vantage")
+	if result.Data == nil {
+		return nil, fmt.Errorf("No dividend data returned from Alpha Vantage")
 	}
 
-	start, err := time.Parse("2006-01-02", startDate)
-	if err != nil {
-		return nil, err
-	}
-	end, err := time.Parse("2006-01-02", endDate)
-	if err != nil {
-		return nil, err
-	}
+	start := dateOnly(startDate)
+	end := dateOnly(endDate)
 
 	var dividends []dividendData
-	for dateStr, data := range result.TimeSeries {
-		divDate, err := time.Parse("2006-01-02", dateStr)
-		if err != nil {
+	for _, event := range result.Data {
+		if event.ExDividendDate <= start || event.ExDividendDate > end {
 			continue
 		}
-		if (divDate.After(start) || divDate.Equal(start)) && (divDate.Before(end) || divDate.Equal(end)) {
-			dividendStr := data["7. dividend amount"]
-			amount, err := strconv.ParseFloat(dividendStr, 64)
-			if err != nil || amount == 0 {
-				continue
-			}
-			dividends = append(dividends, dividendData{
-				Date:   dateStr,
-				Amount: amount,
-			})
+		amount, err := strconv.ParseFloat(event.Amount, 64)
+		if err != nil || amount == 0 {
+			continue
+		}
+
+		// Alpha Vantage reports unknown payment dates as "None"
+		paymentDate := event.PaymentDate
+		if _, err := time.Parse("2006-01-02", paymentDate); err != nil {
+			paymentDate = event.ExDividendDate
 		}
+
+		dividends = append(dividends, dividendData{
+			ExDate:      event.ExDividendDate,
+			PaymentDate: paymentDate,
+			Amount:      amount,
+		})
 	}
 
+	sort.Slice(dividends, func(i, j int) bool { return dividends[i].ExDate < dividends[j].ExDate })
 	return dividends, nil
 }
 
-// Calculate DRIP reinvestment
-func calculateDRIP(shares float64, dividends []dividendData, stockPrice float64) (float64, []dividendData) {
+// A dividend event in a DRIP backtest
+type dripEvent struct {
+	ExDate         string  `json:"exDate"`
+	PaymentDate    string  `json:"paymentDate"`
+	SharesHeld     float64 `json:"sharesHeld"`
+	Amount         float64 `json:"amount"`
+	ReinvestDate   string  `json:"reinvestDate,omitempty"` // when it isn't the payment date
+	Price          float64 `json:"price,omitempty"`
+	Fee            float64 `json:"fee,omitempty"`
+	SharesBought   float64 `json:"sharesBought"`
+	CashKept       float64 `json:"cashKept,omitempty"`       // the part not reinvested, with a partial DRIP
+	InterestEarned float64 `json:"interestEarned,omitempty"` // on the cash not reinvested, with ?cashInterest=true
+}
+
+// Calculate DRIP reinvestment. Each dividend is paid on the shares held on its
+// ex-date, including shares bought by earlier reinvestments already settled by
+// then, and is reinvested at priceOn(reinvest date): its payment date, or the end
+// of its month or quarter when swept, plus any settlement lag. Dividends sharing
+// a reinvest date are bought in one reinvestment with one fee, shared out by
+// amount. A partial DRIP reinvests only its percent of each, keeping the rest.
+// Dividends not reinvested by the sell date, or no bigger than the fee, are
+// returned as cash, with what was kept.
+func calculateDRIP(shares float64, dividends []dividendData, sellDate string, opts dripOptions, priceOn func(date string) (float64, error)) (float64, []dripEvent, float64, error) {
 	totalReinvestedShares := 0.0
-	reinvestedDividends := []dividendData{}
+	cashAfterSale := 0.0
+	events := []dripEvent{}
+
+	// Reinvest the dividends waiting for the same date together
+	var batch []int
+	batchOn := ""
+	reinvestBatch := func() error {
+		total := 0.0
+		for _, i := range batch {
+			reinvested, _ := opts.split(events[i].Amount)
+			total += reinvested
+		}
+		fee := opts.fee(total)
+		if batchOn > dateOnly(sellDate) || fee >= total {
+			for _, i := range batch {
+				cashAfterSale += events[i].Amount
+			}
+			batch = nil
+			return nil
+		}
+		price, err := priceOn(batchOn)
+		if err != nil {
+			return fmt.Errorf("Failed to price reinvestment on %s: %v", batchOn, err)
+		}
+		for _, i := range batch {
+			reinvested, kept := opts.split(events[i].Amount)
+			events[i].Price, events[i].Fee, events[i].CashKept = price, fee*reinvested/total, kept
+			events[i].SharesBought = (reinvested - events[i].Fee) / price
+			totalReinvestedShares += events[i].SharesBought
+			cashAfterSale += kept
+		}
+		batch = nil
+		return nil
+	}
 
 	for _, dividend := range dividends {
-		// Calculate dividend payment for current shares
-		dividendPayment := shares * dividend.Amount
+		reinvestOn, err := opts.reinvestDate(dividend.PaymentDate)
+		if err != nil {
+			return 0, nil, 0, err
+		}
+		if len(batch) > 0 && reinvestOn != batchOn {
+			if err := reinvestBatch(); err != nil {
+				return 0, nil, 0, err
+			}
+		}
+
+		// Shares held at the ex-date
+		sharesHeld := shares
+		for _, event := range events {
+			if event.reinvestedOn() < dividend.ExDate {
+				sharesHeld += event.SharesBought
+			}
+		}
+
+		event := dripEvent{
+			ExDate:      dividend.ExDate,
+			PaymentDate: dividend.PaymentDate,
+			SharesHeld:  sharesHeld,
+			Amount:      sharesHeld * dividend.Amount,
+		}
+		if reinvestOn != dividend.PaymentDate {
+			event.ReinvestDate = reinvestOn
+		}
+		events = append(events, event)
+		batch, batchOn = append(batch, len(events)-1), reinvestOn
+	}
+	if len(batch) > 0 {
+		if err := reinvestBatch(); err != nil {
+			return 0, nil, 0, err
+		}
+	}
+
+	return totalReinvestedShares, events, cashAfterSale, nil
+}
+
+// Helper function to find the last day an asset's exchange traded on or before a
+// date, within a week. Crypto trades every day, and times of day aren't moved.
+func lastTradingDayOnOrBefore(ticker, date, assetType string) string {
+	market := exchangeFor(ticker, assetType)
+	if market == nil || hasTimeOfDay(date) || isModelledType(assetType) {
+		return date
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return date
+	}
+	for i := 0; i < 7; i++ {
+		if market.isTradingDay(day.AddDate(0, 0, -i)) {
+			return day.AddDate(0, 0, -i).Format("2006-01-02")
+		}
+	}
+	return date
+}
 
-		// Calculate additional shares from dividend reinvestment
-		additionalShares := dividendPayment / stockPrice
+// Helper function to price an asset on another day than asked (or on the day
+// itself), noting the price as the one for date, from the day it's for
+func fetchPriceMovedTo(ticker, date, day, assetType string, opts priceOptions) (float64, error) {
+	notes := opts.Notes
+	tried := &dataNotes{}
+	opts.Notes, opts.Lenient = tried, false
+	price, err := fetchPrice(ticker, day, assetType, opts)
+	if err != nil {
+		return 0, err
+	}
+	for _, source := range tried.sources {
+		if source.ActualDate == "" && day != date {
+			source.ActualDate = source.Date
+		}
+		source.Date = date
+		notes.add(source)
+	}
+	return price, nil
+}
 
-		if additionalShares > 0 {
-			totalReinvestedShares += additionalShares
-			reinvestedDividends = append(reinvestedDividends, dividendData{
-				Date:   dividend.Date,
-				Amount: dividendPayment,
-			})
+// Helper function to price an asset on a date, moving forward over its exchange's
+// weekends and holidays by up to a week (for dividend payment dates). Crypto has
+// a price every date, so is priced on the date itself.
+func fetchPriceOnOrAfter(ticker, date, assetType string, opts priceOptions) (float64, error) {
+	if assetType == "crypto" {
+		return fetchPrice(ticker, date, assetType, opts)
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+
+	// Note the day's price as the one for date, moved on to the day it's from
+	market := exchangeFor(ticker, assetType)
+	lastErr := fmt.Errorf("No trading day within a week of %s", date)
+	for i := 0; i < 7; i++ {
+		// Days the exchange is closed have no close to fetch
+		if market != nil && !market.isTradingDay(day.AddDate(0, 0, i)) {
+			continue
+		}
+		price, err := fetchPriceMovedTo(ticker, date, day.AddDate(0, 0, i).Format("2006-01-02"), assetType, opts)
+		if err == nil {
+			return price, nil
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
+// A dividend kept as cash, with the interest it earned until the sell date
+type cashDividend struct {
+	ExDate         string  `json:"exDate"`
+	PaymentDate    string  `json:"paymentDate"`
+	Amount         float64 `json:"amount"`
+	InterestEarned float64 `json:"interestEarned"`
+}
+
+// Calculate dividends received as cash, each earning an annual deposit rate
+// (percent, compounded annually) from its payment date until the sell date.
+// Dividends paid after the sell date earn no interest.
+func calculateDividendIncome(shares float64, dividends []dividendData, depositRate float64, sellDate string) ([]cashDividend, float64, float64, error) {
+	end, err := time.Parse("2006-01-02", dateOnly(sellDate))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	payments := []cashDividend{}
+	income, interest := 0.0, 0.0
+	for _, dividend := range dividends {
+		paid, err := time.Parse("2006-01-02", dividend.PaymentDate)
+		if err != nil {
+			return nil, 0, 0, err
 		}
+
+		payment := shares * dividend.Amount
+		years := math.Max(0, end.Sub(paid).Hours()/24/365.25)
+		earned := payment*math.Pow(1+depositRate/100, years) - payment
+
+		income += payment
+		interest += earned
+		payments = append(payments, cashDividend{ExDate: dividend.ExDate, PaymentDate: dividend.PaymentDate, Amount: payment, InterestEarned: earned})
 	}
 
-	return totalReinvestedShares, reinvestedDividends
+	return payments, income, interest, nil
 }
 
 func main() {
-	// Set Gin mode from environment
+	// Load settings from the config file (-config or CONFIG_FILE), environment and
+	// secret store (reached through the outbound proxy), failing fast on anything
+	// missing or invalid
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	flag.Parse()
+	cfg, err := loadConfig(*configPath)
+	if err == nil {
+		outboundClient, err = newOutboundClient(cfg.OutboundProxy, cfg.CABundlePath)
+	}
+	if err == nil {
+		err = loadSecrets(&cfg)
+	}
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	applyConfig(cfg)
+	reloadOnSIGHUP(*configPath, cfg)
+	refreshSecretsPeriodically()
+	warmPricesPeriodically()
+	refreshLatestPeriodically()
+
+	// Set Gin mode from the config
 	gin.SetMode(ginMode)
 
 	r := gin.Default()
 
-	// Serve static files for the UI
-	r.Static("/", "./static")
+	// Say which build answered, in X-Version
+	r.Use(withVersionHeader())
+
+	// Security headers, and limits on methods, URL, body and path parameter sizes
+	r.Use(withSecurityHeaders(), withRequestLimits(maxURLBytes, maxBodyBytes))
+
+	// Let browsers on CORS_ALLOWED_ORIGINS call the API
+	r.Use(withCORS(splitList(corsAllowedOrigins), splitList(corsAllowedMethods), splitList(corsAllowedHeaders), corsMaxAge))
+
+	// Reject impossible amounts, tickers and dates before they cost a provider request
+	r.Use(withInputValidation())
+
+	// Run heavy backtests in the background: POST a path to /v1/jobs, then poll
+	// /v1/jobs/:id for the result or get it POSTed to a callback URL. Registered before the caching, formatting and
+	// rounding below, which the job's own request already went through.
+	jobs := newJobQueue(r, cfg.JobWorkers, cfg.JobQueueSize, time.Duration(cfg.JobTTL)*time.Second, cfg.WebhookSecret, cfg.WebhookAllowPrivate)
+	r.POST("/v1/jobs", jobs.handleSubmit)
+	r.GET("/v1/jobs/:id", jobs.handleStatus)
+
+	// Alerts on hypothetical positions, checked every ALERT_CHECK_INTERVAL seconds and
+	// delivered by email or signed webhook when they cross their threshold. Private to
+	// the API key (X-API-Key) that registered them.
+	alerts, err := newAlertStore(cfg.AlertsPath, alertNotifier{
+		WebhookSecret: cfg.WebhookSecret, WebhookAllowPrivate: cfg.WebhookAllowPrivate,
+		SMTPAddr: cfg.SMTPAddr, SMTPUsername: cfg.SMTPUsername, SMTPPassword: cfg.SMTPPassword, SMTPFrom: cfg.SMTPFrom,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	alerts.run(time.Duration(cfg.AlertCheckInterval) * time.Second)
+	alertRoutes := r.Group("/v1/alerts", withAPIKey(splitList(cfg.APIKeys)))
+	alertRoutes.POST("", alerts.handleCreate)
+	alertRoutes.GET("/:id", alerts.handleGet)
+	alertRoutes.DELETE("/:id", alerts.handleDelete)
+
+	// Watchlists of saved scenarios, private to the API key (X-API-Key) that saved them
+	watchlists, err := newWatchlistStore(cfg.WatchlistsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lists := r.Group("/v1/watchlists", withAPIKey(splitList(cfg.APIKeys)))
+	lists.GET("", watchlists.handleList)
+	lists.POST("", watchlists.handleCreate)
+	lists.GET("/:id", watchlists.handleGet)
+	lists.PUT("/:id", watchlists.handleUpdate)
+	lists.DELETE("/:id", watchlists.handleDelete)
+	lists.GET("/:id/evaluate", watchlists.handleEvaluate)
+
+	// Providers' circuit breakers, errors, latency and remaining quota, so users
+	// can see why data might be degraded
+	r.GET("/v1/providers/status", handleProviderStatus)
+
+	// Cache results with ETags, compress them (Accept-Encoding), render them as
+	// text, markdown or HTML (?format=), round money, percentages and quantities
+	// in them (?precision=), and note data that was cached, from a fallback
+	// provider or for another date than asked
+	r.Use(withCaching(), withCompression(), withFormat(), withRounding(), withDataNotes())
+
+	// Buy where a limit order (?limitPrice=, ?window=) would have filled
+	r.Use(withLimitOrder())
 
 	// Quantity-based routes
 	r.GET("/:amount/:ticker/on/:buyDate", handleAmountBuy)
 	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
 	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/lump-sum-vs-dca", handleLumpSumVsDCA)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/buy-the-dip", handleBuyTheDip)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/sold-too-early", handleSoldTooEarly)
+	r.GET("/:amount/:ticker/bought-between/:buyDate/:buyEnd", handleAmountBoughtBetween)
+	r.GET("/:amount/:ticker/bought-between/:buyDate/:buyEnd/and-sold-on/:sellDate", handleAmountBoughtBetween)
 
 	// Value-based routes
 	r.GET("/:amount/of/:ticker/on/:buyDate", handleAmountBuy)
 	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
 	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
-
-	// Start server with configured port
-	r.Run(":" + serverPort)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/lump-sum-vs-dca", handleLumpSumVsDCA)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/buy-the-dip", handleBuyTheDip)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/sold-too-early", handleSoldTooEarly)
+	r.GET("/:amount/of/:ticker/bought-between/:buyDate/:buyEnd", handleAmountBoughtBetween)
+	r.GET("/:amount/of/:ticker/bought-between/:buyDate/:buyEnd/and-sold-on/:sellDate", handleAmountBoughtBetween)
+
+	// The same backtests as query parameters, for clients building requests
+	// programmatically ("?ticker=AAPL&amount=1000&buy=2020-03-20&sell=2024-03-20")
+	r.GET("/v1/backtest", handleBacktestQuery)
+
+	// Backtests as a JSON body with every option (fees, taxes, dividends, benchmark),
+	// which the URL grammar can't keep taking on
+	r.POST("/v1/scenario", handleScenario)
+
+	// A portfolio of buys uploaded as CSV (date, ticker, quantity or amount, currency)
+	r.POST("/v1/portfolio/import", handlePortfolioImport)
+
+	// Portfolio routes ("10000USD in AAPL:60,MSFT:40")
+	r.GET("/:amount/in/:portfolio/on/:buyDate/and-sold-on/:sellDate", handlePortfolioBuySell)
+
+	// Rolling-returns analysis ("every 5-year hold of AAPL")
+	r.GET("/rolling/:ticker", handleRollingReturns)
+
+	// Build info
+	r.GET("/version", handleVersion)
+
+	// Buying at the worst moment: the all-time-high close before a date ("even if
+	// you'd bought at the peak")
+	peaks := r.Group("/", withPeakBuy())
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore", handleAmountBuy)
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate", handleAmountBuySell)
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore/and-held", handleAmountBuyHeld)
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore", handleAmountBuy)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate", handleAmountBuySell)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-held", handleAmountBuyHeld)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+
+	// Crypto swap routes ("1ETH into SOL"), priced as crypto unless ?type= says otherwise
+	swaps := r.Group("/", withDefaultType("crypto"))
+	swaps.GET("/:amount/into/:ticker/on/:buyDate", handleAmountBuy)
+	swaps.GET("/:amount/into/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	swaps.GET("/:amount/into/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
+
+	// Serve static files for the UI from any path the API doesn't use
+	r.NoRoute(gin.WrapH(http.FileServer(http.Dir("./static"))))
+
+	// Start server with configured port, over HTTPS and HTTP/2 when TLS is configured,
+	// with timeouts so slow clients can't hold connections
+	server := &http.Server{
+		Addr:              ":" + serverPort,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      120 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 16,
+	}
+	serveAdmin(cfg)
+	log.Fatal(listenAndServe(server, cfg))
 }
 
 // Utility function stubs
@@ -215,110 +779,150 @@ func fetchStockHistory(ticker string, start, end *datetime.Datetime) (interface{
 	return nil, nil
 }
 
-// Fetch historical crypto prices from CoinGecko
-func fetchCryptoHistory(coinID string, fromUnix, toUnix int64) ([][2]float64, error) {
-	// TODO: Implement using CoinGecko API
-	return nil, nil
-}
+// Amount pattern: optional currency prefix, number, optional magnitude, optional currency suffix
+// (e.g. 1000, 1000EUR, $1000, €1.000,50, 1 000kr, 10k, 2.5mUSD, 10K USD, 0.5BTC)
+var amountRegex = regexp.MustCompile(`^(\p{Sc}|[A-Z]{3,5}|kr)?\s*([-+]?[.,]?[0-9](?:[0-9.,'\s\x{00a0}\x{202f}\x{2009}]*[0-9])?)\s*(bn|BN|[kKmMbB])?\s*(\p{Sc}|[A-Z]{3,5}|kr)?$`)
+
+// Helper function to determine if amount is quantity or value, and extract currency.
+// locale is an optional hint (e.g. "de" or "en-US") for reading the decimal separator.
+func parseAmount(amount, locale string) (float64, string, bool, error) {
+	match := amountRegex.FindStringSubmatch(strings.TrimSpace(amount))
+	if match == nil {
+		return 0, "", false, fmt.Errorf("Unrecognised amount %q", amount)
+	}
 
-// Helper function to determine if amount is quantity or value, and extract currency
-func parseAmount(amount string) (float64, string, bool) {
-	// Regex to extract currency symbol or code (e.g. $, €, £, ¥, USD, EUR, GBP, etc.)
-	currencyRegex := regexp.MustCompile(`([\p{Sc}]|[A-Z]{3})`)
-	currencyMatch := currencyRegex.FindString(amount)
+	prefix, number, magnitude, suffix := match[1], match[2], match[3], match[4]
 
-	// Regex to extract the numeric part (supports decimals and minus)
-	numRegex := regexp.MustCompile(`[-+]?[0-9]*\.?[0-9]+`)
-	numMatch := numRegex.FindString(amount)
+	// "10MATIC" is 10 MATIC rather than 10 million ATIC
+	if isCryptoCurrency(strings.ToUpper(magnitude) + suffix) {
+		magnitude, suffix = "", strings.ToUpper(magnitude)+suffix
+	}
 
-	if numMatch == "" {
-		return 0, "", false
+	if prefix != "" && suffix != "" {
+		return 0, "", false, fmt.Errorf("Amount %q has more than one currency", amount)
 	}
 
-	parsedAmount, err := strconv.ParseFloat(numMatch, 64)
+	normalized, err := normalizeLocalizedNumber(number, locale)
 	if err != nil {
-		return 0, "", false
+		return 0, "", false, err
 	}
 
-	isValue := currencyMatch != ""
-	return parsedAmount, currencyMatch, isValue
-}
+	// Expand shorthand magnitudes via the exponent so 1.1k is exactly 1100
+	parsedAmount, err := strconv.ParseFloat(normalized+amountMagnitudes[strings.ToLower(magnitude)], 64)
+	if err != nil {
+		return 0, "", false, err
+	}
 
-// Frankfurter exchange rate response struct
-type frankfurterResponse struct {
-	Amount float64            `json:"amount"`
-	Base   string             `json:"base"`
-	Date   string             `json:"date"`
-	Rates  map[string]float64 `json:"rates"`
+	currency := prefix + suffix
+	isValue := currency != ""
+	return parsedAmount, currency, isValue, nil
 }
 
-// Fetch historical FX rates using Frankfurter (free, no API key required)
-func getHistoricalFXRate(fromCurrency, toCurrency, date string) (float64, error) {
-	// Frankfurter format: https://api.frankfurter.app/2020-01-01?from=EUR&to=USD
-	url := fmt.Sprintf("%s/%s?from=%s&to=%s", frankfurterBaseURL, date, fromCurrency, toCurrency)
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
+// Middleware setting the asset type used when a request has no ?type=
+func withDefaultType(assetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("defaultType", assetType)
+		c.Next()
 	}
-	defer resp.Body.Close()
+}
 
-	var result frankfurterResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0, err
-	}
+// Supported asset types
+var assetTypes = []string{"stock", "crypto", "index", "commodity", "bond", "option"}
 
-	if result.Rates == nil {
-		return 0, fmt.Errorf("No rates returned from Frankfurter")
+// Helper function to check if an asset type is supported
+func isValidAssetType(assetType string) bool {
+	for _, supported := range assetTypes {
+		if assetType == supported {
+			return true
+		}
 	}
+	return false
+}
 
-	rate, ok := result.Rates[toCurrency]
-	if !ok {
-		return 0, fmt.Errorf("No rate found for %s to %s on %s", fromCurrency, toCurrency, date)
-	}
+// Helper function to tell whether an asset type is modelled from the purchase
+// (a bond's yield, an option's strike and premium) rather than priced from a
+// series, so it only has buy/sell backtests
+func isModelledType(assetType string) bool {
+	return assetType == "bond" || assetType == "option"
+}
 
-	return rate, nil
+// Helper function to read the asset type, falling back to the route's default.
+// Index symbols like ^GSPC default to type index.
+func assetTypeParam(c *gin.Context) string {
+	defaultType := c.GetString("defaultType")
+	if isIndexTicker(c.Param("ticker")) {
+		defaultType = "index"
+	}
+	if defaultType == "" {
+		defaultType = "stock"
+	}
+	return c.DefaultQuery("type", defaultType)
 }
 
 // Handler stubs
 func handleAmountBuy(c *gin.Context) {
+	if isStaggered(c.Param("buyDate")) {
+		handleStaggeredBuy(c)
+		return
+	}
 	amount := c.Param("amount")
 	ticker := c.Param("ticker")
 	buyDate := c.Param("buyDate")
-	typeParam := c.DefaultQuery("type", "stock")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Parse amount and detect if it's value-based
-	parsedAmount, currency, isValue := parseAmount(amount)
+	parsedAmount, currency, isValue, err := parseAmount(amount, c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
 	if parsedAmount == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
 		return
 	}
 
-	if typeParam != "stock" && typeParam != "crypto" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be 'stock' or 'crypto'"})
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Scenarios of type %s need a sell date: use /and-sold-on/:sellDate", typeParam)})
 		return
 	}
 
 	if isValue {
 		// Value-based investment
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err := resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+
 		// Get FX rate for buy date
-		fxRate, err := getHistoricalFXRate(currency, "USD", buyDate)
+		fxRate, err := priceOpts.Notes.fxRate(currency, "USD", buyDate)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate", "details": err.Error()})
 			return
 		}
 
 		// Get stock price
-		closePrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
+		closePrice, err := fetchPrice(ticker, buyDate, typeParam, priceOpts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock price", "details": err.Error()})
+			respondPriceError(c, "Failed to fetch stock price", err)
 			return
 		}
 
 		// Calculate shares bought
 		shares := (parsedAmount * fxRate) / closePrice
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":       "Backtest result (value buy only)",
+		response := gin.H{
+			"message":       localize(c, "Backtest result (value buy only)"),
 			"value":         parsedAmount,
 			"currency":      currency,
 			"ticker":        ticker,
@@ -328,73 +932,127 @@ func handleAmountBuy(c *gin.Context) {
 			"stockCurrency": "USD",
 			"fxRate":        fxRate,
 			"type":          typeParam,
+			"priceAt":       priceOpts.At,
+			"priceBasis":    priceOpts.basis(typeParam),
+		}
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, Currency: currency, Amount: parsedAmount, BuyDate: buyDate, BuyPrice: closePrice, FXRateBuy: fxRate, Shares: shares,
 		})
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
 	} else {
 		// Quantity-based investment
-		closePrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
+		closePrice, err := fetchPrice(ticker, buyDate, typeParam, priceOpts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock price", "details": err.Error()})
+			respondPriceError(c, "Failed to fetch stock price", err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Backtest result (quantity buy only)",
+		response := gin.H{
+			"message":    localize(c, "Backtest result (quantity buy only)"),
 			"quantity":   parsedAmount,
 			"ticker":     ticker,
 			"buyDate":    buyDate,
 			"closePrice": closePrice,
 			"type":       typeParam,
+			"priceAt":    priceOpts.At,
+			"priceBasis": priceOpts.basis(typeParam),
+		}
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, BuyDate: buyDate, BuyPrice: closePrice, Shares: parsedAmount,
 		})
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
 	}
 }
 
 func handleAmountBuySell(c *gin.Context) {
+	if isStaggered(c.Param("buyDate")) {
+		handleStaggeredBuy(c)
+		return
+	}
 	amount := c.Param("amount")
 	ticker := c.Param("ticker")
 	buyDate := c.Param("buyDate")
 	sellDate := c.Param("sellDate")
-	typeParam := c.DefaultQuery("type", "stock")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	riskOpts, err := riskOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	taxOpts, err := taxOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Parse amount and detect if it's value-based
-	parsedAmount, currency, isValue := parseAmount(amount)
+	parsedAmount, currency, isValue, err := parseAmount(amount, c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
 	if parsedAmount == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
 		return
 	}
 
-	if typeParam != "stock" && typeParam != "crypto" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be 'stock' or 'crypto'"})
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
 		return
 	}
 
-	if isValue {
-		// Value-based investment
-		// Get FX rate for buy date
-		fxRateBuy, err := getHistoricalFXRate(currency, "USD", buyDate)
+	// Optional fiat or crypto currency to restate the final value in
+	reportIn := c.Query("reportIn")
+	if reportIn != "" {
+		reportIn, err = resolveCurrency(reportIn, c.Query("homeCurrency"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reportIn currency", "details": err.Error()})
 			return
 		}
+	}
 
-		// Get FX rate for sell date
-		fxRateSell, err := getHistoricalFXRate("USD", currency, sellDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
-			return
-		}
+	// Treasuries are modelled from yields rather than priced, and options from
+	// their strike, premium and the underlying's price at expiry
+	if typeParam == "bond" {
+		handleBondBuySell(c, parsedAmount, currency, isValue, reportIn)
+		return
+	}
+	if typeParam == "option" {
+		handleOptionBuySell(c, parsedAmount, currency, isValue, reportIn)
+		return
+	}
 
-		// Get stock prices
-		buyPrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
+	if isValue {
+		// Value-based investment
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err := resolveCurrency(currency, c.Query("homeCurrency"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch buy price", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
 			return
 		}
 
-		sellPrice, err := fetchStockDailyCloseAlphaVantage(ticker, sellDate)
+		// Fetch the FX rates, prices and dividends at once
+		data, err := fetchHoldingData(ticker, typeParam, currency, buyDate, sellDate, priceOpts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+			respondFetchFailure(c, err)
 			return
 		}
+		fxRateBuy, fxRateSell, buyPrice, sellPrice, dividends := data.FXRateBuy, data.FXRateSell, data.BuyPrice, data.SellPrice, data.Dividends
 
 		// Convert investment value to USD
 		investmentUSD := parsedAmount * fxRateBuy
@@ -402,14 +1060,21 @@ func handleAmountBuySell(c *gin.Context) {
 		// Calculate shares bought
 		shares := investmentUSD / buyPrice
 
+		// Positions and cash received from spin-offs and mergers
+		actions, err := applyCorporateActions(ticker, typeParam, buyDate, sellDate, constantShares(shares), priceOpts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+			return
+		}
+
 		// Calculate final value in USD
-		finalValueUSD := shares * sellPrice
+		finalValueUSD := shares*sellPrice + actions.Value
 
 		// Convert back to original currency
 		finalValueInOriginalCurrency := finalValueUSD * fxRateSell
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":                      "Backtest result (value buy/sell)",
+		response := gin.H{
+			"message":                      localize(c, "Backtest result (value buy/sell)"),
 			"value":                        parsedAmount,
 			"currency":                     currency,
 			"ticker":                       ticker,
@@ -424,75 +1089,213 @@ func handleAmountBuySell(c *gin.Context) {
 			"fxRateBuy":                    fxRateBuy,
 			"fxRateSell":                   fxRateSell,
 			"type":                         typeParam,
+			"priceAt":                      priceOpts.At,
+			"priceBasis":                   priceOpts.basis(typeParam),
+			"returns":                      holdingReturns(data, ticker, buyDate, sellDate, sellPrice+actions.ValuePerShare, priceOpts),
+			"corporateActions":             actions,
+		}
+		addHoldingPeriod(response, ticker, buyDate, sellDate, typeParam, dividends)
+		addAfterTax(response, taxOpts, taxedPosition{BuyDate: buyDate, SellDate: sellDate, CostUSD: investmentUSD, ProceedsUSD: finalValueUSD, FinalValueUSD: finalValueUSD})
+		if err := addReportCurrency(response, reportIn, finalValueUSD, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+			return
+		}
+		if err := addCashComparison(response, c.Query("compareCash") == "true", investmentUSD, finalValueUSD, fxRateSell, buyDate, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+			return
+		}
+		if err := addEquivalents(response, c.Query("funUnits") == "true", investmentUSD, finalValueUSD, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+			return
+		}
+		addMood(response, c.Query("mood") == "true", investmentUSD, finalValueUSD, buyDate, sellDate)
+		addHedgedReturn(response, c.Query("hedged") == "true", currency, parsedAmount, finalValueInOriginalCurrency, fxRateBuy, fxRateSell)
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, Currency: currency, Amount: parsedAmount, BuyDate: buyDate, SellDate: sellDate,
+			BuyPrice: buyPrice, SellPrice: sellPrice, FXRateBuy: fxRateBuy, FXRateSell: fxRateSell, Shares: shares, Actions: actions,
 		})
+		if err := addBTCComparison(response, c.Query("vsBTC") == "true", investmentUSD, finalValueUSD, fxRateSell, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+			return
+		}
+		if err := addRiskStats(response, riskOpts, ticker, typeParam, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate risk stats", "details": err.Error()})
+			return
+		}
+		if err := addMilestones(response, c.Query("milestones") == "true", ticker, typeParam, buyDate, sellDate, buyPrice, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan milestones", "details": err.Error()})
+			return
+		}
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
 	} else {
 		// Quantity-based investment
-		buyPrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
+		// Fetch the prices and dividends at once
+		data, err := fetchHoldingData(ticker, typeParam, "", buyDate, sellDate, priceOpts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch buy price", "details": err.Error()})
+			respondFetchFailure(c, err)
 			return
 		}
+		buyPrice, sellPrice, dividends := data.BuyPrice, data.SellPrice, data.Dividends
 
-		sellPrice, err := fetchStockDailyCloseAlphaVantage(ticker, sellDate)
+		// Positions and cash received from spin-offs and mergers
+		actions, err := applyCorporateActions(ticker, typeParam, buyDate, sellDate, constantShares(parsedAmount), priceOpts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
 			return
 		}
 
-		finalValue := parsedAmount * sellPrice
+		finalValue := parsedAmount*sellPrice + actions.Value
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":    "Backtest result (quantity buy/sell)",
-			"quantity":   parsedAmount,
-			"ticker":     ticker,
-			"buyDate":    buyDate,
-			"sellDate":   sellDate,
-			"buyPrice":   buyPrice,
-			"sellPrice":  sellPrice,
-			"finalValue": finalValue,
-			"type":       typeParam,
+		response := gin.H{
+			"message":          localize(c, "Backtest result (quantity buy/sell)"),
+			"quantity":         parsedAmount,
+			"ticker":           ticker,
+			"buyDate":          buyDate,
+			"sellDate":         sellDate,
+			"buyPrice":         buyPrice,
+			"sellPrice":        sellPrice,
+			"finalValue":       finalValue,
+			"type":             typeParam,
+			"priceAt":          priceOpts.At,
+			"priceBasis":       priceOpts.basis(typeParam),
+			"returns":          holdingReturns(data, ticker, buyDate, sellDate, sellPrice+actions.ValuePerShare, priceOpts),
+			"corporateActions": actions,
+		}
+		addHoldingPeriod(response, ticker, buyDate, sellDate, typeParam, dividends)
+		addAfterTax(response, taxOpts, taxedPosition{BuyDate: buyDate, SellDate: sellDate, CostUSD: parsedAmount * buyPrice, ProceedsUSD: finalValue, FinalValueUSD: finalValue})
+		if err := addReportCurrency(response, reportIn, finalValue, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+			return
+		}
+		if err := addCashComparison(response, c.Query("compareCash") == "true", parsedAmount*buyPrice, finalValue, 1, buyDate, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+			return
+		}
+		if err := addEquivalents(response, c.Query("funUnits") == "true", parsedAmount*buyPrice, finalValue, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+			return
+		}
+		addMood(response, c.Query("mood") == "true", parsedAmount*buyPrice, finalValue, buyDate, sellDate)
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, BuyDate: buyDate, SellDate: sellDate,
+			BuyPrice: buyPrice, SellPrice: sellPrice, Shares: parsedAmount, Actions: actions,
 		})
+		if err := addBTCComparison(response, c.Query("vsBTC") == "true", parsedAmount*buyPrice, finalValue, 1, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+			return
+		}
+		if err := addRiskStats(response, riskOpts, ticker, typeParam, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate risk stats", "details": err.Error()})
+			return
+		}
+		if err := addMilestones(response, c.Query("milestones") == "true", ticker, typeParam, buyDate, sellDate, buyPrice, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan milestones", "details": err.Error()})
+			return
+		}
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
 	}
 }
 
+// Handler for "and-held" routes: a buy/sell sold on the most recent close, for
+// holdings that are still open
+func handleAmountBuyHeld(c *gin.Context) {
+	buyDate := c.Param("buyDate")
+	sellDate := latestCloseDate(c.Param("ticker"), assetTypeParam(c), time.Now())
+	if dateOnly(lastBuyDate(buyDate)) >= sellDate {
+		abortWithDateError(c, fmt.Errorf("buyDate %s must be before the most recent close, on %s", buyDate, sellDate))
+		return
+	}
+
+	// Sold on the latest close, which moves on tomorrow, so never cached for good
+	c.Params = append(c.Params, gin.Param{Key: "sellDate", Value: sellDate})
+	c.Set("stillHeld", true)
+	handleAmountBuySell(c)
+}
+
 func handleAmountBuySellDrip(c *gin.Context) {
 	amount := c.Param("amount")
 	ticker := c.Param("ticker")
 	buyDate := c.Param("buyDate")
 	sellDate := c.Param("sellDate")
-	typeParam := c.DefaultQuery("type", "stock")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	riskOpts, err := riskOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	taxOpts, err := taxOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	dripOpts, err := dripOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cashInterest := c.Query("cashInterest") == "true"
 
 	// Parse amount and detect if it's value-based
-	parsedAmount, currency, isValue := parseAmount(amount)
+	parsedAmount, currency, isValue, err := parseAmount(amount, c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
 
-	if isValue {
-		// Value-based investment with DRIP
-		// Get FX rate for buy date
-		fxRateBuy, err := getHistoricalFXRate(currency, "USD", buyDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
-			return
-		}
+	// Adjusted closes already account for dividends; reinvesting them again would double count
+	if priceOpts.Adjusted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "adjusted=true cannot be combined with with-drip"})
+		return
+	}
 
-		// Get FX rate for sell date
-		fxRateSell, err := getHistoricalFXRate("USD", currency, sellDate)
+	// Optional fiat or crypto currency to restate the final value in
+	reportIn := c.Query("reportIn")
+	if reportIn != "" {
+		reportIn, err = resolveCurrency(reportIn, c.Query("homeCurrency"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reportIn currency", "details": err.Error()})
 			return
 		}
+	}
 
-		// Get stock prices
-		buyPrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
+	if isValue {
+		// Value-based investment with DRIP
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err := resolveCurrency(currency, c.Query("homeCurrency"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch buy price", "details": err.Error()})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
 			return
 		}
 
-		sellPrice, err := fetchStockDailyCloseAlphaVantage(ticker, sellDate)
+		// Fetch the FX rates, prices and dividends at once
+		data, err := fetchHoldingData(ticker, typeParam, currency, buyDate, sellDate, priceOpts)
+		if err == nil {
+			err = data.DividendsErr // reinvesting them needs them
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+			respondFetchFailure(c, err)
 			return
 		}
+		fxRateBuy, fxRateSell, buyPrice, sellPrice, dividends := data.FXRateBuy, data.FXRateSell, data.BuyPrice, data.SellPrice, data.Dividends
 
 		// Convert investment value to USD
 		investmentUSD := parsedAmount * fxRateBuy
@@ -500,27 +1303,41 @@ func handleAmountBuySellDrip(c *gin.Context) {
 		// Calculate initial shares
 		initialShares := investmentUSD / buyPrice
 
-		// Fetch dividends for the period
-		dividends, err := fetchStockDividendsAlphaVantage(ticker, buyDate, sellDate)
+		// Calculate DRIP reinvestment
+		reinvestedShares, reinvestedDividends, dividendCash, err := calculateDRIP(initialShares, dividends, sellDate, dripOpts, dripOpts.priceOn(ticker, typeParam, priceOpts))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dividends", "details": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate DRIP", "details": err.Error()})
 			return
 		}
 
-		// Calculate DRIP reinvestment
-		reinvestedShares, reinvestedDividends := calculateDRIP(initialShares, dividends, buyPrice)
+		// Interest on the dividend cash it didn't reinvest
+		interestEarned := 0.0
+		if cashInterest {
+			interestEarned, err = accrueDRIPInterest(reinvestedDividends, buyDate, sellDate)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+				return
+			}
+		}
 
 		// Total shares after DRIP
 		totalShares := initialShares + reinvestedShares
 
-		// Calculate final value in USD
-		finalValueUSD := totalShares * sellPrice
+		// Positions and cash received from spin-offs and mergers
+		actions, err := applyCorporateActions(ticker, typeParam, buyDate, sellDate, dripSharesOn(initialShares, reinvestedDividends), priceOpts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+			return
+		}
+
+		// Calculate final value in USD, including dividends paid after the sale
+		finalValueUSD := totalShares*sellPrice + dividendCash + interestEarned + actions.Value
 
 		// Convert back to original currency
 		finalValueInOriginalCurrency := finalValueUSD * fxRateSell
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":                      "Backtest result (value buy/sell with DRIP)",
+		response := gin.H{
+			"message":                      localize(c, "Backtest result (value buy/sell with DRIP)"),
 			"value":                        parsedAmount,
 			"currency":                     currency,
 			"ticker":                       ticker,
@@ -532,46 +1349,109 @@ func handleAmountBuySellDrip(c *gin.Context) {
 			"reinvestedShares":             reinvestedShares,
 			"totalShares":                  totalShares,
 			"dividends":                    reinvestedDividends,
+			"dividendCash":                 dividendCash,
 			"finalValueUSD":                finalValueUSD,
 			"finalValueInOriginalCurrency": finalValueInOriginalCurrency,
 			"fxRateBuy":                    fxRateBuy,
 			"fxRateSell":                   fxRateSell,
 			"drip":                         true,
+			"reinvestment":                 dripOpts,
 			"type":                         typeParam,
+			"priceAt":                      priceOpts.At,
+			"priceBasis":                   priceOpts.basis(typeParam),
+			"returns":                      calculateReturns(buyPrice, sellPrice+actions.ValuePerShare, dividends, priceOpts),
+			"corporateActions":             actions,
+		}
+		addDRIPFees(response, reinvestedDividends)
+		addDRIPCashKept(response, dripOpts, reinvestedDividends)
+		addCashInterest(response, cashInterest, interestEarned)
+		addHoldingPeriod(response, ticker, buyDate, sellDate, typeParam, dividends)
+		addIncomeSummary(response, dripIncome(reinvestedDividends), investmentUSD, totalShares*sellPrice, sellDate)
+		addAfterTax(response, taxOpts, taxedPosition{
+			BuyDate: buyDate, SellDate: sellDate, CostUSD: investmentUSD, ReinvestedUSD: dripReinvestedUSD(reinvestedDividends),
+			ProceedsUSD: totalShares*sellPrice + actions.Value, FinalValueUSD: finalValueUSD, Dividends: dripIncome(reinvestedDividends), InterestUSD: interestEarned,
 		})
+		if err := addReportCurrency(response, reportIn, finalValueUSD, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+			return
+		}
+		if err := addCashComparison(response, c.Query("compareCash") == "true", investmentUSD, finalValueUSD, fxRateSell, buyDate, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+			return
+		}
+		if err := addEquivalents(response, c.Query("funUnits") == "true", investmentUSD, finalValueUSD, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+			return
+		}
+		addMood(response, c.Query("mood") == "true", investmentUSD, finalValueUSD, buyDate, sellDate)
+		addHedgedReturn(response, c.Query("hedged") == "true", currency, parsedAmount, finalValueInOriginalCurrency, fxRateBuy, fxRateSell)
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, Currency: currency, Amount: parsedAmount, BuyDate: buyDate, SellDate: sellDate,
+			BuyPrice: buyPrice, SellPrice: sellPrice, FXRateBuy: fxRateBuy, FXRateSell: fxRateSell, Shares: initialShares, Reinvestments: reinvestedDividends, Actions: actions,
+		})
+		if err := addBTCComparison(response, c.Query("vsBTC") == "true", investmentUSD, finalValueUSD, fxRateSell, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+			return
+		}
+		if err := addRiskStats(response, riskOpts, ticker, typeParam, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate risk stats", "details": err.Error()})
+			return
+		}
+		if err := addMilestones(response, c.Query("milestones") == "true", ticker, typeParam, buyDate, sellDate, buyPrice, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan milestones", "details": err.Error()})
+			return
+		}
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
 	} else {
 		// Quantity-based investment with DRIP
-		// Get stock prices
-		buyPrice, err := fetchStockDailyCloseAlphaVantage(ticker, buyDate)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch buy price", "details": err.Error()})
-			return
+		// Fetch the prices and dividends at once
+		data, err := fetchHoldingData(ticker, typeParam, "", buyDate, sellDate, priceOpts)
+		if err == nil {
+			err = data.DividendsErr // reinvesting them needs them
 		}
-
-		sellPrice, err := fetchStockDailyCloseAlphaVantage(ticker, sellDate)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+			respondFetchFailure(c, err)
 			return
 		}
+		buyPrice, sellPrice, dividends := data.BuyPrice, data.SellPrice, data.Dividends
 
-		// Fetch dividends for the period
-		dividends, err := fetchStockDividendsAlphaVantage(ticker, buyDate, sellDate)
+		// Calculate DRIP reinvestment
+		reinvestedShares, reinvestedDividends, dividendCash, err := calculateDRIP(parsedAmount, dividends, sellDate, dripOpts, dripOpts.priceOn(ticker, typeParam, priceOpts))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch dividends", "details": err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate DRIP", "details": err.Error()})
 			return
 		}
 
-		// Calculate DRIP reinvestment
-		reinvestedShares, reinvestedDividends := calculateDRIP(parsedAmount, dividends, buyPrice)
+		// Interest on the dividend cash it didn't reinvest
+		interestEarned := 0.0
+		if cashInterest {
+			interestEarned, err = accrueDRIPInterest(reinvestedDividends, buyDate, sellDate)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+				return
+			}
+		}
 
 		// Total shares after DRIP
 		totalShares := parsedAmount + reinvestedShares
 
-		// Calculate final value
-		finalValue := totalShares * sellPrice
+		// Positions and cash received from spin-offs and mergers
+		actions, err := applyCorporateActions(ticker, typeParam, buyDate, sellDate, dripSharesOn(parsedAmount, reinvestedDividends), priceOpts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+			return
+		}
+
+		// Calculate final value, including dividends paid after the sale
+		finalValue := totalShares*sellPrice + dividendCash + interestEarned + actions.Value
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":          "Backtest result (quantity buy/sell with DRIP)",
+		response := gin.H{
+			"message":          localize(c, "Backtest result (quantity buy/sell with DRIP)"),
 			"quantity":         parsedAmount,
 			"ticker":           ticker,
 			"buyDate":          buyDate,
@@ -581,9 +1461,262 @@ func handleAmountBuySellDrip(c *gin.Context) {
 			"reinvestedShares": reinvestedShares,
 			"totalShares":      totalShares,
 			"dividends":        reinvestedDividends,
+			"dividendCash":     dividendCash,
 			"finalValue":       finalValue,
 			"drip":             true,
+			"reinvestment":     dripOpts,
 			"type":             typeParam,
+			"priceAt":          priceOpts.At,
+			"priceBasis":       priceOpts.basis(typeParam),
+			"returns":          calculateReturns(buyPrice, sellPrice+actions.ValuePerShare, dividends, priceOpts),
+			"corporateActions": actions,
+		}
+		addDRIPFees(response, reinvestedDividends)
+		addDRIPCashKept(response, dripOpts, reinvestedDividends)
+		addCashInterest(response, cashInterest, interestEarned)
+		addHoldingPeriod(response, ticker, buyDate, sellDate, typeParam, dividends)
+		addIncomeSummary(response, dripIncome(reinvestedDividends), parsedAmount*buyPrice, totalShares*sellPrice, sellDate)
+		addAfterTax(response, taxOpts, taxedPosition{
+			BuyDate: buyDate, SellDate: sellDate, CostUSD: parsedAmount * buyPrice, ReinvestedUSD: dripReinvestedUSD(reinvestedDividends),
+			ProceedsUSD: totalShares*sellPrice + actions.Value, FinalValueUSD: finalValue, Dividends: dripIncome(reinvestedDividends), InterestUSD: interestEarned,
 		})
+		if err := addReportCurrency(response, reportIn, finalValue, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+			return
+		}
+		if err := addCashComparison(response, c.Query("compareCash") == "true", parsedAmount*buyPrice, finalValue, 1, buyDate, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+			return
+		}
+		if err := addEquivalents(response, c.Query("funUnits") == "true", parsedAmount*buyPrice, finalValue, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+			return
+		}
+		addMood(response, c.Query("mood") == "true", parsedAmount*buyPrice, finalValue, buyDate, sellDate)
+		addExplanation(response, c.Query("explain") == "true", priceOpts.Notes, explainedHolding{
+			Ticker: ticker, At: priceOpts.At, BuyDate: buyDate, SellDate: sellDate,
+			BuyPrice: buyPrice, SellPrice: sellPrice, Shares: parsedAmount, Reinvestments: reinvestedDividends, Actions: actions,
+		})
+		if err := addBTCComparison(response, c.Query("vsBTC") == "true", parsedAmount*buyPrice, finalValue, 1, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+			return
+		}
+		if err := addRiskStats(response, riskOpts, ticker, typeParam, buyDate, sellDate, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate risk stats", "details": err.Error()})
+			return
+		}
+		if err := addMilestones(response, c.Query("milestones") == "true", ticker, typeParam, buyDate, sellDate, buyPrice, priceOpts); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan milestones", "details": err.Error()})
+			return
+		}
+		addLimitOrder(response, priceOpts)
+		addPeakBuy(response, c)
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCryptoPricing(response, typeParam)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func handleAmountBuySellDividends(c *gin.Context) {
+	amount := c.Param("amount")
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	riskOpts, err := riskOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	taxOpts, err := taxOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Parse amount and detect if it's value-based
+	parsedAmount, currency, isValue, err := parseAmount(amount, c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+
+	// Adjusted closes already account for dividends; paying them out again would double count
+	if priceOpts.Adjusted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "adjusted=true cannot be combined with with-dividends"})
+		return
+	}
+
+	// Optional annual interest rate (percent) earned on dividends held as cash
+	depositRate := 0.0
+	if rate := c.Query("depositRate"); rate != "" {
+		depositRate, err = strconv.ParseFloat(rate, 64)
+		if err != nil || depositRate < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depositRate parameter: must be a non-negative annual percentage"})
+			return
+		}
+	}
+
+	// Or interest at the CASH_RATE_SERIES rate, as it was over the holding
+	cashInterest := c.Query("cashInterest") == "true"
+	if cashInterest && depositRate > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "depositRate cannot be combined with cashInterest=true"})
+		return
+	}
+
+	// Optional fiat or crypto currency to restate the final value in
+	reportIn := c.Query("reportIn")
+	if reportIn != "" {
+		reportIn, err = resolveCurrency(reportIn, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reportIn currency", "details": err.Error()})
+			return
+		}
+	}
+
+	shares := parsedAmount
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+	}
+
+	// Fetch the FX rates, prices and dividends at once
+	data, err := fetchHoldingData(ticker, typeParam, currency, buyDate, sellDate, priceOpts)
+	if err == nil {
+		err = data.DividendsErr // paying them out needs them
+	}
+	if err != nil {
+		respondFetchFailure(c, err)
+		return
+	}
+	fxRateBuy, fxRateSell, buyPrice, sellPrice, dividends := data.FXRateBuy, data.FXRateSell, data.BuyPrice, data.SellPrice, data.Dividends
+
+	if isValue {
+		// Shares bought with the investment converted to USD
+		shares = parsedAmount * fxRateBuy / buyPrice
+	}
+
+	// Dividends accumulate as cash instead of buying shares
+	payments, dividendIncome, interestEarned, err := calculateDividendIncome(shares, dividends, depositRate, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate dividend income", "details": err.Error()})
+		return
+	}
+	if cashInterest {
+		interestEarned, err = accrueDividendInterest(payments, buyDate, sellDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+			return
+		}
+	}
+
+	// Positions and cash received from spin-offs and mergers
+	actions, err := applyCorporateActions(ticker, typeParam, buyDate, sellDate, constantShares(shares), priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+		return
+	}
+
+	// Split the outcome into capital gain (including spin-offs and mergers) and income received
+	capitalGain := shares*(sellPrice-buyPrice) + actions.Value
+	incomeReceived := dividendIncome + interestEarned
+	finalValue := shares*sellPrice + actions.Value + incomeReceived
+
+	response := gin.H{
+		"ticker":           ticker,
+		"buyDate":          buyDate,
+		"sellDate":         sellDate,
+		"buyPrice":         buyPrice,
+		"sellPrice":        sellPrice,
+		"shares":           shares,
+		"dividends":        payments,
+		"depositRate":      depositRate,
+		"capitalGain":      capitalGain,
+		"dividendIncome":   dividendIncome,
+		"interestEarned":   interestEarned,
+		"incomeReceived":   incomeReceived,
+		"dividendsAsCash":  true,
+		"type":             typeParam,
+		"priceAt":          priceOpts.At,
+		"priceBasis":       priceOpts.basis(typeParam),
+		"returns":          calculateReturns(buyPrice, sellPrice+actions.ValuePerShare, dividends, priceOpts),
+		"corporateActions": actions,
+	}
+	if isValue {
+		response["message"] = localize(c, "Backtest result (value buy/sell with dividends as cash)")
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["finalValueUSD"] = finalValue
+		response["finalValueInOriginalCurrency"] = finalValue * fxRateSell
+		response["fxRateBuy"] = fxRateBuy
+		response["fxRateSell"] = fxRateSell
+		addHedgedReturn(response, c.Query("hedged") == "true", currency, parsedAmount, finalValue*fxRateSell, fxRateBuy, fxRateSell)
+	} else {
+		response["message"] = localize(c, "Backtest result (quantity buy/sell with dividends as cash)")
+		response["quantity"] = parsedAmount
+		response["finalValue"] = finalValue
+	}
+	addCashInterest(response, cashInterest, interestEarned)
+	addHoldingPeriod(response, ticker, buyDate, sellDate, typeParam, dividends)
+	addIncomeSummary(response, cashIncome(payments), shares*buyPrice, shares*sellPrice, sellDate)
+	addAfterTax(response, taxOpts, taxedPosition{
+		BuyDate: buyDate, SellDate: sellDate, CostUSD: shares * buyPrice, ProceedsUSD: shares*sellPrice + actions.Value,
+		FinalValueUSD: finalValue, Dividends: cashIncome(payments), InterestUSD: interestEarned,
+	})
+	addXIRR(response, dividendCashFlows(shares*buyPrice, buyDate, payments, shares*sellPrice+actions.Value, sellDate))
+	if err := addReportCurrency(response, reportIn, finalValue, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+		return
+	}
+	if err := addCashComparison(response, c.Query("compareCash") == "true", shares*buyPrice, finalValue, fxRateSell, buyDate, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+		return
+	}
+	if err := addEquivalents(response, c.Query("funUnits") == "true", shares*buyPrice, finalValue, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+		return
+	}
+	addMood(response, c.Query("mood") == "true", shares*buyPrice, finalValue, buyDate, sellDate)
+	if c.Query("explain") == "true" {
+		explained := explainedHolding{
+			Ticker: ticker, At: priceOpts.At, BuyDate: buyDate, SellDate: sellDate,
+			BuyPrice: buyPrice, SellPrice: sellPrice, Shares: shares, Payments: payments, Actions: actions,
+		}
+		if isValue {
+			explained.Currency, explained.Amount, explained.FXRateBuy, explained.FXRateSell = currency, parsedAmount, fxRateBuy, fxRateSell
+		}
+		addExplanation(response, true, priceOpts.Notes, explained)
+	}
+	if err := addBTCComparison(response, c.Query("vsBTC") == "true", shares*buyPrice, finalValue, fxRateSell, buyDate, sellDate, priceOpts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+		return
+	}
+	if err := addRiskStats(response, riskOpts, ticker, typeParam, buyDate, sellDate, priceOpts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate risk stats", "details": err.Error()})
+		return
+	}
+	if err := addMilestones(response, c.Query("milestones") == "true", ticker, typeParam, buyDate, sellDate, buyPrice, priceOpts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan milestones", "details": err.Error()})
+		return
 	}
+	addLimitOrder(response, priceOpts)
+	addPeakBuy(response, c)
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCryptoPricing(response, typeParam)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
 }
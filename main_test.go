@@ -3,10 +3,12 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -15,17 +17,31 @@ import (
 // Mock data for testing
 var mockStockData = map[string]map[string]string{
 	"2025-07-18": {
-		"4. close": "211.18",
+		"1. open":           "210.87",
+		"2. high":           "211.79",
+		"3. low":            "209.70",
+		"4. close":          "211.18",
+		"5. adjusted close": "211.18",
 	},
 	"2025-07-17": {
 		"4. close": "210.02",
 	},
 	"2025-03-31": {
-		"4. close": "200.50",
+		"1. open":           "198.20",
+		"2. high":           "201.10",
+		"3. low":            "197.40",
+		"4. close":          "200.50",
+		"5. adjusted close": "199.50",
 	},
 	"2025-06-20": {
 		"4. close": "205.75",
 	},
+	"2025-06-30": {
+		"4. close": "205.17",
+	},
+	"2025-05-15": {
+		"4. close": "211.45",
+	},
 }
 
 var mockFXData = map[string]map[string]float64{
@@ -55,18 +71,100 @@ var mockFXData = map[string]map[string]float64{
 	},
 }
 
+// Mock daily WTI spot prices, shaped like Alpha Vantage's commodity functions
+var mockWTIData = []map[string]string{
+	{"date": "2025-07-18", "value": "67.34"},
+	{"date": "2025-07-17", "value": "."},
+	{"date": "2025-03-31", "value": "71.48"},
+}
+
+// Mock dividend events, shaped like Alpha Vantage's DIVIDENDS function
+var mockDividends = []map[string]string{
+	{"ex_dividend_date": "2025-08-11", "payment_date": "2025-08-14", "amount": "0.26"},
+	{"ex_dividend_date": "2025-07-14", "payment_date": "2025-08-14", "amount": "0.26"},
+	{"ex_dividend_date": "2025-05-12", "payment_date": "2025-05-15", "amount": "0.26"},
+	{"ex_dividend_date": "2025-02-10", "payment_date": "2025-02-13", "amount": "0.25"},
+}
+
+// Mock 1-minute bars for intraday tests, keyed like Alpha Vantage
+var mockIntradayData = map[string]map[string]string{
+	"2025-07-18 09:30:00": {"4. close": "210.10"},
+	"2025-07-18 10:30:00": {"4. close": "210.80"},
+	"2025-07-18 10:32:00": {"4. close": "210.95"},
+}
+
+// Start a fake Alpha Vantage API serving the mock series above
+func setupMockAlphaVantage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("function") {
+		case "TIME_SERIES_DAILY", "TIME_SERIES_DAILY_ADJUSTED":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Time Series (Daily)": mockStockData})
+		case "GLOBAL_QUOTE":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Global Quote": map[string]string{"01. symbol": r.URL.Query().Get("symbol"), "05. price": "212.48", "07. latest trading day": "2025-07-21"}})
+		case "TIME_SERIES_INTRADAY":
+			json.NewEncoder(w).Encode(map[string]interface{}{"Time Series (1min)": mockIntradayData})
+		case "WTI":
+			json.NewEncoder(w).Encode(map[string]interface{}{"name": "Crude Oil Prices WTI", "data": mockWTIData})
+		case "DIVIDENDS":
+			json.NewEncoder(w).Encode(map[string]interface{}{"symbol": r.URL.Query().Get("symbol"), "data": mockDividends})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"Information": "unsupported function"})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// Mocked prices missing a date mustn't fall back to the live providers
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL, cfg.StockProviders = server.URL, "alphaVantage" })
+	prices.invalidate(priceFilter{}) // prices cached from other mocks would shadow this one's
+	latest.clear()
+	setupAlphaVantagePacing(t, 0)
+}
+
 // Test setup with mocked APIs
 func setupTestRouterWithMocks() *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
+	r.Use(withInputValidation(), withLimitOrder())
 
 	// Setup routes
 	r.GET("/:amount/:ticker/on/:buyDate", handleAmountBuy)
 	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
 	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/lump-sum-vs-dca", handleLumpSumVsDCA)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/buy-the-dip", handleBuyTheDip)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/sold-too-early", handleSoldTooEarly)
 	r.GET("/:amount/of/:ticker/on/:buyDate", handleAmountBuy)
 	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
 	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/lump-sum-vs-dca", handleLumpSumVsDCA)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/buy-the-dip", handleBuyTheDip)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/sold-too-early", handleSoldTooEarly)
+	r.GET("/:amount/:ticker/bought-between/:buyDate/:buyEnd", handleAmountBoughtBetween)
+	r.GET("/:amount/:ticker/bought-between/:buyDate/:buyEnd/and-sold-on/:sellDate", handleAmountBoughtBetween)
+	r.GET("/:amount/of/:ticker/bought-between/:buyDate/:buyEnd", handleAmountBoughtBetween)
+	r.GET("/:amount/of/:ticker/bought-between/:buyDate/:buyEnd/and-sold-on/:sellDate", handleAmountBoughtBetween)
+
+	r.GET("/v1/backtest", handleBacktestQuery)
+	r.POST("/v1/scenario", handleScenario)
+	r.POST("/v1/portfolio/import", handlePortfolioImport)
+	r.GET("/:amount/in/:portfolio/on/:buyDate/and-sold-on/:sellDate", handlePortfolioBuySell)
+	r.GET("/rolling/:ticker", handleRollingReturns)
+
+	swaps := r.Group("/", withDefaultType("crypto"))
+	swaps.GET("/:amount/into/:ticker/on/:buyDate", handleAmountBuy)
+	swaps.GET("/:amount/into/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	swaps.GET("/:amount/into/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
+
+	peaks := r.Group("/", withPeakBuy())
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore", handleAmountBuy)
+	peaks.GET("/:amount/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate", handleAmountBuySell)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore", handleAmountBuy)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate", handleAmountBuySell)
+	peaks.GET("/:amount/of/:ticker/at-the-peak-before/:peakBefore/and-sold-on/:sellDate/with-dividends", handleAmountBuySellDividends)
 
 	return r
 }
@@ -464,7 +562,7 @@ func TestEdgeCasesWithMocks(t *testing.T) {
 		expected int
 	}{
 		{"Zero amount", "/0/AAPL/on/2025-07-18?type=stock", http.StatusBadRequest},
-		{"Empty ticker", "/1000EUR/of//on/2025-07-18?type=stock", http.StatusInternalServerError},
+		{"Empty ticker", "/1000EUR/of//on/2025-07-18?type=stock", http.StatusBadRequest},
 	}
 
 	for _, tc := range testCases {
@@ -523,26 +621,294 @@ func TestURLRoutingWithMocks(t *testing.T) {
 	}
 }
 
+// Test pricing both legs at the open, high, low or close
+func TestPriceAtWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	testCases := []struct {
+		priceAt   string
+		buyPrice  float64
+		sellPrice float64
+	}{
+		{"", 200.50, 211.18},
+		{"open", 198.20, 210.87},
+		{"high", 201.10, 211.79},
+		{"low", 197.40, 209.70},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.priceAt, func(t *testing.T) {
+			w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock&priceAt="+tc.priceAt)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var response map[string]interface{}
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.buyPrice, response["buyPrice"])
+			assert.Equal(t, tc.sellPrice, response["sellPrice"])
+			if tc.priceAt == "" {
+				assert.Equal(t, "close", response["priceAt"])
+			} else {
+				assert.Equal(t, tc.priceAt, response["priceAt"])
+			}
+		})
+	}
+}
+
+// Test split/dividend-adjusted prices and the priceBasis label
+func TestAdjustedPricesWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "unadjusted", response["priceBasis"])
+	assert.Equal(t, 200.50, response["buyPrice"])
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock&adjusted=true&priceAt=open")
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "adjusted", response["priceBasis"])
+	assert.InDelta(t, 198.20*199.50/200.50, response["buyPrice"], 1e-9)
+	assert.InDelta(t, 210.87, response["sellPrice"], 1e-9)
+}
+
+// Test the price-only vs total return split on buy/sell queries
+func TestReturnsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	returns := response["returns"].(map[string]interface{})
+	assert.InDelta(t, (211.18/200.50-1)*100, returns["priceReturnPercent"], 1e-9)
+	assert.InDelta(t, 0.52/200.50*100, returns["incomeReturnPercent"], 1e-9)
+	assert.InDelta(t, (211.70/200.50-1)*100, returns["totalReturnPercent"], 1e-9)
+	assert.InDelta(t, 0.52, returns["dividendsPerShare"], 1e-9)
+
+	// Adjusted prices already include dividends
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock&adjusted=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	returns = response["returns"].(map[string]interface{})
+	assert.InDelta(t, (211.18/199.50-1)*100, returns["totalReturnPercent"], 1e-9)
+	assert.NotContains(t, returns, "priceReturnPercent")
+}
+
+// Test a buy/sell query still answers when no provider has dividends, with the
+// price return only and a data note saying why
+func TestReturnsWithoutDividendsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockPolygon(t)
+	setStockProviderOrder(t, "polygon")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+
+	var response struct {
+		Returns   map[string]float64 `json:"returns"`
+		DataNotes []dataSource       `json:"dataNotes"`
+	}
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Returns, 1)
+	assert.InDelta(t, (211.18/200.50-1)*100, response.Returns["priceReturnPercent"], 1e-9)
+	notes := map[string]dataSource{}
+	for _, source := range response.DataNotes {
+		notes[source.Kind] = source
+	}
+	assert.Equal(t, "2025-03-31/2025-07-18", notes["dividends"].Date)
+	assert.Contains(t, notes["dividends"].Unavailable, "none of STOCK_PROVIDERS has dividends")
+
+	// A source without dividends is named
+	setStockProviderOrder(t, "alphaVantage,polygon")
+	response.DataNotes = nil
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?source=polygon")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, w.Body.String(), "polygon has no dividends")
+
+	// Reinvesting dividends needs them
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?source=polygon")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "Failed to fetch dividends")
+}
+
+// Test dividends accumulating as cash with a deposit rate
+func TestDividendsAsCashWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?type=stock&depositRate=5")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 10.0, response["shares"])
+	assert.InDelta(t, 10*(211.18-200.50), response["capitalGain"], 1e-9)
+	assert.InDelta(t, 5.2, response["dividendIncome"], 1e-9)
+
+	// 2.60 paid on 2025-05-15 earns 5% a year for 64 days; the July dividend is
+	// paid after the sale and earns nothing
+	interest := 2.6*math.Pow(1.05, 64/365.25) - 2.6
+	assert.InDelta(t, interest, response["interestEarned"], 1e-9)
+	assert.InDelta(t, 10*211.18+5.2+interest, response["finalValue"], 1e-9)
+	assert.Len(t, response["dividends"], 2)
+}
+
+// Test DRIP ownership on ex-dates and reinvestment on payment dates
+func TestDRIPPaymentDatesWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// May dividend reinvested at the 2025-05-15 price
+	bought := 2.6 / 211.45
+	dividends := response["dividends"].([]interface{})
+	assert.Len(t, dividends, 2)
+	may := dividends[0].(map[string]interface{})
+	assert.Equal(t, "2025-05-12", may["exDate"])
+	assert.Equal(t, "2025-05-15", may["paymentDate"])
+	assert.Equal(t, 211.45, may["price"])
+	assert.InDelta(t, bought, may["sharesBought"], 1e-9)
+
+	// July dividend is earned on the reinvested shares too, but paid after the sale
+	july := dividends[1].(map[string]interface{})
+	assert.InDelta(t, 10+bought, july["sharesHeld"], 1e-9)
+	assert.Equal(t, 0.0, july["sharesBought"])
+	assert.InDelta(t, (10+bought)*0.26, response["dividendCash"], 1e-9)
+	assert.InDelta(t, (10+bought)*211.18+(10+bought)*0.26, response["finalValue"], 1e-9)
+}
+
+// Test weekend dates are priced on the Friday before unless ?strict=true
+func TestStrictDatesWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-20?explain=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		SellDate  string        `json:"sellDate"`
+		SellPrice float64       `json:"sellPrice"`
+		Explain   []explainStep `json:"explain"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-07-20", response.SellDate)
+	assert.Equal(t, 211.18, response.SellPrice)
+	if assert.Len(t, response.Explain, 5) {
+		assert.Equal(t, "2025-07-20", response.Explain[2].Date)
+		assert.Equal(t, "2025-07-18", response.Explain[2].ActualDate)
+	}
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-20?strict=true")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "No data for date 2025-07-20")
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-20?strict=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid strict parameter")
+
+	// Trading days are priced on the day either way
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?strict=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2025-07-18", lastTradingDayOnOrBefore("AAPL", "2025-07-18", "stock"))
+	assert.Equal(t, "2025-07-20", lastTradingDayOnOrBefore("BTC", "2025-07-20", "crypto"))
+}
+
+// Test "and-held" sells on the most recent close and isn't cached for good
+func TestAndHeldWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	sellDate := latestCloseDate("AAPL", "stock", time.Now())
+	mockStockData[sellDate] = mockStockData["2025-07-18"]
+	t.Cleanup(func() { delete(mockStockData, sellDate) })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withCaching())
+	router.GET("/:amount/of/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
+	router.GET("/:amount/:ticker/on/:buyDate/and-held", handleAmountBuyHeld)
+
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-07-17/and-held")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEqual(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, sellDate, response["sellDate"])
+	assert.Equal(t, 211.18, response["sellPrice"])
+
+	// A buy on or after the latest close has nothing to sell on
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/"+sellDate+"/and-held")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "most recent close")
+}
+
 // Test helper functions
 func TestParseAmount(t *testing.T) {
 	testCases := []struct {
 		input    string
+		locale   string
 		expected float64
 		currency string
 		isValue  bool
 	}{
-		{"1000", 1000, "", false},
-		{"1000EUR", 1000, "EUR", true},
-		{"$1000", 1000, "$", true},
-		{"€1000", 1000, "€", true},
-		{"£1000", 1000, "£", true},
-		{"1000.50", 1000.50, "", false},
-		{"1000,50", 1000, "", false}, // Comma parsing not implemented
+		{"1000", "", 1000, "", false},
+		{"1000EUR", "", 1000, "EUR", true},
+		{"$1000", "", 1000, "$", true},
+		{"€1000", "", 1000, "€", true},
+		{"£1000", "", 1000, "£", true},
+		{"1000kr", "", 1000, "kr", true},
+		{"1000.50", "", 1000.50, "", false},
+		{"1000,50", "", 1000.50, "", false},
+		{"1,000.50", "", 1000.50, "", false},
+		{"1.000,50", "", 1000.50, "", false},
+		{"1 000,50EUR", "", 1000.50, "EUR", true},
+		{"1\u00a0000\u00a0000", "", 1000000, "", false},
+		{"1'000.50", "", 1000.50, "", false},
+		{"1,000,000", "", 1000000, "", false},
+		{"1,000", "en", 1000, "", false},
+		{"1,000", "de-DE", 1, "", false},
+		{"1.000", "de", 1000, "", false},
+		{"0.500", "", 0.5, "", false},
+		{"10k", "", 10000, "", false},
+		{"1.1k", "", 1100, "", false},
+		{"2.5m", "", 2500000, "", false},
+		{"2,5M", "de", 2500000, "", false},
+		{"1bn", "", 1000000000, "", false},
+		{"10K USD", "", 10000, "USD", true},
+		{"10KUSD", "", 10000, "USD", true},
+		{"$10k", "", 10000, "$", true},
+		{"10MXN", "", 10, "MXN", true},
+		{"10kr", "", 10, "kr", true},
 	}
 
 	for _, tc := range testCases {
-		t.Run(tc.input, func(t *testing.T) {
-			amount, currency, isValue := parseAmount(tc.input)
+		t.Run(tc.input+"_"+tc.locale, func(t *testing.T) {
+			amount, currency, isValue, err := parseAmount(tc.input, tc.locale)
+			assert.NoError(t, err)
 			assert.Equal(t, tc.expected, amount)
 			assert.Equal(t, tc.currency, currency)
 			assert.Equal(t, tc.isValue, isValue)
@@ -550,6 +916,30 @@ func TestParseAmount(t *testing.T) {
 	}
 }
 
+// Test amounts that can't be parsed or read differently per locale
+func TestParseAmountErrors(t *testing.T) {
+	testCases := []struct {
+		input  string
+		locale string
+	}{
+		{"invalid", ""},
+		{"1,000", ""},
+		{"1.000", ""},
+		{"1,00,000", ""},
+		{"1.000,50.25", ""},
+		{"$1000EUR", ""},
+		{"1000", "x"},
+		{"10kk", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input+"_"+tc.locale, func(t *testing.T) {
+			_, _, _, err := parseAmount(tc.input, tc.locale)
+			assert.Error(t, err)
+		})
+	}
+}
+
 // Test URL routing without external API calls
 func TestURLRoutingNoAPI(t *testing.T) {
 	router := setupTestRouterWithMocks()
@@ -563,7 +953,12 @@ func TestURLRoutingNoAPI(t *testing.T) {
 		{"Invalid amount", "/invalid/AAPL/on/2025-07-18?type=stock", http.StatusBadRequest},
 		{"Zero amount", "/0/AAPL/on/2025-07-18?type=stock", http.StatusBadRequest},
 		{"Invalid type", "/10/AAPL/on/2025-07-18?type=invalid", http.StatusBadRequest},
-		{"Invalid date", "/10/AAPL/on/invalid-date?type=stock", http.StatusInternalServerError},
+		{"Invalid priceAt", "/10/AAPL/on/2025-07-18?type=stock&priceAt=vwap", http.StatusBadRequest},
+		{"Invalid adjusted", "/10/AAPL/on/2025-07-18?type=stock&adjusted=maybe", http.StatusBadRequest},
+		{"Invalid depositRate", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?depositRate=-1", http.StatusBadRequest},
+		{"Adjusted DRIP", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?adjusted=true", http.StatusBadRequest},
+		{"Ambiguous amount", "/1,000/AAPL/on/2025-07-18?type=stock", http.StatusBadRequest},
+		{"Invalid date", "/10/AAPL/on/invalid-date?type=stock", http.StatusBadRequest},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The deepest peak-to-trough fall of a holding, and when it recovered
+type drawdown struct {
+	Percent      float64 `json:"percent"`
+	PeakDate     string  `json:"peakDate"`
+	TroughDate   string  `json:"troughDate"`
+	RecoveredOn  *string `json:"recoveredOn"`
+	RecoveryDays *int    `json:"recoveryDays"`
+	DurationDays *int    `json:"durationDays"`
+}
+
+// Milestone dates of a holding. Milestones not reached by the sell date are null.
+type milestones struct {
+	DoubledOn         *string   `json:"doubledOn"`
+	DaysToDouble      *int      `json:"daysToDouble"`
+	FirstUnderwaterOn *string   `json:"firstUnderwaterOn"`
+	MaxDrawdown       *drawdown `json:"maxDrawdown"`
+}
+
+// Helper function to count the calendar days between two dates
+func daysBetween(from, to string) int {
+	start, _ := time.Parse("2006-01-02", from)
+	end, _ := time.Parse("2006-01-02", to)
+	return int(end.Sub(start).Hours() / 24)
+}
+
+// Scan the daily prices after the buy date for milestones of a position bought at
+// buyPrice: when it first doubled, when it first went underwater, and its deepest
+// drawdown with how long that took to recover to the previous peak
+func calculateMilestones(buyDate string, buyPrice float64, points []pricePoint) milestones {
+	var result milestones
+
+	peak, peakDate := buyPrice, buyDate
+	var deepest *drawdown
+	var deepestPeak float64
+
+	for _, point := range points {
+		if point.Date <= buyDate {
+			continue
+		}
+
+		if result.DoubledOn == nil && point.Price >= 2*buyPrice {
+			date, days := point.Date, daysBetween(buyDate, point.Date)
+			result.DoubledOn, result.DaysToDouble = &date, &days
+		}
+		if result.FirstUnderwaterOn == nil && point.Price < buyPrice {
+			date := point.Date
+			result.FirstUnderwaterOn = &date
+		}
+
+		// Recovery of the deepest drawdown so far
+		if deepest != nil && deepest.RecoveredOn == nil && point.Price >= deepestPeak {
+			date := point.Date
+			recovery, duration := daysBetween(deepest.TroughDate, date), daysBetween(deepest.PeakDate, date)
+			deepest.RecoveredOn, deepest.RecoveryDays, deepest.DurationDays = &date, &recovery, &duration
+		}
+
+		if point.Price > peak {
+			peak, peakDate = point.Price, point.Date
+			continue
+		}
+		if fall := (point.Price/peak - 1) * 100; fall < 0 && (deepest == nil || fall < deepest.Percent) {
+			deepest = &drawdown{Percent: fall, PeakDate: peakDate, TroughDate: point.Date}
+			deepestPeak = peak
+		}
+	}
+
+	result.MaxDrawdown = deepest
+	return result
+}
+
+// Helper function to add a "milestones" block scanning the holding period's daily
+// prices: first doubled, first underwater, and the deepest drawdown's recovery
+func addMilestones(response gin.H, enabled bool, ticker, assetType, buyDate, sellDate string, buyPrice float64, opts priceOptions) error {
+	if !enabled {
+		return nil
+	}
+
+	points, err := fetchPriceHistory(ticker, assetType, dateOnly(buyDate), dateOnly(sellDate), opts)
+	if err != nil {
+		return err
+	}
+
+	response["milestones"] = calculateMilestones(dateOnly(buyDate), buyPrice, points)
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test milestone scanning over a price series
+func TestCalculateMilestones(t *testing.T) {
+	points := []pricePoint{
+		{Date: "2020-01-02", Price: 100},
+		{Date: "2020-02-03", Price: 120},
+		{Date: "2020-03-02", Price: 90},
+		{Date: "2020-03-16", Price: 60},
+		{Date: "2020-06-01", Price: 125},
+		{Date: "2020-09-01", Price: 210},
+	}
+
+	result := calculateMilestones("2020-01-02", 100, points)
+	assert.Equal(t, "2020-09-01", *result.DoubledOn)
+	assert.Equal(t, 243, *result.DaysToDouble)
+	assert.Equal(t, "2020-03-02", *result.FirstUnderwaterOn)
+
+	// 120 -> 60, back above 120 on 2020-06-01
+	assert.InDelta(t, -50.0, result.MaxDrawdown.Percent, 1e-9)
+	assert.Equal(t, "2020-02-03", result.MaxDrawdown.PeakDate)
+	assert.Equal(t, "2020-03-16", result.MaxDrawdown.TroughDate)
+	assert.Equal(t, "2020-06-01", *result.MaxDrawdown.RecoveredOn)
+	assert.Equal(t, 77, *result.MaxDrawdown.RecoveryDays)
+	assert.Equal(t, 119, *result.MaxDrawdown.DurationDays)
+
+	// A position that only rose has no underwater date or drawdown
+	result = calculateMilestones("2020-01-02", 100, points[:2])
+	assert.Nil(t, result.DoubledOn)
+	assert.Nil(t, result.FirstUnderwaterOn)
+	assert.Nil(t, result.MaxDrawdown)
+}
+
+// Test the milestones block on buy/sell routes
+func TestMilestonesWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?milestones=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	result := response["milestones"].(map[string]interface{})
+	assert.Nil(t, result["doubledOn"])
+	assert.Nil(t, result["firstUnderwaterOn"])
+
+	// 211.45 on 2025-05-15 fell to 205.17 and had not recovered by the sell date
+	drawdown := result["maxDrawdown"].(map[string]interface{})
+	assert.InDelta(t, (205.17/211.45-1)*100, drawdown["percent"], 1e-9)
+	assert.Equal(t, "2025-05-15", drawdown["peakDate"])
+	assert.Equal(t, "2025-06-30", drawdown["troughDate"])
+	assert.Nil(t, drawdown["recoveredOn"])
+}
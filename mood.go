@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A playful read of how a holding went, for bots and social posts. Score runs from
+// -100 (all regret) to 100 (all glee): doubling your money scores 60, halving it -60.
+type mood struct {
+	Score         float64 `json:"score"`
+	Feeling       string  `json:"feeling"`
+	Emoji         string  `json:"emoji"`
+	ReturnPercent float64 `json:"returnPercent"`
+	Summary       string  `json:"summary"`
+}
+
+// Emoji for return thresholds (percent), checked from the top
+var moodEmoji = []struct {
+	MinReturn float64
+	Emoji     string
+}{
+	{1000, "🚀🌕"},
+	{100, "🚀"},
+	{20, "📈"},
+	{-20, "😐"},
+	{-50, "📉"},
+	{math.Inf(-1), "📉💀"},
+}
+
+// Work out the mood of turning invested into finalValue over a holding period
+// of days. Gains held for a year or more earn diamond hands.
+func calculateMood(invested, finalValue float64, days int) mood {
+	growth := finalValue / invested
+	returnPercent := (growth - 1) * 100
+
+	result := mood{
+		Score:         math.Round(100 * (growth*growth - 1) / (growth*growth + 1)),
+		Feeling:       "meh",
+		ReturnPercent: returnPercent,
+	}
+	switch {
+	case result.Score >= 10:
+		result.Feeling = "glee"
+	case result.Score <= -10:
+		result.Feeling = "regret"
+	}
+
+	for _, tier := range moodEmoji {
+		if returnPercent >= tier.MinReturn {
+			result.Emoji = tier.Emoji
+			break
+		}
+	}
+	if returnPercent > 0 && days >= 365 {
+		result.Emoji += "💎🙌"
+	}
+
+	result.Summary = fmt.Sprintf("%s %+.1f%% (%s)", result.Emoji, returnPercent, result.Feeling)
+	return result
+}
+
+// Helper function to add a "mood" block scoring the USD gain or loss
+func addMood(response gin.H, enabled bool, investedUSD, finalValueUSD float64, buyDate, sellDate string) {
+	if !enabled || investedUSD <= 0 {
+		return
+	}
+	response["mood"] = calculateMood(investedUSD, finalValueUSD, daysBetween(dateOnly(buyDate), dateOnly(sellDate)))
+}
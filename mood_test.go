@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test scores, feelings and emoji
+func TestCalculateMood(t *testing.T) {
+	doubled := calculateMood(100, 200, 400)
+	assert.Equal(t, 60.0, doubled.Score)
+	assert.Equal(t, "glee", doubled.Feeling)
+	assert.Equal(t, "🚀💎🙌", doubled.Emoji)
+	assert.Equal(t, "🚀💎🙌 +100.0% (glee)", doubled.Summary)
+
+	halved := calculateMood(100, 50, 400)
+	assert.Equal(t, -60.0, halved.Score)
+	assert.Equal(t, "regret", halved.Feeling)
+	assert.Equal(t, "📉", halved.Emoji)
+
+	assert.Equal(t, "😐", calculateMood(100, 103, 30).Emoji)
+	assert.Equal(t, "meh", calculateMood(100, 103, 30).Feeling)
+	assert.Equal(t, "🚀🌕", calculateMood(100, 1200, 30).Emoji)
+	assert.Equal(t, "📉💀", calculateMood(100, 10, 30).Emoji)
+	assert.Equal(t, -100.0, calculateMood(100, 0, 30).Score)
+}
+
+// Test ?mood=true on the buy/sell route
+func TestMoodWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?mood=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Mood mood `json:"mood"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "😐", response.Mood.Emoji)
+	assert.InDelta(t, (211.18/200.50-1)*100, response.Mood.ReturnPercent, 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.NotContains(t, w.Body.String(), `"mood"`)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Shares of the underlying one option contract covers, unless ?contractSize= says otherwise
+const defaultContractSize = 100.0
+
+// A European option's terms, read from the query: ?right=call|put, ?strike= and
+// ?premium= (per share of the underlying, in USD) and ?contractSize=
+type optionTerms struct {
+	Right        string
+	Strike       float64
+	Premium      float64
+	ContractSize float64
+}
+
+// Helper function to read an option's terms from the query
+func optionTermsParam(c *gin.Context) (optionTerms, error) {
+	terms := optionTerms{Right: strings.ToLower(c.Query("right")), ContractSize: defaultContractSize}
+	if terms.Right != "call" && terms.Right != "put" {
+		return terms, fmt.Errorf("Invalid right parameter: must be 'call' or 'put'")
+	}
+	for name, value := range map[string]*float64{"strike": &terms.Strike, "premium": &terms.Premium, "contractSize": &terms.ContractSize} {
+		param := c.Query(name)
+		if param == "" && name == "contractSize" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(param, 64)
+		if err != nil || parsed <= 0 || math.IsInf(parsed, 0) {
+			return terms, fmt.Errorf("Invalid %s parameter: must be a number above 0", name)
+		}
+		*value = parsed
+	}
+	return terms, nil
+}
+
+// Payoff of one share's worth of the option at expiry, with the underlying at a price
+func (t optionTerms) intrinsicValue(underlying float64) float64 {
+	if t.Right == "call" {
+		return math.Max(0, underlying-t.Strike)
+	}
+	return math.Max(0, t.Strike-underlying)
+}
+
+// Price of the underlying at expiry where buying the option breaks even
+func (t optionTerms) breakEven() float64 {
+	if t.Right == "call" {
+		return t.Strike + t.Premium
+	}
+	return t.Strike - t.Premium
+}
+
+// Handle a buy/sell scenario for type=option: a European call or put on the ticker
+// bought on the buy date at the given premium and held to expiry on the sell date,
+// where it pays its intrinsic value. Value amounts spend that much on premiums;
+// quantities are numbers of contracts. ?underlyingType= is the ticker's own type.
+func handleOptionBuySell(c *gin.Context, parsedAmount float64, currency string, isValue bool, reportIn string) {
+	ticker := strings.ToUpper(c.Param("ticker"))
+	buyDate := c.Param("buyDate")
+	expiry := c.Param("sellDate")
+	if c.GetBool("stillHeld") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Options are valued at expiry: use /and-sold-on/:expiryDate"})
+		return
+	}
+
+	terms, err := optionTermsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	underlyingType := c.Query("underlyingType")
+	if underlyingType == "" {
+		underlyingType = "stock"
+		if isIndexTicker(ticker) {
+			underlyingType = "index"
+		}
+	}
+	if !isValidAssetType(underlyingType) || isModelledType(underlyingType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid underlyingType parameter: must be stock, crypto, index or commodity"})
+		return
+	}
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	fxRateBuy, fxRateSell := 1.0, 1.0
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+		if fxRateBuy, err = priceOpts.Notes.fxRate(currency, "USD", buyDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+			return
+		}
+		if fxRateSell, err = priceOpts.Notes.fxRate("USD", currency, expiry); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			return
+		}
+	}
+
+	// The underlying's price when the option was bought, for its moneyness, and
+	// at expiry, for its payoff
+	underlyingAtBuy, err := fetchPrice(ticker, buyDate, underlyingType, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch underlying price", err)
+		return
+	}
+	underlyingAtExpiry, err := fetchSellPrice(ticker, buyDate, expiry, underlyingType, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch underlying price at expiry", err)
+		return
+	}
+
+	contracts := parsedAmount
+	if isValue {
+		contracts = parsedAmount * fxRateBuy / (terms.Premium * terms.ContractSize)
+	}
+	premiumPaid := contracts * terms.ContractSize * terms.Premium
+	intrinsicValue := terms.intrinsicValue(underlyingAtExpiry)
+	finalValueUSD := contracts * terms.ContractSize * intrinsicValue
+
+	response := gin.H{
+		"ticker":             ticker,
+		"underlyingType":     underlyingType,
+		"right":              terms.Right,
+		"strike":             terms.Strike,
+		"premium":            terms.Premium,
+		"contractSize":       terms.ContractSize,
+		"contracts":          contracts,
+		"buyDate":            buyDate,
+		"sellDate":           expiry,
+		"underlyingAtBuy":    underlyingAtBuy,
+		"underlyingAtExpiry": underlyingAtExpiry,
+		"inTheMoney":         intrinsicValue > 0,
+		"intrinsicValue":     intrinsicValue,
+		"breakEven":          terms.breakEven(),
+		"premiumPaid":        premiumPaid,
+		"payoff":             finalValueUSD,
+		"profit":             finalValueUSD - premiumPaid,
+		"returnPercent":      (finalValueUSD/premiumPaid - 1) * 100,
+		"type":               "option",
+		"priceAt":            priceOpts.At,
+		"priceBasis":         priceOpts.basis(underlyingType),
+	}
+	if isValue {
+		response["message"] = localize(c, "Backtest result (value buy/sell of an option)")
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["finalValueUSD"] = finalValueUSD
+		response["finalValueInOriginalCurrency"] = finalValueUSD * fxRateSell
+		response["fxRateBuy"] = fxRateBuy
+		response["fxRateSell"] = fxRateSell
+	} else {
+		response["message"] = localize(c, "Backtest result (quantity buy/sell of options)")
+		response["quantity"] = parsedAmount
+		response["finalValue"] = finalValueUSD
+	}
+	addHoldingPeriod(response, ticker, buyDate, expiry, underlyingType, nil)
+	if err := addReportCurrency(response, reportIn, finalValueUSD, expiry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+		return
+	}
+	if err := addCashComparison(response, c.Query("compareCash") == "true", premiumPaid, finalValueUSD, fxRateSell, buyDate, expiry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cash rates", "details": err.Error()})
+		return
+	}
+	if err := addEquivalents(response, c.Query("funUnits") == "true", premiumPaid, finalValueUSD, expiry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load item prices", "details": err.Error()})
+		return
+	}
+	addMood(response, c.Query("mood") == "true", premiumPaid, finalValueUSD, buyDate, expiry)
+	if err := addBTCComparison(response, c.Query("vsBTC") == "true", premiumPaid, finalValueUSD, fxRateSell, buyDate, expiry, priceOpts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BTC prices", "details": err.Error()})
+		return
+	}
+	addCryptoUnits(response, ticker, "option", currency)
+	c.JSON(http.StatusOK, response)
+}
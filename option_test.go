@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test calls and puts pay what they're in the money by at expiry, never less than 0
+func TestOptionIntrinsicValue(t *testing.T) {
+	call := optionTerms{Right: "call", Strike: 200, Premium: 5}
+	assert.Equal(t, 11.0, call.intrinsicValue(211))
+	assert.Equal(t, 0.0, call.intrinsicValue(190))
+	assert.Equal(t, 205.0, call.breakEven())
+
+	put := optionTerms{Right: "put", Strike: 200, Premium: 5}
+	assert.Equal(t, 10.0, put.intrinsicValue(190))
+	assert.Equal(t, 0.0, put.intrinsicValue(211))
+	assert.Equal(t, 195.0, put.breakEven())
+}
+
+// Test option scenarios pay out from the underlying's close at expiry
+func TestOptionWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	// Two calls struck at 200, with AAPL at 211.18 at expiry
+	w := makeTestRequest(router, "GET", "/2/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&right=call&strike=200&premium=5")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "option", response["type"])
+	assert.Equal(t, "stock", response["underlyingType"])
+	assert.Equal(t, 200.50, response["underlyingAtBuy"])
+	assert.Equal(t, 211.18, response["underlyingAtExpiry"])
+	assert.Equal(t, true, response["inTheMoney"])
+	assert.Equal(t, 1000.0, response["premiumPaid"])
+	assert.InDelta(t, 2*100*11.18, response["finalValue"], 1e-9)
+	assert.InDelta(t, (2*100*11.18/1000-1)*100, response["returnPercent"], 1e-9)
+
+	// 800 USD of puts struck at 210 buys two contracts, which expire worthless
+	w = makeTestRequest(router, "GET", "/800USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&right=put&strike=210&premium=4")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response = nil
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2.0, response["contracts"])
+	assert.Equal(t, false, response["inTheMoney"])
+	assert.Equal(t, 0.0, response["finalValueInOriginalCurrency"])
+	assert.Equal(t, -100.0, response["returnPercent"])
+}
+
+// Test option scenarios without terms, or on other routes, are answered with 400
+func TestOptionErrors(t *testing.T) {
+	router := setupTestRouterWithMocks()
+
+	tests := map[string]string{
+		"/2/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&strike=200&premium=5":                                   "right",
+		"/2/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&right=call&premium=5":                                   "strike",
+		"/2/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&right=call&strike=200&premium=0":                        "premium",
+		"/2/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=option&right=call&strike=200&premium=5&underlyingType=bond":    "underlyingType",
+		"/2/AAPL/on/2025-03-31?type=option&right=call&strike=200&premium=5":                                               "need a sell date",
+		"/2/AAPL/on/2025-03-31/and-held?type=option&right=call&strike=200&premium=5":                                      "valued at expiry",
+		"/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/lump-sum-vs-dca?type=option&right=call&strike=1&premium=1": "bonds or options",
+	}
+	for path, message := range tests {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), message, path)
+	}
+}
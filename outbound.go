@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Client for all outbound provider and secret store traffic, set up from the
+// proxy and CA settings at startup. Requests are counted per provider.
+var outboundClient = &http.Client{Transport: countingTransport{http.DefaultTransport}}
+
+// Build the outbound client: through proxyURL if given (hosts in NO_PROXY still go
+// direct), otherwise through HTTP_PROXY/HTTPS_PROXY as usual, and trusting the PEM
+// certificates in caBundlePath on top of the system's
+func newOutboundClient(proxyURL, caBundlePath string) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if proxyURL != "" {
+		parsed, err := url.Parse(proxyURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https" && parsed.Scheme != "socks5") || parsed.Host == "" {
+			return nil, fmt.Errorf("outbound_proxy %q is not an http(s) or socks5 URL", proxyURL)
+		}
+		proxyFor := (&httpproxy.Config{HTTPProxy: proxyURL, HTTPSProxy: proxyURL, NoProxy: os.Getenv("NO_PROXY")}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFor(req.URL)
+		}
+	}
+
+	if caBundlePath != "" {
+		pem, err := os.ReadFile(caBundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("ca_bundle_path: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle_path %s has no PEM certificates", caBundlePath)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: countingTransport{transport}}, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test outbound requests go through the configured proxy, except NO_PROXY hosts
+func TestOutboundClientProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("via proxy"))
+	}))
+	defer proxy.Close()
+
+	t.Setenv("NO_PROXY", "direct.invalid")
+	client, err := newOutboundClient(proxy.URL, "")
+	assert.NoError(t, err)
+
+	resp, err := client.Get("http://www.alphavantage.example/query?function=TIME_SERIES_DAILY")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"http://www.alphavantage.example/query?function=TIME_SERIES_DAILY"}, proxied)
+
+	_, err = client.Get("http://direct.invalid/")
+	assert.Error(t, err)
+	assert.Len(t, proxied, 1)
+
+	_, err = newOutboundClient("ftp://proxy.example", "")
+	assert.ErrorContains(t, err, "is not an http(s) or socks5 URL")
+}
+
+// Test outbound requests trust the extra CA bundle
+func TestOutboundClientCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client, err := newOutboundClient("", "")
+	assert.NoError(t, err)
+	_, err = client.Get(server.URL)
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(path, certificate, 0o600))
+	client, err = newOutboundClient("", path)
+	assert.NoError(t, err)
+	resp, err := client.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = newOutboundClient("", filepath.Join(t.TempDir(), "missing.pem"))
+	assert.ErrorContains(t, err, "ca_bundle_path")
+	assert.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0o600))
+	_, err = newOutboundClient("", path)
+	assert.ErrorContains(t, err, "has no PEM certificates")
+}
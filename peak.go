@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The all-time high an at-the-peak-before backtest bought at: the highest close
+// in the history before a date
+type peakBuy struct {
+	Before      string  `json:"before"`
+	PeakDate    string  `json:"peakDate"`
+	PeakClose   float64 `json:"peakClose"`
+	HistoryFrom string  `json:"historyFrom"` // first day of the history searched
+	TradingDays int     `json:"tradingDays"`
+}
+
+// Find the highest close in a daily history. The first day to reach it wins, so a
+// peak that was matched later still counts from when it was first set.
+func calculatePeak(points []pricePoint, before string) (peakBuy, error) {
+	peak := peakBuy{Before: before}
+	for _, point := range points {
+		if point.Date >= before {
+			break
+		}
+		if peak.TradingDays == 0 {
+			peak.HistoryFrom = point.Date
+		}
+		peak.TradingDays++
+		if point.Price > peak.PeakClose {
+			peak.PeakDate, peak.PeakClose = point.Date, point.Price
+		}
+	}
+	if peak.PeakDate == "" {
+		return peak, fmt.Errorf("No closes before %s", before)
+	}
+	return peak, nil
+}
+
+// Middleware for the at-the-peak-before routes: the buy happens on the highest
+// close before :peakBefore, the worst moment to have bought, and the rest of the
+// backtest runs from there
+func withPeakBuy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if applyPeakBuy(c) {
+			c.Next()
+		}
+	}
+}
+
+// Helper function to set a request's buy date to the peak before its
+// :peakBefore date. Answers and returns false when there's no peak to buy at.
+func applyPeakBuy(c *gin.Context) bool {
+	before := c.Param("peakBefore")
+	ticker := c.Param("ticker")
+	assetType := assetTypeParam(c)
+	if c.Query("limitPrice") != "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "limitPrice can't be combined with at-the-peak-before"})
+		return false
+	}
+	if !isValidAssetType(assetType) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return false
+	}
+	if isModelledType(assetType) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "at-the-peak-before is not supported for bonds or options"})
+		return false
+	}
+	if hasTimeOfDay(before) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Peaks are found on daily closes: use a date without a time of day"})
+		return false
+	}
+
+	opts, err := priceOptionsParam(c)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	opts.At = "close"
+	points, err := fetchPriceHistory(ticker, assetType, "", before, opts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch price history", err)
+		c.Abort()
+		return false
+	}
+	peak, err := calculatePeak(points, before)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "No peak to buy at", "details": err.Error()})
+		return false
+	}
+
+	c.Params = append(c.Params, gin.Param{Key: "buyDate", Value: peak.PeakDate})
+	c.Set("peakBuy", peak)
+	return true
+}
+
+// Helper function to add the peak a backtest bought at, if any
+func addPeakBuy(response gin.H, c *gin.Context) {
+	if peak, ok := c.Get("peakBuy"); ok {
+		response["peak"] = peak
+	}
+}
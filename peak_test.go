@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the peak is the first day of the highest close before the date
+func TestCalculatePeak(t *testing.T) {
+	points := []pricePoint{
+		{Date: "2025-06-02", Price: 100},
+		{Date: "2025-06-03", Price: 120},
+		{Date: "2025-06-04", Price: 110},
+		{Date: "2025-06-05", Price: 120},
+		{Date: "2025-06-06", Price: 130},
+	}
+	peak, err := calculatePeak(points, "2025-06-06")
+	assert.NoError(t, err)
+	assert.Equal(t, "2025-06-03", peak.PeakDate)
+	assert.Equal(t, 120.0, peak.PeakClose)
+	assert.Equal(t, "2025-06-02", peak.HistoryFrom)
+	assert.Equal(t, 4, peak.TradingDays)
+
+	_, err = calculatePeak(points, "2025-06-02")
+	assert.Error(t, err)
+}
+
+// Test the at-the-peak-before routes buy at the highest mocked close before the date
+func TestAtThePeakBeforeWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		BuyDate    string  `json:"buyDate"`
+		BuyPrice   float64 `json:"buyPrice"`
+		SellPrice  float64 `json:"sellPrice"`
+		ClosePrice float64 `json:"closePrice"`
+		Peak       peakBuy `json:"peak"`
+	}
+	// 211.45 on 2025-05-15 is the high before 2025-07-18's 211.18
+	w := makeTestRequest(router, "GET", "/10/AAPL/at-the-peak-before/2025-07-18/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-05-15", response.BuyDate)
+	assert.Equal(t, 211.45, response.BuyPrice)
+	assert.Equal(t, 211.18, response.SellPrice)
+	assert.Equal(t, "2025-07-18", response.Peak.Before)
+	assert.Equal(t, "2025-05-15", response.Peak.PeakDate)
+
+	// Before the peak was set, the high is an earlier close
+	w = makeTestRequest(router, "GET", "/10/AAPL/at-the-peak-before/2025-05-15")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-03-31", response.Peak.PeakDate)
+	assert.Equal(t, 200.50, response.ClosePrice)
+
+	w = makeTestRequest(router, "GET", "/1000EUR/of/AAPL/at-the-peak-before/2025-05-15/and-sold-on/2025-07-18/with-dividends")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"peakDate":"2025-03-31"`)
+
+	for path, code := range map[string]int{
+		"/10/AAPL/at-the-peak-before/2025-03-31":                                  http.StatusNotFound,
+		"/10/AAPL/at-the-peak-before/2025-07-18/and-sold-on/2025-07-17":           http.StatusBadRequest,
+		"/10/AAPL/at-the-peak-before/2025-07-18?limitPrice=200":                   http.StatusBadRequest,
+		"/10/AAPL/at-the-peak-before/2025-07-18T10:00":                            http.StatusBadRequest,
+		"/10/AAPL/at-the-peak-before/2025-07-18/and-sold-on/2025-07-18?type=bond": http.StatusBadRequest,
+	} {
+		w = makeTestRequest(router, "GET", path)
+		assert.Equal(t, code, w.Code, path)
+	}
+}
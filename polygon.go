@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Polygon aggregates response struct
+// Example: https://api.polygon.io/v2/aggs/ticker/AAPL/range/1/day/2025-07-18/2025-07-18?adjusted=false&sort=asc&limit=50000&apiKey=demo
+type polygonAggregatesResponse struct {
+	Status  string `json:"status"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Results []struct {
+		Open  float64 `json:"o"`
+		High  float64 `json:"h"`
+		Low   float64 `json:"l"`
+		Close float64 `json:"c"`
+		Time  int64   `json:"t"`
+	} `json:"results"`
+}
+
+// Polygon's daily aggregates (bars) for US tickers, as traded. Polygon adjusts for
+// splits but not dividends, so it doesn't answer for adjusted prices rather than
+// mix the two bases.
+type polygonProvider struct{}
+
+// Returned for adjusted prices, which Polygon doesn't have
+var errPolygonAdjusted = errors.New("Polygon has no split- and dividend-adjusted prices")
+
+func (polygonProvider) Name() string { return "polygon" }
+
+// Fetch unadjusted daily bars between two dates as a series. Each bar is stamped at midnight
+// New York time on its trading day.
+func fetchPolygonSeries(ticker, start, end string) (dailySeries, error) {
+	cfg := settings()
+	query := url.Values{
+		"adjusted": {"false"},
+		"sort":     {"asc"},
+		"limit":    {"50000"},
+		"apiKey":   {cfg.PolygonAPIKey},
+	}
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/v2/aggs/ticker/%s/range/1/day/%s/%s?%s",
+		cfg.PolygonBaseURL, url.PathEscape(ticker), start, end, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result polygonAggregatesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Status == "ERROR" || result.Status == "NOT_AUTHORIZED" {
+		message := result.Error
+		if message == "" {
+			message = result.Message
+		}
+		return nil, fmt.Errorf("Polygon returned status %d %s", resp.StatusCode, message)
+	}
+
+	series := dailySeries{}
+	for _, bar := range result.Results {
+		date := time.UnixMilli(bar.Time).In(newYork).Format("2006-01-02")
+		series[date] = map[string]float64{"open": bar.Open, "high": bar.High, "low": bar.Low, "close": bar.Close}
+	}
+	return series, nil
+}
+
+func (polygonProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	if opts.Adjusted {
+		return 0, errPolygonAdjusted
+	}
+	series, err := fetchPolygonSeries(ticker, date, date)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := series[date][opts.At]
+	if !ok {
+		return 0, fmt.Errorf("No data for date %s", date)
+	}
+	return price, nil
+}
+
+func (polygonProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	if opts.Adjusted {
+		return nil, errPolygonAdjusted
+	}
+	if start == "" {
+		start = "1970-01-01"
+	}
+	if end == "" {
+		end = time.Now().In(newYork).Format("2006-01-02")
+	}
+	series, err := fetchPolygonSeries(ticker, start, end)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(series))
+	for date, day := range series {
+		points = append(points, pricePoint{Date: date, Price: day[opts.At]})
+	}
+	return points, nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Polygon aggregates API for AAPL with mockStockData's closes, each
+// bar opening 1 below its close. Other tickers have no results.
+func setupMockPolygon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("apiKey") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"status": "NOT_AUTHORIZED", "message": "Unknown API Key"}`)
+			return
+		}
+		// /v2/aggs/ticker/AAPL/range/1/day/2025-03-31/2025-07-18
+		parts := strings.Split(r.URL.Path, "/")
+		if len(parts) != 10 || r.URL.Query().Get("adjusted") != "false" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status": "ERROR", "error": "bad request"}`)
+			return
+		}
+		var result polygonAggregatesResponse
+		result.Status = "OK"
+		if parts[4] == "AAPL" {
+			for date, day := range mockStockData {
+				if date < parts[8] || date > parts[9] {
+					continue
+				}
+				var price float64
+				fmt.Sscan(day["4. close"], &price)
+				midnight, _ := time.ParseInLocation("2006-01-02", date, newYork)
+				result.Results = append(result.Results, struct {
+					Open  float64 `json:"o"`
+					High  float64 `json:"h"`
+					Low   float64 `json:"l"`
+					Close float64 `json:"c"`
+					Time  int64   `json:"t"`
+				}{price - 1, price + 1, price - 2, price, midnight.UnixMilli()})
+			}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.PolygonBaseURL, cfg.PolygonAPIKey = server.URL, "test-key" })
+}
+
+// Helper function to try stock providers in another order for a test
+func setStockProviderOrder(t *testing.T, order string) {
+	setSettings(t, func(cfg *Config) { cfg.StockProviders = order })
+}
+
+// Test Polygon's bars are read on their New York trading day
+func TestPolygonDailyPrice(t *testing.T) {
+	setupMockPolygon(t)
+
+	price, err := polygonProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+	price, err = polygonProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "open"})
+	assert.NoError(t, err)
+	assert.Equal(t, 210.18, price)
+
+	_, err = polygonProvider{}.DailyPrice("AAPL", "2025-07-19", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-19")
+	_, err = polygonProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close", Adjusted: true})
+	assert.ErrorIs(t, err, errPolygonAdjusted)
+
+	setSettings(t, func(cfg *Config) { cfg.PolygonAPIKey = "wrong" })
+	_, err = polygonProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Polygon returned status 401 Unknown API Key")
+}
+
+// Test Polygon's history covers the dates asked for
+func TestPolygonHistory(t *testing.T) {
+	setupMockPolygon(t)
+
+	points, err := polygonProvider{}.History("AAPL", "2025-06-01", "2025-07-17", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []pricePoint{{Date: "2025-06-20", Price: 205.75}, {Date: "2025-06-30", Price: 205.17}, {Date: "2025-07-17", Price: 210.02}}, points)
+}
+
+// Test stock prices fall back to Polygon when Alpha Vantage has none, and the
+// response notes it
+func TestStockProviderFallback(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	exhausted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("function") == "DIVIDENDS" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"symbol": r.URL.Query().Get("symbol"), "data": mockDividends})
+			return
+		}
+		fmt.Fprint(w, `{"Information": "Our standard API rate limit is 25 requests per day."}`)
+	}))
+	t.Cleanup(exhausted.Close)
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = exhausted.URL })
+	setupMockPolygon(t)
+	setStockProviderOrder(t, "alphaVantage,polygon")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		BuyPrice  float64      `json:"buyPrice"`
+		SellPrice float64      `json:"sellPrice"`
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 200.50, response.BuyPrice)
+	assert.Equal(t, 211.18, response.SellPrice)
+	for _, source := range response.DataNotes {
+		if source.Kind == "price" {
+			assert.Equal(t, "polygon", source.Provider)
+			assert.True(t, source.Fallback)
+		}
+	}
+
+	// With every provider failing, the error says how each did
+	_, _, err := fetchStockDailyPrice("MSFT", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "alphaVantage: No time series data returned from Alpha Vantage")
+	assert.ErrorContains(t, err, "polygon: No data for date 2025-07-18")
+
+	// Polygon first, when configured so
+	setStockProviderOrder(t, "polygon,alphaVantage")
+	_, answer, err := fetchStockDailyPrice("AAPL", "2025-06-30", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, priceAnswer{Date: "2025-06-30", Provider: "polygon"}, answer)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A portfolio position and its target weight (fraction of the portfolio)
+type portfolioHolding struct {
+	Ticker string
+	Type   string
+	Weight float64
+}
+
+// Parse a portfolio spec like "AAPL:60,MSFT:30,BTC:10:crypto" into holdings.
+// Weights are percentages summing to 100; each entry may name its asset type,
+// otherwise defaultType applies (index for ^ symbols).
+func parsePortfolio(spec, defaultType string) ([]portfolioHolding, error) {
+	var holdings []portfolioHolding
+	total := 0.0
+	seen := map[string]bool{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid holding %q: use TICKER:WEIGHT or TICKER:WEIGHT:TYPE", entry)
+		}
+
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("Invalid weight for %s: %q", parts[0], parts[1])
+		}
+
+		assetType := defaultType
+		if isIndexTicker(parts[0]) {
+			assetType = "index"
+		}
+		if len(parts) == 3 {
+			assetType = parts[2]
+		}
+		if !isValidAssetType(assetType) || isModelledType(assetType) {
+			return nil, fmt.Errorf("Unsupported type %q for %s", assetType, parts[0])
+		}
+
+		key := strings.ToUpper(parts[0]) + ":" + assetType
+		if seen[key] {
+			return nil, fmt.Errorf("%s is listed more than once", parts[0])
+		}
+		seen[key] = true
+
+		total += weight
+		holdings = append(holdings, portfolioHolding{Ticker: parts[0], Type: assetType, Weight: weight / 100})
+	}
+
+	if math.Abs(total-100) > 0.01 {
+		return nil, fmt.Errorf("Weights must add up to 100, got %g", total)
+	}
+	return holdings, nil
+}
+
+// A scheduled rebalance back to target weights
+type rebalanceEvent struct {
+	Date      string  `json:"date"`
+	ValueUSD  float64 `json:"valueUSD"`
+	TradedUSD float64 `json:"tradedUSD"`
+}
+
+// A periodic contribution, split across holdings by target weight
+type portfolioContribution struct {
+	Date      string  `json:"date"`
+	Amount    float64 `json:"amount"`
+	AmountUSD float64 `json:"amountUSD"`
+}
+
+// Outcome of running a portfolio through its contributions and rebalances
+type portfolioSimulation struct {
+	Contributions []portfolioContribution
+	Rebalances    []rebalanceEvent
+	TradedUSD     float64
+}
+
+// Run contributions (amount in currency, converted at each date's usdRate and
+// split by target weight) and rebalances back to the target weights over time,
+// updating shares in place. On a date with both, the contribution comes first.
+// Traded value counts one side of each rebalance (what was sold, which equals
+// what was bought).
+func simulatePortfolio(holdings []portfolioHolding, shares []float64, amount float64, contributionDates, rebalanceDates []string, usdRate func(date string) (float64, error), priceOn func(holding portfolioHolding, date string) (float64, error)) (*portfolioSimulation, error) {
+	contributes, rebalances := map[string]bool{}, map[string]bool{}
+	var dates []string
+	for _, date := range contributionDates {
+		contributes[date] = true
+		dates = append(dates, date)
+	}
+	for _, date := range rebalanceDates {
+		rebalances[date] = true
+		if !contributes[date] {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	result := &portfolioSimulation{Contributions: []portfolioContribution{}, Rebalances: []rebalanceEvent{}}
+	for _, date := range dates {
+		prices := make([]float64, len(holdings))
+		for i, holding := range holdings {
+			price, err := priceOn(holding, date)
+			if err != nil {
+				return nil, fmt.Errorf("%s on %s: %v", holding.Ticker, date, err)
+			}
+			prices[i] = price
+		}
+
+		if contributes[date] {
+			rate, err := usdRate(date)
+			if err != nil {
+				return nil, fmt.Errorf("FX rate for %s: %v", date, err)
+			}
+			for i, holding := range holdings {
+				shares[i] += amount * rate * holding.Weight / prices[i]
+			}
+			result.Contributions = append(result.Contributions, portfolioContribution{Date: date, Amount: amount, AmountUSD: amount * rate})
+		}
+
+		if rebalances[date] {
+			value := 0.0
+			for i := range holdings {
+				value += shares[i] * prices[i]
+			}
+
+			traded := 0.0
+			for i, holding := range holdings {
+				target := value * holding.Weight / prices[i]
+				traded += math.Abs(target-shares[i]) * prices[i]
+				shares[i] = target
+			}
+
+			result.TradedUSD += traded / 2
+			result.Rebalances = append(result.Rebalances, rebalanceEvent{Date: date, ValueUSD: value, TradedUSD: traded / 2})
+		}
+	}
+
+	return result, nil
+}
+
+// Outcome for one position of a portfolio
+type portfolioPosition struct {
+	Ticker        string  `json:"ticker"`
+	Type          string  `json:"type"`
+	TargetWeight  float64 `json:"targetWeight"`
+	BuyPrice      float64 `json:"buyPrice"`
+	SellPrice     float64 `json:"sellPrice"`
+	InitialShares float64 `json:"initialShares"`
+	Shares        float64 `json:"shares"`
+	FinalValueUSD float64 `json:"finalValueUSD"`
+	FinalWeight   float64 `json:"finalWeight"`
+	PriceBasis    string  `json:"priceBasis"`
+}
+
+// Handle a value invested across a weighted portfolio, optionally rebalanced back
+// to its targets (?rebalance=month|quarter|year) and compared against buy-and-hold.
+// With ?every=week|month|quarter|year the amount is instead contributed on each
+// period from the buy date, split by target weight.
+// Holdings are price-only; use adjusted=true for total return.
+func handlePortfolioBuySell(c *gin.Context) {
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	rebalance := c.DefaultQuery("rebalance", "none")
+	every := c.Query("every")
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+	if !isValue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Portfolios need a value amount, e.g. 10000USD"})
+		return
+	}
+
+	holdings, err := parsePortfolio(c.Param("portfolio"), c.DefaultQuery("type", "stock"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio", "details": err.Error()})
+		return
+	}
+
+	var rebalanceDates []string
+	if rebalance != "none" {
+		rebalanceDates, err = periodicDates(dateOnly(buyDate), dateOnly(sellDate), rebalance)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rebalance parameter", "details": err.Error()})
+			return
+		}
+		// The portfolio starts at its targets, so the first rebalance is the one after the buy date
+		rebalanceDates = rebalanceDates[1:]
+	}
+
+	var contributionDates []string
+	if every != "" {
+		contributionDates, err = periodicDates(dateOnly(buyDate), dateOnly(sellDate), every)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid every parameter", "details": err.Error()})
+			return
+		}
+	}
+
+	// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+	currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+		return
+	}
+
+	fxRateBuy, err := priceOpts.Notes.fxRate(currency, "USD", buyDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+		return
+	}
+
+	fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+		return
+	}
+
+	// A lump sum buys every position at its target weight on the buy date;
+	// contributions start from nothing
+	positions := make([]portfolioPosition, len(holdings))
+	shares := make([]float64, len(holdings))
+	for i, holding := range holdings {
+		buyPrice, err := fetchPrice(holding.Ticker, buyDate, holding.Type, priceOpts)
+		if err != nil {
+			respondPriceError(c, "Failed to fetch buy price for "+holding.Ticker, err)
+			return
+		}
+
+		sellPrice, err := fetchPrice(holding.Ticker, sellDate, holding.Type, priceOpts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price for " + holding.Ticker, "details": err.Error()})
+			return
+		}
+
+		if every == "" {
+			shares[i] = parsedAmount * fxRateBuy * holding.Weight / buyPrice
+		}
+		positions[i] = portfolioPosition{
+			Ticker:        holding.Ticker,
+			Type:          holding.Type,
+			TargetWeight:  holding.Weight * 100,
+			BuyPrice:      buyPrice,
+			SellPrice:     sellPrice,
+			InitialShares: shares[i],
+			PriceBasis:    priceOpts.basis(holding.Type),
+		}
+	}
+
+	// Contributions and rebalances falling on weekends or holidays trade on the
+	// next trading day. Prices are shared between both runs.
+	prices := map[string]float64{}
+	priceOn := func(holding portfolioHolding, date string) (float64, error) {
+		key := holding.Ticker + ":" + holding.Type + ":" + date
+		if price, ok := prices[key]; ok {
+			return price, nil
+		}
+		price, err := fetchPriceOnOrAfter(holding.Ticker, date, holding.Type, priceOpts)
+		if err != nil {
+			return 0, err
+		}
+		prices[key] = price
+		return price, nil
+	}
+	usdRate := func(date string) (float64, error) {
+		return priceOpts.Notes.fxRate(currency, "USD", date)
+	}
+
+	// The same contributions without rebalancing, to compare against
+	heldShares := append([]float64{}, shares...)
+	_, err = simulatePortfolio(holdings, heldShares, parsedAmount, contributionDates, nil, usdRate, priceOn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price contributions", "details": err.Error()})
+		return
+	}
+
+	simulation, err := simulatePortfolio(holdings, shares, parsedAmount, contributionDates, rebalanceDates, usdRate, priceOn)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price rebalance", "details": err.Error()})
+		return
+	}
+
+	finalValueUSD, buyAndHoldUSD := 0.0, 0.0
+	for i := range positions {
+		positions[i].Shares = shares[i]
+		positions[i].FinalValueUSD = shares[i] * positions[i].SellPrice
+		finalValueUSD += positions[i].FinalValueUSD
+		buyAndHoldUSD += heldShares[i] * positions[i].SellPrice
+	}
+	for i := range positions {
+		positions[i].FinalWeight = positions[i].FinalValueUSD / finalValueUSD * 100
+	}
+
+	// Turnover as the share of the portfolio traded, summed over every rebalance
+	turnoverPercent := 0.0
+	for _, event := range simulation.Rebalances {
+		turnoverPercent += event.TradedUSD / event.ValueUSD * 100
+	}
+
+	invested, investedUSD := parsedAmount, parsedAmount*fxRateBuy
+	if every != "" {
+		invested, investedUSD = 0, 0
+		for _, contribution := range simulation.Contributions {
+			invested += contribution.Amount
+			investedUSD += contribution.AmountUSD
+		}
+	}
+
+	response := gin.H{
+		"message":                      localize(c, "Backtest result (portfolio buy/sell)"),
+		"value":                        parsedAmount,
+		"currency":                     currency,
+		"buyDate":                      buyDate,
+		"sellDate":                     sellDate,
+		"holdings":                     positions,
+		"invested":                     invested,
+		"investedUSD":                  investedUSD,
+		"rebalance":                    rebalance,
+		"rebalances":                   simulation.Rebalances,
+		"turnoverUSD":                  simulation.TradedUSD,
+		"turnoverPercent":              turnoverPercent,
+		"finalValueUSD":                finalValueUSD,
+		"finalValueInOriginalCurrency": finalValueUSD * fxRateSell,
+		"buyAndHold": gin.H{
+			"finalValueUSD":                buyAndHoldUSD,
+			"finalValueInOriginalCurrency": buyAndHoldUSD * fxRateSell,
+		},
+		"differenceUSD": finalValueUSD - buyAndHoldUSD,
+		"fxRateBuy":     fxRateBuy,
+		"fxRateSell":    fxRateSell,
+		"priceAt":       priceOpts.At,
+	}
+	if every != "" {
+		response["message"] = localize(c, "Backtest result (portfolio contributions)")
+		response["every"] = every
+		response["contributions"] = simulation.Contributions
+	}
+	for _, holding := range holdings {
+		addCryptoPricing(response, holding.Type)
+	}
+	c.JSON(http.StatusOK, response)
+}
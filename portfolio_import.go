@@ -0,0 +1,487 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most rows a portfolio CSV can have
+const maxImportRows = 200
+
+// The columns a portfolio CSV can have, named in its header row. Each row buys
+// (or, with side=sell, sells) a quantity of shares, or buys an amount in its
+// currency (USD by default).
+var importColumns = []string{"date", "ticker", "quantity", "amount", "currency", "type", "side"}
+
+// One trade from a portfolio CSV and what it came to. Invested (for buys) and
+// Proceeds (for sells) are in the report currency.
+type importedTrade struct {
+	Line        int     `json:"line"`
+	Date        string  `json:"date"`
+	Ticker      string  `json:"ticker"`
+	Type        string  `json:"type"`
+	Side        string  `json:"side"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Amount      float64 `json:"amount,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+	Price       float64 `json:"price"`
+	Shares      float64 `json:"shares"`
+	InvestedUSD float64 `json:"investedUSD,omitempty"`
+	Invested    float64 `json:"invested,omitempty"`
+	ProceedsUSD float64 `json:"proceedsUSD,omitempty"`
+	Proceeds    float64 `json:"proceeds,omitempty"`
+}
+
+// A row of a portfolio CSV that can't be imported
+type importError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// An imported portfolio's trades of one asset, together. FinalValue is what the
+// shares still held come to on the sell date.
+type importedPosition struct {
+	Ticker        string  `json:"ticker"`
+	Type          string  `json:"type"`
+	Buys          int     `json:"buys"`
+	Sells         int     `json:"sells"`
+	Shares        float64 `json:"shares"`
+	SellPrice     float64 `json:"sellPrice,omitempty"`
+	InvestedUSD   float64 `json:"investedUSD"`
+	Invested      float64 `json:"invested"`
+	Proceeds      float64 `json:"proceeds"`
+	FinalValueUSD float64 `json:"finalValueUSD"`
+	FinalValue    float64 `json:"finalValue"`
+	ReturnPercent float64 `json:"returnPercent"`
+	Weight        float64 `json:"weight"` // percent of the holdings' final value
+	PriceBasis    string  `json:"priceBasis"`
+}
+
+// Parse a portfolio CSV into trades, checking every row. With a broker, the CSV
+// is that broker's export, whose rows are mapped onto the import's columns first
+// and skipped when they aren't trades. Rows that can't be right come back as
+// import errors with their line numbers, so they can all be fixed at once; the
+// error is for a CSV that can't be read at all.
+func parsePortfolioCSV(r io.Reader, broker *brokerFormat, symbols map[string]string, defaultType, locale, homeCurrency string) ([]importedTrade, []importError, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if len(records) < 2 {
+		return nil, nil, 0, fmt.Errorf("The CSV needs a header row and at least one trade")
+	}
+	if len(records)-1 > maxImportRows {
+		return nil, nil, 0, fmt.Errorf("The CSV has %d rows: at most %d can be imported at once", len(records)-1, maxImportRows)
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		// Spreadsheets often save UTF-8 with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+	if err := checkImportColumns(columns, broker); err != nil {
+		return nil, nil, 0, err
+	}
+
+	trades := []importedTrade{}
+	rowErrors := []importError{}
+	skipped := 0
+	for n, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if broker != nil {
+			row, err := broker.row(field, symbols)
+			if err != nil {
+				rowErrors = append(rowErrors, importError{Line: n + 2, Error: err.Error()})
+				continue
+			}
+			if row == nil {
+				skipped++
+				continue
+			}
+			field = func(name string) string { return row[name] }
+		}
+		trade, err := parseImportedTrade(field, symbols, defaultType, locale, homeCurrency)
+		if err != nil {
+			rowErrors = append(rowErrors, importError{Line: n + 2, Error: err.Error()})
+			continue
+		}
+		trade.Line = n + 2
+		trades = append(trades, trade)
+	}
+	if len(trades) == 0 && len(rowErrors) == 0 {
+		return nil, nil, skipped, fmt.Errorf("The CSV has no trades to import")
+	}
+	return trades, rowErrors, skipped, nil
+}
+
+// Helper function to check a CSV's header has the columns needed: the import's
+// own, or the broker's
+func checkImportColumns(columns map[string]int, broker *brokerFormat) error {
+	if broker != nil {
+		for _, name := range broker.Columns {
+			if _, ok := columns[name]; !ok {
+				return fmt.Errorf("The CSV isn't a %s export: it has no %q column", broker.Name, name)
+			}
+		}
+		return nil
+	}
+
+	for name := range columns {
+		known := false
+		for _, column := range importColumns {
+			known = known || name == column
+		}
+		if !known {
+			return fmt.Errorf("Unknown column %q: use %s", name, strings.Join(importColumns, ", "))
+		}
+	}
+	_, hasQuantity := columns["quantity"]
+	_, hasAmount := columns["amount"]
+	if _, ok := columns["date"]; !ok {
+		return fmt.Errorf("The CSV needs a date column")
+	}
+	if _, ok := columns["ticker"]; !ok {
+		return fmt.Errorf("The CSV needs a ticker column")
+	}
+	if !hasQuantity && !hasAmount {
+		return fmt.Errorf("The CSV needs a quantity or an amount column")
+	}
+	return nil
+}
+
+// Helper function to check one row of a portfolio CSV. Tickers are mapped through
+// symbols (?symbols=) first.
+func parseImportedTrade(field func(name string) string, symbols map[string]string, defaultType, locale, homeCurrency string) (importedTrade, error) {
+	trade := importedTrade{Date: field("date"), Ticker: strings.ToUpper(field("ticker")), Type: defaultType, Side: strings.ToLower(field("side"))}
+	if ticker, ok := symbols[trade.Ticker]; ok {
+		trade.Ticker = ticker
+	}
+	if hasTimeOfDay(trade.Date) {
+		return trade, fmt.Errorf("date %s: imported trades are priced on daily prices, without a time of day", trade.Date)
+	}
+	if err := checkDateParam("date", trade.Date); err != nil {
+		return trade, err
+	}
+	if !tickerRegex.MatchString(trade.Ticker) {
+		return trade, fmt.Errorf("%q is not a ticker", trade.Ticker)
+	}
+	if isIndexTicker(trade.Ticker) {
+		trade.Type = "index"
+	}
+	if assetType := strings.ToLower(field("type")); assetType != "" {
+		trade.Type = assetType
+	}
+	if !isValidAssetType(trade.Type) || isModelledType(trade.Type) {
+		return trade, fmt.Errorf("Unsupported type %q for %s", trade.Type, trade.Ticker)
+	}
+	if trade.Side == "" {
+		trade.Side = "buy"
+	}
+	if trade.Side != "buy" && trade.Side != "sell" {
+		return trade, fmt.Errorf("side %q must be buy or sell", trade.Side)
+	}
+
+	quantity, amount := field("quantity"), field("amount")
+	switch {
+	case quantity != "" && amount != "":
+		return trade, fmt.Errorf("give a quantity or an amount, not both")
+	case quantity != "":
+		parsed, _, isValue, err := parseAmount(quantity, locale)
+		if err != nil || isValue || parsed <= 0 {
+			return trade, fmt.Errorf("quantity %q must be a number of shares above 0", quantity)
+		}
+		trade.Quantity = parsed
+	case amount != "" && trade.Side == "sell":
+		return trade, fmt.Errorf("sells need a quantity of shares")
+	case amount != "":
+		parsed, currency, _, err := parseAmount(amount, locale)
+		if err != nil || parsed <= 0 {
+			return trade, fmt.Errorf("amount %q must be a number above 0", amount)
+		}
+		if column := field("currency"); column != "" {
+			if currency != "" && !strings.EqualFold(currency, column) {
+				return trade, fmt.Errorf("amount %q is not in %s", amount, column)
+			}
+			currency = column
+		}
+		if currency == "" {
+			currency = "USD"
+		}
+		if _, ok := currencySymbols[currency]; !ok {
+			currency = strings.ToUpper(currency)
+		}
+		if trade.Currency, err = resolveCurrency(currency, homeCurrency); err != nil {
+			return trade, err
+		}
+		trade.Amount = parsed
+	default:
+		return trade, fmt.Errorf("give a quantity or an amount")
+	}
+	return trade, nil
+}
+
+// Helper function to read ?symbols= (US0378331005:AAPL,VUSA:VUSA.L), mapping the
+// ISINs or tickers in a CSV to the tickers to price them as
+func importSymbolsParam(c *gin.Context) (map[string]string, error) {
+	symbols := map[string]string{}
+	if param := c.Query("symbols"); param != "" {
+		for _, entry := range strings.Split(param, ",") {
+			from, to, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || from == "" || !tickerRegex.MatchString(to) {
+				return nil, fmt.Errorf("Invalid symbols parameter: %q should be ISIN:TICKER or TICKER:TICKER", entry)
+			}
+			symbols[strings.ToUpper(from)] = strings.ToUpper(to)
+		}
+	}
+	return symbols, nil
+}
+
+// Helper function to read the CSV of an import: the request body, or the "file"
+// field of a multipart form
+func importBody(c *gin.Context) (io.ReadCloser, error) {
+	if c.ContentType() != "multipart/form-data" {
+		return c.Request.Body, nil
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("Upload the CSV as the file field: %v", err)
+	}
+	return header.Open()
+}
+
+// Import a portfolio as a CSV of trades (POST /v1/portfolio/import), or a broker's
+// export with ?broker=, and backtest it in one call. Trades replay in date order:
+// buys buy their quantity, or their amount at the day's FX rate, and sells sell
+// shares for cash at the day's price. What's still held is valued on ?sellDate=
+// (the most recent close by default). Values are reported in ?currency=, by
+// default the amounts' currency if they share one, otherwise USD. Holdings are
+// price-only; use adjusted=true for total return.
+func handlePortfolioImport(c *gin.Context) {
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sellDate := c.Query("sellDate")
+	if sellDate == "" {
+		// Valued on the latest close, which moves on tomorrow
+		sellDate = latestCloseDate("", "stock", time.Now())
+		c.Set("stillHeld", true)
+	} else if err := checkDateParam("sellDate", sellDate); err != nil {
+		abortWithDateError(c, err)
+		return
+	}
+	var broker *brokerFormat
+	if name := strings.ToLower(c.Query("broker")); name != "" {
+		format, ok := brokerFormats[name]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid broker parameter: must be one of " + strings.Join(brokerNames(), ", ")})
+			return
+		}
+		broker = &format
+	}
+	symbols, err := importSymbolsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	body, err := importBody(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio CSV", "details": err.Error()})
+		return
+	}
+	defer body.Close()
+	trades, rowErrors, skipped, err := parsePortfolioCSV(body, broker, symbols, c.DefaultQuery("type", "stock"), c.Query("locale"), c.Query("homeCurrency"))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body is too large (max %d bytes)", tooLarge.Limit)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio CSV", "details": err.Error()})
+		return
+	}
+	for _, trade := range trades {
+		if dateOnly(sellDate) <= trade.Date {
+			rowErrors = append(rowErrors, importError{Line: trade.Line, Error: fmt.Sprintf("date %s must be before sellDate %s", trade.Date, sellDate)})
+		}
+	}
+	if len(rowErrors) > 0 {
+		sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Line < rowErrors[j].Line })
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio CSV", "details": fmt.Sprintf("%d of the rows can't be imported", len(rowErrors)), "rows": rowErrors})
+		return
+	}
+
+	currency := c.Query("currency")
+	if currency == "" {
+		currencies := map[string]bool{}
+		for _, trade := range trades {
+			if trade.Currency != "" {
+				currencies[trade.Currency] = true
+			}
+		}
+		currency = "USD"
+		if len(currencies) == 1 {
+			for code := range currencies {
+				currency = code
+			}
+		}
+	}
+	if currency, err = resolveCurrency(currency, c.Query("homeCurrency")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+		return
+	}
+	fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+		return
+	}
+
+	// Replay the trades in date order, buys before sells on the same day, whatever
+	// order the CSV lists them in (broker exports are often newest first)
+	sort.SliceStable(trades, func(i, j int) bool {
+		if trades[i].Date != trades[j].Date {
+			return trades[i].Date < trades[j].Date
+		}
+		return trades[i].Side == "buy" && trades[j].Side == "sell"
+	})
+	positions := []importedPosition{}
+	index := map[string]int{}
+	for i := range trades {
+		trade := &trades[i]
+		trade.Price, err = fetchPrice(trade.Ticker, trade.Date, trade.Type, priceOpts)
+		if err != nil {
+			respondPriceError(c, fmt.Sprintf("Failed to fetch price for %s on line %d", trade.Ticker, trade.Line), err)
+			return
+		}
+		toUSD := 1.0
+		if trade.Currency != "" {
+			if toUSD, err = priceOpts.Notes.fxRate(trade.Currency, "USD", trade.Date); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch FX rate for line %d", trade.Line), "details": err.Error()})
+				return
+			}
+		}
+		fromUSD, err := priceOpts.Notes.fxRate("USD", currency, trade.Date)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to fetch FX rate for line %d", trade.Line), "details": err.Error()})
+			return
+		}
+
+		key := trade.Ticker + ":" + trade.Type
+		if _, ok := index[key]; !ok {
+			index[key] = len(positions)
+			positions = append(positions, importedPosition{Ticker: trade.Ticker, Type: trade.Type, PriceBasis: priceOpts.basis(trade.Type)})
+		}
+		position := &positions[index[key]]
+
+		if trade.Side == "sell" {
+			// A little slack for the rounding in brokers' fractional shares
+			if trade.Quantity > position.Shares*(1+1e-6) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid portfolio CSV", "details": fmt.Sprintf("line %d sells %g shares of %s on %s, but only %g are held then", trade.Line, trade.Quantity, trade.Ticker, trade.Date, position.Shares)})
+				return
+			}
+			trade.Shares = math.Min(trade.Quantity, position.Shares)
+			trade.ProceedsUSD = trade.Shares * trade.Price
+			trade.Proceeds = trade.ProceedsUSD * fromUSD
+			position.Sells++
+			position.Shares -= trade.Shares
+			position.Proceeds += trade.Proceeds
+			continue
+		}
+
+		if trade.Currency == "" {
+			trade.Shares = trade.Quantity
+			trade.InvestedUSD = trade.Quantity * trade.Price
+		} else {
+			trade.InvestedUSD = trade.Amount * toUSD
+			trade.Shares = trade.InvestedUSD / trade.Price
+		}
+		trade.Invested = trade.InvestedUSD * fromUSD
+		if trade.Currency == currency {
+			trade.Invested = trade.Amount
+		}
+		position.Buys++
+		position.Shares += trade.Shares
+		position.InvestedUSD += trade.InvestedUSD
+		position.Invested += trade.Invested
+	}
+
+	// Value what's still held; sold-out positions are all cash
+	holdingsValue, holdingsValueUSD := 0.0, 0.0
+	for i := range positions {
+		position := &positions[i]
+		if position.Shares > 0 {
+			position.SellPrice, err = fetchSellPrice(position.Ticker, trades[0].Date, sellDate, position.Type, priceOpts)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price for " + position.Ticker, "details": err.Error()})
+				return
+			}
+		}
+		position.FinalValueUSD = position.Shares * position.SellPrice
+		position.FinalValue = position.FinalValueUSD * fxRateSell
+		position.ReturnPercent = ((position.FinalValue+position.Proceeds)/position.Invested - 1) * 100
+		holdingsValue += position.FinalValue
+		holdingsValueUSD += position.FinalValueUSD
+	}
+	for i := range positions {
+		if holdingsValue > 0 {
+			positions[i].Weight = positions[i].FinalValue / holdingsValue * 100
+		}
+	}
+
+	invested, proceeds := 0.0, 0.0
+	flows := make([]cashFlow, 0, len(trades)+1)
+	for _, trade := range trades {
+		invested += trade.Invested
+		proceeds += trade.Proceeds
+		flows = append(flows, cashFlow{trade.Date, trade.Proceeds - trade.Invested})
+	}
+	finalValue := holdingsValue + proceeds
+
+	response := gin.H{
+		"message":          localize(c, "Backtest result (imported portfolio)"),
+		"currency":         currency,
+		"sellDate":         sellDate,
+		"trades":           trades,
+		"positions":        positions,
+		"invested":         invested,
+		"proceeds":         proceeds,
+		"holdingsValue":    holdingsValue,
+		"holdingsValueUSD": holdingsValueUSD,
+		"finalValue":       finalValue,
+		"gain":             finalValue - invested,
+		"returnPercent":    (finalValue/invested - 1) * 100,
+		"fxRateSell":       fxRateSell,
+		"priceAt":          priceOpts.At,
+	}
+	if broker != nil {
+		response["broker"] = broker.Name
+		response["skippedRows"] = skipped
+	}
+	// Money-weighted return over the dates each trade went in or came out, in the
+	// report currency
+	addXIRR(response, append(flows, cashFlow{sellDate, holdingsValue}))
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to POST a portfolio CSV
+func postPortfolioCSV(router *gin.Engine, query, csv string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/v1/portfolio/import"+query, strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test every bad row of a CSV is reported with its line
+func TestParsePortfolioCSV(t *testing.T) {
+	trades, rowErrors, _, err := parsePortfolioCSV(strings.NewReader("\ufeffDate,Ticker,Amount,Currency\n2025-03-31,aapl,1000EUR,\n2025-06-30,^GSPC,\"1,000.50\",usd\n"), nil, nil, "stock", "", "")
+	assert.NoError(t, err)
+	assert.Empty(t, rowErrors)
+	assert.Equal(t, "AAPL", trades[0].Ticker)
+	assert.Equal(t, "EUR", trades[0].Currency)
+	assert.Equal(t, "buy", trades[0].Side)
+	assert.Equal(t, "index", trades[1].Type)
+	assert.Equal(t, 1000.50, trades[1].Amount)
+	assert.Equal(t, 3, trades[1].Line)
+
+	// Sells need a quantity, and tickers map through symbols
+	trades, rowErrors, _, err = parsePortfolioCSV(strings.NewReader("date,ticker,quantity,amount,side\n2025-03-31,VUSA,10,,SELL\n2025-03-31,AAPL,,1000,sell\n2025-03-31,AAPL,10,,short\n"), nil, map[string]string{"VUSA": "VOO"}, "stock", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "VOO", trades[0].Ticker)
+	assert.Equal(t, "sell", trades[0].Side)
+	assert.Equal(t, []int{3, 4}, []int{rowErrors[0].Line, rowErrors[1].Line})
+
+	_, rowErrors, _, err = parsePortfolioCSV(strings.NewReader("date,ticker,quantity,amount\n2025-03-31,AAPL,10,1000\n2025-03-31,AAPL,10,\nyesterday,AAPL,10,\n2025-03-31,AAPL,,\n"), nil, nil, "stock", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 4, 5}, []int{rowErrors[0].Line, rowErrors[1].Line, rowErrors[2].Line})
+
+	for _, csv := range []string{"date,ticker,quantity\n", "date,ticker,shares\n2025-03-31,AAPL,10\n", "date,quantity\n2025-03-31,10\n", "date,ticker\n2025-03-31,AAPL\n"} {
+		_, _, _, err = parsePortfolioCSV(strings.NewReader(csv), nil, nil, "stock", "", "")
+		assert.Error(t, err, csv)
+	}
+}
+
+// Test an imported portfolio is backtested in the amounts' currency
+func TestPortfolioImportWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		Currency      string             `json:"currency"`
+		Trades        []importedTrade    `json:"trades"`
+		Positions     []importedPosition `json:"positions"`
+		Invested      float64            `json:"invested"`
+		Proceeds      float64            `json:"proceeds"`
+		HoldingsValue float64            `json:"holdingsValue"`
+		FinalValue    float64            `json:"finalValue"`
+		ReturnPercent float64            `json:"returnPercent"`
+		XIRRPercent   float64            `json:"xirrPercent"`
+	}
+	csv := "date,ticker,quantity,amount,currency\n2025-03-31,AAPL,10,,\n2025-06-30,AAPL,,1000,USD\n2025-03-31,AAPL,,500,EUR\n"
+	w := postPortfolioCSV(router, "?sellDate=2025-07-18", csv)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// Amounts in USD and EUR report in USD
+	shares := 10 + 1000/205.17 + 540/200.50
+	assert.Equal(t, "USD", response.Currency)
+	assert.InDelta(t, 2005+1000+540, response.Invested, 1e-9)
+	assert.InDelta(t, shares*211.18, response.FinalValue, 1e-9)
+	assert.Len(t, response.Positions, 1)
+	assert.Equal(t, 3, response.Positions[0].Buys)
+	assert.InDelta(t, 100, response.Positions[0].Weight, 1e-9)
+	flows := []cashFlow{{"2025-03-31", -2005}, {"2025-06-30", -1000}, {"2025-03-31", -540}, {"2025-07-18", shares * 211.18}}
+	assert.InDelta(t, 0, netPresentValue(flows, response.XIRRPercent/100), 1e-6)
+
+	// Sells replay in date order, whatever order the CSV lists them in, and what
+	// they raise counts as cash
+	csv = "date,ticker,quantity,side\n2025-06-30,AAPL,4,sell\n2025-03-31,AAPL,10,buy\n"
+	w = postPortfolioCSV(router, "?sellDate=2025-07-18", csv)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "buy", response.Trades[0].Side)
+	assert.InDelta(t, 4*205.17, response.Proceeds, 1e-9)
+	assert.InDelta(t, 6*211.18, response.HoldingsValue, 1e-9)
+	assert.InDelta(t, 4*205.17+6*211.18, response.FinalValue, 1e-9)
+	assert.Equal(t, 1, response.Positions[0].Sells)
+	assert.InDelta(t, 6, response.Positions[0].Shares, 1e-9)
+
+	// Selling more than is held then
+	w = postPortfolioCSV(router, "?sellDate=2025-07-18", "date,ticker,quantity,side\n2025-03-31,AAPL,10,sell\n2025-06-30,AAPL,10,buy\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "only 0 are held")
+
+	// The same CSV uploaded as a form
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	file, _ := form.CreateFormFile("file", "portfolio.csv")
+	file.Write([]byte("date,ticker,amount,currency\n2025-03-31,AAPL,1000,EUR\n"))
+	form.Close()
+	req, _ := http.NewRequest("POST", "/v1/portfolio/import?sellDate=2025-07-18", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "EUR", response.Currency)
+	assert.InDelta(t, 1000, response.Invested, 1e-9)
+	assert.InDelta(t, (1080/200.50*211.18/1.16/1000-1)*100, response.ReturnPercent, 1e-6)
+
+	// Rows bought on or after the sale can't be imported
+	w = postPortfolioCSV(router, "?sellDate=2025-07-18", "date,ticker,quantity\n2025-03-31,AAPL,10\n2025-07-18,AAPL,10\n2025-03-31,AAPL,-1\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var failure struct {
+		Rows []importError `json:"rows"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &failure))
+	assert.Len(t, failure.Rows, 2)
+
+	w = postPortfolioCSV(router, "?sellDate=2025-07-18", "not,a,portfolio\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
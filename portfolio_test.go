@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test parsing portfolio specs
+func TestParsePortfolio(t *testing.T) {
+	holdings, err := parsePortfolio("AAPL:60,BTC:30:crypto,^GSPC:10", "stock")
+	assert.NoError(t, err)
+	assert.Equal(t, []portfolioHolding{
+		{Ticker: "AAPL", Type: "stock", Weight: 0.6},
+		{Ticker: "BTC", Type: "crypto", Weight: 0.3},
+		{Ticker: "^GSPC", Type: "index", Weight: 0.1},
+	}, holdings)
+
+	for _, spec := range []string{"AAPL:60,MSFT:30", "AAPL", "AAPL:abc", "AAPL:-10,MSFT:110", "AAPL:50,AAPL:50", "UST10Y:100:bond"} {
+		_, err := parsePortfolio(spec, "stock")
+		assert.Error(t, err, spec)
+	}
+}
+
+// Test contributions and rebalancing back to target weights
+func TestSimulatePortfolio(t *testing.T) {
+	holdings := []portfolioHolding{{Ticker: "A", Weight: 0.5}, {Ticker: "B", Weight: 0.5}}
+	prices := map[string]map[string]float64{
+		"2025-03-31": {"A": 10, "B": 10},
+		"2025-06-30": {"A": 30, "B": 10},
+	}
+	priceOn := func(holding portfolioHolding, date string) (float64, error) {
+		return prices[date][holding.Ticker], nil
+	}
+	usdRate := func(date string) (float64, error) { return 1, nil }
+
+	// $400 split 200/200: sell 10/3 A ($100) and buy 10 B
+	shares := []float64{10, 10}
+	result, err := simulatePortfolio(holdings, shares, 0, nil, []string{"2025-06-30"}, usdRate, priceOn)
+	assert.NoError(t, err)
+	assert.InDelta(t, 100.0, result.TradedUSD, 1e-9)
+	assert.InDelta(t, 200.0/30, shares[0], 1e-9)
+	assert.InDelta(t, 20.0, shares[1], 1e-9)
+	assert.Equal(t, []rebalanceEvent{{Date: "2025-06-30", ValueUSD: 400, TradedUSD: 100}}, result.Rebalances)
+
+	// $200 contributions split 100/100, the second before rebalancing: $600 split 300/300
+	shares = []float64{0, 0}
+	result, err = simulatePortfolio(holdings, shares, 200, []string{"2025-03-31", "2025-06-30"}, []string{"2025-06-30"}, usdRate, priceOn)
+	assert.NoError(t, err)
+	assert.Len(t, result.Contributions, 2)
+	assert.InDelta(t, 10.0, shares[0], 1e-9)
+	assert.InDelta(t, 30.0, shares[1], 1e-9)
+	assert.InDelta(t, 100.0, result.TradedUSD, 1e-9)
+}
+
+// Test a rebalanced portfolio against buy-and-hold
+func TestPortfolioRouteWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockCoinGecko(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10000USD/in/AAPL:50,BTC:50:crypto/on/2025-03-31/and-sold-on/2025-07-18?rebalance=quarter")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	aapl, btc := 5000/200.50, 5000/82500.0
+	buyAndHold := aapl*211.18 + btc*118000
+	atRebalance := aapl*205.17 + btc*107000
+	rebalanced := atRebalance/2/205.17*211.18 + atRebalance/2/107000*118000
+
+	assert.InDelta(t, rebalanced, response["finalValueUSD"], 1e-6)
+	assert.InDelta(t, buyAndHold, response["buyAndHold"].(map[string]interface{})["finalValueUSD"], 1e-6)
+	assert.InDelta(t, rebalanced-buyAndHold, response["differenceUSD"], 1e-6)
+	assert.Len(t, response["rebalances"], 1)
+	assert.Greater(t, response["turnoverPercent"], 0.0)
+
+	// Without rebalancing the portfolio is buy-and-hold
+	w = makeTestRequest(router, "GET", "/10000USD/in/AAPL:50,BTC:50:crypto/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = map[string]interface{}{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.InDelta(t, buyAndHold, response["finalValueUSD"], 1e-6)
+	assert.Equal(t, 0.0, response["differenceUSD"])
+
+	w = makeTestRequest(router, "GET", "/10/in/AAPL:50,BTC:50:crypto/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = makeTestRequest(router, "GET", "/10000USD/in/AAPL:50,BTC:50:crypto/on/2025-03-31/and-sold-on/2025-07-18?rebalance=daily")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Contributions of $1000 a quarter, split 50/50, without rebalancing
+	w = makeTestRequest(router, "GET", "/1000USD/in/AAPL:50,BTC:50:crypto/on/2025-03-31/and-sold-on/2025-07-18?every=quarter")
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = map[string]interface{}{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	contributed := (500/200.50+500/205.17)*211.18 + (500/82500.0+500/107000.0)*118000
+	assert.InDelta(t, contributed, response["finalValueUSD"], 1e-6)
+	assert.Equal(t, 2000.0, response["invested"])
+	assert.Len(t, response["contributions"], 2)
+}
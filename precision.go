@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Currencies whose minor unit isn't cents (ISO 4217)
+var currencyMinorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Decimal places used when rounding share counts and other asset quantities
+const quantityDecimals = 8
+
+// Helper function to get the number of decimals an amount of a currency is
+// rounded to: its minor unit for fiat, its small unit for crypto
+func currencyDecimals(code string) int {
+	if decimals, ok := currencyMinorUnits[code]; ok {
+		return decimals
+	}
+	if isCryptoCurrency(code) {
+		if unit, ok := cryptoSmallUnits[code]; ok {
+			return unit.Decimals
+		}
+		return defaultCryptoDecimals
+	}
+	return 2
+}
+
+// Response fields holding money in the purchase currency, the report currency,
+// GBP or USD (besides fields ending in "USD"), and asset quantities. Nested fields are
+// matched as "parent.field". Prices, FX rates and ratios are never rounded.
+var (
+	originalCurrencyMoneyFields = []string{"value", "finalValueInOriginalCurrency", "difference", "foregoneGain", "sold.finalValue", "held.finalValue", "invested", "purchases.amount", "contributions.amount", "fees.buy", "fees.sell", "fees.total", "taxes.taxableGain", "taxes.capitalGains", "taxes.dividends", "taxes.total", "lots.buyFee", "lots.gain", "usTax.tax", "lots.cost", "lots.proceeds", "shortTerm.gain", "shortTerm.tax", "longTerm.gain", "longTerm.tax"}
+	reportCurrencyMoneyFields   = []string{"finalValueInReportCurrency"}
+	gbpMoneyFields              = []string{"ukTax.proceeds", "ukTax.allowableCost", "ukTax.gain", "ukTax.allowance", "ukTax.taxableGain", "ukTax.tax", "pool.cost"}
+	usdMoneyFields              = []string{"finalValue", "capitalGain", "couponIncome", "dividendIncome", "interestEarned", "incomeReceived", "dividendCash", "cashKept", "dividends.cashKept", "faceValue", "cash", "cashReceived", "amount", "corporateActions.value", "premiumPaid", "payoff", "profit"}
+	quantityFields              = []string{"quantity", "shares", "initialShares", "reinvestedShares", "totalShares", "sharesBought", "sharesHeld", "sharesReceived", "contracts"}
+)
+
+// Helper function to check how closely a field matches a list: 2 for an exact
+// "parent.field" entry, 1 for a plain field name, 0 for no match
+func fieldMatch(fields []string, parent, field string) int {
+	match := 0
+	for _, f := range fields {
+		if f == parent+"."+field {
+			return 2
+		}
+		if f == field {
+			match = 1
+		}
+	}
+	return match
+}
+
+// How to round a response's numbers: decimals for each kind of field, or none
+// at all with ?precision=full
+type rounding struct {
+	Full             bool
+	Precision        int  // decimals for money and percentages when Override is set
+	Override         bool // ?precision=N given
+	OriginalDecimals int
+	ReportDecimals   int
+}
+
+// Helper function to read ?precision= (0-12 decimals, or "full" for unrounded values)
+func precisionParam(c *gin.Context) (rounding, error) {
+	precision := c.Query("precision")
+	switch precision {
+	case "":
+		return rounding{}, nil
+	case "full":
+		return rounding{Full: true}, nil
+	}
+	decimals, err := strconv.Atoi(precision)
+	if err != nil || decimals < 0 || decimals > 12 {
+		return rounding{}, fmt.Errorf("Invalid precision parameter: must be 0-12 or full")
+	}
+	return rounding{Precision: decimals, Override: true}, nil
+}
+
+// Kinds of response fields, by what they hold
+type fieldKind int
+
+const (
+	otherField fieldKind = iota
+	quantityField
+	originalMoneyField
+	reportMoneyField
+	gbpMoneyField
+	usdMoneyField
+	percentField
+)
+
+// Helper function to tell what a response field holds. A "parent.field" entry
+// wins over a plain field name in another list.
+func classifyField(parent, field string) fieldKind {
+	best, kind := 0, otherField
+	for _, class := range []struct {
+		fields []string
+		kind   fieldKind
+	}{
+		{quantityFields, quantityField},
+		{originalCurrencyMoneyFields, originalMoneyField},
+		{reportCurrencyMoneyFields, reportMoneyField},
+		{gbpMoneyFields, gbpMoneyField},
+		{usdMoneyFields, usdMoneyField},
+	} {
+		if match := fieldMatch(class.fields, parent, field); match > best {
+			best, kind = match, class.kind
+		}
+	}
+	if kind != otherField {
+		return kind
+	}
+
+	switch {
+	case strings.HasSuffix(field, "USD"):
+		return usdMoneyField
+	case strings.HasSuffix(field, "Percent"):
+		return percentField
+	}
+	return otherField
+}
+
+// Helper function to pick the decimals for a field, or false to leave it as is
+func (r rounding) decimals(parent, field string) (int, bool) {
+	money := func(decimals int) (int, bool) {
+		if r.Override {
+			return r.Precision, true
+		}
+		return decimals, true
+	}
+
+	switch classifyField(parent, field) {
+	case quantityField:
+		return quantityDecimals, true
+	case originalMoneyField:
+		return money(r.OriginalDecimals)
+	case reportMoneyField:
+		return money(r.ReportDecimals)
+	case gbpMoneyField, usdMoneyField, percentField:
+		return money(2)
+	}
+	return 0, false
+}
+
+// Walk a decoded JSON value, rounding the numbers of money, percentage and
+// quantity fields. Array elements are rounded as fields of the array's key.
+func (r rounding) apply(value interface{}, parent, field string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = r.apply(child, field, key)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = r.apply(child, parent, field)
+		}
+	case json.Number:
+		decimals, ok := r.decimals(parent, field)
+		if !ok {
+			return v
+		}
+		number, err := v.Float64()
+		if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return v
+		}
+		return json.Number(strconv.FormatFloat(number, 'f', decimals, 64))
+	}
+	return value
+}
+
+// A response writer holding the body back so it can be rounded before sending
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Middleware rounding successful JSON responses: money to its currency's minor
+// unit (0 decimals for JPY, 2 for USD), percentages to 2 decimals and quantities
+// to 8, or every money and percentage field to ?precision=N decimals
+func withRounding() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r, err := precisionParam(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		if r.Full || c.Writer.Status() != http.StatusOK || !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			c.Writer.Write(body)
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var response map[string]interface{}
+		if err := decoder.Decode(&response); err != nil {
+			c.Writer.Write(body)
+			return
+		}
+
+		currency, _ := response["currency"].(string)
+		reportCurrency, _ := response["reportCurrency"].(string)
+		r.OriginalDecimals, r.ReportDecimals = currencyDecimals(currency), currencyDecimals(reportCurrency)
+		r.apply(response, "", "")
+
+		rounded, err := json.Marshal(response)
+		if err != nil {
+			c.Writer.Write(body)
+			return
+		}
+		c.Writer.Write(rounded)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test setup with rounding applied to the buy/sell routes
+func setupRoundingRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(withRounding())
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+	r.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate/with-drip", handleAmountBuySellDrip)
+	r.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate/sold-too-early", handleSoldTooEarly)
+	return r
+}
+
+// Test decimals per currency
+func TestCurrencyDecimals(t *testing.T) {
+	assert.Equal(t, 2, currencyDecimals("USD"))
+	assert.Equal(t, 0, currencyDecimals("JPY"))
+	assert.Equal(t, 3, currencyDecimals("KWD"))
+	assert.Equal(t, 8, currencyDecimals("BTC"))
+	assert.Equal(t, 9, currencyDecimals("ETH"))
+	assert.Equal(t, 2, currencyDecimals(""))
+}
+
+// Test default rounding, ?precision= and ?precision=full
+func TestRoundingWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	router := setupRoundingRouter()
+
+	shares := 100000 * 0.0067 / 200.50
+	finalValueUSD := shares * 211.18
+	finalValueJPY := finalValueUSD / 0.0068
+
+	// Decode numbers as written to check their decimals
+	get := func(path string) map[string]interface{} {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusOK, w.Code)
+		decoder := json.NewDecoder(w.Body)
+		decoder.UseNumber()
+		var response map[string]interface{}
+		assert.NoError(t, decoder.Decode(&response))
+		return response
+	}
+
+	// Yen have no decimals, USD two, shares eight; prices and FX rates are untouched
+	response := get("/100000JPY/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, json.Number(strconv.FormatFloat(finalValueJPY, 'f', 0, 64)), response["finalValueInOriginalCurrency"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(finalValueUSD, 'f', 2, 64)), response["finalValueUSD"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(shares, 'f', 8, 64)), response["shares"])
+	assert.Equal(t, json.Number("100000"), response["value"])
+	assert.Equal(t, json.Number("200.5"), response["buyPrice"])
+	assert.Equal(t, json.Number("0.0067"), response["fxRateBuy"])
+	assert.Equal(t, json.Number("0.00"), response["corporateActions"].(map[string]interface{})["value"])
+
+	response = get("/100000JPY/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?precision=1")
+	assert.Equal(t, json.Number(strconv.FormatFloat(finalValueJPY, 'f', 1, 64)), response["finalValueInOriginalCurrency"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(finalValueUSD, 'f', 1, 64)), response["finalValueUSD"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(shares, 'f', 8, 64)), response["shares"])
+
+	response = get("/100000JPY/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?precision=full")
+	value, _ := response["finalValueInOriginalCurrency"].(json.Number).Float64()
+	assert.InDelta(t, finalValueJPY, value, 1e-9)
+
+	// Percentages are rounded to two decimals
+	response = get("/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.Equal(t, json.Number(strconv.FormatFloat((211.18/200.50-1)*100, 'f', 2, 64)), response["returns"].(map[string]interface{})["priceReturnPercent"])
+
+	// Dividend cash a partial DRIP kept is USD
+	response = get("/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?reinvest=50")
+	assert.Equal(t, json.Number("1.30"), response["cashKept"])
+	assert.Equal(t, json.Number("1.30"), response["dividends"].([]interface{})[0].(map[string]interface{})["cashKept"])
+
+	// Selling too early is measured in the purchase currency
+	heldUntil := latestCloseDate("AAPL", "stock", time.Now())
+	mockStockData[heldUntil] = map[string]string{"4. close": "250.00"}
+	mockUSDPerUnit[heldUntil] = mockUSDPerUnit["2025-07-18"]
+	t.Cleanup(func() {
+		delete(mockStockData, heldUntil)
+		delete(mockUSDPerUnit, heldUntil)
+	})
+	response = get("/100000JPY/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/sold-too-early")
+	heldValueJPY := shares * 250 / 0.0068
+	assert.Equal(t, json.Number(strconv.FormatFloat(heldValueJPY-finalValueJPY, 'f', 0, 64)), response["foregoneGain"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(finalValueJPY, 'f', 0, 64)), response["sold"].(map[string]interface{})["finalValue"])
+	assert.Equal(t, json.Number(strconv.FormatFloat(heldValueJPY, 'f', 0, 64)), response["held"].(map[string]interface{})["finalValue"])
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?precision=-1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "precision")
+}
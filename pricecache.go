@@ -0,0 +1,181 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// What a cached price is for
+type priceKey struct {
+	AssetType string `json:"type"`
+	Ticker    string `json:"ticker"`
+	Date      string `json:"date"`
+	At        string `json:"priceAt"`
+	Adjusted  bool   `json:"adjusted"`
+	Source    string `json:"source,omitempty"` // the stock or crypto provider a request chose
+}
+
+// Helper function to make the cache key of a price. Prices from a provider a
+// request chose are kept apart, so comparing providers compares their data.
+func newPriceKey(ticker, date, assetType string, opts priceOptions) priceKey {
+	key := priceKey{AssetType: assetType, Ticker: strings.ToUpper(ticker), Date: date, At: opts.At, Adjusted: opts.Adjusted}
+	if assetType == "stock" || assetType == "crypto" {
+		key.Source = opts.Source
+	}
+	return key
+}
+
+// Helper function to check whether a price can be cached: daily prices for days
+// before today (UTC), which providers won't revise unless they were wrong
+func (k priceKey) cacheable() bool {
+	return !hasTimeOfDay(k.Date) && k.Date < time.Now().UTC().Format("2006-01-02")
+}
+
+// A cached price and when it was fetched. Warmed prices were fetched ahead of
+// time for WARM_TICKERS and don't count towards PRICE_CACHE_SIZE.
+type cachedPrice struct {
+	priceKey
+	Price     float64   `json:"price"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Warmed    bool      `json:"warmed"`
+}
+
+// Past daily prices fetched from providers, so repeated backtests don't spend the
+// providers' quotas. Operators can inspect and invalidate it on the admin listener.
+type priceCache struct {
+	mu      sync.Mutex
+	entries map[priceKey]cachedPrice
+	fetched int // entries that weren't warmed
+	hits    int
+	misses  int
+}
+
+var prices = &priceCache{entries: map[priceKey]cachedPrice{}}
+
+// Look up a cached price
+func (p *priceCache) get(key priceKey) (float64, bool) {
+	entry, ok := p.entry(key)
+	return entry.Price, ok
+}
+
+// Look up a cached price with when it was fetched
+func (p *priceCache) entry(key priceKey) (cachedPrice, bool) {
+	if !key.cacheable() {
+		return cachedPrice{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[key]
+	if ok {
+		p.hits++
+	} else {
+		p.misses++
+	}
+	return entry, ok
+}
+
+// Helper function to drop a cached price. Callers hold p.mu.
+func (p *priceCache) remove(key priceKey) {
+	if entry, ok := p.entries[key]; ok {
+		delete(p.entries, key)
+		if !entry.Warmed {
+			p.fetched--
+		}
+	}
+}
+
+// Cache a price, making room by dropping the oldest fetched (not warmed) ones
+func (p *priceCache) put(key priceKey, price float64) {
+	cfg := settings()
+	if !key.cacheable() || cfg.PriceCacheSize == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.entries[key]; ok {
+		return
+	}
+	for p.fetched >= cfg.PriceCacheSize {
+		var oldest priceKey
+		var oldestAt time.Time
+		for k, entry := range p.entries {
+			if !entry.Warmed && (oldestAt.IsZero() || entry.FetchedAt.Before(oldestAt)) {
+				oldest, oldestAt = k, entry.FetchedAt
+			}
+		}
+		p.remove(oldest)
+	}
+	p.entries[key] = cachedPrice{priceKey: key, Price: price, FetchedAt: time.Now().UTC()}
+	p.fetched++
+}
+
+// Cache a price fetched ahead of time, which stays until it's invalidated or warmed again
+func (p *priceCache) warm(key priceKey, price float64) {
+	if !key.cacheable() || settings().PriceCacheSize == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.remove(key)
+	p.entries[key] = cachedPrice{priceKey: key, Price: price, FetchedAt: time.Now().UTC(), Warmed: true}
+}
+
+// Which cached prices to inspect or invalidate. Empty fields match everything;
+// From and To are inclusive dates.
+type priceFilter struct {
+	Ticker    string
+	AssetType string
+	From      string
+	To        string
+}
+
+// Helper function to check whether a cached price matches a filter
+func (f priceFilter) matches(key priceKey) bool {
+	return (f.Ticker == "" || strings.EqualFold(f.Ticker, key.Ticker)) &&
+		(f.AssetType == "" || f.AssetType == key.AssetType) &&
+		(f.From == "" || key.Date >= f.From) &&
+		(f.To == "" || key.Date <= f.To)
+}
+
+// List the cached prices matching a filter, by ticker and date
+func (p *priceCache) list(filter priceFilter) []cachedPrice {
+	p.mu.Lock()
+	matching := []cachedPrice{}
+	for key, entry := range p.entries {
+		if filter.matches(key) {
+			matching = append(matching, entry)
+		}
+	}
+	p.mu.Unlock()
+
+	sort.Slice(matching, func(i, j int) bool {
+		if matching[i].Ticker != matching[j].Ticker {
+			return matching[i].Ticker < matching[j].Ticker
+		}
+		return matching[i].Date < matching[j].Date
+	})
+	return matching
+}
+
+// Drop the cached prices matching a filter, returning how many there were
+func (p *priceCache) invalidate(filter priceFilter) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	removed := 0
+	for key := range p.entries {
+		if filter.matches(key) {
+			p.remove(key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Report the cache's size and hit rate
+func (p *priceCache) stats() (size, hits, misses int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries), p.hits, p.misses
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test only past daily prices are cached, and the oldest make room for new ones
+func TestPriceCache(t *testing.T) {
+	setSettings(t, func(cfg *Config) { cfg.PriceCacheSize = 2 })
+	cache := &priceCache{entries: map[priceKey]cachedPrice{}}
+	atClose := priceOptions{At: "close"}
+
+	cache.put(newPriceKey("aapl", "2025-07-17", "stock", atClose), 210.0)
+	price, ok := cache.get(newPriceKey("AAPL", "2025-07-17", "stock", atClose))
+	assert.True(t, ok)
+	assert.Equal(t, 210.0, price)
+	_, ok = cache.get(newPriceKey("AAPL", "2025-07-17", "stock", priceOptions{At: "open"}))
+	assert.False(t, ok)
+
+	today := time.Now().UTC().Format("2006-01-02")
+	cache.put(newPriceKey("AAPL", today, "stock", atClose), 1.0)
+	cache.put(newPriceKey("AAPL", "2025-07-17T10:30", "stock", atClose), 1.0)
+	assert.Len(t, cache.entries, 1)
+
+	cache.put(newPriceKey("AAPL", "2025-07-18", "stock", atClose), 211.18)
+	cache.put(newPriceKey("MSFT", "2025-07-18", "stock", atClose), 510.0)
+	assert.Len(t, cache.entries, 2)
+	_, ok = cache.get(newPriceKey("AAPL", "2025-07-17", "stock", atClose))
+	assert.False(t, ok)
+
+	size, hits, misses := cache.stats()
+	assert.Equal(t, 2, size)
+	assert.Equal(t, 1, hits)
+	assert.Equal(t, 2, misses)
+}
+
+// Test cached prices are listed and invalidated by ticker, type and date range
+func TestPriceCacheFilter(t *testing.T) {
+	cache := &priceCache{entries: map[priceKey]cachedPrice{}}
+	for _, key := range []priceKey{
+		newPriceKey("AAPL", "2025-07-16", "stock", priceOptions{At: "close"}),
+		newPriceKey("AAPL", "2025-07-17", "stock", priceOptions{At: "close"}),
+		newPriceKey("AAPL", "2025-07-18", "stock", priceOptions{At: "close", Adjusted: true}),
+		newPriceKey("BTC", "2025-07-17", "crypto", priceOptions{At: "close"}),
+	} {
+		cache.put(key, 1)
+	}
+
+	assert.Len(t, cache.list(priceFilter{Ticker: "aapl"}), 3)
+	assert.Len(t, cache.list(priceFilter{AssetType: "crypto"}), 1)
+	listed := cache.list(priceFilter{From: "2025-07-17"})
+	assert.Len(t, listed, 3)
+	assert.Equal(t, "AAPL", listed[0].Ticker)
+	assert.Equal(t, "2025-07-17", listed[0].Date)
+
+	assert.Equal(t, 2, cache.invalidate(priceFilter{Ticker: "AAPL", From: "2025-07-17", To: "2025-07-18"}))
+	assert.Len(t, cache.list(priceFilter{}), 2)
+}
+
+// Test fetchPrice answers past days from the cache until they're invalidated
+func TestFetchPriceCached(t *testing.T) {
+	setupMockAlphaVantage(t)
+	price, err := fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = down.URL })
+
+	price, err = fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+
+	prices.invalidate(priceFilter{Ticker: "AAPL"})
+	_, err = fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most discrepancies kept for /admin/price-checks
+const maxRecordedDiscrepancies = 100
+
+// How a price compared with the same price from another stock provider
+type priceCheck struct {
+	Provider    string  `json:"provider"`
+	Price       float64 `json:"price,omitempty"`
+	Difference  float64 `json:"differencePercent"`
+	Discrepancy bool    `json:"discrepancy"`
+	Error       string  `json:"error,omitempty"` // why no other provider could be compared
+}
+
+// A flagged price, kept for operators
+type priceDiscrepancy struct {
+	Ticker    string     `json:"ticker"`
+	Date      string     `json:"date"`
+	Provider  string     `json:"provider"`
+	Price     float64    `json:"price"`
+	Check     priceCheck `json:"check"`
+	CheckedAt time.Time  `json:"checkedAt"`
+}
+
+// Counts of the prices checked and flagged since startup, and the latest flagged
+type priceCheckLog struct {
+	mu            sync.Mutex
+	checked       int
+	flagged       int
+	discrepancies []priceDiscrepancy
+}
+
+var priceChecks = &priceCheckLog{}
+
+// Record a check, logging and keeping it when it's a discrepancy
+func (l *priceCheckLog) record(ticker, date, provider string, price float64, check priceCheck) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checked++
+	if !check.Discrepancy {
+		return
+	}
+	l.flagged++
+	log.Printf("Price check: %s on %s is %.4f from %s but %.4f from %s (%.2f%% apart)",
+		ticker, date, price, provider, check.Price, check.Provider, check.Difference)
+	l.discrepancies = append(l.discrepancies, priceDiscrepancy{
+		Ticker: ticker, Date: date, Provider: provider, Price: price, Check: check, CheckedAt: time.Now().UTC(),
+	})
+	if len(l.discrepancies) > maxRecordedDiscrepancies {
+		l.discrepancies = l.discrepancies[1:]
+	}
+}
+
+// Check a stock's daily price against the first other configured provider that
+// has it. Prices from a provider are cached apart from the chain's, as for
+// ?source=, so checking a backtest again doesn't spend more quota.
+func checkStockPrice(ticker, date, provider string, price float64, opts priceOptions) priceCheck {
+	opts.Notes, opts.Fill = nil, nil
+	var failures []string
+	for _, other := range stockProviders("") {
+		if other.Name() == provider {
+			continue
+		}
+		opts.Source = other.Name()
+		key := newPriceKey(ticker, date, "stock", opts)
+		otherPrice, ok := prices.get(key)
+		if !ok {
+			var err error
+			if otherPrice, err = other.DailyPrice(ticker, date, opts); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", other.Name(), err))
+				continue
+			}
+			prices.put(key, otherPrice)
+		}
+
+		check := priceCheck{Provider: other.Name(), Price: otherPrice}
+		if price != 0 {
+			check.Difference = math.Round(10000*math.Abs(otherPrice-price)/price) / 100
+		}
+		check.Discrepancy = check.Difference > settings().PriceCheckTolerance
+		priceChecks.record(ticker, date, provider, price, check)
+		return check
+	}
+
+	check := priceCheck{Error: "No other stock provider is configured"}
+	if len(failures) > 0 {
+		check.Error = fmt.Sprintf("No other stock provider had the price (%s)", strings.Join(failures, "; "))
+	}
+	return check
+}
+
+// Helper function to check a price a request fetched, when it asked for checks.
+// Only stocks' daily prices have several providers to compare.
+func (opts priceOptions) check(ticker, date, assetType, provider string, price float64) *priceCheck {
+	if !opts.Verify || assetType != "stock" || hasTimeOfDay(date) {
+		return nil
+	}
+	check := checkStockPrice(ticker, date, provider, price, opts)
+	return &check
+}
+
+// Report how many prices were checked and flagged, and the latest discrepancies
+func handlePriceChecks(c *gin.Context) {
+	tolerance := settings().PriceCheckTolerance
+	priceChecks.mu.Lock()
+	defer priceChecks.mu.Unlock()
+	c.JSON(http.StatusOK, gin.H{
+		"checked":       priceChecks.checked,
+		"flagged":       priceChecks.flagged,
+		"tolerance":     tolerance,
+		"discrepancies": append([]priceDiscrepancy{}, priceChecks.discrepancies...),
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to start price checks afresh with a tolerance, for one test
+func setupPriceChecks(t *testing.T, tolerance float64) {
+	original := priceChecks
+	priceChecks = &priceCheckLog{}
+	t.Cleanup(func() { priceChecks = original })
+	setSettings(t, func(cfg *Config) { cfg.PriceCheckTolerance = tolerance })
+}
+
+// Test ?verify=true checks each stock price against the next provider and flags
+// prices further apart than the tolerance
+func TestPriceCheck(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	setupMockTiingo(t)
+	setStockProviderOrder(t, "alphaVantage,tiingo")
+	setupPriceChecks(t, 0.1)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	var response struct {
+		Discrepancy bool         `json:"discrepancy"`
+		DataNotes   []dataSource `json:"dataNotes"`
+	}
+	w := makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?verify=true")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(t, response.Discrepancy)
+	assert.Len(t, response.DataNotes, 2)
+	for _, source := range response.DataNotes {
+		assert.Equal(t, "tiingo", source.Check.Provider)
+		assert.Zero(t, source.Check.Difference)
+		assert.False(t, source.Check.Discrepancy)
+	}
+
+	// Alpha Vantage's adjusted close on 2025-03-31 is 199.50; Tiingo's 198.495
+	w = makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?verify=true&adjusted=true")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	response.DataNotes = nil
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.True(t, response.Discrepancy)
+	assert.Equal(t, "2025-03-31", response.DataNotes[0].Date)
+	assert.Equal(t, &priceCheck{Provider: "tiingo", Price: 200.50 * 0.99, Difference: 0.5, Discrepancy: true}, response.DataNotes[0].Check)
+
+	w = makeAdminRequest("", "/admin/price-checks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report struct {
+		Checked       int                `json:"checked"`
+		Flagged       int                `json:"flagged"`
+		Discrepancies []priceDiscrepancy `json:"discrepancies"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 4, report.Checked)
+	assert.Equal(t, 2, report.Flagged)
+	assert.Equal(t, "alphaVantage", report.Discrepancies[0].Provider)
+
+	// Without ?verify=true nothing is checked
+	w = makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?adjusted=true")
+	assert.NotContains(t, w.Body.String(), "check")
+	w = makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?verify=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Test a check with no other provider to compare says so rather than flagging
+func TestPriceCheckWithoutOtherProvider(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupPriceChecks(t, 1)
+
+	check := checkStockPrice("AAPL", "2025-07-18", "alphaVantage", 211.18, priceOptions{At: "close"})
+	assert.Equal(t, priceCheck{Error: "No other stock provider is configured"}, check)
+
+	setStockProviderOrder(t, "alphaVantage,stooq")
+	check = checkStockPrice("AAPL", "2025-07-18", "alphaVantage", 211.18, priceOptions{At: "close", Adjusted: true})
+	assert.Equal(t, "No other stock provider had the price (stooq: Stooq has no split- and dividend-adjusted prices)", check.Error)
+	assert.False(t, check.Discrepancy)
+}
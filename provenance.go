@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Where a price or FX rate a result was worked out from came from
+type dataSource struct {
+	Kind        string      `json:"kind"`                 // "price", "fxRate" or "dividends"
+	Symbol      string      `json:"symbol"`               // ticker, or currency pair as "EUR/USD"
+	Date        string      `json:"date"`                 // the date asked for, or the from/to dates of dividends
+	ActualDate  string      `json:"actualDate,omitempty"` // the date (or time) the data is for, when it isn't Date
+	Provider    string      `json:"provider"`
+	Fallback    bool        `json:"fallback,omitempty"` // answered by a provider tried after another
+	Cached      bool        `json:"cached"`
+	Stale       bool        `json:"stale,omitempty"` // served from the cache because every provider failed
+	RetrievedAt time.Time   `json:"retrievedAt"`
+	Check       *priceCheck `json:"check,omitempty"`       // the same price from another provider, with ?verify=true
+	Unavailable string      `json:"unavailable,omitempty"` // why the result goes without this data
+}
+
+// Helper function to check whether a source is worth pointing out: data for
+// another date, from a fallback provider, served from a cache, or checked
+// against another provider, or missing
+func (s dataSource) notable() bool {
+	return s.ActualDate != "" || s.Fallback || s.Cached || s.Stale || s.Check != nil || s.Unavailable != ""
+}
+
+// The data sources one request used, so results can be audited. A nil
+// *dataNotes records nothing.
+type dataNotes struct {
+	mu      sync.Mutex
+	sources []dataSource
+}
+
+// Record a data source, replacing an earlier one for the same data
+func (n *dataNotes) add(source dataSource) {
+	if n == nil || (source.Provider == "" && source.Unavailable == "") {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i, s := range n.sources {
+		if s.Kind == source.Kind && s.Symbol == source.Symbol && s.Date == source.Date {
+			n.sources[i] = source
+			return
+		}
+	}
+	n.sources = append(n.sources, source)
+}
+
+// Record data a result had to go without, and why
+func (n *dataNotes) unavailable(kind, symbol, date string, err error) {
+	n.add(dataSource{Kind: kind, Symbol: symbol, Date: date, RetrievedAt: time.Now().UTC(), Unavailable: err.Error()})
+}
+
+// Check whether any source was served stale, came from a fallback provider or
+// was missing, so a later request may get better data
+func (n *dataNotes) provisional() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, s := range n.sources {
+		if s.Stale || s.Fallback || s.Unavailable != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// List the sources by kind, symbol and date, if any is notable
+func (n *dataNotes) report() []dataSource {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	notable := false
+	for _, s := range n.sources {
+		notable = notable || s.notable()
+	}
+	if !notable {
+		return nil
+	}
+
+	report := append([]dataSource{}, n.sources...)
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Kind != report[j].Kind {
+			return report[i].Kind > report[j].Kind // prices first
+		}
+		if report[i].Symbol != report[j].Symbol {
+			return report[i].Symbol < report[j].Symbol
+		}
+		return report[i].Date < report[j].Date
+	})
+	return report
+}
+
+// Fetch a historical FX rate, recording where it came from
+func (n *dataNotes) fxRate(fromCurrency, toCurrency, date string) (float64, error) {
+	rate, source, err := getHistoricalFXRateSource(fromCurrency, toCurrency, date)
+	if err != nil {
+		return 0, err
+	}
+	n.add(source)
+	return rate, nil
+}
+
+// Helper function to get the data notes of a request, starting them on first use
+func requestNotes(c *gin.Context) *dataNotes {
+	if notes, ok := c.Get("dataNotes"); ok {
+		return notes.(*dataNotes)
+	}
+	notes := &dataNotes{}
+	c.Set("dataNotes", notes)
+	return notes
+}
+
+// Helper function to name the provider prices of a type come from: for stocks and
+// crypto, the first one tried, or the one the request chose
+func priceProvider(assetType, source string) string {
+	switch assetType {
+	case "crypto":
+		if source != "" {
+			return source
+		}
+		return "coinGecko"
+	case "index":
+		return "stooq"
+	}
+	if providers := stockProviders(source); len(providers) > 0 {
+		return providers[0].Name()
+	}
+	return "alphaVantage"
+}
+
+// Middleware adding the data sources a result used as "dataNotes" when any of
+// them is for another date than asked, came from a fallback provider or was
+// served from a cache, "stale": true when any was served stale during an outage,
+// and "discrepancy": true when a checked price disagreed with another provider
+func withDataNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		writer := &bufferedWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		body := writer.body.Bytes()
+		notes, ok := c.Get("dataNotes")
+		if !ok || c.Writer.Status() != http.StatusOK || !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") {
+			c.Writer.Write(body)
+			return
+		}
+		sources := notes.(*dataNotes).report()
+		if sources == nil {
+			c.Writer.Write(body)
+			return
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var response map[string]interface{}
+		if err := decoder.Decode(&response); err != nil {
+			c.Writer.Write(body)
+			return
+		}
+		response["dataNotes"] = sources
+		for _, source := range sources {
+			if source.Stale {
+				response["stale"] = true
+			}
+			if source.Check != nil && source.Check.Discrepancy {
+				response["discrepancy"] = true
+			}
+		}
+		noted, err := json.Marshal(response)
+		if err != nil {
+			c.Writer.Write(body)
+			return
+		}
+		c.Writer.Write(noted)
+	}
+}
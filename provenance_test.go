@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test sources are only reported when one of them is worth pointing out
+func TestDataNotesReport(t *testing.T) {
+	notes := &dataNotes{}
+	notes.add(dataSource{Kind: "fxRate", Symbol: "EUR/USD", Date: "2025-07-18", Provider: "frankfurter"})
+	notes.add(dataSource{Kind: "price", Symbol: "AAPL", Date: "2025-07-18", Provider: "alphaVantage"})
+	notes.add(dataSource{Kind: "fxRate", Symbol: "USD/USD", Date: "2025-07-18"}) // needed no provider
+	assert.Nil(t, notes.report())
+
+	notes.add(dataSource{Kind: "price", Symbol: "AAPL", Date: "2025-07-18", Provider: "alphaVantage", Cached: true})
+	report := notes.report()
+	assert.Len(t, report, 2)
+	assert.Equal(t, "price", report[0].Kind)
+	assert.True(t, report[0].Cached)
+	assert.Equal(t, "fxRate", report[1].Kind)
+
+	var none *dataNotes
+	none.add(dataSource{Kind: "price", Symbol: "AAPL", Provider: "alphaVantage"})
+}
+
+// Test results note cached prices and intraday prices from an earlier minute
+func TestDataNotes(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate", handleAmountBuy)
+	router.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	path := "/1000EUR/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18?type=stock"
+	w := makeTestRequest(router, "GET", path)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "dataNotes")
+
+	before := time.Now().UTC()
+	w = makeTestRequest(router, "GET", path)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.DataNotes, 4)
+	for _, source := range body.DataNotes {
+		if source.Kind == "price" {
+			assert.Equal(t, "alphaVantage", source.Provider)
+		} else {
+			assert.Equal(t, "frankfurter", source.Provider)
+		}
+		assert.True(t, source.Cached)
+		assert.True(t, source.RetrievedAt.Before(before))
+	}
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-07-18T10:31?type=stock")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, []dataSource{{
+		Kind: "price", Symbol: "AAPL", Date: "2025-07-18T10:31", ActualDate: "2025-07-18T10:30",
+		Provider: "alphaVantage", RetrievedAt: body.DataNotes[0].RetrievedAt,
+	}}, body.DataNotes)
+}
+
+// Test prices moved on from a weekend are noted with the day they're from
+func TestDataNotesMovedDate(t *testing.T) {
+	setupMockAlphaVantage(t)
+	resetFirstTradedDates(t)
+	notes := &dataNotes{}
+
+	price, err := fetchPriceOnOrAfter("AAPL", "2025-06-28", "stock", priceOptions{At: "close", Notes: notes})
+	assert.NoError(t, err)
+	assert.Positive(t, price)
+	assert.Len(t, notes.sources, 1)
+	assert.Equal(t, "2025-06-28", notes.sources[0].Date)
+	assert.Equal(t, "2025-06-30", notes.sources[0].ActualDate)
+}
+
+// Test FX rates from a fallback provider are noted as such
+func TestDataNotesFallbackFX(t *testing.T) {
+	setupMockFrankfurter(t)
+	setupMockCoinGecko(t)
+	setupMockFallbackFX(t)
+	notes := &dataNotes{}
+
+	_, err := notes.fxRate("NGN", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	_, err = notes.fxRate("EUR", "USD", "2025-07-18")
+	assert.NoError(t, err)
+	report := notes.report()
+	assert.Len(t, report, 2)
+	assert.Equal(t, "EUR/USD", report[0].Symbol)
+	assert.Equal(t, "frankfurter", report[0].Provider)
+	assert.False(t, report[0].Fallback)
+	assert.Equal(t, "NGN/USD", report[1].Symbol)
+	assert.Equal(t, "exchangerate.host", report[1].Provider)
+	assert.True(t, report[1].Fallback)
+}
+
+// Test today's price is served stale from the latest data cache when every
+// provider fails, and results say so
+func TestDataNotesStale(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupLatestCache(t)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(down.Close)
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = down.URL })
+
+	today := time.Now().UTC().Format("2006-01-02")
+	opts := priceOptions{At: "close"}
+	key := latestPriceKey(newPriceKey("AAPL", today, "stock", opts))
+	retrievedAt := time.Now().UTC().Add(-time.Hour)
+	latest.entries[key] = &latestEntry{Value: 212.48, Date: today, Day: today, FetchedAt: retrievedAt, UsedAt: retrievedAt}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withDataNotes())
+	router.GET("/price", func(c *gin.Context) {
+		opts.Notes = requestNotes(c)
+		price, err := fetchPrice("AAPL", today, "stock", opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"price": price})
+	})
+
+	w := makeTestRequest(router, "GET", "/price")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Price     float64      `json:"price"`
+		Stale     bool         `json:"stale"`
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, 212.48, body.Price)
+	assert.True(t, body.Stale)
+	assert.Len(t, body.DataNotes, 1)
+	assert.True(t, body.DataNotes[0].Stale)
+	assert.True(t, body.DataNotes[0].Cached)
+	assert.WithinDuration(t, retrievedAt, body.DataNotes[0].RetrievedAt, time.Second)
+
+	// Without a cached price, the outage fails the request
+	latest.clear()
+	w = makeTestRequest(router, "GET", "/price")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Returned instead of calling a provider whose circuit breaker is open
+var errCircuitOpen = errors.New("provider is failing; circuit breaker open")
+
+// How requests to one host have been going, for its circuit breaker and status
+type providerHealth struct {
+	consecutiveFailures int
+	openUntil           time.Time
+	probing             bool // a request is testing whether a half-open host recovered
+	lastError           string
+	lastErrorAt         time.Time
+	lastLatency         time.Duration
+	totalLatency        time.Duration
+	answered            int
+}
+
+var (
+	providerHealthMu sync.Mutex
+	providerHealths  = map[string]*providerHealth{}
+)
+
+// Helper function to name a breaker's state: closed (requests go through), open
+// (requests fail fast) or half-open (cooled down, the next request tests the host)
+func (h *providerHealth) state(now time.Time) string {
+	cfg := settings()
+	switch {
+	case cfg.BreakerThreshold <= 0 || h.consecutiveFailures < cfg.BreakerThreshold:
+		return "closed"
+	case now.Before(h.openUntil):
+		return "open"
+	}
+	return "half-open"
+}
+
+// Check a host's breaker before a request, letting one request through once an
+// open breaker has cooled down
+func allowProviderRequest(host string) error {
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+	h := providerHealths[host]
+	if h == nil {
+		return nil
+	}
+	switch h.state(time.Now()) {
+	case "open":
+		return errCircuitOpen
+	case "half-open":
+		if h.probing {
+			return errCircuitOpen
+		}
+		h.probing = true
+	}
+	return nil
+}
+
+// Record how a request to a host went: transport errors, 5xx and 429 answers are
+// failures, and enough of them in a row open the host's breaker
+func recordProviderHealth(host string, latency time.Duration, status int, err error) {
+	cfg := settings()
+	now := time.Now().UTC()
+
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+	h := providerHealths[host]
+	if h == nil {
+		h = &providerHealth{}
+		providerHealths[host] = h
+	}
+	h.probing = false
+	if errors.Is(err, context.Canceled) {
+		return // the caller gave up, which says nothing about the host
+	}
+	if err == nil {
+		h.lastLatency = latency
+		h.totalLatency += latency
+		h.answered++
+	}
+
+	if err == nil && status < 500 && status != http.StatusTooManyRequests {
+		h.consecutiveFailures = 0
+		return
+	}
+	h.consecutiveFailures++
+	h.lastErrorAt = now
+	h.lastError = fmt.Sprintf("HTTP %d", status)
+	if err != nil {
+		h.lastError = err.Error()
+	}
+	if cfg.BreakerThreshold > 0 && h.consecutiveFailures >= cfg.BreakerThreshold {
+		h.openUntil = now.Add(time.Duration(cfg.BreakerCooldown) * time.Second)
+	}
+}
+
+// A provider as users see it: breaker state, last error, latency and quota
+type providerStatus struct {
+	Provider            string     `json:"provider"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	RetryAt             *time.Time `json:"retryAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastErrorAt         *time.Time `json:"lastErrorAt,omitempty"`
+	LastLatencyMs       *int64     `json:"lastLatencyMs,omitempty"`
+	AverageLatencyMs    *int64     `json:"averageLatencyMs,omitempty"`
+	RequestsToday       int        `json:"requestsToday"`
+	DailyLimit          *int       `json:"dailyLimit,omitempty"`
+	Remaining           *int       `json:"remaining,omitempty"`
+}
+
+// Helper function to list the providers in use and their base URLs. Alpha Vantage,
+// Polygon, Tiingo, Twelve Data, FMP, EODHD, Alpaca, FRED, exchangerate.host and
+// CoinCap need an API key; the others are always in use (Coinbase, Binance and
+// CryptoCompare unless their base URLs are blank).
+func configuredProviders() []struct{ Name, BaseURL string } {
+	cfg := settings()
+	providers := []struct{ Name, BaseURL string }{}
+	add := func(name, baseURL string, configured bool) {
+		if configured && baseURL != "" {
+			providers = append(providers, struct{ Name, BaseURL string }{name, baseURL})
+		}
+	}
+	add("alphaVantage", cfg.AlphaVantageBaseURL, cfg.AlphaVantageAPIKey != "")
+	add("polygon", cfg.PolygonBaseURL, cfg.PolygonAPIKey != "")
+	add("tiingo", cfg.TiingoBaseURL, cfg.TiingoAPIKey != "")
+	add("twelveData", cfg.TwelveDataBaseURL, cfg.TwelveDataAPIKey != "")
+	add("fmp", cfg.FMPBaseURL, cfg.FMPAPIKey != "")
+	add("eodhd", cfg.EODHDBaseURL, cfg.EODHDAPIKey != "")
+	add("alpaca", cfg.AlpacaBaseURL, cfg.AlpacaKeyID != "" && cfg.AlpacaSecretKey != "")
+	add("coinGecko", cfg.CoinGeckoBaseURL, true)
+	add("coinbase", cfg.CoinbaseBaseURL, true)
+	add("binance", cfg.BinanceBaseURL, true)
+	add("cryptoCompare", cfg.CryptoCompareBaseURL, true)
+	add("coinCap", cfg.CoinCapBaseURL, cfg.CoinCapAPIKey != "")
+	add("stooq", cfg.StooqBaseURL, true)
+	add("fred", cfg.FREDBaseURL, cfg.FREDAPIKey != "")
+	add("frankfurter", cfg.FrankfurterBaseURL, true)
+	add("exchangeRateHost", cfg.FXFallbackBaseURL, cfg.FXFallbackAPIKey != "")
+	return providers
+}
+
+// Helper function to check a SOURCE_ALLOWLIST names only stock and crypto providers
+func checkSourceAllowlist(list string) error {
+	for _, name := range splitList(list) {
+		if _, ok := stockProviderRegistry[name]; !ok && !isCryptoProviderName(name) {
+			return fmt.Errorf("source_allowlist: unknown provider %q", name)
+		}
+	}
+	return nil
+}
+
+// Helper function to check whether SOURCE_ALLOWLIST lets requests choose a provider
+func sourceAllowed(name string) bool {
+	names := splitList(settings().SourceAllowlist)
+	if len(names) == 0 {
+		return true
+	}
+	for _, allowed := range names {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Helper function to find the provider a request chose with ?source=, by name in
+// any case: a configured stock or crypto provider that SOURCE_ALLOWLIST allows
+func selectableSource(name string) (string, bool) {
+	if stock, ok := configuredStockProvider(name); ok {
+		return stock, sourceAllowed(stock)
+	}
+	for _, provider := range cryptoProviders(false) {
+		if strings.EqualFold(provider.Name(), name) {
+			return provider.Name(), sourceAllowed(provider.Name())
+		}
+	}
+	return "", false
+}
+
+// Helper function to name the providers ?source= can choose, stocks' then crypto's
+func selectableSourceNames() []string {
+	var names []string
+	for _, name := range configuredStockProviderNames() {
+		if sourceAllowed(name) {
+			names = append(names, name)
+		}
+	}
+	for _, provider := range cryptoProviders(false) {
+		if sourceAllowed(provider.Name()) {
+			names = append(names, provider.Name())
+		}
+	}
+	return names
+}
+
+// Helper function to get the host of a base URL
+func hostOf(baseURL string) string {
+	if parsed, err := url.Parse(baseURL); err == nil {
+		return parsed.Host
+	}
+	return ""
+}
+
+// Report each configured provider's status
+func providerStatusReport() []providerStatus {
+	usages := map[string]providerUsage{}
+	for _, usage := range providerUsageReport() {
+		usages[usage.Provider] = usage
+	}
+	now := time.Now().UTC()
+
+	providerHealthMu.Lock()
+	defer providerHealthMu.Unlock()
+	report := []providerStatus{}
+	for _, provider := range configuredProviders() {
+		status := providerStatus{Provider: provider.Name, State: "closed"}
+		if usage, ok := usages[provider.Name]; ok {
+			status.RequestsToday, status.DailyLimit, status.Remaining = usage.Requests, usage.DailyLimit, usage.Remaining
+		}
+		if h := providerHealths[hostOf(provider.BaseURL)]; h != nil {
+			status.State = h.state(now)
+			status.ConsecutiveFailures = h.consecutiveFailures
+			if status.State == "open" {
+				retryAt := h.openUntil
+				status.RetryAt = &retryAt
+			}
+			if h.lastError != "" {
+				lastErrorAt := h.lastErrorAt
+				status.LastError, status.LastErrorAt = h.lastError, &lastErrorAt
+			}
+			if h.answered > 0 {
+				last, average := h.lastLatency.Milliseconds(), (h.totalLatency / time.Duration(h.answered)).Milliseconds()
+				status.LastLatencyMs, status.AverageLatencyMs = &last, &average
+			}
+		}
+		report = append(report, status)
+	}
+	return report
+}
+
+// Show users why data might be degraded: GET /v1/providers/status
+func handleProviderStatus(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"providers": providerStatusReport(), "checkedAt": time.Now().UTC()})
+}
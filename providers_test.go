@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to set the circuit breakers up for a test
+func setupBreakers(t *testing.T, threshold, cooldown int) {
+	setSettings(t, func(cfg *Config) { cfg.BreakerThreshold, cfg.BreakerCooldown = threshold, cooldown })
+}
+
+// Test a failing host's breaker opens, fails fast, and closes after a good probe
+func TestCircuitBreaker(t *testing.T) {
+	setupBreakers(t, 2, 60)
+	healthy := false
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	host := hostOf(server.URL)
+
+	for i := 0; i < 2; i++ {
+		resp, err := outboundClient.Get(server.URL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := outboundClient.Get(server.URL)
+	assert.ErrorIs(t, err, errCircuitOpen)
+	assert.Equal(t, 2, calls)
+
+	// Once cooled down, one request tests the host and closes the breaker
+	providerHealthMu.Lock()
+	providerHealths[host].openUntil = time.Now().Add(-time.Second)
+	assert.Equal(t, "half-open", providerHealths[host].state(time.Now()))
+	providerHealthMu.Unlock()
+	healthy = true
+	resp, err := outboundClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 3, calls)
+
+	providerHealthMu.Lock()
+	assert.Equal(t, "closed", providerHealths[host].state(time.Now()))
+	assert.Zero(t, providerHealths[host].consecutiveFailures)
+	providerHealthMu.Unlock()
+}
+
+// Test a failed probe opens the breaker again, and a threshold of 0 never opens it
+func TestCircuitBreakerProbeFails(t *testing.T) {
+	setupBreakers(t, 1, 60)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	host := hostOf(server.URL)
+
+	resp, err := outboundClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	providerHealthMu.Lock()
+	providerHealths[host].openUntil = time.Now().Add(-time.Second)
+	providerHealthMu.Unlock()
+
+	resp, err = outboundClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	_, err = outboundClient.Get(server.URL)
+	assert.ErrorIs(t, err, errCircuitOpen)
+
+	setSettings(t, func(cfg *Config) { cfg.BreakerThreshold = 0 })
+	resp, err = outboundClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
+
+// Test the status endpoint reports configured providers' breakers, errors,
+// latency and quota
+func TestProviderStatus(t *testing.T) {
+	setupBreakers(t, 1, 60)
+	setupAlphaVantagePacing(t, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	setSettings(t, func(cfg *Config) {
+		cfg.AlphaVantageBaseURL, cfg.AlphaVantageAPIKey, cfg.FREDAPIKey, cfg.ProviderDailyLimits = server.URL, "demo", "", "alphaVantage=25"
+	})
+
+	resp, err := outboundClient.Get(server.URL + "/query")
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/providers/status", handleProviderStatus)
+	w := makeTestRequest(router, "GET", "/v1/providers/status")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var body struct {
+		Providers []providerStatus `json:"providers"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	byProvider := map[string]providerStatus{}
+	for _, status := range body.Providers {
+		byProvider[status.Provider] = status
+	}
+	assert.NotContains(t, byProvider, "fred")
+
+	alphaVantage := byProvider["alphaVantage"]
+	assert.Equal(t, "open", alphaVantage.State)
+	assert.Equal(t, 1, alphaVantage.ConsecutiveFailures)
+	assert.NotNil(t, alphaVantage.RetryAt)
+	assert.Equal(t, "HTTP 502", alphaVantage.LastError)
+	assert.NotNil(t, alphaVantage.AverageLatencyMs)
+	assert.Equal(t, 1, alphaVantage.RequestsToday)
+	assert.Equal(t, 24, *alphaVantage.Remaining)
+}
+
+// Test ?source= can choose crypto providers too, and only those SOURCE_ALLOWLIST
+// allows
+func TestSourceSelection(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockCoinGecko(t)
+	setupMockCoinbase(t)
+	setupMockTiingo(t)
+	setStockProviderOrder(t, "alphaVantage,tiingo")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/0.5/BTC/on/2025-03-31/and-sold-on/2025-07-18?type=crypto&source=Coinbase")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		BuyPrice  float64      `json:"buyPrice"`
+		DataNotes []dataSource `json:"dataNotes"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 82500.0+1439+20, response.BuyPrice)
+
+	// A crypto provider has no stock prices, nor a stock provider crypto ones
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?source=coinbase")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "coinbase has no stock prices")
+	w = makeTestRequest(router, "GET", "/0.5/BTC/on/2025-03-31/and-sold-on/2025-07-18?type=crypto&source=tiingo")
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "tiingo has no crypto prices")
+
+	setSettings(t, func(cfg *Config) { cfg.SourceAllowlist = "alphaVantage,coinGecko" })
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?source=tiingo")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "must be a configured provider (alphaVantage, coinGecko)")
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?source=alphavantage")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
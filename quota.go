@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outbound requests to one host: today's (UTC) and since the server started
+type providerUsage struct {
+	Provider      string     `json:"provider"`
+	Host          string     `json:"host"`
+	Day           string     `json:"day"`
+	Requests      int        `json:"requests"`
+	Failures      int        `json:"failures"`    // transport errors and 5xx answers
+	RateLimited   int        `json:"rateLimited"` // 429 answers
+	DailyLimit    *int       `json:"dailyLimit,omitempty"`
+	Remaining     *int       `json:"remaining,omitempty"`
+	TotalRequests int        `json:"totalRequests"`
+	LastRequestAt *time.Time `json:"lastRequestAt,omitempty"`
+}
+
+var (
+	providerUsageMu sync.Mutex
+	providerUsages  = map[string]*providerUsage{}
+)
+
+// Helper function to name the provider behind a host, from the configured base
+// URLs; other hosts (secret stores, webhooks) go by their host name
+func providerName(host string) string {
+	cfg := settings()
+	for name, baseURL := range map[string]string{
+		"alphaVantage":     cfg.AlphaVantageBaseURL,
+		"polygon":          cfg.PolygonBaseURL,
+		"tiingo":           cfg.TiingoBaseURL,
+		"twelveData":       cfg.TwelveDataBaseURL,
+		"fmp":              cfg.FMPBaseURL,
+		"eodhd":            cfg.EODHDBaseURL,
+		"alpaca":           cfg.AlpacaBaseURL,
+		"coinGecko":        cfg.CoinGeckoBaseURL,
+		"coinbase":         cfg.CoinbaseBaseURL,
+		"binance":          cfg.BinanceBaseURL,
+		"cryptoCompare":    cfg.CryptoCompareBaseURL,
+		"coinCap":          cfg.CoinCapBaseURL,
+		"stooq":            cfg.StooqBaseURL,
+		"fred":             cfg.FREDBaseURL,
+		"frankfurter":      cfg.FrankfurterBaseURL,
+		"exchangeRateHost": cfg.FXFallbackBaseURL,
+	} {
+		if parsed, err := url.Parse(baseURL); err == nil && parsed.Host == host {
+			return name
+		}
+	}
+	return host
+}
+
+// Helper function to parse PROVIDER_DAILY_LIMITS ("name=limit, ...")
+func parseProviderLimits(limits string) (map[string]int, error) {
+	parsed := map[string]int{}
+	for _, item := range splitList(limits) {
+		name, value, ok := strings.Cut(item, "=")
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if !ok || err != nil || limit < 0 {
+			return nil, fmt.Errorf("provider_daily_limits entry %q must be name=limit", item)
+		}
+		parsed[strings.TrimSpace(name)] = limit
+	}
+	return parsed, nil
+}
+
+// Record an outbound request and how it went
+func recordProviderRequest(host string, status int, failed bool) {
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+
+	providerUsageMu.Lock()
+	defer providerUsageMu.Unlock()
+	usage := providerUsages[host]
+	if usage == nil {
+		usage = &providerUsage{Host: host}
+		providerUsages[host] = usage
+	}
+	if usage.Day != today {
+		usage.Day, usage.Requests, usage.Failures, usage.RateLimited = today, 0, 0, 0
+	}
+	usage.Requests++
+	usage.TotalRequests++
+	usage.LastRequestAt = &now
+	if failed || status >= 500 {
+		usage.Failures++
+	}
+	if status == http.StatusTooManyRequests {
+		usage.RateLimited++
+	}
+}
+
+// Report every provider's usage, with what's left of its daily limit if it has one.
+func providerUsageReport() []providerUsage {
+	limits, _ := parseProviderLimits(settings().ProviderDailyLimits)
+	today := time.Now().UTC().Format("2006-01-02")
+
+	providerUsageMu.Lock()
+	report := []providerUsage{}
+	seen := map[string]bool{}
+	for host, usage := range providerUsages {
+		entry := *usage
+		entry.Provider = providerName(host)
+		if entry.Day != today {
+			entry.Day, entry.Requests, entry.Failures, entry.RateLimited = today, 0, 0, 0
+		}
+		report = append(report, entry)
+		seen[entry.Provider] = true
+	}
+	providerUsageMu.Unlock()
+
+	for name := range limits {
+		if !seen[name] {
+			report = append(report, providerUsage{Provider: name, Day: today})
+		}
+	}
+	for i := range report {
+		if limit, ok := limits[report[i].Provider]; ok {
+			remaining := max(limit-report[i].Requests, 0)
+			report[i].DailyLimit, report[i].Remaining = &limit, &remaining
+		}
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Provider < report[j].Provider })
+	return report
+}
+
+// Transport counting each outbound request against its provider, pacing and
+// coalescing provider requests, and failing fast while the provider's circuit
+// breaker is open
+type countingTransport struct {
+	base http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Identical provider fetches in flight at once share one upstream request
+	if req.Method == http.MethodGet && providerName(req.URL.Host) != req.URL.Host {
+		return coalesceRequest(req, t.send)
+	}
+	return t.send(req)
+}
+
+// Helper function to send one request upstream, at its provider's pace
+func (t countingTransport) send(req *http.Request) (*http.Response, error) {
+	if err := paceRequest(req); err != nil {
+		return nil, err
+	}
+	if err := allowProviderRequest(req.URL.Host); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	recordProviderRequest(req.URL.Host, status, err != nil)
+	recordProviderHealth(req.URL.Host, time.Since(start), status, err)
+	return resp, err
+}
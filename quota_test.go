@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test outbound requests are counted per provider against its daily limit
+func TestProviderUsage(t *testing.T) {
+	setupAlphaVantagePacing(t, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	setSettings(t, func(cfg *Config) {
+		cfg.AlphaVantageBaseURL, cfg.ProviderDailyLimits = server.URL, "alphaVantage=25, fred=120"
+	})
+
+	for _, path := range []string{"/query", "/limited", "/broken"} {
+		resp, err := outboundClient.Get(server.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	byProvider := map[string]providerUsage{}
+	for _, usage := range providerUsageReport() {
+		byProvider[usage.Provider] = usage
+	}
+	alphaVantage := byProvider["alphaVantage"]
+	assert.Equal(t, 3, alphaVantage.Requests)
+	assert.Equal(t, 3, alphaVantage.TotalRequests)
+	assert.Equal(t, 1, alphaVantage.RateLimited)
+	assert.Equal(t, 1, alphaVantage.Failures)
+	assert.Equal(t, 25, *alphaVantage.DailyLimit)
+	assert.Equal(t, 22, *alphaVantage.Remaining)
+	assert.NotNil(t, alphaVantage.LastRequestAt)
+
+	// Providers with a limit are listed before their first request
+	assert.Equal(t, 120, *byProvider["fred"].Remaining)
+}
+
+// Test PROVIDER_DAILY_LIMITS is parsed and checked
+func TestParseProviderLimits(t *testing.T) {
+	limits, err := parseProviderLimits("alphaVantage=25, coinGecko = 10000")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"alphaVantage": 25, "coinGecko": 10000}, limits)
+
+	for _, bad := range []string{"alphaVantage", "alphaVantage=lots", "alphaVantage=-1"} {
+		_, err := parseProviderLimits(bad)
+		assert.ErrorContains(t, err, "must be name=limit", bad)
+	}
+}
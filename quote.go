@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alpha Vantage latest quote response struct
+// Example: https://www.alphavantage.co/query?function=GLOBAL_QUOTE&symbol=AAPL&apikey=demo
+type alphaVantageQuoteResponse struct {
+	Quote map[string]string `json:"Global Quote"`
+}
+
+// Fetch a stock's latest traded price and trading day from Alpha Vantage
+func fetchStockQuoteAlphaVantage(ticker string) (float64, string, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=GLOBAL_QUOTE&symbol=%s&apikey=%s", cfg.AlphaVantageBaseURL, url.QueryEscape(ticker), cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var result alphaVantageQuoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", err
+	}
+	price, err := strconv.ParseFloat(result.Quote["05. price"], 64)
+	if err != nil || price <= 0 {
+		return 0, "", fmt.Errorf("No latest quote for %s", ticker)
+	}
+	return price, result.Quote["07. latest trading day"], nil
+}
+
+// Fetch a coin's latest USD price from CoinGecko
+// Example: https://api.coingecko.com/api/v3/simple/price?ids=bitcoin&vs_currencies=usd
+func fetchCryptoQuoteUSD(symbol string) (float64, string, error) {
+	cfg := settings()
+	coinID, err := lookupCoinID(symbol)
+	if err != nil {
+		return 0, "", err
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/simple/price?ids=%s&vs_currencies=usd", cfg.CoinGeckoBaseURL, url.QueryEscape(coinID)), nil)
+	if err != nil {
+		return 0, "", err
+	}
+	if cfg.CoinGeckoAPIKey != "" {
+		req.Header.Set("x-cg-demo-api-key", cfg.CoinGeckoAPIKey)
+	}
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	var result map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, "", err
+	}
+	price, ok := result[coinID]["usd"]
+	if !ok || price <= 0 {
+		return 0, "", fmt.Errorf("No latest quote for %s", symbol)
+	}
+	return price, time.Now().UTC().Format("2006-01-02"), nil
+}
+
+// Fetch an asset's latest price in USD (index levels in the index's own currency)
+// and the date it's from, kept fresh by the background refresher
+func fetchLatestPrice(ticker, assetType string) (float64, string, error) {
+	return latest.lookup("quote "+assetType+" "+strings.ToUpper(ticker), "", func() (float64, string, error) {
+		return fetchProviderLatestPrice(ticker, assetType)
+	})
+}
+
+// Fetch an asset's latest price from its provider: the live quote for stocks and
+// crypto, and the most recent daily close for indices and commodities
+func fetchProviderLatestPrice(ticker, assetType string) (float64, string, error) {
+	switch assetType {
+	case "stock":
+		return fetchStockQuoteAlphaVantage(ticker)
+	case "crypto":
+		return fetchCryptoQuoteUSD(strings.ToUpper(ticker))
+	case "index", "commodity":
+		today := time.Now().UTC()
+		points, err := fetchPriceHistory(ticker, assetType, today.AddDate(0, 0, -14).Format("2006-01-02"), today.Format("2006-01-02"), priceOptions{At: "close"})
+		if err != nil {
+			return 0, "", err
+		}
+		latest := points[len(points)-1]
+		return latest.Price, latest.Date, nil
+	}
+	return 0, "", fmt.Errorf("Latest prices are not available for type %s", assetType)
+}
+
+// Value shares of an asset at its latest price, in currency
+func latestValue(ticker, assetType, currency string, shares float64) (float64, error) {
+	price, date, err := fetchLatestPrice(ticker, assetType)
+	if err != nil {
+		return 0, err
+	}
+	valueUSD := shares * price
+	if currency == "USD" {
+		return valueUSD, nil
+	}
+	fxRate, err := getHistoricalFXRate(currency, "USD", date)
+	if err != nil {
+		return 0, err
+	}
+	return valueUSD / fxRate, nil
+}
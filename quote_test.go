@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test latest prices come from the live quote for stocks and crypto
+func TestFetchLatestPrice(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockCoinGecko(t)
+
+	price, date, err := fetchLatestPrice("AAPL", "stock")
+	assert.NoError(t, err)
+	assert.Equal(t, 212.48, price)
+	assert.Equal(t, "2025-07-21", date)
+
+	price, _, err = fetchLatestPrice("SOLANA", "crypto")
+	assert.NoError(t, err)
+	assert.Equal(t, 177.5, price)
+
+	_, _, err = fetchLatestPrice("US10Y", "bond")
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+)
+
+// Paces Alpha Vantage requests across the whole server, allowing a minute's worth at once
+var alphaVantagePacer = rate.NewLimiter(rate.Inf, 1)
+
+// Concurrent identical provider fetches, answered by one upstream request
+var upstreamFetches singleflight.Group
+
+// Helper function to set how fast Alpha Vantage requests go out
+func setAlphaVantageRateLimit(perMinute int) {
+	if perMinute == 0 {
+		alphaVantagePacer.SetLimit(rate.Inf)
+		return
+	}
+	alphaVantagePacer.SetLimit(rate.Limit(float64(perMinute) / 60))
+	alphaVantagePacer.SetBurst(perMinute)
+}
+
+// An upstream response read in full, so every request waiting on it gets a copy
+type sharedResponse struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+// Helper function to wait for Alpha Vantage's turn, for requests to it. Other hosts
+// go straight away.
+func paceRequest(req *http.Request) error {
+	if req.URL.Host != hostOf(settings().AlphaVantageBaseURL) {
+		return nil
+	}
+	return alphaVantagePacer.Wait(req.Context())
+}
+
+// Helper function to send a GET to a provider once however many requests for the
+// same URL arrive while it's in flight. The upstream request outlives the first
+// caller giving up (though not its deadline), so the others still get its answer.
+func coalesceRequest(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	key := req.URL.String()
+	results := upstreamFetches.DoChan(key, func() (interface{}, error) {
+		ctx := context.WithoutCancel(req.Context())
+		if deadline, ok := req.Context().Deadline(); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+		resp, err := send(req.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return sharedResponse{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, Body: body}, nil
+	})
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case result := <-results:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		shared := result.Val.(sharedResponse)
+		return &http.Response{
+			StatusCode:    shared.StatusCode,
+			Status:        shared.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        shared.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(shared.Body)),
+			ContentLength: int64(len(shared.Body)),
+			Request:       req,
+		}, nil
+	}
+}
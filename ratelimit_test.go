@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Tests talk to mocks, which needn't be paced; tests of pacing turn it on
+func init() {
+	cfg := *settings()
+	cfg.AlphaVantageRate = 0
+	publishConfig(cfg)
+}
+
+// Helper function to pace Alpha Vantage requests for a test; 0 lets mocks answer
+// as fast as tests ask
+func setupAlphaVantagePacing(t *testing.T, perMinute int) {
+	setAlphaVantageRateLimit(perMinute)
+	t.Cleanup(func() { setAlphaVantageRateLimit(settings().AlphaVantageRate) })
+}
+
+// Test a burst of identical requests reaches the provider once, with every caller
+// getting the whole answer
+func TestCoalesceRequests(t *testing.T) {
+	setupAlphaVantagePacing(t, 0)
+	var upstream atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"symbol":"AAPL"}`))
+	}))
+	defer server.Close()
+
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = server.URL })
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := outboundClient.Get(server.URL + "/query?function=TIME_SERIES_DAILY&symbol=AAPL")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			body := make([]byte, 64)
+			n, _ := resp.Body.Read(body)
+			bodies[i] = string(body[:n])
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstream.Load())
+	for _, body := range bodies {
+		assert.Equal(t, `{"symbol":"AAPL"}`, body)
+	}
+
+	// Once answered, the next request goes upstream again
+	resp, err := outboundClient.Get(server.URL + "/query?function=TIME_SERIES_DAILY&symbol=AAPL")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), upstream.Load())
+}
+
+// Test Alpha Vantage requests beyond the burst wait their turn
+func TestAlphaVantagePacing(t *testing.T) {
+	setupAlphaVantagePacing(t, 600)
+	alphaVantagePacer.SetBurst(1) // one every 100ms
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = server.URL })
+
+	start := time.Now()
+	for _, symbol := range []string{"AAPL", "MSFT", "NVDA"} {
+		resp, err := outboundClient.Get(server.URL + "/query?symbol=" + symbol)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// A caller giving up stops waiting
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/query?symbol=AMZN", nil)
+	ctx, cancel := context.WithTimeout(req.Context(), 10*time.Millisecond)
+	defer cancel()
+	_, err := outboundClient.Do(req.WithContext(ctx))
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Latest data points not requested for this long stop being refreshed
+const latestIdleTimeout = 24 * time.Hour
+
+// A latest data point (a live quote, today's price or today's FX rate), how to
+// fetch it again, and when it was last fetched and requested. Day is the UTC day
+// it's for, or empty for quotes that move on by themselves.
+type latestEntry struct {
+	Value     float64
+	Date      string
+	Day       string
+	FetchedAt time.Time
+	UsedAt    time.Time
+	fetch     func() (float64, string, error)
+}
+
+// Latest data points requests have asked for, kept fresh in the background so
+// requests don't wait on providers for data that changes during the day
+type latestCache struct {
+	mu      sync.Mutex
+	entries map[string]*latestEntry
+	hits    int
+	misses  int
+}
+
+var latest = &latestCache{entries: map[string]*latestEntry{}}
+
+// Look up a latest data point, fetching it if it isn't cached or wasn't refreshed
+// for two intervals (the refresher is behind or failing). Values fetched here are
+// refreshed in the background from then on. When every provider fails, the last
+// value fetched for the same day is served stale rather than failing the request.
+func (l *latestCache) lookup(key, day string, fetch func() (float64, string, error)) (float64, string, error) {
+	cfg := settings()
+	if cfg.RefreshInterval <= 0 {
+		return fetch()
+	}
+	now := time.Now().UTC()
+	maxAge := 2 * time.Duration(cfg.RefreshInterval) * time.Second
+
+	l.mu.Lock()
+	if entry, ok := l.entries[key]; ok && entry.Day == day && now.Sub(entry.FetchedAt) < maxAge {
+		entry.UsedAt = now
+		l.hits++
+		l.mu.Unlock()
+		return entry.Value, entry.Date, nil
+	}
+	l.misses++
+	l.mu.Unlock()
+
+	value, date, err := fetch()
+	if err != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if entry, ok := l.entries[key]; ok && entry.Day == day {
+			entry.UsedAt = now
+			return entry.Value, entry.Date, nil
+		}
+		return 0, "", err
+	}
+	l.mu.Lock()
+	l.entries[key] = &latestEntry{Value: value, Date: date, Day: day, FetchedAt: now, UsedAt: now, fetch: fetch}
+	l.mu.Unlock()
+	return value, date, nil
+}
+
+// Fetch every latest data point requested recently again, one at a time, dropping
+// the ones nobody asked for lately and the ones for a day that's over. Returns
+// how many were refreshed and how many failed.
+func (l *latestCache) refresh() (refreshed, failed int) {
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+
+	l.mu.Lock()
+	keys := []string{}
+	for key, entry := range l.entries {
+		if now.Sub(entry.UsedAt) > latestIdleTimeout || (entry.Day != "" && entry.Day != today) {
+			delete(l.entries, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	l.mu.Unlock()
+
+	for _, key := range keys {
+		l.mu.Lock()
+		entry, ok := l.entries[key]
+		l.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		value, date, err := entry.fetch()
+		if err != nil {
+			log.Printf("Refreshing %s failed: %v", key, err)
+			failed++
+			continue
+		}
+
+		l.mu.Lock()
+		if current, ok := l.entries[key]; ok {
+			current.Value, current.Date, current.FetchedAt = value, date, time.Now().UTC()
+		}
+		l.mu.Unlock()
+		refreshed++
+	}
+	return refreshed, failed
+}
+
+// When a latest data point was last fetched, or otherwise (the cache is off) now,
+// and whether it's stale: not refreshed for two intervals, so served because
+// fetching it again failed
+func (l *latestCache) fetchedAt(key string, now time.Time) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.entries[key]; ok {
+		return entry.FetchedAt, time.Since(entry.FetchedAt) >= 2*time.Duration(settings().RefreshInterval)*time.Second
+	}
+	return now, false
+}
+
+// Drop every latest data point
+func (l *latestCache) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = map[string]*latestEntry{}
+}
+
+// Report the cache's size and hit rate
+func (l *latestCache) stats() (size, hits, misses int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.entries), l.hits, l.misses
+}
+
+// Helper function to make the cache key of today's price of an asset
+func latestPriceKey(key priceKey) string {
+	latestKey := fmt.Sprintf("price %s %s %s adjusted=%t", key.AssetType, key.Ticker, key.At, key.Adjusted)
+	if key.Source != "" {
+		latestKey += " source=" + key.Source
+	}
+	return latestKey
+}
+
+// Refresh latest data points every REFRESH_INTERVAL seconds. While the cache is
+// off (0) the interval is checked again every minute, so a reload can turn it on.
+func refreshLatestPeriodically() {
+	go func() {
+		for {
+			interval := settings().RefreshInterval
+			if interval <= 0 {
+				time.Sleep(time.Minute)
+				continue
+			}
+
+			time.Sleep(time.Duration(interval) * time.Second)
+			if refreshed, failed := latest.refresh(); failed > 0 {
+				log.Printf("Refreshed %d latest data points, %d failed", refreshed, failed)
+			}
+		}
+	}()
+}
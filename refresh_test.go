@@ -0,0 +1,157 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to turn the latest data cache on for a test, starting empty
+func setupLatestCache(t *testing.T) {
+	setSettings(t, func(cfg *Config) { cfg.RefreshInterval = 300 })
+	latest.clear()
+	t.Cleanup(latest.clear)
+}
+
+// Test latest data points are fetched once, then served and refreshed from the cache
+func TestLatestCacheLookupAndRefresh(t *testing.T) {
+	setupLatestCache(t)
+	calls := 0
+	fetch := func() (float64, string, error) {
+		calls++
+		return float64(calls), "2025-07-21", nil
+	}
+
+	value, date, err := latest.lookup("quote stock AAPL", "", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, value)
+	assert.Equal(t, "2025-07-21", date)
+	value, _, _ = latest.lookup("quote stock AAPL", "", fetch)
+	assert.Equal(t, 1.0, value)
+	assert.Equal(t, 1, calls)
+
+	refreshed, failed := latest.refresh()
+	assert.Equal(t, 1, refreshed)
+	assert.Zero(t, failed)
+	value, _, _ = latest.lookup("quote stock AAPL", "", fetch)
+	assert.Equal(t, 2.0, value)
+	assert.Equal(t, 2, calls)
+
+	// Entries the refresher fell behind on are fetched by the request
+	latest.entries["quote stock AAPL"].FetchedAt = time.Now().Add(-time.Hour)
+	value, _, _ = latest.lookup("quote stock AAPL", "", fetch)
+	assert.Equal(t, 3.0, value)
+}
+
+// Test failed fetches aren't cached and failed refreshes keep the last value
+func TestLatestCacheFailures(t *testing.T) {
+	setupLatestCache(t)
+	_, _, err := latest.lookup("fx EUR USD", "", func() (float64, string, error) { return 0, "", assert.AnError })
+	assert.ErrorIs(t, err, assert.AnError)
+	size, _, _ := latest.stats()
+	assert.Zero(t, size)
+
+	up := true
+	fetch := func() (float64, string, error) {
+		if !up {
+			return 0, "", assert.AnError
+		}
+		return 1.16, "2025-07-21", nil
+	}
+	latest.lookup("fx EUR USD", "", fetch)
+	up = false
+	refreshed, failed := latest.refresh()
+	assert.Zero(t, refreshed)
+	assert.Equal(t, 1, failed)
+	value, _, err := latest.lookup("fx EUR USD", "", fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.16, value)
+}
+
+// Test the refresher drops entries nobody asked for lately and days that are over
+func TestLatestCacheDropsStale(t *testing.T) {
+	setupLatestCache(t)
+	fetch := func() (float64, string, error) { return 1, "", nil }
+	today := time.Now().UTC().Format("2006-01-02")
+	latest.lookup("idle", "", fetch)
+	latest.lookup("yesterday", "2025-07-18", fetch)
+	latest.lookup("today", today, fetch)
+	latest.entries["idle"].UsedAt = time.Now().Add(-2 * latestIdleTimeout)
+
+	refreshed, _ := latest.refresh()
+	assert.Equal(t, 1, refreshed)
+	assert.Len(t, latest.entries, 1)
+	assert.Contains(t, latest.entries, "today")
+}
+
+// Test a REFRESH_INTERVAL of 0 turns the cache off
+func TestLatestCacheOff(t *testing.T) {
+	setupLatestCache(t)
+	setSettings(t, func(cfg *Config) { cfg.RefreshInterval = 0 })
+	calls := 0
+	fetch := func() (float64, string, error) {
+		calls++
+		return 1, "", nil
+	}
+	latest.lookup("quote stock AAPL", "", fetch)
+	latest.lookup("quote stock AAPL", "", fetch)
+	assert.Equal(t, 2, calls)
+	size, _, _ := latest.stats()
+	assert.Zero(t, size)
+}
+
+// Test latest quotes are served from the cache while the provider is down
+func TestLatestQuoteCached(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupLatestCache(t)
+
+	price, date, err := fetchLatestPrice("aapl", "stock")
+	assert.NoError(t, err)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = down.URL })
+
+	againPrice, againDate, err := fetchLatestPrice("AAPL", "stock")
+	assert.NoError(t, err)
+	assert.Equal(t, price, againPrice)
+	assert.Equal(t, date, againDate)
+
+	_, failed := latest.refresh()
+	assert.Equal(t, 1, failed)
+}
+
+// Test values the refresher fell behind on are served stale while every provider
+// fails, but not once their day is over
+func TestLatestCacheServesStale(t *testing.T) {
+	setupLatestCache(t)
+	up := true
+	fetch := func() (float64, string, error) {
+		if !up {
+			return 0, "", assert.AnError
+		}
+		return 1.16, "2025-07-21", nil
+	}
+	today := time.Now().UTC().Format("2006-01-02")
+	latest.lookup("fx EUR USD", today, fetch)
+	fetchedAt, stale := latest.fetchedAt("fx EUR USD", time.Now())
+	assert.False(t, stale)
+
+	up = false
+	latest.entries["fx EUR USD"].FetchedAt = time.Now().Add(-time.Hour)
+	value, date, err := latest.lookup("fx EUR USD", today, fetch)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.16, value)
+	assert.Equal(t, "2025-07-21", date)
+	staleAt, stale := latest.fetchedAt("fx EUR USD", time.Now())
+	assert.True(t, stale)
+	assert.True(t, staleAt.Before(fetchedAt))
+
+	_, _, err = latest.lookup("fx EUR USD", "2099-01-01", fetch)
+	assert.ErrorIs(t, err, assert.AnError)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// One way a position ended: sold on the sell date, or still held at the most
+// recent close. Values are in the amount's currency (USD for quantities).
+type regretOutcome struct {
+	Date          string  `json:"date"`
+	Price         float64 `json:"price"`
+	FinalValue    float64 `json:"finalValue"`
+	FinalValueUSD float64 `json:"finalValueUSD"`
+	ReturnPercent float64 `json:"returnPercent"`
+}
+
+// For users who actually sold: compare the sale with having held the same
+// position until the most recent close, and report the gain given up since
+// (negative when selling was the right call). Both are price-only; add
+// ?adjusted=true to count dividends.
+func handleSoldTooEarly(c *gin.Context) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sold too early is not supported for bonds or options"})
+		return
+	}
+
+	heldUntil := latestCloseDate(ticker, typeParam, time.Now())
+	if dateOnly(sellDate) >= heldUntil {
+		abortWithDateError(c, fmt.Errorf("sellDate %s must be before the most recent close, on %s", sellDate, heldUntil))
+		return
+	}
+	// Held until the latest close, which moves on tomorrow, so never cached for good
+	c.Set("stillHeld", true)
+
+	fxRateBuy, fxRateSell, fxRateHeld := 1.0, 1.0, 1.0
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+		if fxRateBuy, err = priceOpts.Notes.fxRate(currency, "USD", buyDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+			return
+		}
+		if fxRateSell, err = priceOpts.Notes.fxRate("USD", currency, sellDate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			return
+		}
+		if fxRateHeld, err = priceOpts.Notes.fxRate("USD", currency, heldUntil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for the most recent close", "details": err.Error()})
+			return
+		}
+	}
+
+	buyPrice, err := fetchPrice(ticker, buyDate, typeParam, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch buy price", err)
+		return
+	}
+	sellPrice, err := fetchPrice(ticker, sellDate, typeParam, priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sell price", "details": err.Error()})
+		return
+	}
+	heldPrice, err := fetchPrice(ticker, heldUntil, typeParam, priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch the most recent price", "details": err.Error()})
+		return
+	}
+
+	shares, invested := parsedAmount, parsedAmount*buyPrice
+	if isValue {
+		shares, invested = parsedAmount*fxRateBuy/buyPrice, parsedAmount
+	}
+	sold := regretOutcome{Date: sellDate, Price: sellPrice, FinalValueUSD: shares * sellPrice}
+	sold.FinalValue = sold.FinalValueUSD * fxRateSell
+	sold.ReturnPercent = (sold.FinalValue/invested - 1) * 100
+	held := regretOutcome{Date: heldUntil, Price: heldPrice, FinalValueUSD: shares * heldPrice}
+	held.FinalValue = held.FinalValueUSD * fxRateHeld
+	held.ReturnPercent = (held.FinalValue/invested - 1) * 100
+
+	response := gin.H{
+		"message":             localize(c, "Sold too early"),
+		"ticker":              ticker,
+		"buyDate":             buyDate,
+		"sellDate":            sellDate,
+		"buyPrice":            buyPrice,
+		"shares":              shares,
+		"sold":                sold,
+		"held":                held,
+		"foregoneGain":        held.FinalValue - sold.FinalValue,
+		"foregoneGainPercent": (held.FinalValue/sold.FinalValue - 1) * 100,
+		"soldTooEarly":        held.FinalValue > sold.FinalValue,
+		"type":                typeParam,
+		"priceAt":             priceOpts.At,
+		"priceBasis":          priceOpts.basis(typeParam),
+	}
+	if isValue {
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["stockCurrency"] = "USD"
+		response["fxRateBuy"] = fxRateBuy
+		response["fxRateSell"] = fxRateSell
+		response["fxRateHeld"] = fxRateHeld
+	} else {
+		response["quantity"] = parsedAmount
+	}
+	addLimitOrder(response, priceOpts)
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCryptoPricing(response, typeParam)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the sold-too-early route against holding until the mocked latest close
+func TestSoldTooEarlyWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	heldUntil := latestCloseDate("AAPL", "stock", time.Now())
+	mockStockData[heldUntil] = map[string]string{"4. close": "250.00"}
+	t.Cleanup(func() { delete(mockStockData, heldUntil) })
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		Shares              float64       `json:"shares"`
+		Sold                regretOutcome `json:"sold"`
+		Held                regretOutcome `json:"held"`
+		ForegoneGain        float64       `json:"foregoneGain"`
+		ForegoneGainPercent float64       `json:"foregoneGainPercent"`
+		SoldTooEarly        bool          `json:"soldTooEarly"`
+	}
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31/and-sold-on/2025-07-18/sold-too-early")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEqual(t, immutableCacheControl, w.Header().Get("Cache-Control"))
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	shares := 1000 / 200.50
+	assert.InDelta(t, shares*211.18, response.Sold.FinalValue, 1e-9)
+	assert.Equal(t, heldUntil, response.Held.Date)
+	assert.InDelta(t, shares*250, response.Held.FinalValue, 1e-9)
+	assert.InDelta(t, shares*(250-211.18), response.ForegoneGain, 1e-9)
+	assert.InDelta(t, (250/211.18-1)*100, response.ForegoneGainPercent, 1e-9)
+	assert.True(t, response.SoldTooEarly)
+
+	// Selling above today's price was the right call
+	mockStockData[heldUntil]["4. close"] = "190.00"
+	prices.invalidate(priceFilter{})
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/sold-too-early")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 10*(190-211.18), response.ForegoneGain, 1e-9)
+	assert.InDelta(t, (190/200.50-1)*100, response.Held.ReturnPercent, 1e-9)
+	assert.False(t, response.SoldTooEarly)
+
+	// There's nothing to regret selling on the latest close
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/"+heldUntil+"/sold-too-early")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "most recent close")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+)
+
+// Serialises changes to the settings, so a reload and a secrets refresh don't
+// overwrite each other. Readers don't take it: see settings().
+var configMu sync.Mutex
+
+// Settings only read at startup, which a reload can't change
+var restartOnlySettings = []string{
+	"port", "listen_addresses", "admin_address", "admin_token", "gin_mode", "max_url_bytes", "max_body_bytes",
+	"job_workers", "job_queue_size", "job_ttl", "webhook_secret", "webhook_allow_private",
+	"alerts_path", "alert_check_interval", "api_keys", "watchlists_path", "smtp_addr", "smtp_username", "smtp_password", "smtp_from",
+	"cors_allowed_origins", "cors_allowed_methods", "cors_allowed_headers", "cors_max_age",
+	"fx_dataset_path", "corporate_actions_path", "equivalents_path", "exchange_holidays_path",
+	"outbound_proxy", "ca_bundle_path",
+	"tls_cert_file", "tls_key_file", "tls_autocert_domains", "tls_autocert_cache_dir", "tls_autocert_email", "http_redirect_port",
+}
+
+// Helper function to list the restart-only settings that differ between two configs
+func restartOnlyChanges(old, updated Config) []string {
+	oldValue, updatedValue, fields := reflect.ValueOf(old), reflect.ValueOf(updated), reflect.TypeOf(old)
+
+	var changed []string
+	for _, key := range restartOnlySettings {
+		for i := 0; i < fields.NumField(); i++ {
+			if fields.Field(i).Tag.Get("key") == key && oldValue.Field(i).Interface() != updatedValue.Field(i).Interface() {
+				changed = append(changed, key)
+			}
+		}
+	}
+	return changed
+}
+
+// Helper function to copy the restart-only settings from the running config, so
+// a reload doesn't publish values nothing will apply
+func keepRestartOnlySettings(cfg *Config, running Config) {
+	value, runningValue, fields := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(running), reflect.TypeOf(running)
+	for _, key := range restartOnlySettings {
+		for i := 0; i < fields.NumField(); i++ {
+			if fields.Field(i).Tag.Get("key") == key {
+				value.Field(i).Set(runningValue.Field(i))
+			}
+		}
+	}
+}
+
+// Reload settings from the config file, environment and secret store, returning
+// them as loaded. Invalid settings are rejected and the current ones kept, and
+// restart-only settings keep their running values until a restart.
+func reloadConfig(path string, current Config) (Config, error) {
+	cfg, err := loadConfig(path)
+	if err == nil {
+		err = loadSecrets(&cfg)
+	}
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		return current, err
+	}
+
+	configMu.Lock()
+	published := cfg
+	keepRestartOnlySettings(&published, *settings())
+	publishConfig(published)
+	configMu.Unlock()
+	return cfg, nil
+}
+
+// Reload settings whenever the process gets SIGHUP, so keys, rate series and cache
+// lifetimes can change without a restart
+func reloadOnSIGHUP(path string, cfg Config) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			updated, err := reloadConfig(path, cfg)
+			if err != nil {
+				log.Printf("Config reload failed, keeping current settings: %v", err)
+				continue
+			}
+			if changed := restartOnlyChanges(*settings(), updated); len(changed) > 0 {
+				log.Printf("Config reloaded; restart to apply %v", changed)
+			} else {
+				log.Printf("Config reloaded")
+			}
+			cfg = updated
+		}
+	}()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test which changed settings need a restart
+func TestRestartOnlyChanges(t *testing.T) {
+	old := defaultConfig()
+	updated := old
+	updated.AlphaVantageAPIKey = "rotated"
+	updated.CacheMaxAge = 60
+	assert.Empty(t, restartOnlyChanges(old, updated))
+
+	updated.Port = 9000
+	updated.CORSAllowedOrigins = "*"
+	assert.Equal(t, []string{"port", "cors_allowed_origins"}, restartOnlyChanges(old, updated))
+}
+
+// Test reloading applies valid settings and keeps the current ones otherwise
+func TestReloadConfig(t *testing.T) {
+	t.Setenv("ALPHA_VANTAGE_API_KEY", "")
+	t.Setenv("CASH_RATE_SERIES", "")
+	old := settings()
+	t.Cleanup(func() { publishConfig(*old) })
+
+	current := defaultConfig()
+	current.AlphaVantageAPIKey = "old"
+	applyConfig(current)
+
+	path := writeConfigFile(t, "config.yaml", "alpha_vantage_api_key: rotated\ncash_rate_series: TB3MS\ncache_max_age: 60\n")
+	updated, err := reloadConfig(path, current)
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", updated.AlphaVantageAPIKey)
+	assert.Equal(t, "rotated", settings().AlphaVantageAPIKey)
+	assert.Equal(t, "TB3MS", settings().CashRateSeries)
+	assert.Equal(t, 60, settings().CacheMaxAge)
+
+	// No key: rejected, settings unchanged
+	path = writeConfigFile(t, "config.yaml", "cash_rate_series: FEDFUNDS\n")
+	kept, err := reloadConfig(path, updated)
+	assert.Error(t, err)
+	assert.Equal(t, updated, kept)
+	assert.Equal(t, "rotated", settings().AlphaVantageAPIKey)
+	assert.Equal(t, "TB3MS", settings().CashRateSeries)
+}
+
+// Test a reload changing restart-only settings leaves their running values alone
+func TestReloadKeepsRestartOnlySettings(t *testing.T) {
+	t.Setenv("ALPHA_VANTAGE_API_KEY", "")
+	t.Setenv("PORT", "")
+	old := settings()
+	t.Cleanup(func() { publishConfig(*old) })
+
+	current := defaultConfig()
+	current.AlphaVantageAPIKey = "old"
+	applyConfig(current)
+
+	path := writeConfigFile(t, "config.yaml", "alpha_vantage_api_key: rotated\nport: 9000\nmax_body_bytes: 4096\napi_keys: newkey\n")
+	updated, err := reloadConfig(path, current)
+	assert.NoError(t, err)
+	assert.Equal(t, 9000, updated.Port)
+	assert.Equal(t, []string{"port", "max_body_bytes", "api_keys"}, restartOnlyChanges(*settings(), updated))
+
+	assert.Equal(t, "rotated", settings().AlphaVantageAPIKey)
+	assert.Equal(t, current.Port, settings().Port)
+	assert.Equal(t, current.MaxBodyBytes, settings().MaxBodyBytes)
+	assert.Equal(t, current.APIKeys, settings().APIKeys)
+}
+
+// Test a reload doesn't wait for requests in flight, which finish with the
+// settings they started with
+func TestReloadDuringRequest(t *testing.T) {
+	t.Setenv("ALPHA_VANTAGE_API_KEY", "")
+	old := settings()
+	t.Cleanup(func() { publishConfig(*old) })
+	setupMockAlphaVantage(t)
+
+	// Hold Alpha Vantage's answer until the reload is done
+	upstream, _ := url.Parse(settings().AlphaVantageBaseURL)
+	proxy := httputil.NewSingleHostReverseProxy(upstream)
+	arrived, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = server.URL })
+
+	type result struct {
+		price float64
+		err   error
+	}
+	fetched := make(chan result)
+	go func() {
+		price, err := fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+		fetched <- result{price, err}
+	}()
+	<-arrived
+
+	path := writeConfigFile(t, "config.yaml", "alpha_vantage_api_key: rotated\nalpha_vantage_base_url: http://127.0.0.1:1\nstock_providers: alphaVantage\n")
+	reloaded := make(chan error)
+	go func() {
+		_, err := reloadConfig(path, *settings())
+		reloaded <- err
+	}()
+	select {
+	case err := <-reloaded:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload waited for the request in flight")
+	}
+	assert.Equal(t, "http://127.0.0.1:1", settings().AlphaVantageBaseURL)
+
+	close(release)
+	got := <-fetched
+	assert.NoError(t, got.err)
+	assert.InDelta(t, 211.18, got.price, 1e-9)
+}
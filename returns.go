@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fetch the per-share dividends paid between two dates. Only stocks pay them, and
+// adjusted prices already fold dividends in, so nothing else needs a lookup.
+func fetchHoldingDividends(ticker, assetType, buyDate, sellDate string, opts priceOptions) ([]dividendData, error) {
+	if assetType != "stock" || opts.Adjusted {
+		return nil, nil
+	}
+	return fetchStockDividends(ticker, buyDate, sellDate, opts.Source)
+}
+
+// Calculate a holding's returns with calculateReturns. Dividends are best-effort:
+// without them only the price return is known, and the failure goes in the data
+// notes.
+func holdingReturns(data holdingData, ticker, buyDate, sellDate string, sellPrice float64, opts priceOptions) gin.H {
+	if data.DividendsErr == nil {
+		return calculateReturns(data.BuyPrice, sellPrice, data.Dividends, opts)
+	}
+	opts.Notes.unavailable("dividends", strings.ToUpper(ticker), dateOnly(buyDate)+"/"+dateOnly(sellDate), data.DividendsErr)
+	return gin.H{
+		"priceReturnPercent": (sellPrice/data.BuyPrice - 1) * 100,
+	}
+}
+
+// Calculate the price-only and total return of holding one unit from buy to sell,
+// in the asset's own currency. Income is counted as cash, not reinvested, so the
+// split between price and income is the same with or without DRIP. Adjusted prices
+// already include dividends, so only their total return is known.
+func calculateReturns(buyPrice, sellPrice float64, dividends []dividendData, opts priceOptions) gin.H {
+	if opts.Adjusted {
+		return gin.H{
+			"totalReturnPercent": (sellPrice/buyPrice - 1) * 100,
+		}
+	}
+
+	dividendsPerShare := 0.0
+	for _, dividend := range dividends {
+		dividendsPerShare += dividend.Amount
+	}
+
+	return gin.H{
+		"priceReturnPercent":  (sellPrice/buyPrice - 1) * 100,
+		"incomeReturnPercent": dividendsPerShare / buyPrice * 100,
+		"totalReturnPercent":  ((sellPrice+dividendsPerShare)/buyPrice - 1) * 100,
+		"dividendsPerShare":   dividendsPerShare,
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// One holding window of a rolling-returns analysis
+type rollingWindow struct {
+	Start             string  `json:"start"`
+	End               string  `json:"end"`
+	StartPrice        float64 `json:"startPrice"`
+	EndPrice          float64 `json:"endPrice"`
+	ReturnPercent     float64 `json:"returnPercent"`
+	AnnualizedPercent float64 `json:"annualizedPercent"`
+}
+
+// Distribution of annualized returns across rolling windows
+type rollingStats struct {
+	Count           int     `json:"count"`
+	MinPercent      float64 `json:"minPercent"`
+	P10Percent      float64 `json:"p10Percent"`
+	P25Percent      float64 `json:"p25Percent"`
+	MedianPercent   float64 `json:"medianPercent"`
+	P75Percent      float64 `json:"p75Percent"`
+	P90Percent      float64 `json:"p90Percent"`
+	MaxPercent      float64 `json:"maxPercent"`
+	MeanPercent     float64 `json:"meanPercent"`
+	PositivePercent float64 `json:"positivePercent"`
+}
+
+// Calculate the return of every holding window of the given length in months,
+// starting on each day of the series and selling on the first day on or after
+// the window's end. Windows that would end after the series are left out.
+func rollingReturns(points []pricePoint, months int) []rollingWindow {
+	windows := []rollingWindow{}
+	years := float64(months) / 12
+
+	end := 0
+	for _, start := range points {
+		day, err := time.Parse("2006-01-02", start.Date)
+		if err != nil {
+			continue
+		}
+		target := addMonthsClamped(day, months).Format("2006-01-02")
+		for end < len(points) && points[end].Date < target {
+			end++
+		}
+		if end == len(points) {
+			break
+		}
+
+		growth := points[end].Price / start.Price
+		windows = append(windows, rollingWindow{
+			Start:             start.Date,
+			End:               points[end].Date,
+			StartPrice:        start.Price,
+			EndPrice:          points[end].Price,
+			ReturnPercent:     (growth - 1) * 100,
+			AnnualizedPercent: (math.Pow(growth, 1/years) - 1) * 100,
+		})
+	}
+	return windows
+}
+
+// Helper function to read a percentile from sorted values, interpolating linearly
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// Summarize the annualized returns of rolling windows
+func summarizeRollingReturns(windows []rollingWindow) rollingStats {
+	values := make([]float64, len(windows))
+	sum, positive := 0.0, 0
+	for i, window := range windows {
+		values[i] = window.AnnualizedPercent
+		sum += window.AnnualizedPercent
+		if window.ReturnPercent > 0 {
+			positive++
+		}
+	}
+	sort.Float64s(values)
+
+	return rollingStats{
+		Count:           len(values),
+		MinPercent:      values[0],
+		P10Percent:      percentile(values, 10),
+		P25Percent:      percentile(values, 25),
+		MedianPercent:   percentile(values, 50),
+		P75Percent:      percentile(values, 75),
+		P90Percent:      percentile(values, 90),
+		MaxPercent:      values[len(values)-1],
+		MeanPercent:     sum / float64(len(values)),
+		PositivePercent: float64(positive) / float64(len(values)) * 100,
+	}
+}
+
+// Handle a rolling-returns analysis: every possible hold of ?years= (default 5)
+// across the ticker's history, with the distribution of annualized returns.
+// With ?buyDate= the window starting then is ranked against all the others.
+func handleRollingReturns(c *gin.Context) {
+	ticker := c.Param("ticker")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rolling returns are not supported for bonds or options"})
+		return
+	}
+
+	years, err := strconv.ParseFloat(c.DefaultQuery("years", "5"), 64)
+	months := int(math.Round(years * 12))
+	if err != nil || months < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid years parameter: must be at least one month (0.083)"})
+		return
+	}
+
+	points, err := fetchPriceHistory(ticker, typeParam, "", "", priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch price history", "details": err.Error()})
+		return
+	}
+
+	windows := rollingReturns(points, months)
+	if len(windows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough history for a " + strconv.FormatFloat(years, 'f', -1, 64) + "-year hold"})
+		return
+	}
+
+	best, worst := windows[0], windows[0]
+	for _, window := range windows {
+		if window.AnnualizedPercent > best.AnnualizedPercent {
+			best = window
+		}
+		if window.AnnualizedPercent < worst.AnnualizedPercent {
+			worst = window
+		}
+	}
+
+	currency := "USD"
+	if index, ok := marketIndices[strings.ToUpper(ticker)]; ok && typeParam == "index" {
+		currency = index.Currency
+	}
+
+	response := gin.H{
+		"message":      localize(c, "Rolling returns"),
+		"ticker":       ticker,
+		"type":         typeParam,
+		"years":        years,
+		"historyStart": points[0].Date,
+		"historyEnd":   points[len(points)-1].Date,
+		"stats":        summarizeRollingReturns(windows),
+		"best":         best,
+		"worst":        worst,
+		"currency":     currency,
+		"priceAt":      priceOpts.At,
+		"priceBasis":   priceOpts.basis(typeParam),
+	}
+
+	// Rank the window starting on (or just after) the user's own buy date
+	if buyDate := c.Query("buyDate"); buyDate != "" {
+		i := sort.Search(len(windows), func(i int) bool { return windows[i].Start >= buyDate })
+		if i == len(windows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No complete window starts on or after buyDate " + buyDate})
+			return
+		}
+		beaten := 0
+		for _, window := range windows {
+			if window.AnnualizedPercent < windows[i].AnnualizedPercent {
+				beaten++
+			}
+		}
+		response["yourWindow"] = windows[i]
+		response["yourPercentile"] = float64(beaten) / float64(len(windows)) * 100
+	}
+
+	c.JSON(http.StatusOK, response)
+}
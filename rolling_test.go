@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test rolling windows and their distribution
+func TestRollingReturns(t *testing.T) {
+	points := []pricePoint{
+		{Date: "2020-01-02", Price: 100},
+		{Date: "2020-07-01", Price: 90},
+		{Date: "2021-01-04", Price: 121},
+		{Date: "2021-07-01", Price: 81},
+		{Date: "2022-01-03", Price: 150},
+	}
+
+	// One-year holds sell on the first day on or after the anniversary; the hold
+	// from 2021-01-04 would end after the series
+	windows := rollingReturns(points, 12)
+	assert.Len(t, windows, 2)
+	assert.Equal(t, "2021-01-04", windows[0].End)
+	assert.InDelta(t, 21.0, windows[0].ReturnPercent, 1e-9)
+	assert.InDelta(t, -10.0, windows[1].ReturnPercent, 1e-9)
+
+	// Two-year holds annualize their return
+	windows = rollingReturns(points, 24)
+	assert.Len(t, windows, 1)
+	assert.InDelta(t, (1.5-1)*100, windows[0].ReturnPercent, 1e-9)
+	assert.InDelta(t, (1.224744871391589-1)*100, windows[0].AnnualizedPercent, 1e-9)
+
+	assert.Empty(t, rollingReturns(points, 36))
+
+	stats := summarizeRollingReturns(rollingReturns(points, 12))
+	assert.Equal(t, 2, stats.Count)
+	assert.InDelta(t, -10.0, stats.MinPercent, 1e-9)
+	assert.InDelta(t, 5.5, stats.MedianPercent, 1e-9)
+	assert.InDelta(t, 21.0, stats.MaxPercent, 1e-9)
+	assert.InDelta(t, 50.0, stats.PositivePercent, 1e-9)
+}
+
+// Test percentile interpolation
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+	assert.Equal(t, 1.0, percentile(sorted, 0))
+	assert.Equal(t, 2.5, percentile(sorted, 50))
+	assert.Equal(t, 4.0, percentile(sorted, 100))
+	assert.Equal(t, 7.0, percentile([]float64{7}, 90))
+}
+
+// Test the rolling-returns route
+func TestRollingRoute(t *testing.T) {
+	setupMockCoinGecko(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/rolling/BTC?type=crypto&years=0.25&buyDate=2025-03-01")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	stats := response["stats"].(map[string]interface{})
+	assert.Equal(t, 1.0, stats["count"])
+	assert.InDelta(t, (107000.0/82500-1)*100, response["best"].(map[string]interface{})["returnPercent"], 1e-9)
+	assert.Equal(t, "2025-03-31", response["yourWindow"].(map[string]interface{})["start"])
+	assert.Equal(t, 0.0, response["yourPercentile"])
+
+	w = makeTestRequest(router, "GET", "/rolling/BTC?type=crypto&years=5")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = makeTestRequest(router, "GET", "/rolling/BTC?type=crypto&years=0")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
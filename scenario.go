@@ -0,0 +1,665 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The asset a scenario holds, or compares against
+type scenarioAsset struct {
+	Ticker string `json:"ticker" binding:"required"`
+	Type   string `json:"type,omitempty"`
+}
+
+// A trade in a scenario. Buys give an amount as on the paths (1000EUR, 10); a sell
+// sells the whole position.
+type scenarioLeg struct {
+	Side   string `json:"side" binding:"required,oneof=buy sell"`
+	Date   string `json:"date" binding:"required"`
+	Amount string `json:"amount,omitempty"`
+}
+
+// Most buy lots a scenario takes; each fetches its own prices and dividends
+const maxScenarioLots = 20
+
+// Trading costs charged on every leg, in the scenario's currency
+type scenarioFees struct {
+	Percent float64 `json:"percent"`
+	Fixed   float64 `json:"fixed"`
+}
+
+// Taxes as flat rates: on the gain when the position is sold, and withheld from
+// each dividend when it's paid. Jurisdiction "UK" taxes the gain under the UK's
+// share pooling rules instead, at the band's ("basic" or "higher") rate, and "US"
+// at the short- or long-term rate by each lot's holding period.
+type scenarioTaxes struct {
+	CapitalGainsPercent float64  `json:"capitalGainsPercent"`
+	DividendPercent     float64  `json:"dividendPercent"`
+	Jurisdiction        string   `json:"jurisdiction,omitempty"`
+	Band                string   `json:"band,omitempty"`
+	ShortTermPercent    *float64 `json:"shortTermPercent,omitempty"`
+	LongTermPercent     *float64 `json:"longTermPercent,omitempty"`
+}
+
+// What happens to dividends: "none" (left out, as on the buy/sell paths), "cash"
+// (kept, earning depositRate) or "reinvest" (DRIP). With cashInterest, the cash
+// either leaves idle earns the CASH_RATE_SERIES rate instead.
+type scenarioDividends struct {
+	Mode         string  `json:"mode,omitempty"`
+	DepositRate  float64 `json:"depositRate"`
+	CashInterest bool    `json:"cashInterest"`
+	dripOptions          // mode reinvest: frequency, lagDays, price, feePercent, feeFixed and reinvestPercent
+}
+
+// Request body of POST /v1/scenario, the one interface taking every option at once
+type scenarioRequest struct {
+	Asset     scenarioAsset     `json:"asset" binding:"required"`
+	Legs      []scenarioLeg     `json:"legs" binding:"required,min=1,dive"`
+	Fees      scenarioFees      `json:"fees"`
+	Taxes     scenarioTaxes     `json:"taxes"`
+	Dividends scenarioDividends `json:"dividends"`
+	Benchmark *scenarioAsset    `json:"benchmark,omitempty"`
+	Currency  string            `json:"currency,omitempty"`
+	PriceAt   string            `json:"priceAt,omitempty"`
+	Adjusted  bool              `json:"adjusted"`
+	Strict    bool              `json:"strict"`
+}
+
+// Helper function to default an asset's type from its ticker, as the paths do
+func (a *scenarioAsset) resolve() error {
+	if !tickerRegex.MatchString(a.Ticker) {
+		return fmt.Errorf("%q is not a ticker", a.Ticker)
+	}
+	if a.Type == "" {
+		a.Type = "stock"
+		if isIndexTicker(a.Ticker) {
+			a.Type = "index"
+		}
+	}
+	if !isValidAssetType(a.Type) || isModelledType(a.Type) {
+		return fmt.Errorf("type of %s must be stock, crypto, index or commodity", a.Ticker)
+	}
+	return nil
+}
+
+// Helper function to check a scenario, filling in its defaults. Returns the buy
+// legs oldest first, the currency their amounts are in (USD for quantities) and
+// the sell date, the most recent close when there's no sell leg.
+func checkScenarioRequest(request *scenarioRequest) ([]scenarioLeg, string, string, error) {
+	fail := func(err error) ([]scenarioLeg, string, string, error) {
+		return nil, "", "", err
+	}
+	if err := request.Asset.resolve(); err != nil {
+		return fail(err)
+	}
+	if request.Benchmark != nil {
+		if err := request.Benchmark.resolve(); err != nil {
+			return fail(fmt.Errorf("benchmark: %v", err))
+		}
+	}
+
+	var buys, sells []scenarioLeg
+	for _, leg := range request.Legs {
+		if err := checkDateParam(leg.Side+" date", leg.Date); err != nil {
+			return fail(err)
+		}
+		if leg.Side == "buy" {
+			buys = append(buys, leg)
+		} else {
+			sells = append(sells, leg)
+		}
+	}
+	if len(buys) == 0 || len(sells) > 1 {
+		return fail(fmt.Errorf("legs must be at least one buy and at most one sell"))
+	}
+	if len(buys) > maxScenarioLots {
+		return fail(fmt.Errorf("a scenario takes at most %d buys", maxScenarioLots))
+	}
+	sort.SliceStable(buys, func(i, j int) bool { return buys[i].Date < buys[j].Date })
+
+	// Every lot is in one currency, so their costs and values add up
+	currency := ""
+	for i, buy := range buys {
+		amount, code, isValue, err := parseAmount(buy.Amount, "")
+		if err != nil || amount <= 0 {
+			return fail(fmt.Errorf("invalid buy amount %q", buy.Amount))
+		}
+		if !isValue {
+			code = "USD"
+		} else if code, err = resolveCurrency(code, ""); err != nil {
+			return fail(err)
+		}
+		if i > 0 && code != currency {
+			return fail(fmt.Errorf("every buy amount must be in the same currency (or all quantities, in USD): %s and %s", currency, code))
+		}
+		currency = code
+	}
+
+	sellDate := latestCloseDate(request.Asset.Ticker, request.Asset.Type, time.Now())
+	if len(sells) == 1 {
+		if sells[0].Amount != "" {
+			return fail(fmt.Errorf("a sell leg sells the whole position, so takes no amount"))
+		}
+		sellDate = sells[0].Date
+	}
+	if err := checkDateOrder(buys[len(buys)-1].Date, sellDate); err != nil {
+		return fail(err)
+	}
+
+	switch request.Dividends.Mode {
+	case "":
+		request.Dividends.Mode = "none"
+	case "none", "cash", "reinvest":
+	default:
+		return fail(fmt.Errorf("dividends.mode must be none, cash or reinvest"))
+	}
+	if request.Dividends.Frequency == "" {
+		request.Dividends.Frequency = "dividend"
+	}
+	if !isDRIPFrequency(request.Dividends.Frequency) {
+		return fail(fmt.Errorf("dividends.frequency must be one of %s", strings.Join(dripFrequencies, ", ")))
+	}
+	if request.Dividends.LagDays < 0 || request.Dividends.LagDays > maxDRIPLagDays {
+		return fail(fmt.Errorf("dividends.lagDays must be from 0 to %d", maxDRIPLagDays))
+	}
+	if request.Dividends.ReinvestPercent == 0 {
+		request.Dividends.ReinvestPercent = 100
+	}
+	if request.Dividends.ReinvestPercent < 0 || request.Dividends.ReinvestPercent > 100 {
+		return fail(fmt.Errorf("dividends.reinvestPercent must be above 0 and up to 100"))
+	}
+	if request.Dividends.Price != "" && !isDRIPPrice(request.Dividends.Price) {
+		return fail(fmt.Errorf("dividends.price must be one of %s", strings.Join(dripPrices, ", ")))
+	}
+	if request.Adjusted && request.Dividends.Mode != "none" {
+		return fail(fmt.Errorf("adjusted prices already include dividends; use dividends.mode none"))
+	}
+	for name, rate := range map[string]float64{
+		"fees.percent": request.Fees.Percent, "taxes.capitalGainsPercent": request.Taxes.CapitalGainsPercent,
+		"taxes.dividendPercent": request.Taxes.DividendPercent, "dividends.feePercent": request.Dividends.FeePercent,
+	} {
+		if rate < 0 || rate >= 100 {
+			return fail(fmt.Errorf("%s must be at least 0 and under 100", name))
+		}
+	}
+	if err := checkScenarioJurisdiction(&request.Taxes, sellDate); err != nil {
+		return fail(err)
+	}
+	if request.Fees.Fixed < 0 || request.Dividends.DepositRate < 0 || request.Dividends.FeeFixed < 0 {
+		return fail(fmt.Errorf("fees.fixed, dividends.depositRate and dividends.feeFixed can't be negative"))
+	}
+	if request.Dividends.CashInterest && request.Dividends.DepositRate > 0 {
+		return fail(fmt.Errorf("dividends.depositRate can't be combined with dividends.cashInterest"))
+	}
+	if request.PriceAt == "" {
+		request.PriceAt = "close"
+	}
+	if _, ok := priceAtFields[request.PriceAt]; !ok {
+		return fail(fmt.Errorf("priceAt must be open, high, low or close"))
+	}
+	return buys, currency, sellDate, nil
+}
+
+// Helper function to check a scenario's tax jurisdiction and the rates it takes,
+// filling in their defaults
+func checkScenarioJurisdiction(taxes *scenarioTaxes, sellDate string) error {
+	taxes.Jurisdiction = strings.ToUpper(taxes.Jurisdiction)
+	if taxes.Band != "" && taxes.Jurisdiction != "UK" {
+		return fmt.Errorf("taxes.band needs taxes.jurisdiction UK")
+	}
+	if (taxes.ShortTermPercent != nil || taxes.LongTermPercent != nil) && taxes.Jurisdiction != "US" {
+		return fmt.Errorf("taxes.shortTermPercent and taxes.longTermPercent need taxes.jurisdiction US")
+	}
+	if taxes.Jurisdiction != "" && taxes.CapitalGainsPercent != 0 {
+		return fmt.Errorf("taxes.capitalGainsPercent doesn't apply with taxes.jurisdiction %s, which sets its own rates", taxes.Jurisdiction)
+	}
+
+	switch taxes.Jurisdiction {
+	case "":
+	case "UK":
+		if taxes.Band == "" {
+			taxes.Band = "higher"
+		}
+		return checkUKDisposal(sellDate, taxes.Band)
+	case "US":
+		if taxes.ShortTermPercent == nil {
+			return fmt.Errorf("taxes.jurisdiction US needs taxes.shortTermPercent, the marginal income tax rate")
+		}
+		if taxes.LongTermPercent == nil {
+			longTerm := defaultUSLongTermPercent
+			taxes.LongTermPercent = &longTerm
+		}
+		for name, rate := range map[string]float64{"taxes.shortTermPercent": *taxes.ShortTermPercent, "taxes.longTermPercent": *taxes.LongTermPercent} {
+			if rate < 0 || rate >= 100 {
+				return fmt.Errorf("%s must be at least 0 and under 100", name)
+			}
+		}
+	default:
+		return fmt.Errorf("taxes.jurisdiction must be UK or US, or left out for flat rates")
+	}
+	return nil
+}
+
+// A buy lot of a scenario, valued on the sell date before the sell fee and taxes,
+// which are charged on the whole sale. Money is in the scenario's currency.
+type scenarioLot struct {
+	Date             string      `json:"date"`
+	Amount           string      `json:"amount"`
+	BuyPrice         float64     `json:"buyPrice"`
+	FXRateBuy        float64     `json:"fxRateBuy"`
+	Shares           float64     `json:"shares"`
+	ReinvestedShares float64     `json:"reinvestedShares,omitempty"`
+	BuyFee           float64     `json:"buyFee"`
+	Invested         float64     `json:"invested"`
+	CostPerShare     float64     `json:"costPerShare"`
+	Value            float64     `json:"value"`
+	Gain             float64     `json:"gain"`
+	ReturnPercent    float64     `json:"returnPercent"`
+	Dividends        interface{} `json:"dividends,omitempty"`
+
+	paid           float64 // before fees, in the scenario's currency
+	investedUSD    float64 // before fees, for the benchmark
+	proceedsUSD    float64 // shares and corporate actions at the sell date
+	incomeUSD      float64 // dividends kept as cash, with their interest
+	interestUSD    float64
+	reinvested     float64 // dividends bought back in, at their reinvestedCost
+	dividendTaxUSD float64
+	sellPrice      float64
+	fxRateSell     float64
+	dividends      []dividendData
+	reinvestments  []dripEvent
+	reinvestedCost []float64 // each reinvestment in the scenario's currency on its day, for capital gains tax
+	sharesOn       func(date string) float64
+}
+
+// Helper function to value one buy lot of a scenario: the shares it bought less
+// the buy fee, its dividends (less the tax withheld) and its corporate actions.
+// Failures are fetchFailures carrying the message to answer with.
+func runScenarioLot(request *scenarioRequest, leg scenarioLeg, currency, sellDate string, opts priceOptions) (scenarioLot, error) {
+	ticker, assetType := strings.ToUpper(request.Asset.Ticker), request.Asset.Type
+	amount, _, isValue, _ := parseAmount(leg.Amount, "")
+	if !isValue {
+		currency = ""
+	}
+	data, err := fetchHoldingData(ticker, assetType, currency, leg.Date, sellDate, opts)
+	if err == nil && request.Dividends.Mode != "none" {
+		err = data.DividendsErr // paying or reinvesting them needs them
+	}
+	if err != nil {
+		return scenarioLot{}, err
+	}
+	lot := scenarioLot{
+		Date: leg.Date, Amount: leg.Amount, BuyPrice: data.BuyPrice, FXRateBuy: data.FXRateBuy,
+		sellPrice: data.SellPrice, fxRateSell: data.FXRateSell, dividends: data.Dividends,
+	}
+
+	// Fees in the scenario's currency; for values they come out of the amount
+	// invested, for quantities they're paid on top
+	if isValue {
+		lot.investedUSD = amount * data.FXRateBuy
+		lot.BuyFee = amount*request.Fees.Percent/100 + request.Fees.Fixed
+		lot.Shares = (amount - lot.BuyFee) * data.FXRateBuy / data.BuyPrice
+		lot.Invested, lot.paid = amount, amount
+	} else {
+		lot.investedUSD = amount * data.BuyPrice
+		lot.BuyFee = lot.investedUSD*request.Fees.Percent/100 + request.Fees.Fixed
+		lot.Shares = amount
+		lot.Invested, lot.paid = lot.investedUSD+lot.BuyFee, lot.investedUSD
+	}
+	if lot.Shares <= 0 {
+		return scenarioLot{}, errBuyFeeTooHigh
+	}
+	lot.CostPerShare = lot.Invested / lot.Shares
+
+	// Dividends, less the tax withheld on each
+	withheld := 1 - request.Taxes.DividendPercent/100
+	netDividends := make([]dividendData, len(data.Dividends))
+	for i, dividend := range data.Dividends {
+		netDividends[i] = dividend
+		netDividends[i].Amount *= withheld
+	}
+	lot.sharesOn = constantShares(lot.Shares)
+	switch request.Dividends.Mode {
+	case "cash":
+		payments, income, interest, err := calculateDividendIncome(lot.Shares, netDividends, request.Dividends.DepositRate, sellDate)
+		if err != nil {
+			return scenarioLot{}, &fetchFailure{Message: "Failed to calculate dividend income", Err: err}
+		}
+		if request.Dividends.CashInterest {
+			if interest, err = accrueDividendInterest(payments, leg.Date, sellDate); err != nil {
+				return scenarioLot{}, &fetchFailure{Message: "Failed to fetch cash rates", Err: err}
+			}
+		}
+		for _, dividend := range data.Dividends {
+			lot.dividendTaxUSD += lot.Shares * dividend.Amount * request.Taxes.DividendPercent / 100
+		}
+		lot.incomeUSD, lot.interestUSD = income+interest, interest
+		lot.Dividends = payments
+	case "reinvest":
+		reinvestedShares, events, cashAfterSale, err := calculateDRIP(lot.Shares, netDividends, sellDate, request.Dividends.dripOptions, request.Dividends.priceOn(ticker, assetType, opts))
+		if err != nil {
+			return scenarioLot{}, &fetchFailure{Message: "Failed to calculate DRIP", Err: err}
+		}
+		for i, event := range events {
+			lot.dividendTaxUSD += event.SharesHeld * data.Dividends[i].Amount * request.Taxes.DividendPercent / 100
+		}
+		if request.Dividends.CashInterest {
+			if lot.interestUSD, err = accrueDRIPInterest(events, leg.Date, sellDate); err != nil {
+				return scenarioLot{}, &fetchFailure{Message: "Failed to fetch cash rates", Err: err}
+			}
+		}
+		lot.ReinvestedShares, lot.incomeUSD = reinvestedShares, cashAfterSale+lot.interestUSD
+		lot.sharesOn = dripSharesOn(lot.Shares, events)
+		lot.Dividends, lot.reinvestments = events, events
+		if lot.reinvestedCost, err = reinvestmentCosts(events, currency, opts); err != nil {
+			return scenarioLot{}, &fetchFailure{Message: "Failed to fetch FX rate for a reinvested dividend", Err: err}
+		}
+		for _, cost := range lot.reinvestedCost {
+			lot.reinvested += cost
+		}
+	}
+
+	actions, err := applyCorporateActions(ticker, assetType, leg.Date, sellDate, lot.sharesOn, opts)
+	if err != nil {
+		return scenarioLot{}, &fetchFailure{Message: "Failed to apply corporate actions", Err: err}
+	}
+	lot.proceedsUSD = (lot.Shares+lot.ReinvestedShares)*data.SellPrice + actions.Value
+
+	// Reinvested dividends count as paid in, at the rate on the day each was reinvested
+	lot.Value = (lot.proceedsUSD + lot.incomeUSD) * data.FXRateSell
+	lot.Gain = lot.Value - lot.Invested - lot.reinvested
+	lot.ReturnPercent = (lot.Value/lot.Invested - 1) * 100
+	return lot, nil
+}
+
+// Helper function to convert each reinvested dividend into a lot's currency at the
+// rate on the day its shares were bought, which is their cost for capital gains tax
+func reinvestmentCosts(events []dripEvent, currency string, opts priceOptions) ([]float64, error) {
+	costs := make([]float64, len(events))
+	for i, event := range events {
+		if event.reinvested() == 0 {
+			continue
+		}
+		rate := 1.0
+		if currency != "" {
+			var err error
+			if rate, err = opts.Notes.fxRate("USD", currency, event.reinvestedOn()); err != nil {
+				return nil, err
+			}
+		}
+		costs[i] = event.reinvested() * rate
+	}
+	return costs, nil
+}
+
+// Helper function to put every lot and reinvested dividend into one Section 104
+// pool, each at its cost in GBP on the day it was bought
+func ukSection104Pool(lots []scenarioLot, currency string, opts priceOptions) (section104Pool, error) {
+	var pool section104Pool
+	for _, lot := range lots {
+		rate, err := gbpRateOn(currency, lot.Date, opts)
+		if err != nil {
+			return pool, err
+		}
+		pool.add(lot.Shares, lot.Invested*rate)
+		for _, event := range lot.reinvestments {
+			if event.reinvested() == 0 {
+				continue
+			}
+			if rate, err = gbpRateOn("USD", event.reinvestedOn(), opts); err != nil {
+				return pool, err
+			}
+			pool.add(event.SharesBought, event.reinvested()*rate)
+		}
+	}
+	return pool, nil
+}
+
+// Returned for a buy whose fee leaves nothing to invest
+var errBuyFeeTooHigh = errors.New("the buy fee is more than the amount invested")
+
+// Helper function to add a "benchmark" block, running the same money (USD, before
+// fees) through another asset on the same dates, price only
+func addScenarioBenchmark(response gin.H, benchmark *scenarioAsset, lots []scenarioLot, fxRateSell float64, sellDate string, returnPercent float64, opts priceOptions) error {
+	if benchmark == nil {
+		return nil
+	}
+	sellPrice, err := fetchSellPrice(benchmark.Ticker, lots[0].Date, sellDate, benchmark.Type, opts)
+	if err != nil {
+		return err
+	}
+	units, investedUSD := 0.0, 0.0
+	for _, lot := range lots {
+		buyPrice, err := fetchPrice(benchmark.Ticker, lot.Date, benchmark.Type, opts)
+		if err != nil {
+			return err
+		}
+		units += lot.investedUSD / buyPrice
+		investedUSD += lot.investedUSD
+	}
+
+	finalValueUSD := units * sellPrice
+	benchmarkReturn := (finalValueUSD/investedUSD - 1) * 100
+	response["benchmark"] = gin.H{
+		"ticker":                       strings.ToUpper(benchmark.Ticker),
+		"type":                         benchmark.Type,
+		"buyPrice":                     investedUSD / units,
+		"sellPrice":                    sellPrice,
+		"units":                        units,
+		"finalValueUSD":                finalValueUSD,
+		"finalValueInOriginalCurrency": finalValueUSD * fxRateSell,
+		"returnPercent":                benchmarkReturn,
+		"excessReturnPercent":          returnPercent - benchmarkReturn,
+	}
+	return nil
+}
+
+// Run a scenario: POST /v1/scenario {"asset": {"ticker": "AAPL"}, "legs": [{"side":
+// "buy", "date": "2020-03-20", "amount": "1000EUR"}, {"side": "sell", "date":
+// "2024-03-20"}], "fees": {"percent": 0.1}, "taxes": {"capitalGainsPercent": 26},
+// "dividends": {"mode": "reinvest"}, "benchmark": {"ticker": "^GSPC"}, "currency": "CHF"}.
+// Money is in the buy amounts' currency, USD for quantities. Each buy is a lot,
+// valued on its own; fees come off each trade, dividend tax off each dividend,
+// and capital gains tax off the gain on the sale over everything paid in (buys
+// and reinvested dividends, fees included), at a flat rate or under UK rules.
+func handleScenario(c *gin.Context) {
+	var request scenarioRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scenario", "details": err.Error()})
+		return
+	}
+	buys, currency, sellDate, err := checkScenarioRequest(&request)
+	if err != nil {
+		abortWithScenarioError(c, err, request.Asset)
+		return
+	}
+	ticker, assetType := strings.ToUpper(request.Asset.Ticker), request.Asset.Type
+	reportIn := request.Currency
+	if reportIn != "" {
+		if reportIn, err = resolveCurrency(reportIn, ""); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+	}
+
+	opts := priceOptions{At: request.PriceAt, Adjusted: request.Adjusted, Lenient: !request.Strict, Notes: requestNotes(c)}
+	lots := make([]scenarioLot, len(buys))
+	for i, buy := range buys {
+		if lots[i], err = runScenarioLot(&request, buy, currency, sellDate, opts); err != nil {
+			if errors.Is(err, errBuyFeeTooHigh) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scenario", "details": fmt.Sprintf("buy on %s: %v", buy.Date, err)})
+				return
+			}
+			respondFetchFailure(c, err)
+			return
+		}
+	}
+	sellPrice, fxRateSell := lots[0].sellPrice, lots[0].fxRateSell
+
+	// Blend the lots: their cost basis, and what they came to together
+	var shares, reinvestedShares, paidUSD, paid, invested, investedUSD, buyFees, proceedsUSD, incomeUSD, interestUSD, reinvested, dividendTaxUSD, reinvestmentFeesUSD float64
+	for _, lot := range lots {
+		shares += lot.Shares
+		paidUSD += lot.Shares * lot.BuyPrice
+		reinvestedShares += lot.ReinvestedShares
+		paid += lot.paid
+		invested += lot.Invested
+		investedUSD += lot.investedUSD
+		buyFees += lot.BuyFee
+		proceedsUSD += lot.proceedsUSD
+		incomeUSD += lot.incomeUSD
+		interestUSD += lot.interestUSD
+		reinvested += lot.reinvested
+		dividendTaxUSD += lot.dividendTaxUSD
+		reinvestmentFeesUSD += dripFees(lot.reinvestments)
+	}
+	actions, err := applyCorporateActions(ticker, assetType, buys[0].Date, sellDate, func(date string) float64 {
+		held := 0.0
+		for _, lot := range lots {
+			if dateOnly(lot.Date) < date {
+				held += lot.sharesOn(date)
+			}
+		}
+		return held
+	}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+		return
+	}
+
+	// Sell, then tax the gain over what was paid in. Reinvested dividends are
+	// converted at the rate on the day each was reinvested.
+	proceeds := proceedsUSD * fxRateSell
+	sellFee := proceeds*request.Fees.Percent/100 + request.Fees.Fixed
+	taxableGain := proceeds - sellFee - invested - reinvested
+	capitalGainsTax := max(0, taxableGain) * request.Taxes.CapitalGainsPercent / 100
+	var ukTax *ukCapitalGains
+	if request.Taxes.Jurisdiction == "UK" {
+		pool, err := ukSection104Pool(lots, currency, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for UK tax", "details": err.Error()})
+			return
+		}
+		gbpRate, err := gbpRateOn(currency, sellDate, opts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for UK tax", "details": err.Error()})
+			return
+		}
+		// The gain and tax are worked out in GBP; only the tax comes back
+		result := calculateUKCapitalGains(pool, (proceeds-sellFee)*gbpRate, sellDate, request.Taxes.Band)
+		ukTax, capitalGainsTax = &result, result.Tax/gbpRate
+	}
+	var usTax *usCapitalGains
+	if request.Taxes.Jurisdiction == "US" {
+		// The sell fee comes off each lot's proceeds in proportion
+		var sales []usTaxLot
+		for _, lot := range lots {
+			lotProceeds := lot.proceedsUSD * fxRateSell
+			if proceeds > 0 {
+				lotProceeds -= sellFee * lotProceeds / proceeds
+			}
+			sales = append(sales, lot.usTaxLots(lotProceeds)...)
+		}
+		result := calculateUSCapitalGains(sales, sellDate, *request.Taxes.ShortTermPercent, *request.Taxes.LongTermPercent)
+		usTax, capitalGainsTax = &result, result.Tax
+	}
+	dividendTax := dividendTaxUSD * fxRateSell
+	finalValue := proceeds - sellFee - capitalGainsTax + incomeUSD*fxRateSell
+	returnPercent := (finalValue/invested - 1) * 100
+
+	// Reinvestment fees came out of dividends, converted at the sell date's rate
+	fees := gin.H{"buy": buyFees, "sell": sellFee, "total": buyFees + sellFee}
+	if reinvestmentFeesUSD > 0 {
+		fees["reinvestment"] = reinvestmentFeesUSD * fxRateSell
+		fees["total"] = buyFees + sellFee + reinvestmentFeesUSD*fxRateSell
+	}
+
+	response := gin.H{
+		"message":                      localize(c, "Scenario result"),
+		"ticker":                       ticker,
+		"type":                         assetType,
+		"currency":                     currency,
+		"buyDate":                      lots[0].Date,
+		"sellDate":                     sellDate,
+		"buyPrice":                     paidUSD / shares,
+		"sellPrice":                    sellPrice,
+		"fxRateBuy":                    investedUSD / paid,
+		"fxRateSell":                   fxRateSell,
+		"lots":                         lots,
+		"invested":                     invested,
+		"shares":                       shares,
+		"totalShares":                  shares + reinvestedShares,
+		"averageCost":                  invested / shares,
+		"dividendMode":                 request.Dividends.Mode,
+		"fees":                         fees,
+		"taxes":                        gin.H{"taxableGain": taxableGain, "capitalGains": capitalGainsTax, "dividends": dividendTax, "total": capitalGainsTax + dividendTax},
+		"finalValueUSD":                finalValue / fxRateSell,
+		"finalValueInOriginalCurrency": finalValue,
+		"returnPercent":                returnPercent,
+		"priceAt":                      opts.At,
+		"priceBasis":                   opts.basis(assetType),
+		"corporateActions":             actions,
+	}
+	flows := make([]cashFlow, 0, len(lots)+1)
+	for _, lot := range lots {
+		flows = append(flows, cashFlow{lot.Date, -lot.Invested})
+	}
+	addXIRR(response, append(flows, cashFlow{sellDate, finalValue}))
+	if ukTax != nil {
+		response["ukTax"] = ukTax
+	}
+	if usTax != nil {
+		response["usTax"] = usTax
+	}
+	switch request.Dividends.Mode {
+	case "cash":
+		response["dividendIncome"] = incomeUSD - interestUSD
+		response["interestEarned"] = interestUSD
+		addCashInterest(response, request.Dividends.CashInterest, interestUSD)
+	case "reinvest":
+		response["reinvestedShares"] = reinvestedShares
+		response["dividendCash"] = incomeUSD - interestUSD
+		var events []dripEvent
+		for _, lot := range lots {
+			events = append(events, lot.reinvestments...)
+		}
+		addDRIPCashKept(response, request.Dividends.dripOptions, events)
+		addCashInterest(response, request.Dividends.CashInterest, interestUSD)
+	}
+	addHedgedReturn(response, c.Query("hedged") == "true", currency, invested, finalValue, investedUSD/paid, fxRateSell)
+	if c.Query("explain") == "true" {
+		response["explain"] = explainScenario(opts.Notes, lots, explainedHolding{
+			Ticker: ticker, At: opts.At, Currency: currency, SellDate: sellDate, SellPrice: sellPrice, FXRateSell: fxRateSell,
+			SellFee: sellFee, Tax: capitalGainsTax,
+		})
+	}
+	addHoldingPeriod(response, ticker, lots[0].Date, sellDate, assetType, lots[0].dividends)
+	if err := addReportCurrency(response, reportIn, finalValue/fxRateSell, sellDate); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for report currency", "details": err.Error()})
+		return
+	}
+	if err := addScenarioBenchmark(response, request.Benchmark, lots, fxRateSell, sellDate, returnPercent, opts); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch benchmark prices", "details": err.Error()})
+		return
+	}
+	addCryptoUnits(response, ticker, assetType, currency)
+	addCryptoPricing(response, assetType)
+	addCommodityUnit(response, ticker, assetType)
+	c.JSON(http.StatusOK, response)
+}
+
+// Helper function to answer 400 for a scenario that can't be run, with the
+// FUTURE_DATE code for dates in the future
+func abortWithScenarioError(c *gin.Context, err error, asset scenarioAsset) {
+	if errors.Is(err, errFutureDate) {
+		abortWithAssetDateError(c, err, asset.Ticker, asset.Type)
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scenario", "details": err.Error()})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to post a scenario
+func postScenario(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/v1/scenario", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test fees, taxes and dividends kept as cash come off a quantity scenario
+func TestScenarioWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "aapl"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}],
+		"fees": {"percent": 1, "fixed": 5},
+		"taxes": {"capitalGainsPercent": 25, "dividendPercent": 15},
+		"dividends": {"mode": "cash"}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Ticker         string             `json:"ticker"`
+		Type           string             `json:"type"`
+		Currency       string             `json:"currency"`
+		Invested       float64            `json:"invested"`
+		Shares         float64            `json:"shares"`
+		DividendIncome float64            `json:"dividendIncome"`
+		Fees           map[string]float64 `json:"fees"`
+		Taxes          map[string]float64 `json:"taxes"`
+		FinalValue     float64            `json:"finalValueInOriginalCurrency"`
+		ReturnPercent  float64            `json:"returnPercent"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "AAPL", response.Ticker)
+	assert.Equal(t, "stock", response.Type)
+	assert.Equal(t, "USD", response.Currency)
+	assert.Equal(t, 10.0, response.Shares)
+
+	// 10 shares at 200.50 plus 1% and 5, sold at 211.18 less 1% and 5
+	assert.InDelta(t, 2030.05, response.Invested, 1e-9)
+	assert.InDelta(t, 25.05, response.Fees["buy"], 1e-9)
+	assert.InDelta(t, 26.118, response.Fees["sell"], 1e-9)
+	// Two 0.26 dividends (May and July ex-dates), 15% withheld
+	assert.InDelta(t, 4.42, response.DividendIncome, 1e-9)
+	assert.InDelta(t, 0.78, response.Taxes["dividends"], 1e-9)
+	// A quarter of the gain over what was paid in
+	assert.InDelta(t, 2111.8-26.118-2030.05, response.Taxes["taxableGain"], 1e-9)
+	assert.InDelta(t, (2111.8-26.118-2030.05)*0.25, response.Taxes["capitalGains"], 1e-9)
+	assert.InDelta(t, 2111.8-26.118-(2111.8-26.118-2030.05)*0.25+4.42, response.FinalValue, 1e-9)
+	assert.InDelta(t, (response.FinalValue/2030.05-1)*100, response.ReturnPercent, 1e-9)
+}
+
+// Test a value scenario reinvesting dividends against a benchmark
+func TestScenarioReinvestWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL", "type": "stock"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "1000USD"}, {"side": "sell", "date": "2025-07-18"}],
+		"fees": {"fixed": 10},
+		"dividends": {"mode": "reinvest"},
+		"benchmark": {"ticker": "MSFT"}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Invested         float64 `json:"invested"`
+		Shares           float64 `json:"shares"`
+		ReinvestedShares float64 `json:"reinvestedShares"`
+		TotalShares      float64 `json:"totalShares"`
+		ReturnPercent    float64 `json:"returnPercent"`
+		Benchmark        struct {
+			Ticker              string  `json:"ticker"`
+			ReturnPercent       float64 `json:"returnPercent"`
+			ExcessReturnPercent float64 `json:"excessReturnPercent"`
+		} `json:"benchmark"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 1000.0, response.Invested)
+	assert.InDelta(t, 990/200.50, response.Shares, 1e-9)
+	assert.Positive(t, response.ReinvestedShares)
+	assert.InDelta(t, response.Shares+response.ReinvestedShares, response.TotalShares, 1e-9)
+
+	// The mock prices every ticker alike, so the benchmark is AAPL's price return
+	assert.Equal(t, "MSFT", response.Benchmark.Ticker)
+	assert.InDelta(t, (211.18/200.50-1)*100, response.Benchmark.ReturnPercent, 1e-9)
+	assert.InDelta(t, response.ReturnPercent-response.Benchmark.ReturnPercent, response.Benchmark.ExcessReturnPercent, 1e-9)
+}
+
+// Test a reinvested dividend counts as paid in at the rate on the day it was
+// reinvested, with a flat capital gains rate too
+func TestScenarioReinvestedCostWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	mockUSDPerUnit["2025-05-15"] = map[string]float64{"USD": 1, "EUR": 1.13}
+	t.Cleanup(func() { delete(mockUSDPerUnit, "2025-05-15") })
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "2000EUR"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest"},
+		"taxes": {"capitalGainsPercent": 20}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Taxes struct {
+			TaxableGain float64 `json:"taxableGain"`
+		} `json:"taxes"`
+		Lots []scenarioLot `json:"lots"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// The May dividend is reinvested; July's is paid after the sale
+	shares := 2000 * 1.08 / 200.50
+	cost := shares * 0.26 / 1.13
+	proceeds := (shares + shares*0.26/211.45) * 211.18 / 1.16
+	assert.InDelta(t, proceeds-2000-cost, response.Taxes.TaxableGain, 1e-9)
+	assert.Len(t, response.Lots, 1)
+	assert.InDelta(t, response.Lots[0].Value-2000-cost, response.Lots[0].Gain, 1e-9)
+}
+
+// Test several buys are valued lot by lot and blended, oldest first
+func TestScenarioLotsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-06-20", "amount": "5"}, {"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18"}]
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		BuyDate       string  `json:"buyDate"`
+		Invested      float64 `json:"invested"`
+		Shares        float64 `json:"shares"`
+		AverageCost   float64 `json:"averageCost"`
+		ReturnPercent float64 `json:"returnPercent"`
+		Lots          []struct {
+			Date          string  `json:"date"`
+			Shares        float64 `json:"shares"`
+			Invested      float64 `json:"invested"`
+			CostPerShare  float64 `json:"costPerShare"`
+			Value         float64 `json:"value"`
+			Gain          float64 `json:"gain"`
+			ReturnPercent float64 `json:"returnPercent"`
+		} `json:"lots"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025-03-31", response.BuyDate)
+	assert.Len(t, response.Lots, 2)
+	assert.Equal(t, "2025-03-31", response.Lots[0].Date)
+	assert.Equal(t, "2025-06-20", response.Lots[1].Date)
+
+	// 10 shares at 200.50 and 5 at 205.75, all sold at 211.18
+	assert.InDelta(t, 2005.0, response.Lots[0].Invested, 1e-9)
+	assert.InDelta(t, 205.75, response.Lots[1].CostPerShare, 1e-9)
+	assert.InDelta(t, 5*211.18, response.Lots[1].Value, 1e-9)
+	assert.InDelta(t, 5*(211.18-205.75), response.Lots[1].Gain, 1e-9)
+	assert.InDelta(t, (211.18/205.75-1)*100, response.Lots[1].ReturnPercent, 1e-9)
+	assert.Equal(t, 15.0, response.Shares)
+	assert.InDelta(t, 3033.75, response.Invested, 1e-9)
+	assert.InDelta(t, 3033.75/15, response.AverageCost, 1e-9)
+	assert.InDelta(t, (15*211.18/3033.75-1)*100, response.ReturnPercent, 1e-9)
+}
+
+// Test scenarios that can't be run are answered with 400
+func TestScenarioErrors(t *testing.T) {
+	router := setupTestRouterWithMocks()
+
+	tests := map[string]string{
+		`{"legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}]}`:                                                                                                                             "Invalid scenario",
+		`{"asset": {"ticker": "AAPL"}, "legs": []}`:                                                                                                                                                     "Invalid scenario",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "hold", "date": "2025-03-31"}]}`:                                                                                                               "Invalid scenario",
+		`{"asset": {"ticker": "AAPL", "type": "bond"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}]}`:                                                                                "must be stock, crypto, index or commodity",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "ten"}]}`:                                                                                               "invalid buy amount",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "buy", "date": "2025-04-01", "amount": "10EUR"}]}`:                                      "same currency",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "sell", "date": "2025-07-18"}]}`:                                                                                                               "at least one buy",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-06-20"}, {"side": "buy", "date": "2025-07-18", "amount": "10"}]}`: "must be after buyDate",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "sell", "date": "2025-07-18", "amount": "5"}]}`:                                         "takes no amount",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-07-18", "amount": "10"}, {"side": "sell", "date": "2025-03-31"}]}`:                                                        "must be after buyDate",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2999-01-01", "amount": "10"}]}`:                                                                                                "FUTURE_DATE",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "dividends": {"mode": "spend"}}`:                                                                "dividends.mode",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "fees": {"percent": 100}}`:                                                                      "fees.percent",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "adjusted": true, "dividends": {"mode": "cash"}}`:                                               "already include dividends",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "priceAt": "noon"}`:                                                                             "priceAt",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"jurisdiction": "FR"}}`:                                                               "taxes.jurisdiction",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"band": "basic"}}`:                                                                    "taxes.band",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"jurisdiction": "UK", "band": "top"}}`:                                                "taxes.band",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"jurisdiction": "UK", "capitalGainsPercent": 20}}`:                                    "capitalGainsPercent",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"jurisdiction": "US"}}`:                                                               "shortTermPercent",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"jurisdiction": "US", "shortTermPercent": 32, "longTermPercent": 120}}`:               "taxes.longTermPercent",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}], "taxes": {"longTermPercent": 15}}`:                                                              "need taxes.jurisdiction US",
+		`{"asset": {"ticker": "AAPL"}, "legs": [{"side": "buy", "date": "2005-03-31", "amount": "10"}, {"side": "sell", "date": "2007-03-30"}], "taxes": {"jurisdiction": "UK"}}`:                       "2008/09",
+	}
+	for body, message := range tests {
+		w := postScenario(router, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), message, body)
+	}
+}
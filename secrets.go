@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Endpoints of the cloud secret stores; empty for AWS means the region's endpoint
+var (
+	gcpMetadataBaseURL        = "http://metadata.google.internal"
+	gcpSecretManagerBaseURL   = "https://secretmanager.googleapis.com"
+	awsSecretsManagerEndpoint = ""
+)
+
+// Helper function to GET or POST to a secret store and decode its JSON answer
+func fetchSecretJSON(req *http.Request, target interface{}) error {
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %.200s", req.URL.Host, resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, target)
+}
+
+// Helper function to turn a secret's JSON object into strings
+func secretValues(object map[string]interface{}) map[string]string {
+	values := map[string]string{}
+	for key, value := range object {
+		values[key] = fmt.Sprint(value)
+	}
+	return values
+}
+
+// Read a Vault KV secret (v1 or v2) at a path like "secret/data/ifyoubought"
+func fetchVaultSecret(addr, token, path string) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	var result struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := fetchSecretJSON(req, &result); err != nil {
+		return nil, err
+	}
+	// KV v2 nests the secret under data.data
+	if nested, ok := result.Data["data"].(map[string]interface{}); ok {
+		return secretValues(nested), nil
+	}
+	return secretValues(result.Data), nil
+}
+
+// Read a GCP Secret Manager secret like "projects/my-project/secrets/ifyoubought"
+// (its latest version unless one is named) holding a JSON object, using the
+// instance's service account token from the metadata server
+func fetchGCPSecret(name string) (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, gcpMetadataBaseURL+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := fetchSecretJSON(req, &token); err != nil {
+		return nil, fmt.Errorf("GCP access token: %v", err)
+	}
+
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	req, err = http.NewRequest(http.MethodGet, gcpSecretManagerBaseURL+"/v1/"+name+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	var result struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := fetchSecretJSON(req, &result); err != nil {
+		return nil, err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(result.Payload.Data)
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, fmt.Errorf("Secret %s is not a JSON object: %v", name, err)
+	}
+	return secretValues(object), nil
+}
+
+// AWS credentials, from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// Helper function to HMAC-SHA256 a message
+func hmacSHA256(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// Sign a request with AWS Signature Version 4, covering its host and every header
+// it already has
+func signAWSRequest(req *http.Request, body []byte, region, service string, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// Read an AWS Secrets Manager secret holding a JSON object
+func fetchAWSSecret(region, secretID string) (map[string]string, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for AWS Secrets Manager")
+	}
+
+	endpoint := awsSecretsManagerEndpoint
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	req, err := http.NewRequest(http.MethodPost, endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, region, "secretsmanager", creds, time.Now())
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := fetchSecretJSON(req, &result); err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(result.SecretString), &object); err != nil {
+		return nil, fmt.Errorf("Secret %s is not a JSON object: %v", secretID, err)
+	}
+	return secretValues(object), nil
+}
+
+// Fetch the secret named by SECRETS_REF from SECRETS_SOURCE
+func fetchSecrets(cfg Config) (map[string]string, error) {
+	switch cfg.SecretsSource {
+	case "vault":
+		return fetchVaultSecret(cfg.VaultAddr, cfg.VaultToken, cfg.SecretsRef)
+	case "gcp":
+		return fetchGCPSecret(cfg.SecretsRef)
+	case "aws":
+		return fetchAWSSecret(cfg.AWSRegion, cfg.SecretsRef)
+	}
+	return nil, fmt.Errorf("Unknown secrets source %q", cfg.SecretsSource)
+}
+
+// Provider credential settings a secret store may set
+var secretSettings = map[string]bool{
+	"alpha_vantage_api_key": true, "fx_fallback_api_key": true, "coingecko_api_key": true,
+	"cryptocompare_api_key": true, "coincap_api_key": true, "polygon_api_key": true,
+	"tiingo_api_key": true, "twelve_data_api_key": true, "fmp_api_key": true, "eodhd_api_key": true,
+	"alpaca_api_key_id": true, "alpaca_api_secret_key": true, "fred_api_key": true,
+}
+
+// Set provider credential settings from a secret's fields, keyed like the config
+// file ("alpha_vantage_api_key", "alpaca_api_key_id", ...). Other fields are errors.
+func overlaySecrets(cfg *Config, secrets map[string]string) error {
+	value, fields := reflect.ValueOf(cfg).Elem(), reflect.TypeOf(*cfg)
+	for key, secret := range secrets {
+		found := false
+		for i := 0; i < fields.NumField(); i++ {
+			if fields.Field(i).Tag.Get("key") == key && secretSettings[key] {
+				value.Field(i).SetString(secret)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("Secret field %q is not an API key setting", key)
+		}
+	}
+	return nil
+}
+
+// Load API keys from the configured secret store over the settings, if there is one
+func loadSecrets(cfg *Config) error {
+	if cfg.SecretsSource == "" {
+		return nil
+	}
+	secrets, err := fetchSecrets(*cfg)
+	if err != nil {
+		return fmt.Errorf("Secrets from %s: %v", cfg.SecretsSource, err)
+	}
+	return overlaySecrets(cfg, secrets)
+}
+
+// Re-read API keys from the secret store every SECRETS_REFRESH seconds, so keys
+// rotated there are picked up. Failures are logged and the current keys kept.
+func refreshSecretsPeriodically() {
+	go func() {
+		for {
+			cfg := *settings()
+			if cfg.SecretsSource == "" || cfg.SecretsRefresh <= 0 {
+				return
+			}
+			time.Sleep(time.Duration(cfg.SecretsRefresh) * time.Second)
+
+			secrets, err := fetchSecrets(cfg)
+			if err != nil {
+				log.Printf("Secrets refresh failed, keeping current keys: %v", err)
+				continue
+			}
+
+			configMu.Lock()
+			updated := *settings()
+			if err := overlaySecrets(&updated, secrets); err != nil {
+				log.Printf("Secrets refresh failed, keeping current keys: %v", err)
+			} else {
+				publishConfig(updated)
+			}
+			configMu.Unlock()
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test reading a Vault KV v2 secret
+func TestFetchVaultSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/secret/data/ifyoubought", r.URL.Path)
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"alpha_vantage_api_key": "AVKEY", "fred_api_key": "FREDKEY"}, "metadata": {"version": 3}}}`))
+	}))
+	defer server.Close()
+
+	secrets, err := fetchVaultSecret(server.URL, "s.token", "secret/data/ifyoubought")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alpha_vantage_api_key": "AVKEY", "fred_api_key": "FREDKEY"}, secrets)
+
+	_, err = fetchVaultSecret(server.URL, "wrong", "secret/data/ifyoubought")
+	assert.ErrorContains(t, err, "403")
+}
+
+// Test reading a GCP secret with the metadata server's token
+func TestFetchGCPSecret(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"alpha_vantage_api_key": "AVKEY"}`))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			w.Write([]byte(`{"access_token": "ya29.token", "expires_in": 3599}`))
+		case "/v1/projects/demo/secrets/ifyoubought/versions/latest:access":
+			assert.Equal(t, "Bearer ya29.token", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"payload": {"data": "` + payload + `"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	oldMetadata, oldSecretManager := gcpMetadataBaseURL, gcpSecretManagerBaseURL
+	gcpMetadataBaseURL, gcpSecretManagerBaseURL = server.URL, server.URL
+	defer func() { gcpMetadataBaseURL, gcpSecretManagerBaseURL = oldMetadata, oldSecretManager }()
+
+	secrets, err := fetchGCPSecret("projects/demo/secrets/ifyoubought")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"alpha_vantage_api_key": "AVKEY"}, secrets)
+}
+
+// Test Signature Version 4 against the AWS test suite's get-vanilla case
+func TestSignAWSRequest(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAWSRequest(req, nil, "us-east-1", "service", creds, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", req.Header.Get("Authorization"))
+}
+
+// Test reading an AWS Secrets Manager secret
+func TestFetchAWSSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/secretsmanager/aws4_request")
+		assert.Equal(t, "session", r.Header.Get("X-Amz-Security-Token"))
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "ifyoubought/prod", body["SecretId"])
+		w.Write([]byte(`{"Name": "ifyoubought/prod", "SecretString": "{\"coingecko_api_key\": \"CGKEY\"}"}`))
+	}))
+	defer server.Close()
+
+	oldEndpoint := awsSecretsManagerEndpoint
+	awsSecretsManagerEndpoint = server.URL
+	defer func() { awsSecretsManagerEndpoint = oldEndpoint }()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err := fetchAWSSecret("eu-west-1", "ifyoubought/prod")
+	assert.ErrorContains(t, err, "AWS_ACCESS_KEY_ID")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+	secrets, err := fetchAWSSecret("eu-west-1", "ifyoubought/prod")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"coingecko_api_key": "CGKEY"}, secrets)
+}
+
+// Test secrets can only set API keys
+func TestOverlaySecrets(t *testing.T) {
+	cfg := defaultConfig()
+	assert.NoError(t, overlaySecrets(&cfg, map[string]string{"alpha_vantage_api_key": "AVKEY", "fx_fallback_api_key": "FXKEY"}))
+	assert.Equal(t, "AVKEY", cfg.AlphaVantageAPIKey)
+	assert.Equal(t, "FXKEY", cfg.FXFallbackAPIKey)
+
+	// Alpaca's credentials don't end in _api_key
+	assert.NoError(t, overlaySecrets(&cfg, map[string]string{"alpaca_api_key_id": "ALPACAID", "alpaca_api_secret_key": "ALPACASECRET"}))
+	assert.Equal(t, "ALPACAID", cfg.AlpacaKeyID)
+	assert.Equal(t, "ALPACASECRET", cfg.AlpacaSecretKey)
+
+	assert.ErrorContains(t, overlaySecrets(&cfg, map[string]string{"alpha_vantage_base_url": "http://evil"}), "not an API key setting")
+	assert.ErrorContains(t, overlaySecrets(&cfg, map[string]string{"unknown": "x"}), "not an API key setting")
+	assert.ErrorContains(t, overlaySecrets(&cfg, map[string]string{"admin_token": "x"}), "not an API key setting")
+}
+
+// Test loading keys from Vault over the config, and the secrets settings' validation
+func TestLoadSecrets(t *testing.T) {
+	cfg := defaultConfig()
+	assert.NoError(t, loadSecrets(&cfg))
+	assert.Equal(t, "", cfg.AlphaVantageAPIKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"alpha_vantage_api_key": "FROMVAULT"}}`))
+	}))
+	defer server.Close()
+
+	cfg.AlphaVantageAPIKey = "fromenv"
+	cfg.SecretsSource, cfg.SecretsRef, cfg.VaultAddr, cfg.VaultToken = "vault", "secret/ifyoubought", server.URL, "s.token"
+	assert.NoError(t, loadSecrets(&cfg))
+	assert.Equal(t, "FROMVAULT", cfg.AlphaVantageAPIKey)
+	assert.NoError(t, cfg.validate())
+
+	cfg.VaultToken = ""
+	assert.ErrorContains(t, cfg.validate(), "vault_addr and vault_token are required")
+	cfg.SecretsSource, cfg.SecretsRef = "azure", ""
+	err := cfg.validate()
+	assert.ErrorContains(t, err, "secrets_source \"azure\" must be vault, gcp or aws")
+	assert.ErrorContains(t, err, "secrets_ref is required")
+	cfg.SecretsSource, cfg.SecretsRef = "aws", "ifyoubought/prod"
+	assert.ErrorContains(t, cfg.validate(), "aws_region is required")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTP methods the API answers; anything else gets 405 before routing
+var allowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}
+
+// Longest accepted value of each path parameter, in bytes. A buy date can be a
+// staggered buy's dates joined by '+'.
+var pathParamLimits = map[string]int{
+	"amount":    32,
+	"ticker":    24,
+	"portfolio": 512,
+	"buyDate":   maxStaggeredDates*len("2006-01-02T15:04") + maxStaggeredDates - 1,
+	"sellDate":  16,
+}
+
+// Middleware setting security headers on every response. HSTS is only sent over TLS.
+func withSecurityHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		header.Set("Cross-Origin-Opener-Policy", "same-origin")
+		if c.Request.TLS != nil {
+			header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+		}
+		c.Next()
+	}
+}
+
+// Middleware rejecting requests with a disallowed method, a URL longer than
+// maxURLBytes, a body larger than maxBodyBytes, or an overlong path parameter
+func withRequestLimits(maxURLBytes, maxBodyBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := false
+		for _, method := range allowedMethods {
+			if c.Request.Method == method {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			c.Header("Allow", strings.Join(allowedMethods, ", "))
+			c.AbortWithStatusJSON(http.StatusMethodNotAllowed, gin.H{"error": "Method " + c.Request.Method + " is not allowed"})
+			return
+		}
+
+		if int64(len(c.Request.URL.RequestURI())) > maxURLBytes {
+			c.AbortWithStatusJSON(http.StatusRequestURITooLong, gin.H{"error": fmt.Sprintf("URL is too long (max %d bytes)", maxURLBytes)})
+			return
+		}
+		if c.Request.ContentLength > maxBodyBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Request body is too large (max %d bytes)", maxBodyBytes)})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes)
+
+		for _, param := range c.Params {
+			if limit, ok := pathParamLimits[param.Key]; ok && len(param.Value) > limit {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Path parameter %s is too long (max %d characters)", param.Key, limit)})
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test security headers and request limits
+func TestSecurityMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withSecurityHeaders(), withRequestLimits(100, 10))
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) }
+	router.GET("/:amount/:ticker", ok)
+	router.POST("/post", ok)
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "/10/AAPL", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	assert.NotEmpty(t, w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	w = request("POST", "/post", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request("PATCH", "/post", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD, POST, PUT, DELETE, OPTIONS", w.Header().Get("Allow"))
+
+	w = request("GET", "/10/"+strings.Repeat("A", 25), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "ticker is too long")
+
+	w = request("GET", "/10/AAPL?"+strings.Repeat("x", 100), "")
+	assert.Equal(t, http.StatusRequestURITooLong, w.Code)
+
+	w = request("GET", "/10/AAPL", strings.Repeat("x", 11))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
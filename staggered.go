@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most buy dates a staggered buy can have
+const maxStaggeredDates = 24
+
+// One of a staggered buy's purchases
+type staggeredPurchase struct {
+	Date      string  `json:"date"`
+	Weight    float64 `json:"weight"`
+	Amount    float64 `json:"amount"` // in the amount's currency, or shares for quantities
+	AmountUSD float64 `json:"amountUSD"`
+	Price     float64 `json:"price"`
+	Shares    float64 `json:"shares"`
+	FXRate    float64 `json:"fxRate,omitempty"`
+}
+
+// Helper function to tell whether a buy date is several dates joined by '+'
+// (2020-03-20+2020-09-01+2021-02-10)
+func isStaggered(buyDate string) bool {
+	return strings.Contains(buyDate, "+")
+}
+
+// Helper function to get the last of a buy date's dates, the date itself unless
+// it's staggered
+func lastBuyDate(buyDate string) string {
+	return buyDate[strings.LastIndexByte(buyDate, '+')+1:]
+}
+
+// Helper function to tell whether a route takes a staggered buy: the buy,
+// buy/sell and and-held routes of a single asset
+func isStaggeredRoute(c *gin.Context) bool {
+	if c.Param("portfolio") != "" || c.Param("buyEnd") != "" {
+		return false
+	}
+	path := c.FullPath()
+	return strings.HasSuffix(path, "/on/:buyDate") || strings.HasSuffix(path, "/and-sold-on/:sellDate") || strings.HasSuffix(path, "/and-held")
+}
+
+// Helper function to check a staggered buy's dates: days without a time of day,
+// each after the one before
+func checkStaggeredDates(buyDate string) ([]string, error) {
+	dates := strings.Split(buyDate, "+")
+	if len(dates) > maxStaggeredDates {
+		return nil, fmt.Errorf("buyDate has %d dates: at most %d can be joined with '+'", len(dates), maxStaggeredDates)
+	}
+	for i, date := range dates {
+		if hasTimeOfDay(date) {
+			return nil, fmt.Errorf("buyDate %s: staggered buys are priced on daily prices, without a time of day", date)
+		}
+		if err := checkDateParam("buyDate", date); err != nil {
+			return nil, err
+		}
+		if i > 0 && date <= dates[i-1] {
+			return nil, fmt.Errorf("buyDate %s must be after %s: list the dates in order", date, dates[i-1])
+		}
+	}
+	return dates, nil
+}
+
+// Helper function to read ?weights= (50,30,20), one per buy date, as fractions of
+// the amount. Without it, the amount is split equally.
+func staggeredWeightsParam(c *gin.Context, count int) ([]float64, error) {
+	weights := make([]float64, count)
+	param := c.Query("weights")
+	if param == "" {
+		for i := range weights {
+			weights[i] = 1 / float64(count)
+		}
+		return weights, nil
+	}
+
+	parts := strings.Split(param, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("Invalid weights parameter: %d weights for %d buy dates", len(parts), count)
+	}
+	total := 0.0
+	for i, part := range parts {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("Invalid weights parameter: %q is not a weight above 0", part)
+		}
+		weights[i] = weight
+		total += weight
+	}
+	for i := range weights {
+		weights[i] /= total
+	}
+	return weights, nil
+}
+
+// Helper function giving the shares held before a date in a staggered buy
+func staggeredSharesOn(purchases []staggeredPurchase) func(date string) float64 {
+	return func(date string) float64 {
+		shares := 0.0
+		for _, purchase := range purchases {
+			if purchase.Date < date {
+				shares += purchase.Shares
+			}
+		}
+		return shares
+	}
+}
+
+// Backtest an amount split across several buy dates (/on/2020-03-20+2020-09-01),
+// equally or by ?weights=, as one blended position: each part buys at its date's
+// price and FX rate, and the sale (if any) sells the lot. Returns are price-only.
+func handleStaggeredBuy(c *gin.Context) {
+	ticker := c.Param("ticker")
+	buyDate := c.Param("buyDate")
+	sellDate := c.Param("sellDate")
+	typeParam := assetTypeParam(c)
+	priceOpts, err := priceOptionsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsedAmount, currency, isValue, err := parseAmount(c.Param("amount"), c.Query("locale"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+		return
+	}
+	if parsedAmount == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
+		return
+	}
+
+	if !isValidAssetType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid type parameter: must be one of " + strings.Join(assetTypes, ", ")})
+		return
+	}
+	if isModelledType(typeParam) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Staggered buys are not supported for bonds or options"})
+		return
+	}
+
+	dates, err := checkStaggeredDates(buyDate)
+	if err != nil {
+		abortWithDateError(c, err)
+		return
+	}
+	weights, err := staggeredWeightsParam(c, len(dates))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if isValue {
+		// Resolve ambiguous symbols ($, ¥, kr) to an ISO code
+		currency, err = resolveCurrency(currency, c.Query("homeCurrency"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid currency", "details": err.Error()})
+			return
+		}
+	}
+
+	purchases := make([]staggeredPurchase, len(dates))
+	shares, investedUSD := 0.0, 0.0
+	for i, date := range dates {
+		price, err := fetchPrice(ticker, date, typeParam, priceOpts)
+		if err != nil {
+			respondPriceError(c, "Failed to fetch buy price", err)
+			return
+		}
+		purchase := staggeredPurchase{Date: date, Weight: weights[i], Amount: parsedAmount * weights[i], Price: price}
+		if isValue {
+			purchase.FXRate, err = priceOpts.Notes.fxRate(currency, "USD", date)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for buy date", "details": err.Error()})
+				return
+			}
+			purchase.AmountUSD = purchase.Amount * purchase.FXRate
+			purchase.Shares = purchase.AmountUSD / price
+		} else {
+			purchase.Shares = purchase.Amount
+			purchase.AmountUSD = purchase.Amount * price
+		}
+		purchases[i] = purchase
+		shares += purchase.Shares
+		investedUSD += purchase.AmountUSD
+	}
+
+	response := gin.H{
+		"ticker":       ticker,
+		"buyDate":      buyDate,
+		"buyDates":     dates,
+		"purchases":    purchases,
+		"shares":       shares,
+		"investedUSD":  investedUSD,
+		"averagePrice": investedUSD / shares,
+		"type":         typeParam,
+		"priceAt":      priceOpts.At,
+		"priceBasis":   priceOpts.basis(typeParam),
+	}
+	if isValue {
+		response["value"] = parsedAmount
+		response["currency"] = currency
+		response["stockCurrency"] = "USD"
+	} else {
+		response["quantity"] = parsedAmount
+	}
+
+	if sellDate == "" {
+		if isValue {
+			response["message"] = localize(c, "Backtest result (staggered value buy)")
+		} else {
+			response["message"] = localize(c, "Backtest result (staggered quantity buy)")
+		}
+		addCryptoUnits(response, ticker, typeParam, currency)
+		addCommodityUnit(response, ticker, typeParam)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	last := dates[len(dates)-1]
+	sellPrice, err := fetchSellPrice(ticker, last, sellDate, typeParam, priceOpts)
+	if err != nil {
+		respondPriceError(c, "Failed to fetch sell price", err)
+		return
+	}
+
+	// Positions and cash received from spin-offs and mergers, on the shares bought so far
+	actions, err := applyCorporateActions(ticker, typeParam, dates[0], sellDate, staggeredSharesOn(purchases), priceOpts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply corporate actions", "details": err.Error()})
+		return
+	}
+	finalValueUSD := shares*sellPrice + actions.Value
+
+	response["sellDate"] = sellDate
+	response["sellPrice"] = sellPrice
+	response["corporateActions"] = actions
+
+	// Money-weighted return over the dates each part went in, in the amount's currency
+	flows := make([]cashFlow, 0, len(purchases)+1)
+	if isValue {
+		fxRateSell, err := priceOpts.Notes.fxRate("USD", currency, sellDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch FX rate for sell date", "details": err.Error()})
+			return
+		}
+		finalValue := finalValueUSD * fxRateSell
+		response["message"] = localize(c, "Backtest result (staggered value buy/sell)")
+		response["finalValueUSD"] = finalValueUSD
+		response["finalValueInOriginalCurrency"] = finalValue
+		response["fxRateSell"] = fxRateSell
+		response["returnPercent"] = (finalValue/parsedAmount - 1) * 100
+		for _, purchase := range purchases {
+			flows = append(flows, cashFlow{purchase.Date, -purchase.Amount})
+		}
+		addXIRR(response, append(flows, cashFlow{sellDate, finalValue}))
+		// The blended rate the amount went into USD at
+		addHedgedReturn(response, c.Query("hedged") == "true", currency, parsedAmount, finalValue, investedUSD/parsedAmount, fxRateSell)
+	} else {
+		response["message"] = localize(c, "Backtest result (staggered quantity buy/sell)")
+		response["finalValue"] = finalValueUSD
+		response["returnPercent"] = (finalValueUSD/investedUSD - 1) * 100
+		for _, purchase := range purchases {
+			flows = append(flows, cashFlow{purchase.Date, -purchase.AmountUSD})
+		}
+		addXIRR(response, append(flows, cashFlow{sellDate, finalValueUSD}))
+	}
+	addMood(response, c.Query("mood") == "true", investedUSD, finalValueUSD, dates[0], sellDate)
+	addCryptoUnits(response, ticker, typeParam, currency)
+	addCommodityUnit(response, ticker, typeParam)
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test staggered buy dates are checked in order and weights normalized
+func TestStaggeredDatesAndWeights(t *testing.T) {
+	dates, err := checkStaggeredDates("2025-03-31+2025-06-30")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2025-03-31", "2025-06-30"}, dates)
+	assert.Equal(t, "2025-06-30", lastBuyDate("2025-03-31+2025-06-30"))
+	assert.Equal(t, "2025-03-31", lastBuyDate("2025-03-31"))
+
+	for _, buyDate := range []string{"2025-06-30+2025-03-31", "2025-03-31+2025-03-31", "2025-03-31+2025-06-30T10:00", "2025-03-31+tomorrow"} {
+		_, err = checkStaggeredDates(buyDate)
+		assert.Error(t, err, buyDate)
+	}
+}
+
+// Test staggered buys blend their purchases into one position
+func TestStaggeredBuyWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	var response struct {
+		Purchases     []staggeredPurchase `json:"purchases"`
+		Shares        float64             `json:"shares"`
+		InvestedUSD   float64             `json:"investedUSD"`
+		AveragePrice  float64             `json:"averagePrice"`
+		FinalValueUSD float64             `json:"finalValueUSD"`
+		FinalValue    float64             `json:"finalValue"`
+		ReturnPercent float64             `json:"returnPercent"`
+		XIRRPercent   float64             `json:"xirrPercent"`
+	}
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	shares := 500/200.50 + 500/205.17
+	assert.Len(t, response.Purchases, 2)
+	assert.InDelta(t, shares, response.Shares, 1e-9)
+	assert.InDelta(t, 1000/shares, response.AveragePrice, 1e-9)
+	assert.InDelta(t, shares*211.18, response.FinalValueUSD, 1e-9)
+	flows := []cashFlow{{"2025-03-31", -500}, {"2025-06-30", -500}, {"2025-07-18", shares * 211.18}}
+	assert.InDelta(t, 0, netPresentValue(flows, response.XIRRPercent/100), 1e-6)
+
+	w = makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18?weights=3,1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 0.75, response.Purchases[0].Weight, 1e-9)
+	assert.InDelta(t, 750/200.50+250/205.17, response.Shares, 1e-9)
+
+	// Quantities split the shares, costing each date's price
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	invested := 5*200.50 + 5*205.17
+	assert.InDelta(t, invested, response.InvestedUSD, 1e-9)
+	assert.InDelta(t, 10*211.18, response.FinalValue, 1e-9)
+	assert.InDelta(t, (10*211.18/invested-1)*100, response.ReturnPercent, 1e-9)
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31+2025-06-30")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "sellPrice")
+
+	for _, path := range []string{
+		"/10/AAPL/on/2025-06-30+2025-03-31/and-sold-on/2025-07-18",
+		"/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-06-20",
+		"/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18?weights=1,2,3",
+		"/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18?weights=1,0",
+		"/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18?limitPrice=200",
+		"/10/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18/with-drip",
+		"/1000USD/of/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18/lump-sum-vs-dca",
+	} {
+		w = makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
+
+// Test staggered buy dates get through the request limits and input validation
+// main() puts in front of every route
+func TestStaggeredBuyThroughMiddleware(t *testing.T) {
+	setupMockAlphaVantage(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withRequestLimits(2048, 1<<20))
+	router.Use(withInputValidation())
+	router.GET("/:amount/of/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/2025-03-31+2025-06-30/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// As many dates as a staggered buy can have fit; any more are turned away
+	dates := make([]string, maxStaggeredDates+1)
+	for i := range dates {
+		dates[i] = "2025-03-31"
+	}
+	w = makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/"+strings.Join(dates[1:], "+")+"/and-sold-on/2025-07-18")
+	assert.NotContains(t, w.Body.String(), "too long")
+	w = makeTestRequest(router, "GET", "/1000USD/of/AAPL/on/"+strings.Join(dates, "+")+"T00:00/and-sold-on/2025-07-18")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
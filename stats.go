@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Options for the risk-adjusted stats block, read from ?stats=, ?benchmark= and
+// ?riskFreeRate= (a fixed annual percent instead of RISK_FREE_RATE_SERIES)
+type riskOptions struct {
+	Enabled       bool
+	Benchmark     string
+	RiskFreeRate  float64
+	FixedRiskFree bool
+}
+
+// Helper function to read the risk stats options
+func riskOptionsParam(c *gin.Context) (riskOptions, error) {
+	opts := riskOptions{
+		Enabled:   c.Query("stats") == "true",
+		Benchmark: c.DefaultQuery("benchmark", "^GSPC"),
+	}
+	if !tickerRegex.MatchString(opts.Benchmark) {
+		return opts, fmt.Errorf("Invalid benchmark parameter: %q is not a ticker", opts.Benchmark)
+	}
+	if rate := c.Query("riskFreeRate"); rate != "" {
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return opts, fmt.Errorf("Invalid riskFreeRate parameter: must be an annual percentage")
+		}
+		opts.RiskFreeRate, opts.FixedRiskFree = parsed, true
+	}
+	return opts, nil
+}
+
+// Risk-adjusted measures of a holding period's daily returns. Ratios that are
+// undefined (no volatility, no downside, too few benchmark days) are null.
+type riskStats struct {
+	Observations        int      `json:"observations"`
+	VolatilityPercent   float64  `json:"volatilityPercent"`
+	RiskFreeRatePercent float64  `json:"riskFreeRatePercent"`
+	RiskFreeSource      string   `json:"riskFreeSource"`
+	SharpeRatio         *float64 `json:"sharpeRatio"`
+	SortinoRatio        *float64 `json:"sortinoRatio"`
+	Benchmark           string   `json:"benchmark"`
+	Beta                *float64 `json:"beta"`
+}
+
+// Helper function to turn a price series into period returns
+func dailyReturns(points []pricePoint) []float64 {
+	returns := make([]float64, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		returns = append(returns, points[i].Price/points[i-1].Price-1)
+	}
+	return returns
+}
+
+// Calculate annualized Sharpe and Sortino ratios and volatility from daily returns,
+// given the annual risk-free rate (percent) and trading periods per year
+func sharpeSortino(returns []float64, riskFreePercent, periodsPerYear float64) (volatility float64, sharpe, sortino *float64) {
+	if len(returns) < 2 {
+		return 0, nil, nil
+	}
+	riskFree := riskFreePercent / 100 / periodsPerYear
+
+	mean, excessMean := 0.0, 0.0
+	for _, r := range returns {
+		mean += r
+		excessMean += r - riskFree
+	}
+	mean /= float64(len(returns))
+	excessMean /= float64(len(returns))
+
+	variance, downside := 0.0, 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if shortfall := r - riskFree; shortfall < 0 {
+			downside += shortfall * shortfall
+		}
+	}
+	stdDev := math.Sqrt(variance / float64(len(returns)-1))
+	downsideDev := math.Sqrt(downside / float64(len(returns)))
+
+	annualize := math.Sqrt(periodsPerYear)
+	if stdDev > 0 {
+		ratio := excessMean / stdDev * annualize
+		sharpe = &ratio
+	}
+	if downsideDev > 0 {
+		ratio := excessMean / downsideDev * annualize
+		sortino = &ratio
+	}
+	return stdDev * annualize * 100, sharpe, sortino
+}
+
+// Calculate beta of an asset against a benchmark from the days both were priced
+func beta(asset, benchmark []pricePoint) *float64 {
+	benchmarkOn := map[string]float64{}
+	for _, point := range benchmark {
+		benchmarkOn[point.Date] = point.Price
+	}
+
+	var common, commonBenchmark []pricePoint
+	for _, point := range asset {
+		if price, ok := benchmarkOn[point.Date]; ok {
+			common = append(common, point)
+			commonBenchmark = append(commonBenchmark, pricePoint{Date: point.Date, Price: price})
+		}
+	}
+
+	assetReturns, benchmarkReturns := dailyReturns(common), dailyReturns(commonBenchmark)
+	if len(assetReturns) < 2 {
+		return nil
+	}
+
+	assetMean, benchmarkMean := 0.0, 0.0
+	for i := range assetReturns {
+		assetMean += assetReturns[i]
+		benchmarkMean += benchmarkReturns[i]
+	}
+	assetMean /= float64(len(assetReturns))
+	benchmarkMean /= float64(len(benchmarkReturns))
+
+	covariance, variance := 0.0, 0.0
+	for i := range assetReturns {
+		covariance += (assetReturns[i] - assetMean) * (benchmarkReturns[i] - benchmarkMean)
+		variance += (benchmarkReturns[i] - benchmarkMean) * (benchmarkReturns[i] - benchmarkMean)
+	}
+	if variance == 0 {
+		return nil
+	}
+	result := covariance / variance
+	return &result
+}
+
+// Average a FRED rate series (annual percent) over the days of a price series,
+// using the latest published rate on each day
+func averageRiskFreeRate(seriesID string, points []pricePoint) (float64, error) {
+	start, err := time.Parse("2006-01-02", points[0].Date)
+	if err != nil {
+		return 0, err
+	}
+
+	// Start a year early so monthly and weekly series have a rate in force on the first day
+	observations, err := fetchFREDObservations(seriesID, start.AddDate(-1, 0, 0).Format("2006-01-02"), points[len(points)-1].Date)
+	if err != nil {
+		return 0, err
+	}
+	if len(observations) == 0 || observations[0].Date > points[0].Date {
+		return 0, fmt.Errorf("No %s rate in force on %s", seriesID, points[0].Date)
+	}
+
+	next, rate, total := 0, 0.0, 0.0
+	for _, point := range points[1:] {
+		for next < len(observations) && observations[next].Date <= point.Date {
+			rate = observations[next].Value
+			next++
+		}
+		total += rate
+	}
+	return total / float64(len(points)-1), nil
+}
+
+// Helper function to add a "stats" block of risk-adjusted measures computed from
+// the holding period's daily returns: volatility, Sharpe and Sortino ratios
+// against a risk-free rate, and beta against a benchmark
+func addRiskStats(response gin.H, opts riskOptions, ticker, assetType, buyDate, sellDate string, priceOpts priceOptions) error {
+	cfg := settings()
+	if !opts.Enabled {
+		return nil
+	}
+
+	points, err := fetchPriceHistory(ticker, assetType, dateOnly(buyDate), dateOnly(sellDate), priceOpts)
+	if err != nil {
+		return err
+	}
+
+	riskFree, source := opts.RiskFreeRate, "fixed"
+	if !opts.FixedRiskFree && len(points) > 1 {
+		source = cfg.RiskFreeRateSeries
+		riskFree, err = averageRiskFreeRate(cfg.RiskFreeRateSeries, points)
+		if err != nil {
+			return err
+		}
+	}
+
+	benchmarkType := "stock"
+	if isIndexTicker(opts.Benchmark) {
+		benchmarkType = "index"
+	}
+	benchmark, err := fetchPriceHistory(opts.Benchmark, benchmarkType, dateOnly(buyDate), dateOnly(sellDate), priceOpts)
+	if err != nil {
+		return fmt.Errorf("Benchmark %s: %v", opts.Benchmark, err)
+	}
+
+	// Crypto trades every day; everything else on weekdays
+	periodsPerYear := 252.0
+	if assetType == "crypto" {
+		periodsPerYear = 365
+	}
+
+	returns := dailyReturns(points)
+	volatility, sharpe, sortino := sharpeSortino(returns, riskFree, periodsPerYear)
+
+	response["stats"] = riskStats{
+		Observations:        len(returns),
+		VolatilityPercent:   volatility,
+		RiskFreeRatePercent: riskFree,
+		RiskFreeSource:      source,
+		SharpeRatio:         sharpe,
+		SortinoRatio:        sortino,
+		Benchmark:           strings.ToUpper(opts.Benchmark),
+		Beta:                beta(points, benchmark),
+	}
+	return nil
+}
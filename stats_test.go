@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test Sharpe and Sortino ratios from daily returns
+func TestSharpeSortino(t *testing.T) {
+	returns := []float64{0.01, -0.01, 0.02, 0}
+
+	volatility, sharpe, sortino := sharpeSortino(returns, 0, 252)
+	stdDev := math.Sqrt(0.0005 / 3)
+	assert.InDelta(t, stdDev*math.Sqrt(252)*100, volatility, 1e-9)
+	assert.InDelta(t, 0.005/stdDev*math.Sqrt(252), *sharpe, 1e-9)
+	assert.InDelta(t, 0.005/0.005*math.Sqrt(252), *sortino, 1e-9)
+
+	// A risk-free rate lowers both ratios
+	_, sharpeRF, _ := sharpeSortino(returns, 5, 252)
+	assert.Less(t, *sharpeRF, *sharpe)
+
+	// Returns that never fall short of the risk-free rate have no Sortino ratio
+	_, _, sortino = sharpeSortino([]float64{0.01, 0.02}, 0, 252)
+	assert.Nil(t, sortino)
+
+	_, sharpe, _ = sharpeSortino([]float64{0.01}, 0, 252)
+	assert.Nil(t, sharpe)
+}
+
+// Test beta from the days both series were priced
+func TestBeta(t *testing.T) {
+	benchmark := []pricePoint{{Date: "2025-01-02", Price: 100}, {Date: "2025-01-03", Price: 101}, {Date: "2025-01-06", Price: 99.99}, {Date: "2025-01-07", Price: 102}}
+	asset := []pricePoint{{Date: "2025-01-02", Price: 50}, {Date: "2025-01-03", Price: 51}, {Date: "2025-01-04", Price: 70}, {Date: "2025-01-06", Price: 49.98}, {Date: "2025-01-07", Price: 51.990796}}
+
+	// The asset moves twice as much as the benchmark on common days
+	result := beta(asset, benchmark)
+	assert.NotNil(t, result)
+	assert.InDelta(t, 2.0, *result, 1e-3)
+
+	assert.Nil(t, beta(asset, benchmark[:2]))
+}
+
+// Test the stats block on buy/sell routes
+func TestRiskStatsWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockStooq(t)
+	setupMockFRED(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?stats=true&riskFreeRate=4")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	stats := response["stats"].(map[string]interface{})
+	assert.Equal(t, 5.0, stats["observations"])
+	assert.Equal(t, "fixed", stats["riskFreeSource"])
+	assert.Equal(t, "^GSPC", stats["benchmark"])
+	assert.NotNil(t, stats["sharpeRatio"])
+	assert.NotNil(t, stats["beta"])
+
+	// The risk-free rate defaults to a FRED series
+	setSettings(t, func(cfg *Config) { cfg.RiskFreeRateSeries = "FEDFUNDS" })
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?stats=true")
+	assert.Equal(t, http.StatusOK, w.Code)
+	response = map[string]interface{}{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	stats = response["stats"].(map[string]interface{})
+	assert.Equal(t, "FEDFUNDS", stats["riskFreeSource"])
+	assert.Equal(t, 4.33, stats["riskFreeRatePercent"])
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?stats=true&riskFreeRate=abc")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// The benchmark goes into provider URLs, so it must be a ticker
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?stats=true&benchmark=MSFT%26apikey%3Dx")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid benchmark parameter")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A source of daily stock prices
+type stockProvider interface {
+	Name() string
+	// DailyPrice returns the open, high, low or close on a date, as opts asks
+	DailyPrice(ticker, date string, opts priceOptions) (float64, error)
+	// History returns daily prices between two dates (YYYY-MM-DD, inclusive;
+	// empty for the whole history) at opts' point of the day, in any order
+	History(ticker, start, end string, opts priceOptions) ([]pricePoint, error)
+}
+
+// Stock providers by name, and whether each has what it needs to be used. Alpha
+// Vantage's key is a required setting and Stooq needs none, so they always are.
+var stockProviderRegistry = map[string]struct {
+	Provider   stockProvider
+	Configured func() bool
+}{
+	"alphaVantage": {alphaVantageProvider{}, func() bool { return true }},
+	"polygon":      {polygonProvider{}, func() bool { return settings().PolygonAPIKey != "" }},
+	"tiingo":       {tiingoProvider{}, func() bool { return settings().TiingoAPIKey != "" }},
+	"twelveData":   {twelveDataProvider{}, func() bool { return settings().TwelveDataAPIKey != "" }},
+	"fmp":          {fmpProvider{}, func() bool { return settings().FMPAPIKey != "" }},
+	"eodhd":        {eodhdProvider{}, func() bool { return settings().EODHDAPIKey != "" }},
+	"alpaca":       {alpacaProvider{}, func() bool { return settings().AlpacaKeyID != "" && settings().AlpacaSecretKey != "" }},
+	"stooq":        {stooqProvider{}, func() bool { return true }},
+}
+
+// A stock provider that also has dividends, paid between two dates as
+// fetchHoldingDividends describes
+type dividendProvider interface {
+	Dividends(ticker, startDate, endDate string) ([]dividendData, error)
+}
+
+// A stock provider that also has company profiles
+type profileProvider interface {
+	Profile(ticker string) (stockProfile, error)
+}
+
+// Helper function to check a STOCK_PROVIDERS list names only known providers
+func checkStockProviders(list string) error {
+	names := splitList(list)
+	if len(names) == 0 {
+		return fmt.Errorf("stock_providers must name at least one provider")
+	}
+	for _, name := range names {
+		if _, ok := stockProviderRegistry[name]; !ok {
+			return fmt.Errorf("stock_providers: unknown provider %q", name)
+		}
+	}
+	return nil
+}
+
+// Helper function to find a configured stock provider by name, in any case
+func configuredStockProvider(name string) (string, bool) {
+	for known, entry := range stockProviderRegistry {
+		if strings.EqualFold(known, name) && entry.Configured() {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// Helper function to name the configured stock providers, in the order they are tried
+func configuredStockProviderNames() []string {
+	var names []string
+	for _, provider := range stockProviders("") {
+		names = append(names, provider.Name())
+	}
+	return names
+}
+
+// Helper function to list the configured stock providers in the order they are
+// tried, or just the one a request chose with ?source= (none when it chose a
+// crypto provider)
+func stockProviders(source string) []stockProvider {
+	if source != "" {
+		if entry, ok := stockProviderRegistry[source]; ok {
+			return []stockProvider{entry.Provider}
+		}
+		return nil
+	}
+	var providers []stockProvider
+	for _, name := range splitList(settings().StockProviders) {
+		if entry, ok := stockProviderRegistry[name]; ok && entry.Configured() {
+			providers = append(providers, entry.Provider)
+		}
+	}
+	return providers
+}
+
+// Helper function to reject a ?source= naming a crypto provider for a stock
+func checkStockSource(source string) error {
+	if _, ok := stockProviderRegistry[source]; source != "" && !ok {
+		return fmt.Errorf("%s has no stock prices", source)
+	}
+	return nil
+}
+
+// Helper function to combine the failures of the providers tried. One provider's
+// error is returned as it is.
+func stockProvidersFailed(failures []string, errs []error) error {
+	switch len(errs) {
+	case 0:
+		return errors.New("No stock provider is configured")
+	case 1:
+		return errs[0]
+	}
+	return errors.New(strings.Join(failures, "; "))
+}
+
+// Fetch a stock's price on a date from the first provider that has it
+func fetchStockDailyPrice(ticker, date string, opts priceOptions) (float64, priceAnswer, error) {
+	if err := checkStockSource(opts.Source); err != nil {
+		return 0, priceAnswer{}, err
+	}
+	var failures []string
+	var errs []error
+	for i, provider := range stockProviders(opts.Source) {
+		price, err := provider.DailyPrice(ticker, date, opts)
+		if err == nil {
+			return price, priceAnswer{Date: date, Provider: provider.Name(), Fallback: i > 0}, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+		errs = append(errs, err)
+	}
+	return 0, priceAnswer{}, stockProvidersFailed(failures, errs)
+}
+
+// Fetch a stock's daily history from the first provider that has any
+func fetchStockPriceHistory(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	if err := checkStockSource(opts.Source); err != nil {
+		return nil, err
+	}
+	var failures []string
+	var errs []error
+	for _, provider := range stockProviders(opts.Source) {
+		points, err := provider.History(ticker, start, end, opts)
+		if err == nil && len(points) == 0 {
+			err = fmt.Errorf("No price history for %s", ticker)
+		}
+		if err == nil {
+			return points, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+		errs = append(errs, err)
+	}
+	return nil, stockProvidersFailed(failures, errs)
+}
+
+// Fetch a stock's dividends from the first provider with dividends that answers
+func fetchStockDividends(ticker, startDate, endDate, source string) ([]dividendData, error) {
+	if err := checkStockSource(source); err != nil {
+		return nil, err
+	}
+	var failures []string
+	var errs []error
+	for _, provider := range stockProviders(source) {
+		dividends, ok := provider.(dividendProvider)
+		if !ok {
+			continue
+		}
+		events, err := dividends.Dividends(ticker, startDate, endDate)
+		if err == nil {
+			return events, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		if source == "" {
+			return nil, fmt.Errorf("No dividend data: none of STOCK_PROVIDERS has dividends")
+		}
+		return nil, fmt.Errorf("No dividend data: %s has no dividends", source)
+	}
+	return nil, stockProvidersFailed(failures, errs)
+}
+
+// Fetch a company's name, trading currency and exchange from the first provider
+// with profiles that answers
+func fetchStockProfile(ticker string) (stockProfile, error) {
+	var failures []string
+	var errs []error
+	for _, provider := range stockProviders("") {
+		profiles, ok := provider.(profileProvider)
+		if !ok {
+			continue
+		}
+		profile, err := profiles.Profile(ticker)
+		if err == nil {
+			return profile, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s: %v", provider.Name(), err))
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return stockProfile{}, errors.New("No stock provider with company profiles is configured")
+	}
+	return stockProfile{}, stockProvidersFailed(failures, errs)
+}
+
+// Alpha Vantage's daily series, the full history fetched whatever the dates
+type alphaVantageProvider struct{}
+
+func (alphaVantageProvider) Name() string { return "alphaVantage" }
+
+func (alphaVantageProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	return fetchStockDailyPriceAlphaVantage(ticker, date, opts)
+}
+
+func (alphaVantageProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	return fetchStockHistoryAlphaVantage(ticker, opts)
+}
+
+func (alphaVantageProvider) Dividends(ticker, startDate, endDate string) ([]dividendData, error) {
+	return fetchStockDividendsAlphaVantage(ticker, startDate, endDate)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stooq's market suffix for each ticker suffix it has stocks for; tickers without
+// a suffix trade in the US
+var stooqMarkets = map[string]string{
+	".L": ".uk", ".LON": ".uk", ".DE": ".de", ".DEX": ".de", ".T": ".jp", ".HK": ".hk",
+}
+
+// Returned for adjusted prices, which Stooq doesn't have
+var errStooqAdjusted = errors.New("Stooq has no split- and dividend-adjusted prices")
+
+// Helper function to get Stooq's symbol for a stock: AAPL is aapl.us, VOD.L is
+// vod.uk and BRK.B is brk-b.us
+func stooqStockSymbol(ticker string) (string, error) {
+	ticker = strings.ToLower(ticker)
+	if i := strings.LastIndex(ticker, "."); i > 0 {
+		if market, ok := stooqMarkets[strings.ToUpper(ticker[i:])]; ok {
+			return ticker[:i] + market, nil
+		}
+		if _, ok := exchangeSuffixes[strings.ToUpper(ticker[i:])]; ok {
+			return "", fmt.Errorf("Stooq has no stocks on %s", exchangeFor(ticker, "stock").Code)
+		}
+	}
+	return strings.ReplaceAll(ticker, ".", "-") + ".us", nil
+}
+
+// Stooq's free daily CSV history, needing no API key: the last resort when the
+// keyed providers are exhausted. Its stock history is split-adjusted, so before a
+// split it can differ from the as-traded prices, and it doesn't answer for prices
+// adjusted for dividends too.
+type stooqProvider struct{}
+
+func (stooqProvider) Name() string { return "stooq" }
+
+func (stooqProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	if opts.Adjusted {
+		return 0, errStooqAdjusted
+	}
+	symbol, err := stooqStockSymbol(ticker)
+	if err != nil {
+		return 0, err
+	}
+	return fetchStooqPrice(symbol, date, opts.At)
+}
+
+func (stooqProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	if opts.Adjusted {
+		return nil, errStooqAdjusted
+	}
+	symbol, err := stooqStockSymbol(ticker)
+	if err != nil {
+		return nil, err
+	}
+	return fetchStooqHistory(symbol, start, end, opts.At)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test tickers become Stooq symbols on its markets
+func TestStooqStockSymbol(t *testing.T) {
+	for ticker, want := range map[string]string{"AAPL": "aapl.us", "BRK.B": "brk-b.us", "VOD.L": "vod.uk", "SAP.DEX": "sap.de", "7203.T": "7203.jp"} {
+		symbol, err := stooqStockSymbol(ticker)
+		assert.NoError(t, err)
+		assert.Equal(t, want, symbol, ticker)
+	}
+	_, err := stooqStockSymbol("CBA.AX")
+	assert.EqualError(t, err, "Stooq has no stocks on ASX")
+}
+
+// Test Stooq prices stocks no keyed provider has, as the last resort
+func TestStooqStockFallback(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockStooq(t)
+	mockIndexData["msft.us"] = map[string]string{"2025-07-16": "505.18,506.79,499.89,505.62"}
+	t.Cleanup(func() { delete(mockIndexData, "msft.us") })
+	setStockProviderOrder(t, "alphaVantage,stooq")
+
+	price, answer, err := fetchStockDailyPrice("MSFT", "2025-07-16", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 505.62, price)
+	assert.Equal(t, priceAnswer{Date: "2025-07-16", Provider: "stooq", Fallback: true}, answer)
+
+	points, err := stooqProvider{}.History("MSFT", "", "", priceOptions{At: "open"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-07-16", Price: 505.18}}, points)
+
+	_, _, err = fetchStockDailyPrice("MSFT", "2025-07-16", priceOptions{At: "close", Adjusted: true})
+	assert.ErrorContains(t, err, "stooq: Stooq has no split- and dividend-adjusted prices")
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tax rate (percent) Australian companies pay, which franking credits pass on
+const auCompanyTaxRate = 30.0
+
+// Supported ?taxJurisdiction= values
+var taxJurisdictions = []string{"AU"}
+
+// How to tax a backtest, from ?taxJurisdiction= (off when empty), ?taxRate= (the
+// marginal income tax rate, percent) and the jurisdiction's own parameters
+type taxOptions struct {
+	Jurisdiction string
+	TaxRate      float64
+	FrankingRate float64 // AU: percent of each dividend that's franked
+}
+
+// Helper function to read a percentage query parameter between 0 and 100
+func percentParam(c *gin.Context, name string, defaultValue float64) (float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 || parsed > 100 {
+		return 0, fmt.Errorf("Invalid %s parameter: must be a percentage from 0 to 100", name)
+	}
+	return parsed, nil
+}
+
+// Helper function to read and validate the tax options
+func taxOptionsParam(c *gin.Context) (taxOptions, error) {
+	opts := taxOptions{Jurisdiction: strings.ToUpper(c.Query("taxJurisdiction"))}
+	if opts.Jurisdiction == "" {
+		return opts, nil
+	}
+	supported := false
+	for _, jurisdiction := range taxJurisdictions {
+		supported = supported || opts.Jurisdiction == jurisdiction
+	}
+	if !supported {
+		return opts, fmt.Errorf("Invalid taxJurisdiction parameter: must be one of %s", strings.Join(taxJurisdictions, ", "))
+	}
+	if c.Query("taxRate") == "" {
+		return opts, fmt.Errorf("taxJurisdiction needs taxRate, the marginal income tax rate (percent)")
+	}
+
+	var err error
+	if opts.TaxRate, err = percentParam(c, "taxRate", 0); err != nil {
+		return opts, err
+	}
+	if opts.FrankingRate, err = percentParam(c, "frankingRate", 100); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// A position to tax on the sell date, in USD. Reinvested dividends add to the
+// cost base; proceeds are what the shares (and corporate actions) sold for, and
+// the final value is everything the position came to before tax.
+type taxedPosition struct {
+	BuyDate       string
+	SellDate      string
+	CostUSD       float64
+	ReinvestedUSD float64
+	ProceedsUSD   float64
+	FinalValueUSD float64
+	Dividends     []incomePayment
+	InterestUSD   float64
+}
+
+// The taxes on a position and what it came to after them, in USD. Tax on
+// dividends is negative when franking credits exceed it and are refunded.
+type afterTax struct {
+	Jurisdiction          string  `json:"jurisdiction"`
+	TaxRatePercent        float64 `json:"taxRatePercent"`
+	DividendsUSD          float64 `json:"dividendsUSD"`
+	FrankingCreditsUSD    float64 `json:"frankingCreditsUSD,omitempty"`
+	GrossedUpDividendsUSD float64 `json:"grossedUpDividendsUSD,omitempty"`
+	DividendTaxUSD        float64 `json:"dividendTaxUSD"`
+	InterestTaxUSD        float64 `json:"interestTaxUSD"`
+	CapitalGainUSD        float64 `json:"capitalGainUSD"`
+	CGTDiscountPercent    float64 `json:"cgtDiscountPercent"`
+	TaxableGainUSD        float64 `json:"taxableGainUSD"`
+	CapitalGainsTaxUSD    float64 `json:"capitalGainsTaxUSD"`
+	TotalTaxUSD           float64 `json:"totalTaxUSD"`
+	FinalValueUSD         float64 `json:"finalValueUSD"`
+	ReturnPercent         float64 `json:"returnPercent"`
+}
+
+// Helper function to tell whether a position was held for more than a year
+func heldOverAYear(buyDate, sellDate string) bool {
+	buyDay, _ := time.Parse("2006-01-02", dateOnly(buyDate))
+	return dateOnly(sellDate) > buyDay.AddDate(1, 0, 0).Format("2006-01-02")
+}
+
+// Tax a position in Australia. Franked dividends are grossed up by their franking
+// credits and taxed at the marginal rate, with the credits offsetting the tax (and
+// refunded beyond it). Capital gains on assets held more than 12 months are
+// discounted by half, then taxed at the marginal rate; losses aren't offset.
+func calculateAUTax(position taxedPosition, opts taxOptions) afterTax {
+	result := afterTax{Jurisdiction: "AU", TaxRatePercent: opts.TaxRate}
+	rate := opts.TaxRate / 100
+	for _, dividend := range position.Dividends {
+		result.DividendsUSD += dividend.Amount
+	}
+	result.FrankingCreditsUSD = result.DividendsUSD * opts.FrankingRate / 100 * auCompanyTaxRate / (100 - auCompanyTaxRate)
+	result.GrossedUpDividendsUSD = result.DividendsUSD + result.FrankingCreditsUSD
+	result.DividendTaxUSD = result.GrossedUpDividendsUSD*rate - result.FrankingCreditsUSD
+	result.InterestTaxUSD = position.InterestUSD * rate
+
+	result.CapitalGainUSD = position.ProceedsUSD - position.CostUSD - position.ReinvestedUSD
+	if heldOverAYear(position.BuyDate, position.SellDate) {
+		result.CGTDiscountPercent = 50
+	}
+	result.TaxableGainUSD = math.Max(0, result.CapitalGainUSD) * (1 - result.CGTDiscountPercent/100)
+	result.CapitalGainsTaxUSD = result.TaxableGainUSD * rate
+	return result
+}
+
+// Add an "afterTax" block taxing a position under ?taxJurisdiction=, if given
+func addAfterTax(response gin.H, opts taxOptions, position taxedPosition) {
+	var result afterTax
+	switch opts.Jurisdiction {
+	case "AU":
+		result = calculateAUTax(position, opts)
+	default:
+		return
+	}
+	result.TotalTaxUSD = result.DividendTaxUSD + result.InterestTaxUSD + result.CapitalGainsTaxUSD
+	result.FinalValueUSD = position.FinalValueUSD - result.TotalTaxUSD
+	result.ReturnPercent = (result.FinalValueUSD/position.CostUSD - 1) * 100
+	response["afterTax"] = result
+}
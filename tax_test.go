@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test franking credits gross up dividends and offset the tax on them, and gains
+// held over a year are halved
+func TestCalculateAUTax(t *testing.T) {
+	position := taxedPosition{
+		BuyDate: "2023-01-03", SellDate: "2024-01-04", CostUSD: 1000, ProceedsUSD: 1400, FinalValueUSD: 1470,
+		Dividends: []incomePayment{{ExDate: "2023-06-01", Amount: 70}},
+	}
+	result := calculateAUTax(position, taxOptions{Jurisdiction: "AU", TaxRate: 37, FrankingRate: 100})
+	assert.InDelta(t, 30, result.FrankingCreditsUSD, 1e-9)
+	assert.InDelta(t, 100, result.GrossedUpDividendsUSD, 1e-9)
+	assert.InDelta(t, 7, result.DividendTaxUSD, 1e-9)
+	assert.Equal(t, 50.0, result.CGTDiscountPercent)
+	assert.InDelta(t, 200, result.TaxableGainUSD, 1e-9)
+	assert.InDelta(t, 74, result.CapitalGainsTaxUSD, 1e-9)
+
+	// Credits beyond the tax are refunded; half-franked dividends carry half
+	result = calculateAUTax(position, taxOptions{Jurisdiction: "AU", TaxRate: 0, FrankingRate: 50})
+	assert.InDelta(t, -15, result.DividendTaxUSD, 1e-9)
+
+	// A year to the day isn't more than 12 months, and losses aren't taxed
+	position.SellDate, position.ProceedsUSD = "2024-01-03", 900
+	result = calculateAUTax(position, taxOptions{Jurisdiction: "AU", TaxRate: 37, FrankingRate: 100})
+	assert.Zero(t, result.CGTDiscountPercent)
+	assert.InDelta(t, -100, result.CapitalGainUSD, 1e-9)
+	assert.Zero(t, result.CapitalGainsTaxUSD)
+}
+
+// Test ?taxJurisdiction=AU adds the after-tax result to a dividends backtest
+func TestAfterTaxWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?taxJurisdiction=au&taxRate=30&frankingRate=0")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		FinalValue float64  `json:"finalValue"`
+		AfterTax   afterTax `json:"afterTax"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "AU", response.AfterTax.Jurisdiction)
+	assert.InDelta(t, 5.2, response.AfterTax.DividendsUSD, 1e-9)
+	assert.Zero(t, response.AfterTax.FrankingCreditsUSD)
+	assert.InDelta(t, 1.56, response.AfterTax.DividendTaxUSD, 1e-9)
+	assert.InDelta(t, 10*(211.18-200.50), response.AfterTax.CapitalGainUSD, 1e-9)
+	assert.InDelta(t, 10*(211.18-200.50)*0.3, response.AfterTax.CapitalGainsTaxUSD, 1e-9)
+	assert.InDelta(t, response.FinalValue-response.AfterTax.TotalTaxUSD, response.AfterTax.FinalValueUSD, 1e-9)
+
+	// Without a jurisdiction there's no after-tax result
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18")
+	assert.NotContains(t, w.Body.String(), "afterTax")
+}
+
+// Test unknown jurisdictions and bad rates are answered with 400
+func TestAfterTaxErrors(t *testing.T) {
+	router := setupTestRouterWithMocks()
+
+	tests := map[string]string{
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?taxJurisdiction=XX&taxRate=30":                                "Invalid taxJurisdiction",
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18?taxJurisdiction=AU":                                           "needs taxRate",
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-drip?taxJurisdiction=AU&taxRate=120":                     "Invalid taxRate",
+		"/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends?taxJurisdiction=AU&taxRate=30&frankingRate=-1": "Invalid frankingRate",
+	}
+	for path, message := range tests {
+		w := makeTestRequest(router, "GET", path)
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), message, path)
+	}
+}
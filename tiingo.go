@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// A Tiingo end-of-day price, as traded and adjusted for splits and dividends, with
+// the dividend going ex that day
+// Example: https://api.tiingo.com/tiingo/daily/AAPL/prices?startDate=2025-07-18&endDate=2025-07-18
+type tiingoPrice struct {
+	Date     string  `json:"date"`
+	Open     float64 `json:"open"`
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Close    float64 `json:"close"`
+	AdjOpen  float64 `json:"adjOpen"`
+	AdjHigh  float64 `json:"adjHigh"`
+	AdjLow   float64 `json:"adjLow"`
+	AdjClose float64 `json:"adjClose"`
+	DivCash  float64 `json:"divCash"`
+}
+
+// Helper function to pick a Tiingo price's open, high, low or close
+func (p tiingoPrice) at(priceAt string, adjusted bool) float64 {
+	prices := map[string][2]float64{
+		"open":  {p.Open, p.AdjOpen},
+		"high":  {p.High, p.AdjHigh},
+		"low":   {p.Low, p.AdjLow},
+		"close": {p.Close, p.AdjClose},
+	}[priceAt]
+	if adjusted {
+		return prices[1]
+	}
+	return prices[0]
+}
+
+// Tiingo's end-of-day prices for US and Chinese stocks, ETFs and mutual funds,
+// with adjusted prices and dividends from the same series
+type tiingoProvider struct{}
+
+func (tiingoProvider) Name() string { return "tiingo" }
+
+// Fetch Tiingo's end-of-day prices between two dates (or its whole history when
+// start is empty), oldest first
+func fetchTiingoPrices(ticker, start, end string) ([]tiingoPrice, error) {
+	cfg := settings()
+	query := url.Values{"startDate": {start}, "endDate": {end}}
+	if start == "" {
+		query.Set("startDate", "1900-01-01")
+	}
+	if end == "" {
+		query.Del("endDate")
+	}
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/tiingo/daily/%s/prices?%s", cfg.TiingoBaseURL, url.PathEscape(ticker), query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+cfg.TiingoAPIKey)
+	resp, err := outboundClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Detail string `json:"detail"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return nil, fmt.Errorf("Tiingo returned status %d %s", resp.StatusCode, failure.Detail)
+	}
+
+	var prices []tiingoPrice
+	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	for i := range prices {
+		prices[i].Date = dateOnly(prices[i].Date) // 2025-07-18T00:00:00.000Z
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Date < prices[j].Date })
+	return prices, nil
+}
+
+func (tiingoProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	prices, err := fetchTiingoPrices(ticker, date, date)
+	if err != nil {
+		return 0, err
+	}
+	for _, price := range prices {
+		if price.Date == date {
+			return price.at(opts.At, opts.Adjusted), nil
+		}
+	}
+	return 0, fmt.Errorf("No data for date %s", date)
+}
+
+func (tiingoProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	prices, err := fetchTiingoPrices(ticker, start, end)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(prices))
+	for _, price := range prices {
+		points = append(points, pricePoint{Date: price.Date, Price: price.at(opts.At, opts.Adjusted)})
+	}
+	return points, nil
+}
+
+// Tiingo only has dividends' ex-dates, so each is taken as paid on its ex-date
+func (tiingoProvider) Dividends(ticker, startDate, endDate string) ([]dividendData, error) {
+	start, end := dateOnly(startDate), dateOnly(endDate)
+	if today := time.Now().UTC().Format("2006-01-02"); end > today {
+		end = today
+	}
+	prices, err := fetchTiingoPrices(ticker, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var dividends []dividendData
+	for _, price := range prices {
+		if price.DivCash > 0 && price.Date > start && price.Date <= end {
+			dividends = append(dividends, dividendData{ExDate: price.Date, PaymentDate: price.Date, Amount: price.DivCash})
+		}
+	}
+	return dividends, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Tiingo end-of-day API for AAPL with mockStockData's closes, each
+// day opening 1 below its close, adjusted to 99% and paying mockDividends on
+// their ex-dates. Other tickers aren't found.
+func setupMockTiingo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"detail": "Invalid token."}`)
+			return
+		}
+		if r.URL.Path != "/tiingo/daily/AAPL/prices" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"detail": "Error: Ticker 'NOPE' not found"}`)
+			return
+		}
+		start, end := r.URL.Query().Get("startDate"), r.URL.Query().Get("endDate")
+
+		dividends := map[string]float64{}
+		for _, event := range mockDividends {
+			var amount float64
+			fmt.Sscan(event["amount"], &amount)
+			dividends[event["ex_dividend_date"]] = amount
+		}
+		dates := map[string]bool{}
+		for date := range mockStockData {
+			dates[date] = true
+		}
+		for date := range dividends {
+			dates[date] = true
+		}
+
+		prices := []tiingoPrice{}
+		for date := range dates {
+			if date < start || (end != "" && date > end) {
+				continue
+			}
+			price := 200.0
+			if day, ok := mockStockData[date]; ok {
+				fmt.Sscan(day["4. close"], &price)
+			}
+			prices = append(prices, tiingoPrice{
+				Date: date + "T00:00:00.000Z", Open: price - 1, High: price + 1, Low: price - 2, Close: price,
+				AdjOpen: (price - 1) * 0.99, AdjHigh: (price + 1) * 0.99, AdjLow: (price - 2) * 0.99, AdjClose: price * 0.99,
+				DivCash: dividends[date],
+			})
+		}
+		json.NewEncoder(w).Encode(prices)
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.TiingoBaseURL, cfg.TiingoAPIKey = server.URL, "test-key" })
+}
+
+// Test Tiingo's prices, as traded and adjusted
+func TestTiingoDailyPrice(t *testing.T) {
+	setupMockTiingo(t)
+
+	price, err := tiingoProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+	price, err = tiingoProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.InDelta(t, 210.18*0.99, price, 1e-9)
+
+	_, err = tiingoProvider{}.DailyPrice("AAPL", "2025-07-19", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-19")
+	_, err = tiingoProvider{}.DailyPrice("NOPE", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Tiingo returned status 404 Error: Ticker 'NOPE' not found")
+
+	setSettings(t, func(cfg *Config) { cfg.TiingoAPIKey = "wrong" })
+	_, err = tiingoProvider{}.DailyPrice("AAPL", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Tiingo returned status 401 Invalid token.")
+}
+
+// Test Tiingo's dividends are those going ex after the buy date, up to the sale
+func TestTiingoDividends(t *testing.T) {
+	setupMockTiingo(t)
+
+	dividends, err := tiingoProvider{}.Dividends("AAPL", "2025-02-10", "2025-07-18")
+	assert.NoError(t, err)
+	assert.Equal(t, []dividendData{
+		{ExDate: "2025-05-12", PaymentDate: "2025-05-12", Amount: 0.26},
+		{ExDate: "2025-07-14", PaymentDate: "2025-07-14", Amount: 0.26},
+	}, dividends)
+}
+
+// Test ?source= prices a request from the stock provider it names, which must be
+// configured
+func TestStockSourceParam(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	setupMockTiingo(t)
+	setStockProviderOrder(t, "alphaVantage,polygon,tiingo")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation(), withDataNotes())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", handleAmountBuySell)
+
+	w := makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?adjusted=true&source=Tiingo")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		BuyPrice  float64 `json:"buyPrice"`
+		SellPrice float64 `json:"sellPrice"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 200.50*0.99, response.BuyPrice, 1e-9)
+	assert.InDelta(t, 211.18*0.99, response.SellPrice, 1e-9)
+
+	// Without it, Alpha Vantage answers from its own cached prices
+	w = makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?adjusted=true")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 200.50*199.50/200.50, response.BuyPrice, 1e-9)
+
+	// Polygon has no key here
+	w = makeTestRequest(router, "GET", "/1000/AAPL/on/2025-03-31/and-sold-on/2025-07-18?source=polygon")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "must be a configured provider (alphaVantage, tiingo, coinGecko")
+
+	// Tiingo's dividends are used with its prices
+	dividends, err := fetchHoldingDividends("AAPL", "stock", "2025-03-31", "2025-07-18", priceOptions{Source: "tiingo"})
+	assert.NoError(t, err)
+	assert.Len(t, dividends, 2)
+	assert.Equal(t, "2025-05-12", dividends[0].PaymentDate)
+
+	_, _, err = fetchIntradayPrice("AAPL", "2025-07-18T10:30", "stock", priceOptions{At: "close", Source: "tiingo"})
+	assert.ErrorContains(t, err, "only come from alphaVantage")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Helper function to check whether the server terminates TLS itself
+func (cfg Config) servesTLS() bool {
+	return cfg.TLSCertFile != "" || cfg.TLSAutocertDomains != ""
+}
+
+// Handler redirecting plain HTTP requests to the same URL over HTTPS on httpsPort
+func redirectToHTTPS(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Set the server up to serve HTTPS (and HTTP/2) from TLS_CERT_FILE and TLS_KEY_FILE,
+// or from Let's Encrypt certificates for TLS_AUTOCERT_DOMAINS. Returns the handler
+// for plain HTTP: a redirect to HTTPS, which also answers ACME challenges for autocert.
+func configureTLS(server *http.Server, cfg Config) (http.Handler, error) {
+	redirect := redirectToHTTPS(cfg.Port)
+
+	if cfg.TLSAutocertDomains != "" {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(splitList(cfg.TLSAutocertDomains)...),
+			Cache:      autocert.DirCache(cfg.TLSAutocertCacheDir),
+			Email:      cfg.TLSAutocertEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		server.TLSConfig.MinVersion = tls.VersionTLS12
+		return manager.HTTPHandler(redirect), nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("TLS certificate: %v", err)
+	}
+	server.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	return redirect, nil
+}
+
+// Serve plain HTTP, or HTTPS with HTTP/2 when TLS is configured, on every listener
+// (see openListeners). With TLS, HTTP_REDIRECT_PORT (if set) redirects plain HTTP to it.
+func listenAndServe(server *http.Server, cfg Config) error {
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		return err
+	}
+
+	if cfg.servesTLS() {
+		redirect, err := configureTLS(server, cfg)
+		if err != nil {
+			return err
+		}
+		if cfg.HTTPRedirectPort != 0 {
+			redirectServer := &http.Server{
+				Addr:              ":" + strconv.Itoa(cfg.HTTPRedirectPort),
+				Handler:           redirect,
+				ReadHeaderTimeout: server.ReadHeaderTimeout,
+				IdleTimeout:       server.IdleTimeout,
+			}
+			go func() {
+				log.Fatal(redirectServer.ListenAndServe())
+			}()
+		}
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		log.Printf("Listening on %s", listener.Addr())
+		go func(listener net.Listener) {
+			if cfg.servesTLS() {
+				errs <- server.ServeTLS(listener, "", "")
+			} else {
+				errs <- server.Serve(listener)
+			}
+		}(listener)
+	}
+	return <-errs
+}
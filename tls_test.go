@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to write a self-signed certificate and key for 127.0.0.1
+func writeTestCertificate(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// Test serving HTTPS over HTTP/2 from certificate files
+func TestConfigureTLS(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.TLSCertFile, cfg.TLSKeyFile = writeTestCertificate(t)
+
+	server := &http.Server{}
+	_, err := configureTLS(server, cfg)
+	assert.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	ts.TLS = server.TLSConfig
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, ForceAttemptHTTP2: true}}
+	resp, err := client.Get(ts.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	cfg.TLSKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = configureTLS(&http.Server{}, cfg)
+	assert.ErrorContains(t, err, "TLS certificate")
+}
+
+// Test plain HTTP requests are redirected to HTTPS
+func TestRedirectToHTTPS(t *testing.T) {
+	w := httptest.NewRecorder()
+	redirectToHTTPS(443).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.com:80/10/AAPL/on/2020-01-01?lang=de", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "https://api.example.com/10/AAPL/on/2020-01-01?lang=de", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	redirectToHTTPS(8443).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://api.example.com/", nil))
+	assert.Equal(t, "https://api.example.com:8443/", w.Header().Get("Location"))
+}
+
+// Test TLS settings are checked together
+func TestValidateTLS(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.AlphaVantageAPIKey = "demo"
+	cfg.TLSCertFile = "cert.pem"
+	assert.ErrorContains(t, cfg.validate(), "tls_cert_file and tls_key_file must be set together")
+
+	cfg.TLSKeyFile, cfg.TLSAutocertDomains = "key.pem", "api.example.com"
+	assert.ErrorContains(t, cfg.validate(), "can't both be set")
+
+	cfg.TLSCertFile, cfg.TLSKeyFile, cfg.Port, cfg.HTTPRedirectPort = "", "", 443, 80
+	assert.NoError(t, cfg.validate())
+
+	cfg.TLSAutocertDomains = ""
+	assert.ErrorContains(t, cfg.validate(), "http_redirect_port needs tls_cert_file or tls_autocert_domains")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Twelve Data time series response struct. Errors come back as status "error",
+// often with HTTP 200.
+// Example: https://api.twelvedata.com/time_series?symbol=VOD&mic_code=XLON&interval=1day&start_date=2025-07-18&end_date=2025-07-19&adjust=none&apikey=demo
+type twelveDataTimeSeriesResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Values  []struct {
+		Datetime string `json:"datetime"`
+		Open     string `json:"open"`
+		High     string `json:"high"`
+		Low      string `json:"low"`
+		Close    string `json:"close"`
+	} `json:"values"`
+}
+
+// Twelve Data's market (MIC) for each ticker suffix, so symbols listed in several
+// places are priced where the suffix says
+var twelveDataMICs = map[string]string{
+	".T": "XJPX", ".L": "XLON", ".LON": "XLON", ".DE": "XETR", ".DEX": "XETR",
+	".PA": "XPAR", ".AS": "XAMS", ".SW": "XSWX", ".TO": "XTSE", ".TRT": "XTSE", ".V": "XTSX",
+	".AX": "XASX", ".HK": "XHKG", ".SS": "XSHG", ".SHH": "XSHG", ".SZ": "XSHE", ".SHZ": "XSHE",
+	".NS": "XNSE", ".BO": "XBOM", ".BSE": "XBOM", ".KS": "XKRX", ".KQ": "XKOS",
+}
+
+// Helper function to split a ticker into Twelve Data's symbol and market:
+// VOD.L is VOD on XLON. Tickers without a known suffix are left to Twelve Data.
+func twelveDataSymbol(ticker string) (string, string) {
+	ticker = strings.ToUpper(ticker)
+	if i := strings.LastIndex(ticker, "."); i > 0 {
+		if mic, ok := twelveDataMICs[ticker[i:]]; ok {
+			return ticker[:i], mic
+		}
+	}
+	return ticker, ""
+}
+
+// Twelve Data's daily time series, covering stocks and ETFs on exchanges around
+// the world, as traded or adjusted for splits and dividends
+type twelveDataProvider struct{}
+
+func (twelveDataProvider) Name() string { return "twelveData" }
+
+// Fetch daily bars between two dates (or the whole history when start is empty)
+// as a series. Twelve Data's end date is exclusive, so the day after is asked for.
+func fetchTwelveDataSeries(ticker, start, end string, adjusted bool) (dailySeries, error) {
+	cfg := settings()
+	symbol, mic := twelveDataSymbol(ticker)
+	query := url.Values{
+		"symbol":     {symbol},
+		"interval":   {"1day"},
+		"outputsize": {"5000"},
+		"order":      {"ASC"},
+		"adjust":     {"none"},
+		"apikey":     {cfg.TwelveDataAPIKey},
+	}
+	if mic != "" {
+		query.Set("mic_code", mic)
+	}
+	if adjusted {
+		query.Set("adjust", "all")
+	}
+	if start != "" {
+		query.Set("start_date", start)
+	}
+	if end != "" {
+		day, err := time.Parse("2006-01-02", end)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("end_date", day.AddDate(0, 0, 1).Format("2006-01-02"))
+	}
+
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/time_series?%s", cfg.TwelveDataBaseURL, query.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result twelveDataTimeSeriesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Status == "error" {
+		return nil, fmt.Errorf("Twelve Data returned status %d %s", resp.StatusCode, result.Message)
+	}
+
+	series := dailySeries{}
+	for _, value := range result.Values {
+		date := dateOnly(strings.Replace(value.Datetime, " ", "T", 1))
+		series[date] = map[string]float64{}
+		for at, field := range map[string]string{"open": value.Open, "high": value.High, "low": value.Low, "close": value.Close} {
+			if price, err := strconv.ParseFloat(field, 64); err == nil {
+				series[date][at] = price
+			}
+		}
+	}
+	return series, nil
+}
+
+func (twelveDataProvider) DailyPrice(ticker, date string, opts priceOptions) (float64, error) {
+	series, err := fetchTwelveDataSeries(ticker, date, date, opts.Adjusted)
+	if err != nil {
+		return 0, err
+	}
+	price, ok := series[date][opts.At]
+	if !ok {
+		return 0, fmt.Errorf("No data for date %s", date)
+	}
+	return price, nil
+}
+
+func (twelveDataProvider) History(ticker, start, end string, opts priceOptions) ([]pricePoint, error) {
+	series, err := fetchTwelveDataSeries(ticker, start, end, opts.Adjusted)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]pricePoint, 0, len(series))
+	for date, day := range series {
+		if price, ok := day[opts.At]; ok {
+			points = append(points, pricePoint{Date: date, Price: price})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Date < points[j].Date })
+	return points, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Start a fake Twelve Data time series API for VOD on the London Stock Exchange:
+// 70 pence on 2025-07-17 and 71 on 2025-07-18, both 1 lower adjusted. Its end
+// date is exclusive, like Twelve Data's. Returns the last query.
+func setupMockTwelveData(t *testing.T) *map[string]string {
+	last := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for key := range query {
+			last[key] = query.Get(key)
+		}
+		if query.Get("apikey") != "test-key" {
+			fmt.Fprint(w, `{"code": 401, "message": "**apikey** parameter is incorrect or not specified.", "status": "error"}`)
+			return
+		}
+		if query.Get("symbol") != "VOD" || query.Get("mic_code") != "XLON" {
+			fmt.Fprint(w, `{"code": 404, "message": "**symbol** not found", "status": "error"}`)
+			return
+		}
+		offset := 0.0
+		if query.Get("adjust") == "all" {
+			offset = 1
+		}
+		values := []map[string]string{}
+		for _, day := range []struct {
+			Date  string
+			Price float64
+		}{{"2025-07-17", 70}, {"2025-07-18", 71}} {
+			if day.Date >= query.Get("start_date") && (query.Get("end_date") == "" || day.Date < query.Get("end_date")) {
+				price := day.Price - offset
+				values = append(values, map[string]string{
+					"datetime": day.Date, "open": fmt.Sprint(price - 0.5), "high": fmt.Sprint(price + 1),
+					"low": fmt.Sprint(price - 1), "close": fmt.Sprint(price),
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"meta": map[string]string{"symbol": "VOD", "mic_code": "XLON"}, "values": values, "status": "ok"})
+	}))
+	t.Cleanup(server.Close)
+
+	setSettings(t, func(cfg *Config) { cfg.TwelveDataBaseURL, cfg.TwelveDataAPIKey = server.URL, "test-key" })
+	return &last
+}
+
+// Test ticker suffixes become Twelve Data markets
+func TestTwelveDataSymbol(t *testing.T) {
+	symbol, mic := twelveDataSymbol("vod.l")
+	assert.Equal(t, "VOD", symbol)
+	assert.Equal(t, "XLON", mic)
+	symbol, mic = twelveDataSymbol("000001.SZ")
+	assert.Equal(t, "000001", symbol)
+	assert.Equal(t, "XSHE", mic)
+	symbol, mic = twelveDataSymbol("BRK.B")
+	assert.Equal(t, "BRK.B", symbol)
+	assert.Equal(t, "", mic)
+}
+
+// Test Twelve Data's daily bars, as traded and adjusted, on the day asked for
+func TestTwelveDataDailyPrice(t *testing.T) {
+	last := setupMockTwelveData(t)
+
+	price, err := twelveDataProvider{}.DailyPrice("VOD.L", "2025-07-18", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 71.0, price)
+	assert.Equal(t, "2025-07-19", (*last)["end_date"])
+	assert.Equal(t, "none", (*last)["adjust"])
+
+	price, err = twelveDataProvider{}.DailyPrice("VOD.L", "2025-07-17", priceOptions{At: "open", Adjusted: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 68.5, price)
+
+	_, err = twelveDataProvider{}.DailyPrice("VOD.L", "2025-07-19", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "No data for date 2025-07-19")
+	_, err = twelveDataProvider{}.DailyPrice("VOD", "2025-07-18", priceOptions{At: "close"})
+	assert.ErrorContains(t, err, "Twelve Data returned status 200 **symbol** not found")
+
+	points, err := twelveDataProvider{}.History("VOD.L", "", "", priceOptions{At: "high"})
+	assert.NoError(t, err)
+	assert.Equal(t, []pricePoint{{Date: "2025-07-17", Price: 71}, {Date: "2025-07-18", Price: 72}}, points)
+}
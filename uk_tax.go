@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Annual CGT exempt amount (GBP) by the year its tax year starts. Tax years after
+// the last listed keep its allowance until the table catches up.
+var ukCGTAllowances = map[int]float64{
+	2008: 9600, 2009: 10100, 2010: 10100, 2011: 10600, 2012: 10600, 2013: 10900,
+	2014: 11000, 2015: 11100, 2016: 11100, 2017: 11300, 2018: 11700, 2019: 12000,
+	2020: 12300, 2021: 12300, 2022: 12300, 2023: 6000, 2024: 3000, 2025: 3000,
+}
+
+// CGT rates (percent) on shares for basic and higher rate taxpayers, from the
+// date each came in
+var ukCGTRates = []struct {
+	From   string
+	Basic  float64
+	Higher float64
+}{
+	{"2008-04-06", 18, 18},
+	{"2010-06-23", 18, 28},
+	{"2016-04-06", 10, 20},
+	{"2024-10-30", 18, 24},
+}
+
+// First day modelled: before the 2008/09 tax year, gains were tapered and indexed
+const ukTaxModelStart = "2008-04-06"
+
+// A Section 104 holding: every share of the class bought before a disposal, at
+// their total allowable cost (fees included), sold at its average cost
+type section104Pool struct {
+	Shares      float64 `json:"shares"`
+	Cost        float64 `json:"cost"`
+	AverageCost float64 `json:"averageCost"`
+	Additions   int     `json:"additions"`
+}
+
+// Helper function to add shares bought at a cost to the pool
+func (p *section104Pool) add(shares, cost float64) {
+	if shares <= 0 {
+		return
+	}
+	p.Shares += shares
+	p.Cost += cost
+	p.AverageCost = p.Cost / p.Shares
+	p.Additions++
+}
+
+// Helper function to take shares out of the pool, returning their allowable cost
+func (p *section104Pool) dispose(shares float64) float64 {
+	if shares >= p.Shares {
+		cost := p.Cost
+		p.Shares, p.Cost = 0, 0
+		return cost
+	}
+	cost := p.Cost * shares / p.Shares
+	p.Shares -= shares
+	p.Cost -= cost
+	return cost
+}
+
+// Helper function to find the UK tax year (6 April to 5 April) a date falls in,
+// by the year it starts
+func ukTaxYear(date string) int {
+	year, _ := strconv.Atoi(date[:4])
+	if dateOnly(date)[5:] < "04-06" {
+		year--
+	}
+	return year
+}
+
+// Helper function to look up the CGT allowance (GBP) for a tax year
+func ukCGTAllowance(taxYear int) float64 {
+	latest := 0
+	for year := range ukCGTAllowances {
+		if year <= taxYear && year > latest {
+			latest = year
+		}
+	}
+	return ukCGTAllowances[latest]
+}
+
+// Helper function to look up the CGT rate on shares disposed of on a date
+func ukCGTRate(date, band string) float64 {
+	rate := 0.0
+	for _, period := range ukCGTRates {
+		if dateOnly(date) < period.From {
+			break
+		}
+		rate = period.Higher
+		if band == "basic" {
+			rate = period.Basic
+		}
+	}
+	return rate
+}
+
+// Helper function to check a disposal can be taxed under the UK model
+func checkUKDisposal(sellDate, band string) error {
+	if dateOnly(sellDate) < ukTaxModelStart {
+		return fmt.Errorf("UK tax is modelled from the 2008/09 tax year (disposals from %s)", ukTaxModelStart)
+	}
+	if band != "basic" && band != "higher" {
+		return fmt.Errorf("taxes.band must be basic or higher")
+	}
+	return nil
+}
+
+// The estimated UK capital gains tax on disposing of a whole Section 104 pool,
+// all in GBP as HMRC works it out
+type ukCapitalGains struct {
+	TaxYear       string         `json:"taxYear"`
+	Pool          section104Pool `json:"pool"`
+	Proceeds      float64        `json:"proceeds"`
+	AllowableCost float64        `json:"allowableCost"`
+	Gain          float64        `json:"gain"`
+	Allowance     float64        `json:"allowance"`
+	TaxableGain   float64        `json:"taxableGain"`
+	Band          string         `json:"band"`
+	RatePercent   float64        `json:"ratePercent"`
+	Tax           float64        `json:"tax"`
+}
+
+// Tax selling a pool in the UK: the gain is the proceeds (GBP, after the sell fee)
+// less the pool's allowable cost (GBP), and what the tax year's allowance doesn't
+// cover is taxed at the band's rate on the disposal date. Losses aren't carried
+// forward, and the gain is assumed to be the only one in its tax year.
+func calculateUKCapitalGains(pool section104Pool, proceeds float64, sellDate, band string) ukCapitalGains {
+	taxYear := ukTaxYear(sellDate)
+	result := ukCapitalGains{
+		TaxYear:     fmt.Sprintf("%d/%02d", taxYear, (taxYear+1)%100),
+		Pool:        pool,
+		Proceeds:    proceeds,
+		Allowance:   ukCGTAllowance(taxYear),
+		Band:        band,
+		RatePercent: ukCGTRate(sellDate, band),
+	}
+	result.AllowableCost = pool.dispose(pool.Shares)
+	result.Gain = proceeds - result.AllowableCost
+	if result.Gain > result.Allowance {
+		result.TaxableGain = result.Gain - result.Allowance
+	}
+	result.Tax = result.TaxableGain * result.RatePercent / 100
+	return result
+}
+
+// Helper function to convert a currency to GBP on a date
+func gbpRateOn(currency, date string, opts priceOptions) (float64, error) {
+	if currency == "GBP" {
+		return 1, nil
+	}
+	return opts.Notes.fxRate(currency, "GBP", dateOnly(date))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a Section 104 pool averages its cost and disposes of shares at it
+func TestSection104Pool(t *testing.T) {
+	var pool section104Pool
+	pool.add(10, 1000)
+	pool.add(5, 800)
+	pool.add(0, 50)
+	assert.Equal(t, 15.0, pool.Shares)
+	assert.Equal(t, 2, pool.Additions)
+	assert.InDelta(t, 120.0, pool.AverageCost, 1e-9)
+
+	assert.InDelta(t, 600.0, pool.dispose(5), 1e-9)
+	assert.InDelta(t, 1200.0, pool.Cost, 1e-9)
+	assert.InDelta(t, 1200.0, pool.dispose(20), 1e-9)
+	assert.Equal(t, 0.0, pool.Shares)
+}
+
+// Test tax years, allowances and rates follow the disposal date
+func TestUKTaxYearAndRates(t *testing.T) {
+	assert.Equal(t, 2024, ukTaxYear("2025-04-05"))
+	assert.Equal(t, 2025, ukTaxYear("2025-04-06"))
+	assert.Equal(t, 2025, ukTaxYear("2025-07-18T15:30"))
+
+	assert.Equal(t, 12300.0, ukCGTAllowance(2022))
+	assert.Equal(t, 6000.0, ukCGTAllowance(2023))
+	assert.Equal(t, 3000.0, ukCGTAllowance(2030))
+
+	assert.Equal(t, 20.0, ukCGTRate("2024-10-29", "higher"))
+	assert.Equal(t, 24.0, ukCGTRate("2024-10-30", "higher"))
+	assert.Equal(t, 18.0, ukCGTRate("2024-10-30", "basic"))
+	assert.Equal(t, 28.0, ukCGTRate("2012-01-01", "higher"))
+
+	assert.Error(t, checkUKDisposal("2008-04-05", "higher"))
+	assert.Error(t, checkUKDisposal("2025-07-18", "additional"))
+	assert.NoError(t, checkUKDisposal("2025-07-18", "basic"))
+}
+
+// Test the allowance comes off the gain before it's taxed, and covers small gains
+func TestCalculateUKCapitalGains(t *testing.T) {
+	pool := section104Pool{Shares: 100, Cost: 10000, AverageCost: 100}
+	result := calculateUKCapitalGains(pool, 20000, "2025-07-18", "higher")
+	assert.Equal(t, "2025/26", result.TaxYear)
+	assert.InDelta(t, 10000.0, result.Gain, 1e-9)
+	assert.InDelta(t, 7000.0, result.TaxableGain, 1e-9)
+	assert.InDelta(t, 1680.0, result.Tax, 1e-9)
+	assert.Equal(t, 100.0, result.Pool.Shares)
+
+	// A gain under the year's allowance isn't taxed
+	result = calculateUKCapitalGains(pool, 15000, "2024-01-10", "basic")
+	assert.Equal(t, "2023/24", result.TaxYear)
+	assert.InDelta(t, 6000.0, result.Allowance, 1e-9)
+	assert.Equal(t, 0.0, result.TaxableGain)
+	assert.Equal(t, 0.0, result.Tax)
+}
+
+// Test a multi-lot scenario taxed in the UK pools its lots in GBP, each at the
+// rate on the day it was bought
+func TestScenarioUKTaxWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	mockUSDPerUnit["2025-06-20"] = map[string]float64{"USD": 1, "GBP": 1.35}
+	t.Cleanup(func() { delete(mockUSDPerUnit, "2025-06-20") })
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "1000"}, {"side": "buy", "date": "2025-06-20", "amount": "500"}, {"side": "sell", "date": "2025-07-18"}],
+		"taxes": {"jurisdiction": "uk"}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		FinalValue float64 `json:"finalValueInOriginalCurrency"`
+		Taxes      struct {
+			CapitalGains float64 `json:"capitalGains"`
+		} `json:"taxes"`
+		UKTax ukCapitalGains `json:"ukTax"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "2025/26", response.UKTax.TaxYear)
+	assert.Equal(t, "higher", response.UKTax.Band)
+	assert.Equal(t, 24.0, response.UKTax.RatePercent)
+	assert.Equal(t, 1500.0, response.UKTax.Pool.Shares)
+	assert.Equal(t, 2, response.UKTax.Pool.Additions)
+	cost := 1000*200.50/1.29 + 500*205.75/1.35
+	assert.InDelta(t, cost, response.UKTax.Pool.Cost, 1e-6)
+	assert.InDelta(t, cost/1500, response.UKTax.Pool.AverageCost, 1e-9)
+
+	// The proceeds are converted at the sell date's rate, and the £3,000
+	// allowance comes off the gain in GBP; only the tax is converted back
+	proceeds := 1500 * 211.18 / 1.34
+	assert.InDelta(t, proceeds, response.UKTax.Proceeds, 1e-6)
+	assert.InDelta(t, proceeds-cost, response.UKTax.Gain, 1e-6)
+	assert.Equal(t, 3000.0, response.UKTax.Allowance)
+	tax := (proceeds - cost - 3000) * 0.24
+	assert.InDelta(t, tax, response.UKTax.Tax, 1e-6)
+	assert.InDelta(t, tax*1.34, response.Taxes.CapitalGains, 1e-6)
+	assert.InDelta(t, 1500*211.18-tax*1.34, response.FinalValue, 0.01)
+}
+
+// Test a reinvested dividend joins the pool at its cost on the day it was
+// reinvested, not the sell date's
+func TestScenarioUKTaxReinvestedCostWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	mockUSDPerUnit["2025-05-15"] = map[string]float64{"USD": 1, "GBP": 1.33}
+	t.Cleanup(func() { delete(mockUSDPerUnit, "2025-05-15") })
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "2000GBP"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest"},
+		"taxes": {"jurisdiction": "UK"}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		UKTax ukCapitalGains `json:"ukTax"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	// The May dividend is reinvested; July's is paid after the sale
+	shares := 2000 * 1.29 / 200.50
+	assert.Equal(t, 2, response.UKTax.Pool.Additions)
+	assert.InDelta(t, shares+shares*0.26/211.45, response.UKTax.Pool.Shares, 1e-9)
+	assert.InDelta(t, 2000+shares*0.26/1.33, response.UKTax.Pool.Cost, 1e-9)
+}
@@ -0,0 +1,94 @@
+package main
+
+// Long-term capital gains rate (percent) when a US scenario doesn't give one, the
+// middle of the 0/15/20% brackets most investors fall in
+const defaultUSLongTermPercent = 15.0
+
+// Shares of a US scenario sold together, bought on one date: a lot's own shares,
+// or the shares one of its dividends bought back in. Money is in the scenario's
+// currency, with the sell fee shared out by proceeds.
+type usTaxLot struct {
+	Date     string  `json:"date"`
+	Shares   float64 `json:"shares"`
+	Cost     float64 `json:"cost"`
+	Proceeds float64 `json:"proceeds"`
+	Gain     float64 `json:"gain"`
+	Term     string  `json:"term"`
+}
+
+// Gains of one holding period, after netting, and the tax on them
+type usGains struct {
+	Gain        float64 `json:"gain"`
+	RatePercent float64 `json:"ratePercent"`
+	Tax         float64 `json:"tax"`
+}
+
+// The estimated US federal tax on a sale, split into short-term gains (held a
+// year or less, taxed as income) and long-term gains
+type usCapitalGains struct {
+	Lots      []usTaxLot `json:"lots"`
+	ShortTerm usGains    `json:"shortTerm"`
+	LongTerm  usGains    `json:"longTerm"`
+	Tax       float64    `json:"tax"`
+}
+
+// Helper function to tell a lot's holding period on a sale: long-term when held
+// more than a year
+func usTerm(buyDate, sellDate string) string {
+	if heldOverAYear(buyDate, sellDate) {
+		return "long"
+	}
+	return "short"
+}
+
+// Tax a sale in the US. Each lot's gain is short- or long-term by its own holding
+// period; gains and losses net within each term, then a net loss in one term
+// offsets a net gain in the other, and what's left is taxed at the term's rate.
+// Net losses aren't deducted from income or carried forward.
+func calculateUSCapitalGains(lots []usTaxLot, sellDate string, shortTermPercent, longTermPercent float64) usCapitalGains {
+	result := usCapitalGains{
+		Lots:      lots,
+		ShortTerm: usGains{RatePercent: shortTermPercent},
+		LongTerm:  usGains{RatePercent: longTermPercent},
+	}
+	for i, lot := range result.Lots {
+		result.Lots[i].Gain = lot.Proceeds - lot.Cost
+		result.Lots[i].Term = usTerm(lot.Date, sellDate)
+		if result.Lots[i].Term == "long" {
+			result.LongTerm.Gain += result.Lots[i].Gain
+		} else {
+			result.ShortTerm.Gain += result.Lots[i].Gain
+		}
+	}
+
+	taxedShort, taxedLong := result.ShortTerm.Gain, result.LongTerm.Gain
+	if taxedShort < 0 && taxedLong > 0 {
+		taxedLong, taxedShort = max(0, taxedLong+taxedShort), 0
+	} else if taxedLong < 0 && taxedShort > 0 {
+		taxedShort, taxedLong = max(0, taxedShort+taxedLong), 0
+	}
+	result.ShortTerm.Tax = max(0, taxedShort) * shortTermPercent / 100
+	result.LongTerm.Tax = max(0, taxedLong) * longTermPercent / 100
+	result.Tax = result.ShortTerm.Tax + result.LongTerm.Tax
+	return result
+}
+
+// Helper function to split a scenario lot into the shares sold for US tax: its own
+// shares from its buy date, and each reinvested dividend's shares from the date it
+// was reinvested, at its cost that day. proceeds is the lot's share of the sale
+// after the sell fee.
+func (lot scenarioLot) usTaxLots(proceeds float64) []usTaxLot {
+	held := lot.Shares + lot.ReinvestedShares
+	sales := []usTaxLot{{Date: dateOnly(lot.Date), Shares: lot.Shares, Cost: lot.Invested, Proceeds: proceeds * lot.Shares / held}}
+	for i, event := range lot.reinvestments {
+		if event.SharesBought > 0 {
+			sales = append(sales, usTaxLot{
+				Date:     event.reinvestedOn(),
+				Shares:   event.SharesBought,
+				Cost:     lot.reinvestedCost[i],
+				Proceeds: proceeds * event.SharesBought / held,
+			})
+		}
+	}
+	return sales
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test gains split by holding period, with a net loss in one term offsetting the other
+func TestCalculateUSCapitalGains(t *testing.T) {
+	lots := []usTaxLot{
+		{Date: "2024-01-10", Cost: 1000, Proceeds: 1500},
+		{Date: "2025-01-10", Cost: 1000, Proceeds: 1200},
+		{Date: "2025-03-10", Cost: 1000, Proceeds: 900},
+	}
+	result := calculateUSCapitalGains(lots, "2025-07-18", 32, 15)
+	assert.Equal(t, "long", result.Lots[0].Term)
+	assert.Equal(t, "short", result.Lots[1].Term)
+	assert.InDelta(t, 500.0, result.LongTerm.Gain, 1e-9)
+	assert.InDelta(t, 100.0, result.ShortTerm.Gain, 1e-9)
+	assert.InDelta(t, 75.0, result.LongTerm.Tax, 1e-9)
+	assert.InDelta(t, 32.0, result.ShortTerm.Tax, 1e-9)
+	assert.InDelta(t, 107.0, result.Tax, 1e-9)
+
+	// A short-term loss comes off the long-term gain
+	lots = []usTaxLot{
+		{Date: "2024-01-10", Cost: 1000, Proceeds: 1500},
+		{Date: "2025-03-10", Cost: 1000, Proceeds: 800},
+	}
+	result = calculateUSCapitalGains(lots, "2025-07-18", 32, 15)
+	assert.InDelta(t, -200.0, result.ShortTerm.Gain, 1e-9)
+	assert.Equal(t, 0.0, result.ShortTerm.Tax)
+	assert.InDelta(t, 300*0.15, result.LongTerm.Tax, 1e-9)
+
+	// Held exactly a year is still short-term
+	assert.Equal(t, "short", usTerm("2024-07-18", "2025-07-18"))
+	assert.Equal(t, "long", usTerm("2024-07-18", "2025-07-19"))
+}
+
+// Test reinvested dividends are sold as their own lots, from the day they were paid
+// and at what they cost then
+func TestScenarioLotUSTaxLots(t *testing.T) {
+	lot := scenarioLot{
+		Date: "2025-03-31", Shares: 10, ReinvestedShares: 0.5, Invested: 2000,
+		reinvestments:  []dripEvent{{PaymentDate: "2025-05-15", Amount: 100, SharesBought: 0.5}, {PaymentDate: "2025-08-14", Amount: 2.7}},
+		reinvestedCost: []float64{88.5, 0},
+	}
+	sales := lot.usTaxLots(2100)
+	assert.Len(t, sales, 2)
+	assert.InDelta(t, 2000.0, sales[0].Proceeds, 1e-9)
+	assert.Equal(t, "2025-05-15", sales[1].Date)
+	assert.InDelta(t, 88.5, sales[1].Cost, 1e-9)
+	assert.InDelta(t, 100.0, sales[1].Proceeds, 1e-9)
+}
+
+// Test a US scenario reports its gains by term
+func TestScenarioUSTaxWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "buy", "date": "2025-06-20", "amount": "5"}, {"side": "sell", "date": "2025-07-18"}],
+		"fees": {"fixed": 3},
+		"taxes": {"jurisdiction": "US", "shortTermPercent": 32}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Taxes struct {
+			CapitalGains float64 `json:"capitalGains"`
+		} `json:"taxes"`
+		USTax usCapitalGains `json:"usTax"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.USTax.Lots, 2)
+	assert.Equal(t, "short", response.USTax.Lots[0].Term)
+	assert.Equal(t, 15.0, response.USTax.LongTerm.RatePercent)
+	assert.Equal(t, 0.0, response.USTax.LongTerm.Gain)
+
+	// Bought for 2005+3 and 1028.75+3, sold for 15*211.18 less the 3 fee shared
+	// out by proceeds
+	assert.InDelta(t, 15*211.18-3-2008-1031.75, response.USTax.ShortTerm.Gain, 0.01)
+	assert.InDelta(t, 10*211.18-2-2008, response.USTax.Lots[0].Gain, 0.01)
+	assert.InDelta(t, (15*211.18-3-2008-1031.75)*0.32, response.Taxes.CapitalGains, 0.01)
+}
+
+// Test a reinvested dividend's cost is converted at the rate on the day it was
+// reinvested, not the sell date's
+func TestScenarioUSTaxReinvestedCostWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockFrankfurter(t)
+	mockUSDPerUnit["2025-05-15"] = map[string]float64{"USD": 1, "EUR": 1.13}
+	t.Cleanup(func() { delete(mockUSDPerUnit, "2025-05-15") })
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "2000EUR"}, {"side": "sell", "date": "2025-07-18"}],
+		"dividends": {"mode": "reinvest"},
+		"taxes": {"jurisdiction": "US", "shortTermPercent": 32}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		USTax usCapitalGains `json:"usTax"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.USTax.Lots, 2)
+	// The May dividend is reinvested; July's is paid after the sale
+	shares := 2000 * 1.08 / 200.50
+	assert.Equal(t, "2025-05-15", response.USTax.Lots[1].Date)
+	assert.InDelta(t, shares*0.26/1.13, response.USTax.Lots[1].Cost, 1e-9)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tickers, coin IDs and index symbols: letters and digits, then dots, dashes,
+// underscores or = (BRK.B, usd-coin, GC=F), with an optional leading ^ (^GSPC)
+var tickerRegex = regexp.MustCompile(`^\^?[A-Za-z0-9][A-Za-z0-9._=-]*$`)
+
+// Returned for dates after the latest one prices exist for
+var errFutureDate = errors.New("date is in the future")
+
+// Helper function to check a date parameter: YYYY-MM-DD with an optional time of
+// day, and not in the future. Times are read as UTC, which is never later than the
+// same New York time stocks are quoted in, so no time that has passed is turned away.
+func checkDateParam(name, date string) error {
+	now := time.Now().UTC()
+	if hasTimeOfDay(date) {
+		at, err := parseIntradayTime(date, time.UTC)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if at.After(now) {
+			return fmt.Errorf("%s %s: %w", name, date, errFutureDate)
+		}
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("%s %q is not a date: expected YYYY-MM-DD", name, date)
+	}
+	if dateOnly(date) > now.Format("2006-01-02") {
+		return fmt.Errorf("%s %s: %w", name, date, errFutureDate)
+	}
+	return nil
+}
+
+// Helper function to check a sell date comes after the buy date. Either may have a
+// time of day; when only one does, the sell has to be on a later day.
+func checkDateOrder(buyDate, sellDate string) error {
+	after := dateOnly(sellDate) > dateOnly(buyDate)
+	if hasTimeOfDay(buyDate) && hasTimeOfDay(sellDate) {
+		after = sellDate > buyDate
+	}
+	if !after {
+		return fmt.Errorf("sellDate %s must be after buyDate %s", sellDate, buyDate)
+	}
+	return nil
+}
+
+// Helper function to answer 400 for a bad date of the asset in the path. Future
+// dates get the FUTURE_DATE code and the latest date there is data for, so clients
+// can retry with it.
+func abortWithDateError(c *gin.Context, err error) {
+	abortWithAssetDateError(c, err, c.Param("ticker"), assetTypeParam(c))
+}
+
+// Helper function to answer 400 for a bad date of an asset named in the request
+// body, whose latest close sets latestDate
+func abortWithAssetDateError(c *gin.Context, err error, ticker, assetType string) {
+	if errors.Is(err, errFutureDate) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error":      "Date in the future",
+			"code":       "FUTURE_DATE",
+			"details":    err.Error(),
+			"latestDate": latestCloseDate(ticker, assetType, time.Now()),
+		})
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid date", "details": err.Error()})
+}
+
+// Middleware checking the amount, ticker and dates in the path before a handler
+// spends a provider request on them, answering 400 when they can't be right
+func withInputValidation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			// Unmatched paths can carry partly matched params; leave them to the 404
+			c.Next()
+			return
+		}
+		if checkInputParams(c) {
+			c.Next()
+		}
+	}
+}
+
+// Helper function to check the amount, ticker and dates among a request's params,
+// aborting with 400 and returning false when one can't be right. Dates given as
+// "today" or "latest" are resolved to the asset's most recent close first.
+func checkInputParams(c *gin.Context) bool {
+	resolveRelativeDates(c)
+	if amount, ok := c.Params.Get("amount"); ok {
+		parsed, _, _, err := parseAmount(amount, c.Query("locale"))
+		if err == nil && parsed <= 0 {
+			err = fmt.Errorf("amount must be more than 0")
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format", "details": err.Error()})
+			return false
+		}
+	}
+	if ticker, ok := c.Params.Get("ticker"); ok && !tickerRegex.MatchString(ticker) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid ticker", "details": fmt.Sprintf("%q is not a ticker: use letters, digits, '.', '-', '_' or '=', optionally after '^'", ticker)})
+		return false
+	}
+
+	for _, name := range []string{"buyDate", "buyEnd", "peakBefore", "sellDate"} {
+		date, ok := c.Params.Get(name)
+		if !ok {
+			continue
+		}
+		if name == "buyDate" && isStaggered(date) {
+			if !isStaggeredRoute(c) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Several buy dates are only supported on single-asset buy, buy/sell and and-held backtests"})
+				return false
+			}
+			if _, err := checkStaggeredDates(date); err != nil {
+				abortWithDateError(c, err)
+				return false
+			}
+			continue
+		}
+		if err := checkDateParam(name, date); err != nil {
+			abortWithDateError(c, err)
+			return false
+		}
+	}
+	buyDate, hasBuy := c.Params.Get("buyDate")
+	buyDate = lastBuyDate(buyDate)
+	sellDate, hasSell := c.Params.Get("sellDate")
+
+	// Windows run from buyDate to buyEnd, and sell after they close
+	if buyEnd, ok := c.Params.Get("buyEnd"); ok {
+		if buyEnd < buyDate {
+			abortWithDateError(c, fmt.Errorf("buyEnd %s must not be before buyDate %s", buyEnd, buyDate))
+			return false
+		}
+		buyDate = buyEnd
+	}
+	// Peaks are bought before peakBefore, so selling on it is fine
+	if before, ok := c.Params.Get("peakBefore"); ok && hasSell && dateOnly(sellDate) < before {
+		abortWithDateError(c, fmt.Errorf("sellDate %s must not be before peakBefore %s", sellDate, before))
+		return false
+	}
+	if hasBuy && hasSell {
+		if err := checkDateOrder(buyDate, sellDate); err != nil {
+			abortWithDateError(c, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test impossible amounts, tickers and dates are turned away before the handler
+func TestInputValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation())
+	handled := 0
+	ok := func(c *gin.Context) {
+		handled++
+		c.Status(http.StatusOK)
+	}
+	router.GET("/:amount/:ticker/on/:buyDate", ok)
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", ok)
+	router.GET("/rolling/:ticker", ok)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	later := time.Now().UTC().Add(2 * time.Minute).Format("2006-01-02T15:04")
+	testCases := []struct {
+		name     string
+		path     string
+		expected int
+		details  string
+	}{
+		{"Valid", "/10/AAPL/on/2025-07-18/and-sold-on/2025-07-21", http.StatusOK, ""},
+		{"Valid intraday", "/10/AAPL/on/2025-07-18T10:00/and-sold-on/2025-07-18T15:30", http.StatusOK, ""},
+		{"Valid symbols", "/1000USD/%5EGSPC/on/2025-07-18", http.StatusOK, ""},
+		{"Coin ID", "/1/usd-coin/on/2025-07-18", http.StatusOK, ""},
+		{"Bad amount", "/lots/AAPL/on/2025-07-18", http.StatusBadRequest, "Unrecognised amount"},
+		{"Zero amount", "/0/AAPL/on/2025-07-18", http.StatusBadRequest, "more than 0"},
+		{"Bad ticker", "/10/AA$PL/on/2025-07-18", http.StatusBadRequest, "is not a ticker"},
+		{"Bad date", "/10/AAPL/on/2025-13-01", http.StatusBadRequest, "expected YYYY-MM-DD"},
+		{"Bad time", "/10/AAPL/on/2025-07-18T25:00", http.StatusBadRequest, "YYYY-MM-DDTHH:MM"},
+		{"Future date", "/10/AAPL/on/" + tomorrow, http.StatusBadRequest, "FUTURE_DATE"},
+		{"Future sell date", "/10/AAPL/on/2025-07-18/and-sold-on/" + tomorrow, http.StatusBadRequest, "FUTURE_DATE"},
+		{"Future time", "/10/BTC/on/" + later, http.StatusBadRequest, "FUTURE_DATE"},
+		{"Sell before buy", "/10/AAPL/on/2025-07-21/and-sold-on/2025-07-18", http.StatusBadRequest, "must be after buyDate"},
+		{"Sell on buy date", "/10/AAPL/on/2025-07-18/and-sold-on/2025-07-18", http.StatusBadRequest, "must be after buyDate"},
+		{"Intraday sell before buy", "/10/AAPL/on/2025-07-18T15:00/and-sold-on/2025-07-18T10:00", http.StatusBadRequest, "must be after buyDate"},
+		{"Rolling ticker", "/rolling/AA%20PL", http.StatusBadRequest, "is not a ticker"},
+		{"Unknown path", "/10/AAPL/at/2025-07-18", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handled = 0
+			w := makeTestRequest(router, "GET", tc.path)
+			assert.Equal(t, tc.expected, w.Code, w.Body.String())
+			if tc.details != "" {
+				assert.Contains(t, w.Body.String(), tc.details)
+				assert.Zero(t, handled)
+			}
+		})
+	}
+}
+
+// Test future dates say how far the data goes
+func TestFutureDate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withInputValidation())
+	router.GET("/:amount/:ticker/on/:buyDate/and-sold-on/:sellDate", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	today := time.Now().UTC().Format("2006-01-02")
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-07-18/and-sold-on/2999-01-01")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "FUTURE_DATE", body["code"])
+	assert.Equal(t, latestCloseDate("AAPL", "stock", time.Now()), body["latestDate"])
+	assert.Equal(t, "sellDate 2999-01-01: date is in the future", body["details"])
+
+	// Crypto's latest close is its last daily snapshot
+	w = makeTestRequest(router, "GET", "/1/BTC/on/2025-07-18/and-sold-on/2999-01-01?type=crypto")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, cryptoLatestClose(time.Now()), body["latestDate"])
+
+	w = makeTestRequest(router, "GET", "/10/AAPL/on/2025-07-18/and-sold-on/"+today)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build info, set at build time with
+// -ldflags "-X main.version=v1.2.0 -X main.commit=abc1234 -X main.buildDate=2025-07-18T12:00:00Z"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// What a deployment is running
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Helper function to get the build info, falling back to the VCS details Go
+// stamps into binaries built from a checkout when ldflags didn't set them
+func currentBuildInfo() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if stamped, ok := debug.ReadBuildInfo(); ok {
+		modified := false
+		for _, setting := range stamped.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			case setting.Key == "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
+
+// Build info, worked out once
+var runningBuild = currentBuildInfo()
+
+// Helper function to describe the build in one line, like "v1.2.0 (abc1234)"
+func (b buildInfo) String() string {
+	shortCommit := b.Commit
+	if len(shortCommit) > 12 {
+		shortCommit = shortCommit[:12]
+	}
+	return b.Version + " (" + shortCommit + ")"
+}
+
+// Middleware sending the build on every response in X-Version
+func withVersionHeader() gin.HandlerFunc {
+	header := runningBuild.String()
+	return func(c *gin.Context) {
+		c.Header("X-Version", header)
+		c.Next()
+	}
+}
+
+// Report the version, commit and build date of the running server
+func handleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, runningBuild)
+}
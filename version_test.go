@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test build info set with ldflags wins, and unknown fields say so
+func TestCurrentBuildInfo(t *testing.T) {
+	oldVersion, oldCommit, oldBuildDate := version, commit, buildDate
+	defer func() { version, commit, buildDate = oldVersion, oldCommit, oldBuildDate }()
+
+	version, commit, buildDate = "v1.2.0", "0123456789abcdef0123", "2025-07-18T12:00:00Z"
+	info := currentBuildInfo()
+	assert.Equal(t, buildInfo{Version: "v1.2.0", Commit: "0123456789abcdef0123", BuildDate: "2025-07-18T12:00:00Z", GoVersion: info.GoVersion}, info)
+	assert.Equal(t, "v1.2.0 (0123456789ab)", info.String())
+
+	version, commit, buildDate = "dev", "", ""
+	info = currentBuildInfo()
+	assert.NotEmpty(t, info.Commit)
+	assert.NotEmpty(t, info.BuildDate)
+}
+
+// Test the /version endpoint and X-Version header
+func TestVersionEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(withVersionHeader())
+	router.GET("/version", handleVersion)
+
+	w := makeTestRequest(router, "GET", "/version")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, runningBuild.String(), w.Header().Get("X-Version"))
+
+	var response map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, runningBuild.Version, response["version"])
+	assert.Equal(t, runningBuild.Commit, response["commit"])
+	assert.Contains(t, response, "buildDate")
+	assert.Contains(t, response["goVersion"], "go")
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A symbol whose daily prices are fetched ahead of time
+type warmTicker struct {
+	Ticker    string
+	AssetType string
+}
+
+// Helper function to parse WARM_TICKERS: "SPY, AAPL, BTC:crypto, GOLD:commodity".
+// Without a type, tickers are stocks, or indices when they start with ^.
+func parseWarmTickers(list string) ([]warmTicker, error) {
+	var tickers []warmTicker
+	for _, item := range splitList(list) {
+		ticker, assetType, _ := strings.Cut(item, ":")
+		ticker = strings.ToUpper(strings.TrimSpace(ticker))
+		assetType = strings.TrimSpace(assetType)
+		if assetType == "" {
+			assetType = "stock"
+			if isIndexTicker(ticker) {
+				assetType = "index"
+			}
+		}
+		if ticker == "" || !isValidAssetType(assetType) || isModelledType(assetType) {
+			return nil, fmt.Errorf("warm_tickers entry %q must be a ticker with an optional :stock, :crypto, :index or :commodity", item)
+		}
+		tickers = append(tickers, warmTicker{Ticker: ticker, AssetType: assetType})
+	}
+	return tickers, nil
+}
+
+// Daily prices by date and point of the day (open, high, low, close)
+type dailySeries map[string]map[string]float64
+
+// Fetch a stock's full unadjusted daily series from Alpha Vantage, every point of
+// the day in one request
+func fetchStockSeriesAlphaVantage(ticker string) (dailySeries, error) {
+	cfg := settings()
+	url := fmt.Sprintf("%s/query?function=TIME_SERIES_DAILY&symbol=%s&outputsize=full&apikey=%s", cfg.AlphaVantageBaseURL, ticker, cfg.AlphaVantageAPIKey)
+	resp, err := outboundClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result alphaVantageDailyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("JSON unmarshal error: %v", err)
+	}
+	if result.TimeSeries == nil {
+		return nil, fmt.Errorf("No time series data returned from Alpha Vantage")
+	}
+
+	series := dailySeries{}
+	for date, dayData := range result.TimeSeries {
+		series[date] = map[string]float64{}
+		for at, field := range priceAtFields {
+			if price, err := strconv.ParseFloat(dayData[field], 64); err == nil {
+				series[date][at] = price
+			}
+		}
+	}
+	return series, nil
+}
+
+// Fetch a symbol's full daily series from Stooq
+func fetchStooqSeries(symbol string) (dailySeries, error) {
+	resp, err := outboundClient.Get(fmt.Sprintf("%s/q/d/l/?s=%s&i=d", settings().StooqBaseURL, symbol))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	if err != nil || len(records) < 2 {
+		return nil, fmt.Errorf("No Stooq data for %s", symbol)
+	}
+
+	series := dailySeries{}
+	for _, record := range records[1:] {
+		if len(record) < 5 {
+			continue
+		}
+		series[record[0]] = map[string]float64{}
+		for at, column := range stooqPriceColumns {
+			if price, err := strconv.ParseFloat(record[column], 64); err == nil {
+				series[record[0]][at] = price
+			}
+		}
+	}
+	return series, nil
+}
+
+// Helper function to turn a series with one value a day into one with that value
+// at every point of the day
+func flatSeries(points []pricePoint, ats ...string) dailySeries {
+	series := dailySeries{}
+	for _, point := range points {
+		series[point.Date] = map[string]float64{}
+		for _, at := range ats {
+			series[point.Date][at] = point.Price
+		}
+	}
+	return series
+}
+
+// Fetch an asset's full daily series in USD, priced the way fetchPrice would price
+// each day. Indices quoted in other currencies and crypto highs and lows would
+// take a request per day, so they aren't warmed.
+func fetchWarmSeries(t warmTicker) (dailySeries, error) {
+	switch t.AssetType {
+	case "stock":
+		return fetchStockSeriesAlphaVantage(t.Ticker)
+	case "index":
+		index, ok := marketIndices[t.Ticker]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported index %s", t.Ticker)
+		}
+		if index.Currency != "USD" {
+			return nil, fmt.Errorf("%s is quoted in %s; only USD indices are warmed", t.Ticker, index.Currency)
+		}
+		return fetchStooqSeries(index.StooqSymbol)
+	case "commodity":
+		item, ok := commodities[t.Ticker]
+		if !ok {
+			return nil, fmt.Errorf("Unsupported commodity %s", t.Ticker)
+		}
+		if item.StooqSymbol != "" {
+			return fetchStooqSeries(item.StooqSymbol)
+		}
+		points, err := fetchCommodityHistoryAlphaVantage(item.AlphaFunction)
+		if err != nil {
+			return nil, err
+		}
+		return flatSeries(points, "open", "high", "low", "close"), nil
+	case "crypto":
+		if minutes, _ := parseSnapshotTime(settings().CryptoSnapshotTime); minutes > 0 {
+			return nil, fmt.Errorf("Crypto is only warmed with the midnight snapshot time")
+		}
+		// CoinGecko's full history has one price a day, at midnight UTC: the day's
+		// open and, near enough, the previous day's close. Today's is the latest
+		// price, so it doesn't close yesterday.
+		points, err := fetchCryptoDailyHistory(t.Ticker, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+		series := flatSeries(points, "open")
+		today := time.Now().UTC().Format("2006-01-02")
+		for i := 1; i < len(points) && points[i].Date < today; i++ {
+			series[points[i-1].Date]["close"] = points[i].Price
+		}
+		return series, nil
+	}
+	return nil, fmt.Errorf("Type %s can't be warmed", t.AssetType)
+}
+
+// Fetch a symbol's full daily series and keep every past day of it in the price
+// cache, returning how many days that was
+func warmPrices(t warmTicker) (int, error) {
+	series, err := fetchWarmSeries(t)
+	if err != nil {
+		return 0, err
+	}
+	for date, ats := range series {
+		for at, price := range ats {
+			prices.warm(newPriceKey(t.Ticker, date, t.AssetType, priceOptions{At: at}), price)
+		}
+	}
+	return len(series), nil
+}
+
+// Warm the price cache for WARM_TICKERS now and then every WARM_INTERVAL seconds
+// (0: only at startup). One symbol is fetched at a time, so requests and reloads
+// aren't held up; failures are logged and tried again next round.
+func warmPricesPeriodically() {
+	go func() {
+		for {
+			cfg := settings()
+			tickers, _ := parseWarmTickers(cfg.WarmTickers)
+
+			for _, t := range tickers {
+				days, err := warmPrices(t)
+				if err != nil {
+					log.Printf("Warming prices for %s failed: %v", t.Ticker, err)
+					continue
+				}
+				log.Printf("Warmed %d days of prices for %s", days, t.Ticker)
+			}
+
+			if cfg.WarmInterval <= 0 {
+				return
+			}
+			time.Sleep(time.Duration(cfg.WarmInterval) * time.Second)
+		}
+	}()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test WARM_TICKERS is parsed, with types inferred for plain tickers
+func TestParseWarmTickers(t *testing.T) {
+	tickers, err := parseWarmTickers("spy, AAPL, ^GSPC, BTC:crypto, GOLD:commodity")
+	assert.NoError(t, err)
+	assert.Equal(t, []warmTicker{
+		{"SPY", "stock"}, {"AAPL", "stock"}, {"^GSPC", "index"}, {"BTC", "crypto"}, {"GOLD", "commodity"},
+	}, tickers)
+
+	for _, bad := range []string{"US10Y:bond", "AAPL:shares", ":crypto"} {
+		_, err := parseWarmTickers(bad)
+		assert.ErrorContains(t, err, "warm_tickers entry", bad)
+	}
+}
+
+// Test a warmed stock is priced from the cache at every point of the day
+func TestWarmPricesStock(t *testing.T) {
+	setupMockAlphaVantage(t)
+	defer prices.invalidate(priceFilter{})
+
+	days, err := warmPrices(warmTicker{"AAPL", "stock"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(mockStockData), days)
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	setSettings(t, func(cfg *Config) { cfg.AlphaVantageBaseURL = down.URL })
+
+	price, err := fetchPrice("aapl", "2025-07-18", "stock", priceOptions{At: "open"})
+	assert.NoError(t, err)
+	assert.Equal(t, 210.87, price)
+	price, err = fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close"})
+	assert.NoError(t, err)
+	assert.Equal(t, 211.18, price)
+
+	// Adjusted prices come from a different series
+	_, err = fetchPrice("AAPL", "2025-07-18", "stock", priceOptions{At: "close", Adjusted: true})
+	assert.Error(t, err)
+}
+
+// Test warmed prices outlast the cache's size limit but not invalidation
+func TestWarmPricesKept(t *testing.T) {
+	setupMockStooq(t)
+	prices.invalidate(priceFilter{})
+	defer prices.invalidate(priceFilter{})
+	setSettings(t, func(cfg *Config) { cfg.PriceCacheSize = 1 })
+
+	_, err := warmPrices(warmTicker{"^GSPC", "index"})
+	assert.NoError(t, err)
+	prices.put(newPriceKey("MSFT", "2025-07-17", "stock", priceOptions{At: "close"}), 510)
+	prices.put(newPriceKey("MSFT", "2025-07-18", "stock", priceOptions{At: "close"}), 511)
+
+	assert.Len(t, prices.list(priceFilter{Ticker: "^GSPC"}), 4*len(mockIndexData["^spx"]))
+	assert.Len(t, prices.list(priceFilter{Ticker: "MSFT"}), 1)
+	price, ok := prices.get(newPriceKey("^GSPC", "2025-07-18", "index", priceOptions{At: "close"}))
+	assert.True(t, ok)
+	assert.Equal(t, 6296.79, price)
+
+	prices.invalidate(priceFilter{Ticker: "^GSPC", From: "2025-07-18"})
+	_, ok = prices.get(newPriceKey("^GSPC", "2025-07-18", "index", priceOptions{At: "close"}))
+	assert.False(t, ok)
+}
+
+// Test crypto is warmed from CoinGecko's daily history, and non-USD indices aren't
+func TestWarmPricesCryptoAndIndices(t *testing.T) {
+	setupMockCoinGecko(t)
+	defer prices.invalidate(priceFilter{})
+
+	_, err := warmPrices(warmTicker{"SOLANA", "crypto"})
+	assert.NoError(t, err)
+	price, ok := prices.get(newPriceKey("SOLANA", "2025-03-31", "crypto", priceOptions{At: "open"}))
+	assert.True(t, ok)
+	assert.Equal(t, 125.0, price)
+	_, ok = prices.get(newPriceKey("SOLANA", "2025-03-31", "crypto", priceOptions{At: "high"}))
+	assert.False(t, ok)
+
+	_, err = warmPrices(warmTicker{"^FTSE", "index"})
+	assert.ErrorContains(t, err, "only USD indices are warmed")
+}
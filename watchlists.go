@@ -0,0 +1,399 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on what one API key can save
+const (
+	maxWatchlistsPerKey   = 100
+	maxWatchlistScenarios = 50
+)
+
+// A saved "if you bought" scenario
+type scenario struct {
+	Name    string `json:"name,omitempty"`
+	Amount  string `json:"amount" binding:"required"`
+	Ticker  string `json:"ticker" binding:"required"`
+	Type    string `json:"type,omitempty"`
+	BuyDate string `json:"buyDate" binding:"required"`
+}
+
+// A named collection of scenarios belonging to one API key
+type watchlist struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scenarios []scenario `json:"scenarios"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// Middleware letting through requests with one of the API keys in X-API-Key,
+// and recording whose they are (a hash of the key) for the handlers
+func withAPIKey(keys []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(keys) == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or wrong API key", "details": "watchlists and alerts are off: the server has no API_KEYS"})
+			return
+		}
+		given := c.GetHeader("X-API-Key")
+		known := false
+		for _, key := range keys {
+			if subtle.ConstantTimeCompare([]byte(given), []byte(key)) == 1 {
+				known = true
+			}
+		}
+		if given == "" || !known {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or wrong API key", "details": "send one of the server's API_KEYS in X-API-Key"})
+			return
+		}
+		owner := sha256.Sum256([]byte(given))
+		c.Set("apiKeyOwner", hex.EncodeToString(owner[:]))
+		c.Next()
+	}
+}
+
+// Watchlists by owner, saved as JSON at path after every change. Owners are
+// hashes of API keys, so the file holds no keys.
+type watchlistStore struct {
+	path string
+
+	mu         sync.Mutex
+	watchlists map[string]map[string]*watchlist
+}
+
+// Load the watchlists saved at path, if any
+func newWatchlistStore(path string) (*watchlistStore, error) {
+	s := &watchlistStore{path: path, watchlists: map[string]map[string]*watchlist{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved map[string][]*watchlist
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("Watchlists file %s: %v", path, err)
+	}
+	for owner, lists := range saved {
+		s.watchlists[owner] = map[string]*watchlist{}
+		for _, w := range lists {
+			s.watchlists[owner][w.ID] = w
+		}
+	}
+	return s, nil
+}
+
+// Helper function to list an owner's watchlists, oldest first. Callers hold s.mu.
+func (s *watchlistStore) list(owner string) []*watchlist {
+	lists := make([]*watchlist, 0, len(s.watchlists[owner]))
+	for _, w := range s.watchlists[owner] {
+		lists = append(lists, w)
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].CreatedAt.Before(lists[j].CreatedAt) })
+	return lists
+}
+
+// Write the watchlists to the file, replacing it in one step. Callers hold s.mu.
+func (s *watchlistStore) save() error {
+	saved := map[string][]*watchlist{}
+	for owner := range s.watchlists {
+		if lists := s.list(owner); len(lists) > 0 {
+			saved[owner] = lists
+		}
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(s.path+".tmp", s.path)
+}
+
+// Request body of POST and PUT /v1/watchlists
+type watchlistRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scenarios []scenario `json:"scenarios" binding:"dive"`
+}
+
+// Helper function to check a watchlist request, filling in each scenario's type
+func checkWatchlistRequest(request *watchlistRequest) error {
+	if len(request.Scenarios) > maxWatchlistScenarios {
+		return fmt.Errorf("a watchlist holds at most %d scenarios", maxWatchlistScenarios)
+	}
+	for i := range request.Scenarios {
+		sc := &request.Scenarios[i]
+		sc.Ticker = strings.ToUpper(sc.Ticker)
+		if sc.Type == "" {
+			sc.Type = "stock"
+			if isIndexTicker(sc.Ticker) {
+				sc.Type = "index"
+			}
+		}
+		if !isValidAssetType(sc.Type) || isModelledType(sc.Type) {
+			return fmt.Errorf("scenario %d: type must be stock, crypto, index or commodity", i+1)
+		}
+		if amount, _, _, err := parseAmount(sc.Amount, ""); err != nil || amount <= 0 {
+			return fmt.Errorf("scenario %d: invalid amount %q", i+1, sc.Amount)
+		}
+		if _, err := time.Parse("2006-01-02", sc.BuyDate); err != nil {
+			return fmt.Errorf("scenario %d: buyDate must be YYYY-MM-DD", i+1)
+		}
+		if err := checkDateParam("buyDate", sc.BuyDate); err != nil {
+			return fmt.Errorf("scenario %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Helper function to bind and check a watchlist request, answering 400 if it's bad
+func bindWatchlistRequest(c *gin.Context) (watchlistRequest, bool) {
+	var request watchlistRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid watchlist request", "details": err.Error()})
+		return request, false
+	}
+	if err := checkWatchlistRequest(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid watchlist request", "details": err.Error()})
+		return request, false
+	}
+	if request.Scenarios == nil {
+		request.Scenarios = []scenario{}
+	}
+	return request, true
+}
+
+// List the caller's watchlists
+func (s *watchlistStore) handleList(c *gin.Context) {
+	s.mu.Lock()
+	lists := []watchlist{}
+	for _, w := range s.list(c.GetString("apiKeyOwner")) {
+		lists = append(lists, *w)
+	}
+	s.mu.Unlock()
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"watchlists": lists})
+}
+
+// Save a watchlist: POST /v1/watchlists {"name": "Regrets", "scenarios": [{"amount":
+// "1000USD", "ticker": "AAPL", "buyDate": "2020-03-20"}]}
+func (s *watchlistStore) handleCreate(c *gin.Context) {
+	request, ok := bindWatchlistRequest(c)
+	if !ok {
+		return
+	}
+	owner := c.GetString("apiKeyOwner")
+	now := time.Now().UTC()
+	w := &watchlist{ID: newRandomID(), Name: request.Name, Scenarios: request.Scenarios, CreatedAt: now, UpdatedAt: now}
+
+	s.mu.Lock()
+	if len(s.watchlists[owner]) >= maxWatchlistsPerKey {
+		s.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("An API key can save at most %d watchlists", maxWatchlistsPerKey)})
+		return
+	}
+	if s.watchlists[owner] == nil {
+		s.watchlists[owner] = map[string]*watchlist{}
+	}
+	s.watchlists[owner][w.ID] = w
+	err := s.save()
+	created := *w
+	s.mu.Unlock()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save watchlist", "details": err.Error()})
+		return
+	}
+
+	c.Header("Location", "/v1/watchlists/"+created.ID)
+	c.JSON(http.StatusCreated, created)
+}
+
+// Helper function to copy one of the caller's watchlists, answering 404 if there's none
+func (s *watchlistStore) find(c *gin.Context) (watchlist, bool) {
+	s.mu.Lock()
+	w, ok := s.watchlists[c.GetString("apiKeyOwner")][c.Param("id")]
+	var snapshot watchlist
+	if ok {
+		snapshot = *w
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+	}
+	return snapshot, ok
+}
+
+// Show one of the caller's watchlists
+func (s *watchlistStore) handleGet(c *gin.Context) {
+	if w, ok := s.find(c); ok {
+		c.Header("Cache-Control", "no-store")
+		c.JSON(http.StatusOK, w)
+	}
+}
+
+// Replace a watchlist's name and scenarios
+func (s *watchlistStore) handleUpdate(c *gin.Context) {
+	request, ok := bindWatchlistRequest(c)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	w, ok := s.watchlists[c.GetString("apiKeyOwner")][c.Param("id")]
+	var err error
+	var updated watchlist
+	if ok {
+		w.Name, w.Scenarios, w.UpdatedAt = request.Name, request.Scenarios, time.Now().UTC()
+		err = s.save()
+		updated = *w
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save watchlist", "details": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// Delete a watchlist
+func (s *watchlistStore) handleDelete(c *gin.Context) {
+	owner := c.GetString("apiKeyOwner")
+	s.mu.Lock()
+	_, ok := s.watchlists[owner][c.Param("id")]
+	var err error
+	if ok {
+		delete(s.watchlists[owner], c.Param("id"))
+		err = s.save()
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save watchlists", "details": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// A scenario valued at the latest price, in its amount's currency (USD for quantities)
+type scenarioResult struct {
+	scenario
+	Currency  string   `json:"currency,omitempty"`
+	Shares    float64  `json:"shares"`
+	Invested  float64  `json:"invested"`
+	Value     float64  `json:"value"`
+	Gain      float64  `json:"gain"`
+	ReturnPct *float64 `json:"returnPct,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Value a scenario at the latest price
+func evaluateScenario(sc scenario) scenarioResult {
+	result := scenarioResult{scenario: sc, Currency: "USD"}
+	amount, currency, isValue, err := parseAmount(sc.Amount, "")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	buyPrice, err := fetchPrice(sc.Ticker, sc.BuyDate, sc.Type, priceOptions{At: "close"})
+	if err != nil {
+		result.Error = "Failed to fetch stock price: " + err.Error()
+		return result
+	}
+
+	result.Shares, result.Invested = amount, amount*buyPrice
+	if isValue {
+		if result.Currency, err = resolveCurrency(currency, ""); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		fxRate, err := getHistoricalFXRate(result.Currency, "USD", sc.BuyDate)
+		if err != nil {
+			result.Error = "Failed to fetch FX rate: " + err.Error()
+			return result
+		}
+		result.Shares, result.Invested = amount*fxRate/buyPrice, amount
+	}
+
+	if result.Value, err = latestValue(sc.Ticker, sc.Type, result.Currency, result.Shares); err != nil {
+		result.Error = "Failed to fetch latest price: " + err.Error()
+		return result
+	}
+	result.Gain = result.Value - result.Invested
+	returnPct := result.Gain / result.Invested * 100
+	result.ReturnPct = &returnPct
+	return result
+}
+
+// Totals of a watchlist's valued scenarios in one currency
+type watchlistTotal struct {
+	Invested  float64 `json:"invested"`
+	Value     float64 `json:"value"`
+	Gain      float64 `json:"gain"`
+	ReturnPct float64 `json:"returnPct"`
+}
+
+// Re-evaluate a whole watchlist at the latest prices, with totals per currency.
+// Scenarios that can't be valued carry an error and are left out of the totals.
+func (s *watchlistStore) handleEvaluate(c *gin.Context) {
+	w, ok := s.find(c)
+	if !ok {
+		return
+	}
+
+	results := make([]scenarioResult, len(w.Scenarios))
+	totals := map[string]*watchlistTotal{}
+	for i, sc := range w.Scenarios {
+		results[i] = evaluateScenario(sc)
+		if results[i].Error != "" {
+			continue
+		}
+		total := totals[results[i].Currency]
+		if total == nil {
+			total = &watchlistTotal{}
+			totals[results[i].Currency] = total
+		}
+		total.Invested += results[i].Invested
+		total.Value += results[i].Value
+	}
+	for _, total := range totals {
+		total.Gain = total.Value - total.Invested
+		total.ReturnPct = total.Gain / total.Invested * 100
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"id":          w.ID,
+		"name":        w.Name,
+		"evaluatedAt": time.Now().UTC(),
+		"scenarios":   results,
+		"totals":      totals,
+	})
+}
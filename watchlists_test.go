@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to set up a router with the watchlists API over a store in a
+// temporary file, for the keys "alice" and "bob"
+func setupWatchlistsRouter(t *testing.T) (*gin.Engine, *watchlistStore) {
+	gin.SetMode(gin.TestMode)
+	watchlists, err := newWatchlistStore(filepath.Join(t.TempDir(), "watchlists.json"))
+	assert.NoError(t, err)
+
+	router := gin.New()
+	lists := router.Group("/v1/watchlists", withAPIKey([]string{"alice", "bob"}))
+	lists.GET("", watchlists.handleList)
+	lists.POST("", watchlists.handleCreate)
+	lists.GET("/:id", watchlists.handleGet)
+	lists.PUT("/:id", watchlists.handleUpdate)
+	lists.DELETE("/:id", watchlists.handleDelete)
+	lists.GET("/:id/evaluate", watchlists.handleEvaluate)
+	return router, watchlists
+}
+
+// Helper function to call the watchlists API as the holder of key
+func callWatchlists(router *gin.Engine, key, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Test watchlists are saved, listed, replaced and deleted per API key
+func TestWatchlistCRUD(t *testing.T) {
+	router, watchlists := setupWatchlistsRouter(t)
+
+	w := callWatchlists(router, "alice", "POST", "/v1/watchlists", `{"name": "Regrets", "scenarios": [{"amount": "1000USD", "ticker": "aapl", "buyDate": "2025-07-18"}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created watchlist
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "/v1/watchlists/"+created.ID, w.Header().Get("Location"))
+	assert.Equal(t, "AAPL", created.Scenarios[0].Ticker)
+	assert.Equal(t, "stock", created.Scenarios[0].Type)
+
+	w = callWatchlists(router, "alice", "GET", "/v1/watchlists", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), created.ID)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// Other keys don't see it
+	w = callWatchlists(router, "bob", "GET", "/v1/watchlists", "")
+	assert.JSONEq(t, `{"watchlists": []}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, callWatchlists(router, "bob", "GET", "/v1/watchlists/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, callWatchlists(router, "bob", "DELETE", "/v1/watchlists/"+created.ID, "").Code)
+
+	w = callWatchlists(router, "alice", "PUT", "/v1/watchlists/"+created.ID, `{"name": "Big regrets", "scenarios": []}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated watchlist
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, "Big regrets", updated.Name)
+	assert.Empty(t, updated.Scenarios)
+	assert.Equal(t, created.CreatedAt, updated.CreatedAt)
+
+	// Saved watchlists survive a restart, without the keys in the file
+	reloaded, err := newWatchlistStore(watchlists.path)
+	assert.NoError(t, err)
+	assert.Len(t, reloaded.watchlists, 1)
+	for owner := range reloaded.watchlists {
+		assert.Len(t, owner, 64)
+	}
+
+	assert.Equal(t, http.StatusNoContent, callWatchlists(router, "alice", "DELETE", "/v1/watchlists/"+created.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, callWatchlists(router, "alice", "GET", "/v1/watchlists/"+created.ID, "").Code)
+}
+
+// Test watchlists need a known API key
+func TestWatchlistAPIKey(t *testing.T) {
+	router, _ := setupWatchlistsRouter(t)
+	assert.Equal(t, http.StatusUnauthorized, callWatchlists(router, "", "GET", "/v1/watchlists", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, callWatchlists(router, "mallory", "GET", "/v1/watchlists", "").Code)
+
+	gin.SetMode(gin.TestMode)
+	router = gin.New()
+	router.GET("/v1/watchlists", withAPIKey(nil), func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := callWatchlists(router, "alice", "GET", "/v1/watchlists", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "API_KEYS")
+}
+
+// Test watchlist requests are checked
+func TestWatchlistValidation(t *testing.T) {
+	router, _ := setupWatchlistsRouter(t)
+
+	for _, body := range []string{
+		`{"scenarios": []}`,
+		`{"name": "x", "scenarios": [{"ticker": "AAPL", "buyDate": "2025-07-18"}]}`,
+		`{"name": "x", "scenarios": [{"amount": "lots", "ticker": "AAPL", "buyDate": "2025-07-18"}]}`,
+		`{"name": "x", "scenarios": [{"amount": "10", "ticker": "AAPL", "buyDate": "18/07/2025"}]}`,
+		`{"name": "x", "scenarios": [{"amount": "10", "ticker": "AAPL", "buyDate": "2999-01-01"}]}`,
+		`{"name": "x", "scenarios": [{"amount": "10", "ticker": "US10Y", "type": "bond", "buyDate": "2025-07-18"}]}`,
+		`{"name": "x", "scenarios": [` + strings.Repeat(`{"amount": "1", "ticker": "AAPL", "buyDate": "2025-07-18"},`, maxWatchlistScenarios) + `{"amount": "1", "ticker": "AAPL", "buyDate": "2025-07-18"}]}`,
+	} {
+		w := callWatchlists(router, "alice", "POST", "/v1/watchlists", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "Invalid watchlist request", body)
+	}
+}
+
+// Test a watchlist is valued at the latest prices, with totals per currency
+func TestWatchlistEvaluate(t *testing.T) {
+	setupMockAlphaVantage(t)
+	setupMockCoinGecko(t)
+	router, _ := setupWatchlistsRouter(t)
+
+	w := callWatchlists(router, "alice", "POST", "/v1/watchlists", `{"name": "Regrets", "scenarios": [
+		{"name": "Apple", "amount": "1000USD", "ticker": "AAPL", "buyDate": "2025-07-18"},
+		{"amount": "10", "ticker": "AAPL", "buyDate": "2025-07-18"},
+		{"amount": "2", "ticker": "SOLANA", "type": "crypto", "buyDate": "2025-03-31"},
+		{"amount": "1", "ticker": "NOPE", "type": "crypto", "buyDate": "2025-03-31"}]}`)
+	var created watchlist
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = callWatchlists(router, "alice", "GET", "/v1/watchlists/"+created.ID+"/evaluate", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result struct {
+		Scenarios []scenarioResult          `json:"scenarios"`
+		Totals    map[string]watchlistTotal `json:"totals"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Len(t, result.Scenarios, 4)
+
+	apple := result.Scenarios[0]
+	assert.Equal(t, "Apple", apple.Name)
+	assert.InDelta(t, 1000/211.18, apple.Shares, 1e-9)
+	assert.InDelta(t, 1000, apple.Invested, 1e-9)
+	assert.InDelta(t, 1000/211.18*212.48, apple.Value, 1e-9)
+	assert.InDelta(t, (212.48/211.18-1)*100, *apple.ReturnPct, 1e-9)
+
+	shares := result.Scenarios[1]
+	assert.InDelta(t, 2111.8, shares.Invested, 1e-9)
+	assert.InDelta(t, 2124.8, shares.Value, 1e-9)
+
+	sol := result.Scenarios[2]
+	assert.InDelta(t, 250, sol.Invested, 1e-9)
+	assert.InDelta(t, 355, sol.Value, 1e-9)
+	assert.InDelta(t, 105, sol.Gain, 1e-9)
+
+	assert.NotEmpty(t, result.Scenarios[3].Error)
+
+	total := result.Totals["USD"]
+	assert.InDelta(t, 1000+2111.8+250, total.Invested, 1e-9)
+	assert.InDelta(t, apple.Value+2124.8+355, total.Value, 1e-9)
+}
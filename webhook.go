@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Waits between webhook delivery attempts; a callback is tried once more than this
+var webhookRetryDelays = []time.Duration{time.Second, 5 * time.Second, 25 * time.Second}
+
+// Delivery of a finished job to its callback URL
+type callback struct {
+	URL      string `json:"url"`
+	Status   string `json:"status"` // pending, delivered or failed
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Helper function to check a callback URL is http(s), and unless allowPrivate, not
+// on localhost or a private, loopback or link-local address
+func checkCallbackURL(raw string, allowPrivate bool) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("callbackUrl %q is not an http(s) URL", raw)
+	}
+	if allowPrivate {
+		return nil
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("callbackUrl %q is on a private address", raw)
+	}
+	if ip := net.ParseIP(host); ip != nil && isPrivateIP(ip) {
+		return fmt.Errorf("callbackUrl %q is on a private address", raw)
+	}
+	return nil
+}
+
+// Helper function to tell whether an IP is private, loopback, link-local or
+// unspecified: somewhere webhooks mustn't reach unless allowed
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// Clients for webhooks: to public addresses only, or anywhere with
+// WEBHOOK_ALLOW_PRIVATE
+var (
+	publicWebhookClient  = newWebhookClient(false)
+	privateWebhookClient = newWebhookClient(true)
+)
+
+// Build a client for webhooks. Unless allowPrivate, every connection is checked
+// when it's dialled, against the address the hostname actually resolved to, so a
+// public name pointing at a private address is refused as well. Webhooks go
+// direct, not through the outbound proxy, which would hide that address, and
+// redirects aren't followed: a callback URL is the one place it's delivered.
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivateIP(ip) {
+				return fmt.Errorf("webhook to %s refused: it's a private address", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: countingTransport{transport},
+		Timeout:   30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return errors.New("webhooks don't follow redirects")
+		},
+	}
+}
+
+// Sign a webhook body sent at timestamp (Unix seconds): "sha256=" and the hex
+// HMAC-SHA256, keyed with the webhook secret, of "<timestamp>.<body>"
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Helper function to POST a signed webhook body once, only to a public address
+// unless allowPrivate
+func postWebhook(callbackURL, secret, id string, body []byte, allowPrivate bool) error {
+	req, err := http.NewRequest(http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", id)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", signWebhook(secret, timestamp, body))
+
+	client := publicWebhookClient
+	if allowPrivate {
+		client = privateWebhookClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// POST a finished job to its callback URL, retrying failures, and record how it went
+func (q *jobQueue) deliverCallback(j *job) {
+	q.mu.Lock()
+	body, err := json.Marshal(j)
+	callbackURL := j.Callback.URL
+	q.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := postWebhook(callbackURL, q.webhookSecret, j.ID, body, q.webhookAllowPrivate)
+
+		q.mu.Lock()
+		j.Callback.Attempts = attempt + 1
+		if err == nil {
+			j.Callback.Status, j.Callback.Error = "delivered", ""
+		} else {
+			j.Callback.Error = err.Error()
+			if attempt >= len(webhookRetryDelays) {
+				j.Callback.Status = "failed"
+			}
+		}
+		done := j.Callback.Status != "pending"
+		q.mu.Unlock()
+
+		if done {
+			return
+		}
+		time.Sleep(webhookRetryDelays[attempt])
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test webhook signatures cover the timestamp and body
+func TestSignWebhook(t *testing.T) {
+	signature := signWebhook("whsec", 1700000000, []byte(`{"id":"abc"}`))
+	assert.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	assert.Equal(t, signature, signWebhook("whsec", 1700000000, []byte(`{"id":"abc"}`)))
+	assert.NotEqual(t, signature, signWebhook("whsec", 1700000001, []byte(`{"id":"abc"}`)))
+	assert.NotEqual(t, signature, signWebhook("other", 1700000000, []byte(`{"id":"abc"}`)))
+}
+
+// Test callback URLs must be http(s) and, by default, public
+func TestCheckCallbackURL(t *testing.T) {
+	assert.NoError(t, checkCallbackURL("https://hooks.example.com/backtests", false))
+	for _, private := range []string{"http://localhost:9000/", "http://127.0.0.1/", "http://10.0.0.5/", "http://169.254.169.254/latest", "http://[::1]/", "http://0.0.0.0/"} {
+		assert.ErrorContains(t, checkCallbackURL(private, false), "private address", private)
+		assert.NoError(t, checkCallbackURL(private, true), private)
+	}
+	assert.ErrorContains(t, checkCallbackURL("ftp://hooks.example.com/", false), "is not an http(s) URL")
+	assert.ErrorContains(t, checkCallbackURL("/relative", true), "is not an http(s) URL")
+}
+
+// Test webhooks are checked against the address they're dialled on, and don't
+// follow redirects
+func TestPostWebhookPrivateAddress(t *testing.T) {
+	var hits int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer hook.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, hook.URL, http.StatusTemporaryRedirect)
+	}))
+	defer redirect.Close()
+
+	// A hostname that resolves to loopback is refused when it's dialled
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(hook.URL, "http://"))
+	named := "http://localhost:" + port + "/"
+	err := postWebhook(named, "whsec", "abc", []byte(`{}`), false)
+	assert.ErrorContains(t, err, "private address")
+	assert.ErrorContains(t, postWebhook(hook.URL, "whsec", "abc", []byte(`{}`), false), "private address")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits))
+
+	assert.NoError(t, postWebhook(hook.URL, "whsec", "abc", []byte(`{}`), true))
+	assert.ErrorContains(t, postWebhook(redirect.URL, "whsec", "abc", []byte(`{}`), true), "redirects")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+// Test a finished job is POSTed, signed, to its callback URL
+func TestJobCallbackDelivered(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer hook.Close()
+
+	release := make(chan struct{})
+	close(release)
+	router := setupJobsRouter(1, 10, release)
+	w := submitJob(router, `{"path": "/slow/1000", "callbackUrl": "`+hook.URL+`/done"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var submitted job
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.Equal(t, "pending", submitted.Callback.Status)
+
+	var req *http.Request
+	select {
+	case req = <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback not delivered")
+	}
+	body := <-bodies
+	assert.Equal(t, "/done", req.URL.Path)
+	assert.Equal(t, submitted.ID, req.Header.Get("X-Webhook-Id"))
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	assert.NoError(t, err)
+	assert.Equal(t, signWebhook("whsec", timestamp, body), req.Header.Get("X-Webhook-Signature"))
+
+	var delivered job
+	assert.NoError(t, json.Unmarshal(body, &delivered))
+	assert.Equal(t, jobSucceeded, delivered.Status)
+	assert.JSONEq(t, `{"amount": "1000", "lang": "de"}`, string(delivered.Result))
+
+	for i := 0; i < 100; i++ {
+		if finished := waitForJob(t, router, submitted.ID); finished.Callback.Status == "delivered" {
+			assert.Equal(t, 1, finished.Callback.Attempts)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("callback not recorded as delivered")
+}
+
+// Test failed deliveries are retried, then recorded as failed
+func TestJobCallbackFailed(t *testing.T) {
+	oldDelays := webhookRetryDelays
+	webhookRetryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	defer func() { webhookRetryDelays = oldDelays }()
+
+	var attempts int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	release := make(chan struct{})
+	close(release)
+	router := setupJobsRouter(1, 10, release)
+	var submitted job
+	json.Unmarshal(submitJob(router, `{"path": "/slow/1", "callbackUrl": "`+hook.URL+`"}`).Body.Bytes(), &submitted)
+
+	for i := 0; i < 200; i++ {
+		if finished := waitForJob(t, router, submitted.ID); finished.Callback.Status == "failed" {
+			assert.Equal(t, 3, finished.Callback.Attempts)
+			assert.Equal(t, "callback answered 500", finished.Callback.Error)
+			assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("callback not recorded as failed")
+}
+
+// Test callbacks are refused without a webhook secret, or to private addresses
+func TestJobCallbackRejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	jobs := newJobQueue(router, 1, 1, time.Hour, "", false)
+	router.POST("/v1/jobs", jobs.handleSubmit)
+	w := submitJob(router, `{"path": "/10/AAPL/on/2020-01-01", "callbackUrl": "https://hooks.example.com/"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "WEBHOOK_SECRET")
+
+	router = gin.New()
+	jobs = newJobQueue(router, 1, 1, time.Hour, "whsec", false)
+	router.POST("/v1/jobs", jobs.handleSubmit)
+	w = submitJob(router, `{"path": "/10/AAPL/on/2020-01-01", "callbackUrl": "http://127.0.0.1:9000/"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "private address")
+}
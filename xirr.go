@@ -0,0 +1,74 @@
+package main
+
+import (
+	"math"
+
+	"github.com/gin-gonic/gin"
+)
+
+// A dated cash flow of a position: negative for money put in, positive for money
+// taken out (dividends paid, the sale)
+type cashFlow struct {
+	Date   string
+	Amount float64
+}
+
+// Helper function to get the value of cash flows discounted at an annual rate
+// to the first flow's date, with years of 365 days
+func netPresentValue(flows []cashFlow, rate float64) float64 {
+	start := dateOnly(flows[0].Date)
+	total := 0.0
+	for _, flow := range flows {
+		years := float64(daysBetween(start, dateOnly(flow.Date))) / 365
+		total += flow.Amount / math.Pow(1+rate, years)
+	}
+	return total
+}
+
+// Calculate the money-weighted return (XIRR) of cash flows, oldest first: the
+// annual rate at which they're worth nothing together. Found by bisection, which
+// always converges once the rate is bracketed; false when it can't be, such as
+// flows that never change sign.
+func xirr(flows []cashFlow) (float64, bool) {
+	if len(flows) < 2 {
+		return 0, false
+	}
+	low, high := -0.999999, 1.0
+	lowValue := netPresentValue(flows, low)
+	for netPresentValue(flows, high)*lowValue > 0 {
+		if high *= 2; high > 1e6 {
+			return 0, false
+		}
+	}
+
+	for i := 0; i < 200 && high-low > 1e-12; i++ {
+		mid := (low + high) / 2
+		value := netPresentValue(flows, mid)
+		if value*lowValue > 0 {
+			low, lowValue = mid, value
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, true
+}
+
+// Add an "xirrPercent" to a response (or block of one): the money-weighted annual
+// return of its cash flows, which unlike an annualized simple return accounts for
+// when money went in and came out. Left out when there isn't one.
+func addXIRR(response gin.H, flows []cashFlow) {
+	if rate, ok := xirr(flows); ok {
+		response["xirrPercent"] = rate * 100
+	}
+}
+
+// Helper function to list the cash flows of a position whose dividends were paid
+// out, in USD: the buy, each dividend on its payment date (the interest it earns
+// after is the cash's, not the position's) and the sale
+func dividendCashFlows(costUSD float64, buyDate string, payments []cashDividend, proceedsUSD float64, sellDate string) []cashFlow {
+	flows := []cashFlow{{buyDate, -costUSD}}
+	for _, payment := range payments {
+		flows = append(flows, cashFlow{payment.PaymentDate, payment.Amount})
+	}
+	return append(flows, cashFlow{sellDate, proceedsUSD})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test XIRR against returns known in closed form, and flows without one
+func TestXIRR(t *testing.T) {
+	// One flow in and one out a year later is the simple return
+	rate, ok := xirr([]cashFlow{{"2023-01-01", -1000}, {"2024-01-01", 1100}})
+	assert.True(t, ok)
+	assert.InDelta(t, 0.1, rate, 1e-9)
+
+	// 1000 in, then another 1000 a year later, worth 2310 after two years: 10% a year
+	rate, ok = xirr([]cashFlow{{"2021-01-01", -1000}, {"2022-01-01", -1000}, {"2023-01-01", 2310}})
+	assert.True(t, ok)
+	assert.InDelta(t, 0.1, rate, 1e-9)
+
+	// Losses are negative
+	rate, ok = xirr([]cashFlow{{"2023-01-01", -1000}, {"2024-01-01", 500}})
+	assert.True(t, ok)
+	assert.InDelta(t, -0.5, rate, 1e-9)
+
+	_, ok = xirr([]cashFlow{{"2023-01-01", -1000}, {"2024-01-01", -100}})
+	assert.False(t, ok)
+	_, ok = xirr([]cashFlow{{"2023-01-01", -1000}})
+	assert.False(t, ok)
+}
+
+// Test dividends paid out count on the day they're paid
+func TestDividendsXIRRWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := makeTestRequest(router, "GET", "/10/AAPL/on/2025-03-31/and-sold-on/2025-07-18/with-dividends")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		XIRRPercent float64 `json:"xirrPercent"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	flows := []cashFlow{{"2025-03-31", -2005}, {"2025-05-15", 2.6}, {"2025-08-14", 2.6}, {"2025-07-18", 2111.8}}
+	assert.InDelta(t, 0, netPresentValue(flows, response.XIRRPercent/100), 1e-6)
+}
+
+// Test a scenario's XIRR weighs each lot by how long it was held
+func TestScenarioXIRRWithMocks(t *testing.T) {
+	setupMockAlphaVantage(t)
+	router := setupTestRouterWithMocks()
+
+	w := postScenario(router, `{
+		"asset": {"ticker": "AAPL"},
+		"legs": [{"side": "buy", "date": "2025-03-31", "amount": "10"}, {"side": "buy", "date": "2025-06-20", "amount": "5"}, {"side": "sell", "date": "2025-07-18"}]
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		XIRRPercent   float64 `json:"xirrPercent"`
+		ReturnPercent float64 `json:"returnPercent"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	flows := []cashFlow{{"2025-03-31", -2005}, {"2025-06-20", -1028.75}, {"2025-07-18", 15 * 211.18}}
+	assert.InDelta(t, 0, netPresentValue(flows, response.XIRRPercent/100), 1e-6)
+
+	// Annualizing the blended return over the whole window understates it, since
+	// a third of the money was only in for four weeks
+	days := float64(daysBetween("2025-03-31", "2025-07-18"))
+	assert.Greater(t, response.XIRRPercent, (math.Pow(1+response.ReturnPercent/100, 365/days)-1)*100)
+}